@@ -0,0 +1,184 @@
+package leego
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+type (
+	// Operation is a long-running operation accepted with `Context#AcceptAsync()`.
+	Operation struct {
+		ID        string          `json:"id"`
+		Status    OperationStatus `json:"status"`
+		Progress  int             `json:"progress"`
+		Message   string          `json:"message,omitempty"`
+		Result    interface{}     `json:"result,omitempty"`
+		Error     string          `json:"error,omitempty"`
+		CreatedAt time.Time       `json:"created_at"`
+		UpdatedAt time.Time       `json:"updated_at"`
+	}
+
+	// OperationStatus is the status of an operation.
+	OperationStatus string
+
+	// OperationFunc runs an operation in the background. It reports its
+	// progress with p and returns its result.
+	OperationFunc func(ctx context.Context, p *Progress) (interface{}, error)
+
+	// OperationStore stores the operations, e.g. in a database shared by the
+	// instances of a service. See `NewOperationMemoryStore()`.
+	OperationStore interface {
+		// Save creates or updates op.
+		Save(op *Operation) error
+
+		// Get returns the operation with id, or `ErrOperationNotFound`.
+		Get(id string) (*Operation, error)
+	}
+
+	// Progress reports the progress of an operation.
+	Progress struct {
+		store OperationStore
+		op    Operation
+	}
+
+	operationMemoryStore struct {
+		mu  sync.RWMutex
+		ops map[string]Operation
+	}
+)
+
+// Operation statuses
+const (
+	OperationRunning   OperationStatus = "running"
+	OperationSucceeded OperationStatus = "succeeded"
+	OperationFailed    OperationStatus = "failed"
+)
+
+// Errors
+var (
+	ErrOperationNotFound   = errors.New("operation not found")
+	ErrOperationsNotServed = errors.New("operations not served, see Leego#Operations()")
+)
+
+// NewOperationMemoryStore returns an `OperationStore` keeping the operations in
+// memory. Operations are kept until the process exits.
+func NewOperationMemoryStore() OperationStore {
+	return &operationMemoryStore{ops: make(map[string]Operation)}
+}
+
+// Operations registers the status endpoint of the operations accepted with
+// `Context#AcceptAsync()` at prefix + "/:id", with optional route-level
+// middleware, and sets their store. A nil store keeps them in memory.
+//
+// The endpoint responds with the `Operation` as JSON, including its result
+// once it succeeded, or 404 for an unknown ID.
+func (e *Leego) Operations(prefix string, store OperationStore, m ...MiddlewareFunc) *Route {
+	if store == nil {
+		store = NewOperationMemoryStore()
+	}
+	e.operationsPrefix, e.operationStore = prefix, store
+	return e.GET(prefix+"/:id", func(c Context) LeeError {
+		op, err := store.Get(c.Param("id"))
+		if err == ErrOperationNotFound {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, op)
+	}, m...)
+}
+
+func (c *leegoContext) AcceptAsync(fn OperationFunc) (string, error) {
+	store := c.leego.operationStore
+	if store == nil {
+		return "", ErrOperationsNotServed
+	}
+	id, err := operationID()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	p := &Progress{store: store, op: Operation{
+		ID:        id,
+		Status:    OperationRunning,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}}
+	if err = store.Save(&p.op); err != nil {
+		return "", err
+	}
+
+	op := p.op
+
+	// The request context ends with the response.
+	go p.run(context.Background(), fn)
+
+	url := c.leego.operationsPrefix + "/" + id
+	c.response.Header().Set(HeaderLocation, url)
+	return url, c.JSON(http.StatusAccepted, &op)
+}
+
+// Update saves the progress of the operation, in percent, with an optional
+// message.
+func (p *Progress) Update(percent int, message string) error {
+	p.op.Progress = percent
+	p.op.Message = message
+	p.op.UpdatedAt = time.Now()
+	return p.store.Save(&p.op)
+}
+
+func (p *Progress) run(ctx context.Context, fn OperationFunc) {
+	var (
+		result interface{}
+		err    error
+	)
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+		if err != nil {
+			p.op.Status = OperationFailed
+			p.op.Error = err.Error()
+		} else {
+			p.op.Status = OperationSucceeded
+			p.op.Progress = 100
+			p.op.Result = result
+		}
+		p.op.UpdatedAt = time.Now()
+		p.store.Save(&p.op)
+	}()
+	result, err = fn(ctx, p)
+}
+
+func operationID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (s *operationMemoryStore) Save(op *Operation) error {
+	s.mu.Lock()
+	s.ops[op.ID] = *op
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *operationMemoryStore) Get(id string) (*Operation, error) {
+	s.mu.RLock()
+	op, ok := s.ops[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrOperationNotFound
+	}
+	return &op, nil
+}
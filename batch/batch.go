@@ -0,0 +1,181 @@
+// Package batch serves several requests sent in one request, to save round
+// trips, e.g. for mobile clients:
+//
+//	lee.POST("/batch", batch.Handler(lee))
+//
+// The body is a JSON array of requests:
+//
+//	[
+//		{"method": "GET", "path": "/users/1"},
+//		{"method": "POST", "path": "/orders", "body": {"item": "book"}}
+//	]
+//
+// They are served in order through the router, with the headers of the batch
+// request, e.g. Authorization, so they share its authentication. The response
+// is a JSON array of the responses, each with its own status.
+package batch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+)
+
+type (
+	// Config defines the config for the batch handler.
+	Config struct {
+		// MaxRequests is the maximum number of requests in a batch.
+		// Optional. Default value 20.
+		MaxRequests int `json:"max_requests"`
+	}
+
+	// Request is a request of a batch.
+	Request struct {
+		Method  string            `json:"method"`
+		Path    string            `json:"path"`
+		Headers map[string]string `json:"headers,omitempty"`
+
+		// Body is sent as is with a JSON Content-Type by default.
+		Body json.RawMessage `json:"body,omitempty"`
+	}
+
+	// Response is the response to a request of a batch.
+	Response struct {
+		Status  int               `json:"status"`
+		Headers map[string]string `json:"headers,omitempty"`
+
+		// Body is embedded as is if it's JSON, otherwise as a string.
+		Body json.RawMessage `json:"body,omitempty"`
+	}
+
+	responseWriter struct {
+		header http.Header
+		code   int
+		body   bytes.Buffer
+	}
+)
+
+var (
+	// DefaultConfig is the default batch handler config.
+	DefaultConfig = Config{
+		MaxRequests: 20,
+	}
+)
+
+// Handler returns a handler serving batches with lee.
+func Handler(lee *leego.Leego) leego.HandlerFunc {
+	return HandlerWithConfig(lee, DefaultConfig)
+}
+
+// HandlerWithConfig returns a batch handler from config.
+// See `Handler()`.
+func HandlerWithConfig(lee *leego.Leego, config Config) leego.HandlerFunc {
+	// Defaults
+	if config.MaxRequests == 0 {
+		config.MaxRequests = DefaultConfig.MaxRequests
+	}
+
+	return func(c leego.Context) leego.LeeError {
+		var reqs []Request
+		if err := json.NewDecoder(c.Request().Body()).Decode(&reqs); err != nil {
+			return leego.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if len(reqs) > config.MaxRequests {
+			return leego.NewHTTPError(http.StatusRequestEntityTooLarge,
+				fmt.Sprintf("batch of %d requests exceeds %d", len(reqs), config.MaxRequests))
+		}
+		res := make([]Response, len(reqs))
+		for i, r := range reqs {
+			res[i] = serve(lee, c, r)
+		}
+		return c.JSON(http.StatusOK, res)
+	}
+}
+
+// serve serves r as a sub-request of the batch request of c.
+func serve(lee *leego.Leego, c leego.Context, r Request) Response {
+	u, err := url.ParseRequestURI(r.Path)
+	if err != nil || r.Method == "" {
+		return errorResponse(http.StatusBadRequest, "invalid request")
+	}
+	if u.Path == c.Request().URL().Path() {
+		return errorResponse(http.StatusBadRequest, "nested batch")
+	}
+
+	parent := c.Request()
+	req := &http.Request{
+		Method:     strings.ToUpper(r.Method),
+		URL:        u,
+		RequestURI: r.Path,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+		Host:       parent.Host(),
+		RemoteAddr: parent.RemoteAddress(),
+	}
+	for _, k := range parent.Header().Keys() {
+		if k == leego.HeaderContentType || k == leego.HeaderContentLength {
+			continue
+		}
+		req.Header.Set(k, parent.Header().Get(k))
+	}
+	if len(r.Body) > 0 {
+		req.Body = ioutil.NopCloser(bytes.NewReader(r.Body))
+		req.ContentLength = int64(len(r.Body))
+		req.Header.Set(leego.HeaderContentType, leego.MIMEApplicationJSON)
+	}
+	for k, v := range r.Headers {
+		req.Header.Set(k, v)
+	}
+	req = req.WithContext(c.Context())
+
+	w := &responseWriter{header: make(http.Header)}
+	lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(w))
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	res := Response{Status: w.code}
+	for k := range w.header {
+		if res.Headers == nil {
+			res.Headers = make(map[string]string)
+		}
+		res.Headers[k] = w.header.Get(k)
+	}
+	if w.body.Len() > 0 {
+		if strings.HasPrefix(w.header.Get(leego.HeaderContentType), leego.MIMEApplicationJSON) && json.Valid(w.body.Bytes()) {
+			res.Body = w.body.Bytes()
+		} else {
+			res.Body, _ = json.Marshal(w.body.String())
+		}
+	}
+	return res
+}
+
+func errorResponse(code int, msg string) Response {
+	b, _ := json.Marshal(msg)
+	return Response{Status: code, Body: b}
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
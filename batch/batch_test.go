@@ -0,0 +1,57 @@
+package batch_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/batch"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	lee := leego.New()
+	lee.GET("/users/:id", func(c leego.Context) leego.LeeError {
+		if c.Request().Header().Get(leego.HeaderAuthorization) != "Bearer token" {
+			return leego.ErrUnauthorized
+		}
+		return c.JSON(http.StatusOK, map[string]string{"id": c.Param("id")})
+	})
+	lee.POST("/echo", func(c leego.Context) leego.LeeError {
+		m := map[string]string{}
+		if err := c.Bind(&m); err != nil {
+			return err
+		}
+		return c.String(http.StatusCreated, m["item"])
+	})
+	lee.POST("/batch", batch.HandlerWithConfig(lee, batch.Config{MaxRequests: 4}))
+
+	body := `[{"method":"GET","path":"/users/1"},{"method":"POST","path":"/echo","body":{"item":"book"}},` +
+		`{"method":"GET","path":"/missing"},{"method":"POST","path":"/batch"}]`
+	req := httptest.NewRequest(leego.POST, "/batch", strings.NewReader(body))
+	req.Header.Set(leego.HeaderContentType, leego.MIMEApplicationJSON)
+	req.Header.Set(leego.HeaderAuthorization, "Bearer token")
+	rec := httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var res []batch.Response
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	if assert.Len(t, res, 4) {
+		assert.Equal(t, http.StatusOK, res[0].Status)
+		assert.Equal(t, `{"id":"1"}`, strings.TrimSpace(string(res[0].Body)))
+		assert.Equal(t, http.StatusCreated, res[1].Status)
+		assert.Equal(t, `"book"`, string(res[1].Body))
+		assert.Equal(t, http.StatusNotFound, res[2].Status)
+		assert.Equal(t, http.StatusBadRequest, res[3].Status)
+	}
+
+	req = httptest.NewRequest(leego.POST, "/batch", strings.NewReader("[{},{},{},{},{}]"))
+	rec = httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
@@ -0,0 +1,305 @@
+// Package bench provides a load harness which runs representative route sets
+// against each engine and middleware stack and reports comparable numbers.
+package bench
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/go-wyvern/leego/middleware"
+)
+
+type (
+	// Route is a single route registered by a route set.
+	Route struct {
+		Method string
+		Path   string
+		// Request is the concrete path sent to the server to hit this route.
+		Request string
+	}
+
+	// RouteSet is a named collection of routes.
+	RouteSet struct {
+		Name   string
+		Routes []Route
+	}
+
+	// Engine creates an `engine.Server` listening on the provided listener.
+	Engine struct {
+		Name string
+		New  func(net.Listener) engine.Server
+	}
+
+	// Stack is a named middleware combination.
+	Stack struct {
+		Name       string
+		Pre        []leego.MiddlewareFunc
+		Middleware []leego.MiddlewareFunc
+	}
+
+	// Config defines the load generated for each scenario.
+	Config struct {
+		// Duration of the load for each scenario.
+		Duration time.Duration
+
+		// Connections is the number of concurrent client connections.
+		Connections int
+	}
+
+	// Result holds the measurements of a single scenario.
+	Result struct {
+		Engine    string
+		Stack     string
+		RouteSet  string
+		Requests  int64
+		Errors    int64
+		Duration  time.Duration
+		Latencies []time.Duration
+	}
+
+	// Report is a set of results.
+	Report []Result
+)
+
+var (
+	// DefaultConfig is the default load config.
+	DefaultConfig = Config{
+		Duration:    5 * time.Second,
+		Connections: 64,
+	}
+
+	// Engines are the engines compared by the harness.
+	Engines = []Engine{
+		{
+			Name: "standard",
+			New: func(l net.Listener) engine.Server {
+				return standard.WithConfig(engine.Config{Listener: l})
+			},
+		},
+	}
+
+	// Stacks are the middleware combinations compared by the harness.
+	Stacks = []Stack{
+		{Name: "bare"},
+		{
+			Name: "slash",
+			Pre:  []leego.MiddlewareFunc{middleware.RemoveTrailingSlash()},
+		},
+	}
+
+	// RouteSets are the route sets compared by the harness.
+	RouteSets = []RouteSet{
+		{
+			Name: "static",
+			Routes: []Route{
+				{GET, "/", "/"},
+				{GET, "/users", "/users"},
+				{GET, "/users/search", "/users/search"},
+				{GET, "/repos/trending", "/repos/trending"},
+				{POST, "/events", "/events"},
+			},
+		},
+		{
+			Name: "param",
+			Routes: []Route{
+				{GET, "/users/:id", "/users/42"},
+				{GET, "/users/:id/repos", "/users/42/repos"},
+				{GET, "/repos/:owner/:repo", "/repos/go-wyvern/leego"},
+				{GET, "/repos/:owner/:repo/issues/:number", "/repos/go-wyvern/leego/issues/7"},
+				{DELETE, "/repos/:owner/:repo", "/repos/go-wyvern/leego"},
+			},
+		},
+		{
+			Name: "any",
+			Routes: []Route{
+				{GET, "/static/*", "/static/css/app.css"},
+				{GET, "/files/:bucket/*", "/files/assets/img/logo.png"},
+			},
+		},
+	}
+)
+
+// HTTP methods used by the route sets.
+const (
+	GET    = leego.GET
+	POST   = leego.POST
+	DELETE = leego.DELETE
+)
+
+var ok = []byte("ok")
+
+// NewLeego returns a leego instance with the route set and middleware stack
+// registered.
+func NewLeego(rs RouteSet, s Stack) *leego.Leego {
+	lee := leego.New()
+	lee.Pre(s.Pre...)
+	lee.Use(s.Middleware...)
+	for _, r := range rs.Routes {
+		lee.Add(r.Method, r.Path, handler)
+	}
+	return lee
+}
+
+func handler(c leego.Context) leego.LeeError {
+	c.Response().WriteHeader(http.StatusOK)
+	_, err := c.Response().Write(ok)
+	return err
+}
+
+// Run runs every engine, stack and route set combination with the config and
+// returns the report.
+func Run(c Config) (r Report, err error) {
+	for _, e := range Engines {
+		for _, s := range Stacks {
+			for _, rs := range RouteSets {
+				res, err := RunScenario(c, e, s, rs)
+				if err != nil {
+					return r, err
+				}
+				r = append(r, res)
+			}
+		}
+	}
+	return
+}
+
+// RunScenario starts the engine with the route set and stack on a loopback
+// listener and drives load against it for `Config#Duration`.
+func RunScenario(c Config, e Engine, s Stack, rs RouteSet) (res Result, err error) {
+	if c.Duration <= 0 {
+		c.Duration = DefaultConfig.Duration
+	}
+	if c.Connections <= 0 {
+		c.Connections = DefaultConfig.Connections
+	}
+	res = Result{Engine: e.Name, Stack: s.Name, RouteSet: rs.Name}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return
+	}
+	srv := e.New(l)
+	lee := NewLeego(rs, s)
+	go lee.Run(srv)
+	defer srv.Stop()
+
+	base := "http://" + l.Addr().String()
+	client := &http.Client{
+		Transport: &http.Transport{
+			MaxIdleConnsPerHost: c.Connections,
+		},
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		requests int64
+		errors   int64
+		stop     = make(chan struct{})
+	)
+	start := time.Now()
+	for i := 0; i < c.Connections; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			lat := make([]time.Duration, 0, 1024)
+			for n := i; ; n++ {
+				select {
+				case <-stop:
+					mu.Lock()
+					res.Latencies = append(res.Latencies, lat...)
+					mu.Unlock()
+					return
+				default:
+				}
+				r := rs.Routes[n%len(rs.Routes)]
+				t := time.Now()
+				if err := do(client, r.Method, base+r.Request); err != nil {
+					atomic.AddInt64(&errors, 1)
+					continue
+				}
+				lat = append(lat, time.Since(t))
+				atomic.AddInt64(&requests, 1)
+			}
+		}(i)
+	}
+	time.Sleep(c.Duration)
+	close(stop)
+	wg.Wait()
+
+	res.Duration = time.Since(start)
+	res.Requests = requests
+	res.Errors = errors
+	sort.Slice(res.Latencies, func(i, j int) bool {
+		return res.Latencies[i] < res.Latencies[j]
+	})
+	return
+}
+
+func do(client *http.Client, method, url string) error {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d for %s %s", res.StatusCode, method, url)
+	}
+	return nil
+}
+
+// RPS returns the requests per second of the result.
+func (r Result) RPS() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Requests) / r.Duration.Seconds()
+}
+
+// Percentile returns the latency percentile `p` (0-100) of the result.
+func (r Result) Percentile(p float64) time.Duration {
+	l := len(r.Latencies)
+	if l == 0 {
+		return 0
+	}
+	i := int(float64(l-1) * p / 100)
+	return r.Latencies[i]
+}
+
+// WriteTo writes the report as an aligned table.
+func (r Report) WriteTo(w io.Writer) (int64, error) {
+	buf := new(bytes.Buffer)
+	tw := tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENGINE\tSTACK\tROUTES\tREQUESTS\tERRORS\tRPS\tP50\tP99")
+	for _, res := range r {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%.0f\t%v\t%v\n",
+			res.Engine, res.Stack, res.RouteSet, res.Requests, res.Errors,
+			res.RPS(), res.Percentile(50), res.Percentile(99))
+	}
+	tw.Flush()
+	return buf.WriteTo(w)
+}
+
+// String implements `fmt.Stringer` interface.
+func (r Report) String() string {
+	buf := new(bytes.Buffer)
+	r.WriteTo(buf)
+	return buf.String()
+}
@@ -0,0 +1,52 @@
+package bench
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func BenchmarkServe(b *testing.B) {
+	for _, s := range Stacks {
+		for _, rs := range RouteSets {
+			lee := NewLeego(rs, s)
+			reqs := make([]*standard.Request, len(rs.Routes))
+			for i, r := range rs.Routes {
+				reqs[i] = standard.NewRequest(httptest.NewRequest(r.Method, r.Request, nil))
+			}
+			b.Run(s.Name+"/"+rs.Name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					rec := standard.NewResponse(httptest.NewRecorder())
+					lee.ServeHTTP(reqs[i%len(reqs)], rec)
+				}
+			})
+		}
+	}
+}
+
+func TestRouteSets(t *testing.T) {
+	for _, s := range Stacks {
+		for _, rs := range RouteSets {
+			lee := NewLeego(rs, s)
+			for _, r := range rs.Routes {
+				req := standard.NewRequest(httptest.NewRequest(r.Method, r.Request, nil))
+				rec := httptest.NewRecorder()
+				lee.ServeHTTP(req, standard.NewResponse(rec))
+				assert.Equal(t, http.StatusOK, rec.Code, s.Name+" "+r.Method+" "+r.Request)
+			}
+		}
+	}
+}
+
+func TestRunScenario(t *testing.T) {
+	res, err := RunScenario(Config{Duration: 100 * time.Millisecond, Connections: 2}, Engines[0], Stacks[0], RouteSets[0])
+	assert.NoError(t, err)
+	assert.True(t, res.Requests > 0)
+	assert.Equal(t, int64(0), res.Errors)
+	assert.Contains(t, Report{res}.String(), "standard")
+}
@@ -0,0 +1,26 @@
+// Command bench runs the leego load harness and prints the report.
+//
+// Usage `go run ./bench/cmd/bench -d 10s -c 128`
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/go-wyvern/leego/bench"
+)
+
+func main() {
+	c := bench.DefaultConfig
+	flag.DurationVar(&c.Duration, "d", c.Duration, "duration of the load for each scenario")
+	flag.IntVar(&c.Connections, "c", c.Connections, "number of concurrent connections")
+	flag.Parse()
+
+	r, err := bench.Run(c)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	r.WriteTo(os.Stdout)
+}
@@ -1,10 +1,12 @@
 package leego
 
 import (
+	"encoding"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
+	"mime/multipart"
 	"net/http"
 	"reflect"
 	"strconv"
@@ -17,13 +19,38 @@ type (
 		Bind(interface{}, Context) error
 	}
 
-	binder struct{}
+	binder struct {
+		leego *Leego
+	}
 )
 
+// Bind binds path params into fields tagged `param:"name"` and query params into
+// fields tagged `query:"name"`, then binds the request body based on the
+// Content-Type header. Form and multipart bodies are bound into fields tagged
+// `form:"name"` (or named after the field), including multipart files into
+// `*multipart.FileHeader` and `[]*multipart.FileHeader` fields, and the media
+// types registered with `Leego#RegisterCodec()` are decoded with their codec.
+// For GET requests query params are bound into the form fields instead of the
+// body.
 func (b *binder) Bind(i interface{}, c Context) (err error) {
 	req := c.Request()
+	if isStructPtr(i) {
+		names := c.ParamNames()
+		if len(names) > 0 {
+			params := make(map[string][]string, len(names))
+			for j, n := range names {
+				params[n] = []string{c.P(j)}
+			}
+			if err = b.bindData(i, params, "param"); err != nil {
+				return NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+		}
+		if err = b.bindData(i, c.QueryParams(), "query"); err != nil {
+			return NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
 	if req.Method() == GET {
-		if err = b.bindData(i, c.QueryParams()); err != nil {
+		if err = b.bindData(i, c.QueryParams(), "form"); err != nil {
 			err = NewHTTPError(http.StatusBadRequest, err.Error())
 		}
 		return
@@ -35,7 +62,7 @@ func (b *binder) Bind(i interface{}, c Context) (err error) {
 	}
 	err = ErrUnsupportedMediaType
 	switch {
-	case strings.HasPrefix(ctype, MIMEApplicationJSON):
+	case strings.HasPrefix(ctype, MIMEApplicationJSON), strings.HasPrefix(ctype, MIMEApplicationMergePatch):
 		if err = json.NewDecoder(req.Body()).Decode(i); err != nil {
 			if ute, ok := err.(*json.UnmarshalTypeError); ok {
 				err = NewHTTPError(http.StatusBadRequest, fmt.Sprintf("unmarshal type error: expected=%v, got=%v, offset=%v", ute.Type, ute.Value, ute.Offset))
@@ -56,14 +83,73 @@ func (b *binder) Bind(i interface{}, c Context) (err error) {
 			}
 		}
 	case strings.HasPrefix(ctype, MIMEApplicationForm), strings.HasPrefix(ctype, MIMEMultipartForm):
-		if err = b.bindData(i, req.FormParams()); err != nil {
+		if err = b.bindData(i, req.FormParams(), "form"); err != nil {
 			err = NewHTTPError(http.StatusBadRequest, err.Error())
+			return
+		}
+		if strings.HasPrefix(ctype, MIMEMultipartForm) && isStructPtr(i) {
+			var form *multipart.Form
+			if form, err = req.MultipartForm(); err != nil {
+				return NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			b.bindFiles(reflect.ValueOf(i).Elem(), form.File)
+		}
+	default:
+		if b.leego != nil {
+			if codec := b.leego.Codec(ctype); codec != nil {
+				err = b.decode(codec, i, c)
+			}
 		}
 	}
 	return
 }
 
-func (b *binder) bindData(ptr interface{}, data map[string][]string) error {
+var (
+	fileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeaderSliceType = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+// bindFiles binds the multipart files into the `*multipart.FileHeader` and
+// `[]*multipart.FileHeader` fields of the struct val, recursively like
+// `bindData()` does with the "form" tag.
+func (b *binder) bindFiles(val reflect.Value, files map[string][]*multipart.FileHeader) {
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		typeField := typ.Field(i)
+		structField := val.Field(i)
+		if !structField.CanSet() {
+			continue
+		}
+		name := typeField.Tag.Get("form")
+		if name == "" {
+			if structField.Kind() == reflect.Struct && !isTextUnmarshaler(structField) {
+				b.bindFiles(structField, files)
+				continue
+			}
+			name = typeField.Name
+		}
+		fhs := files[name]
+		if len(fhs) == 0 {
+			continue
+		}
+		switch typeField.Type {
+		case fileHeaderType:
+			structField.Set(reflect.ValueOf(fhs[0]))
+		case fileHeaderSliceType:
+			structField.Set(reflect.ValueOf(fhs))
+		}
+	}
+}
+
+func isStructPtr(i interface{}) bool {
+	t := reflect.TypeOf(i)
+	return t != nil && t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct
+}
+
+// bindData binds data into the fields of the struct pointed to by ptr, using
+// the field tag named tag as the data key. Only the "form" tag falls back to the
+// field name for untagged fields; untagged struct fields are bound recursively.
+func (b *binder) bindData(ptr interface{}, data map[string][]string, tag string) error {
 	typ := reflect.TypeOf(ptr).Elem()
 	val := reflect.ValueOf(ptr).Elem()
 
@@ -78,18 +164,21 @@ func (b *binder) bindData(ptr interface{}, data map[string][]string) error {
 			continue
 		}
 		structFieldKind := structField.Kind()
-		inputFieldName := typeField.Tag.Get("form")
+		inputFieldName := typeField.Tag.Get(tag)
 
 		if inputFieldName == "" {
-			inputFieldName = typeField.Name
-			// If "form" tag is nil, we inspect if the field is a struct.
-			if structFieldKind == reflect.Struct {
-				err := b.bindData(structField.Addr().Interface(), data)
+			// If the tag is nil, we inspect if the field is a struct.
+			if structFieldKind == reflect.Struct && !isTextUnmarshaler(structField) {
+				err := b.bindData(structField.Addr().Interface(), data, tag)
 				if err != nil {
 					return err
 				}
 				continue
 			}
+			if tag != "form" {
+				continue
+			}
+			inputFieldName = typeField.Name
 		}
 		inputValue, exists := data[inputFieldName]
 		if !exists {
@@ -98,23 +187,52 @@ func (b *binder) bindData(ptr interface{}, data map[string][]string) error {
 
 		numElems := len(inputValue)
 		if structFieldKind == reflect.Slice && numElems > 0 {
-			sliceOf := structField.Type().Elem().Kind()
 			slice := reflect.MakeSlice(structField.Type(), numElems, numElems)
 			for i := 0; i < numElems; i++ {
-				if err := setWithProperType(sliceOf, inputValue[i], slice.Index(i)); err != nil {
-					return err
+				if err := setValue(inputValue[i], slice.Index(i)); err != nil {
+					return fieldError(inputFieldName, err)
 				}
 			}
 			val.Field(i).Set(slice)
-		} else {
-			if err := setWithProperType(typeField.Type.Kind(), inputValue[0], structField); err != nil {
-				return err
+		} else if numElems > 0 {
+			if err := setValue(inputValue[0], structField); err != nil {
+				return fieldError(inputFieldName, err)
 			}
 		}
 	}
 	return nil
 }
 
+func fieldError(name string, err error) error {
+	return fmt.Errorf("field=%s, error=%v", name, err)
+}
+
+func isTextUnmarshaler(v reflect.Value) bool {
+	if !v.CanAddr() {
+		return false
+	}
+	_, ok := v.Addr().Interface().(encoding.TextUnmarshaler)
+	return ok
+}
+
+// setValue converts val to the type of field and sets it. Pointers are
+// allocated and `encoding.TextUnmarshaler` implementations (e.g. `time.Time`)
+// are honored.
+func setValue(val string, field reflect.Value) error {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		return setValue(val, field.Elem())
+	}
+	if field.CanAddr() {
+		if u, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(val))
+		}
+	}
+	return setWithProperType(field.Kind(), val, field)
+}
+
 func setWithProperType(valueKind reflect.Kind, val string, structField reflect.Value) error {
 	switch valueKind {
 	case reflect.Int:
@@ -0,0 +1,186 @@
+package leego_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+type bindRequest struct {
+	ID    int       `param:"id"`
+	Page  *int      `query:"page"`
+	Tags  []string  `query:"tag"`
+	Since time.Time `query:"since"`
+	Name  string    `json:"name"`
+	Meta  struct {
+		Q string `query:"q"`
+	}
+}
+
+func TestBindParamsAndQuery(t *testing.T) {
+	lee := leego.New()
+	var got bindRequest
+	lee.POST("/users/:id", func(c leego.Context) leego.LeeError {
+		return c.Bind(&got)
+	})
+	req := httptest.NewRequest(leego.POST, "/users/7?page=2&tag=a&tag=b&since=2020-01-02T00:00:00Z&q=x", strings.NewReader(`{"name":"joe"}`))
+	req.Header.Set(leego.HeaderContentType, leego.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+
+	assert.Equal(t, 7, got.ID)
+	if assert.NotNil(t, got.Page) {
+		assert.Equal(t, 2, *got.Page)
+	}
+	assert.Equal(t, []string{"a", "b"}, got.Tags)
+	assert.Equal(t, 2020, got.Since.Year())
+	assert.Equal(t, "joe", got.Name)
+	assert.Equal(t, "x", got.Meta.Q)
+
+	// Conversion error
+	req = httptest.NewRequest(leego.POST, "/users/x", strings.NewReader(`{}`))
+	req.Header.Set(leego.HeaderContentType, leego.MIMEApplicationJSON)
+	rec = httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestBindForm(t *testing.T) {
+	type upload struct {
+		Title string                  `form:"title"`
+		Count int                     `form:"count"`
+		File  *multipart.FileHeader   `form:"file"`
+		Files []*multipart.FileHeader `form:"files"`
+	}
+	lee := leego.New()
+	var got upload
+	lee.POST("/", func(c leego.Context) leego.LeeError {
+		got = upload{}
+		return c.Bind(&got)
+	})
+
+	req := httptest.NewRequest(leego.POST, "/", strings.NewReader("title=hi&count=2"))
+	req.Header.Set(leego.HeaderContentType, leego.MIMEApplicationForm)
+	lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(httptest.NewRecorder()))
+	assert.Equal(t, upload{Title: "hi", Count: 2}, got)
+
+	body := new(bytes.Buffer)
+	mw := multipart.NewWriter(body)
+	mw.WriteField("title", "photos")
+	for _, f := range []string{"file", "files", "files"} {
+		w, _ := mw.CreateFormFile(f, f+".txt")
+		w.Write([]byte(f))
+	}
+	mw.Close()
+	req = httptest.NewRequest(leego.POST, "/", body)
+	req.Header.Set(leego.HeaderContentType, mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "photos", got.Title)
+	if assert.NotNil(t, got.File) {
+		assert.Equal(t, "file.txt", got.File.Filename)
+	}
+	assert.Len(t, got.Files, 2)
+}
+
+func TestBindCodec(t *testing.T) {
+	// JSON standing in for msgpack
+	lee := leego.New()
+	lee.RegisterCodec(leego.MIMEApplicationMsgpack, leego.CodecFuncs{
+		MarshalFunc:   json.Marshal,
+		UnmarshalFunc: json.Unmarshal,
+	})
+	lee.POST("/", func(c leego.Context) leego.LeeError {
+		var u struct {
+			Name string `json:"name"`
+		}
+		if err := c.Bind(&u); err != nil {
+			return err
+		}
+		return c.Msgpack(http.StatusOK, u)
+	})
+
+	req := httptest.NewRequest(leego.POST, "/", strings.NewReader(`{"name":"joe"}`))
+	req.Header.Set(leego.HeaderContentType, leego.MIMEApplicationMsgpack+"; charset=binary")
+	rec := httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, leego.MIMEApplicationMsgpack, rec.Header().Get(leego.HeaderContentType))
+	assert.Equal(t, `{"name":"joe"}`, rec.Body.String())
+
+	// Invalid body
+	req = httptest.NewRequest(leego.POST, "/", strings.NewReader(`{`))
+	req.Header.Set(leego.HeaderContentType, leego.MIMEApplicationMsgpack)
+	rec = httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// No codec
+	req = httptest.NewRequest(leego.POST, "/", strings.NewReader(`{}`))
+	req.Header.Set(leego.HeaderContentType, leego.MIMEApplicationProtobuf)
+	rec = httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	c := lee.NewContext(standard.NewRequest(httptest.NewRequest(leego.GET, "/", nil)), standard.NewResponse(httptest.NewRecorder()))
+	assert.Equal(t, leego.ErrCodecNotRegistered, c.Protobuf(http.StatusOK, "joe"))
+}
+
+type signup struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+func (s *signup) Validate() error {
+	if len(s.Password) < 8 {
+		return errors.New("password too short")
+	}
+	return nil
+}
+
+type requiredValidator struct{}
+
+func (requiredValidator) Validate(i interface{}) error {
+	if s, ok := i.(*signup); ok && s.Email == "" {
+		return leego.NewHTTPError(http.StatusUnprocessableEntity, "email required")
+	}
+	return nil
+}
+
+func TestBindAndValidate(t *testing.T) {
+	lee := leego.New()
+	lee.POST("/", func(c leego.Context) leego.LeeError {
+		return c.BindAndValidate(new(signup))
+	})
+	lee.POST("/user", func(c leego.Context) leego.LeeError {
+		return c.BindAndValidate(new(bindRequest))
+	})
+	request := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(leego.POST, path, strings.NewReader(body))
+		req.Header.Set(leego.HeaderContentType, leego.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		return rec
+	}
+
+	// Self-validating type only
+	assert.Equal(t, http.StatusBadRequest, request("/", `{"password":"short"}`).Code)
+	assert.Equal(t, http.StatusOK, request("/", `{"password":"long enough"}`).Code)
+	assert.Equal(t, http.StatusInternalServerError, request("/user", `{}`).Code)
+
+	lee.SetValidator(requiredValidator{})
+	assert.Equal(t, http.StatusUnprocessableEntity, request("/", `{"password":"long enough"}`).Code)
+	assert.Equal(t, http.StatusOK, request("/", `{"email":"joe@example.com","password":"long enough"}`).Code)
+	assert.Equal(t, http.StatusOK, request("/user", `{}`).Code)
+	assert.Equal(t, http.StatusBadRequest, request("/", `{`).Code)
+}
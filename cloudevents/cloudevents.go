@@ -0,0 +1,292 @@
+// Package cloudevents binds and writes CloudEvents 1.0 over HTTP, in structured
+// and binary content mode, e.g.
+//
+//	lee.POST("/events", func(c leego.Context) leego.LeeError {
+//		ev, err := cloudevents.Bind(c)
+//		if err != nil {
+//			return err
+//		}
+//		o := new(Order)
+//		if err = ev.DataAs(o); err != nil {
+//			return err
+//		}
+//		...
+//		return cloudevents.Binary(c, http.StatusOK, reply)
+//	})
+package cloudevents
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// Event is a CloudEvent.
+	Event struct {
+		// ID identifies the event. Required.
+		ID string
+
+		// Source identifies the context in which the event happened. Required.
+		Source string
+
+		// SpecVersion is the version of the specification. Default value
+		// `SpecVersion`.
+		SpecVersion string
+
+		// Type is the type of the event, e.g. "com.example.order.created".
+		// Required.
+		Type string
+
+		// DataContentType is the content type of Data, e.g. "application/json".
+		DataContentType string
+
+		// DataSchema is the URI of the schema of Data.
+		DataSchema string
+
+		// Subject is the subject of the event in the context of Source.
+		Subject string
+
+		// Time is the time of the event, zero if unset.
+		Time time.Time
+
+		// Extensions are the extension attributes by lowercase name.
+		Extensions map[string]string
+
+		// Data is the payload of the event.
+		Data []byte
+	}
+)
+
+const (
+	// SpecVersion is the supported version of the CloudEvents specification.
+	SpecVersion = "1.0"
+
+	// MIMEApplicationCloudEventsJSON is the content type of structured mode.
+	MIMEApplicationCloudEventsJSON = "application/cloudevents+json"
+
+	headerPrefix = "Ce-"
+)
+
+// Bind reads the event of the request, in structured mode if the Content-Type
+// is `MIMEApplicationCloudEventsJSON` and in binary mode otherwise. An invalid
+// event returns 400.
+func Bind(c leego.Context) (*Event, error) {
+	req := c.Request()
+	ctype := req.Header().Get(leego.HeaderContentType)
+	var body []byte
+	if req.Body() != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body()); err != nil {
+			return nil, err
+		}
+	}
+
+	ev := new(Event)
+	if strings.HasPrefix(ctype, MIMEApplicationCloudEventsJSON) {
+		if err := ev.UnmarshalJSON(body); err != nil {
+			return nil, leego.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	} else {
+		h := req.Header()
+		for _, k := range h.Keys() {
+			if len(k) <= len(headerPrefix) || !strings.EqualFold(k[:len(headerPrefix)], headerPrefix) {
+				continue
+			}
+			ev.set(strings.ToLower(k[len(headerPrefix):]), h.Get(k))
+		}
+		ev.DataContentType = ctype
+		ev.Data = body
+	}
+	if err := ev.Validate(); err != nil {
+		return nil, leego.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return ev, nil
+}
+
+// Structured writes ev with status code in structured mode.
+func Structured(c leego.Context, code int, ev *Event) error {
+	b, err := ev.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	res := c.Response()
+	res.Header().Set(leego.HeaderContentType, MIMEApplicationCloudEventsJSON+"; charset=utf-8")
+	res.WriteHeader(code)
+	_, err = res.Write(b)
+	return err
+}
+
+// Binary writes ev with status code in binary mode, its attributes in `Ce-*`
+// headers and its data as body.
+func Binary(c leego.Context, code int, ev *Event) error {
+	h := c.Response().Header()
+	for k, v := range ev.attributes() {
+		if k == "datacontenttype" {
+			h.Set(leego.HeaderContentType, v)
+			continue
+		}
+		h.Set(headerPrefix+k, v)
+	}
+	res := c.Response()
+	res.WriteHeader(code)
+	_, err := res.Write(ev.Data)
+	return err
+}
+
+// Validate checks the required attributes and the spec version.
+func (e *Event) Validate() error {
+	switch {
+	case e.SpecVersion != SpecVersion:
+		return fmt.Errorf("cloudevents: unsupported specversion %q", e.SpecVersion)
+	case e.ID == "":
+		return errors.New("cloudevents: missing id")
+	case e.Source == "":
+		return errors.New("cloudevents: missing source")
+	case e.Type == "":
+		return errors.New("cloudevents: missing type")
+	}
+	return nil
+}
+
+// DataAs unmarshals the JSON data of the event into v. Invalid data returns
+// 400.
+func (e *Event) DataAs(v interface{}) error {
+	if err := json.Unmarshal(e.Data, v); err != nil {
+		return leego.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return nil
+}
+
+// MarshalJSON implements `json.Marshaler` function, in structured mode. JSON
+// data is embedded as is, other data is base64 encoded.
+func (e *Event) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{})
+	for k, v := range e.attributes() {
+		m[k] = v
+	}
+	if e.Data != nil {
+		if e.isJSON() && json.Valid(e.Data) {
+			m["data"] = json.RawMessage(e.Data)
+		} else {
+			m["data_base64"] = base64.StdEncoding.EncodeToString(e.Data)
+		}
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON implements `json.Unmarshaler` function, in structured mode.
+func (e *Event) UnmarshalJSON(b []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	for k, raw := range m {
+		switch k {
+		case "data":
+			e.Data = []byte(raw)
+		case "data_base64":
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return errors.New("cloudevents: invalid data_base64")
+			}
+			data, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return errors.New("cloudevents: invalid data_base64")
+			}
+			e.Data = data
+		default:
+			var v interface{}
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return err
+			}
+			switch v := v.(type) {
+			case string:
+				e.set(k, v)
+			case nil:
+			default:
+				// Extension attributes may be booleans or integers.
+				e.set(k, strings.Trim(string(raw), `"`))
+			}
+		}
+	}
+	if e.Data != nil && !e.isJSON() {
+		// Non-JSON data is carried as a JSON string.
+		var s string
+		if json.Unmarshal(e.Data, &s) == nil {
+			e.Data = []byte(s)
+		}
+	}
+	return nil
+}
+
+// isJSON reports whether the data content type is JSON, which it defaults to.
+func (e *Event) isJSON() bool {
+	t := strings.ToLower(e.DataContentType)
+	if i := strings.IndexByte(t, ';'); i >= 0 {
+		t = t[:i]
+	}
+	t = strings.TrimSpace(t)
+	return t == "" || t == leego.MIMEApplicationJSON || strings.HasSuffix(t, "+json") ||
+		strings.HasPrefix(t, "text/json")
+}
+
+func (e *Event) set(name, value string) {
+	switch name {
+	case "id":
+		e.ID = value
+	case "source":
+		e.Source = value
+	case "specversion":
+		e.SpecVersion = value
+	case "type":
+		e.Type = value
+	case "datacontenttype":
+		e.DataContentType = value
+	case "dataschema":
+		e.DataSchema = value
+	case "subject":
+		e.Subject = value
+	case "time":
+		e.Time, _ = time.Parse(time.RFC3339Nano, value)
+	default:
+		if e.Extensions == nil {
+			e.Extensions = make(map[string]string)
+		}
+		e.Extensions[name] = value
+	}
+}
+
+// attributes returns the set attributes by name.
+func (e *Event) attributes() map[string]string {
+	m := make(map[string]string, 9+len(e.Extensions))
+	for k, v := range e.Extensions {
+		m[k] = v
+	}
+	add := func(k, v string) {
+		if v != "" {
+			m[k] = v
+		}
+	}
+	add("id", e.ID)
+	add("source", e.Source)
+	add("specversion", e.SpecVersion)
+	if e.SpecVersion == "" {
+		m["specversion"] = SpecVersion
+	}
+	add("type", e.Type)
+	add("datacontenttype", e.DataContentType)
+	add("dataschema", e.DataSchema)
+	add("subject", e.Subject)
+	if !e.Time.IsZero() {
+		m["time"] = e.Time.UTC().Format(time.RFC3339Nano)
+	}
+	return m
+}
@@ -0,0 +1,92 @@
+package cloudevents_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/cloudevents"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+type order struct {
+	ID string `json:"id"`
+}
+
+func newContext(req *http.Request, rec *httptest.ResponseRecorder) leego.Context {
+	return leego.New().NewContext(standard.NewRequest(req), standard.NewResponse(rec))
+}
+
+func TestBindStructured(t *testing.T) {
+	body := `{"specversion":"1.0","id":"1","source":"/orders","type":"order.created",` +
+		`"time":"2020-06-25T10:00:00Z","tenant":"acme","data":{"id":"42"}}`
+	req := httptest.NewRequest(leego.POST, "/", strings.NewReader(body))
+	req.Header.Set(leego.HeaderContentType, cloudevents.MIMEApplicationCloudEventsJSON)
+	ev, err := cloudevents.Bind(newContext(req, httptest.NewRecorder()))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "1", ev.ID)
+		assert.Equal(t, "order.created", ev.Type)
+		assert.Equal(t, 2020, ev.Time.Year())
+		assert.Equal(t, "acme", ev.Extensions["tenant"])
+		o := new(order)
+		assert.NoError(t, ev.DataAs(o))
+		assert.Equal(t, "42", o.ID)
+	}
+
+	req = httptest.NewRequest(leego.POST, "/", strings.NewReader(`{"specversion":"1.0","id":"1"}`))
+	req.Header.Set(leego.HeaderContentType, cloudevents.MIMEApplicationCloudEventsJSON)
+	_, err = cloudevents.Bind(newContext(req, httptest.NewRecorder()))
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusBadRequest, err.(*leego.HTTPError).Code)
+	}
+}
+
+func TestBindBinary(t *testing.T) {
+	req := httptest.NewRequest(leego.POST, "/", strings.NewReader(`{"id":"42"}`))
+	req.Header.Set(leego.HeaderContentType, leego.MIMEApplicationJSON)
+	req.Header.Set("Ce-Specversion", "1.0")
+	req.Header.Set("Ce-Id", "1")
+	req.Header.Set("Ce-Source", "/orders")
+	req.Header.Set("Ce-Type", "order.created")
+	req.Header.Set("Ce-Tenant", "acme")
+	ev, err := cloudevents.Bind(newContext(req, httptest.NewRecorder()))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "/orders", ev.Source)
+		assert.Equal(t, leego.MIMEApplicationJSON, ev.DataContentType)
+		assert.Equal(t, "acme", ev.Extensions["tenant"])
+		assert.Equal(t, `{"id":"42"}`, string(ev.Data))
+	}
+}
+
+func TestWrite(t *testing.T) {
+	ev := &cloudevents.Event{
+		ID:              "1",
+		Source:          "/orders",
+		Type:            "order.created",
+		DataContentType: leego.MIMETextPlain,
+		Data:            []byte("hello"),
+	}
+
+	rec := httptest.NewRecorder()
+	assert.NoError(t, cloudevents.Binary(newContext(httptest.NewRequest(leego.GET, "/", nil), rec), http.StatusOK, ev))
+	assert.Equal(t, "1", rec.Header().Get("Ce-Id"))
+	assert.Equal(t, cloudevents.SpecVersion, rec.Header().Get("Ce-Specversion"))
+	assert.Equal(t, leego.MIMETextPlain, rec.Header().Get(leego.HeaderContentType))
+	assert.Equal(t, "hello", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	assert.NoError(t, cloudevents.Structured(newContext(httptest.NewRequest(leego.GET, "/", nil), rec), http.StatusOK, ev))
+	var m map[string]string
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &m))
+	assert.Equal(t, "aGVsbG8=", m["data_base64"])
+
+	// Round trip
+	got := new(cloudevents.Event)
+	assert.NoError(t, got.UnmarshalJSON(rec.Body.Bytes()))
+	assert.Equal(t, "hello", string(got.Data))
+	assert.NoError(t, got.Validate())
+}
@@ -0,0 +1,98 @@
+package leego
+
+import (
+	"errors"
+	"io/ioutil"
+	"mime"
+	"net/http"
+)
+
+type (
+	// Codec encodes and decodes the bodies of a media type, e.g. msgpack or
+	// protobuf, see `Leego#RegisterCodec()`.
+	Codec interface {
+		Marshal(v interface{}) ([]byte, error)
+		Unmarshal(data []byte, v interface{}) error
+	}
+
+	// CodecFuncs adapts a pair of functions, e.g. `msgpack.Marshal` and
+	// `msgpack.Unmarshal`, to a `Codec`.
+	CodecFuncs struct {
+		MarshalFunc   func(v interface{}) ([]byte, error)
+		UnmarshalFunc func(data []byte, v interface{}) error
+	}
+)
+
+// ErrCodecNotRegistered is returned when no codec is registered for a media
+// type, see `Leego#RegisterCodec()`.
+var ErrCodecNotRegistered = errors.New("codec not registered")
+
+// Marshal implements `Codec#Marshal()`.
+func (f CodecFuncs) Marshal(v interface{}) ([]byte, error) {
+	return f.MarshalFunc(v)
+}
+
+// Unmarshal implements `Codec#Unmarshal()`.
+func (f CodecFuncs) Unmarshal(data []byte, v interface{}) error {
+	return f.UnmarshalFunc(data, v)
+}
+
+// RegisterCodec registers the codec of mediaType, e.g. `MIMEApplicationMsgpack`.
+// The default binder decodes the request bodies of the type with it, and
+// `Context#Encode()` encodes responses. For example:
+//
+//	lee.RegisterCodec(leego.MIMEApplicationMsgpack, leego.CodecFuncs{
+//		MarshalFunc:   msgpack.Marshal,
+//		UnmarshalFunc: msgpack.Unmarshal,
+//	})
+func (e *Leego) RegisterCodec(mediaType string, c Codec) {
+	if e.codecs == nil {
+		e.codecs = make(map[string]Codec)
+	}
+	e.codecs[mediaType] = c
+}
+
+// Codec returns the codec registered for the media type of contentType, nil if
+// there is none.
+func (e *Leego) Codec(contentType string) Codec {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+	return e.codecs[t]
+}
+
+func (c *leegoContext) Encode(code int, contentType string, i interface{}) (err error) {
+	codec := c.leego.Codec(contentType)
+	if codec == nil {
+		return ErrCodecNotRegistered
+	}
+	b, err := codec.Marshal(i)
+	if err != nil {
+		return err
+	}
+	c.response.Header().Set(HeaderContentType, contentType)
+	c.response.WriteHeader(code)
+	_, err = c.response.Write(b)
+	return
+}
+
+func (c *leegoContext) Msgpack(code int, i interface{}) error {
+	return c.Encode(code, MIMEApplicationMsgpack, i)
+}
+
+func (c *leegoContext) Protobuf(code int, i interface{}) error {
+	return c.Encode(code, MIMEApplicationProtobuf, i)
+}
+
+// decode decodes the request body with codec.
+func (b *binder) decode(codec Codec, i interface{}, c Context) error {
+	data, err := ioutil.ReadAll(c.Request().Body())
+	if err != nil {
+		return err
+	}
+	if err = codec.Unmarshal(data, i); err != nil {
+		return NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+// Package compat mounts handlers and middleware written for Echo v3 or Gin on
+// leego with minimal edits, for the incremental migration of older services.
+// Without importing either framework, `EchoContext` and `GinContext` offer
+// the methods and fields of `echo.Context` and `*gin.Context` used by most
+// handlers, so porting one is mostly changing its signature:
+//
+//	// Echo v3
+//	lee.GET("/users/:id", compat.Echo(func(c compat.EchoContext) error {
+//		return c.JSON(http.StatusOK, c.Request().URL.Query())
+//	}))
+//
+//	// Gin
+//	lee.GET("/ping", compat.Gin(func(c *compat.GinContext) {
+//		c.JSON(http.StatusOK, gin.H{"message": "pong"})
+//	}))
+//
+// They require the standard engine.
+package compat
+
+import (
+	"net/http"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine"
+	"github.com/go-wyvern/leego/engine/standard"
+)
+
+type (
+	// responseWriter adapts the response of a context to `http.ResponseWriter`,
+	// writing through the response middleware may have wrapped.
+	responseWriter struct {
+		engine.Response
+	}
+)
+
+// httpRequest returns the `http.Request` of the context.
+func httpRequest(c leego.Context) *http.Request {
+	return c.Request().(*standard.Request).Request
+}
+
+func newResponseWriter(c leego.Context) http.ResponseWriter {
+	return responseWriter{c.Response()}
+}
+
+// Header implements `http.ResponseWriter#Header` function.
+func (w responseWriter) Header() http.Header {
+	return w.Response.Header().(*standard.Header).Header
+}
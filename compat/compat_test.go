@@ -0,0 +1,92 @@
+package compat
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func request(lee *leego.Leego, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(leego.HeaderContentType, leego.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+	return rec
+}
+
+func TestEcho(t *testing.T) {
+	lee := leego.New()
+	lee.Use(EchoMiddleware(func(next EchoHandlerFunc) EchoHandlerFunc {
+		return func(c EchoContext) error {
+			c.Response().Header().Set("X-Echo", "1")
+			c.Set("user", "joe")
+			return next(c)
+		}
+	}))
+	lee.GET("/users/:id", Echo(func(c EchoContext) error {
+		return c.String(http.StatusOK, c.Param("id")+" "+c.Request().URL.Query().Get("q")+" "+c.Get("user").(string))
+	}))
+	lee.GET("/error", Echo(func(c EchoContext) error {
+		return leego.NewHTTPError(http.StatusTeapot)
+	}))
+
+	rec := request(lee, leego.GET, "/users/1?q=a", "")
+	assert.Equal(t, "1 a joe", rec.Body.String())
+	assert.Equal(t, "1", rec.Header().Get("X-Echo"))
+	assert.Equal(t, http.StatusTeapot, request(lee, leego.GET, "/error", "").Code)
+}
+
+func TestGin(t *testing.T) {
+	lee := leego.New()
+	var order []string
+	lee.Use(GinMiddleware(func(c *GinContext) {
+		order = append(order, "before")
+		c.Next()
+		order = append(order, "after")
+	}))
+	auth := func(c *GinContext) {
+		if c.GetHeader("Authorization") == "" && c.Query("token") == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, H{"error": "unauthorized"})
+			return
+		}
+		c.Set("user", "joe")
+	}
+	lee.POST("/users/:id", Gin(auth, func(c *GinContext) {
+		order = append(order, "handler")
+		var body struct {
+			Name string `json:"name"`
+		}
+		if err := c.Bind(&body); err != nil {
+			return
+		}
+		user, _ := c.Get("user")
+		c.Header("X-Route", c.FullPath())
+		c.JSON(http.StatusOK, H{"id": c.Param("id"), "name": body.Name, "user": user, "page": c.DefaultQuery("page", "1")})
+	}))
+	lee.GET("/error", Gin(func(c *GinContext) {
+		c.Error(errors.New("failed"))
+	}))
+
+	rec := request(lee, leego.POST, "/users/1?token=t", `{"name":"Joe"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"id":"1","name":"Joe","page":"1","user":"joe"}`, rec.Body.String())
+	assert.Equal(t, "/users/:id", rec.Header().Get("X-Route"))
+	assert.Equal(t, []string{"before", "handler", "after"}, order)
+
+	// Aborted
+	rec = request(lee, leego.POST, "/users/1", `{}`)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, `{"error":"unauthorized"}`, rec.Body.String())
+
+	// Bind error
+	rec = request(lee, leego.POST, "/users/1?token=t", `{`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	assert.Equal(t, http.StatusInternalServerError, request(lee, leego.GET, "/error", "").Code)
+}
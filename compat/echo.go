@@ -0,0 +1,62 @@
+package compat
+
+import (
+	"net/http"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// EchoContext is the context of Echo v3 handlers. leego has the methods of
+	// `echo.Context` with the same signatures, except for the request and the
+	// response which are the `net/http` ones.
+	EchoContext struct {
+		leego.Context
+	}
+
+	// EchoHandlerFunc is an Echo v3 handler.
+	EchoHandlerFunc func(EchoContext) error
+
+	// EchoMiddlewareFunc is an Echo v3 middleware.
+	EchoMiddlewareFunc func(EchoHandlerFunc) EchoHandlerFunc
+)
+
+// Echo converts an Echo v3 handler into a leego handler.
+func Echo(h EchoHandlerFunc) leego.HandlerFunc {
+	return func(c leego.Context) leego.LeeError {
+		if err := h(EchoContext{c}); err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+// EchoMiddleware converts an Echo v3 middleware into a leego middleware.
+func EchoMiddleware(m EchoMiddlewareFunc) leego.MiddlewareFunc {
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		h := m(func(c EchoContext) error {
+			if err := next(c.Context); err != nil {
+				return err
+			}
+			return nil
+		})
+		return Echo(h)
+	}
+}
+
+// Request returns the `http.Request` of the context, like
+// `echo.Context#Request()`.
+func (c EchoContext) Request() *http.Request {
+	return httpRequest(c.Context)
+}
+
+// Response returns the response as an `http.ResponseWriter`, like
+// `echo.Context#Response()`.
+func (c EchoContext) Response() http.ResponseWriter {
+	return newResponseWriter(c.Context)
+}
+
+// QueryString returns the raw query, like `echo.Context#QueryString()`.
+func (c EchoContext) QueryString() string {
+	return c.Request().URL.RawQuery
+}
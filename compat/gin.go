@@ -0,0 +1,284 @@
+package compat
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// GinContext is the context of Gin handlers, with the fields and the
+	// methods of `*gin.Context` most handlers use. Methods with the name of a
+	// leego method but another signature, e.g. `JSON()`, behave like Gin's.
+	GinContext struct {
+		leego.Context
+
+		// Request is the request, like `gin.Context#Request`.
+		Request *http.Request
+
+		// Writer writes the response, like `gin.Context#Writer`.
+		Writer http.ResponseWriter
+
+		handlers []GinHandlerFunc
+		index    int
+		aborted  bool
+		err      error
+	}
+
+	// GinHandlerFunc is a Gin handler or middleware.
+	GinHandlerFunc func(*GinContext)
+
+	// H is a shortcut for JSON objects, like `gin.H`.
+	H map[string]interface{}
+)
+
+// Gin converts a chain of Gin middleware followed by a handler into a leego
+// handler. The first error passed to `GinContext#Error()` is returned to the
+// HTTP error handler.
+func Gin(handlers ...GinHandlerFunc) leego.HandlerFunc {
+	return func(c leego.Context) leego.LeeError {
+		return runGin(c, handlers)
+	}
+}
+
+// GinMiddleware converts Gin middleware into a leego middleware, the next
+// leego handler running on `GinContext#Next()` like the next Gin handler.
+func GinMiddleware(handlers ...GinHandlerFunc) leego.MiddlewareFunc {
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		chain := make([]GinHandlerFunc, len(handlers), len(handlers)+1)
+		copy(chain, handlers)
+		chain = append(chain, func(c *GinContext) {
+			if err := next(c.Context); err != nil {
+				c.Error(err)
+			}
+		})
+		return Gin(chain...)
+	}
+}
+
+func runGin(c leego.Context, handlers []GinHandlerFunc) leego.LeeError {
+	gc := &GinContext{
+		Context:  c,
+		Request:  httpRequest(c),
+		Writer:   newResponseWriter(c),
+		handlers: handlers,
+		index:    -1,
+	}
+	gc.Next()
+	if gc.err != nil {
+		return gc.err
+	}
+	return nil
+}
+
+// Next runs the next handlers of the chain, like `gin.Context#Next()`.
+func (c *GinContext) Next() {
+	c.index++
+	for c.index < len(c.handlers) && !c.aborted {
+		c.handlers[c.index](c)
+		c.index++
+	}
+}
+
+// Abort prevents the next handlers from running, like `gin.Context#Abort()`.
+func (c *GinContext) Abort() {
+	c.aborted = true
+}
+
+// IsAborted returns true if the chain was aborted.
+func (c *GinContext) IsAborted() bool {
+	return c.aborted
+}
+
+// AbortWithStatus aborts the chain and writes the status.
+func (c *GinContext) AbortWithStatus(code int) {
+	c.Status(code)
+	c.Abort()
+}
+
+// AbortWithStatusJSON aborts the chain and sends obj as JSON.
+func (c *GinContext) AbortWithStatusJSON(code int, obj interface{}) {
+	c.Abort()
+	c.JSON(code, obj)
+}
+
+// AbortWithError aborts the chain, writes the status and records err, see
+// `GinContext#Error()`.
+func (c *GinContext) AbortWithError(code int, err error) error {
+	c.AbortWithStatus(code)
+	return c.Error(err)
+}
+
+// Error records err, returned to the HTTP error handler if it's the first
+// one, like `gin.Context#Error()`.
+func (c *GinContext) Error(err error) error {
+	if c.err == nil {
+		c.err = err
+	}
+	return err
+}
+
+// Set stores a value for the request, like `gin.Context#Set()`.
+func (c *GinContext) Set(key string, value interface{}) {
+	c.Context.Set(key, value)
+}
+
+// Get returns a value stored for the request and whether it exists, like
+// `gin.Context#Get()`.
+func (c *GinContext) Get(key string) (interface{}, bool) {
+	v := c.Context.Get(key)
+	return v, v != nil
+}
+
+// Query returns the query param key, like `gin.Context#Query()`.
+func (c *GinContext) Query(key string) string {
+	return c.QueryParam(key)
+}
+
+// DefaultQuery returns the query param key, def if it's not sent.
+func (c *GinContext) DefaultQuery(key, def string) string {
+	if v, ok := c.GetQuery(key); ok {
+		return v
+	}
+	return def
+}
+
+// GetQuery returns the query param key and whether it's sent.
+func (c *GinContext) GetQuery(key string) (string, bool) {
+	if vs, ok := c.Request.URL.Query()[key]; ok && len(vs) > 0 {
+		return vs[0], true
+	}
+	return "", false
+}
+
+// PostForm returns the form field key, like `gin.Context#PostForm()`.
+func (c *GinContext) PostForm(key string) string {
+	return c.FormValue(key)
+}
+
+// DefaultPostForm returns the form field key, def if it's empty.
+func (c *GinContext) DefaultPostForm(key, def string) string {
+	if v := c.FormValue(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// GetHeader returns the request header key.
+func (c *GinContext) GetHeader(key string) string {
+	return c.Request.Header.Get(key)
+}
+
+// Header sets the response header key, or deletes it if value is empty.
+func (c *GinContext) Header(key, value string) {
+	if value == "" {
+		c.Writer.Header().Del(key)
+		return
+	}
+	c.Writer.Header().Set(key, value)
+}
+
+// Cookie returns the value of the cookie name, like `gin.Context#Cookie()`.
+func (c *GinContext) Cookie(name string) (string, error) {
+	cookie, err := c.Request.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return url.QueryUnescape(cookie.Value)
+}
+
+// SetCookie sets a cookie, like `gin.Context#SetCookie()`.
+func (c *GinContext) SetCookie(name, value string, maxAge int, path, domain string, secure, httpOnly bool) {
+	if path == "" {
+		path = "/"
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    url.QueryEscape(value),
+		MaxAge:   maxAge,
+		Path:     path,
+		Domain:   domain,
+		Secure:   secure,
+		HttpOnly: httpOnly,
+	})
+}
+
+// ClientIP returns the client IP, see `leego.Context#RealIP()`.
+func (c *GinContext) ClientIP() string {
+	return c.RealIP()
+}
+
+// FullPath returns the route path, e.g. "/users/:id".
+func (c *GinContext) FullPath() string {
+	return c.Path()
+}
+
+// ShouldBind binds the request body into obj.
+func (c *GinContext) ShouldBind(obj interface{}) error {
+	return c.Context.Bind(obj)
+}
+
+// ShouldBindJSON binds the request body into obj.
+func (c *GinContext) ShouldBindJSON(obj interface{}) error {
+	return c.Context.Bind(obj)
+}
+
+// Bind binds the request body into obj, aborting with 400 on errors like
+// `gin.Context#Bind()`.
+func (c *GinContext) Bind(obj interface{}) error {
+	if err := c.Context.Bind(obj); err != nil {
+		return c.AbortWithError(http.StatusBadRequest, err)
+	}
+	return nil
+}
+
+// BindJSON is `GinContext#Bind()`.
+func (c *GinContext) BindJSON(obj interface{}) error {
+	return c.Bind(obj)
+}
+
+// Status writes the status of the response.
+func (c *GinContext) Status(code int) {
+	c.Writer.WriteHeader(code)
+}
+
+// JSON sends obj as JSON with status code.
+func (c *GinContext) JSON(code int, obj interface{}) {
+	c.render(c.Context.JSON(code, obj))
+}
+
+// XML sends obj as XML with status code.
+func (c *GinContext) XML(code int, obj interface{}) {
+	c.render(c.Context.XML(code, obj))
+}
+
+// String sends format, formatted with values if any, as text with status
+// code.
+func (c *GinContext) String(code int, format string, values ...interface{}) {
+	if len(values) > 0 {
+		format = fmt.Sprintf(format, values...)
+	}
+	c.render(c.Context.String(code, format))
+}
+
+// Data sends data with the content type and status code.
+func (c *GinContext) Data(code int, contentType string, data []byte) {
+	c.Writer.Header().Set(leego.HeaderContentType, contentType)
+	c.Status(code)
+	_, err := c.Writer.Write(data)
+	c.render(err)
+}
+
+// Redirect redirects to location with status code.
+func (c *GinContext) Redirect(code int, location string) {
+	c.render(c.Context.Redirect(code, location))
+}
+
+// render records the error of writing the response.
+func (c *GinContext) render(err error) {
+	if err != nil {
+		c.Error(err)
+	}
+}
@@ -0,0 +1,187 @@
+// Package consumer feeds the messages of a broker consumer, e.g. Kafka or NATS,
+// into the handlers and middleware of a `Leego` instance, so async consumers
+// share the logging, metrics and validation of the HTTP endpoints.
+//
+// A message is served as a POST request to "/" + topic, with the payload as
+// body and the message headers as request headers, e.g.
+//
+//	lee.POST("/orders.created", func(c leego.Context) leego.LeeError {
+//		o := new(Order)
+//		if err := c.Bind(o); err != nil {
+//			return err
+//		}
+//		id := c.Request().Header().Get(consumer.HeaderMessageKey)
+//		...
+//	})
+//
+//	d := consumer.New(lee)
+//
+//	// With github.com/nats-io/nats.go
+//	nc.Subscribe("orders.*", func(m *nats.Msg) {
+//		d.Handle(context.Background(), &consumer.Message{
+//			Topic:   m.Subject,
+//			Headers: m.Header,
+//			Payload: m.Data,
+//		})
+//	})
+//
+// Kafka consumers map the topic, key, headers and value the same way and
+// commit the offset only once `Dispatcher#Handle()` returns no error.
+package consumer
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"golang.org/x/net/context"
+)
+
+type (
+	// Message is a message received from a broker.
+	Message struct {
+		// Topic is the topic or subject of the message.
+		Topic string
+
+		// Key is the key of the message, passed in the `HeaderMessageKey`
+		// header.
+		Key []byte
+
+		// Headers are the headers of the message.
+		Headers map[string][]string
+
+		// Payload is the body of the message.
+		Payload []byte
+	}
+
+	// Config defines the config for `Dispatcher`.
+	Config struct {
+		// Path returns the request path of a message, to route it.
+		// Optional. Default value returns "/" + topic.
+		Path func(*Message) string
+
+		// ContentType is the content type of the payloads without a
+		// Content-Type header, used by `Context#Bind()`.
+		// Optional. Default value "application/json".
+		ContentType string
+	}
+
+	// Dispatcher serves messages with the handlers of a `Leego` instance.
+	Dispatcher struct {
+		lee    *leego.Leego
+		config Config
+	}
+
+	// Error is returned by `Dispatcher#Handle()` when the handler responds with
+	// an error status, so the message can be retried or dead-lettered.
+	Error struct {
+		Topic string
+		Code  int
+		Body  string
+	}
+
+	responseWriter struct {
+		header http.Header
+		code   int
+		body   bytes.Buffer
+	}
+)
+
+const (
+	// HeaderMessageKey is the request header carrying the message key.
+	HeaderMessageKey = "X-Message-Key"
+
+	// HeaderMessageTopic is the request header carrying the message topic.
+	HeaderMessageTopic = "X-Message-Topic"
+)
+
+var (
+	// DefaultConfig is the default `Dispatcher` config.
+	DefaultConfig = Config{
+		Path: func(m *Message) string {
+			return "/" + m.Topic
+		},
+		ContentType: leego.MIMEApplicationJSON,
+	}
+)
+
+// New returns a dispatcher serving messages with lee.
+func New(lee *leego.Leego) *Dispatcher {
+	return NewWithConfig(lee, DefaultConfig)
+}
+
+// NewWithConfig returns a dispatcher from config.
+// See `New()`.
+func NewWithConfig(lee *leego.Leego, config Config) *Dispatcher {
+	// Defaults
+	if config.Path == nil {
+		config.Path = DefaultConfig.Path
+	}
+	if config.ContentType == "" {
+		config.ContentType = DefaultConfig.ContentType
+	}
+	return &Dispatcher{lee: lee, config: config}
+}
+
+// Error implements `error` function.
+func (e *Error) Error() string {
+	return fmt.Sprintf("consumer: topic %s: code=%d, message=%s", e.Topic, e.Code, e.Body)
+}
+
+// Handle serves m with the handler registered for its path and returns an
+// `*Error` if it responds with a status code >= 400.
+func (d *Dispatcher) Handle(ctx context.Context, m *Message) error {
+	r := &http.Request{
+		Method:        leego.POST,
+		URL:           &url.URL{Path: d.config.Path(m)},
+		RequestURI:    d.config.Path(m),
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header, len(m.Headers)+3),
+		Body:          http.NoBody,
+		ContentLength: int64(len(m.Payload)),
+		Host:          "consumer",
+		RemoteAddr:    "consumer",
+	}
+	if len(m.Payload) > 0 {
+		r.Body = ioutil.NopCloser(bytes.NewReader(m.Payload))
+	}
+	for k, v := range m.Headers {
+		r.Header[http.CanonicalHeaderKey(k)] = v
+	}
+	if r.Header.Get(leego.HeaderContentType) == "" {
+		r.Header.Set(leego.HeaderContentType, d.config.ContentType)
+	}
+	r.Header.Set(HeaderMessageTopic, m.Topic)
+	if m.Key != nil {
+		r.Header.Set(HeaderMessageKey, string(m.Key))
+	}
+	r = r.WithContext(ctx)
+
+	w := &responseWriter{header: make(http.Header)}
+	d.lee.ServeHTTP(standard.NewRequest(r), standard.NewResponse(w))
+	if w.code >= http.StatusBadRequest {
+		return &Error{Topic: m.Topic, Code: w.code, Body: w.body.String()}
+	}
+	return nil
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
@@ -0,0 +1,48 @@
+package consumer_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/consumer"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+type order struct {
+	ID string `json:"id"`
+}
+
+func TestDispatcherHandle(t *testing.T) {
+	lee := leego.New()
+	var got order
+	var key, tenant string
+	lee.POST("/orders.created", func(c leego.Context) leego.LeeError {
+		if err := c.Bind(&got); err != nil {
+			return err
+		}
+		key = c.Request().Header().Get(consumer.HeaderMessageKey)
+		tenant = c.Request().Header().Get("X-Tenant")
+		return c.NoContent(http.StatusNoContent)
+	})
+	d := consumer.New(lee)
+
+	err := d.Handle(context.Background(), &consumer.Message{
+		Topic:   "orders.created",
+		Key:     []byte("42"),
+		Headers: map[string][]string{"x-tenant": {"acme"}},
+		Payload: []byte(`{"id":"42"}`),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "42", got.ID)
+	assert.Equal(t, "42", key)
+	assert.Equal(t, "acme", tenant)
+
+	err = d.Handle(context.Background(), &consumer.Message{Topic: "orders.deleted"})
+	if assert.Error(t, err) {
+		cerr, ok := err.(*consumer.Error)
+		assert.True(t, ok)
+		assert.Equal(t, http.StatusNotFound, cerr.Code)
+	}
+}
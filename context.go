@@ -1,17 +1,22 @@
 package leego
 
 import (
+	"bytes"
+	"crypto/tls"
 	"encoding/json"
 	"encoding/xml"
+	"fmt"
 	"io"
 	"mime"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/go-wyvern/leego/engine"
+	"github.com/go-wyvern/leego/websocket"
 	"github.com/go-wyvern/logger"
 
 	"golang.org/x/net/context"
@@ -21,7 +26,10 @@ type (
 	// Context represents the context of the current HTTP request. It holds request and
 	// response objects, path, path parameters, data and registered handler.
 	Context interface {
-		// Context returns `net/context.Context`.
+		// Context returns `net/context.Context`. It's the context of the
+		// request if the engine has one, e.g. standard, which is cancelled
+		// once the client goes away. Its values include the request store,
+		// see `Set()`.
 		Context() context.Context
 
 		// SetContext sets `net/context.Context`.
@@ -54,6 +62,21 @@ type (
 		// Request returns `engine.Response` interface.
 		Response() engine.Response
 
+		// SetResponse sets `engine.Response`, e.g. to wrap it in a middleware.
+		SetResponse(engine.Response)
+
+		// Forwarded returns the client information reported by the trusted
+		// proxies, see `Leego#SetTrustedProxyDepth()`.
+		Forwarded() Forwarded
+
+		// RealIP returns the client IP, the direct peer unless it's a trusted
+		// proxy, see `Leego#SetTrustedProxies()`.
+		RealIP() string
+
+		// RequestID returns the ID of the request, set on the response by the
+		// request ID middleware or else sent by the client in `X-Request-ID`.
+		RequestID() string
+
 		// Path returns the registered path for the handler.
 		Path() string
 
@@ -114,19 +137,52 @@ type (
 		// It is an alias for `engine.Request#Cookies()`.
 		Cookies() []engine.Cookie
 
-		// Get retrieves data from the context.
+		// Conn returns the connection the request arrived on, carrying the metadata
+		// attached by connection hooks. It is an alias for `engine.Request#Conn()`.
+		Conn() engine.Conn
+
+		// ConnInfo returns the keep-alive and TLS statistics of the connection
+		// the request arrived on.
+		ConnInfo() ConnInfo
+
+		// Get retrieves data from the request store, or from the context set
+		// with `SetContext()` if the key isn't in the store.
 		Get(interface{}) interface{}
 
-		// Set saves data in the context.
+		// Set saves data in the request store. Keys should be of unexported
+		// types, e.g. `type userKey struct{}`, to not collide with other
+		// packages. The values are visible to `Value()` and in `Context()`,
+		// so they reach the code it's passed to.
 		Set(interface{}, interface{})
 
+		// MustGet retrieves data like `Get()`, panicking if there is none.
+		MustGet(interface{}) interface{}
+
+		// GetString retrieves a string like `Get()`, "" if there is none or
+		// it isn't a string.
+		GetString(interface{}) string
+
+		// GetInt retrieves an int like `Get()`, 0 if there is none or it
+		// isn't an int.
+		GetInt(interface{}) int
+
 		// Bind binds the request body into provided type `i`. The default binder
 		// does it based on Content-Type header.
 		Bind(interface{}) error
 
+		// Validate validates `i` with the validator registered using
+		// `Leego#SetValidator()`, then with its own `Validate() error` method
+		// if it has one. Validation errors are returned as 400 unless they are
+		// an `HTTPError`.
+		Validate(interface{}) error
+
+		// BindAndValidate binds the request into `i` and validates it, see
+		// `Context#Bind()` and `Context#Validate()`.
+		BindAndValidate(interface{}) error
+
 		// Render renders a template with data and sends a text/html response with status
 		// code. Templates can be registered using `leego.SetRenderer()`.
-		//Render(int, string, interface{}) error
+		Render(int, string, interface{}) error
 
 		// HTML sends an HTTP response with status code.
 		HTML(int, string) error
@@ -140,6 +196,11 @@ type (
 		// JSONBlob sends a JSON blob response with status code.
 		JSONBlob(int, []byte) error
 
+		// JSONWithETag sends a JSON response with status code and the ETag of
+		// its body, see `ETag()`. A GET or HEAD request with a matching
+		// If-None-Match header gets 304 Not Modified without body instead.
+		JSONWithETag(int, interface{}) error
+
 		// JSONP sends a JSONP response with status code. It uses `callback` to construct
 		// the JSONP payload.
 		JSONP(int, string, interface{}) error
@@ -150,6 +211,33 @@ type (
 		// XMLBlob sends a XML blob response with status code.
 		XMLBlob(int, []byte) error
 
+		// Encode sends a response with status code, encoded with the codec
+		// registered for the content type, see `Leego#RegisterCodec()`. It
+		// returns `ErrCodecNotRegistered` if there is none.
+		Encode(code int, contentType string, i interface{}) error
+
+		// Msgpack sends a msgpack response with status code, see
+		// `Context#Encode()`.
+		Msgpack(int, interface{}) error
+
+		// Protobuf sends a protobuf response with status code, see
+		// `Context#Encode()`.
+		Protobuf(int, interface{}) error
+
+		// Stream sends a streaming response with status code and content type. The
+		// reader is copied in chunks, flushing each chunk to the client as soon as
+		// it is read.
+		Stream(int, string, io.Reader) error
+
+		// WebSocket upgrades the connection to the WebSocket protocol and calls
+		// the handler with it. The connection is closed when the handler returns.
+		WebSocket(func(*websocket.Conn) error) error
+
+		// Push initiates an HTTP/2 server push of target, e.g. a stylesheet the
+		// response refers to. It returns `engine.ErrPushNotSupported` if the
+		// client doesn't support push.
+		Push(target string, opts ...*engine.PushOptions) error
+
 		// File sends a response with the content of the file.
 		File(string) error
 
@@ -163,6 +251,30 @@ type (
 		// Redirect redirects the request with status code.
 		Redirect(int, string) error
 
+		// Accepts returns the offered media type preferred by the Accept header,
+		// e.g. "application/json", according to the quality values. It returns
+		// the first offer without Accept header and "" if none is acceptable.
+		Accepts(offers ...string) string
+
+		// AcceptsLanguage returns the offered language preferred by the
+		// Accept-Language header, see `Accepts()`. An offer "en" matches "en-US".
+		// Without offers, it returns the preferred language of the client.
+		AcceptsLanguage(offers ...string) string
+
+		// Negotiate sends i with status code as the offered media type preferred
+		// by the Accept header, among JSON, XML, HTML and plain text; JSON, XML
+		// and plain text by default. HTML and plain text are `fmt.Sprint(i)`. It
+		// returns `ErrNotAcceptable` (406) if none is acceptable.
+		Negotiate(code int, i interface{}, offers ...string) error
+
+		// AcceptAsync runs fn in the background and responds with 202 Accepted,
+		// the operation as JSON and its status endpoint in the Location header.
+		// It returns the URL of the status endpoint. See `Leego#Operations()`.
+		AcceptAsync(fn OperationFunc) (string, error)
+
+		// Reverse generates a URL from a named route, see `Leego#Reverse()`.
+		Reverse(name string, params ...interface{}) string
+
 		// Error invokes the registered HTTP error handler. Generally used by middleware.
 		Error(err error)
 
@@ -172,8 +284,13 @@ type (
 		// SetHandler sets the matched handler by router.
 		SetHandler(HandlerFunc)
 
+		// SetParamsMap overrides the path parameter map. Passing nil makes
+		// `GetParamsMap()` build it again from the path parameters.
 		SetParamsMap(m map[string]string)
 
+		// GetParamsMap returns path parameters as a name to value map. The map is
+		// built lazily on first access and recycled when the request completes, so
+		// it must not be retained after the handler returns.
 		GetParamsMap() map[string]string
 
 		// Logger returns the `Logger` instance.
@@ -185,7 +302,8 @@ type (
 		SetLogger(*logger.Logger)
 
 		// ServeContent sends static content from `io.Reader` and handles caching
-		// via `If-Modified-Since` request header. It automatically sets `Content-Type`
+		// via `If-Modified-Since` request header, or `If-None-Match` if the
+		// `ETag` response header is set. It automatically sets `Content-Type`
 		// and `Last-Modified` response headers.
 		ServeContent(io.ReadSeeker, string, time.Time) error
 
@@ -198,43 +316,123 @@ type (
 
 		GetData(string) interface{}
 
+		// Language returns the language of the request, see `SetLang()`.
 		Language() string
 
+		// SetLang sets the language of the request, the fallback language of
+		// the catalog if empty, see `Leego#SetCatalog()`, or "zh-CN" without
+		// catalog. It's set to the preferred language of the client, see
+		// `AcceptsLanguage()` and `middleware.Locale()`.
 		SetLang(string)
+
+		// T returns the message key of the catalog in the language of the
+		// request, formatted with args if any, see `Catalog#Translate()`.
+		T(key string, args ...interface{}) string
+	}
+
+	// ConnInfo describes the connection a request arrived on, see
+	// `Context#ConnInfo()`.
+	ConnInfo struct {
+		// Reused is true if the request isn't the first one on the connection.
+		Reused bool `json:"reused"`
+
+		// Requests is the number of requests received on the connection so
+		// far, this one included, 0 if unknown.
+		Requests int64 `json:"requests"`
+
+		// TLSVersion is the TLS version, e.g. "TLS 1.3", empty for plain
+		// connections.
+		TLSVersion string `json:"tls_version,omitempty"`
+
+		// TLSCipherSuite is the TLS cipher suite, e.g.
+		// "TLS_AES_128_GCM_SHA256", empty for plain connections.
+		TLSCipherSuite string `json:"tls_cipher_suite,omitempty"`
 	}
 
 	leegoContext struct {
-		context   context.Context
-		request   engine.Request
-		response  engine.Response
-		logger    *logger.Logger
-		path      string
-		pnames    []string
-		pvalues   []string
-		paramsMap map[string]string
-		handler   HandlerFunc
-		leego     *Leego
-		lang      string
-		data      map[string]interface{}
+		context     context.Context
+		request     engine.Request
+		response    engine.Response
+		logger      *logger.Logger
+		path        string
+		pnames      []string
+		pvalues     []string
+		paramsMap   map[string]string
+		pooledMap   bool
+		handler     HandlerFunc
+		leego       *Leego
+		lang        string
+		data        map[string]interface{}
+		store       map[interface{}]interface{}
+		storeShared bool
+		forwarded   Forwarded
+		forwardedOK bool
+	}
+
+	// storeContext is the context returned by `Context#Context()`, whose
+	// values include the request store.
+	storeContext struct {
+		context.Context
+		store map[interface{}]interface{}
 	}
 )
 
 var _ Context = new(leegoContext)
 
+// maxPooledBufferSize is the capacity above which buffers are dropped instead of
+// returned to the pool, so a single large response doesn't pin memory.
+const maxPooledBufferSize = 64 << 10 // 64 KB
+
+var paramsMapPool = sync.Pool{
+	New: func() interface{} {
+		return make(map[string]string)
+	},
+}
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+func acquireBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func releaseBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// encodeJSON writes the JSON encoding of i to buf without the trailing newline
+// added by `json.Encoder`, matching the output of `json.Marshal`.
+func encodeJSON(buf *bytes.Buffer, i interface{}) error {
+	if err := json.NewEncoder(buf).Encode(i); err != nil {
+		return err
+	}
+	buf.Truncate(buf.Len() - 1)
+	return nil
+}
+
 func (c *leegoContext) Language() string {
 	return c.lang
 }
 
 func (c *leegoContext) SetLang(lang string) {
-	if lang != "" && len(lang) >= 5 {
-		lang = lang[:5]
-	} else {
+	if lang == "" {
 		lang = "zh-CN"
+		if c.leego.catalog != nil {
+			lang = c.leego.catalog.fallback
+		}
 	}
 	c.lang = lang
 }
 
 func (c *leegoContext) SetParamsMap(m map[string]string) {
+	c.releaseParamsMap()
 	c.paramsMap = m
 }
 
@@ -250,9 +448,30 @@ func (c *leegoContext) SetLogger(l *logger.Logger) {
 }
 
 func (c *leegoContext) GetParamsMap() map[string]string {
+	if c.paramsMap == nil {
+		m := paramsMapPool.Get().(map[string]string)
+		for i, name := range c.pnames {
+			if i < len(c.pvalues) {
+				m[name] = c.pvalues[i]
+			}
+		}
+		c.paramsMap = m
+		c.pooledMap = true
+	}
 	return c.paramsMap
 }
 
+func (c *leegoContext) releaseParamsMap() {
+	if c.pooledMap {
+		for k := range c.paramsMap {
+			delete(c.paramsMap, k)
+		}
+		paramsMapPool.Put(c.paramsMap)
+		c.pooledMap = false
+	}
+	c.paramsMap = nil
+}
+
 func (c *leegoContext) SetData(key string, data interface{}) {
 	c.data[key] = data
 }
@@ -262,7 +481,19 @@ func (c *leegoContext) GetData(key string) interface{} {
 }
 
 func (c *leegoContext) Context() context.Context {
-	return c.context
+	if c.store == nil {
+		c.store = make(map[interface{}]interface{})
+	}
+	// The context may outlive the request, so the store isn't reused.
+	c.storeShared = true
+	return storeContext{Context: c.context, store: c.store}
+}
+
+func (ctx storeContext) Value(key interface{}) interface{} {
+	if v, ok := ctx.store[key]; ok {
+		return v
+	}
+	return ctx.Context.Value(key)
 }
 
 func (c *leegoContext) SetContext(ctx context.Context) {
@@ -282,7 +513,7 @@ func (c *leegoContext) Err() error {
 }
 
 func (c *leegoContext) Value(key interface{}) interface{} {
-	return c.context.Value(key)
+	return c.Get(key)
 }
 
 func (c *leegoContext) Request() engine.Request {
@@ -293,6 +524,29 @@ func (c *leegoContext) Response() engine.Response {
 	return c.response
 }
 
+func (c *leegoContext) SetResponse(res engine.Response) {
+	c.response = res
+}
+
+func (c *leegoContext) Forwarded() Forwarded {
+	if !c.forwardedOK {
+		c.forwarded = parseForwarded(c.request, c.leego.trustedProxyDepth, c.leego.trustedProxies)
+		c.forwardedOK = true
+	}
+	return c.forwarded
+}
+
+func (c *leegoContext) RealIP() string {
+	return c.Forwarded().For
+}
+
+func (c *leegoContext) RequestID() string {
+	if id := c.response.Header().Get(HeaderXRequestID); id != "" {
+		return id
+	}
+	return c.request.Header().Get(HeaderXRequestID)
+}
+
 func (c *leegoContext) Path() string {
 	return c.path
 }
@@ -372,31 +626,97 @@ func (c *leegoContext) Cookies() []engine.Cookie {
 	return c.request.Cookies()
 }
 
+func (c *leegoContext) Conn() engine.Conn {
+	return c.request.Conn()
+}
+
+func (c *leegoContext) ConnInfo() ConnInfo {
+	n := c.request.ConnRequests()
+	info := ConnInfo{Reused: n > 1, Requests: n}
+	if cs := c.request.TLSConnectionState(); cs != nil {
+		info.TLSVersion = tls.VersionName(cs.Version)
+		info.TLSCipherSuite = tls.CipherSuiteName(cs.CipherSuite)
+	}
+	return info
+}
+
 func (c *leegoContext) Set(key interface{}, val interface{}) {
-	c.context = context.WithValue(c.context, key, val)
+	if c.store == nil {
+		c.store = make(map[interface{}]interface{})
+	}
+	c.store[key] = val
 }
 
 func (c *leegoContext) Get(key interface{}) interface{} {
+	if v, ok := c.store[key]; ok {
+		return v
+	}
 	return c.context.Value(key)
 }
 
+func (c *leegoContext) MustGet(key interface{}) interface{} {
+	v := c.Get(key)
+	if v == nil {
+		panic(fmt.Sprintf("leego: no value for key %v", key))
+	}
+	return v
+}
+
+func (c *leegoContext) GetString(key interface{}) string {
+	s, _ := c.Get(key).(string)
+	return s
+}
+
+func (c *leegoContext) GetInt(key interface{}) int {
+	i, _ := c.Get(key).(int)
+	return i
+}
+
 func (c *leegoContext) Bind(i interface{}) error {
 	return c.leego.binder.Bind(i, c)
 }
 
-//func (c *leegoContext) Render(code int, name string, data interface{}) (err error) {
-//	if c.leego.renderer == nil {
-//		return ErrRendererNotRegistered
-//	}
-//	buf := new(bytes.Buffer)
-//	if err = c.leego.renderer.Render(buf, name, data, c); err != nil {
-//		return
-//	}
-//	c.response.Header().Set(HeaderContentType, MIMETextHTMLCharsetUTF8)
-//	c.response.WriteHeader(code)
-//	_, err = c.response.Write(buf.Bytes())
-//	return
-//}
+func (c *leegoContext) Validate(i interface{}) (err error) {
+	self, ok := i.(interface{ Validate() error })
+	if c.leego.validator == nil && !ok {
+		return ErrValidatorNotRegistered
+	}
+	if c.leego.validator != nil {
+		err = c.leego.validator.Validate(i)
+	}
+	if err == nil && ok {
+		err = self.Validate()
+	}
+	if err == nil {
+		return nil
+	}
+	if he, ok := err.(*HTTPError); ok {
+		return he
+	}
+	return NewHTTPError(http.StatusBadRequest, err.Error())
+}
+
+func (c *leegoContext) BindAndValidate(i interface{}) error {
+	if err := c.Bind(i); err != nil {
+		return err
+	}
+	return c.Validate(i)
+}
+
+func (c *leegoContext) Render(code int, name string, data interface{}) (err error) {
+	if c.leego.renderer == nil {
+		return ErrRendererNotRegistered
+	}
+	buf := acquireBuffer()
+	defer releaseBuffer(buf)
+	if err = c.leego.renderer.Render(buf, name, data, c); err != nil {
+		return
+	}
+	c.response.Header().Set(HeaderContentType, MIMETextHTMLCharsetUTF8)
+	c.response.WriteHeader(code)
+	_, err = c.response.Write(buf.Bytes())
+	return
+}
 
 func (c *leegoContext) HTML(code int, html string) (err error) {
 	c.response.Header().Set(HeaderContentType, MIMETextHTMLCharsetUTF8)
@@ -413,15 +733,19 @@ func (c *leegoContext) String(code int, s string) (err error) {
 }
 
 func (c *leegoContext) JSON(code int, i interface{}) (err error) {
-	b, err := json.Marshal(i)
-	c.Response().SetBody(string(b))
+	buf := acquireBuffer()
+	defer releaseBuffer(buf)
+	if err = encodeJSON(buf, i); err != nil {
+		c.Response().SetBody("")
+		return err
+	}
+	if c.leego.captureBody {
+		c.Response().SetBody(buf.String())
+	}
 	//if c.leego.Debug() {
 	//	b, err = json.MarshalIndent(i, "", "  ")
 	//}
-	if err != nil {
-		return err
-	}
-	return c.JSONBlob(code, b)
+	return c.JSONBlob(code, buf.Bytes())
 }
 
 func (c *leegoContext) JSONBlob(code int, b []byte) (err error) {
@@ -431,33 +755,54 @@ func (c *leegoContext) JSONBlob(code int, b []byte) (err error) {
 	return
 }
 
-func (c *leegoContext) JSONP(code int, callback string, i interface{}) (err error) {
-	b, err := json.Marshal(i)
-	if err != nil {
+func (c *leegoContext) JSONWithETag(code int, i interface{}) (err error) {
+	buf := acquireBuffer()
+	defer releaseBuffer(buf)
+	if err = encodeJSON(buf, i); err != nil {
 		return err
 	}
-	c.response.Header().Set(HeaderContentType, MIMEApplicationJavaScriptCharsetUTF8)
-	c.response.WriteHeader(code)
-	if _, err = c.response.Write([]byte(callback + "(")); err != nil {
+	etag := etagOf(buf.Bytes())
+	c.response.Header().Set(HeaderETag, etag)
+	if m := c.request.Method(); (m == GET || m == HEAD) &&
+		MatchETag(c.request.Header().Get(HeaderIfNoneMatch), etag) {
+		c.response.WriteHeader(http.StatusNotModified)
 		return
 	}
-	if _, err = c.response.Write(b); err != nil {
-		return
+	if c.leego.captureBody {
+		c.Response().SetBody(buf.String())
+	}
+	return c.JSONBlob(code, buf.Bytes())
+}
+
+func (c *leegoContext) JSONP(code int, callback string, i interface{}) (err error) {
+	buf := acquireBuffer()
+	defer releaseBuffer(buf)
+	buf.WriteString(callback)
+	buf.WriteByte('(')
+	if err = encodeJSON(buf, i); err != nil {
+		return err
 	}
-	_, err = c.response.Write([]byte(");"))
+	buf.WriteString(");")
+	c.response.Header().Set(HeaderContentType, MIMEApplicationJavaScriptCharsetUTF8)
+	c.response.WriteHeader(code)
+	_, err = c.response.Write(buf.Bytes())
 	return
 }
 
 func (c *leegoContext) XML(code int, i interface{}) (err error) {
-	b, err := xml.Marshal(i)
-	c.Response().SetBody(string(b))
+	buf := acquireBuffer()
+	defer releaseBuffer(buf)
+	if err = xml.NewEncoder(buf).Encode(i); err != nil {
+		c.Response().SetBody("")
+		return err
+	}
+	if c.leego.captureBody {
+		c.Response().SetBody(buf.String())
+	}
 	//if c.leego.Debug() {
 	//	b, err = xml.MarshalIndent(i, "", "  ")
 	//}
-	if err != nil {
-		return err
-	}
-	return c.XMLBlob(code, b)
+	return c.XMLBlob(code, buf.Bytes())
 }
 
 func (c *leegoContext) XMLBlob(code int, b []byte) (err error) {
@@ -470,6 +815,50 @@ func (c *leegoContext) XMLBlob(code int, b []byte) (err error) {
 	return
 }
 
+// streamChunkSize is the size of the chunks written by `Context#Stream()`.
+const streamChunkSize = 32 << 10 // 32 KB
+
+func (c *leegoContext) Stream(code int, contentType string, r io.Reader) (err error) {
+	c.response.Header().Set(HeaderContentType, contentType)
+	c.response.WriteHeader(code)
+	buf := make([]byte, streamChunkSize)
+	for {
+		n, rerr := r.Read(buf)
+		if n > 0 {
+			if _, err = c.response.Write(buf[:n]); err != nil {
+				return
+			}
+			c.response.Flush()
+		}
+		if rerr == io.EOF {
+			return nil
+		}
+		if rerr != nil {
+			return rerr
+		}
+	}
+}
+
+func (c *leegoContext) WebSocket(h func(*websocket.Conn) error) error {
+	ws, err := websocket.Upgrade(c.request, c.response)
+	if err != nil {
+		if he, ok := err.(*websocket.HandshakeError); ok {
+			return NewHTTPError(he.Code, he.Message)
+		}
+		return err
+	}
+	defer ws.Close()
+	return h(ws)
+}
+
+func (c *leegoContext) Push(target string, opts ...*engine.PushOptions) error {
+	var o *engine.PushOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	return c.response.Push(target, o)
+}
+
 func (c *leegoContext) File(file string) error {
 	f, err := os.Open(file)
 	if err != nil {
@@ -484,6 +873,7 @@ func (c *leegoContext) File(file string) error {
 		if err != nil {
 			return ErrNotFound
 		}
+		defer f.Close()
 		if fi, err = f.Stat(); err != nil {
 			return err
 		}
@@ -514,13 +904,17 @@ func (c *leegoContext) Redirect(code int, url string) error {
 }
 
 func (c *leegoContext) Error(err error) {
-	c.leego.httpErrorHandler(err, c)
+	c.leego.httpErrorHandlerFor(c.request.URL().Path())(err, c)
 }
 
 func (c *leegoContext) Leego() *Leego {
 	return c.leego
 }
 
+func (c *leegoContext) Reverse(name string, params ...interface{}) string {
+	return c.leego.Reverse(name, params...)
+}
+
 func (c *leegoContext) Handler() HandlerFunc {
 	return c.handler
 }
@@ -537,19 +931,39 @@ func (c *leegoContext) ServeContent(content io.ReadSeeker, name string, modtime
 	req := c.Request()
 	res := c.Response()
 
-	if t, err := time.Parse(http.TimeFormat, req.Header().Get(HeaderIfModifiedSince)); err == nil && modtime.Before(t.Add(1*time.Second)) {
+	// If-None-Match takes precedence over If-Modified-Since, see RFC 7232.
+	// Without an ETag set by the handler, the content is sent and the ETag
+	// middleware, if any, compares the ETag of the body.
+	notModified := false
+	if inm := req.Header().Get(HeaderIfNoneMatch); inm != "" {
+		etag := res.Header().Get(HeaderETag)
+		notModified = etag != "" && MatchETag(inm, etag)
+	} else if t, err := time.Parse(http.TimeFormat, req.Header().Get(HeaderIfModifiedSince)); err == nil && modtime.Before(t.Add(1*time.Second)) {
+		notModified = true
+	}
+	if notModified {
 		res.Header().Del(HeaderContentType)
 		res.Header().Del(HeaderContentLength)
 		return c.NoContent(http.StatusNotModified)
 	}
 
+	var lm [len(http.TimeFormat)]byte
 	res.Header().Set(HeaderContentType, ContentTypeByExtension(name))
-	res.Header().Set(HeaderLastModified, modtime.UTC().Format(http.TimeFormat))
+	res.Header().Set(HeaderLastModified, string(modtime.UTC().AppendFormat(lm[:0], http.TimeFormat)))
 	res.WriteHeader(http.StatusOK)
 	_, err := io.Copy(res, content)
 	return err
 }
 
+// requestContext returns the context of req if the engine has one, background
+// otherwise.
+func requestContext(req engine.Request) context.Context {
+	if r, ok := req.(interface{ Context() context.Context }); ok {
+		return r.Context()
+	}
+	return context.Background()
+}
+
 // ContentTypeByExtension returns the MIME type associated with the file based on
 // its extension. It returns `application/octet-stream` incase MIME type is not
 // found.
@@ -561,9 +975,22 @@ func ContentTypeByExtension(name string) (t string) {
 }
 
 func (c *leegoContext) Reset(req engine.Request, res engine.Response) {
-	c.context = context.Background()
+	c.context = requestContext(req)
 	c.request = req
 	c.response = res
 	c.handler = NotFoundHandler
-	c.data = make(map[string]interface{})
+	// Cleared in place, so it's allocated once per pooled context.
+	for k := range c.data {
+		delete(c.data, k)
+	}
+	if c.storeShared {
+		c.store = nil
+		c.storeShared = false
+	}
+	for k := range c.store {
+		delete(c.store, k)
+	}
+	c.forwarded = Forwarded{}
+	c.forwardedOK = false
+	c.releaseParamsMap()
 }
@@ -1,14 +1,23 @@
 package engine
 
 import (
+	"bufio"
+	"crypto/tls"
+	"errors"
 	"io"
 	"mime/multipart"
 	"net"
 	"time"
 
+	"golang.org/x/net/context"
+
 	"github.com/go-wyvern/logger"
 )
 
+// ErrPushNotSupported is returned by `Response#Push()` when push isn't
+// supported.
+var ErrPushNotSupported = errors.New("push not supported")
+
 type (
 	// Server defines the interface for HTTP server.
 	Server interface {
@@ -21,6 +30,11 @@ type (
 		Stop()
 		// Start starts th e HTTP server.
 		Start() error
+
+		// Shutdown gracefully shuts down the HTTP server: it stops accepting
+		// connections and waits for the in-flight requests to complete, or until
+		// ctx is done.
+		Shutdown(context.Context) error
 	}
 
 	// Request defines the interface for HTTP request.
@@ -97,8 +111,41 @@ type (
 
 		// Cookies returns the HTTP cookies sent with the request.
 		Cookies() []Cookie
+
+		// Conn returns the connection the request arrived on. It is nil unless
+		// connection hooks are configured, see `Config#ConnHooks`.
+		Conn() Conn
+
+		// ConnRequests returns the number of requests received on the
+		// connection so far, this one included, 0 if unknown.
+		ConnRequests() int64
+
+		// TLSConnectionState returns the TLS state of the connection, nil for
+		// plain connections.
+		TLSConnectionState() *tls.ConnectionState
+	}
+
+	// Conn defines the interface for an accepted connection. Values attached to
+	// it by connection hooks are available to every request served on it.
+	Conn interface {
+		net.Conn
+
+		// ServerName returns the TLS SNI server name requested by the client. It is
+		// empty for plain connections.
+		ServerName() string
+
+		// Get retrieves connection metadata.
+		Get(string) interface{}
+
+		// Set saves connection metadata.
+		Set(string, interface{})
 	}
 
+	// ConnHook is executed when a connection is accepted, before any HTTP
+	// parsing. For TLS connections it runs once the client hello is received, so
+	// `Conn#ServerName()` is available. Returning an error closes the connection.
+	ConnHook func(Conn) error
+
 	// Response defines the interface for HTTP response.
 	Response interface {
 		// Header returns `engine.Header`
@@ -110,6 +157,19 @@ type (
 		// Write writes the data to the connection as part of an HTTP reply.
 		Write(b []byte) (int, error)
 
+		// Flush sends any buffered data to the client.
+		Flush()
+
+		// Hijack takes over the underlying connection, e.g. to upgrade it to the
+		// WebSocket protocol. The response is committed and the caller is
+		// responsible for closing the connection.
+		Hijack() (net.Conn, *bufio.ReadWriter, error)
+
+		// Push initiates an HTTP/2 server push of target, so the client gets it
+		// before requesting it. It returns `ErrPushNotSupported` if the connection
+		// or the client doesn't support push. opts may be nil.
+		Push(target string, opts *PushOptions) error
+
 		// SetCookie adds a `Set-Cookie` header in HTTP response.
 		SetCookie(Cookie)
 
@@ -150,6 +210,9 @@ type (
 		// no values associated with the key, Get returns "".
 		Get(string) string
 
+		// Values returns all the values associated with the given key.
+		Values(string) []string
+
 		// Keys returns the header keys.
 		Keys() []string
 
@@ -207,6 +270,132 @@ type (
 		TLSKeyFile   string        // TLS key file path.
 		ReadTimeout  time.Duration // Maximum duration before timing out read of the request.
 		WriteTimeout time.Duration // Maximum duration before timing out write of the response.
+		ConnHooks    []ConnHook    // Hooks executed for every accepted connection.
+
+		// ReadHeaderTimeout is the maximum duration for reading the request
+		// headers, so that slow clients can't hold connections with partial
+		// headers. Optional. Default value ReadTimeout.
+		ReadHeaderTimeout time.Duration
+
+		// IdleTimeout is the maximum duration to wait for the next request on a
+		// keep-alive connection. Optional. Default value ReadTimeout.
+		IdleTimeout time.Duration
+
+		// MaxHeaderBytes is the maximum size of the request headers, the request
+		// line included. Optional. Default value 1 MB.
+		MaxHeaderBytes int
+
+		// DisableKeepAlives closes the connections after every response.
+		DisableKeepAlives bool
+
+		// KeepAlivePeriod is the period of the TCP keep-alive probes of the
+		// connections accepted on `Address`. Negative disables them. Optional.
+		// Default value 15 seconds.
+		KeepAlivePeriod time.Duration
+
+		// TLSCerts maps SNI server names to certificates. A name may be a wildcard
+		// like `*.example.com`. The certificate from `TLSCertFile` is used for
+		// names not found.
+		TLSCerts map[string]TLSCert
+
+		// GetCertificate is consulted first for every TLS handshake. Returning a
+		// nil certificate and nil error falls back to `TLSCerts`.
+		GetCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+
+		// TLSReload reloads certificates, without a restart, when their files
+		// change on disk.
+		TLSReload bool
+
+		// OCSPStapling fetches OCSP responses from the certificate issuers and
+		// staples them to the TLS handshakes. Responses are refreshed halfway
+		// through their validity. Certificate files must contain the issuer chain.
+		OCSPStapling bool
+
+		// TLSRefreshInterval is the interval at which certificate files and OCSP
+		// responses are checked. Optional. Default value 1 minute.
+		TLSRefreshInterval time.Duration
+
+		// TLSTicketKeyRotation is the interval at which TLS session ticket keys are
+		// rotated, preserving forward secrecy in long-running servers. Tickets
+		// encrypted with the two previous keys are still accepted. Zero disables
+		// rotation.
+		TLSTicketKeyRotation time.Duration
+
+		// SecretProvider supplies the session ticket keys when set, so that a
+		// cluster of servers shares them. Otherwise keys are generated locally.
+		SecretProvider SecretProvider
+
+		// ProxyProtocol enables the PROXY protocol on the listener, so the remote
+		// address and TLS information reflect the client behind an L4 load
+		// balancer.
+		ProxyProtocol *ProxyProtocolConfig
+
+		// DisableHTTP2 disables HTTP/2, which is negotiated over TLS by default.
+		DisableHTTP2 bool
+
+		// H2C enables HTTP/2 over cleartext TCP (h2c), with prior knowledge or
+		// upgraded from HTTP/1.1, e.g. behind a TLS terminating load balancer.
+		H2C bool
+
+		// AutoTLS obtains and renews certificates automatically from Let's
+		// Encrypt (ACME). The certificates from `TLSCertFile` and `TLSCerts` are
+		// used for the names it doesn't serve.
+		AutoTLS *AutoTLSConfig
+	}
+
+	// AutoTLSConfig defines the config for automatic TLS.
+	AutoTLSConfig struct {
+		// Hosts lists the host names certificates are requested for. Requests
+		// for other names are refused, so clients can't make the server request
+		// arbitrary certificates.
+		// Required.
+		Hosts []string
+
+		// CacheDir is the directory where certificates are cached between
+		// restarts. Without it, certificates are requested on every start and
+		// may hit the rate limits of Let's Encrypt.
+		CacheDir string
+
+		// Email is the contact address for the ACME account, e.g. to be notified
+		// of problems with the certificates.
+		Email string
+	}
+
+	// PushOptions describes the options of `Response#Push()`.
+	PushOptions struct {
+		// Method is the method of the promised request, `GET` or `HEAD`.
+		// Default value `GET`.
+		Method string
+
+		// Header holds additional headers of the promised request.
+		Header map[string][]string
+	}
+
+	// ProxyProtocolConfig defines the config for the HAProxy PROXY protocol (v1
+	// and v2) on a listener.
+	ProxyProtocolConfig struct {
+		// TrustedSources lists the IPs or CIDR ranges of the load balancers
+		// allowed to send a PROXY header. Connections from trusted sources must
+		// start with one; other connections are served as is. Empty trusts every
+		// source.
+		TrustedSources []string
+
+		// HeaderTimeout is the maximum duration to read the PROXY header.
+		// Optional. Default value 5 seconds.
+		HeaderTimeout time.Duration
+	}
+
+	// SecretProvider supplies secrets shared by a cluster of servers.
+	SecretProvider interface {
+		// SessionTicketKeys returns the current TLS session ticket keys. The first
+		// key encrypts new tickets, all of them decrypt.
+		SessionTicketKeys() ([][32]byte, error)
+	}
+
+	// TLSCert defines a certificate and key file pair.
+	TLSCert struct {
+		CertFile string // TLS certificate file path.
+		KeyFile  string // TLS key file path.
 	}
 
 	// Handler defines an interface to server HTTP requests via `ServeHTTP(Request, Response)`
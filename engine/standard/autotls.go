@@ -0,0 +1,68 @@
+package standard
+
+import (
+	"crypto/tls"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/go-wyvern/leego"
+)
+
+// setupAutoTLS installs the ACME certificate manager. Its certificates are
+// consulted first by the certificate manager, see `engine.Config#GetCertificate`.
+// TLS-ALPN-01 challenges are answered during the handshake, HTTP-01 challenges
+// by `Server#ACMEChallengeHandler()`.
+func (s *Server) setupAutoTLS() {
+	c := s.config.AutoTLS
+	if len(c.Hosts) == 0 {
+		panic("auto tls requires hosts")
+	}
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(c.Hosts...),
+		Email:      c.Email,
+	}
+	if c.CacheDir != "" {
+		m.Cache = autocert.DirCache(c.CacheDir)
+	}
+	s.acme = m
+
+	next := s.config.GetCertificate
+	fallback := s.config.TLSCertFile != "" || len(s.config.TLSCerts) > 0
+	s.config.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		cert, err := m.GetCertificate(hello)
+		switch {
+		case err == nil:
+			return cert, nil
+		case next != nil:
+			return next(hello)
+		case fallback:
+			// Let the certificate manager select a configured certificate.
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if s.TLSConfig == nil {
+		s.TLSConfig = new(tls.Config)
+	}
+	protos := []string{"h2", "http/1.1", acme.ALPNProto}
+	if s.config.DisableHTTP2 {
+		protos = protos[1:]
+	}
+	s.TLSConfig.NextProtos = append(protos, s.TLSConfig.NextProtos...)
+}
+
+// ACMEChallengeHandler returns the handler answering the ACME HTTP-01
+// challenges of `engine.Config#AutoTLS`, to register on the plain HTTP server:
+//
+//	lee.GET("/.well-known/acme-challenge/*", s.ACMEChallengeHandler())
+//
+// Other requests are redirected to HTTPS. It returns nil without auto TLS.
+func (s *Server) ACMEChallengeHandler() leego.HandlerFunc {
+	if s.acme == nil {
+		return nil
+	}
+	return WrapHandler(s.acme.HTTPHandler(nil))
+}
@@ -0,0 +1,183 @@
+package standard
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+)
+
+type (
+	// Conn implements `engine.Conn`.
+	Conn struct {
+		net.Conn
+		serverName string
+		mu         sync.RWMutex
+		values     map[string]interface{}
+	}
+
+	hookListener struct {
+		net.Listener
+		server *Server
+	}
+
+	connRequestsKey struct{}
+	connKey         struct{}
+)
+
+func newConn(c net.Conn, serverName string) *Conn {
+	return &Conn{
+		Conn:       c,
+		serverName: serverName,
+		values:     make(map[string]interface{}),
+	}
+}
+
+// ServerName implements `engine.Conn#ServerName` function. For PROXY protocol
+// connections it defaults to the authority sent in the header.
+func (c *Conn) ServerName() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.serverName == "" {
+		if pc := c.proxied(); pc != nil {
+			return pc.authority
+		}
+	}
+	return c.serverName
+}
+
+// Get implements `engine.Conn#Get` function.
+func (c *Conn) Get(key string) interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.values[key]
+}
+
+// proxied returns the PROXY protocol connection underlying c, if any.
+func (c *Conn) proxied() *proxyConn {
+	pc, _ := c.Conn.(*proxyConn)
+	return pc
+}
+
+// Set implements `engine.Conn#Set` function.
+func (c *Conn) Set(key string, val interface{}) {
+	c.mu.Lock()
+	c.values[key] = val
+	c.mu.Unlock()
+}
+
+// Accept runs the connection hooks for every accepted connection, closing the
+// ones rejected by a hook. For TLS they run on the client hello instead, see
+// `Server#tlsConnHooks()`.
+func (l *hookListener) Accept() (net.Conn, error) {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		hc := newConn(c, "")
+		if l.server.tlsHooks {
+			return hc, nil
+		}
+		if err = l.server.runConnHooks(hc); err != nil {
+			c.Close()
+			continue
+		}
+		return hc, nil
+	}
+}
+
+func (s *Server) hasConnHooks() bool {
+	return len(s.config.ConnHooks) > 0
+}
+
+// trackConns returns true if requests need to find the connection they
+// arrived on.
+func (s *Server) trackConns() bool {
+	return s.hasConnHooks() || s.config.ProxyProtocol != nil
+}
+
+// wrapListener wraps l so that the PROXY protocol is parsed and connection
+// hooks run on accept.
+func (s *Server) wrapListener(l net.Listener) (net.Listener, error) {
+	if s.config.ProxyProtocol != nil {
+		pl, err := newProxyListener(l, *s.config.ProxyProtocol)
+		if err != nil {
+			return nil, err
+		}
+		l = pl
+	}
+	if s.hasConnHooks() {
+		l = &hookListener{Listener: l, server: s}
+	}
+	return l, nil
+}
+
+// tlsConnHooks makes connection hooks run once the TLS client hello is
+// received, when the SNI server name is known.
+func (s *Server) tlsConnHooks() {
+	if !s.hasConnHooks() {
+		return
+	}
+	if s.TLSConfig == nil {
+		s.TLSConfig = new(tls.Config)
+	}
+	s.tlsHooks = true
+	next := s.TLSConfig.GetConfigForClient
+	s.TLSConfig.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		// The connection accepted by `hookListener`, which requests resolve to.
+		hc, ok := hello.Conn.(*Conn)
+		if !ok {
+			hc = newConn(hello.Conn, "")
+		}
+		hc.mu.Lock()
+		hc.serverName = hello.ServerName
+		hc.mu.Unlock()
+		if err := s.runConnHooks(hc); err != nil {
+			return nil, err
+		}
+		if next != nil {
+			return next(hello)
+		}
+		return nil, nil
+	}
+}
+
+func (s *Server) runConnHooks(c *Conn) error {
+	for _, h := range s.config.ConnHooks {
+		if err := h(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// connContext attaches the request counter of the connection, see
+// `engine.Request#ConnRequests()`, and the tracked connection to the context of
+// its requests.
+func (s *Server) connContext(ctx context.Context, c net.Conn) context.Context {
+	ctx = context.WithValue(ctx, connRequestsKey{}, new(int64))
+	if !s.trackConns() {
+		return ctx
+	}
+	// `*tls.Conn` wraps the accepted connection.
+	if tc, ok := c.(interface{ NetConn() net.Conn }); ok {
+		c = tc.NetConn()
+	}
+	switch c := c.(type) {
+	case *Conn:
+		return context.WithValue(ctx, connKey{}, c)
+	case *proxyConn:
+		if c.trusted {
+			return context.WithValue(ctx, connKey{}, newConn(c, ""))
+		}
+	}
+	return ctx
+}
+
+// conn returns the tracked connection the request arrived on.
+func conn(r *http.Request) *Conn {
+	c, _ := r.Context().Value(connKey{}).(*Conn)
+	return c
+}
@@ -0,0 +1,97 @@
+package standard
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-wyvern/leego/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnHooks(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	var reject int32
+	s := WithConfig(engine.Config{
+		Listener: l,
+		ConnHooks: []engine.ConnHook{
+			func(c engine.Conn) error {
+				if atomic.LoadInt32(&reject) == 1 {
+					return errors.New("rejected")
+				}
+				c.Set("peer", "trusted")
+				return nil
+			},
+		},
+	})
+	s.SetHandler(engine.HandlerFunc(func(req engine.Request, res engine.Response) {
+		res.Write([]byte(req.Conn().Get("peer").(string)))
+	}))
+	go s.Start()
+	defer s.Stop()
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	res, err := client.Get("http://" + l.Addr().String())
+	if assert.NoError(t, err) {
+		b, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		assert.Equal(t, "trusted", string(b))
+	}
+
+	atomic.StoreInt32(&reject, 1)
+	_, err = client.Get("http://" + l.Addr().String())
+	assert.Error(t, err)
+}
+
+func TestConnHooksProxyProtocol(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	var n int32
+	s := WithConfig(engine.Config{
+		Listener:      l,
+		ProxyProtocol: &engine.ProxyProtocolConfig{},
+		ConnHooks: []engine.ConnHook{
+			func(c engine.Conn) error {
+				c.Set("n", atomic.AddInt32(&n, 1))
+				return nil
+			},
+		},
+	})
+	s.SetHandler(engine.HandlerFunc(func(req engine.Request, res engine.Response) {
+		fmt.Fprint(res, req.Conn().Get("n"))
+	}))
+	go s.Start()
+	defer s.Stop()
+
+	// Both connections are from the same client address.
+	get := func(c net.Conn, br *bufio.Reader) string {
+		c.Write([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+		res, err := http.ReadResponse(br, nil)
+		if !assert.NoError(t, err) {
+			return ""
+		}
+		b, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		return string(b)
+	}
+	var conns [2]net.Conn
+	var readers [2]*bufio.Reader
+	for i := range conns {
+		c, err := net.Dial("tcp", l.Addr().String())
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer c.Close()
+		c.Write([]byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 80\r\n"))
+		conns[i], readers[i] = c, bufio.NewReader(c)
+	}
+	assert.Equal(t, "1", get(conns[0], readers[0]))
+	assert.Equal(t, "2", get(conns[1], readers[1]))
+	assert.Equal(t, "1", get(conns[0], readers[0]))
+}
@@ -29,6 +29,11 @@ func (h *Header) Get(key string) string {
 	return h.Header.Get(key)
 }
 
+// Values implements `engine.Header#Values` function.
+func (h *Header) Values(key string) []string {
+	return h.Header.Values(key)
+}
+
 // Keys implements `engine.Header#Keys` function.
 func (h *Header) Keys() (keys []string) {
 	keys = make([]string, len(h.Header))
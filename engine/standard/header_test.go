@@ -0,0 +1,31 @@
+package standard
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderSet(t *testing.T) {
+	h := &Header{Header: make(http.Header)}
+	h.Set("content-type", leego.MIMEApplicationJSONCharsetUTF8)
+	assert.Equal(t, leego.MIMEApplicationJSONCharsetUTF8, h.Get(leego.HeaderContentType))
+
+	// Changes to the values must not leak into other headers.
+	h2 := &Header{Header: make(http.Header)}
+	h2.Set(leego.HeaderContentType, leego.MIMEApplicationJSONCharsetUTF8)
+	h.Header[leego.HeaderContentType][0] = leego.MIMETextPlain
+	h.Add(leego.HeaderContentType, "extra")
+	assert.Equal(t, []string{leego.MIMEApplicationJSONCharsetUTF8}, h2.Header[leego.HeaderContentType])
+}
+
+func BenchmarkHeaderSet(b *testing.B) {
+	h := &Header{Header: make(http.Header)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h.Set(leego.HeaderContentType, leego.MIMEApplicationJSONCharsetUTF8)
+		h.Set(leego.HeaderVary, leego.HeaderAcceptEncoding)
+	}
+}
@@ -0,0 +1,191 @@
+package standard
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+// Minimal OCSP (RFC 6960) structures, enough to build a request and read the
+// status and validity of the response.
+type (
+	ocspCertID struct {
+		HashAlgorithm pkix.AlgorithmIdentifier
+		NameHash      []byte
+		IssuerKeyHash []byte
+		SerialNumber  *big.Int
+	}
+
+	ocspSingleRequest struct {
+		Cert ocspCertID
+	}
+
+	ocspTBSRequest struct {
+		Version       int              `asn1:"explicit,tag:0,default:0,optional"`
+		RequestorName pkix.RDNSequence `asn1:"explicit,tag:1,optional"`
+		RequestList   []ocspSingleRequest
+	}
+
+	ocspRequest struct {
+		TBSRequest ocspTBSRequest
+	}
+
+	ocspResponse struct {
+		Status   asn1.Enumerated
+		Response ocspResponseBytes `asn1:"explicit,tag:0,optional"`
+	}
+
+	ocspResponseBytes struct {
+		ResponseType asn1.ObjectIdentifier
+		Response     []byte
+	}
+
+	ocspBasicResponse struct {
+		TBSResponseData    ocspResponseData
+		SignatureAlgorithm pkix.AlgorithmIdentifier
+		Signature          asn1.BitString
+		Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+	}
+
+	ocspResponseData struct {
+		Raw            asn1.RawContent
+		Version        int `asn1:"optional,default:0,explicit,tag:0"`
+		RawResponderID asn1.RawValue
+		ProducedAt     time.Time `asn1:"generalized"`
+		Responses      []ocspSingleResponse
+	}
+
+	ocspSingleResponse struct {
+		CertID           ocspCertID
+		Good             asn1.Flag        `asn1:"tag:0,optional"`
+		Revoked          ocspRevokedInfo  `asn1:"tag:1,optional"`
+		Unknown          asn1.Flag        `asn1:"tag:2,optional"`
+		ThisUpdate       time.Time        `asn1:"generalized"`
+		NextUpdate       time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+		SingleExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+	}
+
+	ocspRevokedInfo struct {
+		RevocationTime time.Time       `asn1:"generalized"`
+		Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+	}
+)
+
+const (
+	mimeOCSPRequest     = "application/ocsp-request"
+	ocspDefaultValidity = time.Hour
+)
+
+var (
+	oidSHA1              = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidOCSPBasicResponse = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+
+	ocspClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+// fetchOCSP fetches the OCSP response for the leaf certificate of cert from its
+// issuer. It returns the raw response to staple and the time it should be
+// refreshed at.
+func fetchOCSP(cert *tls.Certificate) (staple []byte, next time.Time, err error) {
+	if len(cert.Certificate) < 2 {
+		return nil, next, errors.New("certificate chain has no issuer")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return
+	}
+	issuer, err := x509.ParseCertificate(cert.Certificate[1])
+	if err != nil {
+		return
+	}
+	if len(leaf.OCSPServer) == 0 {
+		return nil, next, errors.New("certificate has no OCSP server")
+	}
+	req, err := newOCSPRequest(leaf, issuer)
+	if err != nil {
+		return
+	}
+	res, err := ocspClient.Post(leaf.OCSPServer[0], mimeOCSPRequest, bytes.NewReader(req))
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, next, fmt.Errorf("OCSP server returned %s", res.Status)
+	}
+	staple, err = ioutil.ReadAll(res.Body)
+	if err != nil {
+		return
+	}
+	next, err = checkOCSPResponse(staple, leaf.SerialNumber)
+	return
+}
+
+func newOCSPRequest(leaf, issuer *x509.Certificate) ([]byte, error) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return nil, err
+	}
+	nameHash := sha1.Sum(issuer.RawSubject)
+	keyHash := sha1.Sum(spki.PublicKey.RightAlign())
+	return asn1.Marshal(ocspRequest{
+		TBSRequest: ocspTBSRequest{
+			RequestList: []ocspSingleRequest{{
+				Cert: ocspCertID{
+					HashAlgorithm: pkix.AlgorithmIdentifier{
+						Algorithm:  oidSHA1,
+						Parameters: asn1.RawValue{Tag: 5 /* ASN.1 NULL */},
+					},
+					NameHash:      nameHash[:],
+					IssuerKeyHash: keyHash[:],
+					SerialNumber:  leaf.SerialNumber,
+				},
+			}},
+		},
+	})
+}
+
+// checkOCSPResponse verifies that the response is successful and reports the
+// certificate with the serial as good. It returns the time halfway through the
+// response validity. The signature is verified by the clients.
+func checkOCSPResponse(b []byte, serial *big.Int) (next time.Time, err error) {
+	var res ocspResponse
+	if _, err = asn1.Unmarshal(b, &res); err != nil {
+		return
+	}
+	if res.Status != 0 {
+		return next, fmt.Errorf("OCSP response status %d", res.Status)
+	}
+	if !res.Response.ResponseType.Equal(oidOCSPBasicResponse) {
+		return next, errors.New("unsupported OCSP response type")
+	}
+	var basic ocspBasicResponse
+	if _, err = asn1.Unmarshal(res.Response.Response, &basic); err != nil {
+		return
+	}
+	for _, r := range basic.TBSResponseData.Responses {
+		if r.CertID.SerialNumber.Cmp(serial) != 0 {
+			continue
+		}
+		if !r.Good {
+			return next, errors.New("certificate is not reported good by OCSP")
+		}
+		if r.NextUpdate.IsZero() {
+			return time.Now().Add(ocspDefaultValidity), nil
+		}
+		return r.ThisUpdate.Add(r.NextUpdate.Sub(r.ThisUpdate) / 2), nil
+	}
+	return next, errors.New("OCSP response doesn't cover the certificate")
+}
@@ -0,0 +1,279 @@
+package standard
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-wyvern/leego/engine"
+)
+
+type (
+	// proxyListener accepts connections which start with a PROXY protocol
+	// header. The header is read lazily, on the connection's goroutine, so a slow
+	// client doesn't block the accept loop.
+	proxyListener struct {
+		net.Listener
+		trusted []*net.IPNet
+		timeout time.Duration
+	}
+
+	proxyConn struct {
+		net.Conn
+		br        *bufio.Reader
+		trusted   bool
+		timeout   time.Duration
+		once      sync.Once
+		err       error
+		remote    net.Addr
+		local     net.Addr
+		tls       bool
+		authority string
+	}
+)
+
+const (
+	defaultProxyHeaderTimeout = 5 * time.Second
+	proxyV1MaxLength          = 107
+
+	// PROXY protocol v2 TLV types.
+	pp2TypeAuthority = 0x02
+	pp2TypeSSL       = 0x20
+	pp2ClientSSL     = 0x01
+)
+
+var (
+	proxyV1Prefix    = []byte("PROXY ")
+	proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	errProxyHeader = errors.New("invalid PROXY protocol header")
+)
+
+func newProxyListener(l net.Listener, c engine.ProxyProtocolConfig) (*proxyListener, error) {
+	pl := &proxyListener{Listener: l, timeout: c.HeaderTimeout}
+	if pl.timeout <= 0 {
+		pl.timeout = defaultProxyHeaderTimeout
+	}
+	for _, s := range c.TrustedSources {
+		if !strings.Contains(s, "/") {
+			if strings.Contains(s, ":") {
+				s += "/128"
+			} else {
+				s += "/32"
+			}
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		pl.trusted = append(pl.trusted, n)
+	}
+	return pl, nil
+}
+
+// Accept implements `net.Listener#Accept` function.
+func (l *proxyListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &proxyConn{
+		Conn:    c,
+		br:      bufio.NewReader(c),
+		trusted: l.isTrusted(c.RemoteAddr()),
+		timeout: l.timeout,
+	}, nil
+}
+
+func (l *proxyListener) isTrusted(addr net.Addr) bool {
+	if len(l.trusted) == 0 {
+		return true
+	}
+	ta, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, n := range l.trusted {
+		if n.Contains(ta.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// init reads the PROXY header once, for trusted sources only.
+func (c *proxyConn) init() {
+	c.once.Do(func() {
+		if !c.trusted {
+			return
+		}
+		c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+		c.err = c.readHeader()
+		c.Conn.SetReadDeadline(time.Time{})
+	})
+}
+
+// Read implements `net.Conn#Read` function.
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.br.Read(b)
+}
+
+// RemoteAddr returns the client address sent in the PROXY header.
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.init()
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the destination address sent in the PROXY header.
+func (c *proxyConn) LocalAddr() net.Addr {
+	c.init()
+	if c.local != nil {
+		return c.local
+	}
+	return c.Conn.LocalAddr()
+}
+
+func (c *proxyConn) readHeader() error {
+	b, err := c.br.Peek(len(proxyV1Prefix))
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(b, proxyV1Prefix) {
+		return c.readV1()
+	}
+	if b, err = c.br.Peek(len(proxyV2Signature)); err != nil {
+		return err
+	}
+	if bytes.Equal(b, proxyV2Signature) {
+		return c.readV2()
+	}
+	return errProxyHeader
+}
+
+// readV1 reads a human-readable header,
+// e.g. `PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n`.
+func (c *proxyConn) readV1() error {
+	var line []byte
+	for {
+		b, err := c.br.ReadByte()
+		if err != nil {
+			return err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+		if len(line) >= proxyV1MaxLength {
+			return errProxyHeader
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return errProxyHeader
+	}
+	f := strings.Fields(string(line[:len(line)-2]))
+	if len(f) >= 2 && f[1] == "UNKNOWN" {
+		return nil
+	}
+	if len(f) != 6 || (f[1] != "TCP4" && f[1] != "TCP6") {
+		return errProxyHeader
+	}
+	src, err := parseProxyAddr(f[2], f[4])
+	if err != nil {
+		return err
+	}
+	dst, err := parseProxyAddr(f[3], f[5])
+	if err != nil {
+		return err
+	}
+	c.remote, c.local = src, dst
+	return nil
+}
+
+func parseProxyAddr(host, port string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid PROXY protocol address %q", host)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROXY protocol port %q", port)
+	}
+	return &net.TCPAddr{IP: ip, Port: int(p)}, nil
+}
+
+// readV2 reads a binary header with its TLVs.
+func (c *proxyConn) readV2() error {
+	hdr := make([]byte, 16)
+	if _, err := io.ReadFull(c.br, hdr); err != nil {
+		return err
+	}
+	if hdr[12]>>4 != 2 {
+		return errProxyHeader
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(hdr[14:16]))
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return err
+	}
+	if hdr[12]&0x0f == 0 {
+		// LOCAL command, e.g. health checks from the load balancer.
+		return nil
+	}
+
+	var n int
+	switch hdr[13] >> 4 {
+	case 1: // AF_INET
+		n = 12
+		if len(payload) < n {
+			return errProxyHeader
+		}
+		c.remote = &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}
+		c.local = &net.TCPAddr{IP: net.IP(payload[4:8]), Port: int(binary.BigEndian.Uint16(payload[10:12]))}
+	case 2: // AF_INET6
+		n = 36
+		if len(payload) < n {
+			return errProxyHeader
+		}
+		c.remote = &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}
+		c.local = &net.TCPAddr{IP: net.IP(payload[16:32]), Port: int(binary.BigEndian.Uint16(payload[34:36]))}
+	default:
+		// AF_UNSPEC and AF_UNIX addresses are ignored, along with any TLV.
+		return nil
+	}
+	return c.readTLVs(payload[n:])
+}
+
+func (c *proxyConn) readTLVs(b []byte) error {
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return errProxyHeader
+		}
+		t, l := b[0], int(binary.BigEndian.Uint16(b[1:3]))
+		if len(b) < 3+l {
+			return errProxyHeader
+		}
+		v := b[3 : 3+l]
+		switch t {
+		case pp2TypeAuthority:
+			c.authority = string(v)
+		case pp2TypeSSL:
+			c.tls = l > 0 && v[0]&pp2ClientSSL != 0
+		}
+		b = b[3+l:]
+	}
+	return nil
+}
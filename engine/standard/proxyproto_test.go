@@ -0,0 +1,72 @@
+package standard
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/go-wyvern/leego/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+func proxyServer(t *testing.T, trusted ...string) (*Server, net.Listener) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	s := WithConfig(engine.Config{
+		Listener:      l,
+		ProxyProtocol: &engine.ProxyProtocolConfig{TrustedSources: trusted},
+	})
+	s.SetHandler(engine.HandlerFunc(func(req engine.Request, res engine.Response) {
+		res.Write([]byte(req.Scheme() + " " + req.RemoteAddress()))
+	}))
+	go s.Start()
+	return s, l
+}
+
+func proxyGet(t *testing.T, addr string, header []byte) string {
+	c, err := net.Dial("tcp", addr)
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	defer c.Close()
+	c.Write(header)
+	c.Write([]byte("GET / HTTP/1.1\r\nHost: example.com\r\nConnection: close\r\n\r\n"))
+	res, err := http.ReadResponse(bufio.NewReader(c), nil)
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	b, _ := ioutil.ReadAll(res.Body)
+	return string(b)
+}
+
+func TestProxyProtocolV1(t *testing.T) {
+	s, l := proxyServer(t)
+	defer s.Stop()
+	assert.Equal(t, "http 192.0.2.1:56324", proxyGet(t, l.Addr().String(), []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n")))
+}
+
+func TestProxyProtocolV2(t *testing.T) {
+	s, l := proxyServer(t, "127.0.0.1")
+	defer s.Stop()
+
+	payload := []byte{192, 0, 2, 1, 198, 51, 100, 1, 0, 0, 1, 187}
+	binary.BigEndian.PutUint16(payload[8:10], 56324)
+	// PP2_TYPE_SSL TLV with the client SSL flag.
+	payload = append(payload, pp2TypeSSL, 0, 5, pp2ClientSSL, 0, 0, 0, 0)
+	hdr := append([]byte{}, proxyV2Signature...)
+	hdr = append(hdr, 0x21, 0x11, 0, 0)
+	binary.BigEndian.PutUint16(hdr[14:16], uint16(len(payload)))
+	hdr = append(hdr, payload...)
+	assert.Equal(t, "https 192.0.2.1:56324", proxyGet(t, l.Addr().String(), hdr))
+}
+
+func TestProxyProtocolUntrusted(t *testing.T) {
+	s, l := proxyServer(t, "10.0.0.0/8")
+	defer s.Stop()
+	res := proxyGet(t, l.Addr().String(), nil)
+	host, _, _ := net.SplitHostPort(res[len("http "):])
+	assert.Equal(t, "127.0.0.1", host)
+}
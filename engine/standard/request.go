@@ -1,6 +1,7 @@
 package standard
 
 import (
+	"crypto/tls"
 	"io"
 	"io/ioutil"
 	"mime/multipart"
@@ -17,6 +18,10 @@ type (
 		*http.Request
 		header engine.Header
 		url    engine.URL
+		conn   *Conn
+		// connRequests is the number of requests received on the connection,
+		// see `Server#connContext()`.
+		connRequests int64
 	}
 )
 
@@ -35,7 +40,15 @@ func NewRequest(r *http.Request) *Request {
 
 // IsTLS implements `engine.Request#TLS` function.
 func (r *Request) IsTLS() bool {
-	return r.Request.TLS != nil
+	if r.Request.TLS != nil {
+		return true
+	}
+	if r.conn != nil {
+		if pc := r.conn.proxied(); pc != nil {
+			return pc.tls
+		}
+	}
+	return false
 }
 
 // Scheme implements `engine.Request#Scheme` function.
@@ -175,8 +188,28 @@ func (r *Request) Cookies() []engine.Cookie {
 	return cookies
 }
 
+// Conn implements `engine.Request#Conn` function.
+func (r *Request) Conn() engine.Conn {
+	if r.conn == nil {
+		return nil
+	}
+	return r.conn
+}
+
+// ConnRequests implements `engine.Request#ConnRequests` function.
+func (r *Request) ConnRequests() int64 {
+	return r.connRequests
+}
+
+// TLSConnectionState implements `engine.Request#TLSConnectionState` function.
+func (r *Request) TLSConnectionState() *tls.ConnectionState {
+	return r.Request.TLS
+}
+
 func (r *Request) reset(req *http.Request, h engine.Header, u engine.URL) {
 	r.Request = req
 	r.header = h
 	r.url = u
+	r.conn = nil
+	r.connRequests = 0
 }
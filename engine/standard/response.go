@@ -2,6 +2,7 @@ package standard
 
 import (
 	"bufio"
+	"errors"
 	"io"
 	"net"
 	"net/http"
@@ -27,6 +28,8 @@ type (
 	}
 )
 
+var errHijackNotSupported = errors.New("response does not support hijacking the connection")
+
 // NewResponse returns `Response` instance.
 func NewResponse(w http.ResponseWriter) (r *Response) {
 	r = &Response{
@@ -116,14 +119,48 @@ func (r *Response) SetWriter(w io.Writer) {
 // buffered data to the client.
 // See https://golang.org/pkg/net/http/#Flusher
 func (r *Response) Flush() {
-	r.ResponseWriter.(http.Flusher).Flush()
+	if f, ok := r.writer.(http.Flusher); ok {
+		f.Flush()
+		return
+	}
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
 }
 
 // Hijack implements the http.Hijacker interface to allow an HTTP handler to
 // take over the connection.
 // See https://golang.org/pkg/net/http/#Hijacker
 func (r *Response) Hijack() (net.Conn, *bufio.ReadWriter, error) {
-	return r.ResponseWriter.(http.Hijacker).Hijack()
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errHijackNotSupported
+	}
+	conn, rw, err := h.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	r.committed = true
+	return conn, rw, nil
+}
+
+// Push implements `engine.Response#Push` function.
+func (r *Response) Push(target string, opts *engine.PushOptions) error {
+	p, ok := r.ResponseWriter.(http.Pusher)
+	if !ok {
+		return engine.ErrPushNotSupported
+	}
+	var po *http.PushOptions
+	if opts != nil {
+		po = &http.PushOptions{Method: opts.Method, Header: http.Header(opts.Header)}
+	}
+	if err := p.Push(target, po); err != nil {
+		if err == http.ErrNotSupported {
+			return engine.ErrPushNotSupported
+		}
+		return err
+	}
+	return nil
 }
 
 // CloseNotify implements the http.CloseNotifier interface to allow detecting
@@ -1,8 +1,16 @@
 package standard
 
 import (
+	"crypto/tls"
+	"net"
 	"net/http"
 	"sync"
+	"sync/atomic"
+
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/net/context"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 
 	"github.com/go-wyvern/leego"
 	"github.com/go-wyvern/leego/engine"
@@ -13,10 +21,17 @@ type (
 	// Server implements `engine.Server`.
 	Server struct {
 		*http.Server
-		config  engine.Config
-		handler engine.Handler
-		pool    *pool
-		logger  *logger.Logger
+		config   engine.Config
+		handler  engine.Handler
+		pool     *pool
+		logger   *logger.Logger
+		certs    *certManager
+		tickets  *ticketKeys
+		listener net.Listener
+		stopOnce sync.Once
+		acme     *autocert.Manager
+		// tlsHooks is set when connection hooks run on the TLS client hello.
+		tlsHooks bool
 	}
 
 	pool struct {
@@ -80,8 +95,22 @@ func WithConfig(c engine.Config) (s *Server) {
 	}
 	s.ReadTimeout = c.ReadTimeout
 	s.WriteTimeout = c.WriteTimeout
+	s.ReadHeaderTimeout = c.ReadHeaderTimeout
+	s.IdleTimeout = c.IdleTimeout
+	s.MaxHeaderBytes = c.MaxHeaderBytes
+	s.SetKeepAlivesEnabled(!c.DisableKeepAlives)
 	s.Addr = c.Address
 	s.Handler = s
+	if c.DisableHTTP2 {
+		// A non-nil map disables the automatic HTTP/2 support.
+		s.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	} else if c.H2C {
+		s.Handler = h2c.NewHandler(s, new(http2.Server))
+	}
+	if c.AutoTLS != nil {
+		s.setupAutoTLS()
+	}
+	s.ConnContext = s.connContext
 	return
 }
 
@@ -108,18 +137,72 @@ func (s *Server) Stop() {
 	if s.config.Listener != nil {
 		s.config.Listener.Close()
 	}
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	s.stopRotation()
+}
+
+// Shutdown implements `engine.Server#Shutdown` function.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.Server.Shutdown(ctx)
+	s.stopRotation()
+	return err
 }
 
-func (s *Server) startDefaultListener() error {
-	c := s.config
-	if c.TLSCertFile != "" && c.TLSKeyFile != "" {
-		return s.ListenAndServeTLS(c.TLSCertFile, c.TLSKeyFile)
+// stopRotation stops the certificate reloading and ticket key rotation, once.
+func (s *Server) stopRotation() {
+	s.stopOnce.Do(func() {
+		if s.certs != nil {
+			s.certs.stop()
+		}
+		if s.tickets != nil {
+			s.tickets.stop()
+		}
+	})
+}
+
+func (s *Server) startDefaultListener() (err error) {
+	addr := s.Addr
+	tlsEnabled := s.isTLS()
+	var certFile, keyFile string
+	if tlsEnabled {
+		if certFile, keyFile, err = s.setupTLS(); err != nil {
+			return
+		}
+		if err = s.setupTicketKeys(); err != nil {
+			return
+		}
+		s.tlsConnHooks()
+		if addr == "" {
+			addr = ":https"
+		}
+	} else if addr == "" {
+		addr = ":http"
+	}
+
+	lc := net.ListenConfig{KeepAlive: s.config.KeepAlivePeriod}
+	l, err := lc.Listen(context.Background(), "tcp", addr)
+	if err != nil {
+		return
+	}
+	s.listener = l
+	if l, err = s.wrapListener(l); err != nil {
+		s.listener.Close()
+		return
+	}
+	if tlsEnabled {
+		return s.ServeTLS(l, certFile, keyFile)
 	}
-	return s.ListenAndServe()
+	return s.Serve(l)
 }
 
 func (s *Server) startCustomListener() error {
-	return s.Serve(s.config.Listener)
+	l, err := s.wrapListener(s.config.Listener)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
 }
 
 // ServeHTTP implements `http.Handler` interface.
@@ -131,6 +214,10 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	reqHdr.reset(r.Header)
 	reqURL.reset(r.URL)
 	req.reset(r, reqHdr, reqURL)
+	req.conn = conn(r)
+	if n, ok := r.Context().Value(connRequestsKey{}).(*int64); ok {
+		req.connRequests = atomic.AddInt64(n, 1)
+	}
 
 	// Response
 	res := s.pool.response.Get().(*Response)
@@ -0,0 +1,190 @@
+package standard
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestServerShutdown(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	lee := leego.New()
+	lee.GET("/", func(c leego.Context) leego.LeeError {
+		close(started)
+		<-release
+		return c.String(http.StatusOK, "done")
+	})
+	delivered := make(chan struct{})
+	lee.Events().Subscribe("shutdown", func(leego.Event) {
+		close(delivered)
+	})
+	s := WithConfig(engine.Config{Listener: l})
+	stopped := make(chan struct{})
+	go func() {
+		lee.Run(s)
+		close(stopped)
+	}()
+
+	body := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://" + l.Addr().String())
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		b, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		body <- string(b)
+	}()
+	<-started
+	lee.Events().Publish("shutdown", nil)
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- lee.Shutdown(context.Background())
+	}()
+	select {
+	case <-shutdown:
+		t.Fatal("shutdown returned with a request in flight")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	assert.Equal(t, "done", <-body)
+	assert.NoError(t, <-shutdown)
+	<-stopped
+	<-delivered
+
+	// Not accepting connections anymore
+	_, err = net.Dial("tcp", l.Addr().String())
+	assert.Error(t, err)
+}
+
+func TestServerHTTP2(t *testing.T) {
+	s := WithConfig(engine.Config{DisableHTTP2: true})
+	assert.NotNil(t, s.TLSNextProto)
+	assert.Equal(t, 0, len(s.TLSNextProto))
+	s = WithConfig(engine.Config{})
+	assert.Nil(t, s.TLSNextProto)
+}
+
+type pusher struct {
+	*httptest.ResponseRecorder
+	target string
+	opts   *http.PushOptions
+	err    error
+}
+
+func (p *pusher) Push(target string, opts *http.PushOptions) error {
+	p.target, p.opts = target, opts
+	return p.err
+}
+
+func TestResponsePush(t *testing.T) {
+	res := NewResponse(httptest.NewRecorder())
+	assert.Equal(t, engine.ErrPushNotSupported, res.Push("/app.css", nil))
+
+	p := &pusher{ResponseRecorder: httptest.NewRecorder()}
+	res = NewResponse(p)
+	assert.NoError(t, res.Push("/app.css", &engine.PushOptions{Header: map[string][]string{"Accept": {"text/css"}}}))
+	assert.Equal(t, "/app.css", p.target)
+	assert.Equal(t, "text/css", p.opts.Header.Get("Accept"))
+
+	p.err = http.ErrNotSupported
+	assert.Equal(t, engine.ErrPushNotSupported, res.Push("/app.css", nil))
+}
+
+func TestServerAutoTLS(t *testing.T) {
+	s := WithConfig(engine.Config{})
+	assert.Nil(t, s.ACMEChallengeHandler())
+
+	s = WithConfig(engine.Config{AutoTLS: &engine.AutoTLSConfig{Hosts: []string{"example.com"}}})
+	assert.True(t, s.isTLS())
+	assert.NotNil(t, s.ACMEChallengeHandler())
+	assert.Equal(t, []string{"h2", "http/1.1", "acme-tls/1"}, s.TLSConfig.NextProtos)
+
+	assert.Panics(t, func() {
+		WithConfig(engine.Config{AutoTLS: &engine.AutoTLSConfig{}})
+	})
+}
+
+func TestServerConnInfo(t *testing.T) {
+	lee := leego.New()
+	lee.GET("/", func(c leego.Context) leego.LeeError {
+		return c.JSON(http.StatusOK, c.ConnInfo())
+	})
+	s := WithConfig(engine.Config{})
+	s.SetHandler(lee)
+	ts := httptest.NewUnstartedServer(nil)
+	ts.Config = s.Server
+	ts.StartTLS()
+	defer ts.Close()
+
+	client := ts.Client()
+	for i := int64(1); i <= 2; i++ {
+		res, err := client.Get(ts.URL)
+		if !assert.NoError(t, err) {
+			return
+		}
+		var info leego.ConnInfo
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&info))
+		res.Body.Close()
+		assert.Equal(t, i, info.Requests)
+		assert.Equal(t, i > 1, info.Reused)
+		assert.Contains(t, info.TLSVersion, "TLS 1.")
+		assert.NotEqual(t, "", info.TLSCipherSuite)
+	}
+}
+
+func TestServerConfig(t *testing.T) {
+	s := WithConfig(engine.Config{
+		ReadHeaderTimeout: time.Second,
+		IdleTimeout:       2 * time.Second,
+		MaxHeaderBytes:    1 << 10,
+		DisableKeepAlives: true,
+	})
+	assert.Equal(t, time.Second, s.ReadHeaderTimeout)
+	assert.Equal(t, 2*time.Second, s.IdleTimeout)
+	assert.Equal(t, 1<<10, s.MaxHeaderBytes)
+
+	lee := leego.New()
+	lee.GET("/", func(c leego.Context) leego.LeeError {
+		return c.NoContent(http.StatusNoContent)
+	})
+	s.SetHandler(lee)
+	ts := httptest.NewUnstartedServer(nil)
+	ts.Config = s.Server
+	ts.Start()
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL)
+	if !assert.NoError(t, err) {
+		return
+	}
+	res.Body.Close()
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	assert.True(t, res.Close)
+
+	req, _ := http.NewRequest(leego.GET, ts.URL, nil)
+	req.Header.Set("X-Large", strings.Repeat("a", 8<<10))
+	res, err = http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	res.Body.Close()
+	assert.Equal(t, http.StatusRequestHeaderFieldsTooLarge, res.StatusCode)
+}
@@ -0,0 +1,108 @@
+package standard
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"time"
+
+	"github.com/go-wyvern/leego/engine"
+)
+
+type (
+	// ticketKeys rotates the session ticket keys of the TLS config used for
+	// handshakes.
+	ticketKeys struct {
+		config   *tls.Config
+		provider engine.SecretProvider
+		interval time.Duration
+		keys     [][32]byte
+		quit     chan struct{}
+		logf     func(string, ...interface{})
+	}
+)
+
+// maxTicketKeys is the number of keys kept when rotating locally: the current
+// one plus the two previous ones.
+const maxTicketKeys = 3
+
+var errNoTicketKeys = errors.New("secret provider returned no session ticket keys")
+
+// setupTicketKeys installs session ticket key rotation. `http.Server` clones the
+// TLS config when it starts, so handshakes are served from a dedicated config
+// returned by `tls.Config#GetConfigForClient` whose keys can be updated.
+func (s *Server) setupTicketKeys() error {
+	c := s.config
+	if c.TLSTicketKeyRotation <= 0 {
+		return nil
+	}
+	cfg := s.TLSConfig.Clone()
+	if len(cfg.NextProtos) == 0 {
+		cfg.NextProtos = []string{"h2", "http/1.1"}
+		if c.DisableHTTP2 {
+			cfg.NextProtos = []string{"http/1.1"}
+		}
+	}
+	t := &ticketKeys{
+		config:   cfg,
+		provider: c.SecretProvider,
+		interval: c.TLSTicketKeyRotation,
+		quit:     make(chan struct{}),
+		logf:     s.logf,
+	}
+	if err := t.rotate(); err != nil {
+		return err
+	}
+	s.TLSConfig.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		return cfg, nil
+	}
+	s.tickets = t
+	go t.run()
+	return nil
+}
+
+func (t *ticketKeys) run() {
+	tk := time.NewTicker(t.interval)
+	defer tk.Stop()
+	for {
+		select {
+		case <-t.quit:
+			return
+		case <-tk.C:
+			if err := t.rotate(); err != nil {
+				t.logf("leego: rotating session ticket keys: %v", err)
+			}
+		}
+	}
+}
+
+// rotate fetches the keys from the secret provider or generates a new key,
+// keeping the previous ones, and installs them.
+func (t *ticketKeys) rotate() error {
+	var keys [][32]byte
+	if t.provider != nil {
+		var err error
+		if keys, err = t.provider.SessionTicketKeys(); err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			return errNoTicketKeys
+		}
+	} else {
+		var key [32]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		keys = append([][32]byte{key}, t.keys...)
+		if len(keys) > maxTicketKeys {
+			keys = keys[:maxTicketKeys]
+		}
+	}
+	t.keys = keys
+	t.config.SetSessionTicketKeys(keys)
+	return nil
+}
+
+func (t *ticketKeys) stop() {
+	close(t.quit)
+}
@@ -0,0 +1,225 @@
+package standard
+
+import (
+	"crypto/tls"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-wyvern/leego/engine"
+)
+
+type (
+	// certManager loads the configured certificates, selects them by SNI server
+	// name and keeps them fresh: reloading changed files and refreshing OCSP
+	// staples.
+	certManager struct {
+		config   engine.Config
+		interval time.Duration
+		mu       sync.RWMutex
+		certs    map[string]*certificate // By server name, "" is the default.
+		quit     chan struct{}
+		logf     func(string, ...interface{})
+	}
+
+	certificate struct {
+		files    engine.TLSCert
+		modTime  time.Time
+		cert     *tls.Certificate
+		ocspNext time.Time
+	}
+)
+
+const defaultTLSRefreshInterval = time.Minute
+
+var errNoCertificate = errors.New("no certificate for server name")
+
+// isTLS returns true if the server is configured to serve TLS.
+func (s *Server) isTLS() bool {
+	c := s.config
+	return c.TLSCertFile != "" && c.TLSKeyFile != "" ||
+		len(c.TLSCerts) > 0 || c.GetCertificate != nil
+}
+
+// setupTLS installs the certificate manager if certificate selection, reload
+// or OCSP stapling is configured. It returns the certificate and key files to
+// pass to `http.Server#ListenAndServeTLS()`.
+func (s *Server) setupTLS() (certFile, keyFile string, err error) {
+	c := s.config
+	if len(c.TLSCerts) == 0 && c.GetCertificate == nil && !c.TLSReload && !c.OCSPStapling &&
+		c.TLSTicketKeyRotation <= 0 {
+		return c.TLSCertFile, c.TLSKeyFile, nil
+	}
+	m := &certManager{
+		config:   c,
+		interval: c.TLSRefreshInterval,
+		certs:    make(map[string]*certificate),
+		quit:     make(chan struct{}),
+		logf:     s.logf,
+	}
+	if m.interval <= 0 {
+		m.interval = defaultTLSRefreshInterval
+	}
+	if err = m.load(); err != nil {
+		return
+	}
+	if s.TLSConfig == nil {
+		s.TLSConfig = new(tls.Config)
+	}
+	s.TLSConfig.GetCertificate = m.getCertificate
+	s.certs = m
+	if c.TLSReload || c.OCSPStapling {
+		go m.watch()
+	}
+	return "", "", nil
+}
+
+// logf logs like `net/http` does, using the server's error logger if set.
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+func (m *certManager) load() error {
+	c := m.config
+	files := make(map[string]engine.TLSCert, len(c.TLSCerts)+1)
+	if c.TLSCertFile != "" && c.TLSKeyFile != "" {
+		files[""] = engine.TLSCert{CertFile: c.TLSCertFile, KeyFile: c.TLSKeyFile}
+	}
+	for name, f := range c.TLSCerts {
+		files[strings.ToLower(name)] = f
+	}
+	for name, f := range files {
+		cert, err := loadCertificate(f)
+		if err != nil {
+			return err
+		}
+		if c.OCSPStapling {
+			m.staple(cert)
+		}
+		m.certs[name] = cert
+	}
+	return nil
+}
+
+func loadCertificate(f engine.TLSCert) (*certificate, error) {
+	fi, err := os.Stat(f.CertFile)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.LoadX509KeyPair(f.CertFile, f.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &certificate{files: f, modTime: fi.ModTime(), cert: &cert}, nil
+}
+
+func (m *certManager) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if m.config.GetCertificate != nil {
+		if cert, err := m.config.GetCertificate(hello); cert != nil || err != nil {
+			return cert, err
+		}
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if c := selectCertificate(m.certs, hello.ServerName); c != nil {
+		return c.cert, nil
+	}
+	if c, ok := m.certs[""]; ok {
+		return c.cert, nil
+	}
+	return nil, errNoCertificate
+}
+
+// selectCertificate returns the certificate for the server name, trying an
+// exact match first and a wildcard match next. It returns nil if none matches.
+func selectCertificate(certs map[string]*certificate, name string) *certificate {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	if name == "" {
+		return nil
+	}
+	if cert, ok := certs[name]; ok {
+		return cert
+	}
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		if cert, ok := certs["*"+name[i:]]; ok {
+			return cert
+		}
+	}
+	return nil
+}
+
+func (m *certManager) watch() {
+	t := time.NewTicker(m.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-m.quit:
+			return
+		case <-t.C:
+			m.refresh()
+		}
+	}
+}
+
+// refresh reloads the certificates whose files changed and renews the OCSP
+// staples which are due. The previous certificate is kept on failure.
+func (m *certManager) refresh() {
+	m.mu.RLock()
+	certs := make(map[string]*certificate, len(m.certs))
+	for name, c := range m.certs {
+		certs[name] = c
+	}
+	m.mu.RUnlock()
+
+	for name, c := range certs {
+		next := c
+		if m.config.TLSReload {
+			if fi, err := os.Stat(c.files.CertFile); err == nil && !fi.ModTime().Equal(c.modTime) {
+				nc, err := loadCertificate(c.files)
+				if err != nil {
+					m.logf("leego: reloading certificate %s: %v", c.files.CertFile, err)
+				} else {
+					next = nc
+				}
+			}
+		}
+		if m.config.OCSPStapling && (next != c || !time.Now().Before(c.ocspNext)) {
+			if next == c {
+				// Don't mutate a certificate in use by handshakes.
+				cc := *c
+				tc := *c.cert
+				cc.cert = &tc
+				next = &cc
+			}
+			m.staple(next)
+		}
+		if next != c {
+			m.mu.Lock()
+			m.certs[name] = next
+			m.mu.Unlock()
+		}
+	}
+}
+
+// staple fetches an OCSP response for c and staples it. On failure the
+// previous staple is kept and the fetch is retried on the next refresh.
+func (m *certManager) staple(c *certificate) {
+	staple, next, err := fetchOCSP(c.cert)
+	if err != nil {
+		m.logf("leego: fetching OCSP response for %s: %v", c.files.CertFile, err)
+		return
+	}
+	c.cert.OCSPStaple = staple
+	c.ocspNext = next
+}
+
+func (m *certManager) stop() {
+	close(m.quit)
+}
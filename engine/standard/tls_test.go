@@ -0,0 +1,130 @@
+package standard
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-wyvern/leego/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectCertificate(t *testing.T) {
+	exact := new(certificate)
+	wildcard := new(certificate)
+	certs := map[string]*certificate{
+		"api.example.com": exact,
+		"*.example.com":   wildcard,
+	}
+	assert.True(t, exact == selectCertificate(certs, "API.example.com"))
+	assert.True(t, wildcard == selectCertificate(certs, "www.example.com."))
+	assert.Nil(t, selectCertificate(certs, "example.com"))
+	assert.Nil(t, selectCertificate(certs, ""))
+}
+
+func writeCert(t *testing.T, dir string, serial int64) engine.TLSCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+	kb, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	f := engine.TLSCert{
+		CertFile: filepath.Join(dir, "cert.pem"),
+		KeyFile:  filepath.Join(dir, "key.pem"),
+	}
+	assert.NoError(t, ioutil.WriteFile(f.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(t, ioutil.WriteFile(f.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb}), 0600))
+	return f
+}
+
+func serialOf(t *testing.T, c *tls.Certificate) int64 {
+	leaf, err := x509.ParseCertificate(c.Certificate[0])
+	assert.NoError(t, err)
+	return leaf.SerialNumber.Int64()
+}
+
+func TestCertificateReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "leego")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	f := writeCert(t, dir, 1)
+	s := WithConfig(engine.Config{TLSCertFile: f.CertFile, TLSKeyFile: f.KeyFile, TLSReload: true, TLSRefreshInterval: time.Hour})
+	certFile, keyFile, err := s.setupTLS()
+	assert.NoError(t, err)
+	defer s.Stop()
+	assert.Equal(t, "", certFile+keyFile)
+
+	c, err := s.TLSConfig.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), serialOf(t, c))
+
+	writeCert(t, dir, 2)
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(f.CertFile, later, later))
+	s.certs.refresh()
+	c, err = s.TLSConfig.GetCertificate(&tls.ClientHelloInfo{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), serialOf(t, c))
+}
+
+func TestCheckOCSPResponse(t *testing.T) {
+	this := time.Now().UTC().Truncate(time.Second)
+	basic, err := asn1.Marshal(ocspBasicResponse{
+		TBSResponseData: ocspResponseData{
+			RawResponderID: asn1.RawValue{Class: 2, Tag: 2, IsCompound: true, Bytes: []byte{4, 0}},
+			ProducedAt:     this,
+			Responses: []ocspSingleResponse{{
+				CertID: ocspCertID{
+					HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA1},
+					NameHash:      []byte{1},
+					IssuerKeyHash: []byte{2},
+					SerialNumber:  big.NewInt(7),
+				},
+				Good:       true,
+				ThisUpdate: this,
+				NextUpdate: this.Add(4 * time.Hour),
+			}},
+		},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA1},
+	})
+	assert.NoError(t, err)
+	res, err := asn1.Marshal(ocspResponse{
+		Response: ocspResponseBytes{ResponseType: oidOCSPBasicResponse, Response: basic},
+	})
+	assert.NoError(t, err)
+
+	next, err := checkOCSPResponse(res, big.NewInt(7))
+	assert.NoError(t, err)
+	assert.True(t, next.Equal(this.Add(2*time.Hour)), next)
+
+	_, err = checkOCSPResponse(res, big.NewInt(8))
+	assert.Error(t, err)
+}
+
+func TestTicketKeysRotate(t *testing.T) {
+	tk := &ticketKeys{config: new(tls.Config)}
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, tk.rotate())
+	}
+	assert.Equal(t, maxTicketKeys, len(tk.keys))
+	assert.NotEqual(t, tk.keys[0], tk.keys[1])
+}
@@ -0,0 +1,42 @@
+package leego
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// ETag returns a strong entity tag of i, the hash of its JSON encoding. It's
+// stable as struct fields are encoded in order and map keys sorted.
+func ETag(i interface{}) (string, error) {
+	buf := acquireBuffer()
+	defer releaseBuffer(buf)
+	if err := encodeJSON(buf, i); err != nil {
+		return "", err
+	}
+	return etagOf(buf.Bytes()), nil
+}
+
+func etagOf(b []byte) string {
+	sum := sha256.Sum256(b)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// MatchETag reports whether the If-None-Match header matches etag, with the
+// weak comparison of RFC 7232.
+func MatchETag(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,242 @@
+package leego
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+type (
+	// EventBus is an in-process publish/subscribe bus for domain events, so
+	// modules can react to events without depending on each other. Events are
+	// delivered asynchronously and in no particular order.
+	EventBus struct {
+		mu           sync.RWMutex
+		subscribers  map[string][]*subscriber
+		inflight     sync.WaitGroup
+		closed       bool
+		panicHandler func(Event, interface{})
+		outbox       Outbox
+		outboxSink   OutboxSink
+		relayStop    chan struct{}
+		relayDone    chan struct{}
+	}
+
+	// Event is a published event.
+	Event struct {
+		Topic   string
+		Payload interface{}
+	}
+
+	// EventHandler handles the events of a topic.
+	EventHandler func(Event)
+
+	subscriber struct {
+		handler EventHandler
+	}
+
+	// Outbox persists events published in a transaction, so they're relayed
+	// only once it commits. See `EventBus#SetOutbox()`.
+	Outbox interface {
+		// Store saves ev in the transaction carried by ctx, if any.
+		Store(ctx context.Context, ev Event) error
+
+		// Pending returns up to n committed events not yet relayed, oldest
+		// first.
+		Pending(n int) ([]OutboxRecord, error)
+
+		// Ack marks the event with id as relayed.
+		Ack(id string) error
+	}
+
+	// OutboxRecord is an event stored in an outbox.
+	OutboxRecord struct {
+		ID    string
+		Event Event
+	}
+
+	// OutboxSink relays an event from the outbox, e.g. to a message broker.
+	OutboxSink func(Event) error
+)
+
+// outboxBatchSize is the number of events relayed per `Outbox#Pending()` call.
+const outboxBatchSize = 100
+
+// Errors
+var (
+	ErrEventBusClosed = errors.New("event bus closed")
+	ErrNoOutbox       = errors.New("event bus has no outbox")
+)
+
+// NewEventBus returns an event bus.
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[string][]*subscriber)}
+}
+
+// Events returns the event bus of the instance.
+func (e *Leego) Events() *EventBus {
+	return e.events
+}
+
+// Subscribe registers h for the events of topic. The returned function
+// unsubscribes it.
+func (b *EventBus) Subscribe(topic string, h EventHandler) (unsubscribe func()) {
+	s := &subscriber{handler: h}
+	b.mu.Lock()
+	b.subscribers[topic] = append(b.subscribers[topic], s)
+	b.mu.Unlock()
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		subs := b.subscribers[topic]
+		for i, sub := range subs {
+			if sub == s {
+				b.subscribers[topic] = append(subs[:i:i], subs[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Publish delivers an event to the subscribers of topic in the background.
+func (b *EventBus) Publish(topic string, payload interface{}) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return ErrEventBusClosed
+	}
+	ev := Event{Topic: topic, Payload: payload}
+	for _, s := range b.subscribers[topic] {
+		b.inflight.Add(1)
+		go b.deliver(s, ev)
+	}
+	return nil
+}
+
+// PublishTx stores an event in the outbox, in the transaction carried by ctx.
+// It's relayed after the transaction commits, at least once.
+func (b *EventBus) PublishTx(ctx context.Context, topic string, payload interface{}) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return ErrEventBusClosed
+	}
+	if b.outbox == nil {
+		return ErrNoOutbox
+	}
+	return b.outbox.Store(ctx, Event{Topic: topic, Payload: payload})
+}
+
+// SetOutbox sets the outbox of `EventBus#PublishTx()` and starts relaying its
+// events to sink every interval. A nil sink publishes the events on the bus.
+// An event is acknowledged once sink returns no error, otherwise it's retried.
+func (b *EventBus) SetOutbox(o Outbox, sink OutboxSink, interval time.Duration) {
+	b.stopRelay()
+	if sink == nil {
+		sink = func(ev Event) error {
+			return b.Publish(ev.Topic, ev.Payload)
+		}
+	}
+	b.mu.Lock()
+	b.outbox, b.outboxSink = o, sink
+	b.relayStop, b.relayDone = make(chan struct{}), make(chan struct{})
+	go b.relay(interval, b.relayStop, b.relayDone)
+	b.mu.Unlock()
+}
+
+// RelayOutbox relays the pending events of the outbox.
+func (b *EventBus) RelayOutbox() error {
+	b.mu.RLock()
+	o, sink := b.outbox, b.outboxSink
+	b.mu.RUnlock()
+	if o == nil {
+		return ErrNoOutbox
+	}
+	for {
+		records, err := o.Pending(outboxBatchSize)
+		if err != nil || len(records) == 0 {
+			return err
+		}
+		for _, r := range records {
+			if err = sink(r.Event); err != nil {
+				return err
+			}
+			if err = o.Ack(r.ID); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (b *EventBus) relay(interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			b.RelayOutbox()
+		case <-stop:
+			b.RelayOutbox()
+			return
+		}
+	}
+}
+
+// stopRelay stops relaying the outbox, after a last relay.
+func (b *EventBus) stopRelay() {
+	b.mu.Lock()
+	stop, done := b.relayStop, b.relayDone
+	b.relayStop, b.relayDone = nil, nil
+	b.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// SetPanicHandler sets the function called when a subscriber panics. By
+// default the panic is dropped, so a subscriber can't crash the server.
+func (b *EventBus) SetPanicHandler(h func(ev Event, err interface{})) {
+	b.mu.Lock()
+	b.panicHandler = h
+	b.mu.Unlock()
+}
+
+func (b *EventBus) deliver(s *subscriber, ev Event) {
+	defer b.inflight.Done()
+	defer func() {
+		if r := recover(); r != nil {
+			b.mu.RLock()
+			h := b.panicHandler
+			b.mu.RUnlock()
+			if h != nil {
+				h(ev, r)
+			}
+		}
+	}()
+	s.handler(ev)
+}
+
+// Close stops accepting events, relays the outbox a last time and waits for the
+// delivery of the published events, or until ctx is done.
+func (b *EventBus) Close(ctx context.Context) error {
+	b.stopRelay()
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,141 @@
+package leego
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestEventBus(t *testing.T) {
+	b := New().Events()
+	var (
+		mu       sync.Mutex
+		received []interface{}
+		panics   []interface{}
+	)
+	b.Subscribe("user.created", func(ev Event) {
+		mu.Lock()
+		received = append(received, ev.Payload)
+		mu.Unlock()
+	})
+	b.Subscribe("user.created", func(ev Event) {
+		panic("subscriber")
+	})
+	unsubscribe := b.Subscribe("user.created", func(ev Event) {
+		t.Error("unsubscribed handler called")
+	})
+	unsubscribe()
+	b.SetPanicHandler(func(ev Event, err interface{}) {
+		mu.Lock()
+		panics = append(panics, err)
+		mu.Unlock()
+	})
+	release := make(chan struct{})
+	b.Subscribe("slow", func(ev Event) {
+		<-release
+	})
+
+	assert.NoError(t, b.Publish("user.created", 1))
+	assert.NoError(t, b.Publish("other", 2))
+	assert.NoError(t, b.Publish("slow", nil))
+
+	// Close times out while the slow subscriber runs.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, b.Close(ctx))
+	assert.Equal(t, ErrEventBusClosed, b.Publish("user.created", 3))
+
+	close(release)
+	assert.NoError(t, b.Close(context.Background()))
+	assert.Equal(t, []interface{}{1}, received)
+	assert.Equal(t, []interface{}{"subscriber"}, panics)
+}
+
+// txOutbox stores events in the transaction carried by the context, which are
+// pending once committed.
+type txOutbox struct {
+	mu      sync.Mutex
+	records []OutboxRecord
+	acked   map[string]bool
+}
+
+type txKey struct{}
+
+type tx struct {
+	events []Event
+}
+
+func (o *txOutbox) Store(ctx context.Context, ev Event) error {
+	t := ctx.Value(txKey{}).(*tx)
+	t.events = append(t.events, ev)
+	return nil
+}
+
+func (o *txOutbox) commit(t *tx) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, ev := range t.events {
+		o.records = append(o.records, OutboxRecord{ID: string(rune('a' + len(o.records))), Event: ev})
+	}
+}
+
+func (o *txOutbox) Pending(n int) (records []OutboxRecord, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, r := range o.records {
+		if !o.acked[r.ID] && len(records) < n {
+			records = append(records, r)
+		}
+	}
+	return
+}
+
+func (o *txOutbox) Ack(id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.acked[id] = true
+	return nil
+}
+
+func TestEventBusOutbox(t *testing.T) {
+	b := NewEventBus()
+	assert.Equal(t, ErrNoOutbox, b.PublishTx(context.Background(), "order.placed", 1))
+
+	o := &txOutbox{acked: make(map[string]bool)}
+	var (
+		mu    sync.Mutex
+		sent  []interface{}
+		fails = 1
+	)
+	b.SetOutbox(o, func(ev Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if fails > 0 {
+			fails--
+			return errors.New("broker down")
+		}
+		sent = append(sent, ev.Payload)
+		return nil
+	}, time.Hour)
+
+	t1, t2 := new(tx), new(tx)
+	assert.NoError(t, b.PublishTx(context.WithValue(context.Background(), txKey{}, t1), "order.placed", 1))
+	assert.NoError(t, b.PublishTx(context.WithValue(context.Background(), txKey{}, t2), "order.placed", 2))
+	o.commit(t1)
+
+	// Retried after a failure, rolled back transaction never relayed.
+	assert.Error(t, b.RelayOutbox())
+	assert.NoError(t, b.RelayOutbox())
+	assert.Equal(t, []interface{}{1}, sent)
+
+	// Relayed on close
+	t3 := new(tx)
+	b.PublishTx(context.WithValue(context.Background(), txKey{}, t3), "order.placed", 3)
+	o.commit(t3)
+	assert.NoError(t, b.Close(context.Background()))
+	assert.Equal(t, []interface{}{1, 3}, sent)
+}
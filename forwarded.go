@@ -0,0 +1,268 @@
+package leego
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/go-wyvern/leego/engine"
+)
+
+type (
+	// Forwarded holds the client information of a request, reconciled from the
+	// `Forwarded` (RFC 7239) and `X-Forwarded-*` headers set by trusted proxies.
+	Forwarded struct {
+		// For is the client IP.
+		For string
+
+		// Proto is the protocol scheme used by the client, `http` or `https`.
+		Proto string
+
+		// Host is the host requested by the client.
+		Host string
+
+		// By is the interface where the request came in to the outermost trusted
+		// proxy, if reported.
+		By string
+	}
+)
+
+// SetTrustedProxyDepth sets the number of proxies in front of the server whose
+// forwarding headers are trusted by `Context#Forwarded()`. The default 0 ignores
+// the headers and reports the direct peer.
+func (e *Leego) SetTrustedProxyDepth(n int) {
+	if n < 0 {
+		n = 0
+	}
+	e.trustedProxyDepth = n
+}
+
+// SetTrustedProxies sets the IP ranges of the proxies whose forwarding headers
+// are trusted by `Context#Forwarded()` and `Context#RealIP()`, as CIDRs or
+// single IPs, e.g. "10.0.0.0/8". The hops are trusted from the direct peer
+// back to the first one out of the ranges, which is the client. It takes
+// precedence over `Leego#SetTrustedProxyDepth()`.
+func (e *Leego) SetTrustedProxies(cidrs ...string) error {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return fmt.Errorf("leego: invalid trusted proxy %q", cidr)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets[i] = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+			continue
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("leego: invalid trusted proxy %q", cidr)
+		}
+		nets[i] = n
+	}
+	e.trustedProxies = nets
+	return nil
+}
+
+// parseForwarded returns the forwarding information of the request, trusting
+// the hops from the trusted ranges if any, else the last `depth` hops. The
+// `Forwarded` header takes precedence over the `X-Forwarded-*` headers, then
+// `X-Real-IP`.
+func parseForwarded(req engine.Request, depth int, trusted []*net.IPNet) (f Forwarded) {
+	f.For = hostIP(req.RemoteAddress())
+	f.Proto = req.Scheme()
+	f.Host = req.Host()
+	if trusted != nil {
+		depth = 0
+		if isTrustedProxy(trusted, f.For) {
+			depth = 1
+		}
+	}
+	if depth == 0 {
+		return
+	}
+
+	h := req.Header()
+	var elems []Forwarded
+	if v := h.Get(HeaderForwarded); v != "" {
+		elems = parseForwardedHeader(v)
+	} else if v := h.Get(HeaderXForwardedFor); v != "" {
+		elems = parseXForwarded(v, h.Get(HeaderXForwardedProto), h.Get(HeaderXForwardedHost))
+	}
+	if len(elems) == 0 {
+		if ip := h.Get(HeaderXRealIP); ip != "" {
+			f.For = nodeIP(ip)
+		}
+		return
+	}
+	if trusted != nil {
+		// Each element is the peer of the next hop, so the chain is trusted
+		// while the peers are in the ranges.
+		for depth < len(elems) && isTrustedProxy(trusted, elems[len(elems)-depth].For) {
+			depth++
+		}
+	}
+
+	// Each proxy appends one element describing the hop it received, so the
+	// outermost trusted proxy wrote element `len - depth`.
+	i := len(elems) - depth
+	if i < 0 {
+		i = 0
+	}
+	e := elems[i]
+	if e.For != "" {
+		f.For = e.For
+	}
+	if e.Proto != "" {
+		f.Proto = strings.ToLower(e.Proto)
+	}
+	if e.Host != "" {
+		f.Host = e.Host
+	}
+	f.By = e.By
+	return
+}
+
+// parseForwardedHeader parses a `Forwarded` header value, e.g.
+// `for=192.0.2.60;proto=http;by=203.0.113.43, for="[2001:db8::1]:4711"`.
+func parseForwardedHeader(v string) (elems []Forwarded) {
+	for _, elem := range splitQuoted(v, ',') {
+		var f Forwarded
+		for _, pair := range splitQuoted(elem, ';') {
+			i := strings.IndexByte(pair, '=')
+			if i < 0 {
+				continue
+			}
+			key := strings.ToLower(strings.TrimSpace(pair[:i]))
+			val := unquote(strings.TrimSpace(pair[i+1:]))
+			switch key {
+			case "for":
+				f.For = nodeIP(val)
+			case "by":
+				f.By = nodeIP(val)
+			case "proto":
+				f.Proto = val
+			case "host":
+				f.Host = val
+			}
+		}
+		elems = append(elems, f)
+	}
+	return
+}
+
+// parseXForwarded builds elements from `X-Forwarded-For` and the matching
+// `X-Forwarded-Proto` and `X-Forwarded-Host` values. When the proto or host
+// lists don't line up with the addresses, their last value applies to every
+// element.
+func parseXForwarded(xff, proto, host string) (elems []Forwarded) {
+	fors := splitList(xff)
+	protos := splitList(proto)
+	hosts := splitList(host)
+	for i, ip := range fors {
+		elems = append(elems, Forwarded{
+			For:   nodeIP(ip),
+			Proto: alignedValue(protos, i, len(fors)),
+			Host:  alignedValue(hosts, i, len(fors)),
+		})
+	}
+	return
+}
+
+func alignedValue(values []string, i, n int) string {
+	if len(values) == n {
+		return values[i]
+	}
+	if len(values) > 0 {
+		return values[len(values)-1]
+	}
+	return ""
+}
+
+func splitList(v string) (l []string) {
+	if v == "" {
+		return nil
+	}
+	for _, s := range strings.Split(v, ",") {
+		l = append(l, strings.TrimSpace(s))
+	}
+	return
+}
+
+// splitQuoted splits s at sep, ignoring separators inside quoted strings.
+func splitQuoted(s string, sep byte) (parts []string) {
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case '\\':
+			if quoted {
+				i++
+			}
+		case sep:
+			if !quoted {
+				parts = append(parts, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	s = s[1 : len(s)-1]
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b = append(b, s[i])
+	}
+	return string(b)
+}
+
+// nodeIP strips the port and IPv6 brackets from a node, e.g.
+// `[2001:db8::1]:4711`. Obfuscated identifiers and `unknown` are kept as is.
+func nodeIP(node string) string {
+	if strings.HasPrefix(node, "[") {
+		if i := strings.IndexByte(node, ']'); i > 0 {
+			return node[1:i]
+		}
+		return node
+	}
+	if strings.Count(node, ":") == 1 {
+		return node[:strings.IndexByte(node, ':')]
+	}
+	return node
+}
+
+func isTrustedProxy(trusted []*net.IPNet, ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func hostIP(addr string) string {
+	if ip, _, err := net.SplitHostPort(addr); err == nil {
+		return ip
+	}
+	return addr
+}
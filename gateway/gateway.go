@@ -0,0 +1,434 @@
+// Package gateway runs leego as an API gateway, routing the requests to
+// upstream clusters by a route table loaded from config:
+//
+//	{
+//		"clusters": {
+//			"users": {
+//				"targets": ["http://10.0.0.1:8080", "http://10.0.0.2:8080"],
+//				"transport": {"max_idle_conns_per_host": 32, "dial_timeout": 1000000000},
+//				"retries": 1
+//			}
+//		},
+//		"routes": [
+//			{
+//				"method": "GET", "path": "/api/users/*", "cluster": "users",
+//				"rewrite": {"/api/*": "/$1"},
+//				"auth": "jwt",
+//				"rate_limit": {"rate": 10, "burst": 20},
+//				"request_headers": {"X-Gateway": "leego"}
+//			}
+//		]
+//	}
+//
+// The routes are added as a route manifest, see `leego.Leego#LoadManifest()`,
+// so they're exported with `leego.Leego#Manifest()` like the other routes,
+// with their cluster as metadata. Auth and other middleware are named in
+// config and resolved by a registry:
+//
+//	gw, err := gateway.Load(lee, config, map[string]leego.MiddlewareFunc{
+//		"jwt": middleware.JWT(key),
+//	})
+//
+// The counters of the targets are served by `Gateway#Handler()`:
+//
+//	lee.GET("/admin/upstreams", gw.Handler())
+package gateway
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine"
+	"github.com/go-wyvern/leego/middleware"
+)
+
+type (
+	// Config defines the upstream clusters and the routes of the gateway.
+	Config struct {
+		Clusters map[string]Cluster `json:"clusters"`
+		Routes   []Route            `json:"routes"`
+	}
+
+	// Cluster is a set of upstream targets serving the same API.
+	Cluster struct {
+		// Targets are the base URLs of the targets, e.g.
+		// "http://10.0.0.1:8080".
+		// Required.
+		Targets []string `json:"targets"`
+
+		// Balance chooses the target of each request, "round_robin",
+		// "random", or sticky: "cookie", pinning the clients with a cookie,
+		// or "hash", hashing the client IP or HashHeader. See
+		// `middleware.NewCookieBalancer()` and `middleware.NewHashBalancer()`.
+		// Optional. Default value "round_robin".
+		Balance string `json:"balance"`
+
+		// Cookie is the cookie of the "cookie" balance.
+		// Optional. Default value "lb".
+		Cookie string `json:"cookie"`
+
+		// HashHeader is the request header hashed by the "hash" balance.
+		// Optional. Default value the client IP.
+		HashHeader string `json:"hash_header"`
+
+		// Transport defines the connection pool to the targets, see
+		// `middleware.ProxyTransportConfig`. Its TLS config is set from TLS.
+		// Optional.
+		Transport middleware.ProxyTransportConfig `json:"transport"`
+
+		// TLS defines the TLS connections to HTTPS targets.
+		// Optional.
+		TLS *TLS `json:"tls"`
+
+		// Retries and RetryBudget are the retries on the next target, see
+		// `middleware.ProxyConfig#Retries`.
+		// Optional.
+		Retries     int     `json:"retries"`
+		RetryBudget float64 `json:"retry_budget"`
+
+		// HedgeDelay is the time after which GET requests without response
+		// are also sent to the next target, see
+		// `middleware.ProxyConfig#HedgeDelay`.
+		// Optional.
+		HedgeDelay time.Duration `json:"hedge_delay"`
+	}
+
+	// TLS defines the TLS connections to the targets of a cluster.
+	TLS struct {
+		// CAFile is the PEM file of the CAs of the targets.
+		// Optional. Default value the CAs of the system.
+		CAFile string `json:"ca_file"`
+
+		// CertFile and KeyFile are the PEM files of the client certificate.
+		// Optional.
+		CertFile string `json:"cert_file"`
+		KeyFile  string `json:"key_file"`
+
+		// ServerName is the name the certificates of the targets are checked
+		// against.
+		// Optional. Default value the host of the target.
+		ServerName string `json:"server_name"`
+
+		// InsecureSkipVerify doesn't check the certificates of the targets.
+		// Optional. Default value false.
+		InsecureSkipVerify bool `json:"insecure_skip_verify"`
+	}
+
+	// Gateway is a loaded config, see `Load()`.
+	Gateway struct {
+		targets map[string][]*middleware.ProxyTarget
+	}
+
+	// Route maps the requests of a method and path to a cluster.
+	Route struct {
+		// Name is the name of the route, see `leego.Route#Name`.
+		// Optional.
+		Name string `json:"name"`
+
+		// Method is the HTTP method, e.g. "GET".
+		// Required.
+		Method string `json:"method"`
+
+		// Path is the route path, e.g. "/api/users/*".
+		// Required.
+		Path string `json:"path"`
+
+		// Cluster is the name of the cluster serving the route.
+		// Required.
+		Cluster string `json:"cluster"`
+
+		// Rewrite rewrites the path before forwarding, see
+		// `middleware.RewriteConfig#Rules`.
+		// Optional.
+		Rewrite map[string]string `json:"rewrite"`
+
+		// Auth is the name of the middleware authenticating the requests, run
+		// first.
+		// Optional.
+		Auth string `json:"auth"`
+
+		// RateLimit limits the requests of each client IP to the route.
+		// Optional.
+		RateLimit *RateLimit `json:"rate_limit"`
+
+		// RequestHeaders are set on the forwarded requests, an empty value
+		// removing the header.
+		// Optional.
+		RequestHeaders map[string]string `json:"request_headers"`
+
+		// ResponseHeaders are set on the responses of the cluster, an empty
+		// value removing the header.
+		// Optional.
+		ResponseHeaders map[string]string `json:"response_headers"`
+
+		// Buffering defines how the bodies of the route are forwarded, e.g.
+		// streamed for server-sent events, see `middleware.ProxyBuffering`.
+		// Optional.
+		Buffering *middleware.ProxyBuffering `json:"buffering"`
+
+		// Middleware are the names of more middleware, e.g. transforming the
+		// body, run in order before forwarding.
+		// Optional.
+		Middleware []string `json:"middleware"`
+	}
+
+	// RateLimit is the rate limit of a route, see
+	// `middleware.RateLimiterMemoryStoreConfig`.
+	RateLimit struct {
+		Rate  float64 `json:"rate"`
+		Burst int     `json:"burst"`
+	}
+
+	// headerResponse sets headers on the response before it's committed.
+	headerResponse struct {
+		engine.Response
+		headers map[string]string
+		done    bool
+	}
+)
+
+// MetaCluster is the route metadata key of the cluster serving a gateway
+// route.
+const MetaCluster = "gateway.cluster"
+
+// LoadFile reads the config from a JSON file.
+func LoadFile(filename string) (Config, error) {
+	var config Config
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return config, err
+	}
+	if err = json.Unmarshal(b, &config); err != nil {
+		return config, fmt.Errorf("%s: %v", filename, err)
+	}
+	return config, nil
+}
+
+// Load adds the routes of config to lee, resolving the auth and middleware
+// names with registry. No route is added if the config is invalid.
+func Load(lee *leego.Leego, config Config, registry map[string]leego.MiddlewareFunc) (*Gateway, error) {
+	g := &Gateway{targets: make(map[string][]*middleware.ProxyTarget, len(config.Clusters))}
+	r := leego.RouteRegistry{
+		Handlers:   make(map[string]leego.HandlerFunc),
+		Middleware: make(map[string]leego.MiddlewareFunc, len(registry)),
+	}
+	for name, m := range registry {
+		r.Middleware[name] = m
+	}
+	for name, cluster := range config.Clusters {
+		proxy, targets, err := newProxy(cluster)
+		if err != nil {
+			return nil, fmt.Errorf("gateway: cluster %q: %v", name, err)
+		}
+		g.targets[name] = targets
+		r.Handlers[handlerName(name)] = proxy(leego.NotFoundHandler)
+	}
+
+	m := leego.RouteManifest{Version: leego.ManifestVersion}
+	for _, route := range config.Routes {
+		if _, ok := config.Clusters[route.Cluster]; !ok {
+			return nil, fmt.Errorf("gateway: route %s %s: unknown cluster %q", route.Method, route.Path, route.Cluster)
+		}
+		key := route.Method + " " + route.Path
+		var names []string
+		add := func(name string, m leego.MiddlewareFunc) {
+			r.Middleware[name] = m
+			names = append(names, name)
+		}
+		if route.Auth != "" {
+			names = append(names, route.Auth)
+		}
+		if rl := route.RateLimit; rl != nil {
+			add("gateway.rate_limit:"+key, middleware.RateLimiter(middleware.NewRateLimiterMemoryStoreWithConfig(middleware.RateLimiterMemoryStoreConfig{
+				Rate:  rl.Rate,
+				Burst: rl.Burst,
+			})))
+		}
+		if len(route.Rewrite) > 0 {
+			add("gateway.rewrite:"+key, middleware.Rewrite(route.Rewrite))
+		}
+		if len(route.RequestHeaders) > 0 {
+			add("gateway.request_headers:"+key, requestHeaders(route.RequestHeaders))
+		}
+		if len(route.ResponseHeaders) > 0 {
+			add("gateway.response_headers:"+key, responseHeaders(route.ResponseHeaders))
+		}
+		if route.Buffering != nil {
+			add("gateway.buffering:"+key, middleware.WithProxyBuffering(*route.Buffering))
+		}
+		names = append(names, route.Middleware...)
+		m.Routes = append(m.Routes, leego.RouteInfo{
+			Route: leego.Route{
+				Method:  route.Method,
+				Path:    route.Path,
+				Name:    route.Name,
+				Handler: handlerName(route.Cluster),
+			},
+			Middleware: names,
+			Meta:       map[string]string{MetaCluster: route.Cluster},
+		})
+	}
+	if err := lee.LoadManifest(m, r); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Stats returns the counters of the targets by cluster, see
+// `middleware.ProxyTarget#Stats()`.
+func (g *Gateway) Stats() map[string][]middleware.ProxyTargetStats {
+	stats := make(map[string][]middleware.ProxyTargetStats, len(g.targets))
+	for name, targets := range g.targets {
+		s := make([]middleware.ProxyTargetStats, len(targets))
+		for i, t := range targets {
+			s[i] = t.Stats()
+		}
+		stats[name] = s
+	}
+	return stats
+}
+
+// Handler returns a handler sending `Gateway#Stats()` as JSON.
+func (g *Gateway) Handler() leego.HandlerFunc {
+	return func(c leego.Context) leego.LeeError {
+		return c.JSON(http.StatusOK, g.Stats())
+	}
+}
+
+func handlerName(cluster string) string {
+	return "gateway.proxy:" + cluster
+}
+
+// newProxy returns the proxy to the targets of cluster, with its own
+// connection pool.
+func newProxy(cluster Cluster) (leego.MiddlewareFunc, []*middleware.ProxyTarget, error) {
+	if len(cluster.Targets) == 0 {
+		return nil, nil, fmt.Errorf("no targets")
+	}
+	targets := make([]*middleware.ProxyTarget, len(cluster.Targets))
+	for i, t := range cluster.Targets {
+		u, err := url.Parse(t)
+		if err != nil {
+			return nil, nil, err
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return nil, nil, fmt.Errorf("target %q is not an absolute URL", t)
+		}
+		targets[i] = &middleware.ProxyTarget{Name: t, URL: u}
+	}
+	var balancer middleware.ProxyBalancer
+	switch cluster.Balance {
+	case "", "round_robin":
+		balancer = middleware.NewRoundRobinBalancer(targets...)
+	case "random":
+		balancer = middleware.NewRandomBalancer(targets...)
+	case "cookie":
+		name := cluster.Cookie
+		if name == "" {
+			name = "lb"
+		}
+		balancer = middleware.NewCookieBalancer(name, targets...)
+	case "hash":
+		key := middleware.ProxyHashKeyIP
+		if cluster.HashHeader != "" {
+			key = middleware.ProxyHashKeyHeader(cluster.HashHeader)
+		}
+		balancer = middleware.NewHashBalancer(key, targets...)
+	default:
+		return nil, nil, fmt.Errorf("unknown balance %q", cluster.Balance)
+	}
+	transport := cluster.Transport
+	if cluster.TLS != nil {
+		var err error
+		if transport.TLSConfig, err = cluster.TLS.config(); err != nil {
+			return nil, nil, err
+		}
+	}
+	proxy := middleware.ProxyWithConfig(middleware.ProxyConfig{
+		Balancer:    balancer,
+		Transport:   middleware.NewProxyTransport(transport),
+		Retries:     cluster.Retries,
+		RetryBudget: cluster.RetryBudget,
+		HedgeDelay:  cluster.HedgeDelay,
+	})
+	return proxy, targets, nil
+}
+
+func (t *TLS) config() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+	if t.CAFile != "" {
+		b, err := ioutil.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("%s: no certificates", t.CAFile)
+		}
+	}
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+func requestHeaders(headers map[string]string) leego.MiddlewareFunc {
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			setHeaders(c.Request().Header(), headers)
+			return next(c)
+		}
+	}
+}
+
+// responseHeaders sets the headers once the cluster responded, as the proxy
+// adds the headers of the upstream response to the ones already set.
+func responseHeaders(headers map[string]string) leego.MiddlewareFunc {
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			res := c.Response()
+			c.SetResponse(&headerResponse{Response: res, headers: headers})
+			defer c.SetResponse(res)
+			return next(c)
+		}
+	}
+}
+
+func setHeaders(h engine.Header, headers map[string]string) {
+	for k, v := range headers {
+		if v == "" {
+			h.Del(k)
+		} else {
+			h.Set(k, v)
+		}
+	}
+}
+
+func (r *headerResponse) WriteHeader(code int) {
+	if !r.done {
+		r.done = true
+		setHeaders(r.Header(), r.headers)
+	}
+	r.Response.WriteHeader(code)
+}
+
+func (r *headerResponse) Write(b []byte) (int, error) {
+	if !r.Committed() {
+		r.WriteHeader(http.StatusOK)
+	}
+	return r.Response.Write(b)
+}
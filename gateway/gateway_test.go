@@ -0,0 +1,134 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/go-wyvern/leego/gateway"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "upstream")
+		w.Header().Set("X-Internal", "1")
+		fmt.Fprintf(w, "%s %s %s", r.Method, r.URL.Path, r.Header.Get("X-Gateway"))
+	}))
+	defer upstream.Close()
+
+	var config gateway.Config
+	err := json.Unmarshal([]byte(`{
+		"clusters": {"users": {"targets": ["`+upstream.URL+`"]}},
+		"routes": [
+			{
+				"name": "users", "method": "GET", "path": "/api/users/*", "cluster": "users",
+				"rewrite": {"/api/*": "/$1"},
+				"auth": "token",
+				"rate_limit": {"rate": 1, "burst": 2},
+				"request_headers": {"X-Gateway": "leego"},
+				"response_headers": {"Server": "leego", "X-Internal": ""}
+			},
+			{"method": "POST", "path": "/api/users", "cluster": "users"}
+		]
+	}`), &config)
+	if !assert.NoError(t, err) {
+		return
+	}
+	token := func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if c.Request().Header().Get(leego.HeaderAuthorization) != "Bearer token" {
+				return leego.ErrUnauthorized
+			}
+			return next(c)
+		}
+	}
+
+	lee := leego.New()
+	_, err = gateway.Load(lee, config, nil)
+	assert.Error(t, err)
+	assert.Empty(t, lee.Routes())
+	gw, err := gateway.Load(lee, config, map[string]leego.MiddlewareFunc{"token": token})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	request := func(method, auth string) *httptest.ResponseRecorder {
+		path := "/api/users"
+		if method == leego.GET {
+			path += "/1"
+		}
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set(leego.HeaderAuthorization, auth)
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		return rec
+	}
+	rec := request(leego.GET, "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = request(leego.GET, "Bearer token")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "GET /users/1 leego", rec.Body.String())
+	assert.Equal(t, "leego", rec.Header().Get("Server"))
+	assert.Equal(t, "", rec.Header().Get("X-Internal"))
+	assert.Equal(t, http.StatusOK, request(leego.GET, "Bearer token").Code)
+	assert.Equal(t, http.StatusTooManyRequests, request(leego.GET, "Bearer token").Code)
+
+	rec = request(leego.POST, "")
+	assert.Equal(t, "POST /api/users ", rec.Body.String())
+	assert.Equal(t, "upstream", rec.Header().Get("Server"))
+
+	stats := gw.Stats()["users"]
+	if assert.Len(t, stats, 1) {
+		assert.Equal(t, upstream.URL, stats[0].URL)
+		assert.Equal(t, int64(3), stats[0].Requests)
+		assert.Equal(t, int64(0), stats[0].InFlight)
+	}
+
+	routes := lee.Manifest().Routes
+	if assert.Len(t, routes, 2) {
+		for _, r := range routes {
+			assert.Equal(t, "gateway.proxy:users", r.Handler)
+			assert.Equal(t, map[string]string{gateway.MetaCluster: "users"}, r.Meta)
+			if r.Method == leego.GET {
+				assert.Equal(t, "users", r.Name)
+				assert.Equal(t, []string{
+					"token",
+					"gateway.rate_limit:GET /api/users/*",
+					"gateway.rewrite:GET /api/users/*",
+					"gateway.request_headers:GET /api/users/*",
+					"gateway.response_headers:GET /api/users/*",
+				}, r.Middleware)
+			}
+		}
+	}
+
+	// Sticky
+	lee = leego.New()
+	_, err = gateway.Load(lee, gateway.Config{
+		Clusters: map[string]gateway.Cluster{"users": {Targets: []string{upstream.URL}, Balance: "cookie", Cookie: "users"}},
+		Routes:   []gateway.Route{{Method: leego.GET, Path: "/users", Cluster: "users"}},
+	}, nil)
+	if assert.NoError(t, err) {
+		rec = httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, "/users", nil)), standard.NewResponse(rec))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Header().Get(leego.HeaderSetCookie), "users=")
+	}
+
+	// Invalid config
+	for _, c := range []gateway.Config{
+		{Clusters: map[string]gateway.Cluster{"users": {}}},
+		{Clusters: map[string]gateway.Cluster{"users": {Targets: []string{"/users"}}}},
+		{Clusters: map[string]gateway.Cluster{"users": {Targets: []string{upstream.URL}, Balance: "least_conn"}}},
+		{Clusters: map[string]gateway.Cluster{"users": {Targets: []string{upstream.URL}, TLS: &gateway.TLS{CAFile: "missing.pem"}}}},
+		{Routes: []gateway.Route{{Method: leego.GET, Path: "/", Cluster: "users"}}},
+	} {
+		_, err = gateway.Load(leego.New(), c, nil)
+		assert.Error(t, err)
+	}
+}
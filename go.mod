@@ -4,5 +4,6 @@ require (
 	github.com/go-wyvern/logger v0.0.0-20200625042013-43385d202ce6
 	github.com/niemeyer/pretty v0.0.0-20200227124842-a10e7caefd8e // indirect
 	github.com/stretchr/testify v1.6.1
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
 	golang.org/x/net v0.0.0-20200625001655-4c5254603344
 )
@@ -1,5 +1,7 @@
 package leego
 
+import "net/http"
+
 type (
 	// Group is a set of sub-routes for a specified route. It can be used for inner
 	// routes that share a common middlware or functionality that should be separate
@@ -8,121 +10,211 @@ type (
 		prefix     string
 		middleware []MiddlewareFunc
 		leego       *Leego
+		notFoundHandler         HandlerFunc
+		methodNotAllowedHandler HandlerFunc
+		httpErrorHandler        HTTPErrorHandler
 	}
 )
 
 // Use implements `leego#Use()` for sub-routes within the Group.
 func (g *Group) Use(m ...MiddlewareFunc) {
+	if len(m) == 0 {
+		return
+	}
+	registered := len(g.middleware) > 0
 	g.middleware = append(g.middleware, m...)
+	if registered {
+		return
+	}
+	// Groups with the same prefix share the catch-all route.
+	e, prefix := g.leego, g.prefix
+	if groups, ok := e.groupRoutes[prefix]; ok {
+		e.groupRoutes[prefix] = append(groups, g)
+		return
+	}
+	if e.groupRoutes == nil {
+		e.groupRoutes = make(map[string][]*Group)
+	}
+	e.groupRoutes[prefix] = []*Group{g}
 	// Allow all requests to reach the group as they might get dropped if router
-	// doesn't find a match, making none of the group middleware process.
-	g.leego.Any(g.prefix+"*", func(c Context) LeeError {
-		return ErrNotFound
-	}, g.middleware...)
+	// doesn't find a match, making none of the group middleware process. The
+	// middleware is chained per request to include later `Use()` calls. Routes
+	// registered explicitly on the path replace it.
+	routes := e.Any(prefix+"*", func(c Context) LeeError {
+		var m []MiddlewareFunc
+		for _, g := range e.groupRoutes[prefix] {
+			m = append(m, g.middleware...)
+		}
+		return chain(func(c Context) LeeError {
+			if h, ok := e.groupHandler(c.Request().URL().Path(), http.StatusNotFound); ok {
+				return h(c)
+			}
+			return ErrNotFound
+		}, m)(c)
+	})
+	if e.implicitRoutes == nil {
+		e.implicitRoutes = make(map[string]bool)
+	}
+	for _, r := range routes {
+		e.implicitRoutes[r.Method+r.Path] = true
+	}
+}
+
+// SetNotFoundHandler registers the handler for requests under the group prefix
+// which match no route, in place of the global not found handler. The group
+// with the longest matching prefix wins.
+func (g *Group) SetNotFoundHandler(h HandlerFunc) {
+	g.notFoundHandler = h
+	g.registerErrorHandlers()
+}
+
+// SetMethodNotAllowedHandler registers the handler for requests under the group
+// prefix which match a route but not its method. See `SetNotFoundHandler()`.
+func (g *Group) SetMethodNotAllowedHandler(h HandlerFunc) {
+	g.methodNotAllowedHandler = h
+	g.registerErrorHandlers()
+}
+
+// SetHTTPErrorHandler registers the HTTP error handler for requests under the
+// group prefix, in place of the global one, e.g. to send JSON errors from an
+// API group and render an error page elsewhere. See `SetNotFoundHandler()`.
+func (g *Group) SetHTTPErrorHandler(h HTTPErrorHandler) {
+	g.httpErrorHandler = h
+	g.registerErrorHandlers()
+}
+
+func (g *Group) registerErrorHandlers() {
+	for _, eg := range g.leego.errorGroups {
+		if eg == g {
+			return
+		}
+	}
+	g.leego.errorGroups = append(g.leego.errorGroups, g)
 }
 
 // CONNECT implements `leego#CONNECT()` for sub-routes within the Group.
-func (g *Group) CONNECT(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	g.add(CONNECT, path, h, m...)
+func (g *Group) CONNECT(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return g.add(CONNECT, path, h, m...)
 }
 
 // Connect is deprecated, use `CONNECT()` instead.
-func (g *Group) Connect(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	g.add(CONNECT, path, h, m...)
+func (g *Group) Connect(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return g.add(CONNECT, path, h, m...)
 }
 
 // DELETE implements `leego#DELETE()` for sub-routes within the Group.
-func (g *Group) DELETE(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	g.add(DELETE, path, h, m...)
+func (g *Group) DELETE(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return g.add(DELETE, path, h, m...)
 }
 
 // Delete is deprecated, use `DELETE()` instead.
-func (g *Group) Delete(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	g.add(DELETE, path, h, m...)
+func (g *Group) Delete(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return g.add(DELETE, path, h, m...)
 }
 
 // GET implements `leego#GET()` for sub-routes within the Group.
-func (g *Group) GET(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	g.add(GET, path, h, m...)
+func (g *Group) GET(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return g.add(GET, path, h, m...)
 }
 
 // Get is deprecated, use `GET()` instead.
-func (g *Group) Get(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	g.add(GET, path, h, m...)
+func (g *Group) Get(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return g.add(GET, path, h, m...)
 }
 
 // HEAD implements `leego#HEAD()` for sub-routes within the Group.
-func (g *Group) HEAD(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	g.add(HEAD, path, h, m...)
+func (g *Group) HEAD(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return g.add(HEAD, path, h, m...)
 }
 
 // Head is deprecated, use `HEAD()` instead.
-func (g *Group) Head(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	g.add(HEAD, path, h, m...)
+func (g *Group) Head(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return g.add(HEAD, path, h, m...)
 }
 
 // OPTIONS implements `leego#OPTIONS()` for sub-routes within the Group.
-func (g *Group) OPTIONS(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	g.add(OPTIONS, path, h, m...)
+func (g *Group) OPTIONS(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return g.add(OPTIONS, path, h, m...)
 }
 
 // Options is deprecated, use `OPTIONS()` instead.
-func (g *Group) Options(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	g.add(OPTIONS, path, h, m...)
+func (g *Group) Options(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return g.add(OPTIONS, path, h, m...)
 }
 
 // PATCH implements `leego#PATCH()` for sub-routes within the Group.
-func (g *Group) PATCH(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	g.add(PATCH, path, h, m...)
+func (g *Group) PATCH(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return g.add(PATCH, path, h, m...)
 }
 
 // Patch is deprecated, use `PATCH()` instead.
-func (g *Group) Patch(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	g.add(PATCH, path, h, m...)
+func (g *Group) Patch(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return g.add(PATCH, path, h, m...)
 }
 
 // POST implements `leego#POST()` for sub-routes within the Group.
-func (g *Group) POST(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	g.add(POST, path, h, m...)
+func (g *Group) POST(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return g.add(POST, path, h, m...)
 }
 
 // Post is deprecated, use `POST()` instead.
-func (g *Group) Post(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	g.add(POST, path, h, m...)
+func (g *Group) Post(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return g.add(POST, path, h, m...)
 }
 
 // PUT implements `leego#PUT()` for sub-routes within the Group.
-func (g *Group) PUT(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	g.add(PUT, path, h, m...)
+func (g *Group) PUT(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return g.add(PUT, path, h, m...)
 }
 
 // Put is deprecated, use `PUT()` instead.
-func (g *Group) Put(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	g.add(PUT, path, h, m...)
+func (g *Group) Put(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return g.add(PUT, path, h, m...)
 }
 
 // TRACE implements `leego#TRACE()` for sub-routes within the Group.
-func (g *Group) TRACE(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	g.add(TRACE, path, h, m...)
+func (g *Group) TRACE(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return g.add(TRACE, path, h, m...)
 }
 
 // Trace is deprecated, use `TRACE()` instead.
-func (g *Group) Trace(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	g.add(TRACE, path, h, m...)
+func (g *Group) Trace(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return g.add(TRACE, path, h, m...)
 }
 
 // Any implements `leego#Any()` for sub-routes within the Group.
-func (g *Group) Any(path string, handler HandlerFunc, middleware ...MiddlewareFunc) {
-	for _, m := range methods {
-		g.add(m, path, handler, middleware...)
+func (g *Group) Any(path string, handler HandlerFunc, middleware ...MiddlewareFunc) []*Route {
+	routes := make([]*Route, len(methods))
+	for i, m := range methods {
+		routes[i] = g.add(m, path, handler, middleware...)
 	}
+	return routes
 }
 
 // Match implements `leego#Match()` for sub-routes within the Group.
-func (g *Group) Match(methods []string, path string, handler HandlerFunc, middleware ...MiddlewareFunc) {
-	for _, m := range methods {
-		g.add(m, path, handler, middleware...)
+func (g *Group) Match(methods []string, path string, handler HandlerFunc, middleware ...MiddlewareFunc) []*Route {
+	routes := make([]*Route, len(methods))
+	for i, m := range methods {
+		routes[i] = g.add(m, path, handler, middleware...)
 	}
+	return routes
+}
+
+// Add implements `leego#Add()` for sub-routes within the Group.
+func (g *Group) Add(method, path string, handler HandlerFunc, middleware ...MiddlewareFunc) *Route {
+	return g.add(method, path, handler, middleware...)
+}
+
+// Static implements `leego#Static()` for sub-routes within the Group.
+func (g *Group) Static(prefix, root string) {
+	g.GET(prefix+"*", staticHandler(root))
+}
+
+// File implements `leego#File()` for sub-routes within the Group.
+func (g *Group) File(path, file string) {
+	g.GET(path, func(c Context) LeeError {
+		return c.File(file)
+	})
 }
 
 // Group creates a new sub-group with prefix and optional sub-group-level middleware.
@@ -133,12 +225,12 @@ func (g *Group) Group(prefix string, middleware ...MiddlewareFunc) *Group {
 	return g.leego.Group(g.prefix+prefix, m...)
 }
 
-func (g *Group) add(method, path string, handler HandlerFunc, middleware ...MiddlewareFunc) {
+func (g *Group) add(method, path string, handler HandlerFunc, middleware ...MiddlewareFunc) *Route {
 	// Combine into a new slice, to avoid accidentally passing the same
 	// slice for multiple routes, which would lead to later add() calls overwriting
 	// the middleware from earlier calls
 	m := []MiddlewareFunc{}
 	m = append(m, g.middleware...)
 	m = append(m, middleware...)
-	g.leego.add(method, g.prefix+path, handler, m...)
+	return g.leego.add(method, g.prefix+path, handler, m...)
 }
@@ -0,0 +1,196 @@
+package leego_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroup(t *testing.T) {
+	lee := leego.New()
+	mark := func(v string) leego.MiddlewareFunc {
+		return func(next leego.HandlerFunc) leego.HandlerFunc {
+			return func(c leego.Context) leego.LeeError {
+				c.Response().Header().Add("X-Mark", v)
+				return next(c)
+			}
+		}
+	}
+	h := func(c leego.Context) leego.LeeError {
+		return c.String(http.StatusOK, c.Path())
+	}
+
+	v1 := lee.Group("/v1", mark("v1"))
+	v1.GET("/users", h)
+	v1.Match([]string{leego.PUT, leego.PATCH}, "/users/:id", h)
+	admin := v1.Group("/admin", mark("admin"))
+	admin.Add(leego.DELETE, "/users/:id", h)
+	lee.Group("/v2").POST("/users", h)
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(method, path, nil)), standard.NewResponse(rec))
+		return rec
+	}
+
+	rec := serve(leego.GET, "/v1/users")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "/v1/users", rec.Body.String())
+	assert.Equal(t, []string{"v1"}, rec.Header()["X-Mark"])
+
+	rec = serve(leego.PATCH, "/v1/users/1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = serve(leego.DELETE, "/v1/admin/users/1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "/v1/admin/users/:id", rec.Body.String())
+	assert.Equal(t, []string{"v1", "admin"}, rec.Header()["X-Mark"])
+
+	// Group middleware runs for unmatched routes under the prefix
+	rec = serve(leego.GET, "/v1/missing")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, []string{"v1"}, rec.Header()["X-Mark"])
+
+	// A group without middleware doesn't shadow method not allowed
+	rec = serve(leego.GET, "/v2/users")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	// Groups with the same prefix share the catch-all route
+	assert.NotPanics(t, func() {
+		lee.Group("/v1", mark("v1.1")).GET("/teams", h)
+	})
+	rec = serve(leego.GET, "/v1/teams")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"v1.1"}, rec.Header()["X-Mark"])
+	rec = serve(leego.GET, "/v1/missing")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, []string{"v1", "v1.1"}, rec.Header()["X-Mark"])
+}
+
+func TestGroupCatchAll(t *testing.T) {
+	lee := leego.New()
+	mark := func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			c.Response().Header().Set("X-Mark", "1")
+			return next(c)
+		}
+	}
+
+	// Explicit routes replace the catch-all route of the group.
+	assert.NotPanics(t, func() {
+		lee.Group("/api", mark).GET("*", func(c leego.Context) leego.LeeError {
+			return c.String(http.StatusOK, "api")
+		})
+		lee.Group("/assets", mark).Static("", "engine/standard")
+	})
+	assert.Panics(t, func() {
+		lee.GET("/api*", leego.NotFoundHandler)
+	})
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, path, nil)), standard.NewResponse(rec))
+		return rec
+	}
+	rec := serve("/api/users")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "api", rec.Body.String())
+	assert.Equal(t, "1", rec.Header().Get("X-Mark"))
+	rec = serve("/assets/url.go")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("X-Mark"))
+	assert.Equal(t, http.StatusNotFound, serve("/assets/missing.go").Code)
+}
+
+func TestGroupErrorHandlers(t *testing.T) {
+	lee := leego.New()
+	h := func(c leego.Context) leego.LeeError {
+		return c.String(http.StatusOK, "ok")
+	}
+	api := lee.Group("/api")
+	api.GET("/users", h)
+	api.SetNotFoundHandler(func(c leego.Context) leego.LeeError {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "not found"})
+	})
+	api.SetMethodNotAllowedHandler(func(c leego.Context) leego.LeeError {
+		return c.JSON(http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	})
+	admin := api.Group("/admin")
+	admin.SetNotFoundHandler(func(c leego.Context) leego.LeeError {
+		return c.String(http.StatusNotFound, "admin")
+	})
+	web := lee.Group("/web", func(next leego.HandlerFunc) leego.HandlerFunc {
+		return next
+	})
+	web.GET("/home", h)
+	web.SetNotFoundHandler(func(c leego.Context) leego.LeeError {
+		return c.HTML(http.StatusNotFound, "<h1>Not Found</h1>")
+	})
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(method, path, nil)), standard.NewResponse(rec))
+		return rec
+	}
+
+	rec := serve(leego.GET, "/api/missing")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, `{"error":"not found"}`, rec.Body.String())
+
+	rec = serve(leego.POST, "/api/users")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, `{"error":"method not allowed"}`, rec.Body.String())
+
+	rec = serve(leego.GET, "/api/admin/missing")
+	assert.Equal(t, "admin", rec.Body.String())
+
+	rec = serve(leego.GET, "/web/missing")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "<h1>Not Found</h1>", rec.Body.String())
+
+	rec = serve(leego.GET, "/missing")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, http.StatusText(http.StatusNotFound), rec.Body.String())
+
+	// Fast path
+	lee.SetNotFoundFastPath(true)
+	rec = serve(leego.GET, "/api/missing")
+	assert.Equal(t, `{"error":"not found"}`, rec.Body.String())
+	rec = serve(leego.GET, "/missing")
+	assert.Equal(t, http.StatusText(http.StatusNotFound), rec.Body.String())
+}
+
+func TestGroupHTTPErrorHandler(t *testing.T) {
+	lee := leego.New()
+	fail := func(c leego.Context) leego.LeeError {
+		return leego.NewHTTPError(http.StatusConflict, "conflict")
+	}
+	lee.GET("/fail", fail)
+	api := lee.Group("/api")
+	api.GET("/fail", fail)
+	api.SetHTTPErrorHandler(func(err leego.LeeError, c leego.Context) {
+		c.JSON(err.(*leego.HTTPError).Code, map[string]string{"title": err.Error()})
+	})
+	v2 := api.Group("/v2")
+	v2.GET("/fail", fail)
+	v2.SetHTTPErrorHandler(func(err leego.LeeError, c leego.Context) {
+		c.String(http.StatusTeapot, "v2 "+err.Error())
+	})
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, path, nil)), standard.NewResponse(rec))
+		return rec
+	}
+
+	rec := serve("/api/fail")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, `{"title":"conflict"}`, rec.Body.String())
+	assert.Equal(t, `{"title":"Not Found"}`, serve("/api/missing").Body.String())
+	assert.Equal(t, "v2 conflict", serve("/api/v2/fail").Body.String())
+	assert.Equal(t, "conflict", serve("/fail").Body.String())
+}
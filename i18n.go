@@ -0,0 +1,242 @@
+package leego
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Catalog holds the translated messages by language, see `Context#T()`.
+// Messages are looked up in the language of the request, then its base
+// language, e.g. "en" for "en-US", then the fallback language.
+type Catalog struct {
+	mu       sync.RWMutex
+	fallback string
+	messages map[string]map[string]string
+}
+
+// NewCatalog returns an empty catalog with the fallback language.
+func NewCatalog(fallback string) *Catalog {
+	return &Catalog{
+		fallback: fallback,
+		messages: make(map[string]map[string]string),
+	}
+}
+
+// Fallback returns the fallback language of the catalog.
+func (c *Catalog) Fallback() string {
+	return c.fallback
+}
+
+// Languages returns the languages of the catalog, the fallback language first
+// then sorted, e.g. as the offers of `Context#AcceptsLanguage()`.
+func (c *Catalog) Languages() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	langs := make([]string, 0, len(c.messages)+1)
+	for lang := range c.messages {
+		if lang != c.fallback {
+			langs = append(langs, lang)
+		}
+	}
+	sort.Strings(langs)
+	return append([]string{c.fallback}, langs...)
+}
+
+// Add adds the messages of the language lang, keyed by message key.
+func (c *Catalog) Add(lang string, messages map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m := c.messages[lang]
+	if m == nil {
+		m = make(map[string]string, len(messages))
+		c.messages[lang] = m
+	}
+	for k, v := range messages {
+		m[k] = v
+	}
+}
+
+// Load adds the messages of the language lang from a JSON or TOML file, by
+// extension. Nested objects and tables give dotted keys, e.g. "errors.auth".
+func (c *Catalog) Load(lang, filename string) error {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	messages := make(map[string]string)
+	switch ext := filepath.Ext(filename); ext {
+	case ".json":
+		err = parseJSONMessages(b, messages)
+	case ".toml":
+		err = parseTOMLMessages(b, messages)
+	default:
+		err = fmt.Errorf("unsupported message file %q", filename)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+	c.Add(lang, messages)
+	return nil
+}
+
+// LoadDir loads the message files in dir, named after their language, e.g.
+// "en-US.json" or "fr.toml". Other files are ignored.
+func (c *Catalog) LoadDir(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range files {
+		ext := filepath.Ext(fi.Name())
+		if fi.IsDir() || ext != ".json" && ext != ".toml" {
+			continue
+		}
+		if err = c.Load(strings.TrimSuffix(fi.Name(), ext), filepath.Join(dir, fi.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Translate returns the message key in the language lang, formatted with
+// args by `fmt.Sprintf()` if any. It returns the key itself if there is no
+// such message.
+func (c *Catalog) Translate(lang, key string, args ...interface{}) string {
+	return formatMessage(c.lookup(lang, key), args)
+}
+
+// lookup returns the message key in the language lang, the key itself if
+// there is none.
+func (c *Catalog) lookup(lang, key string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, l := range []string{lang, baseLanguage(lang), c.fallback} {
+		if msg, ok := c.messages[l][key]; ok {
+			return msg
+		}
+	}
+	return key
+}
+
+// SetCatalog registers the catalog of `Context#T()`. Its fallback language is
+// the language of the requests without any.
+func (e *Leego) SetCatalog(c *Catalog) {
+	e.catalog = c
+}
+
+// Catalog returns the catalog, nil if none is registered.
+func (e *Leego) Catalog() *Catalog {
+	return e.catalog
+}
+
+func (c *leegoContext) T(key string, args ...interface{}) string {
+	if cat := c.leego.catalog; cat != nil {
+		return cat.Translate(c.lang, key, args...)
+	}
+	return formatMessage(key, args)
+}
+
+// formatMessage formats msg with args if any. Keys aren't format strings, so
+// args are taken as a slice and vet doesn't check the calls as printf ones.
+func formatMessage(msg string, args []interface{}) string {
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// baseLanguage returns the primary subtag of the language tag, e.g. "en" for
+// "en-US".
+func baseLanguage(lang string) string {
+	if i := strings.IndexByte(lang, '-'); i >= 0 {
+		return lang[:i]
+	}
+	return lang
+}
+
+func parseJSONMessages(b []byte, messages map[string]string) error {
+	var v map[string]interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	return flattenMessages("", v, messages)
+}
+
+func flattenMessages(prefix string, v map[string]interface{}, messages map[string]string) error {
+	for k, v := range v {
+		switch v := v.(type) {
+		case string:
+			messages[prefix+k] = v
+		case map[string]interface{}:
+			if err := flattenMessages(prefix+k+".", v, messages); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("message %q is not a string", prefix+k)
+		}
+	}
+	return nil
+}
+
+// parseTOMLMessages parses the subset of TOML used by message files: tables
+// and string keys.
+func parseTOMLMessages(b []byte, messages map[string]string) error {
+	prefix := ""
+	for i, line := range bytes.Split(b, []byte("\n")) {
+		l := strings.TrimSpace(string(line))
+		if l == "" || l[0] == '#' {
+			continue
+		}
+		if l[0] == '[' {
+			end := strings.IndexByte(l, ']')
+			if end < 0 || strings.HasPrefix(l, "[[") {
+				return fmt.Errorf("line %d: invalid table", i+1)
+			}
+			prefix = strings.TrimSpace(l[1:end]) + "."
+			continue
+		}
+		eq := strings.IndexByte(l, '=')
+		if eq < 0 {
+			return fmt.Errorf("line %d: expected key = value", i+1)
+		}
+		key := strings.Trim(strings.TrimSpace(l[:eq]), `"`)
+		value, err := parseTOMLString(strings.TrimSpace(l[eq+1:]))
+		if err != nil {
+			return fmt.Errorf("line %d: %v", i+1, err)
+		}
+		messages[prefix+key] = value
+	}
+	return nil
+}
+
+// parseTOMLString parses a basic or literal string, followed by an optional
+// comment.
+func parseTOMLString(s string) (string, error) {
+	if s == "" || s[0] != '"' && s[0] != '\'' {
+		return "", fmt.Errorf("value is not a string")
+	}
+	quote := s[0]
+	end := 1
+	for ; end < len(s) && s[end] != quote; end++ {
+		if quote == '"' && s[end] == '\\' {
+			end++
+		}
+	}
+	if end >= len(s) {
+		return "", fmt.Errorf("unterminated string")
+	}
+	if rest := strings.TrimSpace(s[end+1:]); rest != "" && rest[0] != '#' {
+		return "", fmt.Errorf("unexpected %q after string", rest)
+	}
+	if quote == '\'' {
+		return s[1:end], nil
+	}
+	return strconv.Unquote(s[:end+1])
+}
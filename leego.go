@@ -5,9 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"path"
+	"path/filepath"
 	"reflect"
 	"runtime"
+	"sort"
+	"strings"
 	"sync"
 
 	"golang.org/x/net/context"
@@ -19,31 +24,76 @@ import (
 type (
 	// Leego is the top-level framework instance.
 	Leego struct {
-		premiddleware      []MiddlewareFunc
-		middleware         []MiddlewareFunc
-		maxParam           *int
-		notFoundHandler    HandlerFunc
-		httpErrorHandler   HTTPErrorHandler
-		httpSuccessHandler HTTPSuccessHandler
-		binder             Binder
-		renderer           Renderer
-		pool               sync.Pool
-		debug              bool
-		router             *Router
-		logger             *logger.Logger
+		premiddleware           []MiddlewareFunc
+		middleware              []MiddlewareFunc
+		maxParam                *int
+		notFoundHandler         HandlerFunc
+		methodNotAllowedHandler HandlerFunc
+		httpErrorHandler        HTTPErrorHandler
+		httpSuccessHandler      HTTPSuccessHandler
+		binder                  Binder
+		validator               Validator
+		codecs                  map[string]Codec
+		urlSigningKey           []byte
+		renderer                Renderer
+		pool                    sync.Pool
+		debug                   bool
+		router                  *Router
+		logger                  *logger.Logger
+		fastPath                bool
+		fastPathMiddleware      []MiddlewareFunc
+		pvaluesPool             sync.Pool
+		trustedProxyDepth       int
+		trustedProxies          []*net.IPNet
+		conditionals            map[string]*conditionalRoute
+		errorGroups             []*Group
+		groupRoutes             map[string][]*Group
+		implicitRoutes          map[string]bool
+		routeTrace              bool
+		captureBody             bool
+		dispatch                HandlerFunc
+		finalizers              []FinalizerFunc
+		events                  *EventBus
+		serverMu                sync.Mutex
+		server                  engine.Server
+		operationsPrefix        string
+		operationStore          OperationStore
+		catalog                 *Catalog
 	}
 
 	// Route contains a handler and information for matching against requests.
 	Route struct {
-		Method  string
-		Path    string
-		Handler string
+		Method  string `json:"method"`
+		Path    string `json:"path"`
+		Handler string `json:"handler"`
+
+		// Name names the route for `Leego#Reverse()`.
+		Name string `json:"name,omitempty"`
+	}
+
+	// RouteInfo describes a registered route, see `Leego#Routes()`.
+	RouteInfo struct {
+		Route
+
+		// Middleware are the names of the group and route-level middleware.
+		Middleware []string `json:"middleware,omitempty"`
+
+		// Meta is the metadata of the route, see `Leego#SetRouteMeta()`.
+		Meta map[string]string `json:"meta,omitempty"`
 	}
 
 	// HTTPError represents an error that occurred while handling a request.
+	// Internal is the underlying error, only shown in debug mode.
 	HTTPError struct {
-		Code    int
-		Message string
+		Code     int                    `json:"-"`
+		Message  string                 `json:"message"`
+		Details  map[string]interface{} `json:"details,omitempty"`
+		Internal error                  `json:"-"`
+	}
+
+	// Aborted is the panic value of `Abort()`.
+	Aborted struct {
+		*HTTPError
 	}
 
 	// MiddlewareFunc defines a function to process middleware.
@@ -56,6 +106,10 @@ type (
 	HTTPErrorHandler func(LeeError, Context)
 	// HTTPSuccessHandler is a centralized HTTP success handler.
 	HTTPSuccessHandler func(Context)
+	// FinalizerFunc is run after the request is handled, with the error
+	// returned by the chain. See `Leego#Finally()`.
+	FinalizerFunc func(Context, LeeError)
+
 	// ResponseHandler is a response handler.
 	ResponseHandler func(LeeError, Context)
 
@@ -64,9 +118,16 @@ type (
 		Error() string
 	}
 
-	// Validator is the interface that wraps the Validate function.
+	// Validator is the interface that wraps the Validate function, e.g. with
+	// go-playground/validator:
+	//
+	//	type validator struct{ v *validator.Validate }
+	//
+	//	func (v *validator) Validate(i interface{}) error {
+	//		return v.v.Struct(i)
+	//	}
 	Validator interface {
-		Validate() error
+		Validate(i interface{}) error
 	}
 
 	// Renderer is the interface that wraps the Render function.
@@ -106,6 +167,7 @@ var (
 const (
 	MIMEApplicationJSON                  = "application/json"
 	MIMEApplicationJSONCharsetUTF8       = MIMEApplicationJSON + "; " + charsetUTF8
+	MIMEApplicationProblemJSON           = "application/problem+json"
 	MIMEApplicationJavaScript            = "application/javascript"
 	MIMEApplicationJavaScriptCharsetUTF8 = MIMEApplicationJavaScript + "; " + charsetUTF8
 	MIMEApplicationXML                   = "application/xml"
@@ -127,16 +189,21 @@ const (
 
 // Headers
 const (
+	HeaderAccept                        = "Accept"
 	HeaderAcceptEncoding                = "Accept-Encoding"
+	HeaderAcceptLanguage                = "Accept-Language"
 	HeaderAllow                         = "Allow"
 	HeaderAuthorization                 = "Authorization"
 	HeaderContentDisposition            = "Content-Disposition"
 	HeaderContentEncoding               = "Content-Encoding"
+	HeaderContentLanguage               = "Content-Language"
 	HeaderContentLength                 = "Content-Length"
 	HeaderContentType                   = "Content-Type"
 	HeaderCookie                        = "Cookie"
 	HeaderSetCookie                     = "Set-Cookie"
+	HeaderETag                          = "ETag"
 	HeaderIfModifiedSince               = "If-Modified-Since"
+	HeaderIfNoneMatch                   = "If-None-Match"
 	HeaderLastModified                  = "Last-Modified"
 	HeaderLocation                      = "Location"
 	HeaderUpgrade                       = "Upgrade"
@@ -145,8 +212,17 @@ const (
 	HeaderXForwardedProto               = "X-Forwarded-Proto"
 	HeaderXHTTPMethodOverride           = "X-HTTP-Method-Override"
 	HeaderXForwardedFor                 = "X-Forwarded-For"
+	HeaderXForwardedHost                = "X-Forwarded-Host"
+	HeaderForwarded                     = "Forwarded"
 	HeaderXRealIP                       = "X-Real-IP"
+	HeaderXRequestID                    = "X-Request-ID"
+	HeaderXNonce                        = "X-Nonce"
+	HeaderXTimestamp                    = "X-Timestamp"
+	HeaderAPIVersion                    = "API-Version"
+	HeaderTraceparent                   = "Traceparent"
+	HeaderTracestate                    = "Tracestate"
 	HeaderServer                        = "Server"
+	HeaderXRouteTrace                   = "X-Route-Trace"
 	HeaderOrigin                        = "Origin"
 	HeaderAccessControlRequestMethod    = "Access-Control-Request-Method"
 	HeaderAccessControlRequestHeaders   = "Access-Control-Request-Headers"
@@ -156,6 +232,12 @@ const (
 	HeaderAccessControlAllowCredentials = "Access-Control-Allow-Credentials"
 	HeaderAccessControlExposeHeaders    = "Access-Control-Expose-Headers"
 	HeaderAccessControlMaxAge           = "Access-Control-Max-Age"
+	HeaderRetryAfter                    = "Retry-After"
+	HeaderXRateLimitLimit               = "X-RateLimit-Limit"
+	HeaderXRateLimitRemaining           = "X-RateLimit-Remaining"
+	HeaderXRateLimitReset               = "X-RateLimit-Reset"
+	HeaderCacheControl                  = "Cache-Control"
+	HeaderXCache                        = "X-Cache"
 
 	// Security
 	HeaderStrictTransportSecurity = "Strict-Transport-Security"
@@ -172,8 +254,10 @@ var (
 	ErrNotFound                    = NewHTTPError(http.StatusNotFound)
 	ErrUnauthorized                = NewHTTPError(http.StatusUnauthorized)
 	ErrMethodNotAllowed            = NewHTTPError(http.StatusMethodNotAllowed)
+	ErrNotAcceptable               = NewHTTPError(http.StatusNotAcceptable)
 	ErrStatusRequestEntityTooLarge = NewHTTPError(http.StatusRequestEntityTooLarge)
 	ErrRendererNotRegistered       = errors.New("renderer not registered")
+	ErrValidatorNotRegistered      = errors.New("validator not registered")
 	ErrInvalidRedirectCode         = errors.New("invalid redirect status code")
 	ErrCookieNotFound              = errors.New("cookie not found")
 )
@@ -199,20 +283,84 @@ func NewHTTPError(code int, msg ...string) *HTTPError {
 	return he
 }
 
+// Abort stops handling the request by panicking with an HTTP error, so deeply
+// nested code doesn't have to return it through every caller, e.g.
+//
+//	if !allowed {
+//		leego.Abort(http.StatusForbidden, "not your document")
+//	}
+//
+// `middleware.Recover()` returns the error to the HTTP error handler, without
+// logging a stack trace.
+func Abort(code int, msg ...string) {
+	panic(Aborted{NewHTTPError(code, msg...)})
+}
+
 // Error makes it compatible with `error` interface.
 func (e *HTTPError) Error() string {
+	if e.Internal != nil {
+		return e.Message + ": " + e.Internal.Error()
+	}
 	return e.Message
 }
 
+// SetInternal returns a copy of e wrapping the internal error err, so that
+// predefined errors like `ErrNotFound` are never modified.
+func (e *HTTPError) SetInternal(err error) *HTTPError {
+	he := *e
+	he.Internal = err
+	return &he
+}
+
+// WithDetail returns a copy of e with the detail key set to value.
+func (e *HTTPError) WithDetail(key string, value interface{}) *HTTPError {
+	he := *e
+	he.Details = make(map[string]interface{}, len(e.Details)+1)
+	for k, v := range e.Details {
+		he.Details[k] = v
+	}
+	he.Details[key] = value
+	return &he
+}
+
+// Unwrap returns the internal error, for `errors.Is()` and `errors.As()`.
+func (e *HTTPError) Unwrap() error {
+	return e.Internal
+}
+
+// Is reports whether target is an HTTPError with the same code and message,
+// so that `errors.Is(err, leego.ErrNotFound)` holds for copies returned by
+// `SetInternal()` and `WithDetail()`.
+func (e *HTTPError) Is(target error) bool {
+	he, ok := target.(*HTTPError)
+	return ok && he.Code == e.Code && he.Message == e.Message
+}
+
+// asHTTPError returns the first HTTPError in the chain of err.
+func asHTTPError(err error) (*HTTPError, bool) {
+	for err != nil {
+		if he, ok := err.(*HTTPError); ok {
+			return he, true
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return nil, false
+		}
+		err = u.Unwrap()
+	}
+	return nil, false
+}
+
 // New creates an instance of leego.
 func New() (e *Leego) {
-	e = &Leego{maxParam: new(int)}
+	e = &Leego{maxParam: new(int), events: NewEventBus()}
 	e.pool.New = func() interface{} {
 		return e.NewContext(nil, nil)
 	}
 	e.router = NewRouter(e)
+	e.dispatch = e.route
 
-	e.SetBinder(&binder{})
+	e.SetBinder(&binder{leego: e})
 	e.SetHTTPErrorHandler(e.DefaultHTTPErrorHandler)
 	e.SetHTTPSuccessHandler(e.DefaultHTTPSuccessHandler)
 	return
@@ -221,7 +369,7 @@ func New() (e *Leego) {
 // NewContext returns a Context instance.
 func (e *Leego) NewContext(req engine.Request, res engine.Response) Context {
 	return &leegoContext{
-		context:  context.Background(),
+		context:  requestContext(req),
 		request:  req,
 		response: res,
 		leego:    e,
@@ -234,43 +382,158 @@ func (e *Leego) NewContext(req engine.Request, res engine.Response) Context {
 // ResponseHandler response do this handler
 func (e *Leego) ResponseHandler(err LeeError, c Context) {
 	if err != nil {
-		e.httpErrorHandler(err, c)
+		e.httpErrorHandlerFor(c.Request().URL().Path())(err, c)
 	} else {
 		e.httpSuccessHandler(c)
 	}
 
 }
 
+// SetNotFoundFastPath enables or disables the not found fast path. With it
+// enabled, requests that match no route (404) or no method of a route (405)
+// are answered without running the `Pre` and `Use` middleware. By default the
+// status text is written as a plain text response without acquiring a context.
+// If middleware `m` (e.g. logging, metrics) is provided, a context is acquired
+// and only `m` runs around the not found handler, followed by the error handler.
+//
+// Note that `Pre` middleware which rewrites the request path is bypassed too.
+func (e *Leego) SetNotFoundFastPath(enabled bool, m ...MiddlewareFunc) {
+	e.fastPath = enabled
+	e.fastPathMiddleware = m
+}
+
+// SetDebug enables/disables debug mode.
+func (e *Leego) SetDebug(on bool) {
+	e.debug = on
+}
+
+// Debug returns debug mode (enabled or disabled).
+func (e *Leego) Debug() bool {
+	return e.debug
+}
+
+// SetCaptureBody enables/disables capturing the body of the JSON and XML
+// responses in `Response#Body()`, e.g. for logging. It's disabled by default, as
+// it keeps a copy of every response body.
+func (e *Leego) SetCaptureBody(on bool) {
+	e.captureBody = on
+}
+
+// CaptureBody returns whether response bodies are captured.
+func (e *Leego) CaptureBody() bool {
+	return e.captureBody
+}
+
+// SetCaseInsensitive enables/disables case-insensitive matching of the static
+// parts of the route paths, e.g. "/Users/:id" matches "/users/Joe" with "Joe"
+// as id. It must be set before adding routes.
+func (e *Leego) SetCaseInsensitive(on bool) {
+	e.router.caseInsensitive = on
+}
+
+// SetAutoOptions enables/disables answering the OPTIONS requests to the paths
+// of the routes without an OPTIONS route, with 204 and the `Allow` header
+// listing their methods. The requests go through the middleware, e.g. CORS
+// answering preflights.
+func (e *Leego) SetAutoOptions(on bool) {
+	e.router.autoOptions = on
+}
+
+// SetRouteTrace enables/disables the route trace in debug mode. When enabled,
+// the router's matching decisions, i.e. the nodes considered and why they were
+// rejected, are sent in the `X-Route-Trace` response header.
+func (e *Leego) SetRouteTrace(on bool) {
+	e.routeTrace = on
+}
+
+// SetMethodNotAllowedHandler registers the handler for requests which match a
+// route but not its method, in place of `MethodNotAllowedHandler`. The `Allow`
+// response header lists the methods of the route. See
+// `Group#SetMethodNotAllowedHandler()` to override it under a prefix.
+func (e *Leego) SetMethodNotAllowedHandler(h HandlerFunc) {
+	e.methodNotAllowedHandler = h
+}
+
+// errorHandler returns the handler registered for the not found (code 404) or
+// method not allowed (code 405) requests to path, by a group or globally.
+func (e *Leego) errorHandler(path string, code int) (HandlerFunc, bool) {
+	if h, ok := e.groupHandler(path, code); ok {
+		return h, true
+	}
+	if code == http.StatusMethodNotAllowed && e.methodNotAllowedHandler != nil {
+		return e.methodNotAllowedHandler, true
+	}
+	return nil, false
+}
+
+// groupHandler returns the not found (code 404) or method not allowed (code
+// 405) handler of the group with the longest prefix of path, if any group
+// registered one. See `Group#SetNotFoundHandler()`.
+func (e *Leego) groupHandler(path string, code int) (h HandlerFunc, ok bool) {
+	n := -1
+	for _, g := range e.errorGroups {
+		if len(g.prefix) <= n || !strings.HasPrefix(path, g.prefix) {
+			continue
+		}
+		gh := g.notFoundHandler
+		if code == http.StatusMethodNotAllowed {
+			gh = g.methodNotAllowedHandler
+		}
+		if gh != nil {
+			h, n = gh, len(g.prefix)
+		}
+	}
+	return h, h != nil
+}
+
+// httpErrorHandlerFor returns the HTTP error handler of the group with the
+// longest prefix matching path, the global one if there is none.
+func (e *Leego) httpErrorHandlerFor(path string) HTTPErrorHandler {
+	h, n := e.httpErrorHandler, -1
+	for _, g := range e.errorGroups {
+		if g.httpErrorHandler != nil && len(g.prefix) > n && strings.HasPrefix(path, g.prefix) {
+			h, n = g.httpErrorHandler, len(g.prefix)
+		}
+	}
+	return h
+}
+
 // Router returns router.
 func (e *Leego) Router() *Router {
 	return e.router
 }
 
-// DefaultHTTPErrorHandler invokes the default HTTP error handler.
+// DefaultHTTPErrorHandler invokes the default HTTP error handler. It sends
+// the message of the error as text, or as JSON with its details when the
+// client prefers it. Other errors than HTTPError and internal errors are only
+// shown in debug mode.
 func (e *Leego) DefaultHTTPErrorHandler(err LeeError, c Context) {
-	code := http.StatusInternalServerError
-	msg := http.StatusText(code)
-	if he, ok := err.(*HTTPError); ok {
-		code = he.Code
-		msg = he.Message
+	he := &HTTPError{Code: http.StatusInternalServerError, Message: http.StatusText(http.StatusInternalServerError)}
+	if h, ok := asHTTPError(err); ok {
+		he = h
 	}
+	msg := he.Message
 	if e.debug {
 		msg = err.Error()
 	}
-	if !c.Response().Committed() {
-		if c.Request().Method() == HEAD {
-			// Issue #608
-			c.NoContent(code)
-		} else {
-			c.String(code, msg)
-		}
+	if c.Response().Committed() {
+		return
+	}
+	if c.Request().Method() == HEAD {
+		// Issue #608
+		c.NoContent(he.Code)
+	} else if c.Accepts(MIMETextPlain, MIMEApplicationJSON) == MIMEApplicationJSON {
+		c.JSON(he.Code, &HTTPError{Message: msg, Details: he.Details})
+	} else {
+		c.String(he.Code, msg)
 	}
 }
 
 // DefaultHTTPSuccessHandler this is default handler when Success do it
 func (e *Leego) DefaultHTTPSuccessHandler(c Context) {}
 
-// SetHTTPErrorHandler set http error handler
+// SetHTTPErrorHandler set http error handler. Groups can override it, see
+// `Group#SetHTTPErrorHandler()`.
 func (e *Leego) SetHTTPErrorHandler(h HTTPErrorHandler) {
 	e.httpErrorHandler = h
 }
@@ -290,6 +553,35 @@ func (e *Leego) Binder() Binder {
 	return e.binder
 }
 
+// SetValidator registers a validator. It's invoked by `Context#Validate()` and
+// `Context#BindAndValidate()`.
+func (e *Leego) SetValidator(v Validator) {
+	e.validator = v
+}
+
+// Validator returns the validator instance.
+func (e *Leego) Validator() Validator {
+	return e.validator
+}
+
+// SetRenderer registers an HTML template renderer. It's invoked by `Context#Render()`.
+func (e *Leego) SetRenderer(r Renderer) {
+	e.renderer = r
+}
+
+// Renderer returns the renderer instance.
+func (e *Leego) Renderer() Renderer {
+	return e.renderer
+}
+
+// Finally adds finalizers which run after the handler, all middleware and the
+// error handler for every request. They run even if a middleware returns
+// without calling next or the chain panics, so they are the place for metrics
+// and logging which must never be skipped.
+func (e *Leego) Finally(f ...FinalizerFunc) {
+	e.finalizers = append(e.finalizers, f...)
+}
+
 // Pre adds middleware to the chain which is run before router.
 func (e *Leego) Pre(middleware ...MiddlewareFunc) {
 	e.premiddleware = append(e.premiddleware, middleware...)
@@ -302,142 +594,168 @@ func (e *Leego) Use(middleware ...MiddlewareFunc) {
 
 // CONNECT registers a new CONNECT route for a path with matching handler in the
 // router with optional route-level middleware.
-func (e *Leego) CONNECT(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	e.add(CONNECT, path, h, m...)
+func (e *Leego) CONNECT(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return e.add(CONNECT, path, h, m...)
 }
 
 // Connect is deprecated, use `CONNECT()` instead.
-func (e *Leego) Connect(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	e.CONNECT(path, h, m...)
+func (e *Leego) Connect(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return e.CONNECT(path, h, m...)
 }
 
 // DELETE registers a new DELETE route for a path with matching handler in the router
 // with optional route-level middleware.
-func (e *Leego) DELETE(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	e.add(DELETE, path, h, m...)
+func (e *Leego) DELETE(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return e.add(DELETE, path, h, m...)
 }
 
 // Delete is deprecated, use `DELETE()` instead.
-func (e *Leego) Delete(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	e.DELETE(path, h, m...)
+func (e *Leego) Delete(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return e.DELETE(path, h, m...)
 }
 
 // GET registers a new GET route for a path with matching handler in the router
 // with optional route-level middleware.
-func (e *Leego) GET(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	e.add(GET, path, h, m...)
+func (e *Leego) GET(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return e.add(GET, path, h, m...)
 }
 
 // Get is deprecated, use `GET()` instead.
-func (e *Leego) Get(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	e.GET(path, h, m...)
+func (e *Leego) Get(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return e.GET(path, h, m...)
 }
 
 // HEAD registers a new HEAD route for a path with matching handler in the
 // router with optional route-level middleware.
-func (e *Leego) HEAD(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	e.add(HEAD, path, h, m...)
+func (e *Leego) HEAD(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return e.add(HEAD, path, h, m...)
 }
 
 // Head is deprecated, use `HEAD()` instead.
-func (e *Leego) Head(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	e.HEAD(path, h, m...)
+func (e *Leego) Head(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return e.HEAD(path, h, m...)
 }
 
 // OPTIONS registers a new OPTIONS route for a path with matching handler in the
 // router with optional route-level middleware.
-func (e *Leego) OPTIONS(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	e.add(OPTIONS, path, h, m...)
+func (e *Leego) OPTIONS(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return e.add(OPTIONS, path, h, m...)
 }
 
 // Options is deprecated, use `OPTIONS()` instead.
-func (e *Leego) Options(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	e.OPTIONS(path, h, m...)
+func (e *Leego) Options(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return e.OPTIONS(path, h, m...)
 }
 
 // PATCH registers a new PATCH route for a path with matching handler in the
 // router with optional route-level middleware.
-func (e *Leego) PATCH(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	e.add(PATCH, path, h, m...)
+func (e *Leego) PATCH(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return e.add(PATCH, path, h, m...)
 }
 
 // Patch is deprecated, use `PATCH()` instead.
-func (e *Leego) Patch(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	e.PATCH(path, h, m...)
+func (e *Leego) Patch(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return e.PATCH(path, h, m...)
 }
 
 // POST registers a new POST route for a path with matching handler in the
 // router with optional route-level middleware.
-func (e *Leego) POST(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	e.add(POST, path, h, m...)
+func (e *Leego) POST(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return e.add(POST, path, h, m...)
 }
 
 // Post is deprecated, use `POST()` instead.
-func (e *Leego) Post(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	e.POST(path, h, m...)
+func (e *Leego) Post(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return e.POST(path, h, m...)
 }
 
 // PUT registers a new PUT route for a path with matching handler in the
 // router with optional route-level middleware.
-func (e *Leego) PUT(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	e.add(PUT, path, h, m...)
+func (e *Leego) PUT(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return e.add(PUT, path, h, m...)
 }
 
 // Put is deprecated, use `PUT()` instead.
-func (e *Leego) Put(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	e.PUT(path, h, m...)
+func (e *Leego) Put(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return e.PUT(path, h, m...)
 }
 
 // TRACE registers a new TRACE route for a path with matching handler in the
 // router with optional route-level middleware.
-func (e *Leego) TRACE(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	e.add(TRACE, path, h, m...)
+func (e *Leego) TRACE(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return e.add(TRACE, path, h, m...)
 }
 
 // Trace is deprecated, use `TRACE()` instead.
-func (e *Leego) Trace(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	e.TRACE(path, h, m...)
+func (e *Leego) Trace(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return e.TRACE(path, h, m...)
 }
 
 // Any registers a new route for all HTTP methods and path with matching handler
 // in the router with optional route-level middleware.
-func (e *Leego) Any(path string, handler HandlerFunc, middleware ...MiddlewareFunc) {
-	for _, m := range methods {
-		e.add(m, path, handler, middleware...)
+func (e *Leego) Any(path string, handler HandlerFunc, middleware ...MiddlewareFunc) []*Route {
+	routes := make([]*Route, len(methods))
+	for i, m := range methods {
+		routes[i] = e.add(m, path, handler, middleware...)
 	}
+	return routes
 }
 
 // Match registers a new route for multiple HTTP methods and path with matching
 // handler in the router with optional route-level middleware.
-func (e *Leego) Match(methods []string, path string, handler HandlerFunc, middleware ...MiddlewareFunc) {
-	for _, m := range methods {
-		e.add(m, path, handler, middleware...)
+func (e *Leego) Match(methods []string, path string, handler HandlerFunc, middleware ...MiddlewareFunc) []*Route {
+	routes := make([]*Route, len(methods))
+	for i, m := range methods {
+		routes[i] = e.add(m, path, handler, middleware...)
 	}
+	return routes
 }
 
-// Add registers a new route for multiple HTTP methods and path with add
-// handler in the router with optional route-level middleware.
-func (e *Leego) Add(method, path string, handler HandlerFunc, middleware ...MiddlewareFunc) {
-	e.add(method, path, handler, middleware...)
+// Add registers a new route for an HTTP method and path with matching handler
+// in the router with optional route-level middleware. The method may be
+// non-standard, e.g. PROPFIND or REPORT for WebDAV. It panics if the route is
+// already registered, or conflicts with a route of the same shape but other
+// param names, e.g. "/users/:id" and "/users/:name".
+func (e *Leego) Add(method, path string, handler HandlerFunc, middleware ...MiddlewareFunc) *Route {
+	return e.add(method, path, handler, middleware...)
 }
 
-func (e *Leego) add(method, path string, handler HandlerFunc, middleware ...MiddlewareFunc) {
+func (e *Leego) add(method, path string, handler HandlerFunc, middleware ...MiddlewareFunc) *Route {
 	name := handlerName(handler)
-	e.router.Add(method, path, func(c Context) LeeError {
+	cr := e.conditionals[method+path]
+	if e.implicitRoutes[method+path] {
+		// The catch-all route of a group, see `Group#Use()`, is replaced.
+		delete(e.implicitRoutes, method+path)
+	} else if _, ok := e.router.routes[method+path]; ok && (cr == nil || cr.fallback != nil) {
+		panic(fmt.Sprintf("leego ⇛ route %s %s already registered", method, path))
+	}
+	if cr != nil {
+		// The route is dispatched by predicate, see `When()`.
+		cr.fallback = chain(handler, middleware)
+	} else {
+		e.router.Add(method, path, chain(handler, middleware), e)
+	}
+	r := &Route{
+		Method:  method,
+		Path:    path,
+		Handler: name,
+	}
+	e.router.setMiddleware(method+path, middleware)
+
+	e.router.routes[method+path] = r
+	return r
+}
+
+// chain returns a handler which runs the middleware around handler.
+func chain(handler HandlerFunc, middleware []MiddlewareFunc) HandlerFunc {
+	return func(c Context) LeeError {
 		h := handler
 		// Chain middleware
 		for i := len(middleware) - 1; i >= 0; i-- {
 			h = middleware[i](h)
 		}
 		return h(c)
-	}, e)
-	r := Route{
-		Method:  method,
-		Path:    path,
-		Handler: name,
 	}
-
-	e.router.routes[method+path] = r
 }
 
 // Logger returns the logger instance.
@@ -451,41 +769,186 @@ func (e *Leego) SetLogger(l *logger.Logger) {
 }
 
 func (e *Leego) ServeHTTP(req engine.Request, res engine.Response) {
-	c := e.pool.Get().(*leegoContext)
-	c.Reset(req, res)
-	c.SetLang(req.Header().Get("Accept-Language"))
-
-	// Middleware
-	h := func(Context) LeeError {
-		method := req.Method()
-		path := req.URL().Path()
-		e.router.Find(method, path, c)
-		h := c.handler
-		for i := len(e.middleware) - 1; i >= 0; i-- {
-			h = e.middleware[i](h)
-		}
-		return h(c)
+	if e.fastPath && e.serveNotFound(req, res) {
+		return
 	}
 
+	c := e.AcquireContext()
+	c.Reset(req, res)
+	c.SetLang(c.AcceptsLanguage())
+
 	// Premiddleware
+	h := e.dispatch
 	for i := len(e.premiddleware) - 1; i >= 0; i-- {
 		h = e.premiddleware[i](h)
 	}
 
 	// Execute chain
-	err := h(c)
-	e.ResponseHandler(err, c)
+	e.serve(c, h)
+
+	e.ReleaseContext(c)
+}
+
+// AcquireContext returns an empty `Context` instance from the pool. It must be
+// reset with `Context#Reset()` before use and returned with
+// `Leego#ReleaseContext()` once the request completes.
+func (e *Leego) AcquireContext() Context {
+	return e.pool.Get().(Context)
+}
 
+// ReleaseContext returns the `Context` instance back to the pool. It must not be
+// used afterwards.
+func (e *Leego) ReleaseContext(c Context) {
 	e.pool.Put(c)
 }
 
+// route finds the handler of the request and runs it with the middleware. It's
+// set to `dispatch` once, so serving a request doesn't allocate a closure.
+func (e *Leego) route(c Context) LeeError {
+	req := c.Request()
+	method := req.Method()
+	path := req.URL().Path()
+	if e.debug && e.routeTrace {
+		var trace []string
+		e.router.lookup(method, path, c, &trace)
+		c.Response().Header().Set(HeaderXRouteTrace, strings.Join(trace, "; "))
+	} else {
+		e.router.Find(method, path, c)
+	}
+	h := c.Handler()
+	for i := len(e.middleware) - 1; i >= 0; i-- {
+		h = e.middleware[i](h)
+	}
+	return h(c)
+}
+
+// serve runs the chain h followed by the response handler and the finalizers.
+func (e *Leego) serve(c Context, h HandlerFunc) {
+	if len(e.finalizers) == 0 {
+		e.ResponseHandler(h(c), c)
+		return
+	}
+	var err LeeError
+	defer e.finalize(c, &err)
+	err = h(c)
+	e.ResponseHandler(err, c)
+}
+
+// finalize runs the finalizers. On panic they get an internal server error and
+// the panic is resumed, so it's handled as if there were no finalizers.
+func (e *Leego) finalize(c Context, err *LeeError) {
+	if r := recover(); r != nil {
+		perr := NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("panic: %v", r))
+		for _, f := range e.finalizers {
+			f(c, perr)
+		}
+		panic(r)
+	}
+	for _, f := range e.finalizers {
+		f(c, *err)
+	}
+}
+
+var (
+	notFoundBody         = []byte(http.StatusText(http.StatusNotFound))
+	methodNotAllowedBody = []byte(http.StatusText(http.StatusMethodNotAllowed))
+)
+
+// serveNotFound answers the request if it matches no route and reports whether
+// it did. See `Leego#SetNotFoundFastPath()`.
+func (e *Leego) serveNotFound(req engine.Request, res engine.Response) bool {
+	pv, _ := e.pvaluesPool.Get().(*[]string)
+	if pv == nil || len(*pv) < *e.maxParam {
+		v := make([]string, *e.maxParam)
+		pv = &v
+	}
+	cn, h, code := e.router.find(req.Method(), req.URL().Path(), *pv, nil)
+	e.pvaluesPool.Put(pv)
+	if code == http.StatusOK {
+		return false
+	}
+	if code == http.StatusMethodNotAllowed {
+		if req.Method() == OPTIONS && e.router.autoOptions {
+			return false
+		}
+		res.Header().Set(HeaderAllow, e.router.allow(cn))
+	}
+	ch, custom := e.errorHandler(req.URL().Path(), code)
+	if custom {
+		h = ch
+	}
+
+	if len(e.fastPathMiddleware) == 0 && !custom && len(e.finalizers) == 0 {
+		body := notFoundBody
+		if code == http.StatusMethodNotAllowed {
+			body = methodNotAllowedBody
+		}
+		res.Header().Set(HeaderContentType, MIMETextPlainCharsetUTF8)
+		res.WriteHeader(code)
+		if req.Method() != HEAD {
+			res.Write(body)
+		}
+		return true
+	}
+
+	c := e.AcquireContext()
+	c.Reset(req, res)
+	c.SetHandler(h)
+	for i := len(e.fastPathMiddleware) - 1; i >= 0; i-- {
+		h = e.fastPathMiddleware[i](h)
+	}
+	e.serve(c, h)
+	e.ReleaseContext(c)
+	return true
+}
+
 // Run starts the HTTP server.
 func (e *Leego) Run(s engine.Server) {
 	s.SetLogger(e.logger)
 	s.SetHandler(e)
+	e.serverMu.Lock()
+	e.server = s
+	e.serverMu.Unlock()
 	s.Start()
 }
 
+// Shutdown gracefully shuts down the server started with `Leego#Run()`: it stops
+// accepting connections, waits for the in-flight requests and then for the
+// delivery of the published events, until ctx is done.
+func (e *Leego) Shutdown(ctx context.Context) error {
+	e.serverMu.Lock()
+	s := e.server
+	e.serverMu.Unlock()
+	if s != nil {
+		if err := s.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+	return e.events.Close(ctx)
+}
+
+// Static registers a new route with path prefix to serve static files from the
+// provided root directory.
+func (e *Leego) Static(prefix, root string) {
+	e.GET(prefix+"*", staticHandler(root))
+}
+
+// File registers a new route with path to serve a static file.
+func (e *Leego) File(path, file string) {
+	e.GET(path, func(c Context) LeeError {
+		return c.File(file)
+	})
+}
+
+// staticHandler serves the file named by the any path parameter from root. The
+// name is cleaned as an absolute path first, so it can't escape root.
+func staticHandler(root string) HandlerFunc {
+	return func(c Context) LeeError {
+		name := filepath.FromSlash(path.Clean("/" + c.P(0)))
+		return c.File(filepath.Join(root, name))
+	}
+}
+
 // Group creates a new router group with prefix and optional group-level middleware.
 func (e *Leego) Group(prefix string, m ...MiddlewareFunc) (g *Group) {
 	g = &Group{prefix: prefix, leego: e}
@@ -493,26 +956,72 @@ func (e *Leego) Group(prefix string, m ...MiddlewareFunc) (g *Group) {
 	return
 }
 
+// Routes returns the registered routes with their middleware, sorted by path
+// and method.
+func (e *Leego) Routes() []RouteInfo {
+	routes := make([]RouteInfo, 0, len(e.router.routes))
+	for key, r := range e.router.routes {
+		routes = append(routes, RouteInfo{Route: *r, Middleware: e.router.middleware[key], Meta: e.router.meta[key]})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// RoutesHandler returns a handler rendering `Leego#Routes()` as JSON, e.g. for
+// a debug endpoint:
+//
+//	lee.GET("/debug/routes", lee.RoutesHandler(), middleware.BasicAuth(v))
+func (e *Leego) RoutesHandler() HandlerFunc {
+	return func(c Context) LeeError {
+		return c.JSON(http.StatusOK, e.Routes())
+	}
+}
+
 // URI generates a URI from handler.
 func (e *Leego) URI(handler HandlerFunc, params ...interface{}) string {
-	uri := new(bytes.Buffer)
-	ln := len(params)
-	n := 0
 	name := handlerName(handler)
 	for _, r := range e.router.routes {
 		if r.Handler == name {
-			for i, l := 0, len(r.Path); i < l; i++ {
-				if r.Path[i] == ':' && n < ln {
-					for ; i < l && r.Path[i] != '/'; i++ {
-					}
-					uri.WriteString(fmt.Sprintf("%v", params[n]))
-					n++
-				}
-				if i < l {
-					uri.WriteByte(r.Path[i])
-				}
+			return reverse(r.Path, params)
+		}
+	}
+	return ""
+}
+
+// Reverse generates a URL from the route named name, replacing its path params
+// with params in order, e.g. for a route "/users/:id" named "user.show":
+//
+//	lee.Reverse("user.show", 1) // "/users/1"
+//
+// It returns "" if there is no such route.
+func (e *Leego) Reverse(name string, params ...interface{}) string {
+	for _, r := range e.router.routes {
+		if r.Name == name {
+			return reverse(r.Path, params)
+		}
+	}
+	return ""
+}
+
+// reverse replaces the path params of path with params in order.
+func reverse(path string, params []interface{}) string {
+	uri := new(bytes.Buffer)
+	ln := len(params)
+	n := 0
+	for i, l := 0, len(path); i < l; i++ {
+		if path[i] == ':' && n < ln {
+			for ; i < l && path[i] != '/'; i++ {
 			}
-			break
+			uri.WriteString(fmt.Sprintf("%v", params[n]))
+			n++
+		}
+		if i < l {
+			uri.WriteByte(path[i])
 		}
 	}
 	return uri.String()
@@ -523,6 +1032,49 @@ func (e *Leego) URL(h HandlerFunc, params ...interface{}) string {
 	return e.URI(h, params...)
 }
 
+// Handler converts h into a `HandlerFunc` at registration, easing the
+// migration of handlers from other frameworks. h is one of:
+//
+//	func(Context) LeeError
+//	func(Context) error
+//	func(Context) (interface{}, error)
+//
+// The value of the latter is sent with `Context#Negotiate()`, JSON by
+// default, or 204 if nil. It panics for other types. The route handler name
+// is the one of the adapter, so name the routes to reverse them, see
+// `Leego#Reverse()`.
+//
+//	lee.GET("/users/:id", leego.Handler(func(c leego.Context) (interface{}, error) {
+//		return db.User(c.Param("id"))
+//	}))
+func Handler(h interface{}) HandlerFunc {
+	switch h := h.(type) {
+	case HandlerFunc:
+		return h
+	case func(Context) LeeError:
+		return h
+	case func(Context) error:
+		return func(c Context) LeeError {
+			if err := h(c); err != nil {
+				return err
+			}
+			return nil
+		}
+	case func(Context) (interface{}, error):
+		return func(c Context) LeeError {
+			v, err := h(c)
+			if err != nil {
+				return err
+			}
+			if v == nil {
+				return c.NoContent(http.StatusNoContent)
+			}
+			return c.Negotiate(http.StatusOK, v)
+		}
+	}
+	panic(fmt.Sprintf("leego ⇛ unsupported handler type %T", h))
+}
+
 // WrapMiddleware wrap `leego.HandlerFunc` into `leego.MiddlewareFunc`.
 func WrapMiddleware(h HandlerFunc) MiddlewareFunc {
 	return func(next HandlerFunc) HandlerFunc {
@@ -536,9 +1088,13 @@ func WrapMiddleware(h HandlerFunc) MiddlewareFunc {
 }
 
 func handlerName(h HandlerFunc) string {
-	t := reflect.ValueOf(h).Type()
+	return funcName(h)
+}
+
+func funcName(f interface{}) string {
+	t := reflect.ValueOf(f).Type()
 	if t.Kind() == reflect.Func {
-		return runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
+		return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
 	}
 	return t.String()
 }
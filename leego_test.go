@@ -0,0 +1,819 @@
+package leego_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestNotFoundFastPath(t *testing.T) {
+	lee := leego.New()
+	called := 0
+	lee.Use(func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			called++
+			return next(c)
+		}
+	})
+	lee.GET("/users/:id", func(c leego.Context) leego.LeeError {
+		return c.String(http.StatusOK, c.Param("id"))
+	})
+	lee.SetNotFoundFastPath(true)
+
+	rec := httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, "/files", nil)), standard.NewResponse(rec))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, 0, called)
+
+	rec = httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.POST, "/users/1", nil)), standard.NewResponse(rec))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	rec = httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, "/users/1", nil)), standard.NewResponse(rec))
+	assert.Equal(t, "1", rec.Body.String())
+	assert.Equal(t, 1, called)
+
+	// Selected middleware
+	logged := 0
+	lee.SetNotFoundFastPath(true, func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			logged++
+			return next(c)
+		}
+	})
+	rec = httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, "/files", nil)), standard.NewResponse(rec))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, 1, logged)
+	assert.Equal(t, 1, called)
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	lee := leego.New()
+	h := func(c leego.Context) leego.LeeError {
+		return c.NoContent(http.StatusOK)
+	}
+	lee.GET("/users/:id", h)
+	lee.PUT("/users/:id", h)
+	lee.Add("PROPFIND", "/users/:id", h)
+	request := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(method, path, nil)), standard.NewResponse(rec))
+		return rec
+	}
+
+	rec := request(leego.POST, "/users/1")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "GET, PUT, PROPFIND", rec.Header().Get(leego.HeaderAllow))
+	rec = request(leego.POST, "/files")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Empty(t, rec.Header().Get(leego.HeaderAllow))
+
+	lee.SetMethodNotAllowedHandler(func(c leego.Context) leego.LeeError {
+		return c.JSON(http.StatusMethodNotAllowed, map[string]string{"allow": c.Response().Header().Get(leego.HeaderAllow)})
+	})
+	rec = request(leego.DELETE, "/users/1")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, `{"allow":"GET, PUT, PROPFIND"}`, rec.Body.String())
+
+	// Fast path
+	lee.SetNotFoundFastPath(true)
+	rec = request(leego.DELETE, "/users/1")
+	assert.Equal(t, `{"allow":"GET, PUT, PROPFIND"}`, rec.Body.String())
+	lee.SetMethodNotAllowedHandler(nil)
+	rec = request(leego.DELETE, "/users/1")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "GET, PUT, PROPFIND", rec.Header().Get(leego.HeaderAllow))
+}
+
+func TestAutoOptions(t *testing.T) {
+	lee := leego.New()
+	h := func(c leego.Context) leego.LeeError {
+		return c.NoContent(http.StatusOK)
+	}
+	lee.GET("/users/:id", h)
+	lee.DELETE("/users/:id", h)
+	lee.OPTIONS("/files", func(c leego.Context) leego.LeeError {
+		return c.String(http.StatusOK, "files")
+	})
+	request := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(method, path, nil)), standard.NewResponse(rec))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusMethodNotAllowed, request(leego.OPTIONS, "/users/1").Code)
+
+	lee.SetAutoOptions(true)
+	for _, fastPath := range []bool{false, true} {
+		lee.SetNotFoundFastPath(fastPath)
+		rec := request(leego.OPTIONS, "/users/1")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, "DELETE, GET, OPTIONS", rec.Header().Get(leego.HeaderAllow))
+		rec = request(leego.POST, "/users/1")
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.Equal(t, "DELETE, GET, OPTIONS", rec.Header().Get(leego.HeaderAllow))
+		assert.Equal(t, "files", request(leego.OPTIONS, "/files").Body.String())
+		assert.Equal(t, http.StatusNotFound, request(leego.OPTIONS, "/orders").Code)
+	}
+}
+
+type templateRenderer struct {
+	templates *template.Template
+}
+
+func (t *templateRenderer) Render(w io.Writer, name string, data interface{}, c leego.Context) error {
+	return t.templates.ExecuteTemplate(w, name, data)
+}
+
+func TestContextRender(t *testing.T) {
+	lee := leego.New()
+	rec := httptest.NewRecorder()
+	c := lee.NewContext(standard.NewRequest(httptest.NewRequest(leego.GET, "/", nil)), standard.NewResponse(rec))
+	assert.Equal(t, leego.ErrRendererNotRegistered, c.Render(http.StatusOK, "hello", "Joe"))
+
+	lee.SetRenderer(&templateRenderer{
+		templates: template.Must(template.New("hello").Parse("Hello, {{.}}!")),
+	})
+	assert.NoError(t, c.Render(http.StatusOK, "hello", "Joe"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, leego.MIMETextHTMLCharsetUTF8, rec.Header().Get(leego.HeaderContentType))
+	assert.Equal(t, "Hello, Joe!", rec.Body.String())
+}
+
+func TestContextStream(t *testing.T) {
+	lee := leego.New()
+	rec := httptest.NewRecorder()
+	c := lee.NewContext(standard.NewRequest(httptest.NewRequest(leego.GET, "/", nil)), standard.NewResponse(rec))
+	assert.NoError(t, c.Stream(http.StatusOK, "text/event-stream", strings.NewReader("data: 1\n\ndata: 2\n\n")))
+	assert.Equal(t, "text/event-stream", rec.Header().Get(leego.HeaderContentType))
+	assert.Equal(t, "data: 1\n\ndata: 2\n\n", rec.Body.String())
+	assert.True(t, rec.Flushed)
+}
+
+func TestContextForwarded(t *testing.T) {
+	lee := leego.New()
+	req := httptest.NewRequest(leego.GET, "/", nil)
+	req.RemoteAddr = "10.0.0.2:1234"
+	req.Header.Set(leego.HeaderXForwardedFor, "203.0.113.7, 198.51.100.1, 10.0.0.1")
+	req.Header.Set(leego.HeaderXForwardedProto, "https")
+	req.Header.Set(leego.HeaderXForwardedHost, "example.com")
+
+	// Untrusted
+	c := lee.NewContext(standard.NewRequest(req), standard.NewResponse(httptest.NewRecorder()))
+	f := c.Forwarded()
+	assert.Equal(t, "10.0.0.2", f.For)
+	assert.Equal(t, "http", f.Proto)
+	assert.Equal(t, "example.com", f.Host)
+
+	// X-Forwarded-*
+	lee.SetTrustedProxyDepth(2)
+	c = lee.NewContext(standard.NewRequest(req), standard.NewResponse(httptest.NewRecorder()))
+	f = c.Forwarded()
+	assert.Equal(t, "198.51.100.1", f.For)
+	assert.Equal(t, "https", f.Proto)
+	assert.Equal(t, "example.com", f.Host)
+
+	// Forwarded takes precedence
+	req.Header.Set(leego.HeaderForwarded, `for=192.0.2.60;proto=HTTPS;host="api.example.com", for="[2001:db8::1]:4711";by=10.0.0.1`)
+	c = lee.NewContext(standard.NewRequest(req), standard.NewResponse(httptest.NewRecorder()))
+	f = c.Forwarded()
+	assert.Equal(t, "192.0.2.60", f.For)
+	assert.Equal(t, "https", f.Proto)
+	assert.Equal(t, "api.example.com", f.Host)
+
+	lee.SetTrustedProxyDepth(1)
+	c = lee.NewContext(standard.NewRequest(req), standard.NewResponse(httptest.NewRecorder()))
+	f = c.Forwarded()
+	assert.Equal(t, "2001:db8::1", f.For)
+	assert.Equal(t, "10.0.0.1", f.By)
+}
+
+func TestContextRealIP(t *testing.T) {
+	lee := leego.New()
+	realIP := func(remoteAddr string, header map[string]string) string {
+		req := httptest.NewRequest(leego.GET, "/", nil)
+		req.RemoteAddr = remoteAddr
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		return lee.NewContext(standard.NewRequest(req), standard.NewResponse(httptest.NewRecorder())).RealIP()
+	}
+	xff := map[string]string{leego.HeaderXForwardedFor: "203.0.113.7, 198.51.100.1, 10.0.0.1"}
+
+	assert.Equal(t, "10.0.0.2", realIP("10.0.0.2:1234", xff))
+	assert.Error(t, lee.SetTrustedProxies("10.0.0.0/33"))
+	assert.Error(t, lee.SetTrustedProxies("proxy"))
+	assert.NoError(t, lee.SetTrustedProxies("10.0.0.0/8", "198.51.100.1", "2001:db8::/32"))
+
+	// Untrusted peer
+	assert.Equal(t, "192.0.2.1", realIP("192.0.2.1:1234", xff))
+	assert.Equal(t, "192.0.2.1", realIP("192.0.2.1:1234", map[string]string{leego.HeaderXRealIP: "203.0.113.7"}))
+
+	// Trusted hops
+	assert.Equal(t, "203.0.113.7", realIP("10.0.0.2:1234", xff))
+	assert.Equal(t, "203.0.113.7", realIP("10.0.0.2:1234", map[string]string{leego.HeaderXRealIP: "203.0.113.7"}))
+	assert.Equal(t, "192.0.2.9", realIP("10.0.0.2:1234", map[string]string{
+		leego.HeaderXForwardedFor: "203.0.113.7, 192.0.2.9, 10.0.0.1",
+	}))
+	assert.Equal(t, "192.0.2.60", realIP("[2001:db8::2]:1234", map[string]string{
+		leego.HeaderForwarded: `for=192.0.2.60, for="[2001:db8::1]:4711"`,
+	}))
+}
+
+func TestWhen(t *testing.T) {
+	lee := leego.New()
+	h := func(v string) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			return c.String(http.StatusOK, v)
+		}
+	}
+	lee.GET("/users", h("v1"))
+	lee.When(leego.Header("X-API-Version", "2"), leego.GET, "/users", h("v2"))
+	lee.When(leego.All(leego.Query("format", "csv"), leego.Header("X-API-Version", "3")), leego.GET, "/users", h("v3-csv"))
+	lee.When(leego.Query("beta", "1"), leego.GET, "/beta", h("beta"))
+	lee.When(leego.Header("X-API-Version", "2"), leego.GET, "/items", h("items-v2"))
+	lee.GET("/items", h("items"))
+
+	serve := func(path, version string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(leego.GET, path, nil)
+		if version != "" {
+			req.Header.Set("X-API-Version", version)
+		}
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		return rec
+	}
+
+	assert.Equal(t, "v1", serve("/users", "").Body.String())
+	assert.Equal(t, "v2", serve("/users", "2").Body.String())
+	assert.Equal(t, "v3-csv", serve("/users?format=csv", "3").Body.String())
+	assert.Equal(t, "v1", serve("/users", "3").Body.String())
+	assert.Equal(t, "beta", serve("/beta?beta=1", "").Body.String())
+	assert.Equal(t, http.StatusNotFound, serve("/beta", "").Code)
+	assert.Equal(t, "items", serve("/items", "").Body.String())
+	assert.Equal(t, "items-v2", serve("/items", "2").Body.String())
+}
+
+func TestStatic(t *testing.T) {
+	lee := leego.New()
+	lee.Static("/static", "engine")
+	lee.File("/readme", "engine/engine.go")
+	lee.Group("/assets").Static("", "engine/standard")
+
+	for path, code := range map[string]int{
+		"/static/engine.go":       http.StatusOK,
+		"/static/../leego.go":     http.StatusNotFound,
+		"/static/standard/url.go": http.StatusOK,
+		"/readme":                 http.StatusOK,
+		"/assets/url.go":          http.StatusOK,
+		"/assets/../../leego.go":  http.StatusNotFound,
+		"/static/missing.go":      http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, path, nil)), standard.NewResponse(rec))
+		assert.Equal(t, code, rec.Code)
+	}
+}
+
+func TestFinally(t *testing.T) {
+	lee := leego.New()
+	var (
+		calls []string
+		codes []int
+	)
+	lee.Finally(func(c leego.Context, err leego.LeeError) {
+		calls = append(calls, c.Request().URL().Path())
+		code := c.Response().Status()
+		if he, ok := err.(*leego.HTTPError); ok {
+			code = he.Code
+		}
+		codes = append(codes, code)
+	})
+	lee.Use(func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if c.Request().URL().Path() == "/blocked" {
+				// Doesn't call next
+				return c.NoContent(http.StatusForbidden)
+			}
+			return next(c)
+		}
+	})
+	lee.GET("/ok", func(c leego.Context) leego.LeeError {
+		return c.String(http.StatusOK, "ok")
+	})
+	lee.GET("/panic", func(c leego.Context) leego.LeeError {
+		panic("boom")
+	})
+
+	serve := func(path string) {
+		lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, path, nil)), standard.NewResponse(httptest.NewRecorder()))
+	}
+	serve("/ok")
+	serve("/blocked")
+	serve("/missing")
+	assert.Panics(t, func() { serve("/panic") })
+
+	lee.SetNotFoundFastPath(true)
+	serve("/missing")
+
+	assert.Equal(t, []string{"/ok", "/blocked", "/missing", "/panic", "/missing"}, calls)
+	assert.Equal(t, []int{http.StatusOK, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError, http.StatusNotFound}, codes)
+}
+
+func TestReverse(t *testing.T) {
+	lee := leego.New()
+	h := func(c leego.Context) leego.LeeError {
+		return c.String(http.StatusOK, c.Reverse("user.show", c.Param("id")))
+	}
+	lee.GET("/users/:id", h).Name = "user.show"
+	g := lee.Group("/admin")
+	g.GET("/users/:id/files/:file", h).Name = "admin.file"
+
+	assert.Equal(t, "/users/1", lee.Reverse("user.show", 1))
+	assert.Equal(t, "/admin/users/1/files/a.txt", lee.Reverse("admin.file", 1, "a.txt"))
+	assert.Equal(t, "", lee.Reverse("missing"))
+
+	rec := httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, "/users/2", nil)), standard.NewResponse(rec))
+	assert.Equal(t, "/users/2", rec.Body.String())
+}
+
+func TestAcceptAsync(t *testing.T) {
+	lee := leego.New()
+	lee.Operations("/operations", nil)
+	release := make(chan struct{})
+	lee.POST("/reports", func(c leego.Context) leego.LeeError {
+		_, err := c.AcceptAsync(func(ctx context.Context, p *leego.Progress) (interface{}, error) {
+			p.Update(50, "halfway")
+			<-release
+			return "report.pdf", nil
+		})
+		return err
+	})
+
+	rec := httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.POST, "/reports", nil)), standard.NewResponse(rec))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	url := rec.Header().Get(leego.HeaderLocation)
+	assert.True(t, strings.HasPrefix(url, "/operations/"))
+
+	get := func() *leego.Operation {
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, url, nil)), standard.NewResponse(rec))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		op := new(leego.Operation)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), op))
+		return op
+	}
+	op := get()
+	for op.Progress != 50 {
+		time.Sleep(time.Millisecond)
+		op = get()
+	}
+	assert.Equal(t, leego.OperationRunning, op.Status)
+	assert.Equal(t, "halfway", op.Message)
+
+	close(release)
+	for op.Status == leego.OperationRunning {
+		time.Sleep(time.Millisecond)
+		op = get()
+	}
+	assert.Equal(t, leego.OperationSucceeded, op.Status)
+	assert.Equal(t, "report.pdf", op.Result)
+
+	rec = httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, "/operations/unknown", nil)), standard.NewResponse(rec))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestRoutes(t *testing.T) {
+	lee := leego.New()
+	h := func(c leego.Context) leego.LeeError {
+		return c.NoContent(http.StatusOK)
+	}
+	m := func(next leego.HandlerFunc) leego.HandlerFunc {
+		return next
+	}
+	lee.POST("/users", h)
+	lee.GET("/users", h).Name = "user.list"
+	lee.Group("/admin").DELETE("/users/:id", h, m)
+	lee.GET("/debug/routes", lee.RoutesHandler())
+
+	routes := lee.Routes()
+	if assert.Len(t, routes, 4) {
+		assert.Equal(t, "/admin/users/:id", routes[0].Path)
+		assert.Len(t, routes[0].Middleware, 1)
+		assert.Equal(t, leego.GET, routes[2].Method)
+		assert.Equal(t, "user.list", routes[2].Name)
+		assert.Equal(t, leego.POST, routes[3].Method)
+		assert.True(t, strings.HasPrefix(routes[3].Handler, "github.com/go-wyvern/leego_test.TestRoutes"))
+	}
+
+	rec := httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, "/debug/routes", nil)), standard.NewResponse(rec))
+	var got []leego.RouteInfo
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, routes, got)
+}
+
+func TestContextJSONWithETag(t *testing.T) {
+	lee := leego.New()
+	users := []map[string]string{{"name": "Jon", "email": "jon@example.com"}}
+	lee.GET("/users", func(c leego.Context) leego.LeeError {
+		return c.JSONWithETag(http.StatusOK, users)
+	})
+	etag, err := leego.ETag(users)
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, "/users", nil)), standard.NewResponse(rec))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, etag, rec.Header().Get(leego.HeaderETag))
+	assert.Contains(t, rec.Body.String(), "jon@example.com")
+
+	for _, inm := range []string{etag, `"other", W/` + etag, "*"} {
+		req := httptest.NewRequest(leego.GET, "/users", nil)
+		req.Header.Set(leego.HeaderIfNoneMatch, inm)
+		rec = httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+		assert.Equal(t, 0, rec.Body.Len())
+	}
+
+	users[0]["name"] = "Joe"
+	req := httptest.NewRequest(leego.GET, "/users", nil)
+	req.Header.Set(leego.HeaderIfNoneMatch, etag)
+	rec = httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEqual(t, etag, rec.Header().Get(leego.HeaderETag))
+}
+
+func TestCaptureBody(t *testing.T) {
+	lee := leego.New()
+	var body string
+	lee.GET("/", func(c leego.Context) leego.LeeError {
+		err := c.JSON(http.StatusOK, map[string]int{"a": 1})
+		body = c.Response().Body()
+		return err
+	})
+
+	rec := httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, "/", nil)), standard.NewResponse(rec))
+	assert.Equal(t, `{"a":1}`, rec.Body.String())
+	assert.Equal(t, "", body)
+
+	lee.SetCaptureBody(true)
+	assert.True(t, lee.CaptureBody())
+	rec = httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, "/", nil)), standard.NewResponse(rec))
+	assert.Equal(t, `{"a":1}`, body)
+}
+
+func BenchmarkServeHTTP(b *testing.B) {
+	lee := leego.New()
+	h := func(c leego.Context) leego.LeeError {
+		return nil
+	}
+	lee.GET("/users", h)
+	lee.GET("/users/:id/files/:name", func(c leego.Context) leego.LeeError {
+		c.Param("name")
+		return nil
+	})
+	for _, path := range []string{"/users", "/users/1/files/a.txt"} {
+		req := standard.NewRequest(httptest.NewRequest(leego.GET, path, nil))
+		res := standard.NewResponse(httptest.NewRecorder())
+		b.Run(path, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				lee.ServeHTTP(req, res)
+			}
+		})
+	}
+}
+
+func TestContextNegotiate(t *testing.T) {
+	lee := leego.New()
+	context := func(accept, lang string) leego.Context {
+		req := httptest.NewRequest(leego.GET, "/", nil)
+		req.Header.Set(leego.HeaderAccept, accept)
+		req.Header.Set(leego.HeaderAcceptLanguage, lang)
+		return lee.NewContext(standard.NewRequest(req), standard.NewResponse(httptest.NewRecorder()))
+	}
+
+	for accept, offer := range map[string]string{
+		"":                                    "application/json",
+		"application/xml":                     "application/xml",
+		"text/*;q=0.5, application/xml;q=0.4": "text/html",
+		"*/*;q=0.1, application/xml":          "application/xml",
+		"text/html;level=1;q=0, */*":          "application/json",
+		"TEXT/HTML":                           "text/html",
+		"image/png":                           "",
+		"application/json;q=x":                "",
+	} {
+		assert.Equal(t, offer, context(accept, "").Accepts("application/json", "text/html", "application/xml"), accept)
+	}
+
+	for lang, offer := range map[string]string{
+		"":                          "en",
+		"fr-CH, fr;q=0.9, en;q=0.8": "fr",
+		"en-GB":                     "en",
+		"de, *;q=0.5":               "en",
+		"de":                        "",
+		"EN-us, fr":                 "en-US",
+	} {
+		assert.Equal(t, offer, context("", lang).AcceptsLanguage("en", "fr", "en-US"), lang)
+	}
+	assert.Equal(t, "fr", context("", "en;q=0.8, fr, *").AcceptsLanguage())
+
+	// Language of the request
+	lee.GET("/", func(c leego.Context) leego.LeeError {
+		return c.String(http.StatusOK, c.Language())
+	})
+	for lang, expected := range map[string]string{"": "zh-CN", "en-US,en;q=0.9": "en-US", "de": "de"} {
+		req := httptest.NewRequest(leego.GET, "/", nil)
+		req.Header.Set(leego.HeaderAcceptLanguage, lang)
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		assert.Equal(t, expected, rec.Body.String())
+	}
+
+	user := struct {
+		XMLName struct{} `json:"-" xml:"user"`
+		Name    string   `json:"name" xml:"name"`
+	}{Name: "Jon"}
+	for accept, body := range map[string]string{
+		"application/json": `{"name":"Jon"}`,
+		"application/xml":  `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<user><name>Jon</name></user>`,
+	} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(leego.GET, "/", nil)
+		req.Header.Set(leego.HeaderAccept, accept)
+		c := lee.NewContext(standard.NewRequest(req), standard.NewResponse(rec))
+		assert.NoError(t, c.Negotiate(http.StatusOK, user))
+		assert.Equal(t, body, rec.Body.String())
+	}
+	assert.Equal(t, leego.ErrNotAcceptable, context("image/png", "").Negotiate(http.StatusOK, user))
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(leego.GET, "/", nil)
+	req.Header.Set(leego.HeaderAccept, "text/html")
+	c := lee.NewContext(standard.NewRequest(req), standard.NewResponse(rec))
+	assert.NoError(t, c.Negotiate(http.StatusOK, "<p>Jon</p>", leego.MIMETextHTML))
+	assert.Equal(t, "<p>Jon</p>", rec.Body.String())
+}
+
+func TestHTTPError(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := leego.ErrNotFound.SetInternal(cause).WithDetail("id", "42")
+	assert.Nil(t, leego.ErrNotFound.Internal)
+	assert.Nil(t, leego.ErrNotFound.Details)
+	assert.True(t, errors.Is(err, leego.ErrNotFound))
+	assert.True(t, errors.Is(fmt.Errorf("loading user: %w", err), cause))
+	var he *leego.HTTPError
+	assert.True(t, errors.As(fmt.Errorf("loading user: %w", err), &he))
+	assert.Equal(t, "Not Found: connection refused", he.Error())
+
+	lee := leego.New()
+	lee.GET("/users/:id", func(c leego.Context) leego.LeeError {
+		return fmt.Errorf("loading user: %w", err)
+	})
+	lee.GET("/fail", func(c leego.Context) leego.LeeError {
+		return cause
+	})
+	request := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(leego.GET, path, nil)
+		req.Header.Set(leego.HeaderAccept, accept)
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		return rec
+	}
+
+	rec := request("/users/42", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "Not Found", rec.Body.String())
+	rec = request("/users/42", "application/json")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, `{"message":"Not Found","details":{"id":"42"}}`, rec.Body.String())
+	assert.Equal(t, "Internal Server Error", request("/fail", "").Body.String())
+
+	// Debug mode exposes internal errors
+	lee.SetDebug(true)
+	assert.Equal(t, `{"message":"loading user: Not Found: connection refused","details":{"id":"42"}}`, request("/users/42", "application/json").Body.String())
+	assert.Equal(t, "connection refused", request("/fail", "text/plain").Body.String())
+}
+
+func TestProblemHTTPErrorHandler(t *testing.T) {
+	lee := leego.New()
+	lee.SetHTTPErrorHandler(lee.ProblemHTTPErrorHandler)
+	lee.GET("/credit", func(c leego.Context) leego.LeeError {
+		return &leego.Problem{
+			Type:       "https://example.com/probs/out-of-credit",
+			Title:      "You do not have enough credit.",
+			Status:     http.StatusForbidden,
+			Extensions: map[string]interface{}{"balance": 30},
+		}
+	})
+	lee.GET("/users/:id", func(c leego.Context) leego.LeeError {
+		return leego.NewHTTPError(http.StatusNotFound, "no user 42").WithDetail("id", "42")
+	})
+	lee.GET("/fail", func(c leego.Context) leego.LeeError {
+		return errors.New("connection refused")
+	})
+	request := func(path string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, path, nil)), standard.NewResponse(rec))
+		var p map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &p)
+		return rec, p
+	}
+
+	rec, p := request("/credit")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, leego.MIMEApplicationProblemJSON, rec.Header().Get(leego.HeaderContentType))
+	assert.Equal(t, map[string]interface{}{
+		"type":     "https://example.com/probs/out-of-credit",
+		"title":    "You do not have enough credit.",
+		"status":   float64(403),
+		"instance": "/credit",
+		"balance":  float64(30),
+	}, p)
+
+	rec, p = request("/users/42")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, map[string]interface{}{
+		"type":     "about:blank",
+		"title":    "Not Found",
+		"status":   float64(404),
+		"detail":   "no user 42",
+		"instance": "/users/42",
+		"id":       "42",
+	}, p)
+
+	_, p = request("/fail")
+	assert.Equal(t, "Internal Server Error", p["title"])
+	assert.Nil(t, p["detail"])
+	lee.SetDebug(true)
+	_, p = request("/fail")
+	assert.Equal(t, "connection refused", p["detail"])
+}
+
+func TestContextStore(t *testing.T) {
+	type userKey struct{}
+	lee := leego.New()
+	c := lee.NewContext(standard.NewRequest(httptest.NewRequest(leego.GET, "/", nil)), standard.NewResponse(httptest.NewRecorder()))
+
+	c.Set(userKey{}, "joe")
+	c.Set("count", 3)
+	assert.Equal(t, "joe", c.Get(userKey{}))
+	assert.Equal(t, "joe", c.Value(userKey{}))
+	assert.Equal(t, "joe", c.GetString(userKey{}))
+	assert.Equal(t, 3, c.GetInt("count"))
+	assert.Equal(t, "", c.GetString("count"))
+	ctx := c.Context()
+	assert.Equal(t, "joe", ctx.Value(userKey{}))
+	c.Set("late", true)
+	assert.Equal(t, true, ctx.Value("late"))
+
+	// Values of the context
+	c.SetContext(context.WithValue(c.Context(), "trace", "abc"))
+	assert.Equal(t, "abc", c.MustGet("trace"))
+	assert.Panics(t, func() {
+		c.MustGet("missing")
+	})
+
+	c.Reset(standard.NewRequest(httptest.NewRequest(leego.GET, "/", nil)), standard.NewResponse(httptest.NewRecorder()))
+	assert.Nil(t, c.Get(userKey{}))
+	assert.Nil(t, c.Get("trace"))
+	// The context of the previous request keeps its values.
+	assert.Equal(t, "joe", ctx.Value(userKey{}))
+}
+
+func TestCatalog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "leego-catalog")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "en.json"), []byte(`{"hello": "Hello %s", "errors": {"auth": "Unauthorized"}}`), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "fr.toml"), []byte(`# French
+hello = "Bonjour %s" # greeting
+
+[errors]
+auth = 'Non autorisé'
+`), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "README.md"), nil, 0600)
+
+	cat := leego.NewCatalog("en")
+	if assert.NoError(t, cat.LoadDir(dir)) {
+		assert.Equal(t, []string{"en", "fr"}, cat.Languages())
+		assert.Equal(t, "Non autorisé", cat.Translate("fr", "errors.auth"))
+		assert.Equal(t, "Bonjour Joe", cat.Translate("fr-CA", "hello", "Joe"))
+		assert.Equal(t, "Hello Joe", cat.Translate("de", "hello", "Joe"))
+		assert.Equal(t, "missing", cat.Translate("fr", "missing"))
+	}
+	ioutil.WriteFile(filepath.Join(dir, "de.toml"), []byte(`hello = 1`), 0600)
+	assert.Error(t, cat.Load("de", filepath.Join(dir, "de.toml")))
+
+	lee := leego.New()
+	c := lee.NewContext(standard.NewRequest(httptest.NewRequest(leego.GET, "/", nil)), standard.NewResponse(httptest.NewRecorder()))
+	assert.Equal(t, "hello Joe", c.T("hello %s", "Joe"))
+	lee.SetCatalog(cat)
+	c.SetLang("")
+	assert.Equal(t, "en", c.Language())
+	c.SetLang("fr")
+	assert.Equal(t, "Bonjour Joe", c.T("hello", "Joe"))
+}
+
+func TestHandler(t *testing.T) {
+	lee := leego.New()
+	lee.GET("/lee", leego.Handler(func(c leego.Context) leego.LeeError {
+		return c.String(http.StatusOK, "lee")
+	}))
+	lee.GET("/error", leego.Handler(func(c leego.Context) error {
+		return leego.ErrUnauthorized
+	}))
+	lee.GET("/users/:id", leego.Handler(func(c leego.Context) (interface{}, error) {
+		if c.Param("id") == "0" {
+			return nil, nil
+		}
+		return map[string]string{"id": c.Param("id")}, nil
+	}))
+	request := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, path, nil)), standard.NewResponse(rec))
+		return rec
+	}
+
+	assert.Equal(t, "lee", request("/lee").Body.String())
+	assert.Equal(t, http.StatusUnauthorized, request("/error").Code)
+	rec := request("/users/1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"id":"1"}`, rec.Body.String())
+	assert.Equal(t, http.StatusNoContent, request("/users/0").Code)
+	assert.Panics(t, func() {
+		leego.Handler(func() {})
+	})
+}
+
+func listUsers(c leego.Context) leego.LeeError {
+	return c.String(http.StatusOK, "users")
+}
+
+func TestManifest(t *testing.T) {
+	auth := func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if c.Request().Header().Get(leego.HeaderAuthorization) == "" {
+				return leego.ErrUnauthorized
+			}
+			return next(c)
+		}
+	}
+	lee := leego.New()
+	lee.GET("/users", listUsers, auth).Name = "user.list"
+	lee.SetRouteMeta(leego.GET, "/users", map[string]string{"owner": "accounts", "version": "v2"})
+	m := lee.Manifest()
+	b, err := json.Marshal(m)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Contains(t, string(b), `"meta":{"owner":"accounts","version":"v2"}`)
+
+	var loaded leego.RouteManifest
+	assert.NoError(t, json.Unmarshal(b, &loaded))
+	registry := leego.RouteRegistry{
+		Handlers:   map[string]leego.HandlerFunc{m.Routes[0].Handler: listUsers},
+		Middleware: map[string]leego.MiddlewareFunc{m.Routes[0].Middleware[0]: auth},
+	}
+	lee2 := leego.New()
+	if assert.NoError(t, lee2.LoadManifest(loaded, registry)) {
+		assert.Equal(t, m, lee2.Manifest())
+		assert.Equal(t, "/users", lee2.Reverse("user.list"))
+		rec := httptest.NewRecorder()
+		lee2.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, "/users", nil)), standard.NewResponse(rec))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+
+	// Unknown names
+	lee3 := leego.New()
+	assert.Error(t, lee3.LoadManifest(loaded, leego.RouteRegistry{Handlers: registry.Handlers}))
+	assert.Empty(t, lee3.Routes())
+	loaded.Version = 2
+	assert.Error(t, lee3.LoadManifest(loaded, registry))
+}
@@ -0,0 +1,233 @@
+// Package mail sends emails from handlers, rendering their body with the
+// renderer registered with `Leego#SetRenderer()`.
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/logger"
+)
+
+type (
+	// Message is an email.
+	Message struct {
+		From    string
+		To      []string
+		Cc      []string
+		Bcc     []string
+		Subject string
+
+		// Text is the plain text body.
+		Text string
+
+		// HTML is the HTML body.
+		HTML string
+
+		// Headers are additional headers.
+		Headers map[string]string
+
+		// Metadata is correlation data, e.g. the request ID, which isn't sent
+		// but available to transports and written to the send logs.
+		Metadata map[string]string
+	}
+
+	// Transport sends messages, e.g. `SMTP` or an API provider wrapped in a
+	// `TransportFunc`.
+	Transport interface {
+		Send(*Message) error
+	}
+
+	// TransportFunc adapts a function to a `Transport`.
+	TransportFunc func(*Message) error
+
+	// SMTP is a `Transport` sending through an SMTP server.
+	SMTP struct {
+		// Addr is the address of the server, e.g. "smtp.example.com:587".
+		Addr string
+
+		// Auth authenticates with the server, if not nil.
+		Auth smtp.Auth
+	}
+
+	// Mailer sends messages with a transport.
+	Mailer struct {
+		// Transport sends the messages.
+		Transport Transport
+
+		// From is the sender of the messages without one.
+		From string
+
+		// Logger logs the sent messages with their metadata, if not nil.
+		Logger *logger.Logger
+	}
+)
+
+// Errors
+var (
+	ErrNoRecipient = errors.New("mail: no recipient")
+	ErrNoSender    = errors.New("mail: no sender")
+)
+
+// New returns a mailer sending with t.
+func New(t Transport) *Mailer {
+	return &Mailer{Transport: t}
+}
+
+// Send implements `Transport#Send` function.
+func (f TransportFunc) Send(m *Message) error {
+	return f(m)
+}
+
+// Send sends m.
+func (m *Mailer) Send(msg *Message) (err error) {
+	if msg.From == "" {
+		msg.From = m.From
+	}
+	if msg.From == "" {
+		return ErrNoSender
+	}
+	if len(msg.To)+len(msg.Cc)+len(msg.Bcc) == 0 {
+		return ErrNoRecipient
+	}
+	err = m.Transport.Send(msg)
+	if m.Logger != nil {
+		if err != nil {
+			m.Logger.Error("mail to=%v subject=%q %s error=%v", msg.To, msg.Subject, msg.metadata(), err)
+		} else {
+			m.Logger.Info("mail to=%v subject=%q %s sent", msg.To, msg.Subject, msg.metadata())
+		}
+	}
+	return
+}
+
+// SendTemplate renders the template name with data into the HTML body of msg
+// and sends it. The renderer gets the context, e.g. to pick the locale of the
+// request, and the request ID is added to the metadata.
+func (m *Mailer) SendTemplate(c leego.Context, msg *Message, name string, data interface{}) error {
+	r := c.Leego().Renderer()
+	if r == nil {
+		return leego.ErrRendererNotRegistered
+	}
+	buf := new(bytes.Buffer)
+	if err := r.Render(buf, name, data, c); err != nil {
+		return err
+	}
+	msg.HTML = buf.String()
+	if id := c.RequestID(); id != "" {
+		if msg.Metadata == nil {
+			msg.Metadata = make(map[string]string)
+		}
+		msg.Metadata["request_id"] = id
+	}
+	return m.Send(msg)
+}
+
+// metadata formats the metadata as sorted `key=value` pairs.
+func (msg *Message) metadata() string {
+	keys := make([]string, 0, len(msg.Metadata))
+	for k := range msg.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + msg.Metadata[k]
+	}
+	return strings.Join(pairs, " ")
+}
+
+// Bytes returns the message in the RFC 5322 format. Bcc isn't included.
+func (msg *Message) Bytes() []byte {
+	buf := new(bytes.Buffer)
+	h := textproto.MIMEHeader{}
+	h.Set("From", msg.From)
+	if len(msg.To) > 0 {
+		h.Set("To", strings.Join(msg.To, ", "))
+	}
+	if len(msg.Cc) > 0 {
+		h.Set("Cc", strings.Join(msg.Cc, ", "))
+	}
+	h.Set("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	h.Set("Date", time.Now().Format(time.RFC1123Z))
+	h.Set("MIME-Version", "1.0")
+	for k, v := range msg.Headers {
+		h.Set(k, v)
+	}
+
+	switch {
+	case msg.Text != "" && msg.HTML != "":
+		w := multipart.NewWriter(buf)
+		h.Set(leego.HeaderContentType, "multipart/alternative; boundary="+w.Boundary())
+		writeHeader(buf, h)
+		writePart(w, "text/plain", msg.Text)
+		writePart(w, "text/html", msg.HTML)
+		w.Close()
+		return buf.Bytes()
+	case msg.HTML != "":
+		h.Set(leego.HeaderContentType, leego.MIMETextHTMLCharsetUTF8)
+	default:
+		h.Set(leego.HeaderContentType, leego.MIMETextPlainCharsetUTF8)
+	}
+	h.Set("Content-Transfer-Encoding", "quoted-printable")
+	writeHeader(buf, h)
+	writeQuotedPrintable(buf, msg.Text+msg.HTML)
+	return buf.Bytes()
+}
+
+func writeHeader(w io.Writer, h textproto.MIMEHeader) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s: %s\r\n", k, h.Get(k))
+	}
+	io.WriteString(w, "\r\n")
+}
+
+func writePart(w *multipart.Writer, contentType, body string) {
+	h := textproto.MIMEHeader{}
+	h.Set(leego.HeaderContentType, contentType+"; charset=utf-8")
+	h.Set("Content-Transfer-Encoding", "quoted-printable")
+	p, _ := w.CreatePart(h)
+	writeQuotedPrintable(p, body)
+}
+
+func writeQuotedPrintable(w io.Writer, s string) {
+	qw := quotedprintable.NewWriter(w)
+	io.WriteString(qw, s)
+	qw.Close()
+}
+
+// Send implements `Transport#Send` function.
+func (s *SMTP) Send(m *Message) error {
+	from, err := netmail.ParseAddress(m.From)
+	if err != nil {
+		return err
+	}
+	var rcpt []string
+	for _, list := range [][]string{m.To, m.Cc, m.Bcc} {
+		for _, a := range list {
+			addr, err := netmail.ParseAddress(a)
+			if err != nil {
+				return err
+			}
+			rcpt = append(rcpt, addr.Address)
+		}
+	}
+	return smtp.SendMail(s.Addr, s.Auth, from.Address, rcpt, m.Bytes())
+}
@@ -0,0 +1,67 @@
+package mail_test
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/go-wyvern/leego/mail"
+	"github.com/stretchr/testify/assert"
+)
+
+type renderer struct{}
+
+func (renderer) Render(w io.Writer, name string, data interface{}, c leego.Context) error {
+	_, err := io.WriteString(w, "<p>"+name+" "+data.(string)+" "+c.QueryParam("lang")+"</p>")
+	return err
+}
+
+func TestMailer(t *testing.T) {
+	var sent *mail.Message
+	m := mail.New(mail.TransportFunc(func(msg *mail.Message) error {
+		sent = msg
+		return nil
+	}))
+	m.From = "app@example.com"
+
+	lee := leego.New()
+	req := httptest.NewRequest(leego.GET, "/?lang=fr", nil)
+	req.Header.Set(leego.HeaderXRequestID, "abc")
+	c := lee.NewContext(standard.NewRequest(req), standard.NewResponse(httptest.NewRecorder()))
+	msg := &mail.Message{To: []string{"joe@example.com"}, Subject: "Welcome"}
+	assert.Equal(t, leego.ErrRendererNotRegistered, m.SendTemplate(c, msg, "welcome", "Joe"))
+
+	lee.SetRenderer(renderer{})
+	if assert.NoError(t, m.SendTemplate(c, msg, "welcome", "Joe")) {
+		assert.Equal(t, "app@example.com", sent.From)
+		assert.Equal(t, "<p>welcome Joe fr</p>", sent.HTML)
+		assert.Equal(t, "abc", sent.Metadata["request_id"])
+	}
+
+	assert.Equal(t, mail.ErrNoRecipient, m.Send(&mail.Message{}))
+}
+
+func TestMessageBytes(t *testing.T) {
+	msg := &mail.Message{
+		From:    "App <app@example.com>",
+		To:      []string{"joe@example.com"},
+		Bcc:     []string{"audit@example.com"},
+		Subject: "Café",
+		Text:    "Hi",
+		HTML:    "<p>Hi</p>",
+	}
+	b := string(msg.Bytes())
+	assert.Contains(t, b, "To: joe@example.com\r\n")
+	assert.Contains(t, b, "Subject: =?utf-8?q?Caf=C3=A9?=\r\n")
+	assert.Contains(t, b, "Content-Type: multipart/alternative; boundary=")
+	assert.Contains(t, b, "Content-Type: text/html; charset=utf-8")
+	assert.False(t, strings.Contains(b, "audit@example.com"))
+
+	msg.Text = ""
+	b = string(msg.Bytes())
+	assert.Contains(t, b, "Content-Type: "+leego.MIMETextHTMLCharsetUTF8+"\r\n")
+	assert.Contains(t, b, "\r\n\r\n<p>Hi</p>")
+}
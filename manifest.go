@@ -0,0 +1,75 @@
+package leego
+
+import (
+	"fmt"
+)
+
+type (
+	// RouteManifest is the route table as data, e.g. to generate the config of
+	// a gateway or to compare the routes with golden files in tests. See
+	// `Leego#Manifest()` and `Leego#LoadManifest()`.
+	RouteManifest struct {
+		// Version is the version of the format, `ManifestVersion`.
+		Version int         `json:"version"`
+		Routes  []RouteInfo `json:"routes"`
+	}
+
+	// RouteRegistry resolves the handler and middleware names of a manifest,
+	// see `Leego#LoadManifest()`.
+	RouteRegistry struct {
+		Handlers   map[string]HandlerFunc
+		Middleware map[string]MiddlewareFunc
+	}
+)
+
+// ManifestVersion is the version of the format of the route manifests.
+const ManifestVersion = 1
+
+// Manifest returns the routes with their name, metadata and middleware, see
+// `Leego#Routes()`. It's encoded as JSON, e.g.
+//
+//	b, err := json.MarshalIndent(lee.Manifest(), "", "  ")
+func (e *Leego) Manifest() RouteManifest {
+	return RouteManifest{Version: ManifestVersion, Routes: e.Routes()}
+}
+
+// SetRouteMeta sets the metadata of the route for method and path, e.g. its
+// owner or API version, exported with `Leego#Manifest()`.
+func (e *Leego) SetRouteMeta(method, path string, meta map[string]string) {
+	e.router.meta[method+path] = meta
+}
+
+// LoadManifest adds the routes of m, with the handlers and middleware of the
+// registry by name, so that the manifest of e then includes m. Middleware are
+// added to the routes, in order. No route is added if a name isn't in the
+// registry.
+func (e *Leego) LoadManifest(m RouteManifest, registry RouteRegistry) error {
+	if m.Version != ManifestVersion {
+		return fmt.Errorf("leego: unsupported manifest version %d", m.Version)
+	}
+	for _, r := range m.Routes {
+		if registry.Handlers[r.Handler] == nil {
+			return fmt.Errorf("leego: route %s %s: unknown handler %q", r.Method, r.Path, r.Handler)
+		}
+		for _, name := range r.Middleware {
+			if registry.Middleware[name] == nil {
+				return fmt.Errorf("leego: route %s %s: unknown middleware %q", r.Method, r.Path, name)
+			}
+		}
+	}
+	for _, r := range m.Routes {
+		middleware := make([]MiddlewareFunc, len(r.Middleware))
+		for i, name := range r.Middleware {
+			middleware[i] = registry.Middleware[name]
+		}
+		route := e.add(r.Method, r.Path, registry.Handlers[r.Handler], middleware...)
+		// Named after the manifest, so that it's exported as loaded.
+		route.Handler = r.Handler
+		route.Name = r.Name
+		e.router.middleware[r.Method+r.Path] = r.Middleware
+		if r.Meta != nil {
+			e.SetRouteMeta(r.Method, r.Path, r.Meta)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// BasicAuthConfig defines the config for BasicAuth middleware.
+	BasicAuthConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Validator validates the credentials.
+		// Required.
+		Validator BasicAuthValidator
+
+		// Realm is the realm sent in the `WWW-Authenticate` header.
+		// Optional. Default value "Restricted".
+		Realm string `json:"realm"`
+
+		// FormatLeeError formats the errors returned by the middleware, see
+		// `Middleware#FormatLeeError()`.
+		// Optional. Default value returns the error as is.
+		FormatLeeError func(err error, middlewareName string) leego.LeeError
+	}
+
+	// BasicAuthValidator reports whether the credentials are valid.
+	BasicAuthValidator func(user, password string, c leego.Context) (bool, error)
+
+	// KeyAuthConfig defines the config for KeyAuth middleware.
+	KeyAuthConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Validator validates the key.
+		// Required.
+		Validator KeyAuthValidator
+
+		// KeyLookup is a string in the form of "<source>:<name>" that is used
+		// to extract the key from the request. Possible values:
+		// - "header:<name>"
+		// - "query:<name>"
+		// - "form:<name>"
+		// Optional. Default value "header:Authorization".
+		KeyLookup string `json:"key_lookup"`
+
+		// AuthScheme is the scheme preceding the key in the header lookup. Empty
+		// for a header holding the bare key, e.g. `X-API-Key`.
+		// Optional. Default value "Bearer" for "header:Authorization".
+		AuthScheme string `json:"auth_scheme"`
+
+		// FormatLeeError formats the errors returned by the middleware, see
+		// `Middleware#FormatLeeError()`.
+		// Optional. Default value returns the error as is.
+		FormatLeeError func(err error, middlewareName string) leego.LeeError
+	}
+
+	// KeyAuthValidator reports whether the key is valid.
+	KeyAuthValidator func(key string, c leego.Context) (bool, error)
+)
+
+const (
+	basicAuthScheme         = "Basic"
+	basicAuthMiddlewareName = "basic-auth"
+	keyAuthMiddlewareName   = "key-auth"
+)
+
+var (
+	// DefaultBasicAuthConfig is the default BasicAuth middleware config.
+	DefaultBasicAuthConfig = BasicAuthConfig{
+		Skipper:        defaultSkipper,
+		Realm:          "Restricted",
+		FormatLeeError: defaultFormatLeeError,
+	}
+
+	// DefaultKeyAuthConfig is the default KeyAuth middleware config.
+	DefaultKeyAuthConfig = KeyAuthConfig{
+		Skipper:        defaultSkipper,
+		KeyLookup:      "header:" + leego.HeaderAuthorization,
+		AuthScheme:     "Bearer",
+		FormatLeeError: defaultFormatLeeError,
+	}
+
+	// ErrKeyAuthMissing is returned when the request carries no key.
+	ErrKeyAuthMissing = leego.NewHTTPError(http.StatusBadRequest, "missing or malformed key")
+)
+
+// BasicAuth returns a HTTP basic auth middleware.
+//
+// For valid credentials it calls the next handler. For missing or invalid
+// credentials, it sends the `WWW-Authenticate` header and returns
+// `leego.ErrUnauthorized` (401).
+func BasicAuth(fn BasicAuthValidator) leego.MiddlewareFunc {
+	c := DefaultBasicAuthConfig
+	c.Validator = fn
+	return BasicAuthWithConfig(c)
+}
+
+// BasicAuthWithConfig returns a BasicAuth middleware from config.
+// See `BasicAuth()`.
+func BasicAuthWithConfig(config BasicAuthConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultBasicAuthConfig.Skipper
+	}
+	if config.Validator == nil {
+		panic("basic auth middleware requires validator")
+	}
+	if config.Realm == "" {
+		config.Realm = DefaultBasicAuthConfig.Realm
+	}
+	if config.FormatLeeError == nil {
+		config.FormatLeeError = DefaultBasicAuthConfig.FormatLeeError
+	}
+
+	challenge := basicAuthScheme + " realm=" + strconv.Quote(config.Realm)
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			if user, password, ok := parseBasicAuth(c.Request().Header().Get(leego.HeaderAuthorization)); ok {
+				valid, err := config.Validator(user, password, c)
+				if err != nil {
+					return config.FormatLeeError(err, basicAuthMiddlewareName)
+				}
+				if valid {
+					return next(c)
+				}
+			}
+			c.Response().Header().Set(leego.HeaderWWWAuthenticate, challenge)
+			return config.FormatLeeError(leego.ErrUnauthorized, basicAuthMiddlewareName)
+		}
+	}
+}
+
+// parseBasicAuth parses the credentials of a basic `Authorization` header.
+func parseBasicAuth(auth string) (user, password string, ok bool) {
+	l := len(basicAuthScheme)
+	if len(auth) <= l+1 || !strings.EqualFold(auth[:l], basicAuthScheme) || auth[l] != ' ' {
+		return
+	}
+	b, err := base64.StdEncoding.DecodeString(auth[l+1:])
+	if err != nil {
+		return
+	}
+	cred := string(b)
+	i := strings.IndexByte(cred, ':')
+	if i < 0 {
+		return
+	}
+	return cred[:i], cred[i+1:], true
+}
+
+// KeyAuth returns a key auth middleware, e.g. for API keys.
+//
+// For a valid key it calls the next handler. For an invalid key, it returns
+// `leego.ErrUnauthorized` (401). For a missing key, it returns
+// `ErrKeyAuthMissing` (400).
+func KeyAuth(fn KeyAuthValidator) leego.MiddlewareFunc {
+	c := DefaultKeyAuthConfig
+	c.Validator = fn
+	return KeyAuthWithConfig(c)
+}
+
+// KeyAuthWithConfig returns a KeyAuth middleware from config.
+// See `KeyAuth()`.
+func KeyAuthWithConfig(config KeyAuthConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultKeyAuthConfig.Skipper
+	}
+	if config.Validator == nil {
+		panic("key auth middleware requires validator")
+	}
+	if config.KeyLookup == "" {
+		config.KeyLookup = DefaultKeyAuthConfig.KeyLookup
+	}
+	if config.AuthScheme == "" && config.KeyLookup == DefaultKeyAuthConfig.KeyLookup {
+		config.AuthScheme = DefaultKeyAuthConfig.AuthScheme
+	}
+	if config.FormatLeeError == nil {
+		config.FormatLeeError = DefaultKeyAuthConfig.FormatLeeError
+	}
+
+	// Initialize
+	keyFrom := valueFrom(keyAuthMiddlewareName, config.KeyLookup, "header", "query", "form")
+	if config.AuthScheme != "" && strings.HasPrefix(config.KeyLookup, "header:") {
+		keyFrom = valueAfterScheme(keyFrom, config.AuthScheme)
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			key := keyFrom(c)
+			if key == "" {
+				return config.FormatLeeError(ErrKeyAuthMissing, keyAuthMiddlewareName)
+			}
+			valid, err := config.Validator(key, c)
+			if err != nil {
+				return config.FormatLeeError(err, keyAuthMiddlewareName)
+			}
+			if !valid {
+				return config.FormatLeeError(leego.ErrUnauthorized, keyAuthMiddlewareName)
+			}
+			return next(c)
+		}
+	}
+}
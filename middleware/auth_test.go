@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBasicAuth(t *testing.T) {
+	lee := leego.New()
+	mw := BasicAuth(func(user, password string, c leego.Context) (bool, error) {
+		if user == "error" {
+			return false, errors.New("store down")
+		}
+		return user == "joe" && password == "se:cret", nil
+	})
+	run := func(auth string) (leego.LeeError, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(leego.GET, "/", nil)
+		if auth != "" {
+			req.Header.Set(leego.HeaderAuthorization, auth)
+		}
+		rec := httptest.NewRecorder()
+		c := lee.NewContext(standard.NewRequest(req), standard.NewResponse(rec))
+		return mw(func(c leego.Context) leego.LeeError {
+			return nil
+		})(c), rec
+	}
+	basic := func(cred string) string {
+		return "basic " + base64.StdEncoding.EncodeToString([]byte(cred))
+	}
+
+	err, rec := run(basic("joe:se:cret"))
+	assert.Nil(t, err)
+	assert.Empty(t, rec.Header().Get(leego.HeaderWWWAuthenticate))
+
+	for _, auth := range []string{"", basic("joe:wrong"), "Basic !!", basic("joe"), "Bearer x"} {
+		err, rec = run(auth)
+		assert.Equal(t, leego.ErrUnauthorized, err)
+		assert.Equal(t, `Basic realm="Restricted"`, rec.Header().Get(leego.HeaderWWWAuthenticate))
+	}
+
+	err, _ = run(basic("error:x"))
+	assert.Equal(t, "store down", err.Error())
+}
+
+func TestKeyAuth(t *testing.T) {
+	lee := leego.New()
+	validator := func(key string, c leego.Context) (bool, error) {
+		return key == "valid-key", nil
+	}
+	run := func(config KeyAuthConfig, target string, header string) leego.LeeError {
+		req := httptest.NewRequest(leego.GET, target, nil)
+		if header != "" {
+			req.Header.Set(leego.HeaderAuthorization, header)
+			req.Header.Set("X-API-Key", header)
+		}
+		c := lee.NewContext(standard.NewRequest(req), standard.NewResponse(httptest.NewRecorder()))
+		config.Validator = validator
+		return KeyAuthWithConfig(config)(func(c leego.Context) leego.LeeError {
+			return nil
+		})(c)
+	}
+
+	assert.Nil(t, run(KeyAuthConfig{}, "/", "Bearer valid-key"))
+	assert.Equal(t, leego.ErrUnauthorized, run(KeyAuthConfig{}, "/", "Bearer other"))
+	assert.Equal(t, ErrKeyAuthMissing, run(KeyAuthConfig{}, "/", ""))
+	assert.Equal(t, ErrKeyAuthMissing, run(KeyAuthConfig{}, "/", "valid-key"))
+
+	// Bare header
+	assert.Nil(t, run(KeyAuthConfig{KeyLookup: "header:X-API-Key"}, "/", "valid-key"))
+
+	// Query
+	assert.Nil(t, run(KeyAuthConfig{KeyLookup: "query:api_key"}, "/?api_key=valid-key", ""))
+	assert.Equal(t, ErrKeyAuthMissing, run(KeyAuthConfig{KeyLookup: "query:api_key"}, "/", ""))
+
+	// Form
+	assert.Nil(t, run(KeyAuthConfig{KeyLookup: "form:api_key"}, "/?api_key=valid-key", ""))
+
+	// Unknown source
+	assert.Panics(t, func() {
+		KeyAuthWithConfig(KeyAuthConfig{KeyLookup: "cookie:api_key", Validator: func(string, leego.Context) (bool, error) {
+			return true, nil
+		}})
+	})
+}
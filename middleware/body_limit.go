@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// BodyLimitConfig defines the config for BodyLimit middleware.
+	BodyLimitConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Limit is the maximum allowed size of the request body, e.g. "4K" or
+		// "2M". The units are B, K, M, G and T, optionally followed by B.
+		// Required.
+		Limit string `json:"limit"`
+	}
+
+	limitedReader struct {
+		reader io.Reader
+		limit  int64
+		read   int64
+	}
+)
+
+var (
+	// DefaultBodyLimitConfig is the default BodyLimit middleware config.
+	DefaultBodyLimitConfig = BodyLimitConfig{
+		Skipper: defaultSkipper,
+	}
+)
+
+// BodyLimit returns a middleware which limits the size of the request body.
+//
+// A request whose `Content-Length` exceeds the limit is rejected with
+// `leego.ErrStatusRequestEntityTooLarge` (413) before the handler runs. For a
+// request without `Content-Length`, e.g. a chunked upload, reading the body
+// past the limit returns the same error to the handler.
+func BodyLimit(limit string) leego.MiddlewareFunc {
+	c := DefaultBodyLimitConfig
+	c.Limit = limit
+	return BodyLimitWithConfig(c)
+}
+
+// BodyLimitWithConfig returns a BodyLimit middleware from config.
+// See `BodyLimit()`.
+func BodyLimitWithConfig(config BodyLimitConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultBodyLimitConfig.Skipper
+	}
+	limit, err := parseBytes(config.Limit)
+	if err != nil {
+		panic("body limit middleware: " + err.Error())
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			req := c.Request()
+			if req.ContentLength() > limit {
+				return leego.ErrStatusRequestEntityTooLarge
+			}
+			body := req.Body()
+			if body == nil {
+				return next(c)
+			}
+			req.SetBody(&limitedReader{reader: body, limit: limit})
+			return next(c)
+		}
+	}
+}
+
+// Read implements `io.Reader` function. It fails once more than the limit has
+// been read.
+func (r *limitedReader) Read(b []byte) (n int, err error) {
+	n, err = r.reader.Read(b)
+	r.read += int64(n)
+	if r.read > r.limit {
+		return n, leego.ErrStatusRequestEntityTooLarge
+	}
+	return
+}
+
+// parseBytes parses a human readable size, e.g. "2M", into bytes.
+func parseBytes(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	v = strings.TrimSuffix(v, "B")
+	multiplier := int64(1)
+	if n := len(v); n > 0 {
+		switch v[n-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			v = v[:n-1]
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyLimit(t *testing.T) {
+	lee := leego.New()
+	lee.Use(BodyLimit("2KB"))
+	lee.Post("/", func(c leego.Context) leego.LeeError {
+		b, err := ioutil.ReadAll(c.Request().Body())
+		if err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, string(b))
+	})
+	request := func(size int, chunked bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(leego.POST, "/", bytes.NewReader(make([]byte, size)))
+		if chunked {
+			req.ContentLength = -1
+		}
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		return rec
+	}
+
+	rec := request(2048, false)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 2048, rec.Body.Len())
+	assert.Equal(t, http.StatusRequestEntityTooLarge, request(2049, false).Code)
+
+	// Chunked
+	assert.Equal(t, http.StatusOK, request(2048, true).Code)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, request(4096, true).Code)
+}
+
+func TestParseBytes(t *testing.T) {
+	for s, n := range map[string]int64{
+		"100":  100,
+		"1B":   1,
+		"4K":   4 << 10,
+		"2MB":  2 << 20,
+		"1.5k": 1536,
+		"1G":   1 << 30,
+	} {
+		v, err := parseBytes(s)
+		assert.NoError(t, err)
+		assert.Equal(t, n, v)
+	}
+	for _, s := range []string{"", "M", "-1K", "2X"} {
+		_, err := parseBytes(s)
+		assert.Error(t, err)
+	}
+	assert.Panics(t, func() {
+		BodyLimit("")
+	})
+}
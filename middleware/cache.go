@@ -0,0 +1,479 @@
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine"
+)
+
+type (
+	// CacheConfig defines the config for Cache middleware.
+	CacheConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Store keeps the cached responses.
+		// Required.
+		Store CacheStore
+
+		// TTL is the time a response is cached for.
+		// Optional. Default value 1 minute.
+		TTL time.Duration `json:"ttl"`
+
+		// KeyFunc returns the key a response is cached under. Requests served
+		// different responses, e.g. per user or tenant, must get different keys.
+		// Optional. Default value `CacheKeys(CacheKeyURI,
+		// CacheKeyHeader(leego.HeaderAuthorization))`.
+		KeyFunc CacheKeyFunc
+
+		// Bypass returns true for the requests served by the handler rather
+		// than from the cache, e.g. `CacheBypassNoCache`. Their responses
+		// still refresh the cache.
+		// Optional. Default value nil.
+		Bypass func(c leego.Context) bool
+
+		// Invalidate returns the path patterns to invalidate after a
+		// successful POST, PUT, PATCH or DELETE request, see
+		// `CacheStore#Invalidate()`, e.g. `CacheInvalidatePath`.
+		// Optional. Default value nil.
+		Invalidate func(c leego.Context) []string
+
+		// FormatLeeError formats the errors returned by the middleware, see
+		// `Middleware#FormatLeeError()`.
+		// Optional. Default value returns the error as is.
+		FormatLeeError func(err error, middlewareName string) leego.LeeError
+	}
+
+	// CacheKeyFunc returns a key, or a part of the key, of a cached response.
+	CacheKeyFunc func(c leego.Context) string
+
+	// CacheStore is the storage of the Cache middleware, e.g. in memory with
+	// `CacheMemoryStore` or in Redis to share the cache between servers.
+	CacheStore interface {
+		// Get returns the entry cached under key, nil if there is none or it
+		// expired.
+		Get(key string) (*CacheEntry, error)
+
+		// Set caches e under key until `CacheEntry#Expires`.
+		Set(key string, e *CacheEntry) error
+
+		// Invalidate removes the entries whose path matches pattern, see
+		// `path.Match()`, and returns their number. Handlers call it after
+		// writes, e.g. `store.Invalidate("/users/*")` after updating a user.
+		Invalidate(pattern string) (int, error)
+	}
+
+	// CacheEntry is a cached response. The entry of a response with a `Vary`
+	// header only holds the request headers it varies by, the response being
+	// cached per value of these headers.
+	CacheEntry struct {
+		Path    string            `json:"path"`
+		Status  int               `json:"status"`
+		Header  map[string]string `json:"header"`
+		Body    []byte            `json:"body"`
+		Vary    []string          `json:"vary,omitempty"`
+		Expires time.Time         `json:"expires"`
+	}
+
+	// CacheMemoryStoreConfig defines the config for `CacheMemoryStore`.
+	CacheMemoryStoreConfig struct {
+		// MaxEntries is the maximum number of entries, the least recently
+		// used ones being evicted.
+		// Optional. Default value 10000.
+		MaxEntries int `json:"max_entries"`
+	}
+
+	// CacheMemoryStore is an in-memory LRU `CacheStore`.
+	CacheMemoryStore struct {
+		config   CacheMemoryStoreConfig
+		mu       sync.Mutex
+		entries  map[string]*list.Element
+		lru      *list.List
+		lastScan time.Time
+		now      func() time.Time
+	}
+
+	// cacheItem is an element of the LRU list of `CacheMemoryStore`.
+	cacheItem struct {
+		key   string
+		entry *CacheEntry
+	}
+
+	cacheResponse struct {
+		engine.Response
+		body bytes.Buffer
+	}
+)
+
+const cacheMiddlewareName = "cache"
+
+var (
+	// DefaultCacheConfig is the default Cache middleware config.
+	DefaultCacheConfig = CacheConfig{
+		Skipper:        defaultSkipper,
+		TTL:            time.Minute,
+		KeyFunc:        CacheKeys(CacheKeyURI, CacheKeyHeader(leego.HeaderAuthorization)),
+		FormatLeeError: defaultFormatLeeError,
+	}
+
+	// DefaultCacheMemoryStoreConfig is the default `CacheMemoryStore` config.
+	DefaultCacheMemoryStoreConfig = CacheMemoryStoreConfig{
+		MaxEntries: 10000,
+	}
+)
+
+// Cache returns a middleware which caches the successful responses to GET
+// requests in store, and serves them to the GET and HEAD requests with the same
+// key. The responses vary by Authorization header by default, see
+// `CacheConfig#KeyFunc`.
+//
+// Responses with `Cache-Control: no-store` or `private`, `Vary: *` or a
+// `Set-Cookie` header, e.g. of a session, aren't cached. Responses with a `Vary`
+// header are cached per value of the request headers it lists. The `X-Cache` response
+// header is set to HIT, MISS or BYPASS.
+func Cache(store CacheStore) leego.MiddlewareFunc {
+	c := DefaultCacheConfig
+	c.Store = store
+	return CacheWithConfig(c)
+}
+
+// CacheWithConfig returns a Cache middleware from config.
+// See `Cache()`.
+func CacheWithConfig(config CacheConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultCacheConfig.Skipper
+	}
+	if config.Store == nil {
+		panic("cache middleware requires store")
+	}
+	if config.TTL == 0 {
+		config.TTL = DefaultCacheConfig.TTL
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = DefaultCacheConfig.KeyFunc
+	}
+	if config.FormatLeeError == nil {
+		config.FormatLeeError = DefaultCacheConfig.FormatLeeError
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			method := c.Request().Method()
+			if config.Skipper(c) {
+				return next(c)
+			}
+			switch method {
+			case leego.GET, leego.HEAD:
+			case leego.POST, leego.PUT, leego.PATCH, leego.DELETE:
+				return invalidateCache(c, next, &config)
+			default:
+				return next(c)
+			}
+
+			key := config.KeyFunc(c)
+			bypass := config.Bypass != nil && config.Bypass(c)
+			var e *CacheEntry
+			var err error
+			if !bypass {
+				if e, err = getCacheEntry(c, config.Store, key); err != nil {
+					return config.FormatLeeError(err, cacheMiddlewareName)
+				}
+			}
+			res := c.Response()
+			if e != nil {
+				for k, v := range e.Header {
+					res.Header().Set(k, v)
+				}
+				res.Header().Set(leego.HeaderXCache, "HIT")
+				res.WriteHeader(e.Status)
+				if method == leego.GET {
+					res.Write(e.Body)
+				}
+				return nil
+			}
+
+			if bypass {
+				res.Header().Set(leego.HeaderXCache, "BYPASS")
+			} else {
+				res.Header().Set(leego.HeaderXCache, "MISS")
+			}
+			if method == leego.HEAD {
+				return next(c)
+			}
+			cr := &cacheResponse{Response: res}
+			c.SetResponse(cr)
+			err = next(c)
+			c.SetResponse(res)
+			if err != nil || !res.Committed() || res.Status() != http.StatusOK || !cacheable(res.Header()) {
+				return err
+			}
+
+			e = &CacheEntry{
+				Path:    c.Request().URL().Path(),
+				Status:  res.Status(),
+				Header:  make(map[string]string),
+				Body:    cr.body.Bytes(),
+				Expires: time.Now().Add(config.TTL),
+			}
+			for _, k := range res.Header().Keys() {
+				if k != leego.HeaderXCache {
+					e.Header[k] = strings.Join(res.Header().Values(k), ", ")
+				}
+			}
+			if vary := varyHeaders(res.Header()); len(vary) > 0 {
+				index := &CacheEntry{Path: e.Path, Vary: vary, Expires: e.Expires}
+				if err := config.Store.Set(key, index); err != nil {
+					return config.FormatLeeError(err, cacheMiddlewareName)
+				}
+				key = cacheVariantKey(c, key, vary)
+			}
+			if err := config.Store.Set(key, e); err != nil {
+				return config.FormatLeeError(err, cacheMiddlewareName)
+			}
+			return nil
+		}
+	}
+}
+
+// getCacheEntry returns the entry of the request cached under key, following
+// the index of the responses with a `Vary` header.
+func getCacheEntry(c leego.Context, store CacheStore, key string) (*CacheEntry, error) {
+	e, err := store.Get(key)
+	if err != nil || e == nil || len(e.Vary) == 0 {
+		return e, err
+	}
+	return store.Get(cacheVariantKey(c, key, e.Vary))
+}
+
+// invalidateCache runs the handler of an unsafe request, then invalidates the
+// patterns of `CacheConfig#Invalidate` if it succeeded.
+func invalidateCache(c leego.Context, next leego.HandlerFunc, config *CacheConfig) leego.LeeError {
+	if err := next(c); err != nil || config.Invalidate == nil {
+		return err
+	}
+	if s := c.Response().Status(); s >= http.StatusBadRequest {
+		return nil
+	}
+	for _, pattern := range config.Invalidate(c) {
+		if _, err := config.Store.Invalidate(pattern); err != nil {
+			return config.FormatLeeError(err, cacheMiddlewareName)
+		}
+	}
+	return nil
+}
+
+// cacheVariantKey returns the key of the response to the request varying by
+// the headers vary.
+func cacheVariantKey(c leego.Context, key string, vary []string) string {
+	parts := make([]string, len(vary)+1)
+	parts[0] = key
+	for i, h := range vary {
+		parts[i+1] = strconv.Quote(c.Request().Header().Get(h))
+	}
+	return strings.Join(parts, " ")
+}
+
+// varyHeaders returns the canonical request headers listed by the `Vary`
+// header h, sorted.
+func varyHeaders(h engine.Header) []string {
+	var vary []string
+	seen := make(map[string]bool)
+	for _, v := range h.Values(leego.HeaderVary) {
+		for _, name := range strings.Split(v, ",") {
+			if name = http.CanonicalHeaderKey(strings.TrimSpace(name)); name != "" && !seen[name] {
+				seen[name] = true
+				vary = append(vary, name)
+			}
+		}
+	}
+	sort.Strings(vary)
+	return vary
+}
+
+// cacheable reports whether the response with header h may be cached. The
+// responses setting cookies are per client, even if the key isn't.
+func cacheable(h engine.Header) bool {
+	if h.Get(leego.HeaderSetCookie) != "" {
+		return false
+	}
+	for _, d := range strings.Split(h.Get(leego.HeaderCacheControl), ",") {
+		if d = strings.TrimSpace(d); d == "no-store" || d == "private" {
+			return false
+		}
+	}
+	for _, v := range varyHeaders(h) {
+		if v == "*" {
+			return false
+		}
+	}
+	return true
+}
+
+// CacheBypassNoCache returns true for the requests with `Cache-Control:
+// no-cache` or `Pragma: no-cache`, e.g. on reload, see `CacheConfig#Bypass`.
+func CacheBypassNoCache(c leego.Context) bool {
+	h := c.Request().Header()
+	for _, d := range strings.Split(h.Get(leego.HeaderCacheControl), ",") {
+		if strings.TrimSpace(d) == "no-cache" {
+			return true
+		}
+	}
+	return h.Get("Pragma") == "no-cache"
+}
+
+// CacheInvalidatePath returns the request path and its parent, e.g.
+// "/users/1" and "/users" for `PUT /users/1`, see `CacheConfig#Invalidate`.
+func CacheInvalidatePath(c leego.Context) []string {
+	p := c.Request().URL().Path()
+	if parent := path.Dir(p); parent != p {
+		return []string{p, parent}
+	}
+	return []string{p}
+}
+
+// CacheKeyURI returns the request URI, i.e. the path and the query.
+func CacheKeyURI(c leego.Context) string {
+	return c.Request().URI()
+}
+
+// CacheKeyHeader returns a `CacheKeyFunc` returning the request header name,
+// e.g. Accept-Language.
+func CacheKeyHeader(name string) CacheKeyFunc {
+	return func(c leego.Context) string {
+		return c.Request().Header().Get(name)
+	}
+}
+
+// CacheKeyValue returns a `CacheKeyFunc` returning the context value key, see
+// `Context#Get()`, e.g. the user or tenant ID set by an authentication
+// middleware. The value must print the same for the same identity.
+func CacheKeyValue(key string) CacheKeyFunc {
+	return func(c leego.Context) string {
+		if v := c.Get(key); v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+}
+
+// CacheKeys returns a `CacheKeyFunc` combining the keys of funcs, e.g.
+//
+//	CacheKeys(CacheKeyURI, CacheKeyValue("tenant"), CacheKeyHeader("Accept-Language"))
+func CacheKeys(funcs ...CacheKeyFunc) CacheKeyFunc {
+	return func(c leego.Context) string {
+		parts := make([]string, len(funcs))
+		for i, f := range funcs {
+			// Quoted, so parts containing the separator don't collide
+			parts[i] = strconv.Quote(f(c))
+		}
+		return strings.Join(parts, " ")
+	}
+}
+
+// NewCacheMemoryStore returns an in-memory cache store.
+func NewCacheMemoryStore() *CacheMemoryStore {
+	return NewCacheMemoryStoreWithConfig(DefaultCacheMemoryStoreConfig)
+}
+
+// NewCacheMemoryStoreWithConfig returns an in-memory cache store from config.
+func NewCacheMemoryStoreWithConfig(config CacheMemoryStoreConfig) *CacheMemoryStore {
+	// Defaults
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = DefaultCacheMemoryStoreConfig.MaxEntries
+	}
+	return &CacheMemoryStore{
+		config:  config,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+// Get implements `CacheStore#Get()`.
+func (s *CacheMemoryStore) Get(key string) (*CacheEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el := s.entries[key]
+	if el == nil {
+		return nil, nil
+	}
+	e := el.Value.(*cacheItem).entry
+	if !s.now().Before(e.Expires) {
+		s.remove(el)
+		return nil, nil
+	}
+	s.lru.MoveToFront(el)
+	return e, nil
+}
+
+// Set implements `CacheStore#Set()`. Expired entries are removed every minute
+// on the way, then the least recently used ones over
+// `CacheMemoryStoreConfig#MaxEntries`.
+func (s *CacheMemoryStore) Set(key string, e *CacheEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now := s.now(); now.Sub(s.lastScan) >= time.Minute {
+		for _, el := range s.entries {
+			if !now.Before(el.Value.(*cacheItem).entry.Expires) {
+				s.remove(el)
+			}
+		}
+		s.lastScan = now
+	}
+	if el := s.entries[key]; el != nil {
+		el.Value.(*cacheItem).entry = e
+		s.lru.MoveToFront(el)
+		return nil
+	}
+	s.entries[key] = s.lru.PushFront(&cacheItem{key: key, entry: e})
+	for s.lru.Len() > s.config.MaxEntries {
+		s.remove(s.lru.Back())
+	}
+	return nil
+}
+
+// Len returns the number of entries, expired ones included until they're
+// removed.
+func (s *CacheMemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+func (s *CacheMemoryStore) remove(el *list.Element) {
+	s.lru.Remove(el)
+	delete(s.entries, el.Value.(*cacheItem).key)
+}
+
+// Invalidate implements `CacheStore#Invalidate()`.
+func (s *CacheMemoryStore) Invalidate(pattern string) (int, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, el := range s.entries {
+		if ok, _ := path.Match(pattern, el.Value.(*cacheItem).entry.Path); ok {
+			s.remove(el)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *cacheResponse) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.Response.Write(b)
+}
@@ -0,0 +1,333 @@
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type (
+	// CacheBroadcaster carries the invalidations of a `CacheBroadcastStore`
+	// between replicas, e.g. `RedisCacheBroadcaster`.
+	CacheBroadcaster interface {
+		// Publish sends the invalidation of pattern to all the subscribers,
+		// including the publisher.
+		Publish(pattern string) error
+
+		// Subscribe calls handler with the patterns published from now on.
+		Subscribe(handler func(pattern string)) error
+	}
+
+	// CacheBroadcastStore is a `CacheStore` whose invalidations are broadcast
+	// to the stores of the other replicas, keeping their caches coherent.
+	CacheBroadcastStore struct {
+		CacheStore
+		broadcaster CacheBroadcaster
+	}
+
+	// RedisCacheBroadcasterConfig defines the config for `RedisCacheBroadcaster`.
+	RedisCacheBroadcasterConfig struct {
+		// Addr is the address of the Redis server, e.g. "localhost:6379".
+		// Required.
+		Addr string `json:"addr"`
+
+		// Password authenticates with the server if not empty.
+		// Optional.
+		Password string `json:"-"`
+
+		// Channel is the pub/sub channel of the invalidations.
+		// Optional. Default value "leego:cache:invalidate".
+		Channel string `json:"channel"`
+
+		// RetryInterval is the time between reconnections of the subscription.
+		// Optional. Default value 1 second.
+		RetryInterval time.Duration `json:"retry_interval"`
+
+		// DialTimeout is the timeout of connecting to the server.
+		// Optional. Default value 5 seconds.
+		DialTimeout time.Duration `json:"dial_timeout"`
+
+		// Timeout is the timeout of a command, so that a server which hangs
+		// fails the invalidations instead of blocking them.
+		// Optional. Default value 3 seconds.
+		Timeout time.Duration `json:"timeout"`
+	}
+
+	// RedisCacheBroadcaster is a `CacheBroadcaster` over Redis pub/sub.
+	RedisCacheBroadcaster struct {
+		config RedisCacheBroadcasterConfig
+		mu     sync.Mutex
+		conn   *redisConn // Publishing connection
+		sub    *redisConn // Subscription connection
+		closed bool
+	}
+
+	redisConn struct {
+		net.Conn
+		r       *bufio.Reader
+		timeout time.Duration
+	}
+)
+
+const (
+	redisDialTimeout = 5 * time.Second
+	redisTimeout     = 3 * time.Second
+)
+
+var (
+	// DefaultRedisCacheBroadcasterConfig is the default `RedisCacheBroadcaster`
+	// config.
+	DefaultRedisCacheBroadcasterConfig = RedisCacheBroadcasterConfig{
+		Channel:       "leego:cache:invalidate",
+		RetryInterval: time.Second,
+		DialTimeout:   redisDialTimeout,
+		Timeout:       redisTimeout,
+	}
+
+	errRedisBroadcasterClosed = errors.New("redis cache broadcaster closed")
+)
+
+// NewCacheBroadcastStore returns a store invalidating the entries of store when
+// any replica invalidates them through broadcaster.
+func NewCacheBroadcastStore(store CacheStore, broadcaster CacheBroadcaster) (*CacheBroadcastStore, error) {
+	err := broadcaster.Subscribe(func(pattern string) {
+		store.Invalidate(pattern)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &CacheBroadcastStore{CacheStore: store, broadcaster: broadcaster}, nil
+}
+
+// Invalidate implements `CacheStore#Invalidate()`. It invalidates the local
+// entries and broadcasts pattern; the other replicas invalidate theirs
+// asynchronously.
+func (s *CacheBroadcastStore) Invalidate(pattern string) (int, error) {
+	n, err := s.CacheStore.Invalidate(pattern)
+	if err != nil {
+		return n, err
+	}
+	return n, s.broadcaster.Publish(pattern)
+}
+
+// NewRedisCacheBroadcaster returns a broadcaster over the Redis server at addr.
+func NewRedisCacheBroadcaster(addr string) *RedisCacheBroadcaster {
+	c := DefaultRedisCacheBroadcasterConfig
+	c.Addr = addr
+	return NewRedisCacheBroadcasterWithConfig(c)
+}
+
+// NewRedisCacheBroadcasterWithConfig returns a Redis broadcaster from config.
+// It connects on first use.
+func NewRedisCacheBroadcasterWithConfig(config RedisCacheBroadcasterConfig) *RedisCacheBroadcaster {
+	// Defaults
+	if config.Addr == "" {
+		panic("redis cache broadcaster requires addr")
+	}
+	if config.Channel == "" {
+		config.Channel = DefaultRedisCacheBroadcasterConfig.Channel
+	}
+	if config.RetryInterval == 0 {
+		config.RetryInterval = DefaultRedisCacheBroadcasterConfig.RetryInterval
+	}
+	if config.DialTimeout == 0 {
+		config.DialTimeout = DefaultRedisCacheBroadcasterConfig.DialTimeout
+	}
+	if config.Timeout == 0 {
+		config.Timeout = DefaultRedisCacheBroadcasterConfig.Timeout
+	}
+	return &RedisCacheBroadcaster{config: config}
+}
+
+// Publish implements `CacheBroadcaster#Publish()`.
+func (b *RedisCacheBroadcaster) Publish(pattern string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return errRedisBroadcasterClosed
+	}
+	if b.conn == nil {
+		c, err := b.dial()
+		if err != nil {
+			return err
+		}
+		b.conn = c
+	}
+	_, err := b.conn.do("PUBLISH", b.config.Channel, pattern)
+	if err != nil {
+		// Reconnect on the next call
+		b.conn.Close()
+		b.conn = nil
+	}
+	return err
+}
+
+// Subscribe implements `CacheBroadcaster#Subscribe()`. The subscription is
+// restored after connection errors, the invalidations published in between
+// are lost.
+func (b *RedisCacheBroadcaster) Subscribe(handler func(pattern string)) error {
+	c, err := b.subscribe()
+	if err != nil {
+		return err
+	}
+	go func() {
+		for {
+			b.receive(c, handler)
+			for {
+				time.Sleep(b.config.RetryInterval)
+				if c, err = b.subscribe(); err == nil || err == errRedisBroadcasterClosed {
+					break
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Close closes the connections of the broadcaster.
+func (b *RedisCacheBroadcaster) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	if b.conn != nil {
+		b.conn.Close()
+	}
+	if b.sub != nil {
+		b.sub.Close()
+	}
+	return nil
+}
+
+func (b *RedisCacheBroadcaster) subscribe() (*redisConn, error) {
+	b.mu.Lock()
+	closed := b.closed
+	b.mu.Unlock()
+	if closed {
+		return nil, errRedisBroadcasterClosed
+	}
+	c, err := b.dial()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = c.do("SUBSCRIBE", b.config.Channel); err != nil {
+		c.Close()
+		return nil, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		c.Close()
+		return nil, errRedisBroadcasterClosed
+	}
+	b.sub = c
+	return c, nil
+}
+
+// receive calls handler with the messages of c until it fails.
+func (b *RedisCacheBroadcaster) receive(c *redisConn, handler func(pattern string)) {
+	defer c.Close()
+	for {
+		v, err := c.read()
+		if err != nil {
+			return
+		}
+		// ["message", channel, payload]
+		if m, ok := v.([]interface{}); ok && len(m) == 3 && m[0] == "message" {
+			if p, ok := m[2].(string); ok {
+				handler(p)
+			}
+		}
+	}
+}
+
+func (b *RedisCacheBroadcaster) dial() (*redisConn, error) {
+	return dialRedis(b.config.Addr, b.config.Password, b.config.DialTimeout, b.config.Timeout)
+}
+
+// dialRedis connects to the Redis server at addr within dialTimeout,
+// authenticating with password if not empty. Each command of the connection
+// must complete within timeout, 0 meaning no timeout.
+func dialRedis(addr, password string, dialTimeout, timeout time.Duration) (*redisConn, error) {
+	conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{Conn: conn, r: bufio.NewReader(conn), timeout: timeout}
+	if password != "" {
+		if _, err = c.do("AUTH", password); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// do sends a command and reads its reply. The deadline of the command is
+// cleared afterwards, so that a subscription waits for messages without
+// timeout.
+func (c *redisConn) do(args ...string) (interface{}, error) {
+	if c.timeout > 0 {
+		c.SetDeadline(time.Now().Add(c.timeout))
+		defer c.SetDeadline(time.Time{})
+	}
+	buf := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, a := range args {
+		buf = append(buf, "$"+strconv.Itoa(len(a))+"\r\n"+a+"\r\n"...)
+	}
+	if _, err := c.Write(buf); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+// read reads a reply of the Redis protocol (RESP). Bulk strings are returned
+// as strings, arrays as []interface{}, and error replies as errors.
+func (c *redisConn) read() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: invalid reply %q", line)
+	}
+	t, s := line[0], line[1:len(line)-2]
+	switch t {
+	case '+':
+		return s, nil
+	case '-':
+		return nil, errors.New("redis: " + s)
+	case ':':
+		return strconv.ParseInt(s, 10, 64)
+	case '$', '*':
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid reply %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		if t == '$' {
+			b := make([]byte, n+2)
+			if _, err = io.ReadFull(c.r, b); err != nil {
+				return nil, err
+			}
+			return string(b[:n]), nil
+		}
+		a := make([]interface{}, n)
+		for i := range a {
+			if a[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	}
+	return nil, fmt.Errorf("redis: invalid reply %q", line)
+}
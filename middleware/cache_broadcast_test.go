@@ -0,0 +1,207 @@
+package middleware
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// redisServer is a fake Redis server supporting AUTH, SUBSCRIBE, PUBLISH and
+// the string and set commands of the session store. Key TTLs are recorded but
+// keys don't expire.
+type redisServer struct {
+	net.Listener
+	mu   sync.Mutex
+	subs map[string][]net.Conn
+	kv   map[string]string
+	sets map[string]map[string]bool
+	ttls map[string]int64
+}
+
+func newRedisServer(t *testing.T) *redisServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &redisServer{
+		Listener: l,
+		subs:     make(map[string][]net.Conn),
+		kv:       make(map[string]string),
+		sets:     make(map[string]map[string]bool),
+		ttls:     make(map[string]int64),
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *redisServer) serve(conn net.Conn) {
+	c := &redisConn{Conn: conn, r: bufio.NewReader(conn)}
+	for {
+		v, err := c.read()
+		if err != nil {
+			return
+		}
+		cmd := v.([]interface{})
+		switch cmd[0] {
+		case "AUTH":
+			if cmd[1] == "secret" {
+				conn.Write([]byte("+OK\r\n"))
+			} else {
+				conn.Write([]byte("-WRONGPASS invalid password\r\n"))
+			}
+		case "SUBSCRIBE":
+			ch := cmd[1].(string)
+			s.mu.Lock()
+			s.subs[ch] = append(s.subs[ch], conn)
+			conn.Write([]byte("*3\r\n$9\r\nsubscribe\r\n$" + strconv.Itoa(len(ch)) + "\r\n" + ch + "\r\n:1\r\n"))
+			s.mu.Unlock()
+		case "PUBLISH":
+			ch, msg := cmd[1].(string), cmd[2].(string)
+			s.mu.Lock()
+			subs := s.subs[ch]
+			for _, sc := range subs {
+				sc.Write([]byte("*3\r\n$7\r\nmessage\r\n$" + strconv.Itoa(len(ch)) + "\r\n" + ch + "\r\n$" + strconv.Itoa(len(msg)) + "\r\n" + msg + "\r\n"))
+			}
+			s.mu.Unlock()
+			conn.Write([]byte(":" + strconv.Itoa(len(subs)) + "\r\n"))
+		default:
+			s.mu.Lock()
+			conn.Write([]byte(s.do(cmd)))
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *redisServer) do(cmd []interface{}) string {
+	bulk := func(v string) string {
+		return "$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"
+	}
+	key, _ := cmd[1].(string)
+	switch cmd[0] {
+	case "GET":
+		if v, ok := s.kv[key]; ok {
+			return bulk(v)
+		}
+		return "$-1\r\n"
+	case "SET":
+		s.kv[key] = cmd[2].(string)
+		return "+OK\r\n"
+	case "DEL":
+		delete(s.kv, key)
+		return ":1\r\n"
+	case "SADD":
+		if s.sets[key] == nil {
+			s.sets[key] = make(map[string]bool)
+		}
+		s.sets[key][cmd[2].(string)] = true
+		return ":1\r\n"
+	case "SREM":
+		delete(s.sets[key], cmd[2].(string))
+		return ":1\r\n"
+	case "PTTL":
+		if ttl, ok := s.ttls[key]; ok {
+			return ":" + strconv.FormatInt(ttl, 10) + "\r\n"
+		}
+		return ":-1\r\n"
+	case "PEXPIRE":
+		s.ttls[key], _ = strconv.ParseInt(cmd[2].(string), 10, 64)
+		return ":1\r\n"
+	case "SMEMBERS":
+		r := "*" + strconv.Itoa(len(s.sets[key])) + "\r\n"
+		for m := range s.sets[key] {
+			r += bulk(m)
+		}
+		return r
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func TestCacheBroadcastStore(t *testing.T) {
+	srv := newRedisServer(t)
+	defer srv.Close()
+
+	// Two replicas
+	var stores [2]*CacheBroadcastStore
+	for i := range stores {
+		b := NewRedisCacheBroadcasterWithConfig(RedisCacheBroadcasterConfig{
+			Addr:     srv.Addr().String(),
+			Password: "secret",
+		})
+		defer b.Close()
+		s, err := NewCacheBroadcastStore(NewCacheMemoryStore(), b)
+		if !assert.NoError(t, err) {
+			return
+		}
+		stores[i] = s
+	}
+	expires := time.Now().Add(time.Minute)
+	for _, s := range stores {
+		s.Set("a", &CacheEntry{Path: "/users/1", Expires: expires})
+		s.Set("b", &CacheEntry{Path: "/orders/1", Expires: expires})
+	}
+
+	n, err := stores[0].Invalidate("/users/*")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	for i := 0; i < 100; i++ {
+		if e, _ := stores[1].Get("a"); e == nil {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	e, _ := stores[1].Get("a")
+	assert.Nil(t, e)
+	e, _ = stores[1].Get("b")
+	assert.NotNil(t, e)
+
+	// Authentication
+	b := NewRedisCacheBroadcasterWithConfig(RedisCacheBroadcasterConfig{
+		Addr:     srv.Addr().String(),
+		Password: "wrong",
+	})
+	_, err = NewCacheBroadcastStore(NewCacheMemoryStore(), b)
+	if assert.Error(t, err) {
+		assert.Equal(t, "redis: WRONGPASS invalid password", err.Error())
+	}
+	b.Close()
+	assert.Equal(t, errRedisBroadcasterClosed, b.Publish("/"))
+
+	// Timeout
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer l.Close()
+	go func() {
+		// Accepts, never replies
+		for {
+			if _, err := l.Accept(); err != nil {
+				return
+			}
+		}
+	}()
+	b = NewRedisCacheBroadcasterWithConfig(RedisCacheBroadcasterConfig{
+		Addr:    l.Addr().String(),
+		Timeout: 20 * time.Millisecond,
+	})
+	defer b.Close()
+	start := time.Now()
+	err = b.Publish("/")
+	if assert.Error(t, err) {
+		assert.True(t, err.(net.Error).Timeout())
+	}
+	assert.True(t, time.Since(start) < time.Second)
+}
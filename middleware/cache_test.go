@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	store := NewCacheMemoryStore()
+	calls := 0
+	lee := leego.New()
+	lee.Use(func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			c.Set("tenant", c.Request().Header().Get("X-Tenant"))
+			return next(c)
+		}
+	})
+	lee.Use(CacheWithConfig(CacheConfig{
+		Store:   store,
+		KeyFunc: CacheKeys(CacheKeyURI, CacheKeyValue("tenant"), CacheKeyHeader("Accept-Language")),
+	}))
+	lee.GET("/users/:id", func(c leego.Context) leego.LeeError {
+		calls++
+		return c.String(http.StatusOK, c.Param("id")+" "+strconv.Itoa(calls))
+	})
+	lee.GET("/private", func(c leego.Context) leego.LeeError {
+		calls++
+		c.Response().Header().Set(leego.HeaderCacheControl, "private, max-age=0")
+		return c.String(http.StatusOK, strconv.Itoa(calls))
+	})
+	lee.GET("/session", func(c leego.Context) leego.LeeError {
+		calls++
+		c.Response().Header().Add(leego.HeaderSetCookie, "session=s"+strconv.Itoa(calls))
+		return c.String(http.StatusOK, strconv.Itoa(calls))
+	})
+	lee.PUT("/users/:id", func(c leego.Context) leego.LeeError {
+		n, err := store.Invalidate("/users/" + c.Param("id"))
+		assert.NoError(t, err)
+		return c.String(http.StatusOK, strconv.Itoa(n))
+	})
+	request := func(method, path, tenant string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-Tenant", tenant)
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		return rec
+	}
+
+	rec := request(leego.GET, "/users/1", "a")
+	assert.Equal(t, "1 1", rec.Body.String())
+	assert.Equal(t, "MISS", rec.Header().Get(leego.HeaderXCache))
+	rec = request(leego.GET, "/users/1", "a")
+	assert.Equal(t, "1 1", rec.Body.String())
+	assert.Equal(t, "HIT", rec.Header().Get(leego.HeaderXCache))
+	assert.Equal(t, leego.MIMETextPlainCharsetUTF8, rec.Header().Get(leego.HeaderContentType))
+	rec = request(leego.HEAD, "/users/1", "a")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Body.String())
+
+	// Per tenant
+	assert.Equal(t, "1 2", request(leego.GET, "/users/1", "b").Body.String())
+	assert.Equal(t, "2 3", request(leego.GET, "/users/2", "a").Body.String())
+
+	// Invalidation
+	assert.Equal(t, "2", request(leego.PUT, "/users/1", "a").Body.String())
+	assert.Equal(t, "1 4", request(leego.GET, "/users/1", "a").Body.String())
+	assert.Equal(t, "2 3", request(leego.GET, "/users/2", "a").Body.String())
+	n, err := store.Invalidate("/users/*")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	_, err = store.Invalidate("[")
+	assert.Error(t, err)
+
+	// Not cacheable
+	assert.Equal(t, "5", request(leego.GET, "/private", "a").Body.String())
+	assert.Equal(t, "6", request(leego.GET, "/private", "a").Body.String())
+	assert.Equal(t, "7", request(leego.GET, "/session", "a").Body.String())
+	rec = request(leego.GET, "/session", "a")
+	assert.Equal(t, "8", rec.Body.String())
+	assert.Equal(t, "session=s8", rec.Header().Get(leego.HeaderSetCookie))
+
+	// Expiry
+	request(leego.GET, "/users/1", "a")
+	store.now = func() time.Time { return time.Now().Add(time.Hour) }
+	assert.Equal(t, "MISS", request(leego.GET, "/users/1", "a").Header().Get(leego.HeaderXCache))
+
+	assert.Panics(t, func() {
+		CacheWithConfig(CacheConfig{})
+	})
+}
+
+func TestCacheVary(t *testing.T) {
+	store := NewCacheMemoryStore()
+	calls := 0
+	lee := leego.New()
+	lee.Use(CacheWithConfig(CacheConfig{
+		Store:      store,
+		Bypass:     CacheBypassNoCache,
+		Invalidate: CacheInvalidatePath,
+	}))
+	lee.GET("/users", func(c leego.Context) leego.LeeError {
+		calls++
+		c.Response().Header().Add(leego.HeaderVary, "accept-language")
+		c.Response().Header().Add(leego.HeaderVary, leego.HeaderAcceptEncoding+", "+leego.HeaderAcceptLanguage)
+		return c.String(http.StatusOK, c.Request().Header().Get(leego.HeaderAcceptLanguage)+" "+strconv.Itoa(calls))
+	})
+	lee.GET("/any", func(c leego.Context) leego.LeeError {
+		calls++
+		c.Response().Header().Set(leego.HeaderVary, "*")
+		return c.String(http.StatusOK, strconv.Itoa(calls))
+	})
+	lee.POST("/users/:id", func(c leego.Context) leego.LeeError {
+		return c.NoContent(http.StatusNoContent)
+	})
+	request := func(method, path string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		return rec
+	}
+	en := map[string]string{leego.HeaderAcceptLanguage: "en"}
+	fr := map[string]string{leego.HeaderAcceptLanguage: "fr"}
+
+	assert.Equal(t, "en 1", request(leego.GET, "/users", en).Body.String())
+	assert.Equal(t, "fr 2", request(leego.GET, "/users", fr).Body.String())
+	rec := request(leego.GET, "/users", en)
+	assert.Equal(t, "en 1", rec.Body.String())
+	assert.Equal(t, "HIT", rec.Header().Get(leego.HeaderXCache))
+	assert.Equal(t, "accept-language, Accept-Encoding, Accept-Language", rec.Header().Get(leego.HeaderVary))
+	assert.Equal(t, "fr 2", request(leego.GET, "/users", fr).Body.String())
+
+	// Bypass
+	rec = request(leego.GET, "/users", map[string]string{leego.HeaderAcceptLanguage: "en", leego.HeaderCacheControl: "no-cache"})
+	assert.Equal(t, "en 3", rec.Body.String())
+	assert.Equal(t, "BYPASS", rec.Header().Get(leego.HeaderXCache))
+	assert.Equal(t, "en 3", request(leego.GET, "/users", en).Body.String())
+
+	// Invalidation of the path and its parent
+	assert.Equal(t, http.StatusNoContent, request(leego.POST, "/users/1", nil).Code)
+	assert.Equal(t, "MISS", request(leego.GET, "/users", en).Header().Get(leego.HeaderXCache))
+
+	// Vary: *
+	assert.Equal(t, "5", request(leego.GET, "/any", nil).Body.String())
+	assert.Equal(t, "6", request(leego.GET, "/any", nil).Body.String())
+}
+
+func TestCacheMemoryStoreLRU(t *testing.T) {
+	store := NewCacheMemoryStoreWithConfig(CacheMemoryStoreConfig{MaxEntries: 2})
+	expires := time.Now().Add(time.Hour)
+	for _, k := range []string{"a", "b"} {
+		assert.NoError(t, store.Set(k, &CacheEntry{Path: "/" + k, Expires: expires}))
+	}
+	e, err := store.Get("a")
+	assert.NoError(t, err)
+	assert.NotNil(t, e)
+	assert.NoError(t, store.Set("c", &CacheEntry{Path: "/c", Expires: expires}))
+	assert.Equal(t, 2, store.Len())
+	e, _ = store.Get("b")
+	assert.Nil(t, e)
+	e, _ = store.Get("a")
+	assert.NotNil(t, e)
+	e, _ = store.Get("c")
+	assert.NotNil(t, e)
+}
@@ -0,0 +1,332 @@
+package middleware
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine"
+)
+
+type (
+	// GzipConfig defines the config for Gzip middleware.
+	GzipConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Gzip/deflate compression level.
+		// Optional. Default value -1 (`gzip.DefaultCompression`).
+		Level int `json:"level"`
+
+		// MinLength is the minimum response size in bytes to compress. Smaller
+		// responses are sent as is, since compressing them saves little.
+		// Optional. Default value 1024.
+		MinLength int `json:"min_length"`
+
+		// Types lists the compressible MIME types. A `type/*` entry matches all
+		// subtypes.
+		// Optional. Default value `DefaultGzipConfig.Types`.
+		Types []string `json:"types"`
+	}
+
+	compressResponse struct {
+		engine.Response
+		config   *GzipConfig
+		pool     *sync.Pool
+		encoding string
+		code     int
+		wrote    bool // Header was written by the handler
+		decided  bool
+		cw       compressWriter
+		buf      []byte
+	}
+
+	compressWriter interface {
+		io.WriteCloser
+		Flush() error
+		Reset(io.Writer)
+	}
+)
+
+const (
+	gzipEncoding    = "gzip"
+	deflateEncoding = "deflate"
+)
+
+var (
+	// DefaultGzipConfig is the default Gzip middleware config.
+	DefaultGzipConfig = GzipConfig{
+		Skipper:   defaultSkipper,
+		Level:     gzip.DefaultCompression,
+		MinLength: 1024,
+		Types: []string{
+			"text/*",
+			leego.MIMEApplicationJSON,
+			leego.MIMEApplicationJavaScript,
+			leego.MIMEApplicationXML,
+			"image/svg+xml",
+		},
+	}
+)
+
+// Gzip returns a middleware which compresses the HTTP response with gzip or
+// deflate, as negotiated with the `Accept-Encoding` request header.
+func Gzip() leego.MiddlewareFunc {
+	return GzipWithConfig(DefaultGzipConfig)
+}
+
+// GzipWithConfig returns a Gzip middleware from config.
+// See `Gzip()`.
+func GzipWithConfig(config GzipConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultGzipConfig.Skipper
+	}
+	if config.Level == 0 {
+		config.Level = DefaultGzipConfig.Level
+	}
+	if config.MinLength == 0 {
+		config.MinLength = DefaultGzipConfig.MinLength
+	}
+	if config.Types == nil {
+		config.Types = DefaultGzipConfig.Types
+	}
+
+	pools := map[string]*sync.Pool{
+		gzipEncoding: {
+			New: func() interface{} {
+				w, err := gzip.NewWriterLevel(nil, config.Level)
+				if err != nil {
+					w = gzip.NewWriter(nil)
+				}
+				return w
+			},
+		},
+		deflateEncoding: {
+			New: func() interface{} {
+				w, err := flate.NewWriter(nil, config.Level)
+				if err != nil {
+					w, _ = flate.NewWriter(nil, flate.DefaultCompression)
+				}
+				return w
+			},
+		},
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			res := c.Response()
+			res.Header().Add(leego.HeaderVary, leego.HeaderAcceptEncoding)
+			encoding := negotiateEncoding(c.Request().Header().Get(leego.HeaderAcceptEncoding))
+			if encoding == "" || c.Request().Method() == leego.HEAD {
+				return next(c)
+			}
+
+			cr := &compressResponse{
+				Response: res,
+				config:   &config,
+				pool:     pools[encoding],
+				encoding: encoding,
+				code:     http.StatusOK,
+			}
+			c.SetResponse(cr)
+			defer c.SetResponse(res)
+			err := next(c)
+			if cerr := cr.close(); err == nil && cerr != nil {
+				return cerr
+			}
+			return err
+		}
+	}
+}
+
+// negotiateEncoding returns the preferred encoding out of gzip and deflate
+// accepted by the client, or "" if neither is.
+func negotiateEncoding(accept string) string {
+	if accept == "" {
+		return ""
+	}
+	gz, df, all := -1.0, -1.0, -1.0
+	for _, part := range strings.Split(accept, ",") {
+		name, q := part, 1.0
+		if i := strings.IndexByte(part, ';'); i >= 0 {
+			name = part[:i]
+			p := strings.TrimSpace(part[i+1:])
+			if strings.HasPrefix(p, "q=") {
+				if v, err := strconv.ParseFloat(p[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case gzipEncoding, "x-gzip":
+			gz = q
+		case deflateEncoding:
+			df = q
+		case "*":
+			all = q
+		}
+	}
+	if gz < 0 {
+		gz = all
+	}
+	if df < 0 {
+		df = all
+	}
+	switch {
+	case gz > 0 && gz >= df:
+		return gzipEncoding
+	case df > 0:
+		return deflateEncoding
+	}
+	return ""
+}
+
+// WriteHeader implements `engine.Response#WriteHeader` function. The header is
+// sent once it's known whether the body is compressed.
+func (r *compressResponse) WriteHeader(code int) {
+	if r.wrote || r.Response.Committed() {
+		return
+	}
+	r.code = code
+	r.wrote = true
+}
+
+// Write implements `engine.Response#Write` function. Writes are buffered until
+// `GzipConfig#MinLength` is reached.
+func (r *compressResponse) Write(b []byte) (int, error) {
+	if !r.wrote {
+		r.WriteHeader(http.StatusOK)
+	}
+	if !r.decided {
+		if len(r.buf)+len(b) < r.config.MinLength {
+			r.buf = append(r.buf, b...)
+			return len(b), nil
+		}
+		if err := r.decide(b, true); err != nil {
+			return 0, err
+		}
+	}
+	if r.cw != nil {
+		return r.cw.Write(b)
+	}
+	return r.Response.Write(b)
+}
+
+// Flush implements `engine.Response#Flush` function. A streamed response is
+// compressed regardless of its size.
+func (r *compressResponse) Flush() {
+	if !r.decided {
+		if !r.wrote {
+			r.WriteHeader(http.StatusOK)
+		}
+		if r.decide(nil, true) != nil {
+			return
+		}
+	}
+	if r.cw != nil {
+		r.cw.Flush()
+	}
+	r.Response.Flush()
+}
+
+// Status implements `engine.Response#Status` function.
+func (r *compressResponse) Status() int {
+	if r.wrote {
+		return r.code
+	}
+	return r.Response.Status()
+}
+
+// Committed implements `engine.Response#Committed` function.
+func (r *compressResponse) Committed() bool {
+	return r.wrote || r.Response.Committed()
+}
+
+// Writer implements `engine.Response#Writer` function.
+func (r *compressResponse) Writer() io.Writer {
+	return r
+}
+
+// decide sends the header, compressed if allowed, followed by the buffered
+// body. Content type is sniffed from the body if it isn't set.
+func (r *compressResponse) decide(b []byte, compress bool) error {
+	r.decided = true
+	h := r.Response.Header()
+	if h.Get(leego.HeaderContentType) == "" {
+		sniff := r.buf
+		if len(sniff) == 0 {
+			sniff = b
+		}
+		if len(sniff) > 0 {
+			h.Set(leego.HeaderContentType, http.DetectContentType(sniff))
+		}
+	}
+	compress = compress &&
+		r.code >= http.StatusOK && r.code != http.StatusNoContent && r.code != http.StatusNotModified &&
+		h.Get(leego.HeaderContentEncoding) == "" &&
+		r.compressible(h.Get(leego.HeaderContentType))
+	if compress {
+		h.Set(leego.HeaderContentEncoding, r.encoding)
+		h.Del(leego.HeaderContentLength)
+		r.cw = r.pool.Get().(compressWriter)
+		r.cw.Reset(r.Response)
+	}
+	r.Response.WriteHeader(r.code)
+	if len(r.buf) == 0 {
+		return nil
+	}
+	buf := r.buf
+	r.buf = nil
+	if r.cw != nil {
+		_, err := r.cw.Write(buf)
+		return err
+	}
+	_, err := r.Response.Write(buf)
+	return err
+}
+
+func (r *compressResponse) compressible(contentType string) bool {
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	contentType = strings.TrimSpace(strings.ToLower(contentType))
+	for _, t := range r.config.Types {
+		if strings.HasSuffix(t, "/*") {
+			if strings.HasPrefix(contentType, t[:len(t)-1]) {
+				return true
+			}
+		} else if contentType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// close sends a response smaller than `GzipConfig#MinLength` as is, or
+// finishes the compressed stream.
+func (r *compressResponse) close() error {
+	if !r.decided {
+		if !r.wrote {
+			return nil
+		}
+		return r.decide(nil, false)
+	}
+	if r.cw == nil {
+		return nil
+	}
+	err := r.cw.Close()
+	r.cw.Reset(nil)
+	r.pool.Put(r.cw)
+	r.cw = nil
+	return err
+}
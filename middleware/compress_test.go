@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGzip(t *testing.T) {
+	large := strings.Repeat("leego ", 1024)
+	serve := func(config GzipConfig, accept string, h leego.HandlerFunc) *httptest.ResponseRecorder {
+		lee := leego.New()
+		lee.Use(GzipWithConfig(config))
+		lee.GET("/", h)
+		req := httptest.NewRequest(leego.GET, "/", nil)
+		if accept != "" {
+			req.Header.Set(leego.HeaderAcceptEncoding, accept)
+		}
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		return rec
+	}
+	text := func(s string) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			return c.String(http.StatusCreated, s)
+		}
+	}
+
+	// Gzip
+	rec := serve(GzipConfig{}, "gzip, deflate", text(large))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "gzip", rec.Header().Get(leego.HeaderContentEncoding))
+	assert.Equal(t, leego.HeaderAcceptEncoding, rec.Header().Get(leego.HeaderVary))
+	r, err := gzip.NewReader(rec.Body)
+	if assert.NoError(t, err) {
+		b, _ := ioutil.ReadAll(r)
+		assert.Equal(t, large, string(b))
+	}
+
+	// Deflate preferred
+	rec = serve(GzipConfig{}, "gzip;q=0.5, deflate", text(large))
+	assert.Equal(t, "deflate", rec.Header().Get(leego.HeaderContentEncoding))
+	b, _ := ioutil.ReadAll(flate.NewReader(rec.Body))
+	assert.Equal(t, large, string(b))
+
+	// Not accepted
+	rec = serve(GzipConfig{}, "gzip;q=0, identity", text(large))
+	assert.Equal(t, "", rec.Header().Get(leego.HeaderContentEncoding))
+	assert.Equal(t, large, rec.Body.String())
+
+	// Below min length
+	rec = serve(GzipConfig{}, "gzip", text("small"))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "", rec.Header().Get(leego.HeaderContentEncoding))
+	assert.Equal(t, "small", rec.Body.String())
+
+	// Not allowed type
+	rec = serve(GzipConfig{}, "gzip", func(c leego.Context) leego.LeeError {
+		c.Response().Header().Set(leego.HeaderContentType, "image/png")
+		_, err := c.Response().Write(bytes.Repeat([]byte{1}, 4096))
+		return err
+	})
+	assert.Equal(t, "", rec.Header().Get(leego.HeaderContentEncoding))
+	assert.Equal(t, 4096, rec.Body.Len())
+
+	// Streaming
+	rec = serve(GzipConfig{MinLength: 1 << 20}, "gzip", func(c leego.Context) leego.LeeError {
+		return c.Stream(http.StatusOK, leego.MIMETextPlain, strings.NewReader("data: 1\n\n"))
+	})
+	assert.Equal(t, "gzip", rec.Header().Get(leego.HeaderContentEncoding))
+	assert.True(t, rec.Flushed)
+	r, err = gzip.NewReader(rec.Body)
+	if assert.NoError(t, err) {
+		b, _ := ioutil.ReadAll(r)
+		assert.Equal(t, "data: 1\n\n", string(b))
+	}
+
+	// Error is handled uncompressed
+	rec = serve(GzipConfig{}, "gzip", func(c leego.Context) leego.LeeError {
+		return leego.ErrUnauthorized
+	})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "", rec.Header().Get(leego.HeaderContentEncoding))
+}
@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// CORSConfig defines the config for CORS middleware.
+	CORSConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// AllowOrigins lists the origins which may access the resource. An origin
+		// may contain `*` wildcards, e.g. `https://*.example.com`.
+		// Optional. Default value []string{"*"}.
+		AllowOrigins []string `json:"allow_origins"`
+
+		// AllowMethods lists the methods allowed when accessing the resource, sent
+		// in response to a preflight request.
+		// Optional. Default value DefaultCORSConfig.AllowMethods.
+		AllowMethods []string `json:"allow_methods"`
+
+		// AllowHeaders lists the request headers which can be used when making
+		// the actual request, sent in response to a preflight request. When empty,
+		// the headers requested by the preflight are allowed.
+		// Optional. Default value []string{}.
+		AllowHeaders []string `json:"allow_headers"`
+
+		// AllowCredentials indicates whether the response can be exposed when the
+		// credentials flag is true. It can't be combined with the `*` origin, as
+		// that would let any site make credentialed requests.
+		// Optional. Default value false.
+		AllowCredentials bool `json:"allow_credentials"`
+
+		// ExposeHeaders lists the response headers clients are allowed to access.
+		// Optional. Default value []string{}.
+		ExposeHeaders []string `json:"expose_headers"`
+
+		// MaxAge indicates how long (in seconds) the results of a preflight request
+		// can be cached.
+		// Optional. Default value 0.
+		MaxAge int `json:"max_age"`
+	}
+)
+
+var (
+	// DefaultCORSConfig is the default CORS middleware config.
+	DefaultCORSConfig = CORSConfig{
+		Skipper:      defaultSkipper,
+		AllowOrigins: []string{"*"},
+		AllowMethods: []string{leego.GET, leego.HEAD, leego.PUT, leego.PATCH, leego.POST, leego.DELETE},
+	}
+)
+
+// CORS returns a Cross-Origin Resource Sharing (CORS) middleware.
+// See: https://developer.mozilla.org/en/docs/Web/HTTP/Access_control_CORS
+//
+// Preflight requests are answered by the middleware, so registered with
+// `Leego#Use()` or `Group#Use()` no OPTIONS route is needed: the router's method
+// not allowed handler is never reached.
+func CORS() leego.MiddlewareFunc {
+	return CORSWithConfig(DefaultCORSConfig)
+}
+
+// CORSWithConfig returns a CORS middleware from config.
+// See `CORS()`.
+func CORSWithConfig(config CORSConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultCORSConfig.Skipper
+	}
+	if len(config.AllowOrigins) == 0 {
+		config.AllowOrigins = DefaultCORSConfig.AllowOrigins
+	}
+	if len(config.AllowMethods) == 0 {
+		config.AllowMethods = DefaultCORSConfig.AllowMethods
+	}
+	if config.AllowCredentials {
+		for _, o := range config.AllowOrigins {
+			if o == "*" {
+				panic("cors middleware allow credentials requires explicit allow origins, not *")
+			}
+		}
+	}
+
+	allowMethods := strings.Join(config.AllowMethods, ",")
+	allowHeaders := strings.Join(config.AllowHeaders, ",")
+	exposeHeaders := strings.Join(config.ExposeHeaders, ",")
+	maxAge := strconv.Itoa(config.MaxAge)
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			req := c.Request()
+			res := c.Response()
+			origin := req.Header().Get(leego.HeaderOrigin)
+			preflight := req.Method() == leego.OPTIONS &&
+				req.Header().Get(leego.HeaderAccessControlRequestMethod) != ""
+
+			res.Header().Add(leego.HeaderVary, leego.HeaderOrigin)
+			allowOrigin := matchCORSOrigin(config.AllowOrigins, origin)
+
+			// Simple request
+			if !preflight {
+				if origin == "" || allowOrigin == "" {
+					return next(c)
+				}
+				res.Header().Set(leego.HeaderAccessControlAllowOrigin, allowOrigin)
+				if config.AllowCredentials {
+					res.Header().Set(leego.HeaderAccessControlAllowCredentials, "true")
+				}
+				if exposeHeaders != "" {
+					res.Header().Set(leego.HeaderAccessControlExposeHeaders, exposeHeaders)
+				}
+				return next(c)
+			}
+
+			// Preflight request
+			res.Header().Add(leego.HeaderVary, leego.HeaderAccessControlRequestMethod)
+			res.Header().Add(leego.HeaderVary, leego.HeaderAccessControlRequestHeaders)
+			if origin == "" || allowOrigin == "" {
+				return c.NoContent(http.StatusNoContent)
+			}
+			res.Header().Set(leego.HeaderAccessControlAllowOrigin, allowOrigin)
+			res.Header().Set(leego.HeaderAccessControlAllowMethods, allowMethods)
+			if config.AllowCredentials {
+				res.Header().Set(leego.HeaderAccessControlAllowCredentials, "true")
+			}
+			if allowHeaders != "" {
+				res.Header().Set(leego.HeaderAccessControlAllowHeaders, allowHeaders)
+			} else if h := req.Header().Get(leego.HeaderAccessControlRequestHeaders); h != "" {
+				res.Header().Set(leego.HeaderAccessControlAllowHeaders, h)
+			}
+			if config.MaxAge > 0 {
+				res.Header().Set(leego.HeaderAccessControlMaxAge, maxAge)
+			}
+			return c.NoContent(http.StatusNoContent)
+		}
+	}
+}
+
+// matchCORSOrigin returns the value of the `Access-Control-Allow-Origin` header
+// for origin, or "" if it's not allowed.
+func matchCORSOrigin(allowed []string, origin string) string {
+	for _, o := range allowed {
+		if o == "*" {
+			return "*"
+		}
+		if origin != "" && matchWildcard(strings.ToLower(o), strings.ToLower(origin)) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// matchWildcard reports whether s matches pattern, where `*` matches any
+// sequence of characters.
+func matchWildcard(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for _, p := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, p)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(p):]
+	}
+	last := parts[len(parts)-1]
+	return len(s) >= len(last) && strings.HasSuffix(s, last)
+}
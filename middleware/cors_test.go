@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORS(t *testing.T) {
+	serve := func(config CORSConfig, method, origin string, header map[string]string) *httptest.ResponseRecorder {
+		lee := leego.New()
+		lee.Use(CORSWithConfig(config))
+		lee.GET("/users", func(c leego.Context) leego.LeeError {
+			return c.String(http.StatusOK, "users")
+		})
+		req := httptest.NewRequest(method, "/users", nil)
+		if origin != "" {
+			req.Header.Set(leego.HeaderOrigin, origin)
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		return rec
+	}
+
+	// Wildcard
+	rec := serve(CORSConfig{}, leego.GET, "http://example.com", nil)
+	assert.Equal(t, "*", rec.Header().Get(leego.HeaderAccessControlAllowOrigin))
+	assert.Equal(t, "users", rec.Body.String())
+
+	// No origin
+	rec = serve(CORSConfig{}, leego.GET, "", nil)
+	assert.Equal(t, "", rec.Header().Get(leego.HeaderAccessControlAllowOrigin))
+
+	// Subdomain wildcard with credentials
+	config := CORSConfig{
+		AllowOrigins:     []string{"https://*.example.com"},
+		AllowCredentials: true,
+		ExposeHeaders:    []string{"X-Total"},
+		MaxAge:           3600,
+	}
+	rec = serve(config, leego.GET, "https://api.example.com", nil)
+	assert.Equal(t, "https://api.example.com", rec.Header().Get(leego.HeaderAccessControlAllowOrigin))
+	assert.Equal(t, "true", rec.Header().Get(leego.HeaderAccessControlAllowCredentials))
+	assert.Equal(t, "X-Total", rec.Header().Get(leego.HeaderAccessControlExposeHeaders))
+
+	rec = serve(config, leego.GET, "https://example.org", nil)
+	assert.Equal(t, "", rec.Header().Get(leego.HeaderAccessControlAllowOrigin))
+	assert.Equal(t, "users", rec.Body.String())
+
+	// Preflight without an OPTIONS route
+	rec = serve(config, leego.OPTIONS, "https://app.example.com", map[string]string{
+		leego.HeaderAccessControlRequestMethod:  leego.GET,
+		leego.HeaderAccessControlRequestHeaders: "Authorization",
+	})
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "https://app.example.com", rec.Header().Get(leego.HeaderAccessControlAllowOrigin))
+	assert.Equal(t, "GET,HEAD,PUT,PATCH,POST,DELETE", rec.Header().Get(leego.HeaderAccessControlAllowMethods))
+	assert.Equal(t, "Authorization", rec.Header().Get(leego.HeaderAccessControlAllowHeaders))
+	assert.Equal(t, "3600", rec.Header().Get(leego.HeaderAccessControlMaxAge))
+
+	// Plain OPTIONS falls through to the router
+	rec = serve(config, leego.OPTIONS, "https://app.example.com", nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	// Credentials with any origin
+	assert.Panics(t, func() {
+		CORSWithConfig(CORSConfig{AllowCredentials: true})
+	})
+	assert.Panics(t, func() {
+		CORSWithConfig(CORSConfig{AllowOrigins: []string{"https://example.com", "*"}, AllowCredentials: true})
+	})
+}
+
+func TestMatchWildcard(t *testing.T) {
+	assert.True(t, matchWildcard("https://*.example.com", "https://a.b.example.com"))
+	assert.True(t, matchWildcard("http://localhost:*", "http://localhost:3000"))
+	assert.False(t, matchWildcard("https://*.example.com", "https://example.com"))
+	assert.False(t, matchWildcard("https://*.example.com", "https://evil.com/.example.com.org"))
+}
@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine"
+)
+
+type (
+	// ETagConfig defines the config for ETag middleware.
+	ETagConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Weak sends weak ETags, e.g. `W/"..."`, for responses which are
+		// equivalent but not byte for byte the same, e.g. compressed.
+		// Optional. Default value false.
+		Weak bool `json:"weak"`
+	}
+
+	// etagResponse buffers the response to hash it. It's sent as is once
+	// flushed, e.g. for streams.
+	etagResponse struct {
+		engine.Response
+		code      int
+		wrote     bool
+		streaming bool
+		buf       bytes.Buffer
+	}
+)
+
+var (
+	// DefaultETagConfig is the default ETag middleware config.
+	DefaultETagConfig = ETagConfig{
+		Skipper: defaultSkipper,
+	}
+)
+
+// ETag returns a middleware which sets the `ETag` of the 200 responses to GET
+// and HEAD requests, the hash of their body, and sends 304 Not Modified if it
+// matches `If-None-Match`. ETags set by the handler, e.g. with
+// `Context#JSONWithETag()`, are kept. `Context#ServeContent()` leaves
+// `If-None-Match` to the middleware, so that files get both `Last-Modified`
+// and `ETag`.
+func ETag() leego.MiddlewareFunc {
+	return ETagWithConfig(DefaultETagConfig)
+}
+
+// ETagWithConfig returns an ETag middleware from config.
+// See `ETag()`.
+func ETagWithConfig(config ETagConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultETagConfig.Skipper
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+			req := c.Request()
+			if m := req.Method(); m != leego.GET && m != leego.HEAD {
+				return next(c)
+			}
+
+			res := c.Response()
+			er := &etagResponse{Response: res, code: http.StatusOK}
+			c.SetResponse(er)
+			err := next(c)
+			c.SetResponse(res)
+			if !er.wrote || er.streaming {
+				return err
+			}
+			b := er.buf.Bytes()
+			if err == nil && er.code == http.StatusOK {
+				etag := res.Header().Get(leego.HeaderETag)
+				if etag == "" {
+					etag = bodyETag(b, config.Weak)
+					res.Header().Set(leego.HeaderETag, etag)
+				}
+				if leego.MatchETag(req.Header().Get(leego.HeaderIfNoneMatch), etag) {
+					res.Header().Del(leego.HeaderContentType)
+					res.Header().Del(leego.HeaderContentLength)
+					res.WriteHeader(http.StatusNotModified)
+					return nil
+				}
+			}
+			res.WriteHeader(er.code)
+			res.Write(b)
+			return err
+		}
+	}
+}
+
+// bodyETag returns the ETag of b, hashed like `leego.ETag()`.
+func bodyETag(b []byte, weak bool) string {
+	sum := sha256.Sum256(b)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	if weak {
+		return "W/" + etag
+	}
+	return etag
+}
+
+// WriteHeader implements `engine.Response#WriteHeader` function. The header is
+// sent once the body is hashed.
+func (r *etagResponse) WriteHeader(code int) {
+	if r.streaming {
+		r.Response.WriteHeader(code)
+		return
+	}
+	if r.wrote || r.Response.Committed() {
+		return
+	}
+	r.code = code
+	r.wrote = true
+}
+
+// Write implements `engine.Response#Write` function.
+func (r *etagResponse) Write(b []byte) (int, error) {
+	if r.streaming {
+		return r.Response.Write(b)
+	}
+	if !r.wrote {
+		r.WriteHeader(http.StatusOK)
+	}
+	return r.buf.Write(b)
+}
+
+// Flush implements `engine.Response#Flush` function. Flushed responses are
+// streamed without ETag.
+func (r *etagResponse) Flush() {
+	if !r.streaming {
+		r.streaming = true
+		if r.wrote {
+			r.Response.WriteHeader(r.code)
+			r.Response.Write(r.buf.Bytes())
+		}
+	}
+	r.Response.Flush()
+}
+
+// Status implements `engine.Response#Status` function.
+func (r *etagResponse) Status() int {
+	if r.wrote && !r.streaming {
+		return r.code
+	}
+	return r.Response.Status()
+}
+
+// Committed implements `engine.Response#Committed` function.
+func (r *etagResponse) Committed() bool {
+	return r.wrote || r.Response.Committed()
+}
+
+// Writer implements `engine.Response#Writer` function.
+func (r *etagResponse) Writer() io.Writer {
+	return r
+}
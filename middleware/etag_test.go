@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestETag(t *testing.T) {
+	modtime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	lee := leego.New()
+	lee.Use(ETag())
+	lee.GET("/users", func(c leego.Context) leego.LeeError {
+		return c.String(http.StatusOK, "users")
+	})
+	lee.POST("/users", func(c leego.Context) leego.LeeError {
+		return c.String(http.StatusCreated, "created")
+	})
+	lee.GET("/tagged", func(c leego.Context) leego.LeeError {
+		c.Response().Header().Set(leego.HeaderETag, `"v1"`)
+		return c.String(http.StatusOK, "tagged")
+	})
+	lee.GET("/file", func(c leego.Context) leego.LeeError {
+		return c.ServeContent(strings.NewReader("file"), "file.txt", modtime)
+	})
+	lee.GET("/stream", func(c leego.Context) leego.LeeError {
+		c.Response().WriteHeader(http.StatusOK)
+		c.Response().Write([]byte("a"))
+		c.Response().Flush()
+		c.Response().Write([]byte("b"))
+		return nil
+	})
+
+	request := func(method, path string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		return rec
+	}
+
+	rec := request(leego.GET, "/users", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "users", rec.Body.String())
+	etag := rec.Header().Get(leego.HeaderETag)
+	assert.Equal(t, bodyETag([]byte("users"), false), etag)
+	rec = request(leego.GET, "/users", map[string]string{leego.HeaderIfNoneMatch: `"other", W/` + etag})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Equal(t, "", rec.Body.String())
+	assert.Equal(t, etag, rec.Header().Get(leego.HeaderETag))
+	assert.Equal(t, http.StatusOK, request(leego.GET, "/users", map[string]string{leego.HeaderIfNoneMatch: `"other"`}).Code)
+
+	// Other methods and statuses
+	rec = request(leego.POST, "/users", map[string]string{leego.HeaderIfNoneMatch: "*"})
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "", rec.Header().Get(leego.HeaderETag))
+	rec = request(leego.GET, "/missing", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "", rec.Header().Get(leego.HeaderETag))
+
+	// ETag of the handler
+	assert.Equal(t, http.StatusNotModified, request(leego.GET, "/tagged", map[string]string{leego.HeaderIfNoneMatch: `"v1"`}).Code)
+
+	// If-None-Match takes precedence over If-Modified-Since
+	since := modtime.Add(time.Hour).Format(http.TimeFormat)
+	assert.Equal(t, http.StatusNotModified, request(leego.GET, "/file", map[string]string{leego.HeaderIfModifiedSince: since}).Code)
+	rec = request(leego.GET, "/file", map[string]string{leego.HeaderIfModifiedSince: since, leego.HeaderIfNoneMatch: `"other"`})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "file", rec.Body.String())
+	assert.NotEqual(t, "", rec.Header().Get(leego.HeaderLastModified))
+	rec = request(leego.GET, "/file", map[string]string{leego.HeaderIfNoneMatch: rec.Header().Get(leego.HeaderETag)})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	// Streams
+	rec = request(leego.GET, "/stream", nil)
+	assert.Equal(t, "ab", rec.Body.String())
+	assert.Equal(t, "", rec.Header().Get(leego.HeaderETag))
+
+	// Weak
+	lee = leego.New()
+	lee.GET("/users", func(c leego.Context) leego.LeeError {
+		return c.String(http.StatusOK, "users")
+	}, ETagWithConfig(ETagConfig{Weak: true}))
+	rec = request(leego.GET, "/users", map[string]string{leego.HeaderIfNoneMatch: etag})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Equal(t, "W/"+etag, rec.Header().Get(leego.HeaderETag))
+}
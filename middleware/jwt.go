@@ -0,0 +1,247 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/hmac"
+	_ "crypto/sha256" // SHA-256 and SHA-384 for HS256 and HS384
+	_ "crypto/sha512" // SHA-512 for HS512
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// JWTConfig defines the config for JWT middleware.
+	JWTConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// SigningKey is the key to validate the token signature.
+		// Required.
+		SigningKey []byte `json:"signing_key"`
+
+		// SigningMethod is the algorithm the token must be signed with, one of
+		// `HS256`, `HS384` and `HS512`.
+		// Optional. Default value HS256.
+		SigningMethod string `json:"signing_method"`
+
+		// ContextKey is the key under which the token claims are stored in the
+		// context, see `Context#Get()`.
+		// Optional. Default value "user".
+		ContextKey string `json:"context_key"`
+
+		// TokenLookup is a string in the form of "<source>:<name>" that is used
+		// to extract the token from the request. Possible values:
+		// - "header:<name>"
+		// - "query:<name>"
+		// - "cookie:<name>"
+		// Optional. Default value "header:Authorization".
+		TokenLookup string `json:"token_lookup"`
+
+		// AuthScheme is the scheme preceding the token in the header lookup.
+		// Optional. Default value "Bearer".
+		AuthScheme string `json:"auth_scheme"`
+
+		// FormatLeeError formats the errors returned by the middleware, see
+		// `Middleware#FormatLeeError()`.
+		// Optional. Default value returns the error as is.
+		FormatLeeError func(err error, middlewareName string) leego.LeeError
+	}
+
+	// JWTClaims are the claims of a validated token, stored in the context under
+	// `JWTConfig#ContextKey`.
+	JWTClaims map[string]interface{}
+)
+
+// JWT signing methods.
+const (
+	HS256 = "HS256"
+	HS384 = "HS384"
+	HS512 = "HS512"
+)
+
+const jwtMiddlewareName = "jwt"
+
+var (
+	// DefaultJWTConfig is the default JWT middleware config.
+	DefaultJWTConfig = JWTConfig{
+		Skipper:        defaultSkipper,
+		SigningMethod:  HS256,
+		ContextKey:     "user",
+		TokenLookup:    "header:" + leego.HeaderAuthorization,
+		AuthScheme:     "Bearer",
+		FormatLeeError: defaultFormatLeeError,
+	}
+
+	// ErrJWTMissing is returned when the request carries no token.
+	ErrJWTMissing = leego.NewHTTPError(http.StatusBadRequest, "missing or malformed jwt")
+
+	// ErrJWTInvalid is returned when the token is malformed, badly signed or
+	// expired.
+	ErrJWTInvalid = leego.NewHTTPError(http.StatusUnauthorized, "invalid or expired jwt")
+
+	jwtHashes = map[string]crypto.Hash{
+		HS256: crypto.SHA256,
+		HS384: crypto.SHA384,
+		HS512: crypto.SHA512,
+	}
+)
+
+// JWT returns a JSON Web Token (JWT) auth middleware.
+//
+// For a valid token, it sets the claims in the context and calls next handler.
+// For an invalid token, it returns `ErrJWTInvalid` (401). For a missing token,
+// it returns `ErrJWTMissing` (400).
+//
+// See: https://jwt.io/introduction
+func JWT(key []byte) leego.MiddlewareFunc {
+	c := DefaultJWTConfig
+	c.SigningKey = key
+	return JWTWithConfig(c)
+}
+
+// JWTWithConfig returns a JWT auth middleware from config.
+// See: `JWT()`.
+func JWTWithConfig(config JWTConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultJWTConfig.Skipper
+	}
+	if config.SigningKey == nil {
+		panic("jwt middleware requires signing key")
+	}
+	if config.SigningMethod == "" {
+		config.SigningMethod = DefaultJWTConfig.SigningMethod
+	}
+	hash, ok := jwtHashes[config.SigningMethod]
+	if !ok {
+		panic("jwt middleware doesn't support signing method " + config.SigningMethod)
+	}
+	if config.ContextKey == "" {
+		config.ContextKey = DefaultJWTConfig.ContextKey
+	}
+	if config.TokenLookup == "" {
+		config.TokenLookup = DefaultJWTConfig.TokenLookup
+	}
+	if config.AuthScheme == "" {
+		config.AuthScheme = DefaultJWTConfig.AuthScheme
+	}
+	if config.FormatLeeError == nil {
+		config.FormatLeeError = DefaultJWTConfig.FormatLeeError
+	}
+
+	// Initialize
+	tokenFrom := valueFrom(jwtMiddlewareName, config.TokenLookup, "header", "query", "cookie")
+	if strings.HasPrefix(config.TokenLookup, "header:") {
+		tokenFrom = valueAfterScheme(tokenFrom, config.AuthScheme)
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			token := tokenFrom(c)
+			if token == "" {
+				return config.FormatLeeError(ErrJWTMissing, jwtMiddlewareName)
+			}
+			claims, err := parseJWT(token, config.SigningMethod, hash, config.SigningKey, time.Now())
+			if err != nil {
+				return config.FormatLeeError(err, jwtMiddlewareName)
+			}
+			c.Set(config.ContextKey, claims)
+			return next(c)
+		}
+	}
+}
+
+// parseJWT validates the signature and the `exp` and `nbf` claims of token and
+// returns its claims.
+func parseJWT(token, method string, hash crypto.Hash, key []byte, now time.Time) (JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrJWTInvalid
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJWTSegment(parts[0], &header); err != nil || header.Alg != method {
+		// The algorithm is fixed by config, never chosen by the token.
+		return nil, ErrJWTInvalid
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrJWTInvalid
+	}
+	mac := hmac.New(hash.New, key)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, ErrJWTInvalid
+	}
+
+	claims := JWTClaims{}
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return nil, ErrJWTInvalid
+	}
+	exp, ok, err := claims.time("exp")
+	if err != nil || ok && !now.Before(exp) {
+		return nil, ErrJWTInvalid
+	}
+	nbf, ok, err := claims.time("nbf")
+	if err != nil || ok && now.Before(nbf) {
+		return nil, ErrJWTInvalid
+	}
+	return claims, nil
+}
+
+func decodeJWTSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(seg, "="))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// time returns the NumericDate claim `name`, and whether it's present. It
+// fails if the claim is present but not a NumericDate, e.g. a string.
+func (c JWTClaims) time(name string) (time.Time, bool, error) {
+	v, ok := c[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	switch v := v.(type) {
+	case float64:
+		return time.Unix(int64(v), 0), true, nil
+	case json.Number:
+		n, err := v.Int64()
+		return time.Unix(n, 0), true, err
+	}
+	return time.Time{}, true, errors.New("jwt: invalid " + name + " claim")
+}
+
+// SignJWT returns a token for claims signed with key, e.g. for tests or issuing
+// tokens from a login handler.
+func SignJWT(claims JWTClaims, method string, key []byte) (string, error) {
+	hash, ok := jwtHashes[method]
+	if !ok {
+		return "", errors.New("unsupported signing method " + method)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	s := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"alg":%q,"typ":"JWT"}`, method))) +
+		"." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(hash.New, key)
+	mac.Write([]byte(s))
+	return s + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWT(t *testing.T) {
+	key := []byte("secret")
+	valid, _ := SignJWT(JWTClaims{"sub": "1", "exp": time.Now().Add(time.Hour).Unix()}, HS256, key)
+	expired, _ := SignJWT(JWTClaims{"sub": "1", "exp": time.Now().Add(-time.Hour).Unix()}, HS256, key)
+	otherKey, _ := SignJWT(JWTClaims{"sub": "1"}, HS256, []byte("other"))
+	otherMethod, _ := SignJWT(JWTClaims{"sub": "1"}, HS512, key)
+	stringExp, _ := SignJWT(JWTClaims{"sub": "1", "exp": "1500000000"}, HS256, key)
+	stringNbf, _ := SignJWT(JWTClaims{"sub": "1", "nbf": "later"}, HS256, key)
+
+	run := func(config JWTConfig, setup func(*http.Request)) (leego.Context, leego.LeeError) {
+		lee := leego.New()
+		req := httptest.NewRequest(leego.GET, "/?token="+valid, nil)
+		setup(req)
+		c := lee.NewContext(standard.NewRequest(req), standard.NewResponse(httptest.NewRecorder()))
+		err := JWTWithConfig(config)(func(c leego.Context) leego.LeeError {
+			return nil
+		})(c)
+		return c, err
+	}
+	bearer := func(token string) func(*http.Request) {
+		return func(req *http.Request) {
+			req.Header.Set(leego.HeaderAuthorization, "Bearer "+token)
+		}
+	}
+	config := JWTConfig{SigningKey: key}
+
+	c, err := run(config, bearer(valid))
+	assert.Nil(t, err)
+	claims, ok := c.Get("user").(JWTClaims)
+	if assert.True(t, ok) {
+		assert.Equal(t, "1", claims["sub"])
+	}
+
+	for _, token := range []string{expired, otherKey, otherMethod, stringExp, stringNbf, "a.b.c"} {
+		_, err = run(config, bearer(token))
+		assert.Equal(t, ErrJWTInvalid, err)
+	}
+
+	_, err = run(config, func(*http.Request) {})
+	assert.Equal(t, ErrJWTMissing, err)
+
+	// Query
+	c, err = run(JWTConfig{SigningKey: key, TokenLookup: "query:token", ContextKey: "claims"}, func(*http.Request) {})
+	assert.Nil(t, err)
+	assert.NotNil(t, c.Get("claims"))
+
+	// Cookie
+	_, err = run(JWTConfig{SigningKey: key, TokenLookup: "cookie:jwt"}, func(req *http.Request) {
+		req.AddCookie(&http.Cookie{Name: "jwt", Value: valid})
+	})
+	assert.Nil(t, err)
+
+	// FormatLeeError
+	_, err = run(JWTConfig{
+		SigningKey: key,
+		FormatLeeError: func(err error, name string) leego.LeeError {
+			return errors.New(name + ": " + err.Error())
+		},
+	}, bearer(expired))
+	assert.Equal(t, "jwt: invalid or expired jwt", err.Error())
+
+	assert.Panics(t, func() {
+		JWTWithConfig(JWTConfig{SigningKey: key, TokenLookup: "cokie:jwt"})
+	})
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// LocaleConfig defines the config for Locale middleware.
+	LocaleConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Languages are the languages offered, the first one for the clients
+		// matching none.
+		// Optional. Default value the languages of the catalog, see
+		// `Leego#SetCatalog()`.
+		Languages []string `json:"languages"`
+
+		// QueryParam is the query param choosing the language, e.g. for a
+		// language switcher, "" to ignore it.
+		// Optional. Default value "lang".
+		QueryParam string `json:"query_param"`
+
+		// CookieName is the cookie remembering the language of the client, ""
+		// to ignore it.
+		// Optional. Default value "lang".
+		CookieName string `json:"cookie_name"`
+	}
+)
+
+var (
+	// DefaultLocaleConfig is the default Locale middleware config.
+	DefaultLocaleConfig = LocaleConfig{
+		Skipper:    defaultSkipper,
+		QueryParam: "lang",
+		CookieName: "lang",
+	}
+)
+
+// Locale returns a middleware which sets the language of the request, see
+// `Context#SetLang()`, to the offered language chosen by the query param, the
+// cookie or else `Accept-Language`, in that order. The language is sent in
+// `Content-Language` for `Context#T()` to translate the responses.
+func Locale(languages ...string) leego.MiddlewareFunc {
+	c := DefaultLocaleConfig
+	c.Languages = languages
+	return LocaleWithConfig(c)
+}
+
+// LocaleWithConfig returns a Locale middleware from config.
+// See `Locale()`.
+func LocaleWithConfig(config LocaleConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultLocaleConfig.Skipper
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			offers := config.Languages
+			if len(offers) == 0 {
+				cat := c.Leego().Catalog()
+				if cat == nil {
+					panic("locale middleware requires languages or a catalog")
+				}
+				offers = cat.Languages()
+			}
+
+			lang := ""
+			if config.QueryParam != "" {
+				lang = matchLocale(c.QueryParam(config.QueryParam), offers)
+			}
+			if lang == "" && config.CookieName != "" {
+				if cookie, err := c.Cookie(config.CookieName); err == nil {
+					lang = matchLocale(cookie.Value(), offers)
+				}
+			}
+			res := c.Response()
+			if lang == "" {
+				lang = c.AcceptsLanguage(offers...)
+				res.Header().Add(leego.HeaderVary, leego.HeaderAcceptLanguage)
+			}
+			if lang == "" {
+				lang = offers[0]
+			}
+			c.SetLang(lang)
+			res.Header().Set(leego.HeaderContentLanguage, lang)
+			return next(c)
+		}
+	}
+}
+
+// matchLocale returns the offer matching the language tag, exactly or by
+// base language, "" if none does.
+func matchLocale(tag string, offers []string) string {
+	if tag == "" {
+		return ""
+	}
+	base := tag
+	if i := strings.IndexByte(tag, '-'); i >= 0 {
+		base = tag[:i]
+	}
+	match := ""
+	for _, o := range offers {
+		if strings.EqualFold(o, tag) {
+			return o
+		}
+		if match == "" && (strings.EqualFold(o, base) || strings.HasPrefix(strings.ToLower(o), strings.ToLower(base)+"-")) {
+			match = o
+		}
+	}
+	return match
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocale(t *testing.T) {
+	lee := leego.New()
+	cat := leego.NewCatalog("en")
+	cat.Add("fr", map[string]string{"hello": "Bonjour"})
+	cat.Add("pt-BR", map[string]string{"hello": "Olá"})
+	lee.SetCatalog(cat)
+	h := Locale()(func(c leego.Context) leego.LeeError {
+		return c.String(http.StatusOK, c.T("hello"))
+	})
+	request := func(target, acceptLanguage, cookie string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(leego.GET, target, nil)
+		req.Header.Set(leego.HeaderAcceptLanguage, acceptLanguage)
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "lang", Value: cookie})
+		}
+		rec := httptest.NewRecorder()
+		c := lee.NewContext(standard.NewRequest(req), standard.NewResponse(rec))
+		h(c)
+		return rec
+	}
+
+	rec := request("/", "fr-CH, fr;q=0.9, en;q=0.8", "")
+	assert.Equal(t, "Bonjour", rec.Body.String())
+	assert.Equal(t, "fr", rec.Header().Get(leego.HeaderContentLanguage))
+	assert.Equal(t, leego.HeaderAcceptLanguage, rec.Header().Get(leego.HeaderVary))
+
+	// Query param, then cookie
+	rec = request("/?lang=pt", "fr", "en")
+	assert.Equal(t, "pt-BR", rec.Header().Get(leego.HeaderContentLanguage))
+	assert.Empty(t, rec.Header().Get(leego.HeaderVary))
+	rec = request("/?lang=xx", "fr", "en")
+	assert.Equal(t, "hello", rec.Body.String())
+	assert.Equal(t, "en", rec.Header().Get(leego.HeaderContentLanguage))
+
+	// No match
+	rec = request("/", "de", "")
+	assert.Equal(t, "en", rec.Header().Get(leego.HeaderContentLanguage))
+
+	// Offered languages
+	h = Locale("de", "fr")(func(c leego.Context) leego.LeeError {
+		return c.String(http.StatusOK, c.Language())
+	})
+	rec = request("/", "", "")
+	assert.Equal(t, "de", rec.Body.String())
+}
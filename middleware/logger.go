@@ -0,0 +1,325 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine"
+)
+
+type (
+	// LoggerConfig defines the config for Logger middleware.
+	LoggerConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Output is a writer where logs are written.
+		// Optional. Default value os.Stdout.
+		Output io.Writer
+
+		// Format is the template of the log line. Tags are written as `${tag}`:
+		// - time (RFC 3339), time_unix
+		// - id (request ID), remote_ip, host, method, uri, path, user_agent
+		// - status, error
+		// - latency (in microseconds), latency_human
+		// - bytes_in, bytes_out
+		// - conn_reused, conn_requests, tls_version, tls_cipher_suite, see
+		//   `Context#ConnInfo()`
+		// Optional. Default value
+		// "${time} ${remote_ip} ${method} ${uri} ${status} ${latency}µs ${bytes_in} ${bytes_out}".
+		Format string `json:"format"`
+
+		// JSON writes the fields as one JSON object per line instead of the
+		// format.
+		// Optional. Default value false.
+		JSON bool `json:"json"`
+
+		// Callback is called with the fields of the request instead of writing
+		// the log line, e.g. to send them to a structured logger.
+		// Optional. Default value nil.
+		Callback func(c leego.Context, fields LogFields)
+	}
+
+	// LogFields are the fields of an access log entry.
+	LogFields struct {
+		Time      time.Time     `json:"time"`
+		RequestID string        `json:"id,omitempty"`
+		RemoteIP  string        `json:"remote_ip"`
+		Host      string        `json:"host"`
+		Method    string        `json:"method"`
+		URI       string        `json:"uri"`
+		Path      string        `json:"path"`
+		UserAgent string        `json:"user_agent"`
+		Status    int           `json:"status"`
+		Error     string        `json:"error,omitempty"`
+		Latency   time.Duration `json:"latency"`
+		BytesIn   int64         `json:"bytes_in"`
+		BytesOut  int64         `json:"bytes_out"`
+
+		// Keep-alive and TLS statistics of the connection.
+		ConnReused     bool   `json:"conn_reused"`
+		ConnRequests   int64  `json:"conn_requests"`
+		TLSVersion     string `json:"tls_version,omitempty"`
+		TLSCipherSuite string `json:"tls_cipher_suite,omitempty"`
+	}
+
+	// logSegment is a literal or a tag of a compiled `LoggerConfig#Format`.
+	logSegment struct {
+		literal string
+		tag     func(b []byte, f *LogFields) []byte
+	}
+)
+
+var (
+	// DefaultLoggerConfig is the default Logger middleware config.
+	DefaultLoggerConfig = LoggerConfig{
+		Skipper: defaultSkipper,
+		Output:  os.Stdout,
+	}
+
+	logBufferPool = sync.Pool{
+		New: func() interface{} {
+			b := make([]byte, 0, 256)
+			return &b
+		},
+	}
+)
+
+// logTags are the tags of `LoggerConfig#Format`.
+var logTags = map[string]func(b []byte, f *LogFields) []byte{
+	"time": func(b []byte, f *LogFields) []byte {
+		return f.Time.AppendFormat(b, time.RFC3339)
+	},
+	"time_unix": func(b []byte, f *LogFields) []byte {
+		return strconv.AppendInt(b, f.Time.Unix(), 10)
+	},
+	"id": func(b []byte, f *LogFields) []byte {
+		return append(b, f.RequestID...)
+	},
+	"remote_ip": func(b []byte, f *LogFields) []byte {
+		return append(b, f.RemoteIP...)
+	},
+	"host": func(b []byte, f *LogFields) []byte {
+		return append(b, f.Host...)
+	},
+	"method": func(b []byte, f *LogFields) []byte {
+		return append(b, f.Method...)
+	},
+	"uri": func(b []byte, f *LogFields) []byte {
+		return append(b, f.URI...)
+	},
+	"path": func(b []byte, f *LogFields) []byte {
+		return append(b, f.Path...)
+	},
+	"user_agent": func(b []byte, f *LogFields) []byte {
+		return append(b, f.UserAgent...)
+	},
+	"status": func(b []byte, f *LogFields) []byte {
+		return strconv.AppendInt(b, int64(f.Status), 10)
+	},
+	"error": func(b []byte, f *LogFields) []byte {
+		return append(b, f.Error...)
+	},
+	"latency": func(b []byte, f *LogFields) []byte {
+		return strconv.AppendInt(b, int64(f.Latency/time.Microsecond), 10)
+	},
+	"latency_human": func(b []byte, f *LogFields) []byte {
+		return append(b, f.Latency.String()...)
+	},
+	"bytes_in": func(b []byte, f *LogFields) []byte {
+		return strconv.AppendInt(b, f.BytesIn, 10)
+	},
+	"bytes_out": func(b []byte, f *LogFields) []byte {
+		return strconv.AppendInt(b, f.BytesOut, 10)
+	},
+	"conn_reused": func(b []byte, f *LogFields) []byte {
+		return strconv.AppendBool(b, f.ConnReused)
+	},
+	"conn_requests": func(b []byte, f *LogFields) []byte {
+		return strconv.AppendInt(b, f.ConnRequests, 10)
+	},
+	"tls_version": func(b []byte, f *LogFields) []byte {
+		return append(b, f.TLSVersion...)
+	},
+	"tls_cipher_suite": func(b []byte, f *LogFields) []byte {
+		return append(b, f.TLSCipherSuite...)
+	},
+}
+
+// Logger returns a middleware that logs one access log line per HTTP request.
+func Logger() leego.MiddlewareFunc {
+	return LoggerWithConfig(DefaultLoggerConfig)
+}
+
+// LoggerWithConfig returns a Logger middleware from config.
+// See `Logger()`.
+//
+// The line is built in a pooled buffer with `strconv.Append*` and
+// `time.AppendFormat`, so the hot path does not allocate.
+func LoggerWithConfig(config LoggerConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultLoggerConfig.Skipper
+	}
+	if config.Output == nil {
+		config.Output = DefaultLoggerConfig.Output
+	}
+
+	// Initialize
+	var segments []logSegment
+	if config.Format != "" {
+		segments = compileLogFormat(config.Format)
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) (err leego.LeeError) {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			start := time.Now()
+			err = next(c)
+			stop := time.Now()
+
+			if config.Callback == nil && !config.JSON && segments == nil {
+				bp := logBufferPool.Get().(*[]byte)
+				b := appendAccessLog((*bp)[:0], c, err, start, stop)
+				config.Output.Write(b)
+				*bp = b
+				logBufferPool.Put(bp)
+				return
+			}
+
+			fields := newLogFields(c, err, start, stop)
+			switch {
+			case config.Callback != nil:
+				config.Callback(c, fields)
+			case config.JSON:
+				if b, jerr := json.Marshal(&fields); jerr == nil {
+					config.Output.Write(append(b, '\n'))
+				}
+			default:
+				bp := logBufferPool.Get().(*[]byte)
+				b := (*bp)[:0]
+				for _, s := range segments {
+					if s.tag != nil {
+						b = s.tag(b, &fields)
+					} else {
+						b = append(b, s.literal...)
+					}
+				}
+				b = append(b, '\n')
+				config.Output.Write(b)
+				*bp = b
+				logBufferPool.Put(bp)
+			}
+			return
+		}
+	}
+}
+
+// compileLogFormat splits format into literals and tags. Unknown tags are kept
+// as literals.
+func compileLogFormat(format string) (segments []logSegment) {
+	for format != "" {
+		i := strings.Index(format, "${")
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(format[i:], '}')
+		if j < 0 {
+			break
+		}
+		j += i
+		if tag, ok := logTags[format[i+2:j]]; ok {
+			if i > 0 {
+				segments = append(segments, logSegment{literal: format[:i]})
+			}
+			segments = append(segments, logSegment{tag: tag})
+		} else {
+			segments = append(segments, logSegment{literal: format[:j+1]})
+		}
+		format = format[j+1:]
+	}
+	if format != "" {
+		segments = append(segments, logSegment{literal: format})
+	}
+	return
+}
+
+// newLogFields returns the access log fields of the request.
+func newLogFields(c leego.Context, err leego.LeeError, start, stop time.Time) LogFields {
+	req := c.Request()
+	res := c.Response()
+	f := LogFields{
+		Time:      stop,
+		RequestID: c.RequestID(),
+		RemoteIP:  c.RealIP(),
+		Host:      req.Host(),
+		Method:    req.Method(),
+		URI:       req.URI(),
+		Path:      req.URL().Path(),
+		UserAgent: req.UserAgent(),
+		Status:    responseStatus(res, err),
+		Latency:   stop.Sub(start),
+		BytesIn:   req.ContentLength(),
+		BytesOut:  res.Size(),
+	}
+	conn := c.ConnInfo()
+	f.ConnReused = conn.Reused
+	f.ConnRequests = conn.Requests
+	f.TLSVersion = conn.TLSVersion
+	f.TLSCipherSuite = conn.TLSCipherSuite
+	if err != nil {
+		f.Error = err.Error()
+	}
+	return f
+}
+
+// appendAccessLog appends
+// `time remote_ip method uri status latency bytes_in bytes_out` to b.
+func appendAccessLog(b []byte, c leego.Context, err leego.LeeError, start, stop time.Time) []byte {
+	req := c.Request()
+	res := c.Response()
+
+	b = stop.AppendFormat(b, time.RFC3339)
+	b = append(b, ' ')
+	b = append(b, c.RealIP()...)
+	b = append(b, ' ')
+	b = append(b, req.Method()...)
+	b = append(b, ' ')
+	b = append(b, req.URI()...)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(responseStatus(res, err)), 10)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(stop.Sub(start)/time.Microsecond), 10)
+	b = append(b, "µs "...)
+	b = strconv.AppendInt(b, req.ContentLength(), 10)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, res.Size(), 10)
+	b = append(b, '\n')
+	return b
+}
+
+// responseStatus returns the status that is sent for the request. The error
+// handler runs after the middleware chain, so an error's status is derived
+// from the error itself.
+func responseStatus(res engine.Response, err leego.LeeError) int {
+	if err != nil {
+		if he, ok := err.(*leego.HTTPError); ok {
+			return he.Code
+		}
+		return http.StatusInternalServerError
+	}
+	if s := res.Status(); s != 0 {
+		return s
+	}
+	return http.StatusOK
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogger(t *testing.T) {
+	lee := leego.New()
+	buf := new(bytes.Buffer)
+	r := httptest.NewRequest(leego.GET, "/users/1?q=a", nil)
+	r.RemoteAddr = "10.0.0.1:5000"
+	req := standard.NewRequest(r)
+	rec := standard.NewResponse(httptest.NewRecorder())
+	c := lee.NewContext(req, rec)
+	h := LoggerWithConfig(LoggerConfig{Output: buf})(func(c leego.Context) leego.LeeError {
+		return c.String(http.StatusOK, "hello")
+	})
+	h(c)
+	fields := strings.Fields(buf.String())
+	assert.Equal(t, 8, len(fields))
+	assert.Equal(t, "10.0.0.1", fields[1])
+	assert.Equal(t, "GET", fields[2])
+	assert.Equal(t, "/users/1?q=a", fields[3])
+	assert.Equal(t, "200", fields[4])
+	assert.Equal(t, "5", fields[7])
+
+	// Error status
+	buf.Reset()
+	req.Header().Set(leego.HeaderXForwardedFor, "1.2.3.4, 10.0.0.2")
+	h = LoggerWithConfig(LoggerConfig{Output: buf})(func(c leego.Context) leego.LeeError {
+		return leego.ErrNotFound
+	})
+	h(lee.NewContext(req, standard.NewResponse(httptest.NewRecorder())))
+	fields = strings.Fields(buf.String())
+	assert.Equal(t, "10.0.0.1", fields[1])
+	assert.Equal(t, "404", fields[4])
+
+	// Trusted proxies
+	buf.Reset()
+	assert.NoError(t, lee.SetTrustedProxies("10.0.0.0/8"))
+	h(lee.NewContext(req, standard.NewResponse(httptest.NewRecorder())))
+	fields = strings.Fields(buf.String())
+	assert.Equal(t, "1.2.3.4", fields[1])
+}
+
+func TestLoggerFormat(t *testing.T) {
+	lee := leego.New()
+	buf := new(bytes.Buffer)
+	r := httptest.NewRequest(leego.POST, "/users?q=a", strings.NewReader("body"))
+	r.Header.Set(leego.HeaderXRequestID, "abc")
+	req := standard.NewRequest(r)
+	run := func(config LoggerConfig, h leego.HandlerFunc) {
+		buf.Reset()
+		c := lee.NewContext(req, standard.NewResponse(httptest.NewRecorder()))
+		LoggerWithConfig(config)(h)(c)
+	}
+	ok := func(c leego.Context) leego.LeeError {
+		return c.String(http.StatusCreated, "hello")
+	}
+
+	// Template
+	run(LoggerConfig{Output: buf, Format: "${id} ${method} ${path} ${status} ${bytes_in}/${bytes_out} ${unknown}"}, ok)
+	assert.Equal(t, "abc POST /users 201 4/5 ${unknown}\n", buf.String())
+	run(LoggerConfig{Output: buf, Format: "${conn_reused} ${conn_requests} ${tls_version}"}, ok)
+	assert.Equal(t, "false 0 \n", buf.String())
+
+	// JSON
+	run(LoggerConfig{Output: buf, JSON: true}, func(c leego.Context) leego.LeeError {
+		return leego.ErrUnauthorized
+	})
+	var f LogFields
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &f))
+	assert.Equal(t, "abc", f.RequestID)
+	assert.Equal(t, "/users?q=a", f.URI)
+	assert.Equal(t, http.StatusUnauthorized, f.Status)
+	assert.Equal(t, http.StatusText(http.StatusUnauthorized), f.Error)
+
+	// Callback
+	var fields LogFields
+	run(LoggerConfig{Output: buf, Callback: func(c leego.Context, f LogFields) {
+		fields = f
+	}}, ok)
+	assert.Empty(t, buf.String())
+	assert.Equal(t, http.StatusCreated, fields.Status)
+	assert.Equal(t, int64(5), fields.BytesOut)
+}
+
+func BenchmarkLogger(b *testing.B) {
+	lee := leego.New()
+	req := standard.NewRequest(httptest.NewRequest(leego.GET, "/users/1", nil))
+	res := standard.NewResponse(httptest.NewRecorder())
+	c := lee.NewContext(req, res)
+	h := LoggerWithConfig(LoggerConfig{Output: ioutil.Discard})(func(c leego.Context) leego.LeeError {
+		return nil
+	})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h(c)
+	}
+}
@@ -0,0 +1,347 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// LoginThrottleConfig defines the config for LoginThrottle middleware.
+	LoginThrottleConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Store keeps the login failures of every identifier and IP.
+		// Required.
+		Store LoginThrottleStore
+
+		// IdentifierExtractor returns the account the request logs in, e.g.
+		// from a form value. Handlers which only know it after binding the body
+		// pass it to `CheckLogin()` instead.
+		// Optional. Default value nil, only the IP is checked up front.
+		IdentifierExtractor func(c leego.Context) (string, error)
+
+		// Identifier is the policy of the failures of an account.
+		// Optional. Default value 3 free attempts, then 1 second doubling up
+		// to 1 minute, and 15 minutes of lockout after 10 failures.
+		Identifier LoginThrottlePolicy `json:"identifier"`
+
+		// IP is the policy of the failures of a client IP, more lenient as
+		// many users may share it.
+		// Optional. Default value 20 free attempts, then 1 second doubling up
+		// to 1 minute, and 1 hour of lockout after 100 failures.
+		IP LoginThrottlePolicy `json:"ip"`
+
+		// FormatLeeError formats the errors returned by the middleware, see
+		// `Middleware#FormatLeeError()`.
+		// Optional. Default value returns the error as is.
+		FormatLeeError func(err error, middlewareName string) leego.LeeError
+	}
+
+	// LoginThrottlePolicy defines how long login is blocked after failures.
+	LoginThrottlePolicy struct {
+		// FreeAttempts is the number of failures without delay.
+		FreeAttempts int `json:"free_attempts"`
+
+		// BaseDelay is the delay after the first failure beyond FreeAttempts,
+		// doubled by every further failure.
+		BaseDelay time.Duration `json:"base_delay"`
+
+		// MaxDelay caps the delay.
+		MaxDelay time.Duration `json:"max_delay"`
+
+		// LockoutThreshold is the number of failures locking login out for
+		// LockoutDuration, 0 for no lockout.
+		LockoutThreshold int `json:"lockout_threshold"`
+
+		// LockoutDuration is the duration of a lockout.
+		LockoutDuration time.Duration `json:"lockout_duration"`
+	}
+
+	// LoginFailures are the consecutive login failures of a key.
+	LoginFailures struct {
+		Count int       `json:"count"`
+		Last  time.Time `json:"last"`
+	}
+
+	// LoginThrottleStore is the storage of the LoginThrottle middleware.
+	// Identifiers and IPs are stored under the keys "id:<identifier>" and
+	// "ip:<ip>".
+	LoginThrottleStore interface {
+		// Get returns the failures of key, zero if there are none.
+		Get(key string) (LoginFailures, error)
+
+		// Fail records a failure of key and returns its failures.
+		Fail(key string) (LoginFailures, error)
+
+		// Reset forgets the failures of key, e.g. to unlock an account.
+		Reset(key string) error
+	}
+
+	// LoginThrottleMemoryStore is an in-memory `LoginThrottleStore`.
+	LoginThrottleMemoryStore struct {
+		expiresIn time.Duration
+		mu        sync.Mutex
+		failures  map[string]LoginFailures
+		lastScan  time.Time
+		now       func() time.Time
+	}
+
+	// loginThrottleState is the login attempt of a request.
+	loginThrottleState struct {
+		config     *LoginThrottleConfig
+		ip         string
+		identifier string
+		reported   bool
+	}
+
+	loginThrottleContextKey struct{}
+)
+
+const loginThrottleMiddlewareName = "login-throttle"
+
+var (
+	// DefaultLoginThrottleConfig is the default LoginThrottle middleware config.
+	DefaultLoginThrottleConfig = LoginThrottleConfig{
+		Skipper: defaultSkipper,
+		Identifier: LoginThrottlePolicy{
+			FreeAttempts:     3,
+			BaseDelay:        time.Second,
+			MaxDelay:         time.Minute,
+			LockoutThreshold: 10,
+			LockoutDuration:  15 * time.Minute,
+		},
+		IP: LoginThrottlePolicy{
+			FreeAttempts:     20,
+			BaseDelay:        time.Second,
+			MaxDelay:         time.Minute,
+			LockoutThreshold: 100,
+			LockoutDuration:  time.Hour,
+		},
+		FormatLeeError: defaultFormatLeeError,
+	}
+
+	// ErrLoginThrottled is returned when login is blocked after failures.
+	ErrLoginThrottled = leego.NewHTTPError(http.StatusTooManyRequests, "too many login attempts")
+
+	errNoLoginThrottle = errors.New("login throttle middleware not used")
+)
+
+// LoginThrottle returns a middleware protecting the login routes against
+// brute force with store. Login failures delay further attempts exponentially,
+// per account and per client IP, until a temporary lockout.
+//
+// Handlers report the outcome with `LoginFailed()` and `LoginSucceeded()`; an
+// unreported 401 counts as a failure. A blocked attempt gets `Retry-After` and
+// `ErrLoginThrottled` (429) is returned to the error handler.
+func LoginThrottle(store LoginThrottleStore) leego.MiddlewareFunc {
+	c := DefaultLoginThrottleConfig
+	c.Store = store
+	return LoginThrottleWithConfig(c)
+}
+
+// LoginThrottleWithConfig returns a LoginThrottle middleware from config.
+// See `LoginThrottle()`.
+func LoginThrottleWithConfig(config LoginThrottleConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultLoginThrottleConfig.Skipper
+	}
+	if config.Store == nil {
+		panic("login throttle middleware requires store")
+	}
+	if config.Identifier == (LoginThrottlePolicy{}) {
+		config.Identifier = DefaultLoginThrottleConfig.Identifier
+	}
+	if config.IP == (LoginThrottlePolicy{}) {
+		config.IP = DefaultLoginThrottleConfig.IP
+	}
+	if config.FormatLeeError == nil {
+		config.FormatLeeError = DefaultLoginThrottleConfig.FormatLeeError
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			st := &loginThrottleState{config: &config, ip: c.RealIP()}
+			c.Set(loginThrottleContextKey{}, st)
+			if err := st.check(c, "ip:"+st.ip, config.IP); err != nil {
+				return config.FormatLeeError(err, loginThrottleMiddlewareName)
+			}
+			if config.IdentifierExtractor != nil {
+				id, err := config.IdentifierExtractor(c)
+				if err != nil {
+					return config.FormatLeeError(err, loginThrottleMiddlewareName)
+				}
+				if err = CheckLogin(c, id); err != nil {
+					return config.FormatLeeError(err, loginThrottleMiddlewareName)
+				}
+			}
+
+			err := next(c)
+			if !st.reported && isUnauthorized(c, err) {
+				if ferr := LoginFailed(c); ferr != nil && err == nil {
+					return config.FormatLeeError(ferr, loginThrottleMiddlewareName)
+				}
+			}
+			return err
+		}
+	}
+}
+
+// CheckLogin checks that login to the account identifier isn't blocked and
+// records it as the account of the request. It returns `ErrLoginThrottled`,
+// setting `Retry-After`, if it is.
+func CheckLogin(c leego.Context, identifier string) error {
+	st := loginThrottleFrom(c)
+	if st == nil {
+		return errNoLoginThrottle
+	}
+	st.identifier = identifier
+	if identifier == "" {
+		return nil
+	}
+	return st.check(c, "id:"+identifier, st.config.Identifier)
+}
+
+// LoginFailed records a failed login of the request, for its IP and account.
+func LoginFailed(c leego.Context) error {
+	st := loginThrottleFrom(c)
+	if st == nil {
+		return errNoLoginThrottle
+	}
+	st.reported = true
+	if _, err := st.config.Store.Fail("ip:" + st.ip); err != nil {
+		return err
+	}
+	if st.identifier != "" {
+		if _, err := st.config.Store.Fail("id:" + st.identifier); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoginSucceeded records a successful login of the request, forgetting the
+// failures of its account. The failures of the IP are kept, so that an
+// attacker owning an account can't reset them.
+func LoginSucceeded(c leego.Context) error {
+	st := loginThrottleFrom(c)
+	if st == nil {
+		return errNoLoginThrottle
+	}
+	st.reported = true
+	if st.identifier == "" {
+		return nil
+	}
+	return st.config.Store.Reset("id:" + st.identifier)
+}
+
+// Wait returns how long login is blocked at now after failures f, zero if it
+// isn't.
+func (p LoginThrottlePolicy) Wait(f LoginFailures, now time.Time) time.Duration {
+	var d time.Duration
+	switch {
+	case p.LockoutThreshold > 0 && f.Count >= p.LockoutThreshold:
+		d = p.LockoutDuration
+	case f.Count > p.FreeAttempts:
+		d = p.BaseDelay
+		for i := p.FreeAttempts + 1; i < f.Count && d < p.MaxDelay; i++ {
+			d *= 2
+		}
+		if d > p.MaxDelay {
+			d = p.MaxDelay
+		}
+	}
+	if wait := f.Last.Add(d).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+func loginThrottleFrom(c leego.Context) *loginThrottleState {
+	st, _ := c.Get(loginThrottleContextKey{}).(*loginThrottleState)
+	return st
+}
+
+func (st *loginThrottleState) check(c leego.Context, key string, p LoginThrottlePolicy) error {
+	f, err := st.config.Store.Get(key)
+	if err != nil {
+		return err
+	}
+	if wait := p.Wait(f, time.Now()); wait > 0 {
+		c.Response().Header().Set(leego.HeaderRetryAfter, seconds(wait))
+		return ErrLoginThrottled
+	}
+	return nil
+}
+
+func isUnauthorized(c leego.Context, err error) bool {
+	if he, ok := err.(*leego.HTTPError); ok {
+		return he.Code == http.StatusUnauthorized
+	}
+	return err == nil && c.Response().Status() == http.StatusUnauthorized
+}
+
+// NewLoginThrottleMemoryStore returns an in-memory store forgetting the
+// failures of a key expiresIn after the last one.
+func NewLoginThrottleMemoryStore(expiresIn time.Duration) *LoginThrottleMemoryStore {
+	if expiresIn <= 0 {
+		panic("login throttle memory store requires a positive expiration")
+	}
+	return &LoginThrottleMemoryStore{
+		expiresIn: expiresIn,
+		failures:  make(map[string]LoginFailures),
+		now:       time.Now,
+	}
+}
+
+// Get implements `LoginThrottleStore#Get()`.
+func (s *LoginThrottleMemoryStore) Get(key string) (LoginFailures, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := s.failures[key]
+	if s.now().Sub(f.Last) >= s.expiresIn {
+		return LoginFailures{}, nil
+	}
+	return f, nil
+}
+
+// Fail implements `LoginThrottleStore#Fail()`. Expired failures are removed
+// every minute on the way.
+func (s *LoginThrottleMemoryStore) Fail(key string) (LoginFailures, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.lastScan) >= time.Minute {
+		for k, f := range s.failures {
+			if now.Sub(f.Last) >= s.expiresIn {
+				delete(s.failures, k)
+			}
+		}
+		s.lastScan = now
+	}
+	f := s.failures[key]
+	if now.Sub(f.Last) >= s.expiresIn {
+		f = LoginFailures{}
+	}
+	f.Count++
+	f.Last = now
+	s.failures[key] = f
+	return f, nil
+}
+
+// Reset implements `LoginThrottleStore#Reset()`.
+func (s *LoginThrottleMemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.failures, key)
+	return nil
+}
@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginThrottle(t *testing.T) {
+	store := NewLoginThrottleMemoryStore(time.Hour)
+	lee := leego.New()
+	lee.Use(LoginThrottleWithConfig(LoginThrottleConfig{
+		Store: store,
+		IdentifierExtractor: func(c leego.Context) (string, error) {
+			return c.FormValue("user"), nil
+		},
+		Identifier: LoginThrottlePolicy{
+			FreeAttempts: 2,
+			BaseDelay:    time.Minute,
+			MaxDelay:     time.Hour,
+		},
+	}))
+	lee.POST("/login", func(c leego.Context) leego.LeeError {
+		if c.FormValue("password") != "secret" {
+			return leego.ErrUnauthorized
+		}
+		if err := LoginSucceeded(c); err != nil {
+			return err
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+	login := func(user, password, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(leego.POST, "/login", strings.NewReader("user="+user+"&password="+password))
+		req.Header.Set(leego.HeaderContentType, leego.MIMEApplicationForm)
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		return rec
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, login("joe", "guess", "192.0.2.1").Code)
+	}
+	rec := login("joe", "secret", "192.0.2.2")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get(leego.HeaderRetryAfter))
+	assert.Equal(t, http.StatusNoContent, login("ann", "secret", "192.0.2.1").Code)
+
+	// Success forgets the failures of the account, not of the IP
+	store.Reset("id:joe")
+	login("joe", "guess", "192.0.2.1")
+	assert.Equal(t, http.StatusNoContent, login("joe", "secret", "192.0.2.1").Code)
+	f, _ := store.Get("id:joe")
+	assert.Equal(t, 0, f.Count)
+	f, _ = store.Get("ip:192.0.2.1")
+	assert.Equal(t, 4, f.Count)
+
+	// Failures expire
+	store.now = func() time.Time { return time.Now().Add(-2 * time.Hour) }
+	for i := 0; i < 3; i++ {
+		store.Fail("id:joe")
+	}
+	store.now = time.Now
+	assert.Equal(t, http.StatusNoContent, login("joe", "secret", "192.0.2.1").Code)
+
+	// Account known by the handler
+	lee = leego.New()
+	lee.Use(LoginThrottle(store))
+	lee.POST("/login", func(c leego.Context) leego.LeeError {
+		if err := CheckLogin(c, "bob"); err != nil {
+			return err
+		}
+		return LoginFailed(c)
+	})
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, http.StatusOK, login("", "", "192.0.2.3").Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, login("", "", "192.0.2.4").Code)
+
+	assert.Panics(t, func() {
+		LoginThrottleWithConfig(LoginThrottleConfig{})
+	})
+}
+
+func TestLoginThrottlePolicy(t *testing.T) {
+	p := LoginThrottlePolicy{
+		FreeAttempts:     2,
+		BaseDelay:        time.Second,
+		MaxDelay:         10 * time.Second,
+		LockoutThreshold: 10,
+		LockoutDuration:  time.Hour,
+	}
+	now := time.Now()
+	for count, wait := range map[int]time.Duration{
+		2:  0,
+		3:  time.Second,
+		4:  2 * time.Second,
+		5:  4 * time.Second,
+		7:  10 * time.Second,
+		10: time.Hour,
+	} {
+		assert.Equal(t, wait, p.Wait(LoginFailures{Count: count, Last: now}, now), count)
+	}
+	assert.Equal(t, time.Duration(0), p.Wait(LoginFailures{Count: 3, Last: now}, now.Add(time.Second)))
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// valueExtractor extracts a value from the request, "" if there is none.
+	valueExtractor func(leego.Context) string
+)
+
+// valueFrom returns a `valueExtractor` from a lookup in the form of
+// "<source>:<name>", where source is "header", "query", "form" or "cookie". It
+// panics if the lookup is malformed or its source isn't one of sources, which
+// the middleware named middlewareName supports.
+func valueFrom(middlewareName, lookup string, sources ...string) valueExtractor {
+	parts := strings.SplitN(lookup, ":", 2)
+	if len(parts) != 2 {
+		panic(middlewareName + " middleware lookup must be in the form <source>:<name>, not " + lookup)
+	}
+	source, name := parts[0], parts[1]
+	supported := false
+	for _, s := range sources {
+		supported = supported || s == source
+	}
+	if !supported {
+		panic(middlewareName + " middleware lookup source must be " + strings.Join(sources, ", ") + ", not " + source)
+	}
+	switch source {
+	case "query":
+		return func(c leego.Context) string {
+			return c.QueryParam(name)
+		}
+	case "form":
+		return func(c leego.Context) string {
+			return c.FormValue(name)
+		}
+	case "cookie":
+		return func(c leego.Context) string {
+			cookie, err := c.Cookie(name)
+			if err != nil {
+				return ""
+			}
+			return cookie.Value()
+		}
+	}
+	return func(c leego.Context) string {
+		return c.Request().Header().Get(name)
+	}
+}
+
+// valueAfterScheme returns a `valueExtractor` of the credentials following the
+// authentication scheme in the value of extract, e.g. "Bearer <token>".
+func valueAfterScheme(extract valueExtractor, scheme string) valueExtractor {
+	return func(c leego.Context) string {
+		auth := extract(c)
+		l := len(scheme)
+		if len(auth) > l+1 && strings.EqualFold(auth[:l], scheme) && auth[l] == ' ' {
+			return auth[l+1:]
+		}
+		return ""
+	}
+}
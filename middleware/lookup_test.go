@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValueFrom(t *testing.T) {
+	req := httptest.NewRequest(leego.POST, "/?q=query", nil)
+	req.Header.Set(leego.HeaderAuthorization, "Bearer header")
+	req.AddCookie(&http.Cookie{Name: "c", Value: "cookie"})
+	c := leego.New().NewContext(standard.NewRequest(req), standard.NewResponse(httptest.NewRecorder()))
+
+	sources := []string{"header", "query", "form", "cookie"}
+	assert.Equal(t, "Bearer header", valueFrom("test", "header:"+leego.HeaderAuthorization, sources...)(c))
+	assert.Equal(t, "query", valueFrom("test", "query:q", sources...)(c))
+	assert.Equal(t, "query", valueFrom("test", "form:q", sources...)(c))
+	assert.Equal(t, "cookie", valueFrom("test", "cookie:c", sources...)(c))
+	assert.Equal(t, "", valueFrom("test", "cookie:missing", sources...)(c))
+
+	// Scheme
+	header := valueFrom("test", "header:"+leego.HeaderAuthorization, sources...)
+	assert.Equal(t, "header", valueAfterScheme(header, "bearer")(c))
+	assert.Equal(t, "", valueAfterScheme(header, "Basic")(c))
+
+	// Invalid lookups
+	assert.Panics(t, func() {
+		valueFrom("test", "header", sources...)
+	})
+	assert.Panics(t, func() {
+		valueFrom("test", "cookie:c", "header", "query")
+	})
+}
@@ -0,0 +1,196 @@
+// Package middlewaretest provides a contract test kit for middleware. It runs a
+// middleware through a matrix of scenarios and checks the invariants every
+// middleware is expected to hold, so third-party middleware can verify it
+// composes with the rest of the stack.
+package middlewaretest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/go-wyvern/leego/middleware"
+)
+
+type (
+	// Config defines the middleware under test.
+	Config struct {
+		// New returns the middleware with skipper set in its config.
+		// Required.
+		New func(skipper middleware.Skipper) leego.MiddlewareFunc
+
+		// Request returns the request sent in every scenario.
+		// Optional. Default value returns `GET /`.
+		Request func() *http.Request
+
+		// ShortCircuit allows the middleware to answer the request without
+		// calling next, e.g. an auth middleware rejecting the request.
+		// Optional. Default value false.
+		ShortCircuit bool
+	}
+
+	// result is the outcome of a scenario.
+	result struct {
+		rec       *httptest.ResponseRecorder
+		calls     int
+		panic     interface{}
+		err       leego.LeeError // Returned by the middleware
+		committed bool           // Response committed when the middleware returned
+	}
+)
+
+var (
+	errHandler  = errors.New("middlewaretest: handler error")
+	handlerBody = "middlewaretest"
+)
+
+// Run runs the middleware through the scenarios as subtests of t:
+//
+// - skipped: with the skipper returning true, next is called once and the
+// response matches the one without middleware.
+// - ok: next is called once (at most once with `Config#ShortCircuit`) and no
+// header has duplicate values.
+// - handler error: the handler error is returned to the error handler, unless
+// the middleware answers the request itself.
+// - handler panic: the panic isn't swallowed, unless it's returned as an error
+// or the middleware answers the request itself.
+// - committed response: the status written by the handler is kept.
+func Run(t *testing.T, config Config) {
+	if config.New == nil {
+		t.Fatal("middlewaretest: Config.New is required")
+	}
+	if config.Request == nil {
+		config.Request = func() *http.Request {
+			return httptest.NewRequest(leego.GET, "/", nil)
+		}
+	}
+	ok := func(c leego.Context) leego.LeeError {
+		return c.String(http.StatusOK, handlerBody)
+	}
+
+	t.Run("skipped", func(t *testing.T) {
+		base := serve(config, nil, ok)
+		r := serve(config, func(leego.Context) bool { return true }, ok)
+		checkCalls(t, r, 1, 1)
+		if r.rec.Code != base.rec.Code {
+			t.Errorf("status is %d, want %d", r.rec.Code, base.rec.Code)
+		}
+		if r.rec.Body.String() != base.rec.Body.String() {
+			t.Errorf("body is %q, want %q", r.rec.Body.String(), base.rec.Body.String())
+		}
+		for k := range r.rec.Header() {
+			if _, ok := base.rec.Header()[k]; !ok {
+				t.Errorf("header %q set although the middleware is skipped", k)
+			}
+		}
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		r := serve(config, never, ok)
+		checkCalls(t, r, minCalls(config), 1)
+		checkHeaders(t, r)
+	})
+
+	t.Run("handler error", func(t *testing.T) {
+		r := serve(config, never, func(leego.Context) leego.LeeError {
+			return errHandler
+		})
+		checkCalls(t, r, minCalls(config), 1)
+		if r.calls == 1 && r.err == nil && !r.committed {
+			t.Error("handler error is swallowed without a response")
+		}
+		checkHeaders(t, r)
+	})
+
+	t.Run("handler panic", func(t *testing.T) {
+		r := serve(config, never, func(leego.Context) leego.LeeError {
+			panic(handlerBody)
+		})
+		checkCalls(t, r, minCalls(config), 1)
+		if r.calls == 1 && r.panic == nil && r.err == nil && !r.committed {
+			t.Error("handler panic is swallowed without a response")
+		}
+	})
+
+	t.Run("committed response", func(t *testing.T) {
+		r := serve(config, never, func(c leego.Context) leego.LeeError {
+			c.Response().WriteHeader(http.StatusAccepted)
+			_, err := c.Response().Write([]byte(handlerBody))
+			return err
+		})
+		checkCalls(t, r, minCalls(config), 1)
+		if r.calls == 1 && r.rec.Code != http.StatusAccepted {
+			t.Errorf("status is %d, want %d written by the handler", r.rec.Code, http.StatusAccepted)
+		}
+		checkHeaders(t, r)
+	})
+}
+
+func never(leego.Context) bool {
+	return false
+}
+
+func minCalls(config Config) int {
+	if config.ShortCircuit {
+		return 0
+	}
+	return 1
+}
+
+// serve sends the request through the middleware, or no middleware if skipper
+// is nil, to handler.
+func serve(config Config, skipper middleware.Skipper, handler leego.HandlerFunc) (r result) {
+	lee := leego.New()
+	lee.Use(func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			// Outcome of the middleware, before the error handler runs.
+			defer func() {
+				r.committed = c.Response().Committed()
+			}()
+			r.err = next(c)
+			return r.err
+		}
+	})
+	if skipper != nil {
+		lee.Use(config.New(skipper))
+	}
+	lee.Any("/*", func(c leego.Context) leego.LeeError {
+		r.calls++
+		return handler(c)
+	})
+
+	r.rec = httptest.NewRecorder()
+	func() {
+		defer func() {
+			r.panic = recover()
+		}()
+		lee.ServeHTTP(standard.NewRequest(config.Request()), standard.NewResponse(r.rec))
+	}()
+	return
+}
+
+func checkCalls(t *testing.T, r result, min, max int) {
+	if r.calls < min || r.calls > max {
+		want := fmt.Sprint(min)
+		if min != max {
+			want = fmt.Sprintf("%d to %d", min, max)
+		}
+		t.Errorf("next called %d times, want %s", r.calls, want)
+	}
+}
+
+func checkHeaders(t *testing.T, r result) {
+	for k, v := range r.rec.Header() {
+		seen := make(map[string]bool, len(v))
+		for _, s := range v {
+			if seen[s] {
+				t.Errorf("header %q has duplicate value %q", k, s)
+			}
+			seen[s] = true
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package middlewaretest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/middleware"
+)
+
+func TestMiddleware(t *testing.T) {
+	t.Run("Logger", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.LoggerWithConfig(middleware.LoggerConfig{Skipper: s, Output: httptest.NewRecorder().Body})
+			},
+		})
+	})
+	t.Run("Gzip", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.GzipWithConfig(middleware.GzipConfig{Skipper: s, MinLength: 1})
+			},
+			Request: func() *http.Request {
+				req := httptest.NewRequest(leego.GET, "/", nil)
+				req.Header.Set(leego.HeaderAcceptEncoding, "gzip")
+				return req
+			},
+		})
+	})
+	t.Run("CORS", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.CORSWithConfig(middleware.CORSConfig{Skipper: s})
+			},
+			Request: func() *http.Request {
+				req := httptest.NewRequest(leego.GET, "/", nil)
+				req.Header.Set(leego.HeaderOrigin, "http://example.com")
+				return req
+			},
+		})
+	})
+	t.Run("JWT", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.JWTWithConfig(middleware.JWTConfig{Skipper: s, SigningKey: []byte("secret")})
+			},
+			ShortCircuit: true,
+		})
+	})
+	t.Run("RateLimiter", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.RateLimiterWithConfig(middleware.RateLimiterConfig{
+					Skipper: s,
+					Store:   middleware.NewRateLimiterMemoryStore(1000),
+				})
+			},
+		})
+	})
+	t.Run("Recover", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.RecoverWithConfig(middleware.RecoverConfig{Skipper: s})
+			},
+		})
+	})
+	t.Run("RequestID", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.RequestIDWithConfig(middleware.RequestIDConfig{Skipper: s})
+			},
+		})
+	})
+	t.Run("BasicAuth", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.BasicAuthWithConfig(middleware.BasicAuthConfig{
+					Skipper: s,
+					Validator: func(user, password string, c leego.Context) (bool, error) {
+						return true, nil
+					},
+				})
+			},
+			Request: func() *http.Request {
+				req := httptest.NewRequest(leego.GET, "/", nil)
+				req.SetBasicAuth("joe", "secret")
+				return req
+			},
+		})
+	})
+	t.Run("Timeout", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.TimeoutWithConfig(middleware.TimeoutConfig{Skipper: s, Timeout: time.Second})
+			},
+		})
+	})
+	t.Run("Cache", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.CacheWithConfig(middleware.CacheConfig{Skipper: s, Store: middleware.NewCacheMemoryStore()})
+			},
+		})
+	})
+	t.Run("Session", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.SessionWithConfig(middleware.SessionConfig{Skipper: s, Store: middleware.NewSessionMemoryStore()})
+			},
+		})
+	})
+	t.Run("RememberMe", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				session := middleware.Session(middleware.NewSessionMemoryStore())
+				rememberMe := middleware.RememberMeWithConfig(middleware.RememberMeConfig{Skipper: s, Store: middleware.NewRememberMeMemoryStore()})
+				return func(next leego.HandlerFunc) leego.HandlerFunc {
+					return session(rememberMe(next))
+				}
+			},
+		})
+	})
+	t.Run("LoginThrottle", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.LoginThrottleWithConfig(middleware.LoginThrottleConfig{
+					Skipper: s,
+					Store:   middleware.NewLoginThrottleMemoryStore(time.Hour),
+				})
+			},
+		})
+	})
+	t.Run("SignedURL", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.SignedURLWithConfig(middleware.SignedURLConfig{Skipper: s})
+			},
+			ShortCircuit: true,
+		})
+	})
+	t.Run("Nonce", func(t *testing.T) {
+		n := 0
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.NonceWithConfig(middleware.NonceConfig{Skipper: s, Store: middleware.NewNonceMemoryStore()})
+			},
+			Request: func() *http.Request {
+				n++
+				req := httptest.NewRequest(leego.GET, "/", nil)
+				req.Header.Set(leego.HeaderXNonce, strconv.Itoa(n))
+				req.Header.Set(leego.HeaderXTimestamp, strconv.FormatInt(time.Now().Unix(), 10))
+				return req
+			},
+		})
+	})
+	t.Run("Moderate", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.ModerateWithConfig(middleware.ModerateConfig{
+					Skipper: s,
+					Moderator: middleware.ModeratorFunc(func(field, text string) (middleware.ModerationResult, error) {
+						return middleware.ModerationResult{}, nil
+					}),
+					Fields: []string{"comment"},
+				})
+			},
+			Request: func() *http.Request {
+				return httptest.NewRequest(leego.GET, "/?comment=hello", nil)
+			},
+		})
+	})
+	t.Run("Problem", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.ProblemWithConfig(middleware.ProblemConfig{Skipper: s})
+			},
+		})
+	})
+	t.Run("Migrate", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.MigrateWithConfig(middleware.MigrateConfig{
+					Skipper:  s,
+					Versions: []middleware.SchemaVersion{{Name: "1"}, {Name: "2"}},
+				})
+			},
+			Request: func() *http.Request {
+				req := httptest.NewRequest(leego.GET, "/", nil)
+				req.Header.Set(leego.HeaderAPIVersion, "1")
+				return req
+			},
+		})
+	})
+	t.Run("SlowStart", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.SlowStartWithConfig(middleware.SlowStartConfig{Skipper: s, MinFraction: 1})
+			},
+		})
+	})
+	t.Run("PayloadSize", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.PayloadSizeWithConfig(middleware.PayloadSizeConfig{Skipper: s, Stats: middleware.NewPayloadSizeStats()})
+			},
+		})
+	})
+	t.Run("Locale", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.LocaleWithConfig(middleware.LocaleConfig{Skipper: s, Languages: []string{"en"}})
+			},
+		})
+	})
+	t.Run("Trace", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.TraceWithConfig(middleware.TraceConfig{Skipper: s, Export: func(*middleware.Span) {}})
+			},
+		})
+	})
+	t.Run("Proxy", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.ProxyWithConfig(middleware.ProxyConfig{Skipper: s, Balancer: middleware.NewRoundRobinBalancer()})
+			},
+			ShortCircuit: true,
+		})
+	})
+	t.Run("Rewrite", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.RewriteWithConfig(middleware.RewriteConfig{Skipper: s, Rules: map[string]string{"/*": "/new/$1"}})
+			},
+		})
+	})
+	t.Run("HTTPSRedirect", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.HTTPSRedirectWithConfig(middleware.RedirectConfig{Skipper: s})
+			},
+			ShortCircuit: true,
+		})
+	})
+	t.Run("ETag", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.ETagWithConfig(middleware.ETagConfig{Skipper: s})
+			},
+		})
+	})
+	t.Run("Query", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.QueryWithConfig(middleware.QueryConfig{Skipper: s, Fields: []string{"name"}})
+			},
+		})
+	})
+	t.Run("KeyAuth", func(t *testing.T) {
+		Run(t, Config{
+			New: func(s middleware.Skipper) leego.MiddlewareFunc {
+				return middleware.KeyAuthWithConfig(middleware.KeyAuthConfig{
+					Skipper: s,
+					Validator: func(key string, c leego.Context) (bool, error) {
+						return true, nil
+					},
+				})
+			},
+			ShortCircuit: true,
+		})
+	})
+}
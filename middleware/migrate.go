@@ -0,0 +1,247 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine"
+)
+
+type (
+	// MigrateConfig defines the config for Migrate middleware.
+	MigrateConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Versions are the versions of the schema, oldest first. The last one
+		// is the current version understood by the handlers.
+		// Required.
+		Versions []SchemaVersion `json:"versions"`
+
+		// VersionLookup is a string in the form of "<source>:<name>" that is
+		// used to extract the version of the client from the request. Possible
+		// values:
+		// - "header:<name>"
+		// - "query:<name>"
+		// Clients without version get the current version.
+		// Optional. Default value "header:API-Version".
+		VersionLookup string `json:"version_lookup"`
+
+		// FormatLeeError formats the errors returned by the middleware, see
+		// `Middleware#FormatLeeError()`.
+		// Optional. Default value returns the error as is.
+		FormatLeeError func(err error, middlewareName string) leego.LeeError
+	}
+
+	// SchemaVersion is a version of the schema of the JSON request and
+	// response bodies, with the migrations to and from the next version.
+	SchemaVersion struct {
+		// Name is the version sent by clients, e.g. "2020-06-01".
+		Name string `json:"name"`
+
+		// Request migrates a request body of this version to the next one.
+		// Optional.
+		Request MigrationFunc
+
+		// Response migrates a response body of the next version back to this
+		// one. Optional.
+		Response MigrationFunc
+	}
+
+	// MigrationFunc migrates a decoded JSON body, numbers being
+	// `json.Number`.
+	MigrationFunc func(body interface{}) (interface{}, error)
+
+	// migrateResponse buffers the response to migrate it.
+	migrateResponse struct {
+		engine.Response
+		code  int
+		wrote bool
+		buf   bytes.Buffer
+	}
+
+	schemaVersionContextKey struct{}
+)
+
+const migrateMiddlewareName = "migrate"
+
+var (
+	// DefaultMigrateConfig is the default Migrate middleware config.
+	DefaultMigrateConfig = MigrateConfig{
+		Skipper:        defaultSkipper,
+		VersionLookup:  "header:" + leego.HeaderAPIVersion,
+		FormatLeeError: defaultFormatLeeError,
+	}
+
+	// ErrUnknownSchemaVersion is returned for a version which isn't in
+	// `MigrateConfig#Versions`.
+	ErrUnknownSchemaVersion = leego.NewHTTPError(http.StatusBadRequest, "unknown api version")
+)
+
+// Migrate returns a middleware which lets handlers only understand the current
+// schema while older clients keep working: JSON request bodies of an older
+// version are migrated up to the current version, and JSON responses back
+// down to the version of the client. The version of the client is available
+// with `SchemaVersionFrom()`.
+func Migrate(versions ...SchemaVersion) leego.MiddlewareFunc {
+	c := DefaultMigrateConfig
+	c.Versions = versions
+	return MigrateWithConfig(c)
+}
+
+// MigrateWithConfig returns a Migrate middleware from config.
+// See `Migrate()`.
+func MigrateWithConfig(config MigrateConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultMigrateConfig.Skipper
+	}
+	if len(config.Versions) == 0 {
+		panic("migrate middleware requires versions")
+	}
+	if config.VersionLookup == "" {
+		config.VersionLookup = DefaultMigrateConfig.VersionLookup
+	}
+	if config.FormatLeeError == nil {
+		config.FormatLeeError = DefaultMigrateConfig.FormatLeeError
+	}
+
+	// Initialize
+	versionFrom := valueFrom(migrateMiddlewareName, config.VersionLookup, "header", "query")
+	current := len(config.Versions) - 1
+	index := make(map[string]int, len(config.Versions))
+	for i, v := range config.Versions {
+		index[v.Name] = i
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			version := current
+			if name := versionFrom(c); name != "" {
+				var ok bool
+				if version, ok = index[name]; !ok {
+					return config.FormatLeeError(ErrUnknownSchemaVersion, migrateMiddlewareName)
+				}
+			}
+			c.Set(schemaVersionContextKey{}, config.Versions[version].Name)
+			if version == current {
+				return next(c)
+			}
+
+			req := c.Request()
+			if isJSON(req.Header().Get(leego.HeaderContentType)) {
+				b, err := ioutil.ReadAll(req.Body())
+				if err != nil {
+					return config.FormatLeeError(err, migrateMiddlewareName)
+				}
+				for _, v := range config.Versions[version:current] {
+					if b, err = migrateJSON(b, v.Request); err != nil {
+						return config.FormatLeeError(err, migrateMiddlewareName)
+					}
+				}
+				req.SetBody(bytes.NewReader(b))
+			}
+
+			res := c.Response()
+			mr := &migrateResponse{Response: res, code: http.StatusOK}
+			c.SetResponse(mr)
+			defer c.SetResponse(res)
+			err := next(c)
+			b := mr.buf.Bytes()
+			if mr.code < http.StatusMultipleChoices && isJSON(res.Header().Get(leego.HeaderContentType)) {
+				for i := current - 1; i >= version; i-- {
+					var merr error
+					if b, merr = migrateJSON(b, config.Versions[i].Response); merr != nil {
+						return config.FormatLeeError(merr, migrateMiddlewareName)
+					}
+				}
+				res.Header().Set(leego.HeaderContentLength, strconv.Itoa(len(b)))
+			}
+			if mr.wrote {
+				res.WriteHeader(mr.code)
+				res.Write(b)
+			}
+			return err
+		}
+	}
+}
+
+// SchemaVersionFrom returns the schema version of the client, see
+// `Migrate()`.
+func SchemaVersionFrom(c leego.Context) string {
+	v, _ := c.Get(schemaVersionContextKey{}).(string)
+	return v
+}
+
+func isJSON(contentType string) bool {
+	return strings.HasPrefix(contentType, leego.MIMEApplicationJSON)
+}
+
+// migrateJSON migrates the JSON document b with f. Empty documents are left
+// to the binder.
+func migrateJSON(b []byte, f MigrationFunc) ([]byte, error) {
+	if f == nil || len(bytes.TrimSpace(b)) == 0 {
+		return b, nil
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var body interface{}
+	if err := d.Decode(&body); err != nil {
+		return nil, leego.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	body, err := f(body)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(body)
+}
+
+// WriteHeader implements `engine.Response#WriteHeader` function. The header is
+// sent once the body is migrated.
+func (r *migrateResponse) WriteHeader(code int) {
+	if r.wrote || r.Response.Committed() {
+		return
+	}
+	r.code = code
+	r.wrote = true
+}
+
+// Write implements `engine.Response#Write` function.
+func (r *migrateResponse) Write(b []byte) (int, error) {
+	if !r.wrote {
+		r.WriteHeader(http.StatusOK)
+	}
+	return r.buf.Write(b)
+}
+
+// Flush implements `engine.Response#Flush` function. The body is sent at once
+// after migration, so it's a no-op.
+func (r *migrateResponse) Flush() {}
+
+// Status implements `engine.Response#Status` function.
+func (r *migrateResponse) Status() int {
+	if r.wrote {
+		return r.code
+	}
+	return r.Response.Status()
+}
+
+// Committed implements `engine.Response#Committed` function.
+func (r *migrateResponse) Committed() bool {
+	return r.wrote || r.Response.Committed()
+}
+
+// Writer implements `engine.Response#Writer` function.
+func (r *migrateResponse) Writer() io.Writer {
+	return r
+}
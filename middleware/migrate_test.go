@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrate(t *testing.T) {
+	// 2019: {"name"}, 2020: {"full_name"}, 2021: {"full_name", "email"}
+	lee := leego.New()
+	lee.POST("/users", func(c leego.Context) leego.LeeError {
+		var u struct {
+			FullName string `json:"full_name"`
+			Email    string `json:"email"`
+		}
+		if err := c.Bind(&u); err != nil {
+			return err
+		}
+		u.Email = strings.ToLower(u.Email)
+		return c.JSON(http.StatusCreated, u)
+	}, Migrate(
+		SchemaVersion{
+			Name: "2019",
+			Request: func(body interface{}) (interface{}, error) {
+				m := body.(map[string]interface{})
+				m["full_name"] = m["name"]
+				delete(m, "name")
+				return m, nil
+			},
+			Response: func(body interface{}) (interface{}, error) {
+				m := body.(map[string]interface{})
+				m["name"] = m["full_name"]
+				delete(m, "full_name")
+				return m, nil
+			},
+		},
+		SchemaVersion{
+			Name: "2020",
+			Request: func(body interface{}) (interface{}, error) {
+				body.(map[string]interface{})["email"] = "UNKNOWN@EXAMPLE.COM"
+				return body, nil
+			},
+			Response: func(body interface{}) (interface{}, error) {
+				delete(body.(map[string]interface{}), "email")
+				return body, nil
+			},
+		},
+		SchemaVersion{Name: "2021"},
+	))
+	request := func(version, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(leego.POST, "/users", strings.NewReader(body))
+		req.Header.Set(leego.HeaderContentType, leego.MIMEApplicationJSON)
+		if version != "" {
+			req.Header.Set(leego.HeaderAPIVersion, version)
+		}
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		return rec
+	}
+
+	rec := request("", `{"full_name":"Joe","email":"JOE@EXAMPLE.COM"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, `{"full_name":"Joe","email":"joe@example.com"}`, rec.Body.String())
+	assert.Equal(t, `{"full_name":"Joe"}`, request("2020", `{"full_name":"Joe"}`).Body.String())
+	rec = request("2019", `{"name":"Joe"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, `{"name":"Joe"}`, rec.Body.String())
+	assert.Equal(t, "14", rec.Header().Get(leego.HeaderContentLength))
+	assert.Equal(t, http.StatusBadRequest, request("2018", `{}`).Code)
+	assert.Equal(t, http.StatusBadRequest, request("2019", `{`).Code)
+}
@@ -0,0 +1,239 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// ModerateConfig defines the config for Moderate middleware.
+	ModerateConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Moderator reviews the text of the fields.
+		// Required.
+		Moderator Moderator
+
+		// Fields are the names of the query and form params to moderate, or the
+		// paths of JSON body fields, e.g. "comment" or "author.name". The
+		// strings of arrays are moderated one by one.
+		// Required.
+		Fields []string `json:"fields"`
+
+		// Async moderates in the background, without delaying the handler.
+		// Rejected texts are only flagged then.
+		// Optional. Default value false.
+		Async bool `json:"async"`
+
+		// Flag is called with flagged or rejected texts and, in the background,
+		// texts which couldn't be moderated.
+		// Required with `Async`.
+		Flag func(f ModerationFlag)
+
+		// FormatLeeError formats the errors returned by the middleware, see
+		// `Middleware#FormatLeeError()`.
+		// Optional. Default value returns the error as is.
+		FormatLeeError func(err error, middlewareName string) leego.LeeError
+	}
+
+	// Moderator reviews user-generated text, e.g. with a moderation API.
+	Moderator interface {
+		// Moderate reviews the text of field.
+		Moderate(field, text string) (ModerationResult, error)
+	}
+
+	// ModeratorFunc adapts a function to a `Moderator`.
+	ModeratorFunc func(field, text string) (ModerationResult, error)
+
+	// ModerationResult is the result of a moderation.
+	ModerationResult struct {
+		// Rejected reports whether the text must not be accepted.
+		Rejected bool
+
+		// Flagged reports whether the text should be reviewed.
+		Flagged bool
+
+		// Reason is the reason of the verdict, e.g. "spam".
+		Reason string
+	}
+
+	// ModerationFlag is a text flagged by the moderator.
+	ModerationFlag struct {
+		RequestID string
+		Method    string
+		Path      string
+		Field     string
+		Text      string
+		Result    ModerationResult
+		Err       error
+	}
+
+	// moderatedText is a text of the request to moderate.
+	moderatedText struct {
+		field string
+		text  string
+	}
+)
+
+const moderateMiddlewareName = "moderate"
+
+var (
+	// DefaultModerateConfig is the default Moderate middleware config.
+	DefaultModerateConfig = ModerateConfig{
+		Skipper:        defaultSkipper,
+		FormatLeeError: defaultFormatLeeError,
+	}
+
+	// ErrContentRejected is returned when the moderator rejects a text. Its
+	// details hold the field and the reason.
+	ErrContentRejected = leego.NewHTTPError(http.StatusUnprocessableEntity, "content rejected")
+)
+
+// Moderate implements `Moderator#Moderate()`.
+func (f ModeratorFunc) Moderate(field, text string) (ModerationResult, error) {
+	return f(field, text)
+}
+
+// Moderate returns a middleware which reviews the fields of the request with
+// moderator before they are bound. A rejected text returns
+// `ErrContentRejected` (422).
+func Moderate(moderator Moderator, fields ...string) leego.MiddlewareFunc {
+	c := DefaultModerateConfig
+	c.Moderator = moderator
+	c.Fields = fields
+	return ModerateWithConfig(c)
+}
+
+// ModerateWithConfig returns a Moderate middleware from config.
+// See `Moderate()`.
+func ModerateWithConfig(config ModerateConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultModerateConfig.Skipper
+	}
+	if config.Moderator == nil {
+		panic("moderate middleware requires moderator")
+	}
+	if len(config.Fields) == 0 {
+		panic("moderate middleware requires fields")
+	}
+	if config.Async && config.Flag == nil {
+		panic("moderate middleware requires flag with async")
+	}
+	if config.FormatLeeError == nil {
+		config.FormatLeeError = DefaultModerateConfig.FormatLeeError
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			texts, err := moderatedTexts(c, config.Fields)
+			if err != nil {
+				return config.FormatLeeError(err, moderateMiddlewareName)
+			}
+			if len(texts) == 0 {
+				return next(c)
+			}
+			flag := ModerationFlag{
+				RequestID: c.RequestID(),
+				Method:    c.Request().Method(),
+				Path:      c.Request().URL().Path(),
+			}
+			if config.Async {
+				go func() {
+					for _, t := range texts {
+						result, err := config.Moderator.Moderate(t.field, t.text)
+						if err != nil || result.Rejected || result.Flagged {
+							f := flag
+							f.Field, f.Text, f.Result, f.Err = t.field, t.text, result, err
+							config.Flag(f)
+						}
+					}
+				}()
+				return next(c)
+			}
+			for _, t := range texts {
+				result, err := config.Moderator.Moderate(t.field, t.text)
+				if err != nil {
+					return config.FormatLeeError(err, moderateMiddlewareName)
+				}
+				if (result.Rejected || result.Flagged) && config.Flag != nil {
+					f := flag
+					f.Field, f.Text, f.Result = t.field, t.text, result
+					config.Flag(f)
+				}
+				if result.Rejected {
+					err := ErrContentRejected.WithDetail("field", t.field).WithDetail("reason", result.Reason)
+					return config.FormatLeeError(err, moderateMiddlewareName)
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+// moderatedTexts returns the texts of fields in the query, the form or the
+// JSON body of the request, which is restored for binding.
+func moderatedTexts(c leego.Context, fields []string) ([]moderatedText, error) {
+	req := c.Request()
+	ctype := req.Header().Get(leego.HeaderContentType)
+	var body interface{}
+	if strings.HasPrefix(ctype, leego.MIMEApplicationJSON) {
+		b, err := ioutil.ReadAll(req.Body())
+		if err != nil {
+			return nil, err
+		}
+		req.SetBody(bytes.NewReader(b))
+		// Invalid bodies are left to the binder.
+		json.Unmarshal(b, &body)
+	}
+	form := strings.HasPrefix(ctype, leego.MIMEApplicationForm) || strings.HasPrefix(ctype, leego.MIMEMultipartForm)
+
+	var texts []moderatedText
+	for _, field := range fields {
+		for _, v := range req.URL().QueryParams()[field] {
+			texts = append(texts, moderatedText{field, v})
+		}
+		if form {
+			for _, v := range req.FormParams()[field] {
+				texts = append(texts, moderatedText{field, v})
+			}
+		}
+		if body != nil {
+			for _, v := range jsonStrings(body, strings.Split(field, ".")) {
+				texts = append(texts, moderatedText{field, v})
+			}
+		}
+	}
+	return texts, nil
+}
+
+// jsonStrings returns the strings at path in the decoded JSON value v.
+func jsonStrings(v interface{}, path []string) []string {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(path) > 0 {
+			return jsonStrings(v[path[0]], path[1:])
+		}
+	case []interface{}:
+		var s []string
+		for _, e := range v {
+			s = append(s, jsonStrings(e, path)...)
+		}
+		return s
+	case string:
+		if len(path) == 0 {
+			return []string{v}
+		}
+	}
+	return nil
+}
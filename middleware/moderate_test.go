@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModerate(t *testing.T) {
+	moderator := ModeratorFunc(func(field, text string) (ModerationResult, error) {
+		switch {
+		case strings.Contains(text, "spam"):
+			return ModerationResult{Rejected: true, Reason: "spam"}, nil
+		case strings.Contains(text, "rude"):
+			return ModerationResult{Flagged: true, Reason: "toxicity"}, nil
+		}
+		return ModerationResult{}, nil
+	})
+	run := func(config ModerateConfig, ctype, body string) (leego.LeeError, string) {
+		req := httptest.NewRequest(leego.POST, "/comments", strings.NewReader(body))
+		req.Header.Set(leego.HeaderContentType, ctype)
+		c := leego.New().NewContext(standard.NewRequest(req), standard.NewResponse(httptest.NewRecorder()))
+		bound := ""
+		config.Moderator = moderator
+		err := ModerateWithConfig(config)(func(c leego.Context) leego.LeeError {
+			var v struct {
+				Comment string `json:"comment" form:"comment"`
+			}
+			c.Bind(&v)
+			bound = v.Comment
+			return nil
+		})(c)
+		return err, bound
+	}
+	var flags []ModerationFlag
+	config := ModerateConfig{
+		Fields: []string{"comment", "author.name"},
+		Flag: func(f ModerationFlag) {
+			flags = append(flags, f)
+		},
+	}
+
+	err, bound := run(config, leego.MIMEApplicationJSON, `{"comment":"hello","author":{"name":"joe"}}`)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", bound)
+	err, _ = run(config, leego.MIMEApplicationJSON, `{"comment":"hi","author":{"name":"spam bot"}}`)
+	if he, ok := err.(*leego.HTTPError); assert.True(t, ok) {
+		assert.Equal(t, http.StatusUnprocessableEntity, he.Code)
+		assert.Equal(t, "author.name", he.Details["field"])
+		assert.Equal(t, "spam", he.Details["reason"])
+	}
+	err, bound = run(config, leego.MIMEApplicationForm, "comment=so+rude")
+	assert.Nil(t, err)
+	assert.Equal(t, "so rude", bound)
+	if assert.Len(t, flags, 2) {
+		assert.Equal(t, "/comments", flags[1].Path)
+		assert.Equal(t, "toxicity", flags[1].Result.Reason)
+	}
+
+	// Async
+	flagged := make(chan ModerationFlag, 1)
+	err, bound = run(ModerateConfig{
+		Fields: []string{"comment"},
+		Async:  true,
+		Flag: func(f ModerationFlag) {
+			flagged <- f
+		},
+	}, leego.MIMEApplicationJSON, `{"comment":"spam"}`)
+	assert.Nil(t, err)
+	assert.Equal(t, "spam", bound)
+	assert.True(t, (<-flagged).Result.Rejected)
+
+	assert.Panics(t, func() {
+		ModerateWithConfig(ModerateConfig{Moderator: moderator})
+	})
+}
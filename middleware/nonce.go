@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// NonceConfig defines the config for Nonce middleware.
+	NonceConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Store records the used nonces.
+		// Required.
+		Store NonceStore
+
+		// NonceLookup is a string in the form of "<source>:<name>" that is
+		// used to extract the nonce from the request. Possible values:
+		// - "header:<name>"
+		// - "query:<name>"
+		// - "form:<name>"
+		// Optional. Default value "header:X-Nonce".
+		NonceLookup string `json:"nonce_lookup"`
+
+		// TimestampLookup is a string in the form of "<source>:<name>" that is
+		// used to extract the time of the request, in Unix seconds. See
+		// `NonceLookup`.
+		// Optional. Default value "header:X-Timestamp".
+		TimestampLookup string `json:"timestamp_lookup"`
+
+		// Skew is the maximum difference between the timestamp and the time of
+		// the server. Nonces are remembered as long.
+		// Optional. Default value 5 minutes.
+		Skew time.Duration `json:"skew"`
+
+		// FormatLeeError formats the errors returned by the middleware, see
+		// `Middleware#FormatLeeError()`.
+		// Optional. Default value returns the error as is.
+		FormatLeeError func(err error, middlewareName string) leego.LeeError
+	}
+
+	// NonceStore records the used nonces.
+	NonceStore interface {
+		// Use records nonce as used until expiresAt and reports whether it was
+		// already used.
+		Use(nonce string, expiresAt time.Time) (used bool, err error)
+	}
+
+	// NonceMemoryStore is an in-memory `NonceStore`.
+	NonceMemoryStore struct {
+		mu       sync.Mutex
+		nonces   map[string]time.Time
+		lastScan time.Time
+		now      func() time.Time
+	}
+)
+
+const (
+	nonceMiddlewareName = "nonce"
+
+	// maxNonceLength is the maximum length of a nonce, bounding the memory of
+	// the store.
+	maxNonceLength = 128
+)
+
+var (
+	// DefaultNonceConfig is the default Nonce middleware config.
+	DefaultNonceConfig = NonceConfig{
+		Skipper:         defaultSkipper,
+		NonceLookup:     "header:" + leego.HeaderXNonce,
+		TimestampLookup: "header:" + leego.HeaderXTimestamp,
+		Skew:            5 * time.Minute,
+		FormatLeeError:  defaultFormatLeeError,
+	}
+
+	// ErrNonceMissing is returned when the nonce or the timestamp is missing
+	// or malformed.
+	ErrNonceMissing = leego.NewHTTPError(http.StatusBadRequest, "missing or malformed nonce")
+
+	// ErrNonceExpired is returned when the timestamp is outside the skew
+	// window.
+	ErrNonceExpired = leego.NewHTTPError(http.StatusUnauthorized, "request timestamp outside the allowed window")
+
+	// ErrNonceReplayed is returned when the nonce was already used.
+	ErrNonceReplayed = leego.NewHTTPError(http.StatusUnauthorized, "nonce already used")
+)
+
+// Nonce returns a middleware which rejects replayed requests, e.g. to callback
+// and webhook endpoints: every request must carry a single-use nonce and a
+// timestamp within the skew window. It should come after the middleware
+// authenticating the request, so that the nonce and the timestamp are signed
+// and unauthenticated requests don't use up nonces.
+func Nonce(store NonceStore) leego.MiddlewareFunc {
+	c := DefaultNonceConfig
+	c.Store = store
+	return NonceWithConfig(c)
+}
+
+// NonceWithConfig returns a Nonce middleware from config.
+// See `Nonce()`.
+func NonceWithConfig(config NonceConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultNonceConfig.Skipper
+	}
+	if config.Store == nil {
+		panic("nonce middleware requires store")
+	}
+	if config.NonceLookup == "" {
+		config.NonceLookup = DefaultNonceConfig.NonceLookup
+	}
+	if config.TimestampLookup == "" {
+		config.TimestampLookup = DefaultNonceConfig.TimestampLookup
+	}
+	if config.Skew <= 0 {
+		config.Skew = DefaultNonceConfig.Skew
+	}
+	if config.FormatLeeError == nil {
+		config.FormatLeeError = DefaultNonceConfig.FormatLeeError
+	}
+
+	// Initialize
+	nonceFrom := valueFrom(nonceMiddlewareName, config.NonceLookup, "header", "query", "form")
+	timestampFrom := valueFrom(nonceMiddlewareName, config.TimestampLookup, "header", "query", "form")
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			nonce := nonceFrom(c)
+			ts, err := strconv.ParseInt(timestampFrom(c), 10, 64)
+			if nonce == "" || len(nonce) > maxNonceLength || err != nil {
+				return config.FormatLeeError(ErrNonceMissing, nonceMiddlewareName)
+			}
+			t := time.Unix(ts, 0)
+			if d := time.Since(t); d > config.Skew || d < -config.Skew {
+				return config.FormatLeeError(ErrNonceExpired, nonceMiddlewareName)
+			}
+			used, err := config.Store.Use(nonce, t.Add(config.Skew))
+			if err != nil {
+				return config.FormatLeeError(err, nonceMiddlewareName)
+			}
+			if used {
+				return config.FormatLeeError(ErrNonceReplayed, nonceMiddlewareName)
+			}
+			return next(c)
+		}
+	}
+}
+
+// NewNonceMemoryStore returns an in-memory nonce store.
+func NewNonceMemoryStore() *NonceMemoryStore {
+	return &NonceMemoryStore{
+		nonces: make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+// Use implements `NonceStore#Use()`. Expired nonces are removed every minute
+// on the way.
+func (s *NonceMemoryStore) Use(nonce string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.lastScan) >= time.Minute {
+		for n, exp := range s.nonces {
+			if !now.Before(exp) {
+				delete(s.nonces, n)
+			}
+		}
+		s.lastScan = now
+	}
+	if exp, ok := s.nonces[nonce]; ok && now.Before(exp) {
+		return true, nil
+	}
+	s.nonces[nonce] = expiresAt
+	return false, nil
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNonce(t *testing.T) {
+	lee := leego.New()
+	store := NewNonceMemoryStore()
+	lee.POST("/hook", func(c leego.Context) leego.LeeError {
+		return c.NoContent(http.StatusNoContent)
+	}, Nonce(store))
+	request := func(nonce string, at time.Time) int {
+		req := httptest.NewRequest(leego.POST, "/hook", nil)
+		req.Header.Set(leego.HeaderXNonce, nonce)
+		req.Header.Set(leego.HeaderXTimestamp, strconv.FormatInt(at.Unix(), 10))
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		return rec.Code
+	}
+
+	now := time.Now()
+	assert.Equal(t, http.StatusNoContent, request("n1", now))
+	assert.Equal(t, http.StatusUnauthorized, request("n1", now))
+	assert.Equal(t, http.StatusNoContent, request("n2", now.Add(-4*time.Minute)))
+	assert.Equal(t, http.StatusUnauthorized, request("n3", now.Add(-6*time.Minute)))
+	assert.Equal(t, http.StatusUnauthorized, request("n3", now.Add(6*time.Minute)))
+	assert.Equal(t, http.StatusBadRequest, request("", now))
+
+	// Expiration
+	store.now = func() time.Time { return now.Add(5*time.Minute + time.Second) }
+	used, _ := store.Use("n1", now.Add(10*time.Minute))
+	assert.False(t, used)
+	assert.Len(t, store.nonces, 1)
+
+	// Query lookup
+	lee.GET("/callback", func(c leego.Context) leego.LeeError {
+		return c.NoContent(http.StatusNoContent)
+	}, NonceWithConfig(NonceConfig{Store: NewNonceMemoryStore(), NonceLookup: "query:nonce", TimestampLookup: "query:ts"}))
+	req := httptest.NewRequest(leego.GET, "/callback?nonce=a&ts="+strconv.FormatInt(now.Unix(), 10), nil)
+	rec := httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	assert.Panics(t, func() {
+		NonceWithConfig(NonceConfig{})
+	})
+}
@@ -0,0 +1,259 @@
+package middleware
+
+import (
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// PayloadSizeConfig defines the config for PayloadSize middleware.
+	PayloadSizeConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Stats collects the sizes.
+		// Required.
+		Stats *PayloadSizeStats
+	}
+
+	// PayloadSizeStats collects the request and response body sizes per route.
+	// The percentiles are estimated from a uniform sample of the sizes of each
+	// route.
+	PayloadSizeStats struct {
+		mu     sync.Mutex
+		routes map[string]*routePayloadSize
+		rand   *rand.Rand
+	}
+
+	// RoutePayloadSize is the report of the sizes of a route.
+	RoutePayloadSize struct {
+		Method   string             `json:"method"`
+		Path     string             `json:"path"`
+		Request  PayloadSizeSummary `json:"request"`
+		Response PayloadSizeSummary `json:"response"`
+	}
+
+	// PayloadSizeSummary summarizes sizes in bytes.
+	PayloadSizeSummary struct {
+		Count int64 `json:"count"`
+		Total int64 `json:"total"`
+		Max   int64 `json:"max"`
+		P50   int64 `json:"p50"`
+		P90   int64 `json:"p90"`
+		P99   int64 `json:"p99"`
+	}
+
+	routePayloadSize struct {
+		method, path      string
+		request, response sizeSample
+	}
+
+	// sizeSample keeps a reservoir sample of the sizes.
+	sizeSample struct {
+		count, total, max int64
+		samples           []int64
+	}
+
+	// countingReader counts the bytes read from the request body.
+	countingReader struct {
+		io.Reader
+		n int64
+	}
+)
+
+const payloadSizeSamples = 1024
+
+var (
+	// DefaultPayloadSizeConfig is the default PayloadSize middleware config.
+	DefaultPayloadSizeConfig = PayloadSizeConfig{
+		Skipper: defaultSkipper,
+	}
+)
+
+// NewPayloadSizeStats returns empty stats.
+func NewPayloadSizeStats() *PayloadSizeStats {
+	return &PayloadSizeStats{
+		routes: make(map[string]*routePayloadSize),
+		rand:   rand.New(rand.NewSource(1)),
+	}
+}
+
+// PayloadSize returns a middleware which records the request and response body
+// sizes of the routes in stats, e.g. to find the endpoints to paginate or
+// compress with `PayloadSizeStats#Top()`. Requests matching no route aren't
+// recorded.
+func PayloadSize(stats *PayloadSizeStats) leego.MiddlewareFunc {
+	c := DefaultPayloadSizeConfig
+	c.Stats = stats
+	return PayloadSizeWithConfig(c)
+}
+
+// PayloadSizeWithConfig returns a PayloadSize middleware from config.
+// See `PayloadSize()`.
+func PayloadSizeWithConfig(config PayloadSizeConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultPayloadSizeConfig.Skipper
+	}
+	if config.Stats == nil {
+		panic("payload size middleware requires stats")
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			req := c.Request()
+			body := &countingReader{}
+			if b := req.Body(); b != nil {
+				body.Reader = b
+				req.SetBody(body)
+			}
+			res := c.Response()
+			err := next(c)
+			if c.Path() == "" {
+				return err
+			}
+			// Bodies not read by the handler count for their length.
+			in := body.n
+			if n := req.ContentLength(); n > in {
+				in = n
+			}
+			config.Stats.add(req.Method(), c.Path(), in, res.Size())
+			return err
+		}
+	}
+}
+
+// Routes returns the sizes of the recorded routes, sorted by path and method.
+func (s *PayloadSizeStats) Routes() []RoutePayloadSize {
+	s.mu.Lock()
+	routes := make([]RoutePayloadSize, 0, len(s.routes))
+	for _, r := range s.routes {
+		routes = append(routes, RoutePayloadSize{
+			Method:   r.method,
+			Path:     r.path,
+			Request:  r.request.summary(),
+			Response: r.response.summary(),
+		})
+	}
+	s.mu.Unlock()
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// Top returns the n routes with the largest payloads, by the 90th percentile
+// of their request or response size, whichever is larger.
+func (s *PayloadSizeStats) Top(n int) []RoutePayloadSize {
+	routes := s.Routes()
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].largest() > routes[j].largest()
+	})
+	if n >= 0 && n < len(routes) {
+		routes = routes[:n]
+	}
+	return routes
+}
+
+// Reset forgets the recorded sizes.
+func (s *PayloadSizeStats) Reset() {
+	s.mu.Lock()
+	s.routes = make(map[string]*routePayloadSize)
+	s.mu.Unlock()
+}
+
+// Handler returns a handler rendering `PayloadSizeStats#Top()` as JSON, with
+// the number of routes in the query param "n" (default 10), e.g. for an admin
+// endpoint:
+//
+//	lee.GET("/debug/sizes", stats.Handler(), middleware.BasicAuth(v))
+func (s *PayloadSizeStats) Handler() leego.HandlerFunc {
+	return func(c leego.Context) leego.LeeError {
+		n := 10
+		if v := c.QueryParam("n"); v != "" {
+			var err error
+			if n, err = strconv.Atoi(v); err != nil || n < 0 {
+				return leego.NewHTTPError(http.StatusBadRequest, "invalid n")
+			}
+		}
+		return c.JSON(http.StatusOK, s.Top(n))
+	}
+}
+
+func (s *PayloadSizeStats) add(method, path string, in, out int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := method + " " + path
+	r, ok := s.routes[key]
+	if !ok {
+		r = &routePayloadSize{method: method, path: path}
+		s.routes[key] = r
+	}
+	r.request.add(in, s.rand)
+	r.response.add(out, s.rand)
+}
+
+func (r RoutePayloadSize) largest() int64 {
+	if r.Request.P90 > r.Response.P90 {
+		return r.Request.P90
+	}
+	return r.Response.P90
+}
+
+func (s *sizeSample) add(n int64, rnd *rand.Rand) {
+	s.count++
+	s.total += n
+	if n > s.max {
+		s.max = n
+	}
+	if len(s.samples) < payloadSizeSamples {
+		s.samples = append(s.samples, n)
+	} else if i := rnd.Int63n(s.count); i < payloadSizeSamples {
+		s.samples[i] = n
+	}
+}
+
+func (s *sizeSample) summary() PayloadSizeSummary {
+	sorted := make([]int64, len(s.samples))
+	copy(sorted, s.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return PayloadSizeSummary{
+		Count: s.count,
+		Total: s.total,
+		Max:   s.max,
+		P50:   percentile(sorted, 50),
+		P90:   percentile(sorted, 90),
+		P99:   percentile(sorted, 99),
+	}
+}
+
+// percentile returns the nearest-rank percentile p of the sorted sizes.
+func percentile(sorted []int64, p int) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := (len(sorted)*p + 99) / 100
+	if i < 1 {
+		i = 1
+	}
+	return sorted[i-1]
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	r.n += int64(n)
+	return n, err
+}
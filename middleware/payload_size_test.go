@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPayloadSize(t *testing.T) {
+	stats := NewPayloadSizeStats()
+	lee := leego.New()
+	request := func(method, path, body string, h leego.HandlerFunc) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		c := lee.NewContext(standard.NewRequest(req), standard.NewResponse(httptest.NewRecorder()))
+		c.SetPath(path)
+		PayloadSize(stats)(h)(c)
+	}
+	list := func(c leego.Context) leego.LeeError {
+		return c.String(http.StatusOK, strings.Repeat("x", 1000))
+	}
+	create := func(c leego.Context) leego.LeeError {
+		ioutil.ReadAll(c.Request().Body())
+		return c.NoContent(http.StatusCreated)
+	}
+	for i := 1; i <= 100; i++ {
+		request(leego.GET, "/items", "", list)
+		request(leego.POST, "/items", strings.Repeat("y", i*10), create)
+	}
+	request(leego.GET, "/ping", "", func(c leego.Context) leego.LeeError {
+		return c.String(http.StatusOK, "pong")
+	})
+
+	routes := stats.Routes()
+	if assert.Len(t, routes, 3) {
+		assert.Equal(t, "/items", routes[0].Path)
+		assert.Equal(t, leego.GET, routes[0].Method)
+		assert.Equal(t, PayloadSizeSummary{Count: 100, Total: 100000, Max: 1000, P50: 1000, P90: 1000, P99: 1000}, routes[0].Response)
+		assert.Equal(t, leego.POST, routes[1].Method)
+		assert.Equal(t, PayloadSizeSummary{Count: 100, Total: 50500, Max: 1000, P50: 500, P90: 900, P99: 990}, routes[1].Request)
+	}
+
+	top := stats.Top(2)
+	if assert.Len(t, top, 2) {
+		assert.Equal(t, leego.GET, top[0].Method)
+		assert.Equal(t, leego.POST, top[1].Method)
+	}
+
+	// Handler
+	rec := httptest.NewRecorder()
+	c := lee.NewContext(standard.NewRequest(httptest.NewRequest(leego.GET, "/?n=1", nil)), standard.NewResponse(rec))
+	if assert.NoError(t, stats.Handler()(c)) {
+		var report []RoutePayloadSize
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+		if assert.Len(t, report, 1) {
+			assert.Equal(t, "/items", report[0].Path)
+		}
+	}
+
+	stats.Reset()
+	assert.Empty(t, stats.Routes())
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// ProblemConfig defines the config for Problem middleware.
+	ProblemConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+	}
+)
+
+var (
+	// DefaultProblemConfig is the default Problem middleware config.
+	DefaultProblemConfig = ProblemConfig{
+		Skipper: defaultSkipper,
+	}
+)
+
+// Problem returns a middleware which sends the errors of the next handlers as
+// RFC 7807 problems, see `Leego#ProblemHTTPErrorHandler()`, e.g. on the routes
+// of a public API only. The error is still returned, for logging, with the
+// response committed.
+func Problem() leego.MiddlewareFunc {
+	return ProblemWithConfig(DefaultProblemConfig)
+}
+
+// ProblemWithConfig returns a Problem middleware from config.
+// See `Problem()`.
+func ProblemWithConfig(config ProblemConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultProblemConfig.Skipper
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			err := next(c)
+			if err != nil {
+				c.Leego().ProblemHTTPErrorHandler(err, c)
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProblem(t *testing.T) {
+	lee := leego.New()
+	api := lee.Group("/api", Problem())
+	api.GET("/users/:id", func(c leego.Context) leego.LeeError {
+		return leego.ErrNotFound
+	})
+	lee.GET("/users/:id", func(c leego.Context) leego.LeeError {
+		return leego.ErrNotFound
+	})
+	request := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, path, nil)), standard.NewResponse(rec))
+		return rec
+	}
+
+	rec := request("/api/users/1")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, leego.MIMEApplicationProblemJSON, rec.Header().Get(leego.HeaderContentType))
+	assert.Equal(t, `{"instance":"/api/users/1","status":404,"title":"Not Found","type":"about:blank"}`, rec.Body.String())
+
+	rec = request("/users/1")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "Not Found", rec.Body.String())
+}
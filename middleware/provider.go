@@ -0,0 +1,210 @@
+package middleware
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// Provider creates a middleware from its config. Middleware packages register
+	// a provider with `Register()`, typically from an `init()` function, so the
+	// middleware can be looked up by name, e.g. when the middleware stack is built
+	// from a config file.
+	Provider interface {
+		// Name returns the unique name of the middleware, e.g. "cors".
+		Name() string
+
+		// DefaultConfig returns a pointer to a new default config, for the caller
+		// to decode the user config into.
+		DefaultConfig() interface{}
+
+		// New returns the middleware from a config returned by `DefaultConfig()`.
+		New(config interface{}) (leego.MiddlewareFunc, error)
+	}
+
+	provider struct {
+		name          string
+		defaultConfig func() interface{}
+		new           func(interface{}) (leego.MiddlewareFunc, error)
+	}
+)
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]Provider)
+)
+
+// NewProvider returns a `Provider` from its functions.
+func NewProvider(name string, defaultConfig func() interface{}, newMiddleware func(config interface{}) (leego.MiddlewareFunc, error)) Provider {
+	return &provider{name: name, defaultConfig: defaultConfig, new: newMiddleware}
+}
+
+func (p *provider) Name() string {
+	return p.name
+}
+
+func (p *provider) DefaultConfig() interface{} {
+	return p.defaultConfig()
+}
+
+func (p *provider) New(config interface{}) (leego.MiddlewareFunc, error) {
+	return p.new(config)
+}
+
+// Register makes a middleware provider available by its name. It panics if
+// the name is empty or a provider is already registered with it.
+func Register(p Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	name := p.Name()
+	if name == "" {
+		panic("middleware: provider name is empty")
+	}
+	if _, dup := providers[name]; dup {
+		panic("middleware: provider registered twice for " + name)
+	}
+	providers[name] = p
+}
+
+// Lookup returns the provider registered with name.
+func Lookup(name string) (Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	p, ok := providers[name]
+	return p, ok
+}
+
+// Providers returns the sorted names of the registered providers.
+func Providers() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Build returns the middleware registered with name. Its default config is
+// passed to decode, e.g. a closure around `json.Unmarshal`, to apply the user
+// config before the middleware is created. decode may be nil.
+func Build(name string, decode func(config interface{}) error) (leego.MiddlewareFunc, error) {
+	p, ok := Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("middleware: unknown provider %q", name)
+	}
+	config := p.DefaultConfig()
+	if decode != nil {
+		if err := decode(config); err != nil {
+			return nil, fmt.Errorf("middleware: %s config: %v", name, err)
+		}
+	}
+	return p.New(config)
+}
+
+func invalidConfig(name string, config interface{}) error {
+	return fmt.Errorf("middleware: invalid %s config type %T", name, config)
+}
+
+func init() {
+	Register(NewProvider("logger", func() interface{} {
+		c := DefaultLoggerConfig
+		return &c
+	}, func(config interface{}) (leego.MiddlewareFunc, error) {
+		if c, ok := config.(*LoggerConfig); ok {
+			return LoggerWithConfig(*c), nil
+		}
+		return nil, invalidConfig("logger", config)
+	}))
+	Register(NewProvider("add-trailing-slash", func() interface{} {
+		c := DefaultTrailingSlashConfig
+		return &c
+	}, func(config interface{}) (leego.MiddlewareFunc, error) {
+		if c, ok := config.(*TrailingSlashConfig); ok {
+			return AddTrailingSlashWithConfig(*c), nil
+		}
+		return nil, invalidConfig("add-trailing-slash", config)
+	}))
+	Register(NewProvider("remove-trailing-slash", func() interface{} {
+		c := DefaultTrailingSlashConfig
+		return &c
+	}, func(config interface{}) (leego.MiddlewareFunc, error) {
+		if c, ok := config.(*TrailingSlashConfig); ok {
+			return RemoveTrailingSlashWithConfig(*c), nil
+		}
+		return nil, invalidConfig("remove-trailing-slash", config)
+	}))
+	Register(NewProvider("static", func() interface{} {
+		c := DefaultStaticConfig
+		return &c
+	}, func(config interface{}) (leego.MiddlewareFunc, error) {
+		c, ok := config.(*StaticConfig)
+		if !ok {
+			return nil, invalidConfig("static", config)
+		}
+		if c.Root == "" {
+			return nil, fmt.Errorf("middleware: static config requires root")
+		}
+		return StaticWithConfig(*c), nil
+	}))
+	Register(NewProvider("gzip", func() interface{} {
+		c := DefaultGzipConfig
+		return &c
+	}, func(config interface{}) (leego.MiddlewareFunc, error) {
+		if c, ok := config.(*GzipConfig); ok {
+			return GzipWithConfig(*c), nil
+		}
+		return nil, invalidConfig("gzip", config)
+	}))
+	Register(NewProvider("cors", func() interface{} {
+		c := DefaultCORSConfig
+		return &c
+	}, func(config interface{}) (leego.MiddlewareFunc, error) {
+		if c, ok := config.(*CORSConfig); ok {
+			return CORSWithConfig(*c), nil
+		}
+		return nil, invalidConfig("cors", config)
+	}))
+	Register(NewProvider("jwt", func() interface{} {
+		c := DefaultJWTConfig
+		return &c
+	}, func(config interface{}) (leego.MiddlewareFunc, error) {
+		c, ok := config.(*JWTConfig)
+		if !ok {
+			return nil, invalidConfig("jwt", config)
+		}
+		if len(c.SigningKey) == 0 {
+			return nil, fmt.Errorf("middleware: jwt config requires signing_key")
+		}
+		if _, ok := jwtHashes[c.SigningMethod]; !ok && c.SigningMethod != "" {
+			return nil, fmt.Errorf("middleware: jwt signing method %q not supported", c.SigningMethod)
+		}
+		return JWTWithConfig(*c), nil
+	}))
+	Register(NewProvider("body-limit", func() interface{} {
+		c := DefaultBodyLimitConfig
+		return &c
+	}, func(config interface{}) (leego.MiddlewareFunc, error) {
+		c, ok := config.(*BodyLimitConfig)
+		if !ok {
+			return nil, invalidConfig("body-limit", config)
+		}
+		if _, err := parseBytes(c.Limit); err != nil {
+			return nil, fmt.Errorf("middleware: body-limit config: %v", err)
+		}
+		return BodyLimitWithConfig(*c), nil
+	}))
+	Register(NewProvider("request-id", func() interface{} {
+		c := DefaultRequestIDConfig
+		return &c
+	}, func(config interface{}) (leego.MiddlewareFunc, error) {
+		if c, ok := config.(*RequestIDConfig); ok {
+			return RequestIDWithConfig(*c), nil
+		}
+		return nil, invalidConfig("request-id", config)
+	}))
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvider(t *testing.T) {
+	assert.Equal(t, []string{"add-trailing-slash", "body-limit", "cors", "gzip", "jwt", "logger", "remove-trailing-slash", "request-id", "static"}, Providers())
+
+	m, err := Build("cors", func(config interface{}) error {
+		return json.Unmarshal([]byte(`{"allow_origins": ["https://example.com"], "max_age": 60}`), config)
+	})
+	if assert.NoError(t, err) {
+		lee := leego.New()
+		lee.Use(m)
+		req := httptest.NewRequest(leego.OPTIONS, "/", nil)
+		req.Header.Set(leego.HeaderOrigin, "https://example.com")
+		req.Header.Set(leego.HeaderAccessControlRequestMethod, leego.GET)
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, "https://example.com", rec.Header().Get(leego.HeaderAccessControlAllowOrigin))
+		assert.Equal(t, "60", rec.Header().Get(leego.HeaderAccessControlMaxAge))
+	}
+
+	_, err = Build("unknown", nil)
+	assert.Error(t, err)
+	_, err = Build("jwt", nil)
+	assert.Error(t, err)
+	_, err = Build("cors", func(config interface{}) error {
+		return json.Unmarshal([]byte(`{"max_age": "1h"}`), config)
+	})
+	assert.Error(t, err)
+
+	p, ok := Lookup("gzip")
+	assert.True(t, ok)
+	_, err = p.New(&CORSConfig{})
+	assert.Error(t, err)
+
+	assert.Panics(t, func() {
+		Register(p)
+	})
+}
@@ -0,0 +1,830 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine"
+)
+
+type (
+	// ProxyConfig defines the config for Proxy middleware.
+	ProxyConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Balancer chooses the upstream target of each request.
+		// Required.
+		Balancer ProxyBalancer
+
+		// Rewrite rewrites the path before forwarding, e.g. "/api/*": "/$1",
+		// see `RewriteConfig#Rules`.
+		// Optional. Default value nil.
+		Rewrite map[string]string `json:"rewrite"`
+
+		// Transport sends the requests to the targets, e.g. with the connection
+		// pool of `NewProxyTransport()`. WebSocket upgrades are sent with the
+		// dialer and TLS config of an `*http.Transport`.
+		// Optional. Default value `http.DefaultTransport`.
+		Transport http.RoundTripper
+
+		// Retries is the number of times a request which couldn't reach its
+		// target is retried on the next target of the balancer. Only
+		// idempotent requests, e.g. GET or PUT, are retried, those with a body
+		// if it's buffered, see `ProxyBuffering#RequestBody`. WebSocket
+		// upgrades aren't retried.
+		// Optional. Default value 0.
+		Retries int `json:"retries"`
+
+		// RetryBudget is the ratio of retries to requests, so that retries
+		// don't pile up on failing targets, e.g. 0.2 allows one retry per 5
+		// requests on top of a burst of 10.
+		// Optional. Default value 0.2.
+		RetryBudget float64 `json:"retry_budget"`
+
+		// HedgeDelay is the time after which a GET or HEAD request without
+		// response from its target is also sent to the next target of the
+		// balancer, to cut the tail latency. The first response is sent, the
+		// other request is cancelled. Hedged requests draw on the retry budget.
+		// Optional. Default value 0, not hedged.
+		HedgeDelay time.Duration `json:"hedge_delay"`
+
+		// Buffering defines how the bodies are forwarded, overridden per route
+		// with `WithProxyBuffering()`.
+		// Optional. Default value streams the responses of unknown length and
+		// doesn't buffer the requests.
+		Buffering ProxyBuffering `json:"buffering"`
+
+		// MaxBufferedBytes is the maximum size of the request bodies buffered
+		// at once by the proxy, so that large or many concurrent requests
+		// can't exhaust memory. Requests over it are streamed, and not
+		// retried.
+		// Optional. Default value 64 MB.
+		MaxBufferedBytes int64 `json:"max_buffered_bytes"`
+
+		// FormatLeeError formats the errors returned by the middleware, see
+		// `Middleware#FormatLeeError()`.
+		// Optional. Default value returns the error as is.
+		FormatLeeError func(err error, middlewareName string) leego.LeeError
+	}
+
+	// ProxyTarget is an upstream target, e.g. an instance of an internal
+	// service.
+	ProxyTarget struct {
+		Name string
+		URL  *url.URL
+
+		// Counters of `ProxyTarget#Stats()`, updated atomically.
+		requests int64
+		errors   int64
+		retries  int64
+		hedges   int64
+		inFlight int64
+		latency  int64
+	}
+
+	// ProxyTargetStats are the counters of a target since it's created, see
+	// `ProxyTarget#Stats()`.
+	ProxyTargetStats struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+
+		// Requests is the number of requests sent to the target, retries
+		// included.
+		Requests int64 `json:"requests"`
+
+		// Errors is the number of requests which couldn't reach the target.
+		Errors int64 `json:"errors"`
+
+		// Retries is the number of requests retried on the target after an
+		// error of another.
+		Retries int64 `json:"retries"`
+
+		// Hedges is the number of hedged requests sent to the target, see
+		// `ProxyConfig#HedgeDelay`.
+		Hedges int64 `json:"hedges"`
+
+		// InFlight is the number of requests in progress.
+		InFlight int64 `json:"in_flight"`
+
+		// Latency is the mean time to the response header, or to the
+		// cancellation of the request, e.g. by the client.
+		Latency time.Duration `json:"latency"`
+	}
+
+	// ProxyBuffering defines how the proxy forwards the bodies of a route.
+	ProxyBuffering struct {
+		// Stream streams the bodies end to end, flushing the response on
+		// every write, e.g. for server-sent events or large downloads.
+		// Request bodies aren't buffered.
+		Stream bool `json:"stream"`
+
+		// RequestBody is the maximum size of the request bodies buffered, so
+		// that idempotent requests with a body, e.g. PUT, are retried, see
+		// `ProxyConfig#Retries`. Larger bodies are streamed.
+		// Optional. Default value 0, not buffered.
+		RequestBody int64 `json:"request_body"`
+	}
+
+	// proxyBufferingKey is the context key of the buffering of a route.
+	proxyBufferingKey struct{}
+
+	// proxyResult is the result of a hedged request.
+	proxyResult struct {
+		i      int
+		target *ProxyTarget
+		resp   *http.Response
+		err    error
+	}
+
+	// cancelBody cancels the request of the response once its body is closed.
+	cancelBody struct {
+		io.ReadCloser
+		cancel context.CancelFunc
+	}
+
+	// ProxyTransportConfig defines the connection pool of the transport to the
+	// targets, see `NewProxyTransport()`.
+	ProxyTransportConfig struct {
+		// MaxIdleConns is the maximum number of idle connections to all
+		// targets.
+		// Optional. Default value 100.
+		MaxIdleConns int `json:"max_idle_conns"`
+
+		// MaxIdleConnsPerHost is the maximum number of idle connections to a
+		// target.
+		// Optional. Default value 2.
+		MaxIdleConnsPerHost int `json:"max_idle_conns_per_host"`
+
+		// IdleConnTimeout is the time an idle connection is kept.
+		// Optional. Default value 90 seconds.
+		IdleConnTimeout time.Duration `json:"idle_conn_timeout"`
+
+		// DialTimeout is the timeout of connecting to a target.
+		// Optional. Default value 30 seconds.
+		DialTimeout time.Duration `json:"dial_timeout"`
+
+		// ResponseHeaderTimeout is the time to wait for the response header
+		// of a target once the request is sent.
+		// Optional. Default value 0, no timeout.
+		ResponseHeaderTimeout time.Duration `json:"response_header_timeout"`
+
+		// TLSConfig is the TLS config of HTTPS targets, e.g. with the CA of
+		// internal services.
+		// Optional. Default value nil.
+		TLSConfig *tls.Config `json:"-"`
+
+		// DisableHTTP2 disables HTTP/2 to HTTPS targets.
+		// Optional. Default value false.
+		DisableHTTP2 bool `json:"disable_http2"`
+	}
+
+	// retryBudget allows a retry per 1/ratio requests, on top of a burst.
+	retryBudget struct {
+		mu     sync.Mutex
+		ratio  float64
+		tokens float64
+	}
+
+	// ProxyBalancer chooses the upstream target of a request.
+	ProxyBalancer interface {
+		Next(c leego.Context) *ProxyTarget
+	}
+
+	// hedgeBalancer is implemented by the balancers whose `Next()` pins the
+	// client to the target, e.g. with a cookie. Hedged requests go to the
+	// target of nextHedge, which doesn't, so that the client stays pinned
+	// whichever target wins.
+	hedgeBalancer interface {
+		nextHedge(c leego.Context) *ProxyTarget
+	}
+
+	roundRobinBalancer struct {
+		targets []*ProxyTarget
+		i       uint32
+	}
+
+	randomBalancer struct {
+		targets []*ProxyTarget
+		mu      sync.Mutex
+		rand    *rand.Rand
+	}
+)
+
+const (
+	proxyMiddlewareName   = "proxy"
+	proxyDialTimeout      = 30 * time.Second
+	proxyRetryBudgetBurst = 10
+	proxyMaxBufferedBytes = 64 << 20
+)
+
+var (
+	// DefaultProxyConfig is the default Proxy middleware config.
+	DefaultProxyConfig = ProxyConfig{
+		Skipper:          defaultSkipper,
+		Transport:        http.DefaultTransport,
+		RetryBudget:      0.2,
+		MaxBufferedBytes: proxyMaxBufferedBytes,
+		FormatLeeError:   defaultFormatLeeError,
+	}
+
+	// ErrBadGateway is returned when the target can't be reached, with the
+	// error of the transport as internal error.
+	ErrBadGateway = leego.NewHTTPError(http.StatusBadGateway)
+
+	// hopHeaders are the headers of a connection, not forwarded.
+	hopHeaders = []string{
+		"Connection",
+		"Keep-Alive",
+		"Proxy-Authenticate",
+		"Proxy-Authorization",
+		"Proxy-Connection",
+		"Te",
+		"Trailer",
+		"Transfer-Encoding",
+		"Upgrade",
+	}
+)
+
+// Proxy returns a middleware which forwards the requests to the targets chosen
+// by balancer, e.g. for an API gateway in front of internal services:
+//
+//	api := lee.Group("/api", middleware.Proxy(middleware.NewRoundRobinBalancer(targets...)))
+//	api.Any("/*", leego.NotFoundHandler)
+//
+// It sets `X-Forwarded-For`, `X-Forwarded-Proto`, `X-Forwarded-Host` and
+// `X-Real-IP`, and passes WebSocket upgrades through. The next handler isn't
+// called.
+func Proxy(balancer ProxyBalancer) leego.MiddlewareFunc {
+	c := DefaultProxyConfig
+	c.Balancer = balancer
+	return ProxyWithConfig(c)
+}
+
+// ProxyWithConfig returns a Proxy middleware from config.
+// See `Proxy()`.
+func ProxyWithConfig(config ProxyConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultProxyConfig.Skipper
+	}
+	if config.Balancer == nil {
+		panic("proxy middleware requires balancer")
+	}
+	if config.Transport == nil {
+		config.Transport = DefaultProxyConfig.Transport
+	}
+	if config.RetryBudget == 0 {
+		config.RetryBudget = DefaultProxyConfig.RetryBudget
+	}
+	if config.MaxBufferedBytes == 0 {
+		config.MaxBufferedBytes = DefaultProxyConfig.MaxBufferedBytes
+	}
+	if config.FormatLeeError == nil {
+		config.FormatLeeError = DefaultProxyConfig.FormatLeeError
+	}
+
+	// Initialize
+	rewrites := compileRewriteRules(config.Rewrite)
+	budget := &retryBudget{ratio: config.RetryBudget, tokens: proxyRetryBudgetBurst}
+	var buffered int64
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			target := config.Balancer.Next(c)
+			if target == nil {
+				return config.FormatLeeError(ErrBadGateway, proxyMiddlewareName)
+			}
+			req := c.Request()
+			path, _ := rewritePath(rewrites, req.URL().Path())
+			if isWebSocketUpgrade(req) {
+				if err := proxyWebSocket(c, proxyURL(target, path, req), config.Transport); err != nil {
+					return config.FormatLeeError(ErrBadGateway.SetInternal(err), proxyMiddlewareName)
+				}
+				return nil
+			}
+
+			buffering := config.Buffering
+			if b, ok := c.Get(proxyBufferingKey{}).(ProxyBuffering); ok {
+				buffering = b
+			}
+			var body []byte
+			// The target may have received the request, so it's sent again only
+			// if idempotent.
+			idempotent := isIdempotent(req.Method())
+			retryable := idempotent && req.ContentLength() == 0
+			if idempotent && !retryable && !buffering.Stream && buffering.RequestBody > 0 {
+				var err error
+				if body, err = bufferProxyBody(req, buffering.RequestBody, &buffered, config.MaxBufferedBytes); err != nil {
+					return config.FormatLeeError(err, proxyMiddlewareName)
+				}
+				if body != nil {
+					defer atomic.AddInt64(&buffered, -buffering.RequestBody)
+					retryable = true
+				}
+			}
+
+			m := req.Method()
+			hedge := config.HedgeDelay > 0 && (m == leego.GET || m == leego.HEAD) && req.ContentLength() == 0
+			budget.deposit()
+			var resp *http.Response
+			var err error
+			for attempt := 0; ; attempt++ {
+				if attempt == 0 && hedge {
+					resp, target, err = proxyHedge(c, config, budget, target, path)
+				} else {
+					resp, err = proxyRoundTrip(c, target, proxyURL(target, path, req), config.Transport, body)
+				}
+				if err == nil || attempt == config.Retries || !retryable || c.Context().Err() != nil || !budget.withdraw() {
+					break
+				}
+				if target = config.Balancer.Next(c); target == nil {
+					break
+				}
+				atomic.AddInt64(&target.retries, 1)
+			}
+			if err != nil {
+				return config.FormatLeeError(ErrBadGateway.SetInternal(err), proxyMiddlewareName)
+			}
+			writeProxyResponse(c, resp, buffering.Stream)
+			atomic.AddInt64(&target.inFlight, -1)
+			return nil
+		}
+	}
+}
+
+// WithProxyBuffering returns a route middleware setting the buffering of the
+// proxy for the route, e.g. to stream server-sent events:
+//
+//	api.GET("/events", leego.NotFoundHandler, middleware.WithProxyBuffering(middleware.ProxyBuffering{Stream: true}))
+func WithProxyBuffering(b ProxyBuffering) leego.MiddlewareFunc {
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			c.Set(proxyBufferingKey{}, b)
+			return next(c)
+		}
+	}
+}
+
+// bufferProxyBody reads the body of req if it's at most limit bytes and there
+// is room for limit more bytes in buffered, returning nil otherwise. A body
+// found too large while reading is put back to be streamed.
+func bufferProxyBody(req engine.Request, limit int64, buffered *int64, max int64) ([]byte, error) {
+	if req.ContentLength() > limit {
+		return nil, nil
+	}
+	if atomic.AddInt64(buffered, limit) > max {
+		atomic.AddInt64(buffered, -limit)
+		return nil, nil
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(req.Body(), limit+1))
+	if err != nil || int64(len(b)) > limit {
+		atomic.AddInt64(buffered, -limit)
+		if err == nil {
+			req.SetBody(io.MultiReader(bytes.NewReader(b), req.Body()))
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// isIdempotent reports whether requests with method may be sent again, see
+// RFC 7231.
+func isIdempotent(method string) bool {
+	switch method {
+	case leego.GET, leego.HEAD, leego.OPTIONS, leego.PUT, leego.DELETE:
+		return true
+	}
+	return false
+}
+
+// NewProxyTransport returns a transport to the targets with the connection
+// pool of config, see `ProxyConfig#Transport`.
+func NewProxyTransport(config ProxyTransportConfig) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if config.MaxIdleConns != 0 {
+		t.MaxIdleConns = config.MaxIdleConns
+	}
+	if config.MaxIdleConnsPerHost != 0 {
+		t.MaxIdleConnsPerHost = config.MaxIdleConnsPerHost
+	}
+	if config.IdleConnTimeout != 0 {
+		t.IdleConnTimeout = config.IdleConnTimeout
+	}
+	if config.DialTimeout != 0 {
+		t.DialContext = (&net.Dialer{Timeout: config.DialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	}
+	t.ResponseHeaderTimeout = config.ResponseHeaderTimeout
+	if config.TLSConfig != nil {
+		t.TLSClientConfig = config.TLSConfig.Clone()
+	}
+	if config.DisableHTTP2 {
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+	return t
+}
+
+// Stats returns the counters of the target.
+func (t *ProxyTarget) Stats() ProxyTargetStats {
+	s := ProxyTargetStats{
+		Name:     t.Name,
+		Requests: atomic.LoadInt64(&t.requests),
+		Errors:   atomic.LoadInt64(&t.errors),
+		Retries:  atomic.LoadInt64(&t.retries),
+		Hedges:   atomic.LoadInt64(&t.hedges),
+		InFlight: atomic.LoadInt64(&t.inFlight),
+	}
+	if t.URL != nil {
+		s.URL = t.URL.String()
+	}
+	if n := s.Requests - s.Errors; n > 0 {
+		s.Latency = time.Duration(atomic.LoadInt64(&t.latency) / n)
+	}
+	return s
+}
+
+func (b *retryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens += b.ratio; b.tokens > proxyRetryBudgetBurst {
+		b.tokens = proxyRetryBudgetBurst
+	}
+}
+
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// NewRoundRobinBalancer returns a balancer choosing the targets in turn.
+func NewRoundRobinBalancer(targets ...*ProxyTarget) ProxyBalancer {
+	return &roundRobinBalancer{targets: targets}
+}
+
+// NewRandomBalancer returns a balancer choosing the targets at random.
+func NewRandomBalancer(targets ...*ProxyTarget) ProxyBalancer {
+	return &randomBalancer{
+		targets: targets,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Next implements `ProxyBalancer#Next()`.
+func (b *roundRobinBalancer) Next(leego.Context) *ProxyTarget {
+	if len(b.targets) == 0 {
+		return nil
+	}
+	i := atomic.AddUint32(&b.i, 1) - 1
+	return b.targets[i%uint32(len(b.targets))]
+}
+
+// Next implements `ProxyBalancer#Next()`.
+func (b *randomBalancer) Next(leego.Context) *ProxyTarget {
+	if len(b.targets) == 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.targets[b.rand.Intn(len(b.targets))]
+}
+
+func joinProxyPath(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case strings.HasSuffix(a, "/") && strings.HasPrefix(b, "/"):
+		return a + b[1:]
+	case !strings.HasSuffix(a, "/") && !strings.HasPrefix(b, "/"):
+		return a + "/" + b
+	}
+	return a + b
+}
+
+func isWebSocketUpgrade(req engine.Request) bool {
+	return strings.EqualFold(req.Header().Get(leego.HeaderUpgrade), "websocket")
+}
+
+// proxyHeader returns the headers of the request to forward. `X-Real-IP` is
+// the client IP, see `Context#RealIP()`, not the one sent by the client.
+func proxyHeader(c leego.Context) http.Header {
+	req := c.Request()
+	h := make(http.Header)
+	for _, k := range req.Header().Keys() {
+		for _, v := range req.Header().Values(k) {
+			h.Add(k, v)
+		}
+	}
+	removeHopHeaders(h)
+	ip := req.RemoteAddress()
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	if prior := strings.Join(h.Values(leego.HeaderXForwardedFor), ", "); prior != "" {
+		h.Set(leego.HeaderXForwardedFor, prior+", "+ip)
+	} else {
+		h.Set(leego.HeaderXForwardedFor, ip)
+	}
+	h.Set(leego.HeaderXRealIP, c.RealIP())
+	h.Set(leego.HeaderXForwardedProto, req.Scheme())
+	h.Set(leego.HeaderXForwardedHost, req.Host())
+	return h
+}
+
+func removeHopHeaders(h http.Header) {
+	for _, f := range strings.Split(h.Get("Connection"), ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			h.Del(f)
+		}
+	}
+	for _, k := range hopHeaders {
+		h.Del(k)
+	}
+}
+
+// proxyURL returns the URL of the request at path to target.
+func proxyURL(target *ProxyTarget, path string, req engine.Request) *url.URL {
+	u := *target.URL
+	u.Path = joinProxyPath(u.Path, path)
+	u.RawPath = ""
+	u.RawQuery = req.URL().QueryString()
+	return &u
+}
+
+// proxyRoundTrip sends the request to target, with the buffered body if not
+// nil. Unless it fails, the target has it in flight until the response is
+// written.
+func proxyRoundTrip(c leego.Context, target *ProxyTarget, u *url.URL, transport http.RoundTripper, body []byte) (*http.Response, error) {
+	out, err := newProxyRequest(c, u, body)
+	if err != nil {
+		return nil, err
+	}
+	return sendProxyRequest(target, out, transport)
+}
+
+// proxyHedge sends the request to target and, without response after
+// `ProxyConfig#HedgeDelay`, to the next target of the balancer too, returning
+// the first response and its target. The other request is cancelled. It fails
+// if all the requests sent fail.
+func proxyHedge(c leego.Context, config ProxyConfig, budget *retryBudget, target *ProxyTarget, path string) (*http.Response, *ProxyTarget, error) {
+	results := make(chan proxyResult, 2)
+	var cancels []context.CancelFunc
+	send := func(t *ProxyTarget) error {
+		out, err := newProxyRequest(c, proxyURL(t, path, c.Request()), nil)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithCancel(c.Context())
+		i := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := sendProxyRequest(t, out.WithContext(ctx), config.Transport)
+			results <- proxyResult{i: i, target: t, resp: resp, err: err}
+		}()
+		return nil
+	}
+	if err := send(target); err != nil {
+		return nil, target, err
+	}
+
+	next := config.Balancer.Next
+	if b, ok := config.Balancer.(hedgeBalancer); ok {
+		next = b.nextHedge
+	}
+	timer := time.NewTimer(config.HedgeDelay)
+	defer timer.Stop()
+	pending := 1
+	var r proxyResult
+	for {
+		select {
+		case <-timer.C:
+			if t := next(c); t != nil && t != target && budget.withdraw() {
+				if send(t) == nil {
+					atomic.AddInt64(&t.hedges, 1)
+					pending++
+				}
+			}
+			continue
+		case r = <-results:
+		}
+		pending--
+		if r.err == nil || pending == 0 {
+			break
+		}
+	}
+
+	for i, cancel := range cancels {
+		if i != r.i || r.err != nil {
+			cancel()
+		}
+	}
+	if pending > 0 {
+		// The loser may have responded before being cancelled.
+		go func() {
+			if l := <-results; l.err == nil {
+				l.resp.Body.Close()
+				atomic.AddInt64(&l.target.inFlight, -1)
+			}
+		}()
+	}
+	if r.err != nil {
+		return nil, r.target, r.err
+	}
+	r.resp.Body = cancelBody{r.resp.Body, cancels[r.i]}
+	return r.resp, r.target, nil
+}
+
+// Close implements `io.Closer#Close()`.
+func (b cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// newProxyRequest returns the request to u, with the buffered body if not nil.
+func newProxyRequest(c leego.Context, u *url.URL, body []byte) (*http.Request, error) {
+	req := c.Request()
+	var r io.Reader = req.Body()
+	length := req.ContentLength()
+	if body != nil {
+		r = bytes.NewReader(body)
+		length = int64(len(body))
+	}
+	out, err := http.NewRequestWithContext(c.Context(), req.Method(), u.String(), r)
+	if err != nil {
+		return nil, err
+	}
+	out.Header = proxyHeader(c)
+	out.ContentLength = length
+	if out.ContentLength == 0 {
+		out.Body = nil
+	}
+	return out, nil
+}
+
+// sendProxyRequest sends out to target, counting it in the stats of target.
+func sendProxyRequest(target *ProxyTarget, out *http.Request, transport http.RoundTripper) (*http.Response, error) {
+	atomic.AddInt64(&target.requests, 1)
+	atomic.AddInt64(&target.inFlight, 1)
+	start := time.Now()
+	resp, err := transport.RoundTrip(out)
+	if err != nil {
+		if out.Context().Err() != nil {
+			// Cancelled by the client, or as the loser of hedged requests
+			atomic.AddInt64(&target.latency, int64(time.Since(start)))
+		} else {
+			atomic.AddInt64(&target.errors, 1)
+		}
+		atomic.AddInt64(&target.inFlight, -1)
+		return nil, err
+	}
+	atomic.AddInt64(&target.latency, int64(time.Since(start)))
+	return resp, nil
+}
+
+// writeProxyResponse copies resp to the response, flushing every write if
+// stream.
+func writeProxyResponse(c leego.Context, resp *http.Response, stream bool) {
+	defer resp.Body.Close()
+
+	removeHopHeaders(resp.Header)
+	res := c.Response()
+	for k, vs := range resp.Header {
+		for _, v := range vs {
+			res.Header().Add(k, v)
+		}
+	}
+	res.WriteHeader(resp.StatusCode)
+	// Responses of unknown length, e.g. event streams, are flushed as they
+	// come.
+	stream = stream || resp.ContentLength < 0
+	buf := make([]byte, 32<<10)
+	for {
+		n, rerr := resp.Body.Read(buf)
+		if n > 0 {
+			if _, werr := res.Write(buf[:n]); werr != nil {
+				return
+			}
+			if stream {
+				res.Flush()
+			}
+		}
+		if rerr != nil {
+			// The response is committed, so errors can only cut it short.
+			return
+		}
+	}
+}
+
+// proxyWebSocket passes a WebSocket upgrade through to u, then copies the
+// frames both ways until either side closes.
+func proxyWebSocket(c leego.Context, u *url.URL, transport http.RoundTripper) error {
+	req := c.Request()
+	upstream, err := dialWebSocket(c.Context(), u, transport)
+	if err != nil {
+		return err
+	}
+	out, err := http.NewRequest(req.Method(), u.String(), nil)
+	if err != nil {
+		upstream.Close()
+		return err
+	}
+	out.Header = proxyHeader(c)
+	out.Header.Set("Connection", "Upgrade")
+	out.Header.Set(leego.HeaderUpgrade, req.Header().Get(leego.HeaderUpgrade))
+	if err = out.Write(upstream); err != nil {
+		upstream.Close()
+		return err
+	}
+
+	conn, rw, err := c.Response().Hijack()
+	if err != nil {
+		upstream.Close()
+		return err
+	}
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, rw)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, upstream)
+		done <- struct{}{}
+	}()
+	<-done
+	conn.Close()
+	upstream.Close()
+	<-done
+	return nil
+}
+
+// dialWebSocket connects to the target of a WebSocket upgrade with the dialer
+// and TLS config of transport, if it's an `*http.Transport`, e.g. from
+// `NewProxyTransport()`.
+func dialWebSocket(ctx context.Context, u *url.URL, transport http.RoundTripper) (net.Conn, error) {
+	dial := (&net.Dialer{Timeout: proxyDialTimeout}).DialContext
+	var tlsConfig *tls.Config
+	var handshakeTimeout time.Duration
+	if t, ok := transport.(*http.Transport); ok {
+		if t.DialContext != nil {
+			dial = t.DialContext
+		}
+		tlsConfig = t.TLSClientConfig
+		handshakeTimeout = t.TLSHandshakeTimeout
+	}
+	secure := u.Scheme == "https" || u.Scheme == "wss"
+	host := u.Host
+	if u.Port() == "" {
+		if secure {
+			host += ":443"
+		} else {
+			host += ":80"
+		}
+	}
+	conn, err := dial(ctx, "tcp", host)
+	if err != nil || !secure {
+		return conn, err
+	}
+
+	if tlsConfig == nil {
+		tlsConfig = new(tls.Config)
+	} else {
+		tlsConfig = tlsConfig.Clone()
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = u.Hostname()
+	}
+	// Upgrades need HTTP/1.1.
+	tlsConfig.NextProtos = nil
+	tc := tls.Client(conn, tlsConfig)
+	if handshakeTimeout > 0 {
+		tc.SetDeadline(time.Now().Add(handshakeTimeout))
+	}
+	if err = tc.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tc.SetDeadline(time.Time{})
+	return tc, nil
+}
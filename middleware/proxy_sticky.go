@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"encoding/hex"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// ProxyHashKeyFunc returns the key a hash balancer pins a request by,
+	// e.g. `ProxyHashKeyIP`.
+	ProxyHashKeyFunc func(c leego.Context) string
+
+	cookieBalancer struct {
+		cookie   string
+		targets  map[string]*ProxyTarget
+		fallback ProxyBalancer
+	}
+
+	hashBalancer struct {
+		key      ProxyHashKeyFunc
+		ring     []hashNode
+		targets  int
+		fallback ProxyBalancer
+	}
+
+	// hashNode is a point of a target on the ring of a hash balancer.
+	hashNode struct {
+		hash   uint32
+		target *ProxyTarget
+	}
+
+	// stickyKey is the context key of the choices of a sticky balancer for
+	// the request, so that a retry goes to another target.
+	stickyKey struct {
+		b ProxyBalancer
+	}
+)
+
+// proxyHashReplicas is the number of points of a target on the ring, so that
+// the keys spread evenly.
+const proxyHashReplicas = 100
+
+// NewCookieBalancer returns a balancer pinning each client to a target with
+// the cookie name, e.g. for upstreams keeping sessions in memory. New clients,
+// and clients whose target is gone, are balanced in turn. A retry pins the
+// client to another target.
+func NewCookieBalancer(name string, targets ...*ProxyTarget) ProxyBalancer {
+	b := &cookieBalancer{
+		cookie:   name,
+		targets:  make(map[string]*ProxyTarget, len(targets)),
+		fallback: NewRoundRobinBalancer(targets...),
+	}
+	for _, t := range targets {
+		b.targets[targetID(t)] = t
+	}
+	return b
+}
+
+// NewHashBalancer returns a balancer choosing the target by consistent hashing
+// of the key of the request, e.g. the client IP or a user header, so that the
+// requests with the same key go to the same target while the targets stay the
+// same. Adding or removing a target only moves the keys of a share of the
+// others. Requests without key are balanced in turn. A retry goes to the next
+// target on the ring. Targets passed more than once are counted once.
+func NewHashBalancer(key ProxyHashKeyFunc, targets ...*ProxyTarget) ProxyBalancer {
+	b := &hashBalancer{
+		key:      key,
+		ring:     make([]hashNode, 0, len(targets)*proxyHashReplicas),
+		fallback: NewRoundRobinBalancer(targets...),
+	}
+	seen := make(map[*ProxyTarget]bool, len(targets))
+	for _, t := range targets {
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		b.targets++
+		id := targetID(t)
+		for i := 0; i < proxyHashReplicas; i++ {
+			b.ring = append(b.ring, hashNode{hash: hash32(id + "#" + strconv.Itoa(i)), target: t})
+		}
+	}
+	sort.Slice(b.ring, func(i, j int) bool {
+		return b.ring[i].hash < b.ring[j].hash
+	})
+	return b
+}
+
+// ProxyHashKeyIP returns the client IP, see `Context#RealIP()`.
+func ProxyHashKeyIP(c leego.Context) string {
+	return c.RealIP()
+}
+
+// ProxyHashKeyHeader returns a `ProxyHashKeyFunc` returning the request header
+// name, e.g. a user or tenant ID set by an authentication middleware.
+func ProxyHashKeyHeader(name string) ProxyHashKeyFunc {
+	return func(c leego.Context) string {
+		return c.Request().Header().Get(name)
+	}
+}
+
+// Next implements `ProxyBalancer#Next()`.
+func (b *cookieBalancer) Next(c leego.Context) *ProxyTarget {
+	key := stickyKey{b}
+	prior, _ := c.Get(key).(*ProxyTarget)
+	if prior == nil {
+		if cookie, err := c.Cookie(b.cookie); err == nil {
+			if t := b.targets[cookie.Value()]; t != nil {
+				c.Set(key, t)
+				return t
+			}
+		}
+	}
+	t := b.fallback.Next(c)
+	if t == prior && len(b.targets) > 1 {
+		t = b.fallback.Next(c)
+	}
+	if t != nil {
+		c.Set(key, t)
+		cookie := &http.Cookie{
+			Name:     b.cookie,
+			Value:    targetID(t),
+			Path:     "/",
+			HttpOnly: true,
+		}
+		c.Response().Header().Add(leego.HeaderSetCookie, cookie.String())
+	}
+	return t
+}
+
+// nextHedge implements `hedgeBalancer#nextHedge()`, choosing another target
+// than the one the client is pinned to, without changing the cookie.
+func (b *cookieBalancer) nextHedge(c leego.Context) *ProxyTarget {
+	prior, _ := c.Get(stickyKey{b}).(*ProxyTarget)
+	t := b.fallback.Next(c)
+	if t == prior && len(b.targets) > 1 {
+		t = b.fallback.Next(c)
+	}
+	return t
+}
+
+// Next implements `ProxyBalancer#Next()`.
+func (b *hashBalancer) Next(c leego.Context) *ProxyTarget {
+	if len(b.ring) == 0 {
+		return nil
+	}
+	k := b.key(c)
+	if k == "" {
+		return b.fallback.Next(c)
+	}
+	key := stickyKey{b}
+	attempt, _ := c.Get(key).(int)
+	c.Set(key, attempt+1)
+
+	h := hash32(k)
+	i := sort.Search(len(b.ring), func(i int) bool {
+		return b.ring[i].hash >= h
+	})
+	// The attempt-th distinct target from the point of the key.
+	attempt %= b.targets
+	seen := make(map[*ProxyTarget]bool, attempt+1)
+	t := b.ring[i%len(b.ring)].target
+	for n := 0; n < len(b.ring); n++ {
+		t = b.ring[(i+n)%len(b.ring)].target
+		if seen[t] {
+			continue
+		}
+		if len(seen) == attempt {
+			break
+		}
+		seen[t] = true
+	}
+	return t
+}
+
+// targetID returns the ID of a target in cookies, which doesn't reveal its
+// address.
+func targetID(t *ProxyTarget) string {
+	h := fnv.New64a()
+	h.Write([]byte(t.URL.String()))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func hash32(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func stickyTargets(n int) []*ProxyTarget {
+	targets := make([]*ProxyTarget, n)
+	for i := range targets {
+		u, _ := url.Parse("http://10.0.0." + strconv.Itoa(i+1) + ":8080")
+		targets[i] = &ProxyTarget{Name: u.Host, URL: u}
+	}
+	return targets
+}
+
+func TestCookieBalancer(t *testing.T) {
+	lee := leego.New()
+	targets := stickyTargets(3)
+	b := NewCookieBalancer("lb", targets...)
+	next := func(cookie string) (*ProxyTarget, *httptest.ResponseRecorder, leego.Context) {
+		req := httptest.NewRequest(leego.GET, "/", nil)
+		if cookie != "" {
+			req.Header.Set(leego.HeaderCookie, cookie)
+		}
+		rec := httptest.NewRecorder()
+		c := lee.NewContext(standard.NewRequest(req), standard.NewResponse(rec))
+		return b.Next(c), rec, c
+	}
+
+	t1, rec, _ := next("")
+	assert.Equal(t, targets[0], t1)
+	cookie := rec.Header().Get(leego.HeaderSetCookie)
+	assert.Contains(t, cookie, "lb="+targetID(t1))
+	assert.False(t, strings.Contains(cookie, "10.0.0.1"))
+	t2, _, _ := next("")
+	assert.Equal(t, targets[1], t2)
+
+	// Pinned
+	for i := 0; i < 3; i++ {
+		pinned, rec, _ := next("lb=" + targetID(t1))
+		assert.Equal(t, t1, pinned)
+		assert.Equal(t, "", rec.Header().Get(leego.HeaderSetCookie))
+	}
+
+	// Unknown target and retry
+	gone, _, _ := next("lb=gone")
+	assert.NotNil(t, gone)
+	pinned, rec, c := next("lb=" + targetID(t1))
+	assert.Equal(t, t1, pinned)
+	retry := b.Next(c)
+	assert.NotEqual(t, t1, retry)
+	assert.Contains(t, rec.Header().Get(leego.HeaderSetCookie), "lb="+targetID(retry))
+}
+
+func TestHashBalancer(t *testing.T) {
+	lee := leego.New()
+	targets := stickyTargets(4)
+	b := NewHashBalancer(ProxyHashKeyHeader("X-User"), targets...)
+	context := func(user string) leego.Context {
+		req := httptest.NewRequest(leego.GET, "/", nil)
+		req.Header.Set("X-User", user)
+		return lee.NewContext(standard.NewRequest(req), standard.NewResponse(httptest.NewRecorder()))
+	}
+
+	chosen := make(map[string]*ProxyTarget)
+	counts := make(map[*ProxyTarget]int)
+	for i := 0; i < 400; i++ {
+		user := strconv.Itoa(i)
+		chosen[user] = b.Next(context(user))
+		counts[chosen[user]]++
+		assert.Equal(t, chosen[user], b.Next(context(user)))
+	}
+	assert.Len(t, counts, 4)
+	for _, n := range counts {
+		assert.True(t, n > 50, "unbalanced: %d", n)
+	}
+
+	// Removing a target only moves its keys.
+	b = NewHashBalancer(ProxyHashKeyHeader("X-User"), targets[:3]...)
+	for user, target := range chosen {
+		if target != targets[3] {
+			assert.Equal(t, target, b.Next(context(user)))
+		}
+	}
+
+	// Retries go to the other targets in turn.
+	c := context("1")
+	seen := map[*ProxyTarget]bool{}
+	for i := 0; i < 3; i++ {
+		seen[b.Next(c)] = true
+	}
+	assert.Len(t, seen, 3)
+
+	// Duplicate targets
+	b = NewHashBalancer(ProxyHashKeyHeader("X-User"), targets[0], targets[0])
+	c = context("1")
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, targets[0], b.Next(c))
+	}
+
+	// Without key
+	b = NewHashBalancer(ProxyHashKeyHeader("X-User"), targets[:3]...)
+	assert.Equal(t, targets[0], b.Next(context("")))
+	assert.Equal(t, targets[1], b.Next(context("")))
+	assert.Nil(t, NewHashBalancer(ProxyHashKeyIP).Next(context("1")))
+}
+
+func TestProxySticky(t *testing.T) {
+	t1, ts1 := proxyTarget(t, "t1")
+	defer ts1.Close()
+	t2, ts2 := proxyTarget(t, "t2")
+	defer ts2.Close()
+
+	lee := leego.New()
+	lee.Use(Proxy(NewCookieBalancer("lb", t1, t2)))
+	request := func(cookie string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(leego.GET, "/", nil)
+		req.Header.Set(leego.HeaderCookie, cookie)
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		return rec
+	}
+	rec := request("")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	target := rec.Header().Get("X-Target")
+	cookie := (&http.Response{Header: http.Header{"Set-Cookie": rec.Header()["Set-Cookie"]}}).Cookies()
+	for _, ck := range cookie {
+		if ck.Name == "lb" {
+			for i := 0; i < 3; i++ {
+				assert.Equal(t, target, request("lb="+ck.Value).Header().Get("X-Target"))
+			}
+			return
+		}
+	}
+	t.Error("no lb cookie")
+}
@@ -0,0 +1,349 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func proxyTarget(t *testing.T, name string) (*ProxyTarget, *httptest.Server) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") == "websocket" {
+			conn, rw, _ := w.(http.Hijacker).Hijack()
+			defer conn.Close()
+			rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+			rw.Flush()
+			line, _ := rw.ReadString('\n')
+			rw.WriteString(name + " " + r.URL.Path + " " + line)
+			rw.Flush()
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Target", name)
+		w.Header().Add("Set-Cookie", "a=1")
+		w.Header().Add("Set-Cookie", "b=2")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "%s %s?%s %s %s %s %s %s", r.Method, r.URL.Path, r.URL.RawQuery, b,
+			r.Header.Get(leego.HeaderXForwardedFor), r.Header.Get(leego.HeaderXForwardedHost),
+			r.Header.Get(leego.HeaderXForwardedProto), r.Header.Get("Proxy-Authorization"))
+	}))
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &ProxyTarget{Name: name, URL: u}, ts
+}
+
+func TestProxy(t *testing.T) {
+	t1, ts1 := proxyTarget(t, "t1")
+	defer ts1.Close()
+	t2, ts2 := proxyTarget(t, "t2")
+	defer ts2.Close()
+
+	lee := leego.New()
+	api := lee.Group("/api", ProxyWithConfig(ProxyConfig{
+		Balancer: NewRoundRobinBalancer(t1, t2),
+		Rewrite: map[string]string{
+			"/api/*":       "/$1",
+			"/api/users/*": "/v2/users/$1",
+		},
+	}))
+	api.Any("/*", leego.NotFoundHandler)
+
+	request := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(leego.POST, target, strings.NewReader("body"))
+		req.Header.Set("Proxy-Authorization", "secret")
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		return rec
+	}
+	rec := request("/api/orders?page=2")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "t1", rec.Header().Get("X-Target"))
+	assert.Equal(t, []string{"a=1", "b=2"}, rec.Header()["Set-Cookie"])
+	assert.Equal(t, "POST /orders?page=2 body 192.0.2.1 example.com http ", rec.Body.String())
+	rec = request("/api/users/1")
+	assert.Equal(t, "t2", rec.Header().Get("X-Target"))
+	assert.Equal(t, "POST /v2/users/1? body 192.0.2.1 example.com http ", rec.Body.String())
+	assert.Equal(t, "t1", request("/api/orders").Header().Get("X-Target"))
+
+	// Unreachable target
+	ts2.Close()
+	rec = request("/api/orders")
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+
+	// Repeated headers
+	req := httptest.NewRequest(leego.GET, "/", nil)
+	req.Header.Add(leego.HeaderAccept, "text/html")
+	req.Header.Add(leego.HeaderAccept, "application/json")
+	req.Header.Add(leego.HeaderXForwardedFor, "192.0.2.10")
+	req.Header.Add(leego.HeaderXForwardedFor, "192.0.2.11")
+	h := proxyHeader(lee.NewContext(standard.NewRequest(req), standard.NewResponse(httptest.NewRecorder())))
+	assert.Equal(t, []string{"text/html", "application/json"}, h[leego.HeaderAccept])
+	assert.Equal(t, "192.0.2.10, 192.0.2.11, 192.0.2.1", h.Get(leego.HeaderXForwardedFor))
+}
+
+func TestProxyWebSocket(t *testing.T) {
+	upgrade := func(config ProxyConfig) {
+		lee := leego.New()
+		lee.Use(ProxyWithConfig(config))
+		s := standard.WithConfig(engine.Config{})
+		s.SetHandler(lee)
+		front := httptest.NewServer(s)
+		defer front.Close()
+
+		conn, err := net.Dial("tcp", strings.TrimPrefix(front.URL, "http://"))
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "GET /chat HTTP/1.1\r\nHost: example.com\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		br := bufio.NewReader(conn)
+		res, err := http.ReadResponse(br, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+		fmt.Fprint(conn, "hello\n")
+		line, err := br.ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, "t1 /chat hello\n", line)
+	}
+
+	target, ts := proxyTarget(t, "t1")
+	defer ts.Close()
+	upgrade(ProxyConfig{Balancer: NewRandomBalancer(target)})
+
+	// HTTPS target trusted by the transport
+	tlsTS := httptest.NewTLSServer(ts.Config.Handler)
+	defer tlsTS.Close()
+	u, _ := url.Parse(tlsTS.URL)
+	roots := x509.NewCertPool()
+	roots.AddCert(tlsTS.Certificate())
+	upgrade(ProxyConfig{
+		Balancer:  NewRandomBalancer(&ProxyTarget{Name: "t1", URL: u}),
+		Transport: NewProxyTransport(ProxyTransportConfig{TLSConfig: &tls.Config{RootCAs: roots}}),
+	})
+}
+
+func TestProxyRetry(t *testing.T) {
+	down, ts1 := proxyTarget(t, "down")
+	ts1.Close()
+	up, ts2 := proxyTarget(t, "up")
+	defer ts2.Close()
+
+	lee := leego.New()
+	lee.Use(ProxyWithConfig(ProxyConfig{
+		Balancer: NewRoundRobinBalancer(down, up),
+		Retries:  1,
+	}))
+	request := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/users", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		return rec
+	}
+	rec := request(leego.GET, "")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "up", rec.Header().Get("X-Target"))
+	assert.Equal(t, http.StatusCreated, request(leego.GET, "").Code)
+
+	// Bodies can't be sent again.
+	assert.Equal(t, http.StatusBadGateway, request(leego.POST, "body").Code)
+
+	s := down.Stats()
+	assert.Equal(t, ts1.URL, s.URL)
+	assert.Equal(t, int64(3), s.Requests)
+	assert.Equal(t, int64(3), s.Errors)
+	assert.Equal(t, int64(0), s.InFlight)
+	s = up.Stats()
+	assert.Equal(t, int64(2), s.Requests)
+	assert.Equal(t, int64(0), s.Errors)
+	assert.Equal(t, int64(2), s.Retries)
+	assert.Equal(t, int64(0), s.InFlight)
+	assert.True(t, s.Latency > 0)
+
+	// Requests which aren't idempotent may have reached the target.
+	lee = leego.New()
+	lee.Use(ProxyWithConfig(ProxyConfig{
+		Balancer: NewRoundRobinBalancer(down, up),
+		Retries:  1,
+	}))
+	assert.Equal(t, http.StatusBadGateway, request(leego.POST, "").Code)
+	assert.Equal(t, int64(2), up.Stats().Requests)
+}
+
+func TestProxyRetryBudget(t *testing.T) {
+	b := &retryBudget{ratio: 0.5, tokens: proxyRetryBudgetBurst}
+	for i := 0; i < proxyRetryBudgetBurst; i++ {
+		assert.True(t, b.withdraw())
+	}
+	assert.False(t, b.withdraw())
+	b.deposit()
+	assert.False(t, b.withdraw())
+	b.deposit()
+	assert.True(t, b.withdraw())
+}
+
+func TestProxyBuffering(t *testing.T) {
+	down, ts1 := proxyTarget(t, "down")
+	ts1.Close()
+	up, ts2 := proxyTarget(t, "up")
+	defer ts2.Close()
+
+	request := func(config ProxyConfig, method, body string, length int64) *httptest.ResponseRecorder {
+		lee := leego.New()
+		lee.Any("/users", ProxyWithConfig(config)(leego.NotFoundHandler),
+			WithProxyBuffering(ProxyBuffering{RequestBody: 4}))
+		req := httptest.NewRequest(method, "/users", strings.NewReader(body))
+		req.ContentLength = length
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		return rec
+	}
+	retry := ProxyConfig{Balancer: NewRoundRobinBalancer(down, up), Retries: 1}
+
+	// Buffered idempotent requests are retried.
+	rec := request(retry, leego.PUT, "body", 4)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "PUT /users? body "))
+	retry.Balancer = NewRoundRobinBalancer(down, up)
+	assert.Equal(t, http.StatusBadGateway, request(retry, leego.POST, "body", 4).Code)
+	retry.Balancer = NewRoundRobinBalancer(down, up)
+	retry.MaxBufferedBytes = 2
+	assert.Equal(t, http.StatusBadGateway, request(retry, leego.PUT, "body", 4).Code)
+
+	// Larger bodies, or of unknown length, are streamed as is.
+	config := ProxyConfig{Balancer: NewRoundRobinBalancer(up)}
+	for _, body := range []string{"bodies", "body\n\n"} {
+		rec = request(config, leego.PUT, body, -1)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.True(t, strings.HasPrefix(rec.Body.String(), "PUT /users? "+body+" "))
+	}
+	rec = request(config, leego.PUT, "bodies", 6)
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "PUT /users? bodies "))
+
+	// Stream
+	lee := leego.New()
+	lee.GET("/events", ProxyWithConfig(config)(leego.NotFoundHandler))
+	lee.GET("/stream", ProxyWithConfig(config)(leego.NotFoundHandler),
+		WithProxyBuffering(ProxyBuffering{Stream: true}))
+	for path, flushed := range map[string]bool{"/events": false, "/stream": true} {
+		rec = httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, path, nil)), standard.NewResponse(rec))
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, flushed, rec.Flushed, path)
+	}
+}
+
+func TestProxyCancel(t *testing.T) {
+	cancelled := make(chan bool, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+			cancelled <- false
+		case <-r.Context().Done():
+			cancelled <- true
+		}
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+	target := &ProxyTarget{Name: "slow", URL: u}
+
+	lee := leego.New()
+	lee.Use(ProxyWithConfig(ProxyConfig{Balancer: NewRoundRobinBalancer(target), Retries: 1}))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(leego.GET, "/users", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+	assert.True(t, <-cancelled)
+	s := target.Stats()
+	assert.Equal(t, int64(1), s.Requests)
+	assert.Equal(t, int64(0), s.Errors)
+}
+
+func TestProxyHedge(t *testing.T) {
+	fast, ts1 := proxyTarget(t, "fast")
+	defer ts1.Close()
+	ts2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("X-Target", "slow")
+	}))
+	defer ts2.Close()
+	u, _ := url.Parse(ts2.URL)
+	slow := &ProxyTarget{Name: "slow", URL: u}
+
+	request := func(balancer ProxyBalancer, method string) *httptest.ResponseRecorder {
+		lee := leego.New()
+		lee.Use(ProxyWithConfig(ProxyConfig{
+			Balancer:   balancer,
+			HedgeDelay: 10 * time.Millisecond,
+		}))
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(method, "/users", nil)), standard.NewResponse(rec))
+		return rec
+	}
+
+	start := time.Now()
+	rec := request(NewRoundRobinBalancer(slow, fast), leego.GET)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "fast", rec.Header().Get("X-Target"))
+	assert.True(t, time.Since(start) < 100*time.Millisecond)
+	assert.Equal(t, int64(1), fast.Stats().Hedges)
+	for i := 0; i < 100 && slow.Stats().InFlight > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	s := slow.Stats()
+	assert.Equal(t, int64(1), s.Requests)
+	assert.Equal(t, int64(0), s.Errors)
+	assert.Equal(t, int64(0), s.InFlight)
+
+	// Fast responses and other methods aren't hedged.
+	assert.Equal(t, "fast", request(NewRoundRobinBalancer(fast, slow), leego.GET).Header().Get("X-Target"))
+	assert.Equal(t, "slow", request(NewRoundRobinBalancer(slow, fast), leego.POST).Header().Get("X-Target"))
+	assert.Equal(t, int64(2), slow.Stats().Requests)
+	assert.Equal(t, int64(1), fast.Stats().Hedges)
+	assert.Equal(t, int64(0), fast.Stats().InFlight)
+
+	// Sticky clients stay pinned to their target whichever wins.
+	balancer := NewCookieBalancer("lb", slow, fast)
+	rec = request(balancer, leego.GET)
+	assert.Equal(t, "fast", rec.Header().Get("X-Target"))
+	assert.Equal(t, int64(2), fast.Stats().Hedges)
+	cookies := rec.Header()[leego.HeaderSetCookie]
+	assert.Equal(t, []string{"lb=" + targetID(slow) + "; Path=/; HttpOnly", "a=1", "b=2"}, cookies)
+}
+
+func TestNewProxyTransport(t *testing.T) {
+	tr := NewProxyTransport(ProxyTransportConfig{
+		MaxIdleConnsPerHost:   32,
+		ResponseHeaderTimeout: time.Second,
+		DisableHTTP2:          true,
+	})
+	assert.Equal(t, 32, tr.MaxIdleConnsPerHost)
+	assert.Equal(t, 100, tr.MaxIdleConns)
+	assert.Equal(t, time.Second, tr.ResponseHeaderTimeout)
+	assert.False(t, tr.ForceAttemptHTTP2)
+	assert.NotNil(t, tr.TLSNextProto)
+}
@@ -0,0 +1,405 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// QueryConfig defines the config for Query middleware.
+	QueryConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Fields lists the fields which can be filtered.
+		// Required.
+		Fields []string `json:"fields"`
+
+		// SortFields lists the fields which can be sorted by.
+		// Optional. Default value `Fields`.
+		SortFields []string `json:"sort_fields"`
+
+		// FilterParam is the query param of the filter.
+		// Optional. Default value "filter".
+		FilterParam string `json:"filter_param"`
+
+		// SortParam is the query param of the sort order.
+		// Optional. Default value "sort".
+		SortParam string `json:"sort_param"`
+
+		// MaxConditions is the maximum number of conditions of a filter.
+		// Optional. Default value 20.
+		MaxConditions int `json:"max_conditions"`
+
+		// FormatLeeError formats the errors returned by the middleware, see
+		// `Middleware#FormatLeeError()`.
+		// Optional. Default value returns the error as is.
+		FormatLeeError func(err error, middlewareName string) leego.LeeError
+	}
+
+	// Query is a parsed list query, see `QueryFrom()`.
+	Query struct {
+		// Filter is the filter, nil if none.
+		Filter QueryExpr
+
+		// Sort is the sort order, empty if none.
+		Sort []QuerySort
+	}
+
+	// QueryExpr is a node of a filter: `*QueryAnd`, `*QueryOr`, `*QueryNot` or
+	// `*QueryCondition`.
+	QueryExpr interface {
+		String() string
+	}
+
+	// QueryAnd holds if both Left and Right hold.
+	QueryAnd struct {
+		Left, Right QueryExpr
+	}
+
+	// QueryOr holds if Left or Right holds.
+	QueryOr struct {
+		Left, Right QueryExpr
+	}
+
+	// QueryNot holds if Expr doesn't hold.
+	QueryNot struct {
+		Expr QueryExpr
+	}
+
+	// QueryCondition compares a field with a value. Value is always a string,
+	// to be passed as a parameter of the database query, never interpolated.
+	QueryCondition struct {
+		Field string
+		Op    QueryOp
+		Value string
+	}
+
+	// QueryOp is a comparison operator.
+	QueryOp string
+
+	// QuerySort is a field of the sort order.
+	QuerySort struct {
+		Field string
+		Desc  bool
+	}
+
+	queryToken struct {
+		kind  queryTokenKind
+		text  string
+		quote bool
+	}
+
+	queryTokenKind int
+
+	queryParser struct {
+		tokens     []queryToken
+		pos        int
+		fields     map[string]bool
+		conditions int
+		max        int
+	}
+
+	queryContextKey struct{}
+)
+
+// Query operators
+const (
+	QueryEq QueryOp = ":"
+	QueryNe QueryOp = "!="
+	QueryGt QueryOp = ">"
+	QueryGe QueryOp = ">="
+	QueryLt QueryOp = "<"
+	QueryLe QueryOp = "<="
+)
+
+const (
+	queryWord queryTokenKind = iota
+	queryOp
+	queryLParen
+	queryRParen
+	queryEOF
+)
+
+const queryMiddlewareName = "query"
+
+var (
+	// DefaultQueryConfig is the default Query middleware config.
+	DefaultQueryConfig = QueryConfig{
+		Skipper:        defaultSkipper,
+		FilterParam:    "filter",
+		SortParam:      "sort",
+		MaxConditions:  20,
+		FormatLeeError: defaultFormatLeeError,
+	}
+)
+
+// QueryWithConfig returns a middleware which parses the filter and sort order
+// of a list request, e.g.
+//
+//	?filter=age>30 AND (status:"active" OR NOT role:guest)&sort=-created_at,name
+//
+// into a `Query` available with `QueryFrom()`. Conditions are combined with
+// AND, OR and NOT, from the highest to the lowest precedence NOT, AND, OR, and
+// compare a field with an operator among `:` (equals), `!=`, `>`, `>=`, `<` and
+// `<=`. Fields not in the allowlists and malformed queries return 400.
+func QueryWithConfig(config QueryConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultQueryConfig.Skipper
+	}
+	if len(config.Fields) == 0 {
+		panic("query middleware requires fields")
+	}
+	if config.SortFields == nil {
+		config.SortFields = config.Fields
+	}
+	if config.FilterParam == "" {
+		config.FilterParam = DefaultQueryConfig.FilterParam
+	}
+	if config.SortParam == "" {
+		config.SortParam = DefaultQueryConfig.SortParam
+	}
+	if config.MaxConditions == 0 {
+		config.MaxConditions = DefaultQueryConfig.MaxConditions
+	}
+	if config.FormatLeeError == nil {
+		config.FormatLeeError = DefaultQueryConfig.FormatLeeError
+	}
+	fields := make(map[string]bool, len(config.Fields))
+	for _, f := range config.Fields {
+		fields[f] = true
+	}
+	sortFields := make(map[string]bool, len(config.SortFields))
+	for _, f := range config.SortFields {
+		sortFields[f] = true
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			q := new(Query)
+			if s := c.QueryParam(config.FilterParam); s != "" {
+				f, err := parseQueryFilter(s, fields, config.MaxConditions)
+				if err != nil {
+					return config.FormatLeeError(leego.NewHTTPError(http.StatusBadRequest, err.Error()), queryMiddlewareName)
+				}
+				q.Filter = f
+			}
+			if s := c.QueryParam(config.SortParam); s != "" {
+				sort, err := parseQuerySort(s, sortFields)
+				if err != nil {
+					return config.FormatLeeError(leego.NewHTTPError(http.StatusBadRequest, err.Error()), queryMiddlewareName)
+				}
+				q.Sort = sort
+			}
+			c.Set(queryContextKey{}, q)
+			return next(c)
+		}
+	}
+}
+
+// QueryFrom returns the query parsed by the Query middleware, or an empty query
+// if there is none.
+func QueryFrom(c leego.Context) *Query {
+	if q, ok := c.Get(queryContextKey{}).(*Query); ok {
+		return q
+	}
+	return new(Query)
+}
+
+func (e *QueryAnd) String() string {
+	return "(" + e.Left.String() + " AND " + e.Right.String() + ")"
+}
+
+func (e *QueryOr) String() string {
+	return "(" + e.Left.String() + " OR " + e.Right.String() + ")"
+}
+
+func (e *QueryNot) String() string {
+	return "NOT " + e.Expr.String()
+}
+
+func (e *QueryCondition) String() string {
+	return fmt.Sprintf("%s%s%q", e.Field, e.Op, e.Value)
+}
+
+func parseQuerySort(s string, fields map[string]bool) ([]QuerySort, error) {
+	var sort []QuerySort
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		qs := QuerySort{}
+		if strings.HasPrefix(f, "-") {
+			qs.Desc = true
+			f = f[1:]
+		} else {
+			f = strings.TrimPrefix(f, "+")
+		}
+		if !fields[f] {
+			return nil, fmt.Errorf("can't sort by %q", f)
+		}
+		qs.Field = f
+		sort = append(sort, qs)
+	}
+	return sort, nil
+}
+
+func parseQueryFilter(s string, fields map[string]bool, max int) (QueryExpr, error) {
+	tokens, err := lexQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens, fields: fields, max: max}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != queryEOF {
+		return nil, fmt.Errorf("unexpected %q in filter", t.text)
+	}
+	return e, nil
+}
+
+func lexQuery(s string) ([]queryToken, error) {
+	var tokens []queryToken
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(':
+			tokens = append(tokens, queryToken{kind: queryLParen, text: "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, queryToken{kind: queryRParen, text: ")"})
+			i++
+		case c == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+				}
+				b.WriteByte(s[j])
+			}
+			if j == len(s) {
+				return nil, fmt.Errorf("unterminated string in filter")
+			}
+			tokens = append(tokens, queryToken{kind: queryWord, text: b.String(), quote: true})
+			i = j + 1
+		case strings.IndexByte(":=!<>", c) >= 0:
+			op := s[i : i+1]
+			if (c == '!' || c == '<' || c == '>') && i+1 < len(s) && s[i+1] == '=' {
+				op = s[i : i+2]
+			}
+			i += len(op)
+			switch op {
+			case "!":
+				return nil, fmt.Errorf("invalid operator ! in filter")
+			case "=":
+				op = string(QueryEq)
+			}
+			tokens = append(tokens, queryToken{kind: queryOp, text: op})
+		default:
+			j := i
+			for ; j < len(s) && strings.IndexByte(" \t()\":=!<>", s[j]) < 0; j++ {
+			}
+			tokens = append(tokens, queryToken{kind: queryWord, text: s[i:j]})
+			i = j
+		}
+	}
+	return append(tokens, queryToken{kind: queryEOF}), nil
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() queryToken {
+	t := p.tokens[p.pos]
+	if t.kind != queryEOF {
+		p.pos++
+	}
+	return t
+}
+
+// keyword reports whether the next token is the unquoted keyword kw, and
+// consumes it if so.
+func (p *queryParser) keyword(kw string) bool {
+	if t := p.peek(); t.kind == queryWord && !t.quote && t.text == kw {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) or() (QueryExpr, error) {
+	e, err := p.and()
+	for err == nil && p.keyword("OR") {
+		var r QueryExpr
+		if r, err = p.and(); err == nil {
+			e = &QueryOr{Left: e, Right: r}
+		}
+	}
+	return e, err
+}
+
+func (p *queryParser) and() (QueryExpr, error) {
+	e, err := p.not()
+	for err == nil && p.keyword("AND") {
+		var r QueryExpr
+		if r, err = p.not(); err == nil {
+			e = &QueryAnd{Left: e, Right: r}
+		}
+	}
+	return e, err
+}
+
+func (p *queryParser) not() (QueryExpr, error) {
+	if p.keyword("NOT") {
+		e, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return &QueryNot{Expr: e}, nil
+	}
+	if p.peek().kind == queryLParen {
+		p.next()
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != queryRParen {
+			return nil, fmt.Errorf("missing ) in filter")
+		}
+		return e, nil
+	}
+	return p.condition()
+}
+
+func (p *queryParser) condition() (QueryExpr, error) {
+	f := p.next()
+	if f.kind != queryWord || f.quote {
+		return nil, fmt.Errorf("expected field in filter, got %q", f.text)
+	}
+	if !p.fields[f.text] {
+		return nil, fmt.Errorf("can't filter by %q", f.text)
+	}
+	op := p.next()
+	if op.kind != queryOp {
+		return nil, fmt.Errorf("expected operator after %q in filter", f.text)
+	}
+	v := p.next()
+	if v.kind != queryWord {
+		return nil, fmt.Errorf("expected value after %s%s in filter", f.text, op.text)
+	}
+	if p.conditions++; p.conditions > p.max {
+		return nil, fmt.Errorf("filter exceeds %d conditions", p.max)
+	}
+	return &QueryCondition{Field: f.text, Op: QueryOp(op.text), Value: v.text}, nil
+}
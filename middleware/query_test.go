@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuery(t *testing.T) {
+	lee := leego.New()
+	var q *Query
+	lee.GET("/users", func(c leego.Context) leego.LeeError {
+		q = QueryFrom(c)
+		return c.NoContent(http.StatusOK)
+	}, QueryWithConfig(QueryConfig{
+		Fields:        []string{"age", "status", "role", "created_at"},
+		SortFields:    []string{"created_at", "age"},
+		MaxConditions: 3,
+	}))
+
+	serve := func(filter, sort string) int {
+		v := url.Values{}
+		if filter != "" {
+			v.Set("filter", filter)
+		}
+		if sort != "" {
+			v.Set("sort", sort)
+		}
+		q = nil
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(leego.GET, "/users?"+v.Encode(), nil)
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		return rec.Code
+	}
+
+	if assert.Equal(t, http.StatusOK, serve(`age>30 AND status:"active"`, "-created_at,age")) {
+		assert.Equal(t, &QueryAnd{
+			Left:  &QueryCondition{Field: "age", Op: QueryGt, Value: "30"},
+			Right: &QueryCondition{Field: "status", Op: QueryEq, Value: "active"},
+		}, q.Filter)
+		assert.Equal(t, []QuerySort{{Field: "created_at", Desc: true}, {Field: "age"}}, q.Sort)
+	}
+
+	// Precedence
+	if assert.Equal(t, http.StatusOK, serve(`age>=18 OR NOT role=guest AND status!="a \"b\""`, "")) {
+		assert.Equal(t, `(age>="18" OR (NOT role:"guest" AND status!="a \"b\""))`, q.Filter.String())
+		assert.Nil(t, q.Sort)
+	}
+	if assert.Equal(t, http.StatusOK, serve(`(age<5 OR age<=6) AND role:"OR"`, "")) {
+		assert.Equal(t, `((age<"5" OR age<="6") AND role:"OR")`, q.Filter.String())
+	}
+
+	// Empty
+	if assert.Equal(t, http.StatusOK, serve("", "")) {
+		assert.Nil(t, q.Filter)
+	}
+
+	// Invalid
+	for _, f := range []string{
+		"password:x",
+		`"age":1`,
+		"age>",
+		"age 30",
+		"age!30",
+		"(age:1",
+		"age:1)",
+		`status:"active`,
+		"age:1 AND",
+		"age:1 AND age:2 AND age:3 AND age:4",
+	} {
+		assert.Equal(t, http.StatusBadRequest, serve(f, ""), f)
+		assert.Nil(t, q, f)
+	}
+	assert.Equal(t, http.StatusBadRequest, serve("", "role"))
+	assert.Equal(t, http.StatusBadRequest, serve("", "age,"))
+
+	assert.Panics(t, func() {
+		QueryWithConfig(QueryConfig{})
+	})
+}
@@ -0,0 +1,249 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// RateLimiterConfig defines the config for RateLimiter middleware.
+	RateLimiterConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Store keeps the state of the limits of every identifier.
+		// Required.
+		Store RateLimiterStore
+
+		// IdentifierExtractor returns the identifier the request is limited by.
+		// Optional. Default value returns the client IP, see `Context#RealIP()`.
+		IdentifierExtractor func(c leego.Context) (string, error)
+
+		// FormatLeeError formats the errors returned by the middleware, see
+		// `Middleware#FormatLeeError()`.
+		// Optional. Default value returns the error as is.
+		FormatLeeError func(err error, middlewareName string) leego.LeeError
+	}
+
+	// RateLimiterStore is the storage of a rate limiter, e.g. in memory with
+	// `RateLimiterMemoryStore` or in Redis to share the limits between servers.
+	RateLimiterStore interface {
+		// Allow takes a request from the limit of identifier.
+		Allow(identifier string) (RateLimit, error)
+	}
+
+	// RateLimit is the state of the limit of an identifier after a request.
+	RateLimit struct {
+		// Allowed reports whether the request is within the limit.
+		Allowed bool
+
+		// Limit is the maximum number of requests in a burst.
+		Limit int
+
+		// Remaining is the number of requests left in the current burst.
+		Remaining int
+
+		// Reset is the time until the limit is fully restored.
+		Reset time.Duration
+
+		// RetryAfter is the time until the next request is allowed, zero if
+		// `Allowed`.
+		RetryAfter time.Duration
+	}
+
+	// RateLimiterMemoryStoreConfig defines the config for `RateLimiterMemoryStore`.
+	RateLimiterMemoryStoreConfig struct {
+		// Rate is the number of requests per second restored to the limit.
+		// Required.
+		Rate float64 `json:"rate"`
+
+		// Burst is the maximum number of requests allowed at once.
+		// Optional. Default value Rate rounded up, at least 1.
+		Burst int `json:"burst"`
+
+		// ExpiresIn is the duration after which the limit of an idle identifier
+		// is forgotten, once it's fully restored.
+		// Optional. Default value 3 minutes.
+		ExpiresIn time.Duration `json:"expires_in"`
+	}
+
+	// RateLimiterMemoryStore is an in-memory token bucket `RateLimiterStore`.
+	RateLimiterMemoryStore struct {
+		config   RateLimiterMemoryStoreConfig
+		mu       sync.Mutex
+		buckets  map[string]*tokenBucket
+		lastScan time.Time
+		now      func() time.Time
+	}
+
+	tokenBucket struct {
+		tokens float64
+		last   time.Time
+	}
+)
+
+const rateLimiterMiddlewareName = "rate-limiter"
+
+var (
+	// DefaultRateLimiterConfig is the default RateLimiter middleware config.
+	DefaultRateLimiterConfig = RateLimiterConfig{
+		Skipper: defaultSkipper,
+		IdentifierExtractor: func(c leego.Context) (string, error) {
+			return c.RealIP(), nil
+		},
+		FormatLeeError: defaultFormatLeeError,
+	}
+
+	// DefaultRateLimiterMemoryStoreConfig is the default `RateLimiterMemoryStore`
+	// config.
+	DefaultRateLimiterMemoryStoreConfig = RateLimiterMemoryStoreConfig{
+		ExpiresIn: 3 * time.Minute,
+	}
+
+	// ErrRateLimitExceeded is returned when the request is over the limit.
+	ErrRateLimitExceeded = leego.NewHTTPError(http.StatusTooManyRequests, "rate limit exceeded")
+)
+
+// RateLimiter returns a middleware which limits the requests per client IP with
+// store.
+//
+// It sets the `X-RateLimit-Limit`, `X-RateLimit-Remaining` and
+// `X-RateLimit-Reset` (in seconds) response headers. A request over the limit
+// gets `Retry-After` and `ErrRateLimitExceeded` (429) is returned to the error
+// handler.
+func RateLimiter(store RateLimiterStore) leego.MiddlewareFunc {
+	c := DefaultRateLimiterConfig
+	c.Store = store
+	return RateLimiterWithConfig(c)
+}
+
+// RateLimiterWithConfig returns a RateLimiter middleware from config.
+// See `RateLimiter()`.
+func RateLimiterWithConfig(config RateLimiterConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultRateLimiterConfig.Skipper
+	}
+	if config.Store == nil {
+		panic("rate limiter middleware requires store")
+	}
+	if config.IdentifierExtractor == nil {
+		config.IdentifierExtractor = DefaultRateLimiterConfig.IdentifierExtractor
+	}
+	if config.FormatLeeError == nil {
+		config.FormatLeeError = DefaultRateLimiterConfig.FormatLeeError
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			id, err := config.IdentifierExtractor(c)
+			if err != nil {
+				return config.FormatLeeError(err, rateLimiterMiddlewareName)
+			}
+			limit, err := config.Store.Allow(id)
+			if err != nil {
+				return config.FormatLeeError(err, rateLimiterMiddlewareName)
+			}
+
+			h := c.Response().Header()
+			h.Set(leego.HeaderXRateLimitLimit, strconv.Itoa(limit.Limit))
+			h.Set(leego.HeaderXRateLimitRemaining, strconv.Itoa(limit.Remaining))
+			h.Set(leego.HeaderXRateLimitReset, seconds(limit.Reset))
+			if !limit.Allowed {
+				h.Set(leego.HeaderRetryAfter, seconds(limit.RetryAfter))
+				return config.FormatLeeError(ErrRateLimitExceeded, rateLimiterMiddlewareName)
+			}
+			return next(c)
+		}
+	}
+}
+
+// seconds formats d in whole seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// NewRateLimiterMemoryStore returns an in-memory store allowing rate requests
+// per second.
+func NewRateLimiterMemoryStore(rate float64) *RateLimiterMemoryStore {
+	c := DefaultRateLimiterMemoryStoreConfig
+	c.Rate = rate
+	return NewRateLimiterMemoryStoreWithConfig(c)
+}
+
+// NewRateLimiterMemoryStoreWithConfig returns an in-memory store from config.
+func NewRateLimiterMemoryStoreWithConfig(config RateLimiterMemoryStoreConfig) *RateLimiterMemoryStore {
+	// Defaults
+	if config.Rate <= 0 {
+		panic("rate limiter memory store requires a positive rate")
+	}
+	if config.Burst <= 0 {
+		config.Burst = int(math.Ceil(config.Rate))
+	}
+	if config.ExpiresIn == 0 {
+		config.ExpiresIn = DefaultRateLimiterMemoryStoreConfig.ExpiresIn
+	}
+	return &RateLimiterMemoryStore{
+		config:  config,
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// Allow implements `RateLimiterStore#Allow` function.
+func (s *RateLimiterMemoryStore) Allow(identifier string) (RateLimit, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastScan) >= s.config.ExpiresIn {
+		s.expire(now)
+	}
+	burst := float64(s.config.Burst)
+	b, ok := s.buckets[identifier]
+	if !ok {
+		b = &tokenBucket{tokens: burst}
+		s.buckets[identifier] = b
+	} else {
+		b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*s.config.Rate)
+	}
+	b.last = now
+
+	limit := RateLimit{Limit: s.config.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		limit.Allowed = true
+	} else {
+		limit.RetryAfter = s.duration(1 - b.tokens)
+	}
+	limit.Remaining = int(b.tokens)
+	limit.Reset = s.duration(burst - b.tokens)
+	return limit, nil
+}
+
+// expire forgets the buckets idle for longer than `ExpiresIn` and the time to
+// refill them, so that forgetting doesn't restore a limit early.
+func (s *RateLimiterMemoryStore) expire(now time.Time) {
+	burst := float64(s.config.Burst)
+	for id, b := range s.buckets {
+		idle := now.Sub(b.last)
+		if idle >= s.config.ExpiresIn && idle >= s.duration(burst-b.tokens) {
+			delete(s.buckets, id)
+		}
+	}
+	s.lastScan = now
+}
+
+// duration returns the time to restore n tokens.
+func (s *RateLimiterMemoryStore) duration(n float64) time.Duration {
+	return time.Duration(n / s.config.Rate * float64(time.Second))
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	store := NewRateLimiterMemoryStoreWithConfig(RateLimiterMemoryStoreConfig{Rate: 1, Burst: 2})
+	store.now = func() time.Time { return now }
+
+	lee := leego.New()
+	lee.Use(RateLimiter(store))
+	lee.Get("/", func(c leego.Context) leego.LeeError {
+		return c.String(http.StatusOK, "test")
+	})
+	request := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(leego.GET, "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		return rec
+	}
+
+	rec := request("10.0.0.1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get(leego.HeaderXRateLimitLimit))
+	assert.Equal(t, "1", rec.Header().Get(leego.HeaderXRateLimitRemaining))
+	assert.Equal(t, "1", rec.Header().Get(leego.HeaderXRateLimitReset))
+	rec = request("10.0.0.1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get(leego.HeaderXRateLimitRemaining))
+
+	// Over the limit
+	rec = request("10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get(leego.HeaderRetryAfter))
+	assert.Equal(t, "2", rec.Header().Get(leego.HeaderXRateLimitReset))
+
+	// Other identifier
+	assert.Equal(t, http.StatusOK, request("10.0.0.2").Code)
+
+	// Refill
+	now = now.Add(500 * time.Millisecond)
+	assert.Equal(t, http.StatusTooManyRequests, request("10.0.0.1").Code)
+	now = now.Add(500 * time.Millisecond)
+	assert.Equal(t, http.StatusOK, request("10.0.0.1").Code)
+
+	// Expiry
+	now = now.Add(DefaultRateLimiterMemoryStoreConfig.ExpiresIn)
+	request("10.0.0.3")
+	assert.Len(t, store.buckets, 1)
+
+	// No expiry before refill
+	slow := NewRateLimiterMemoryStoreWithConfig(RateLimiterMemoryStoreConfig{Rate: 0.01, Burst: 2, ExpiresIn: time.Second})
+	slow.now = store.now
+	for i := 0; i < 2; i++ {
+		slow.Allow("10.0.0.1")
+	}
+	now = now.Add(2 * time.Second)
+	slow.Allow("10.0.0.2")
+	l, _ := slow.Allow("10.0.0.1")
+	assert.False(t, l.Allowed)
+	now = now.Add(200 * time.Second)
+	slow.Allow("10.0.0.2")
+	assert.Len(t, slow.buckets, 1)
+
+	// Identifier error
+	e := errors.New("no identifier")
+	lee = leego.New()
+	c := lee.NewContext(standard.NewRequest(httptest.NewRequest(leego.GET, "/", nil)), standard.NewResponse(httptest.NewRecorder()))
+	err := RateLimiterWithConfig(RateLimiterConfig{
+		Store: store,
+		IdentifierExtractor: func(leego.Context) (string, error) {
+			return "", e
+		},
+	})(func(leego.Context) leego.LeeError {
+		return nil
+	})(c)
+	assert.Equal(t, e, err)
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// RecoverConfig defines the config for Recover middleware.
+	RecoverConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// StackSize is the size of the stack to be captured.
+		// Optional. Default value 4KB.
+		StackSize int `json:"stack_size"`
+
+		// DisableStackAll disables capturing the stack of all the other
+		// goroutines.
+		// Optional. Default value false.
+		DisableStackAll bool `json:"disable_stack_all"`
+
+		// DisablePrintStack disables logging the stack trace.
+		// Optional. Default value false.
+		DisablePrintStack bool `json:"disable_print_stack"`
+
+		// PanicHandler is called with the recovered value and the stack trace,
+		// e.g. to report the panic to an error tracker.
+		// Optional. Default value nil.
+		PanicHandler func(c leego.Context, err interface{}, stack []byte)
+	}
+)
+
+var (
+	// DefaultRecoverConfig is the default Recover middleware config.
+	DefaultRecoverConfig = RecoverConfig{
+		Skipper:   defaultSkipper,
+		StackSize: 4 << 10, // 4 KB
+	}
+)
+
+// Recover returns a middleware which recovers from panics anywhere in the chain,
+// logs the stack trace with the context logger and returns the panic as an
+// error to the HTTP error handler, which answers with 500. The error of
+// `leego.Abort()` is returned as is, without stack trace.
+func Recover() leego.MiddlewareFunc {
+	return RecoverWithConfig(DefaultRecoverConfig)
+}
+
+// RecoverWithConfig returns a Recover middleware from config.
+// See `Recover()`.
+func RecoverWithConfig(config RecoverConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultRecoverConfig.Skipper
+	}
+	if config.StackSize == 0 {
+		config.StackSize = DefaultRecoverConfig.StackSize
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) (lerr leego.LeeError) {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			defer func() {
+				r := recover()
+				if r == nil {
+					return
+				}
+				if a, ok := r.(leego.Aborted); ok {
+					lerr = a.HTTPError
+					return
+				}
+				err, ok := r.(error)
+				if !ok {
+					err = fmt.Errorf("%v", r)
+				}
+				stack := make([]byte, config.StackSize)
+				stack = stack[:runtime.Stack(stack, !config.DisableStackAll)]
+				if !config.DisablePrintStack && c.Logger() != nil {
+					c.Logger().Error("[PANIC RECOVER] %v %s", err, stack)
+				}
+				if config.PanicHandler != nil {
+					config.PanicHandler(c, r, stack)
+				}
+				lerr = err
+			}()
+			return next(c)
+		}
+	}
+}
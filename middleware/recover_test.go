@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecover(t *testing.T) {
+	var (
+		recovered interface{}
+		trace     []byte
+	)
+	lee := leego.New()
+	lee.Use(RecoverWithConfig(RecoverConfig{
+		PanicHandler: func(c leego.Context, err interface{}, stack []byte) {
+			recovered, trace = err, stack
+		},
+	}))
+	lee.Get("/", func(c leego.Context) leego.LeeError {
+		panic("test")
+	})
+	req := httptest.NewRequest(leego.GET, "/", nil)
+	rec := httptest.NewRecorder()
+	assert.NotPanics(t, func() {
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+	})
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, http.StatusText(http.StatusInternalServerError), rec.Body.String())
+	assert.Equal(t, "test", recovered)
+	assert.Contains(t, string(trace), "goroutine")
+
+	// Debug mode exposes the panic
+	lee.SetDebug(true)
+	rec = httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+	assert.Equal(t, "test", rec.Body.String())
+}
+
+func TestRecoverAbort(t *testing.T) {
+	recovered := false
+	lee := leego.New()
+	lee.Use(RecoverWithConfig(RecoverConfig{
+		PanicHandler: func(c leego.Context, err interface{}, stack []byte) {
+			recovered = true
+		},
+	}))
+	lee.Get("/", func(c leego.Context) leego.LeeError {
+		leego.Abort(http.StatusForbidden, "not your document")
+		return nil
+	})
+	rec := httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, "/", nil)), standard.NewResponse(rec))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, "not your document", rec.Body.String())
+	assert.False(t, recovered)
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// RedirectConfig defines the config for the Redirect middleware family.
+	RedirectConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Code is the status code of the redirects.
+		// Optional. Default value 301.
+		Code int `json:"code"`
+	}
+
+	// redirectFunc returns the scheme and host to redirect to, and whether
+	// the request is redirected.
+	redirectFunc func(scheme, host string) (string, string, bool)
+)
+
+var (
+	// DefaultRedirectConfig is the default Redirect middleware config.
+	DefaultRedirectConfig = RedirectConfig{
+		Skipper: defaultSkipper,
+		Code:    http.StatusMovedPermanently,
+	}
+)
+
+// HTTPSRedirect returns a root level (before router) middleware which
+// redirects http requests to https, e.g. http://example.com to
+// https://example.com. The scheme and host are the ones of the client, see
+// `Context#Forwarded()`.
+//
+// Usage `Leego#Pre(HTTPSRedirect())`
+func HTTPSRedirect() leego.MiddlewareFunc {
+	return HTTPSRedirectWithConfig(DefaultRedirectConfig)
+}
+
+// HTTPSRedirectWithConfig returns a HTTPSRedirect middleware from config.
+// See `HTTPSRedirect()`.
+func HTTPSRedirectWithConfig(config RedirectConfig) leego.MiddlewareFunc {
+	return redirect(config, func(scheme, host string) (string, string, bool) {
+		return "https", host, scheme != "https"
+	})
+}
+
+// HTTPSWWWRedirect returns a root level (before router) middleware which
+// redirects http requests to https www, e.g. http://example.com to
+// https://www.example.com.
+//
+// Usage `Leego#Pre(HTTPSWWWRedirect())`
+func HTTPSWWWRedirect() leego.MiddlewareFunc {
+	return HTTPSWWWRedirectWithConfig(DefaultRedirectConfig)
+}
+
+// HTTPSWWWRedirectWithConfig returns a HTTPSWWWRedirect middleware from
+// config. See `HTTPSWWWRedirect()`.
+func HTTPSWWWRedirectWithConfig(config RedirectConfig) leego.MiddlewareFunc {
+	return redirect(config, func(scheme, host string) (string, string, bool) {
+		www := strings.HasPrefix(host, "www.")
+		if !www {
+			host = "www." + host
+		}
+		return "https", host, scheme != "https" || !www
+	})
+}
+
+// HTTPSNonWWWRedirect returns a root level (before router) middleware which
+// redirects http requests to https non www, e.g. http://www.example.com to
+// https://example.com.
+//
+// Usage `Leego#Pre(HTTPSNonWWWRedirect())`
+func HTTPSNonWWWRedirect() leego.MiddlewareFunc {
+	return HTTPSNonWWWRedirectWithConfig(DefaultRedirectConfig)
+}
+
+// HTTPSNonWWWRedirectWithConfig returns a HTTPSNonWWWRedirect middleware from
+// config. See `HTTPSNonWWWRedirect()`.
+func HTTPSNonWWWRedirectWithConfig(config RedirectConfig) leego.MiddlewareFunc {
+	return redirect(config, func(scheme, host string) (string, string, bool) {
+		www := strings.HasPrefix(host, "www.")
+		return "https", strings.TrimPrefix(host, "www."), scheme != "https" || www
+	})
+}
+
+// WWWRedirect returns a root level (before router) middleware which redirects
+// non www requests to www, e.g. http://example.com to http://www.example.com.
+//
+// Usage `Leego#Pre(WWWRedirect())`
+func WWWRedirect() leego.MiddlewareFunc {
+	return WWWRedirectWithConfig(DefaultRedirectConfig)
+}
+
+// WWWRedirectWithConfig returns a WWWRedirect middleware from config.
+// See `WWWRedirect()`.
+func WWWRedirectWithConfig(config RedirectConfig) leego.MiddlewareFunc {
+	return redirect(config, func(scheme, host string) (string, string, bool) {
+		return scheme, "www." + host, !strings.HasPrefix(host, "www.")
+	})
+}
+
+// NonWWWRedirect returns a root level (before router) middleware which
+// redirects www requests to non www, e.g. http://www.example.com to
+// http://example.com.
+//
+// Usage `Leego#Pre(NonWWWRedirect())`
+func NonWWWRedirect() leego.MiddlewareFunc {
+	return NonWWWRedirectWithConfig(DefaultRedirectConfig)
+}
+
+// NonWWWRedirectWithConfig returns a NonWWWRedirect middleware from config.
+// See `NonWWWRedirect()`.
+func NonWWWRedirectWithConfig(config RedirectConfig) leego.MiddlewareFunc {
+	return redirect(config, func(scheme, host string) (string, string, bool) {
+		return scheme, strings.TrimPrefix(host, "www."), strings.HasPrefix(host, "www.")
+	})
+}
+
+func redirect(config RedirectConfig, to redirectFunc) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultRedirectConfig.Skipper
+	}
+	if config.Code == 0 {
+		config.Code = DefaultRedirectConfig.Code
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			f := c.Forwarded()
+			scheme, host, ok := to(f.Proto, f.Host)
+			if !ok {
+				return next(c)
+			}
+			uri := c.Request().URI()
+			// Absolute-form request targets, e.g. sent to proxies
+			if !strings.HasPrefix(uri, "/") {
+				if u, err := url.ParseRequestURI(uri); err == nil {
+					uri = u.RequestURI()
+				}
+			}
+			return c.Redirect(config.Code, scheme+"://"+host+uri)
+		}
+	}
+}
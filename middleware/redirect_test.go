@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirect(t *testing.T) {
+	lee := leego.New()
+	next := func(c leego.Context) leego.LeeError {
+		return c.NoContent(http.StatusOK)
+	}
+	for _, tt := range []struct {
+		middleware leego.MiddlewareFunc
+		url        string
+		location   string
+	}{
+		{HTTPSRedirect(), "http://example.com/a?b=1", "https://example.com/a?b=1"},
+		{HTTPSRedirect(), "https://example.com/a", ""},
+		{HTTPSWWWRedirect(), "http://example.com/a", "https://www.example.com/a"},
+		{HTTPSWWWRedirect(), "https://example.com/a", "https://www.example.com/a"},
+		{HTTPSWWWRedirect(), "https://www.example.com/a", ""},
+		{HTTPSNonWWWRedirect(), "http://www.example.com/a", "https://example.com/a"},
+		{HTTPSNonWWWRedirect(), "https://example.com/a", ""},
+		{WWWRedirect(), "http://example.com/a", "http://www.example.com/a"},
+		{WWWRedirect(), "http://www.example.com/a", ""},
+		{NonWWWRedirect(), "https://www.example.com/a", "https://example.com/a"},
+		{NonWWWRedirect(), "https://example.com/a", ""},
+	} {
+		req := httptest.NewRequest(leego.GET, tt.url, nil)
+		if req.URL.Scheme == "https" {
+			req.TLS = new(tls.ConnectionState)
+		}
+		rec := httptest.NewRecorder()
+		c := lee.NewContext(standard.NewRequest(req), standard.NewResponse(rec))
+		tt.middleware(next)(c)
+		if tt.location == "" {
+			assert.Equal(t, http.StatusOK, rec.Code, tt.url)
+		} else {
+			assert.Equal(t, http.StatusMovedPermanently, rec.Code, tt.url)
+			assert.Equal(t, tt.location, rec.Header().Get(leego.HeaderLocation), tt.url)
+		}
+	}
+}
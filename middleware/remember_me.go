@@ -0,0 +1,335 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// RememberMeConfig defines the config for RememberMe middleware.
+	RememberMeConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Store keeps the remember-me tokens.
+		// Required.
+		Store RememberMeStore
+
+		// CookieName is the name of the remember-me cookie.
+		// Optional. Default value "remember_me".
+		CookieName string `json:"cookie_name"`
+
+		// CookiePath is the path of the remember-me cookie.
+		// Optional. Default value "/".
+		CookiePath string `json:"cookie_path"`
+
+		// CookieDomain is the domain of the remember-me cookie.
+		// Optional.
+		CookieDomain string `json:"cookie_domain"`
+
+		// CookieSecure sends the remember-me cookie over HTTPS only.
+		// Optional. Default value false.
+		CookieSecure bool `json:"cookie_secure"`
+
+		// MaxAge is the time a user stays remembered without visits.
+		// Optional. Default value 30 days.
+		MaxAge time.Duration `json:"max_age"`
+
+		// OnTheft is called when a stolen token is detected, after its series
+		// is invalidated, e.g. to end the other sessions of the user or warn
+		// them.
+		// Optional.
+		OnTheft func(c leego.Context, userID string) error
+
+		// FormatLeeError formats the errors returned by the middleware, see
+		// `Middleware#FormatLeeError()`.
+		// Optional. Default value returns the error as is.
+		FormatLeeError func(err error, middlewareName string) leego.LeeError
+	}
+
+	// RememberMeToken is a persistent login. The series identifies the login
+	// of a device and the token, rotated on each use, proves the possession
+	// of its last cookie. Only the hash of the token is stored.
+	RememberMeToken struct {
+		Series    string    `json:"series"`
+		TokenHash string    `json:"token_hash"`
+		UserID    string    `json:"user_id"`
+		LastUsed  time.Time `json:"last_used"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
+	// RememberMeStore is the storage of the RememberMe middleware.
+	RememberMeStore interface {
+		// Get returns the token of series, nil if there is none or it expired.
+		Get(series string) (*RememberMeToken, error)
+
+		// Save creates or updates t.
+		Save(t *RememberMeToken) error
+
+		// Delete removes the token of series.
+		Delete(series string) error
+	}
+
+	// RememberMeMemoryStore is an in-memory `RememberMeStore`.
+	RememberMeMemoryStore struct {
+		mu       sync.Mutex
+		tokens   map[string]RememberMeToken
+		lastScan time.Time
+		now      func() time.Time
+	}
+
+	// rememberMeState is the remember-me login of a request.
+	rememberMeState struct {
+		config     *RememberMeConfig
+		series     string // Series of the request cookie
+		remembered bool   // Session logged in from the cookie
+	}
+
+	rememberMeContextKey struct{}
+)
+
+const rememberMeMiddlewareName = "remember_me"
+
+var (
+	// DefaultRememberMeConfig is the default RememberMe middleware config.
+	DefaultRememberMeConfig = RememberMeConfig{
+		Skipper:        defaultSkipper,
+		CookieName:     "remember_me",
+		CookiePath:     "/",
+		MaxAge:         30 * 24 * time.Hour,
+		FormatLeeError: defaultFormatLeeError,
+	}
+)
+
+// RememberMe returns a middleware which logs in the session the users
+// remembered with `Remember()`. It must be used after the Session middleware.
+//
+// The remember-me cookie holds a series and a token. When the session has no
+// user, a valid cookie logs its user in with `SetSessionUser()` and the token
+// is rotated. A token which doesn't match its series was used before, the
+// cookie being stolen, so the series is invalidated, logging out both the
+// thief and the user.
+func RememberMe(store RememberMeStore) leego.MiddlewareFunc {
+	c := DefaultRememberMeConfig
+	c.Store = store
+	return RememberMeWithConfig(c)
+}
+
+// RememberMeWithConfig returns a RememberMe middleware from config.
+// See `RememberMe()`.
+func RememberMeWithConfig(config RememberMeConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultRememberMeConfig.Skipper
+	}
+	if config.Store == nil {
+		panic("remember me middleware requires store")
+	}
+	if config.CookieName == "" {
+		config.CookieName = DefaultRememberMeConfig.CookieName
+	}
+	if config.CookiePath == "" {
+		config.CookiePath = DefaultRememberMeConfig.CookiePath
+	}
+	if config.MaxAge == 0 {
+		config.MaxAge = DefaultRememberMeConfig.MaxAge
+	}
+	if config.FormatLeeError == nil {
+		config.FormatLeeError = DefaultRememberMeConfig.FormatLeeError
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			session := SessionFrom(c)
+			if session == nil {
+				return config.FormatLeeError(ErrNoSession, rememberMeMiddlewareName)
+			}
+			st := &rememberMeState{config: &config}
+			c.Set(rememberMeContextKey{}, st)
+			cookie, err := c.Cookie(config.CookieName)
+			if err != nil {
+				return next(c)
+			}
+			series, token := splitRememberMeCookie(cookie.Value())
+			st.series = series
+			if session.UserID != "" || series == "" {
+				return next(c)
+			}
+
+			t, err := config.Store.Get(series)
+			if err != nil {
+				return config.FormatLeeError(err, rememberMeMiddlewareName)
+			}
+			switch {
+			case t == nil:
+				st.series = ""
+				st.setCookie(c, "", -1)
+			case subtle.ConstantTimeCompare([]byte(t.TokenHash), []byte(hashRememberMeToken(token))) != 1:
+				// Theft
+				st.series = ""
+				st.setCookie(c, "", -1)
+				if err = config.Store.Delete(series); err != nil {
+					return config.FormatLeeError(err, rememberMeMiddlewareName)
+				}
+				if config.OnTheft != nil {
+					if err = config.OnTheft(c, t.UserID); err != nil {
+						return config.FormatLeeError(err, rememberMeMiddlewareName)
+					}
+				}
+			default:
+				if err = SetSessionUser(c, t.UserID); err != nil {
+					return config.FormatLeeError(err, rememberMeMiddlewareName)
+				}
+				if err = st.issue(c, t); err != nil {
+					return config.FormatLeeError(err, rememberMeMiddlewareName)
+				}
+				st.remembered = true
+			}
+			return next(c)
+		}
+	}
+}
+
+// Remember remembers the user of the session on the device, e.g. on login
+// with a "remember me" box checked. Call it after `SetSessionUser()` and
+// before writing the response.
+func Remember(c leego.Context) error {
+	st := rememberMeFrom(c)
+	session := SessionFrom(c)
+	if st == nil || session == nil {
+		return ErrNoSession
+	}
+	if st.series != "" {
+		if err := st.config.Store.Delete(st.series); err != nil {
+			return err
+		}
+	}
+	t := &RememberMeToken{Series: generateSessionID(), UserID: session.UserID}
+	return st.issue(c, t)
+}
+
+// Forget forgets the device, e.g. on logout, removing the remember-me cookie.
+func Forget(c leego.Context) error {
+	st := rememberMeFrom(c)
+	if st == nil {
+		return ErrNoSession
+	}
+	if st.series == "" {
+		return nil
+	}
+	if err := st.config.Store.Delete(st.series); err != nil {
+		return err
+	}
+	st.series = ""
+	st.setCookie(c, "", -1)
+	return nil
+}
+
+// IsRemembered returns true if the session was logged in from the
+// remember-me cookie in the request. Applications should ask for the password
+// again before sensitive operations in this case.
+func IsRemembered(c leego.Context) bool {
+	st := rememberMeFrom(c)
+	return st != nil && st.remembered
+}
+
+func rememberMeFrom(c leego.Context) *rememberMeState {
+	st, _ := c.Get(rememberMeContextKey{}).(*rememberMeState)
+	return st
+}
+
+// issue saves t with a new token and sets the cookie.
+func (st *rememberMeState) issue(c leego.Context, t *RememberMeToken) error {
+	token := generateSessionID()
+	t.TokenHash = hashRememberMeToken(token)
+	t.LastUsed = time.Now()
+	t.ExpiresAt = t.LastUsed.Add(st.config.MaxAge)
+	if err := st.config.Store.Save(t); err != nil {
+		return err
+	}
+	st.series = t.Series
+	st.setCookie(c, t.Series+":"+token, int(st.config.MaxAge/time.Second))
+	return nil
+}
+
+func (st *rememberMeState) setCookie(c leego.Context, value string, maxAge int) {
+	cookie := &http.Cookie{
+		Name:     st.config.CookieName,
+		Value:    value,
+		Path:     st.config.CookiePath,
+		Domain:   st.config.CookieDomain,
+		MaxAge:   maxAge,
+		Secure:   st.config.CookieSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	c.Response().Header().Add(leego.HeaderSetCookie, cookie.String())
+}
+
+func splitRememberMeCookie(value string) (series, token string) {
+	i := strings.IndexByte(value, ':')
+	if i <= 0 || i == len(value)-1 {
+		return "", ""
+	}
+	return value[:i], value[i+1:]
+}
+
+func hashRememberMeToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
+
+// NewRememberMeMemoryStore returns an in-memory remember-me store.
+func NewRememberMeMemoryStore() *RememberMeMemoryStore {
+	return &RememberMeMemoryStore{
+		tokens: make(map[string]RememberMeToken),
+		now:    time.Now,
+	}
+}
+
+// Get implements `RememberMeStore#Get()`.
+func (s *RememberMeMemoryStore) Get(series string) (*RememberMeToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tokens[series]
+	if !ok || !s.now().Before(t.ExpiresAt) {
+		return nil, nil
+	}
+	return &t, nil
+}
+
+// Save implements `RememberMeStore#Save()`. Expired tokens are removed every
+// minute on the way.
+func (s *RememberMeMemoryStore) Save(t *RememberMeToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now := s.now(); now.Sub(s.lastScan) >= time.Minute {
+		for series, t := range s.tokens {
+			if !now.Before(t.ExpiresAt) {
+				delete(s.tokens, series)
+			}
+		}
+		s.lastScan = now
+	}
+	s.tokens[t.Series] = *t
+	return nil
+}
+
+// Delete implements `RememberMeStore#Delete()`.
+func (s *RememberMeMemoryStore) Delete(series string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, series)
+	return nil
+}
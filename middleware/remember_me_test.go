@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRememberMe(t *testing.T) {
+	store := NewRememberMeMemoryStore()
+	thefts := 0
+	lee := leego.New()
+	lee.Use(Session(NewSessionMemoryStore()))
+	lee.Use(RememberMeWithConfig(RememberMeConfig{
+		Store:  store,
+		MaxAge: time.Hour,
+		OnTheft: func(c leego.Context, userID string) error {
+			assert.Equal(t, "joe", userID)
+			thefts++
+			return nil
+		},
+	}))
+	lee.GET("/", func(c leego.Context) leego.LeeError {
+		return c.String(http.StatusOK, SessionFrom(c).UserID+" "+strconv.FormatBool(IsRemembered(c)))
+	})
+	lee.POST("/login", func(c leego.Context) leego.LeeError {
+		if err := SetSessionUser(c, "joe"); err != nil {
+			return err
+		}
+		if err := Remember(c); err != nil {
+			return err
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+	lee.POST("/logout", func(c leego.Context) leego.LeeError {
+		if err := Forget(c); err != nil {
+			return err
+		}
+		if err := DestroySession(c); err != nil {
+			return err
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+	request := func(method, path string, cookies ...*http.Cookie) (*httptest.ResponseRecorder, map[string]*http.Cookie) {
+		req := httptest.NewRequest(method, path, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		set := make(map[string]*http.Cookie)
+		for _, c := range (&http.Response{Header: rec.Header()}).Cookies() {
+			set[c.Name] = c
+		}
+		return rec, set
+	}
+
+	_, set := request(leego.POST, "/login")
+	remember := set["remember_me"]
+	if !assert.NotNil(t, remember) {
+		return
+	}
+	assert.True(t, remember.HttpOnly)
+	assert.Equal(t, 3600, remember.MaxAge)
+	rec, _ := request(leego.GET, "/", set["session_id"])
+	assert.Equal(t, "joe false", rec.Body.String())
+
+	// New session from the cookie, rotating the token
+	rec, set = request(leego.GET, "/", remember)
+	assert.Equal(t, "joe true", rec.Body.String())
+	rotated := set["remember_me"]
+	if !assert.NotNil(t, rotated) || !assert.NotNil(t, set["session_id"]) {
+		return
+	}
+	assert.NotEqual(t, remember.Value, rotated.Value)
+	rec, _ = request(leego.GET, "/", set["session_id"])
+	assert.Equal(t, "joe false", rec.Body.String())
+
+	// Theft: the old token invalidates the series
+	rec, set = request(leego.GET, "/", remember)
+	assert.Equal(t, " false", rec.Body.String())
+	assert.Equal(t, -1, set["remember_me"].MaxAge)
+	assert.Equal(t, 1, thefts)
+	rec, _ = request(leego.GET, "/", rotated)
+	assert.Equal(t, " false", rec.Body.String())
+
+	// Logout
+	_, set = request(leego.POST, "/login")
+	remember = set["remember_me"]
+	_, set = request(leego.POST, "/logout", set["session_id"], remember)
+	assert.Equal(t, -1, set["remember_me"].MaxAge)
+	rec, _ = request(leego.GET, "/", remember)
+	assert.Equal(t, " false", rec.Body.String())
+
+	// Expiration
+	_, set = request(leego.POST, "/login")
+	store.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	rec, _ = request(leego.GET, "/", set["remember_me"])
+	assert.Equal(t, " false", rec.Body.String())
+	assert.Equal(t, 1, thefts)
+
+	// Without the Session middleware
+	lee = leego.New()
+	lee.Use(RememberMe(store))
+	lee.GET("/", func(c leego.Context) leego.LeeError {
+		return c.NoContent(http.StatusOK)
+	})
+	rec, _ = request(leego.GET, "/")
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	assert.Panics(t, func() {
+		RememberMeWithConfig(RememberMeConfig{})
+	})
+}
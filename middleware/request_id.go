@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// RequestIDConfig defines the config for RequestID middleware.
+	RequestIDConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Generator returns a new request ID.
+		// Optional. Default value returns 32 random hex characters.
+		Generator func() string
+	}
+)
+
+// maxRequestIDLength is the maximum length of a request ID propagated from the
+// client.
+const maxRequestIDLength = 128
+
+var (
+	// DefaultRequestIDConfig is the default RequestID middleware config.
+	DefaultRequestIDConfig = RequestIDConfig{
+		Skipper:   defaultSkipper,
+		Generator: generateRequestID,
+	}
+)
+
+// RequestID returns a middleware which sets a unique ID for the request in the
+// `X-Request-ID` response header, available with `Context#RequestID()`. An ID
+// sent by the client in `X-Request-ID` is propagated.
+func RequestID() leego.MiddlewareFunc {
+	return RequestIDWithConfig(DefaultRequestIDConfig)
+}
+
+// RequestIDWithConfig returns a RequestID middleware from config.
+// See `RequestID()`.
+func RequestIDWithConfig(config RequestIDConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultRequestIDConfig.Skipper
+	}
+	if config.Generator == nil {
+		config.Generator = DefaultRequestIDConfig.Generator
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			id := c.Request().Header().Get(leego.HeaderXRequestID)
+			if id == "" || len(id) > maxRequestIDLength {
+				id = config.Generator()
+			}
+			c.Response().Header().Set(leego.HeaderXRequestID, id)
+			return next(c)
+		}
+	}
+}
+
+func generateRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("request id: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
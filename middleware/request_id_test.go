@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	lee := leego.New()
+	run := func(config RequestIDConfig, id string) (string, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(leego.GET, "/", nil)
+		if id != "" {
+			req.Header.Set(leego.HeaderXRequestID, id)
+		}
+		rec := httptest.NewRecorder()
+		c := lee.NewContext(standard.NewRequest(req), standard.NewResponse(rec))
+		var got string
+		RequestIDWithConfig(config)(func(c leego.Context) leego.LeeError {
+			got = c.RequestID()
+			return nil
+		})(c)
+		return got, rec
+	}
+
+	id, rec := run(RequestIDConfig{}, "")
+	assert.Len(t, id, 32)
+	assert.Equal(t, id, rec.Header().Get(leego.HeaderXRequestID))
+	other, _ := run(RequestIDConfig{}, "")
+	assert.NotEqual(t, id, other)
+
+	// Propagated
+	id, rec = run(RequestIDConfig{}, "abc")
+	assert.Equal(t, "abc", id)
+	assert.Equal(t, "abc", rec.Header().Get(leego.HeaderXRequestID))
+
+	// Too long
+	id, _ = run(RequestIDConfig{Generator: func() string { return "gen" }}, strings.Repeat("a", 129))
+	assert.Equal(t, "gen", id)
+}
@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// RewriteConfig defines the config for Rewrite middleware.
+	RewriteConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Rules map the old paths to the new ones. A pattern is either a path
+		// where "*" matches anything, e.g. "/old/*": "/new/$1", or a regular
+		// expression starting with "^", e.g. "^/users/(\\d+)$": "/v2/users/$1".
+		// "$n" is the nth match. The longest matching pattern wins.
+		// Required.
+		Rules map[string]string `json:"rules"`
+
+		// RedirectCode redirects the client to the new path with this status
+		// code instead of rewriting the request, e.g. 301 for a migration.
+		// Optional. Default value 0.
+		RedirectCode int `json:"redirect_code"`
+	}
+
+	// rewriteRule is a compiled rule of `RewriteConfig#Rules`.
+	rewriteRule struct {
+		pattern *regexp.Regexp
+		to      string
+	}
+)
+
+var (
+	// DefaultRewriteConfig is the default Rewrite middleware config.
+	DefaultRewriteConfig = RewriteConfig{
+		Skipper: defaultSkipper,
+	}
+)
+
+// Rewrite returns a root level (before router) middleware which rewrites the
+// request path with rules, e.g. for legacy paths after a URL migration, see
+// `RewriteConfig#Rules`. The query is kept.
+//
+// Usage `Leego#Pre(Rewrite(rules))`
+func Rewrite(rules map[string]string) leego.MiddlewareFunc {
+	c := DefaultRewriteConfig
+	c.Rules = rules
+	return RewriteWithConfig(c)
+}
+
+// RewriteWithConfig returns a Rewrite middleware from config.
+// See `Rewrite()`.
+func RewriteWithConfig(config RewriteConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultRewriteConfig.Skipper
+	}
+	if len(config.Rules) == 0 {
+		panic("rewrite middleware requires rules")
+	}
+
+	// Initialize
+	rules := compileRewriteRules(config.Rules)
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			req := c.Request()
+			path, ok := rewritePath(rules, req.URL().Path())
+			if !ok {
+				return next(c)
+			}
+			uri := path
+			if qs := req.URL().QueryString(); qs != "" {
+				uri += "?" + qs
+			}
+			if config.RedirectCode != 0 {
+				return c.Redirect(config.RedirectCode, uri)
+			}
+			req.SetURI(uri)
+			req.URL().SetPath(path)
+			return next(c)
+		}
+	}
+}
+
+func compileRewriteRules(rules map[string]string) []rewriteRule {
+	patterns := make([]string, 0, len(rules))
+	for p := range rules {
+		patterns = append(patterns, p)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+	compiled := make([]rewriteRule, len(patterns))
+	for i, p := range patterns {
+		re := p
+		if !strings.HasPrefix(p, "^") {
+			re = "^" + strings.Replace(regexp.QuoteMeta(p), `\*`, "(.*)", -1) + "$"
+		}
+		compiled[i] = rewriteRule{
+			pattern: regexp.MustCompile(re),
+			to:      rules[p],
+		}
+	}
+	return compiled
+}
+
+// rewritePath returns path rewritten by the first matching rule, and whether
+// one matched.
+func rewritePath(rules []rewriteRule, path string) (string, bool) {
+	for _, r := range rules {
+		if m := r.pattern.FindStringSubmatchIndex(path); m != nil {
+			return string(r.pattern.ExpandString(nil, r.to, path, m)), true
+		}
+	}
+	return path, false
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRewrite(t *testing.T) {
+	lee := leego.New()
+	lee.Pre(Rewrite(map[string]string{
+		"/old/*":             "/new/$1",
+		"/old/users/*":       "/users/$1",
+		`^/u/(\d+)/posts/?$`: "/users/$1/posts",
+	}))
+	lee.GET("/*", func(c leego.Context) leego.LeeError {
+		return c.String(http.StatusOK, c.Request().URL().Path()+" "+c.Request().URI())
+	})
+	request := func(target string) string {
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, target, nil)), standard.NewResponse(rec))
+		return rec.Body.String()
+	}
+
+	assert.Equal(t, "/new/a/b /new/a/b?q=1", request("/old/a/b?q=1"))
+	assert.Equal(t, "/users/1 /users/1", request("/old/users/1"))
+	assert.Equal(t, "/users/42/posts /users/42/posts", request("/u/42/posts/"))
+	assert.Equal(t, "/u/joe/posts /u/joe/posts", request("/u/joe/posts"))
+
+	// Redirect
+	h := RewriteWithConfig(RewriteConfig{
+		Rules:        map[string]string{"/old/*": "/new/$1"},
+		RedirectCode: http.StatusMovedPermanently,
+	})(func(c leego.Context) leego.LeeError {
+		return c.NoContent(http.StatusOK)
+	})
+	rec := httptest.NewRecorder()
+	c := lee.NewContext(standard.NewRequest(httptest.NewRequest(leego.GET, "/old/a?q=1", nil)), standard.NewResponse(rec))
+	h(c)
+	assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+	assert.Equal(t, "/new/a?q=1", rec.Header().Get(leego.HeaderLocation))
+}
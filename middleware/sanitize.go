@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"html"
+	"strings"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// SanitizeConfig defines the config for Sanitize middleware.
+	SanitizeConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Default is the rule for the fields without a rule in `Rules`.
+		// Optional. Default value trims whitespace.
+		Default *SanitizeRule
+
+		// Rules are the rules by field name.
+		// Optional. Default value nil.
+		Rules map[string]SanitizeRule
+	}
+
+	// SanitizeRule defines how the values of a field are sanitized, in the order
+	// of the fields.
+	SanitizeRule struct {
+		// TrimSpace removes leading and trailing whitespace.
+		TrimSpace bool `json:"trim_space"`
+
+		// Normalize normalizes the value, e.g. `norm.NFC.String` from
+		// `golang.org/x/text/unicode/norm` for Unicode NFC.
+		Normalize func(string) string
+
+		// HTML is the policy for HTML in the value.
+		HTML HTMLPolicy `json:"html"`
+	}
+
+	// HTMLPolicy defines how HTML in a value is treated.
+	HTMLPolicy int
+)
+
+// HTML policies
+const (
+	// HTMLKeep keeps the value as is.
+	HTMLKeep HTMLPolicy = iota
+
+	// HTMLEscape escapes `<`, `>`, `&`, `'` and `"`.
+	HTMLEscape
+
+	// HTMLStrip removes tags and unescapes entities.
+	HTMLStrip
+)
+
+var (
+	// DefaultSanitizeConfig is the default Sanitize middleware config.
+	DefaultSanitizeConfig = SanitizeConfig{
+		Skipper: defaultSkipper,
+		Default: &SanitizeRule{TrimSpace: true},
+	}
+)
+
+// Sanitize returns a middleware which sanitizes the query and form params
+// before they are bound or validated. By default whitespace is trimmed from
+// every value. Request bodies of other content types, e.g. JSON, are left
+// untouched.
+func Sanitize() leego.MiddlewareFunc {
+	return SanitizeWithConfig(DefaultSanitizeConfig)
+}
+
+// SanitizeWithConfig returns a Sanitize middleware from config.
+// See `Sanitize()`.
+func SanitizeWithConfig(config SanitizeConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultSanitizeConfig.Skipper
+	}
+	if config.Default == nil {
+		config.Default = DefaultSanitizeConfig.Default
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			req := c.Request()
+			config.sanitize(req.URL().QueryParams())
+			ctype := req.Header().Get(leego.HeaderContentType)
+			if strings.HasPrefix(ctype, leego.MIMEApplicationForm) || strings.HasPrefix(ctype, leego.MIMEMultipartForm) {
+				config.sanitize(req.FormParams())
+			}
+			return next(c)
+		}
+	}
+}
+
+// sanitize sanitizes params in place.
+func (config *SanitizeConfig) sanitize(params map[string][]string) {
+	for name, values := range params {
+		rule, ok := config.Rules[name]
+		if !ok {
+			rule = *config.Default
+		}
+		for i, v := range values {
+			values[i] = rule.apply(v)
+		}
+	}
+}
+
+func (r SanitizeRule) apply(v string) string {
+	if r.TrimSpace {
+		v = strings.TrimSpace(v)
+	}
+	if r.Normalize != nil {
+		v = r.Normalize(v)
+	}
+	switch r.HTML {
+	case HTMLEscape:
+		v = html.EscapeString(v)
+	case HTMLStrip:
+		v = stripTags(v)
+	}
+	return v
+}
+
+// stripTags removes the HTML tags from s and unescapes its entities.
+func stripTags(s string) string {
+	if strings.IndexByte(s, '<') < 0 {
+		return html.UnescapeString(s)
+	}
+	b := make([]byte, 0, len(s))
+	in := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '<':
+			in = true
+		case c == '>' && in:
+			in = false
+		case !in:
+			b = append(b, c)
+		}
+	}
+	return html.UnescapeString(string(b))
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitize(t *testing.T) {
+	form := url.Values{
+		"name":    {"  Jon  "},
+		"bio":     {" <b>Hi</b> &amp; bye "},
+		"comment": {"<script>x</script>"},
+		"raw":     {" as is "},
+	}
+	req := httptest.NewRequest(leego.POST, "/?q=+query+&Q=%C3%A9", strings.NewReader(form.Encode()))
+	req.Header.Set(leego.HeaderContentType, leego.MIMEApplicationForm)
+	lee := leego.New()
+	c := lee.NewContext(standard.NewRequest(req), standard.NewResponse(httptest.NewRecorder()))
+	h := SanitizeWithConfig(SanitizeConfig{
+		Rules: map[string]SanitizeRule{
+			"bio":     {TrimSpace: true, HTML: HTMLStrip},
+			"comment": {HTML: HTMLEscape},
+			"raw":     {},
+			"Q":       {Normalize: strings.ToUpper},
+		},
+	})(func(c leego.Context) leego.LeeError {
+		return nil
+	})
+	assert.Nil(t, h(c))
+	assert.Equal(t, "query", c.QueryParam("q"))
+	assert.Equal(t, "É", c.QueryParam("Q"))
+	assert.Equal(t, "Jon", c.FormValue("name"))
+	assert.Equal(t, "Hi & bye", c.FormValue("bio"))
+	assert.Equal(t, "&lt;script&gt;x&lt;/script&gt;", c.FormValue("comment"))
+	assert.Equal(t, " as is ", c.FormValue("raw"))
+}
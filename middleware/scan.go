@@ -0,0 +1,252 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// ScanConfig defines the config for Scan middleware.
+	ScanConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Scanner scans the uploaded files.
+		// Required.
+		Scanner Scanner
+
+		// FailurePolicy defines what happens when a file can't be scanned.
+		// Optional. Default value `ScanFailClosed`.
+		FailurePolicy ScanFailurePolicy `json:"failure_policy"`
+
+		// AsyncSize is the file size in bytes from which files are scanned in
+		// the background, without delaying the handler. Infected files are passed
+		// to `Quarantine` then. 0 scans all files before the handler.
+		// Optional. Default value 0.
+		AsyncSize int64 `json:"async_size"`
+
+		// Quarantine is called with a file scanned in the background which is
+		// infected or, with `ScanFailClosed`, couldn't be scanned.
+		// Required with `AsyncSize`.
+		Quarantine func(field string, fh *multipart.FileHeader, result ScanResult, err error)
+
+		// FormatLeeError formats the errors returned by the middleware, see
+		// `Middleware#FormatLeeError()`.
+		// Optional. Default value returns the error as is.
+		FormatLeeError func(err error, middlewareName string) leego.LeeError
+	}
+
+	// Scanner scans a file for malware, e.g. with `ClamdScanner`.
+	Scanner interface {
+		// Scan scans the content of the file named name.
+		Scan(name string, r io.Reader) (ScanResult, error)
+	}
+
+	// ScanResult is the result of a scan.
+	ScanResult struct {
+		// Infected reports whether malware was found.
+		Infected bool
+
+		// Threat is the name of the malware found.
+		Threat string
+	}
+
+	// ScanFailurePolicy defines what happens when a file can't be scanned.
+	ScanFailurePolicy int
+
+	// ClamdScanner is a `Scanner` using the INSTREAM command of a clamd daemon.
+	ClamdScanner struct {
+		// Network is "tcp" or "unix".
+		Network string
+
+		// Address is the address of clamd, e.g. "localhost:3310".
+		Address string
+
+		// Timeout is the timeout of a scan, 0 for none.
+		Timeout time.Duration
+	}
+)
+
+// Scan failure policies
+const (
+	// ScanFailClosed rejects the request with 503.
+	ScanFailClosed ScanFailurePolicy = iota
+
+	// ScanFailOpen lets the file through.
+	ScanFailOpen
+)
+
+const (
+	scanMiddlewareName = "scan"
+	clamdChunkSize     = 32 << 10
+)
+
+var (
+	// DefaultScanConfig is the default Scan middleware config.
+	DefaultScanConfig = ScanConfig{
+		Skipper:        defaultSkipper,
+		FailurePolicy:  ScanFailClosed,
+		FormatLeeError: defaultFormatLeeError,
+	}
+
+	// ErrFileInfected is returned when an uploaded file is infected.
+	ErrFileInfected = leego.NewHTTPError(http.StatusUnprocessableEntity, "file infected")
+
+	// ErrScanFailed is returned when an uploaded file can't be scanned with
+	// `ScanFailClosed`.
+	ErrScanFailed = leego.NewHTTPError(http.StatusServiceUnavailable, "file scan failed")
+)
+
+// Scan returns a middleware which scans the files of a multipart request with
+// scanner before the handler runs. An infected file returns `ErrFileInfected`
+// (422).
+func Scan(scanner Scanner) leego.MiddlewareFunc {
+	c := DefaultScanConfig
+	c.Scanner = scanner
+	return ScanWithConfig(c)
+}
+
+// ScanWithConfig returns a Scan middleware from config.
+// See `Scan()`.
+func ScanWithConfig(config ScanConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultScanConfig.Skipper
+	}
+	if config.Scanner == nil {
+		panic("scan middleware requires scanner")
+	}
+	if config.AsyncSize > 0 && config.Quarantine == nil {
+		panic("scan middleware requires quarantine for async scans")
+	}
+	if config.FormatLeeError == nil {
+		config.FormatLeeError = DefaultScanConfig.FormatLeeError
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			req := c.Request()
+			if !strings.HasPrefix(req.Header().Get(leego.HeaderContentType), leego.MIMEMultipartForm) {
+				return next(c)
+			}
+			form, err := req.MultipartForm()
+			if err != nil {
+				return config.FormatLeeError(leego.NewHTTPError(http.StatusBadRequest, err.Error()), scanMiddlewareName)
+			}
+			for field, files := range form.File {
+				for _, fh := range files {
+					if err := config.scan(field, fh); err != nil {
+						return config.FormatLeeError(err, scanMiddlewareName)
+					}
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+// scan scans the file, in the background if it's large.
+func (config *ScanConfig) scan(field string, fh *multipart.FileHeader) error {
+	f, err := fh.Open()
+	if err != nil {
+		return config.failed()
+	}
+	if config.AsyncSize > 0 && fh.Size >= config.AsyncSize {
+		// The open file outlives the removal of the form's temporary files.
+		go func() {
+			defer f.Close()
+			result, err := config.Scanner.Scan(fh.Filename, f)
+			if result.Infected || (err != nil && config.FailurePolicy == ScanFailClosed) {
+				config.Quarantine(field, fh, result, err)
+			}
+		}()
+		return nil
+	}
+	defer f.Close()
+	result, err := config.Scanner.Scan(fh.Filename, f)
+	if err != nil {
+		return config.failed()
+	}
+	if result.Infected {
+		return ErrFileInfected
+	}
+	return nil
+}
+
+// failed returns the error for a file which can't be scanned.
+func (config *ScanConfig) failed() error {
+	if config.FailurePolicy == ScanFailOpen {
+		return nil
+	}
+	return ErrScanFailed
+}
+
+// Scan implements `Scanner#Scan` function.
+func (s *ClamdScanner) Scan(name string, r io.Reader) (result ScanResult, err error) {
+	conn, err := net.DialTimeout(s.Network, s.Address, s.Timeout)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if s.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(s.Timeout))
+	}
+
+	if _, err = conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return
+	}
+	buf := make([]byte, 4+clamdChunkSize)
+	for {
+		n, rerr := r.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			if _, err = conn.Write(buf[:4+n]); err != nil {
+				return
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return result, rerr
+		}
+	}
+	if _, err = conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return
+	}
+
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil && err != io.EOF {
+		return
+	}
+	err = nil
+	// E.g. "stream: OK" or "stream: Eicar-Test-Signature FOUND"
+	reply = bytes.TrimRight(reply, "\x00\n")
+	switch {
+	case bytes.HasSuffix(reply, []byte(" FOUND")):
+		result.Infected = true
+		threat := bytes.TrimSuffix(reply, []byte(" FOUND"))
+		if i := bytes.LastIndex(threat, []byte(": ")); i >= 0 {
+			threat = threat[i+2:]
+		}
+		result.Threat = string(threat)
+	case bytes.HasSuffix(reply, []byte(": OK")):
+	default:
+		err = errors.New("clamd: " + string(reply))
+	}
+	return result, err
+}
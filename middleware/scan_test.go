@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+type scannerFunc func(name string, r io.Reader) (ScanResult, error)
+
+func (f scannerFunc) Scan(name string, r io.Reader) (ScanResult, error) {
+	return f(name, r)
+}
+
+func TestScan(t *testing.T) {
+	scanner := scannerFunc(func(name string, r io.Reader) (ScanResult, error) {
+		b, _ := ioutil.ReadAll(r)
+		switch string(b) {
+		case "virus":
+			return ScanResult{Infected: true, Threat: "Test"}, nil
+		case "error":
+			return ScanResult{}, errors.New("scanner down")
+		}
+		return ScanResult{}, nil
+	})
+	run := func(config ScanConfig, content string) (leego.LeeError, bool) {
+		body := new(bytes.Buffer)
+		w := multipart.NewWriter(body)
+		part, _ := w.CreateFormFile("file", "a.txt")
+		part.Write([]byte(content))
+		w.Close()
+		req := httptest.NewRequest(leego.POST, "/", body)
+		req.Header.Set(leego.HeaderContentType, w.FormDataContentType())
+		c := leego.New().NewContext(standard.NewRequest(req), standard.NewResponse(httptest.NewRecorder()))
+		called := false
+		config.Scanner = scanner
+		err := ScanWithConfig(config)(func(c leego.Context) leego.LeeError {
+			called = true
+			return nil
+		})(c)
+		return err, called
+	}
+
+	err, called := run(ScanConfig{}, "clean")
+	assert.Nil(t, err)
+	assert.True(t, called)
+	err, called = run(ScanConfig{}, "virus")
+	assert.Equal(t, ErrFileInfected, err)
+	assert.False(t, called)
+	err, _ = run(ScanConfig{}, "error")
+	assert.Equal(t, ErrScanFailed, err)
+	err, _ = run(ScanConfig{FailurePolicy: ScanFailOpen}, "error")
+	assert.Nil(t, err)
+
+	// Async
+	quarantined := make(chan ScanResult, 1)
+	err, called = run(ScanConfig{
+		AsyncSize: 1,
+		Quarantine: func(field string, fh *multipart.FileHeader, result ScanResult, err error) {
+			quarantined <- result
+		},
+	}, "virus")
+	assert.Nil(t, err)
+	assert.True(t, called)
+	assert.Equal(t, "Test", (<-quarantined).Threat)
+}
+
+func TestClamdScanner(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			cmd, _ := r.ReadString(0)
+			var data []byte
+			for cmd == "zINSTREAM\x00" {
+				var n uint32
+				if binary.Read(r, binary.BigEndian, &n) != nil || n == 0 {
+					break
+				}
+				chunk := make([]byte, n)
+				io.ReadFull(r, chunk)
+				data = append(data, chunk...)
+			}
+			if strings.Contains(string(data), "EICAR") {
+				conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+			} else {
+				conn.Write([]byte("stream: OK\x00"))
+			}
+			conn.Close()
+		}
+	}()
+
+	s := &ClamdScanner{Network: "tcp", Address: ln.Addr().String()}
+	result, err := s.Scan("a.txt", strings.NewReader("clean"))
+	assert.NoError(t, err)
+	assert.False(t, result.Infected)
+	result, err = s.Scan("b.txt", strings.NewReader(strings.Repeat("x", clamdChunkSize)+"EICAR"))
+	assert.NoError(t, err)
+	assert.True(t, result.Infected)
+	assert.Equal(t, "Eicar-Test-Signature", result.Threat)
+}
@@ -0,0 +1,544 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine"
+)
+
+type (
+	// SessionConfig defines the config for Session middleware.
+	SessionConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Store keeps the sessions.
+		// Required.
+		Store SessionStore
+
+		// CookieName is the name of the session cookie.
+		// Optional. Default value "session_id".
+		CookieName string `json:"cookie_name"`
+
+		// CookiePath is the path of the session cookie.
+		// Optional. Default value "/".
+		CookiePath string `json:"cookie_path"`
+
+		// CookieDomain is the domain of the session cookie.
+		// Optional.
+		CookieDomain string `json:"cookie_domain"`
+
+		// CookieSecure sends the session cookie over HTTPS only.
+		// Optional. Default value false.
+		CookieSecure bool `json:"cookie_secure"`
+
+		// IdleTimeout is the time after which a session expires without
+		// requests.
+		// Optional. Default value 30 minutes.
+		IdleTimeout time.Duration `json:"idle_timeout"`
+
+		// AbsoluteTimeout is the time after which a session expires regardless
+		// of its activity.
+		// Optional. Default value 24 hours.
+		AbsoluteTimeout time.Duration `json:"absolute_timeout"`
+
+		// MaxSessionsPerUser is the maximum number of concurrent sessions of a
+		// user, the least recently used ones are ended on login beyond it, 0
+		// for no limit.
+		// Optional. Default value 0.
+		MaxSessionsPerUser int `json:"max_sessions_per_user"`
+
+		// FormatLeeError formats the errors returned by the middleware, see
+		// `Middleware#FormatLeeError()`.
+		// Optional. Default value returns the error as is.
+		FormatLeeError func(err error, middlewareName string) leego.LeeError
+	}
+
+	// SessionData is a server-side session, see `SessionFrom()`.
+	SessionData struct {
+		ID     string            `json:"id"`
+		UserID string            `json:"user_id,omitempty"`
+		Values map[string]string `json:"values,omitempty"`
+
+		// Flashes are the messages for the next request, see `AddFlash()`.
+		Flashes []SessionFlash `json:"flashes,omitempty"`
+
+		CreatedAt  time.Time `json:"created_at"`
+		LastSeenAt time.Time `json:"last_seen_at"`
+
+		// ExpiresAt is the earliest of the idle and absolute timeouts.
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
+	// SessionFlash is a message shown once, e.g. "Profile saved" after a
+	// redirect.
+	SessionFlash struct {
+		// Kind is the kind of message, e.g. "success" or "error".
+		Kind    string `json:"kind"`
+		Message string `json:"message"`
+	}
+
+	// SessionStore is the storage of the Session middleware, e.g. in memory
+	// with `SessionMemoryStore`, in files with `SessionFileStore` or in Redis
+	// with `SessionRedisStore` to share the sessions between servers. Stores
+	// index the sessions by user.
+	SessionStore interface {
+		// Get returns the session id, nil if there is none or it expired.
+		Get(id string) (*SessionData, error)
+
+		// Save creates or updates s.
+		Save(s *SessionData) error
+
+		// Delete removes the session id.
+		Delete(id string) error
+
+		// UserSessions returns the sessions of the user userID which didn't
+		// expire.
+		UserSessions(userID string) ([]*SessionData, error)
+	}
+
+	// SessionMemoryStore is an in-memory `SessionStore`.
+	SessionMemoryStore struct {
+		mu       sync.Mutex
+		sessions map[string]SessionData
+		users    map[string]map[string]bool
+		lastScan time.Time
+		now      func() time.Time
+	}
+
+	// sessionState is the session of a request.
+	sessionState struct {
+		config    *SessionConfig
+		res       engine.Response
+		session   *SessionData
+		cookieID  string // ID of the request cookie
+		stored    bool   // Session is in the store
+		destroyed bool
+		committed bool
+		err       error
+	}
+
+	sessionResponse struct {
+		engine.Response
+		state *sessionState
+	}
+
+	sessionContextKey struct{}
+)
+
+const sessionMiddlewareName = "session"
+
+var (
+	// DefaultSessionConfig is the default Session middleware config.
+	DefaultSessionConfig = SessionConfig{
+		Skipper:         defaultSkipper,
+		CookieName:      "session_id",
+		CookiePath:      "/",
+		IdleTimeout:     30 * time.Minute,
+		AbsoluteTimeout: 24 * time.Hour,
+		FormatLeeError:  defaultFormatLeeError,
+	}
+
+	// ErrNoSession is returned by the session functions outside of the Session
+	// middleware.
+	ErrNoSession = errors.New("session middleware not used")
+)
+
+// Session returns a middleware which loads the session of the request from
+// store, available with `SessionFrom()`. Sessions are stored once they have
+// values or a user, and the session cookie is set before the response is
+// written, so sessions must be changed before writing it.
+//
+// Call `SetSessionUser()` on login, which changes the session ID against
+// session fixation, `RegenerateSession()` on any other privilege change,
+// `DestroySession()` on logout and `LogoutOtherSessions()` to log out the other
+// devices of the user.
+func Session(store SessionStore) leego.MiddlewareFunc {
+	c := DefaultSessionConfig
+	c.Store = store
+	return SessionWithConfig(c)
+}
+
+// SessionWithConfig returns a Session middleware from config.
+// See `Session()`.
+func SessionWithConfig(config SessionConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultSessionConfig.Skipper
+	}
+	if config.Store == nil {
+		panic("session middleware requires store")
+	}
+	if config.CookieName == "" {
+		config.CookieName = DefaultSessionConfig.CookieName
+	}
+	if config.CookiePath == "" {
+		config.CookiePath = DefaultSessionConfig.CookiePath
+	}
+	if config.IdleTimeout == 0 {
+		config.IdleTimeout = DefaultSessionConfig.IdleTimeout
+	}
+	if config.AbsoluteTimeout == 0 {
+		config.AbsoluteTimeout = DefaultSessionConfig.AbsoluteTimeout
+	}
+	if config.FormatLeeError == nil {
+		config.FormatLeeError = DefaultSessionConfig.FormatLeeError
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			res := c.Response()
+			st := &sessionState{config: &config, res: res}
+			if cookie, err := c.Cookie(config.CookieName); err == nil {
+				st.cookieID = cookie.Value()
+				st.session, err = config.Store.Get(st.cookieID)
+				if err != nil {
+					return config.FormatLeeError(err, sessionMiddlewareName)
+				}
+			}
+			now := time.Now()
+			if st.session != nil && now.Before(st.session.ExpiresAt) {
+				st.stored = true
+			} else {
+				if st.session != nil {
+					if err := config.Store.Delete(st.session.ID); err != nil {
+						return config.FormatLeeError(err, sessionMiddlewareName)
+					}
+				}
+				st.session = &SessionData{ID: generateSessionID(), CreatedAt: now}
+			}
+			if st.session.Values == nil {
+				st.session.Values = make(map[string]string)
+			}
+
+			c.Set(sessionContextKey{}, st)
+			c.SetResponse(&sessionResponse{Response: res, state: st})
+			err := next(c)
+			c.SetResponse(res)
+			st.commit()
+			if err == nil && st.err != nil {
+				return config.FormatLeeError(st.err, sessionMiddlewareName)
+			}
+			return err
+		}
+	}
+}
+
+// SessionFrom returns the session of the request, nil outside of the Session
+// middleware. Its values must be set before writing the response.
+func SessionFrom(c leego.Context) *SessionData {
+	if st := sessionFrom(c); st != nil {
+		return st.session
+	}
+	return nil
+}
+
+// SessionValue returns the value key of the session, "" if there is none.
+func SessionValue(c leego.Context, key string) string {
+	if s := SessionFrom(c); s != nil {
+		return s.Values[key]
+	}
+	return ""
+}
+
+// SetSessionValue sets the value key of the session.
+func SetSessionValue(c leego.Context, key, value string) error {
+	s := SessionFrom(c)
+	if s == nil {
+		return ErrNoSession
+	}
+	s.Values[key] = value
+	return nil
+}
+
+// DeleteSessionValue removes the value key of the session.
+func DeleteSessionValue(c leego.Context, key string) error {
+	s := SessionFrom(c)
+	if s == nil {
+		return ErrNoSession
+	}
+	delete(s.Values, key)
+	return nil
+}
+
+// AddFlash adds a message of kind to the session, to show it on the next
+// request with `Flashes()`, e.g. after a redirect.
+func AddFlash(c leego.Context, kind, message string) error {
+	s := SessionFrom(c)
+	if s == nil {
+		return ErrNoSession
+	}
+	s.Flashes = append(s.Flashes, SessionFlash{Kind: kind, Message: message})
+	return nil
+}
+
+// Flashes returns the messages added with `AddFlash()` and removes them from
+// the session.
+func Flashes(c leego.Context) []SessionFlash {
+	s := SessionFrom(c)
+	if s == nil {
+		return nil
+	}
+	f := s.Flashes
+	s.Flashes = nil
+	return f
+}
+
+// RegenerateSession changes the ID of the session, keeping its values. Call it
+// on privilege changes, so a session ID planted before isn't granted them.
+func RegenerateSession(c leego.Context) error {
+	st := sessionFrom(c)
+	if st == nil {
+		return ErrNoSession
+	}
+	if st.stored {
+		if err := st.config.Store.Delete(st.session.ID); err != nil {
+			return err
+		}
+		st.stored = false
+	}
+	st.session.ID = generateSessionID()
+	return nil
+}
+
+// SetSessionUser logs the user userID in the session, changing its ID. Beyond
+// `SessionConfig#MaxSessionsPerUser` the least recently used sessions of the
+// user are ended.
+func SetSessionUser(c leego.Context, userID string) error {
+	if err := RegenerateSession(c); err != nil {
+		return err
+	}
+	st := sessionFrom(c)
+	st.session.UserID = userID
+	max := st.config.MaxSessionsPerUser
+	if max <= 0 {
+		return nil
+	}
+	sessions, err := st.config.Store.UserSessions(userID)
+	if err != nil {
+		return err
+	}
+	// Least recently used first
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.Before(sessions[j].LastSeenAt)
+	})
+	for i := 0; i < len(sessions)-max+1; i++ {
+		if err = st.config.Store.Delete(sessions[i].ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LogoutOtherSessions ends the other sessions of the user of the session, e.g.
+// "log out other devices", and returns their number.
+func LogoutOtherSessions(c leego.Context) (int, error) {
+	st := sessionFrom(c)
+	if st == nil {
+		return 0, ErrNoSession
+	}
+	if st.session.UserID == "" {
+		return 0, nil
+	}
+	sessions, err := st.config.Store.UserSessions(st.session.UserID)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, s := range sessions {
+		if s.ID == st.session.ID {
+			continue
+		}
+		if err = st.config.Store.Delete(s.ID); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// DestroySession ends the session and removes the session cookie, e.g. on
+// logout.
+func DestroySession(c leego.Context) error {
+	st := sessionFrom(c)
+	if st == nil {
+		return ErrNoSession
+	}
+	if st.stored {
+		if err := st.config.Store.Delete(st.session.ID); err != nil {
+			return err
+		}
+		st.stored = false
+	}
+	st.destroyed = true
+	return nil
+}
+
+func sessionFrom(c leego.Context) *sessionState {
+	st, _ := c.Get(sessionContextKey{}).(*sessionState)
+	return st
+}
+
+func generateSessionID() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic("session id: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// commit saves the session and sets the cookie, once.
+func (st *sessionState) commit() {
+	if st.committed {
+		return
+	}
+	st.committed = true
+	s := st.session
+	if st.destroyed {
+		if st.cookieID != "" {
+			st.setCookie("", -1)
+		}
+		return
+	}
+	if !st.stored && s.UserID == "" && len(s.Values) == 0 && len(s.Flashes) == 0 {
+		return
+	}
+
+	s.LastSeenAt = time.Now()
+	s.ExpiresAt = s.LastSeenAt.Add(st.config.IdleTimeout)
+	if abs := s.CreatedAt.Add(st.config.AbsoluteTimeout); abs.Before(s.ExpiresAt) {
+		s.ExpiresAt = abs
+	}
+	if st.err = st.config.Store.Save(s); st.err != nil {
+		return
+	}
+	st.stored = true
+	if s.ID != st.cookieID {
+		st.setCookie(s.ID, 0)
+	}
+}
+
+func (st *sessionState) setCookie(value string, maxAge int) {
+	cookie := &http.Cookie{
+		Name:     st.config.CookieName,
+		Value:    value,
+		Path:     st.config.CookiePath,
+		Domain:   st.config.CookieDomain,
+		MaxAge:   maxAge,
+		Secure:   st.config.CookieSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	st.res.Header().Add(leego.HeaderSetCookie, cookie.String())
+}
+
+func (r *sessionResponse) WriteHeader(code int) {
+	r.state.commit()
+	r.Response.WriteHeader(code)
+}
+
+func (r *sessionResponse) Write(b []byte) (int, error) {
+	r.state.commit()
+	return r.Response.Write(b)
+}
+
+// NewSessionMemoryStore returns an in-memory session store.
+func NewSessionMemoryStore() *SessionMemoryStore {
+	return &SessionMemoryStore{
+		sessions: make(map[string]SessionData),
+		users:    make(map[string]map[string]bool),
+		now:      time.Now,
+	}
+}
+
+// Get implements `SessionStore#Get()`.
+func (s *SessionMemoryStore) Get(id string) (*SessionData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ss, ok := s.sessions[id]
+	if !ok || !s.now().Before(ss.ExpiresAt) {
+		return nil, nil
+	}
+	return ss.copy(), nil
+}
+
+// Save implements `SessionStore#Save()`. Expired sessions are removed every
+// minute on the way.
+func (s *SessionMemoryStore) Save(ss *SessionData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now := s.now(); now.Sub(s.lastScan) >= time.Minute {
+		for id, ss := range s.sessions {
+			if !now.Before(ss.ExpiresAt) {
+				s.delete(id)
+			}
+		}
+		s.lastScan = now
+	}
+	s.delete(ss.ID)
+	s.sessions[ss.ID] = *ss.copy()
+	if ss.UserID != "" {
+		if s.users[ss.UserID] == nil {
+			s.users[ss.UserID] = make(map[string]bool)
+		}
+		s.users[ss.UserID][ss.ID] = true
+	}
+	return nil
+}
+
+// Delete implements `SessionStore#Delete()`.
+func (s *SessionMemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delete(id)
+	return nil
+}
+
+// UserSessions implements `SessionStore#UserSessions()`.
+func (s *SessionMemoryStore) UserSessions(userID string) ([]*SessionData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	var sessions []*SessionData
+	for id := range s.users[userID] {
+		if ss := s.sessions[id]; now.Before(ss.ExpiresAt) {
+			sessions = append(sessions, ss.copy())
+		}
+	}
+	return sessions, nil
+}
+
+func (s *SessionMemoryStore) delete(id string) {
+	ss, ok := s.sessions[id]
+	if !ok {
+		return
+	}
+	delete(s.sessions, id)
+	if u := s.users[ss.UserID]; u != nil {
+		delete(u, id)
+		if len(u) == 0 {
+			delete(s.users, ss.UserID)
+		}
+	}
+}
+
+func (s *SessionData) copy() *SessionData {
+	c := *s
+	c.Values = make(map[string]string, len(s.Values))
+	for k, v := range s.Values {
+		c.Values[k] = v
+	}
+	c.Flashes = append([]SessionFlash(nil), s.Flashes...)
+	return &c
+}
@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// SessionFileStore is a `SessionStore` keeping a JSON file per session in
+	// a directory, e.g. for a single server without Redis. Listing the
+	// sessions of a user reads all the files.
+	SessionFileStore struct {
+		dir      string
+		mu       sync.Mutex
+		lastScan time.Time
+		now      func() time.Time
+	}
+)
+
+const sessionFileExt = ".session"
+
+// NewSessionFileStore returns a store keeping the sessions in dir, created if
+// it doesn't exist.
+func NewSessionFileStore(dir string) (*SessionFileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &SessionFileStore{dir: dir, now: time.Now}, nil
+}
+
+// Get implements `SessionStore#Get()`.
+func (s *SessionFileStore) Get(id string) (*SessionData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(s.path(id))
+}
+
+// Save implements `SessionStore#Save()`. Expired sessions are removed every
+// minute on the way.
+func (s *SessionFileStore) Save(ss *SessionData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now := s.now(); now.Sub(s.lastScan) >= time.Minute {
+		if _, err := s.scan(""); err != nil {
+			return err
+		}
+		s.lastScan = now
+	}
+	b, err := json.Marshal(ss)
+	if err != nil {
+		return err
+	}
+	// Written to a temporary file first, so a session is never read partly
+	// written.
+	f, err := ioutil.TempFile(s.dir, "tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(b); err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path(ss.ID))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Delete implements `SessionStore#Delete()`.
+func (s *SessionFileStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.path(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// UserSessions implements `SessionStore#UserSessions()`.
+func (s *SessionFileStore) UserSessions(userID string) ([]*SessionData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scan(userID)
+}
+
+// path returns the file of the session id, named after its hash so that
+// session IDs sent by clients can't name other files.
+func (s *SessionFileStore) path(id string) string {
+	h := sha256.Sum256([]byte(id))
+	return filepath.Join(s.dir, hex.EncodeToString(h[:])+sessionFileExt)
+}
+
+// read returns the session in the file name, nil if there is none or it
+// expired.
+func (s *SessionFileStore) read(name string) (*SessionData, error) {
+	b, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ss := new(SessionData)
+	if err = json.Unmarshal(b, ss); err != nil {
+		return nil, err
+	}
+	if !s.now().Before(ss.ExpiresAt) {
+		return nil, nil
+	}
+	return ss, nil
+}
+
+// scan removes the expired sessions and returns the ones of the user userID.
+func (s *SessionFileStore) scan(userID string) ([]*SessionData, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var sessions []*SessionData
+	for _, fi := range files {
+		if !strings.HasSuffix(fi.Name(), sessionFileExt) {
+			continue
+		}
+		name := filepath.Join(s.dir, fi.Name())
+		ss, err := s.read(name)
+		if err != nil {
+			// Unreadable files are left to the operator.
+			continue
+		}
+		if ss == nil {
+			os.Remove(name)
+		} else if userID != "" && ss.UserID == userID {
+			sessions = append(sessions, ss)
+		}
+	}
+	return sessions, nil
+}
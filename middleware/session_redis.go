@@ -0,0 +1,233 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type (
+	// SessionRedisStoreConfig defines the config for `SessionRedisStore`.
+	SessionRedisStoreConfig struct {
+		// Addr is the address of the Redis server, e.g. "localhost:6379".
+		// Required.
+		Addr string `json:"addr"`
+
+		// Password authenticates with the server if not empty.
+		// Optional.
+		Password string `json:"-"`
+
+		// Prefix is the prefix of the keys of the store.
+		// Optional. Default value "leego:session:".
+		Prefix string `json:"prefix"`
+
+		// DialTimeout is the timeout of connecting to the server.
+		// Optional. Default value 5 seconds.
+		DialTimeout time.Duration `json:"dial_timeout"`
+
+		// Timeout is the timeout of a command, so that a server which hangs
+		// fails the requests using sessions instead of blocking them.
+		// Optional. Default value 3 seconds.
+		Timeout time.Duration `json:"timeout"`
+	}
+
+	// SessionRedisStore is a `SessionStore` in Redis, sharing the sessions
+	// between servers. Sessions expire with Redis key expiration.
+	SessionRedisStore struct {
+		config SessionRedisStoreConfig
+		mu     sync.Mutex
+		conn   *redisConn
+		now    func() time.Time
+	}
+)
+
+var (
+	// DefaultSessionRedisStoreConfig is the default `SessionRedisStore`
+	// config.
+	DefaultSessionRedisStoreConfig = SessionRedisStoreConfig{
+		Prefix:      "leego:session:",
+		DialTimeout: redisDialTimeout,
+		Timeout:     redisTimeout,
+	}
+
+	errRedisUnexpectedReply = errors.New("redis: unexpected reply")
+)
+
+// NewSessionRedisStore returns a store in the Redis server at addr.
+func NewSessionRedisStore(addr string) *SessionRedisStore {
+	c := DefaultSessionRedisStoreConfig
+	c.Addr = addr
+	return NewSessionRedisStoreWithConfig(c)
+}
+
+// NewSessionRedisStoreWithConfig returns a Redis session store from config. It
+// connects on first use.
+func NewSessionRedisStoreWithConfig(config SessionRedisStoreConfig) *SessionRedisStore {
+	// Defaults
+	if config.Addr == "" {
+		panic("session redis store requires addr")
+	}
+	if config.Prefix == "" {
+		config.Prefix = DefaultSessionRedisStoreConfig.Prefix
+	}
+	if config.DialTimeout == 0 {
+		config.DialTimeout = DefaultSessionRedisStoreConfig.DialTimeout
+	}
+	if config.Timeout == 0 {
+		config.Timeout = DefaultSessionRedisStoreConfig.Timeout
+	}
+	return &SessionRedisStore{config: config, now: time.Now}
+}
+
+// Get implements `SessionStore#Get()`.
+func (s *SessionRedisStore) Get(id string) (*SessionData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.get(id)
+}
+
+// Save implements `SessionStore#Save()`.
+func (s *SessionRedisStore) Save(ss *SessionData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ttl := ss.ExpiresAt.Sub(s.now())
+	if ttl <= 0 {
+		return s.delete(ss.ID)
+	}
+	b, err := json.Marshal(ss)
+	if err != nil {
+		return err
+	}
+	px := int64(ttl/time.Millisecond) + 1
+	ms := strconv.FormatInt(px, 10)
+	if _, err = s.do("SET", s.key(ss.ID), string(b), "PX", ms); err != nil {
+		return err
+	}
+	if ss.UserID == "" {
+		return nil
+	}
+	// The index of the user expires with the last of its sessions.
+	key := s.userKey(ss.UserID)
+	if _, err = s.do("SADD", key, ss.ID); err != nil {
+		return err
+	}
+	v, err := s.do("PTTL", key)
+	if err != nil {
+		return err
+	}
+	if pttl, _ := v.(int64); pttl < px {
+		_, err = s.do("PEXPIRE", key, ms)
+	}
+	return err
+}
+
+// Delete implements `SessionStore#Delete()`.
+func (s *SessionRedisStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.delete(id)
+}
+
+// UserSessions implements `SessionStore#UserSessions()`. The IDs of the
+// expired sessions are removed from the index of the user on the way.
+func (s *SessionRedisStore) UserSessions(userID string) ([]*SessionData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, err := s.do("SMEMBERS", s.userKey(userID))
+	if err != nil {
+		return nil, err
+	}
+	ids, ok := v.([]interface{})
+	if !ok {
+		return nil, errRedisUnexpectedReply
+	}
+	var sessions []*SessionData
+	for _, id := range ids {
+		id, _ := id.(string)
+		ss, err := s.get(id)
+		if err != nil {
+			return nil, err
+		}
+		if ss == nil || ss.UserID != userID {
+			if _, err = s.do("SREM", s.userKey(userID), id); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		sessions = append(sessions, ss)
+	}
+	return sessions, nil
+}
+
+// Close closes the connection of the store.
+func (s *SessionRedisStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+func (s *SessionRedisStore) key(id string) string {
+	return s.config.Prefix + "id:" + id
+}
+
+func (s *SessionRedisStore) userKey(userID string) string {
+	return s.config.Prefix + "user:" + userID
+}
+
+func (s *SessionRedisStore) get(id string) (*SessionData, error) {
+	v, err := s.do("GET", s.key(id))
+	if err != nil || v == nil {
+		return nil, err
+	}
+	b, ok := v.(string)
+	if !ok {
+		return nil, errRedisUnexpectedReply
+	}
+	ss := new(SessionData)
+	if err = json.Unmarshal([]byte(b), ss); err != nil {
+		return nil, err
+	}
+	if !s.now().Before(ss.ExpiresAt) {
+		return nil, nil
+	}
+	return ss, nil
+}
+
+func (s *SessionRedisStore) delete(id string) error {
+	ss, err := s.get(id)
+	if err != nil {
+		return err
+	}
+	if _, err = s.do("DEL", s.key(id)); err != nil {
+		return err
+	}
+	if ss != nil && ss.UserID != "" {
+		_, err = s.do("SREM", s.userKey(ss.UserID), id)
+	}
+	return err
+}
+
+// do sends a command, connecting first if needed. The connection is closed on
+// errors and reopened by the next command.
+func (s *SessionRedisStore) do(args ...string) (interface{}, error) {
+	if s.conn == nil {
+		c, err := dialRedis(s.config.Addr, s.config.Password, s.config.DialTimeout, s.config.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		s.conn = c
+	}
+	v, err := s.conn.do(args...)
+	if err != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	return v, err
+}
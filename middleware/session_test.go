@@ -0,0 +1,224 @@
+package middleware
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession(t *testing.T) {
+	store := NewSessionMemoryStore()
+	lee := leego.New()
+	lee.Use(SessionWithConfig(SessionConfig{
+		Store:              store,
+		IdleTimeout:        time.Minute,
+		MaxSessionsPerUser: 2,
+	}))
+	lee.GET("/", func(c leego.Context) leego.LeeError {
+		s := SessionFrom(c)
+		return c.String(http.StatusOK, s.UserID+" "+s.Values["theme"])
+	})
+	lee.POST("/theme", func(c leego.Context) leego.LeeError {
+		SessionFrom(c).Values["theme"] = "dark"
+		return c.NoContent(http.StatusNoContent)
+	})
+	lee.POST("/login", func(c leego.Context) leego.LeeError {
+		if err := SetSessionUser(c, "joe"); err != nil {
+			return err
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+	lee.POST("/logout-others", func(c leego.Context) leego.LeeError {
+		n, err := LogoutOtherSessions(c)
+		if err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, strconv.Itoa(n))
+	})
+	lee.POST("/logout", func(c leego.Context) leego.LeeError {
+		if err := DestroySession(c); err != nil {
+			return err
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+	request := func(method, path string, cookie *http.Cookie) (*httptest.ResponseRecorder, *http.Cookie) {
+		req := httptest.NewRequest(method, path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		for _, c := range (&http.Response{Header: rec.Header()}).Cookies() {
+			return rec, c
+		}
+		return rec, cookie
+	}
+
+	// Not stored until used
+	rec, cookie := request(leego.GET, "/", nil)
+	assert.Nil(t, cookie)
+	assert.Equal(t, " ", rec.Body.String())
+
+	_, cookie = request(leego.POST, "/theme", nil)
+	if !assert.NotNil(t, cookie) {
+		return
+	}
+	assert.True(t, cookie.HttpOnly)
+	rec, same := request(leego.GET, "/", cookie)
+	assert.Equal(t, " dark", rec.Body.String())
+	assert.Equal(t, cookie, same)
+
+	// Fixation: the ID changes on login
+	_, login := request(leego.POST, "/login", cookie)
+	assert.NotEqual(t, cookie.Value, login.Value)
+	rec, _ = request(leego.GET, "/", login)
+	assert.Equal(t, "joe dark", rec.Body.String())
+	rec, _ = request(leego.GET, "/", cookie)
+	assert.Equal(t, " ", rec.Body.String())
+
+	// Concurrent sessions
+	_, login2 := request(leego.POST, "/login", nil)
+	_, login3 := request(leego.POST, "/login", nil)
+	sessions, _ := store.UserSessions("joe")
+	assert.Len(t, sessions, 2)
+	rec, _ = request(leego.GET, "/", login)
+	assert.Equal(t, " ", rec.Body.String())
+	rec, _ = request(leego.POST, "/logout-others", login3)
+	assert.Equal(t, "1", rec.Body.String())
+	rec, _ = request(leego.GET, "/", login2)
+	assert.Equal(t, " ", rec.Body.String())
+
+	// Logout
+	rec, expired := request(leego.POST, "/logout", login3)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, -1, expired.MaxAge)
+	rec, _ = request(leego.GET, "/", login3)
+	assert.Equal(t, " ", rec.Body.String())
+
+	// Idle timeout
+	_, login = request(leego.POST, "/login", nil)
+	store.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	rec, _ = request(leego.GET, "/", login)
+	assert.Equal(t, " ", rec.Body.String())
+
+	assert.Panics(t, func() {
+		SessionWithConfig(SessionConfig{})
+	})
+}
+
+func TestSessionFlash(t *testing.T) {
+	lee := leego.New()
+	lee.Use(Session(NewSessionMemoryStore()))
+	lee.POST("/profile", func(c leego.Context) leego.LeeError {
+		SetSessionValue(c, "name", "Joe")
+		AddFlash(c, "success", "Profile saved")
+		return c.Redirect(http.StatusSeeOther, "/")
+	})
+	lee.GET("/", func(c leego.Context) leego.LeeError {
+		s := SessionValue(c, "name")
+		for _, f := range Flashes(c) {
+			s += " " + f.Kind + ":" + f.Message
+		}
+		return c.String(http.StatusOK, s)
+	})
+	request := func(method string, cookie *http.Cookie) (*httptest.ResponseRecorder, *http.Cookie) {
+		req := httptest.NewRequest(method, "/profile", nil)
+		if method == leego.GET {
+			req = httptest.NewRequest(method, "/", nil)
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		for _, c := range (&http.Response{Header: rec.Header()}).Cookies() {
+			return rec, c
+		}
+		return rec, cookie
+	}
+
+	_, cookie := request(leego.POST, nil)
+	if !assert.NotNil(t, cookie) {
+		return
+	}
+	rec, _ := request(leego.GET, cookie)
+	assert.Equal(t, "Joe success:Profile saved", rec.Body.String())
+	rec, _ = request(leego.GET, cookie)
+	assert.Equal(t, "Joe", rec.Body.String())
+
+	c := lee.NewContext(standard.NewRequest(httptest.NewRequest(leego.GET, "/", nil)), standard.NewResponse(httptest.NewRecorder()))
+	assert.Equal(t, ErrNoSession, AddFlash(c, "error", "x"))
+	assert.Equal(t, ErrNoSession, SetSessionValue(c, "a", "b"))
+	assert.Nil(t, Flashes(c))
+}
+
+func TestSessionStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sessions")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	fileStore, err := NewSessionFileStore(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	srv := newRedisServer(t)
+	defer srv.Close()
+	redisStore := NewSessionRedisStore(srv.Addr().String())
+	defer redisStore.Close()
+
+	for name, store := range map[string]SessionStore{
+		"memory": NewSessionMemoryStore(),
+		"file":   fileStore,
+		"redis":  redisStore,
+	} {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now()
+			a := &SessionData{ID: "a", UserID: "joe", Values: map[string]string{"k": "v"}, ExpiresAt: now.Add(time.Hour)}
+			b := &SessionData{ID: "b", UserID: "joe", Flashes: []SessionFlash{{"info", "hi"}}, ExpiresAt: now.Add(time.Hour)}
+			assert.NoError(t, store.Save(a))
+			assert.NoError(t, store.Save(b))
+			assert.NoError(t, store.Save(&SessionData{ID: "c", UserID: "joe", ExpiresAt: now.Add(-time.Second)}))
+
+			s, err := store.Get("a")
+			if assert.NoError(t, err) && assert.NotNil(t, s) {
+				assert.Equal(t, "v", s.Values["k"])
+			}
+			s, _ = store.Get("b")
+			if assert.NotNil(t, s) {
+				assert.Equal(t, []SessionFlash{{"info", "hi"}}, s.Flashes)
+			}
+			s, _ = store.Get("c")
+			assert.Nil(t, s)
+			s, _ = store.Get("../a")
+			assert.Nil(t, s)
+
+			sessions, err := store.UserSessions("joe")
+			assert.NoError(t, err)
+			assert.Len(t, sessions, 2)
+			assert.NoError(t, store.Delete("a"))
+			s, _ = store.Get("a")
+			assert.Nil(t, s)
+			sessions, _ = store.UserSessions("joe")
+			assert.Len(t, sessions, 1)
+		})
+	}
+
+	// The index of the user expires with the last of its sessions.
+	srv.mu.Lock()
+	ttl := srv.ttls["leego:session:user:joe"]
+	srv.mu.Unlock()
+	assert.True(t, ttl > int64(59*time.Minute/time.Millisecond), "ttl: %d", ttl)
+	assert.NoError(t, redisStore.Save(&SessionData{ID: "d", UserID: "joe", ExpiresAt: time.Now().Add(time.Minute)}))
+	srv.mu.Lock()
+	assert.Equal(t, ttl, srv.ttls["leego:session:user:joe"])
+	srv.mu.Unlock()
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// SignedURLConfig defines the config for SignedURL middleware.
+	SignedURLConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// AllowMethods are the methods allowed with signed URLs.
+		// Optional. Default value []string{GET, HEAD}.
+		AllowMethods []string `json:"allow_methods"`
+
+		// FormatLeeError formats the errors returned by the middleware, see
+		// `Middleware#FormatLeeError()`.
+		// Optional. Default value returns the error as is.
+		FormatLeeError func(err error, middlewareName string) leego.LeeError
+	}
+)
+
+const signedURLMiddlewareName = "signed-url"
+
+var (
+	// DefaultSignedURLConfig is the default SignedURL middleware config.
+	DefaultSignedURLConfig = SignedURLConfig{
+		Skipper:        defaultSkipper,
+		AllowMethods:   []string{leego.GET, leego.HEAD},
+		FormatLeeError: defaultFormatLeeError,
+	}
+)
+
+// SignedURL returns a middleware which only lets through requests to URLs
+// signed by `Leego#SignURL()` that haven't expired. It returns
+// `leego.ErrInvalidSignature` or `leego.ErrURLExpired` otherwise.
+func SignedURL() leego.MiddlewareFunc {
+	return SignedURLWithConfig(DefaultSignedURLConfig)
+}
+
+// SignedURLWithConfig returns a SignedURL middleware from config.
+// See `SignedURL()`.
+func SignedURLWithConfig(config SignedURLConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultSignedURLConfig.Skipper
+	}
+	if len(config.AllowMethods) == 0 {
+		config.AllowMethods = DefaultSignedURLConfig.AllowMethods
+	}
+	if config.FormatLeeError == nil {
+		config.FormatLeeError = DefaultSignedURLConfig.FormatLeeError
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			allowed := false
+			for _, m := range config.AllowMethods {
+				if m == c.Request().Method() {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				return config.FormatLeeError(leego.ErrMethodNotAllowed, signedURLMiddlewareName)
+			}
+			if err := c.Leego().VerifyURL(c.Request().URI()); err != nil {
+				return config.FormatLeeError(err, signedURLMiddlewareName)
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignedURL(t *testing.T) {
+	lee := leego.New()
+	lee.SetURLSigningKey([]byte("secret"))
+	lee.GET("/invoices/:id", func(c leego.Context) leego.LeeError {
+		return c.String(http.StatusOK, c.Param("id"))
+	}, SignedURL()).Name = "invoice"
+	request := func(method, uri string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, uri, nil)
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		return rec
+	}
+
+	uri := lee.SignURL("invoice", []interface{}{42}, time.Hour)
+	assert.True(t, strings.HasPrefix(uri, "/invoices/42?expires="))
+	rec := request(leego.GET, uri)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "42", rec.Body.String())
+	assert.Equal(t, "", lee.SignURL("other", nil, time.Hour))
+
+	// Tampered
+	assert.Equal(t, http.StatusForbidden, request(leego.GET, "/invoices/42").Code)
+	assert.Equal(t, http.StatusForbidden, request(leego.GET, strings.Replace(uri, "/42", "/43", 1)).Code)
+	assert.Equal(t, http.StatusForbidden, request(leego.GET, uri+"&download=1").Code)
+	assert.Equal(t, http.StatusOK, request(leego.GET, lee.SignURI("/invoices/42?download=1", time.Hour)).Code)
+
+	// Expired
+	assert.Equal(t, leego.ErrURLExpired, lee.VerifyURL(lee.SignURI("/invoices/42", -time.Second)))
+
+	// Method
+	lee.POST("/invoices/:id", func(c leego.Context) leego.LeeError {
+		return c.NoContent(http.StatusNoContent)
+	}, SignedURL())
+	assert.Equal(t, http.StatusMethodNotAllowed, request(leego.POST, uri).Code)
+}
@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// SlowStartConfig defines the config for SlowStart middleware.
+	SlowStartConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Ramp is the time over which the accepted traffic grows to all of it.
+		// Optional. Default value 1 minute.
+		Ramp time.Duration `json:"ramp"`
+
+		// MinFraction is the fraction of the traffic accepted at the start of
+		// the ramp.
+		// Optional. Default value 0.1.
+		MinFraction float64 `json:"min_fraction"`
+
+		// MaxConcurrency ramps the number of concurrent requests up to it
+		// instead of the fraction of the requests, 0 to ramp the fraction.
+		// There is no limit once the ramp is over.
+		// Optional. Default value 0.
+		MaxConcurrency int `json:"max_concurrency"`
+
+		// RetryAfter is sent in `Retry-After` with the shed requests.
+		// Optional. Default value 1 second.
+		RetryAfter time.Duration `json:"retry_after"`
+
+		// FormatLeeError formats the errors returned by the middleware, see
+		// `Middleware#FormatLeeError()`.
+		// Optional. Default value returns the error as is.
+		FormatLeeError func(err error, middlewareName string) leego.LeeError
+	}
+
+	// slowStart is the state of the ramp.
+	slowStart struct {
+		config   *SlowStartConfig
+		mu       sync.Mutex
+		start    time.Time
+		credit   float64 // Requests to accept, spreading the fraction evenly
+		inFlight int
+		over     int32 // Set once the ramp is over
+		now      func() time.Time
+	}
+)
+
+const slowStartMiddlewareName = "slow-start"
+
+var (
+	// DefaultSlowStartConfig is the default SlowStart middleware config.
+	DefaultSlowStartConfig = SlowStartConfig{
+		Skipper:        defaultSkipper,
+		Ramp:           time.Minute,
+		MinFraction:    0.1,
+		RetryAfter:     time.Second,
+		FormatLeeError: defaultFormatLeeError,
+	}
+
+	// ErrWarmingUp is returned for the requests shed during the ramp.
+	ErrWarmingUp = leego.NewHTTPError(http.StatusServiceUnavailable, "warming up")
+)
+
+// SlowStart returns a middleware which, right after a deploy, accepts a
+// growing fraction of the traffic over a ramp starting with the first request,
+// so cold caches don't cause a latency spike. Shed requests get `Retry-After`
+// and `ErrWarmingUp` (503) is returned to the error handler.
+func SlowStart(ramp time.Duration) leego.MiddlewareFunc {
+	c := DefaultSlowStartConfig
+	c.Ramp = ramp
+	return SlowStartWithConfig(c)
+}
+
+// SlowStartWithConfig returns a SlowStart middleware from config.
+// See `SlowStart()`.
+func SlowStartWithConfig(config SlowStartConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultSlowStartConfig.Skipper
+	}
+	if config.Ramp <= 0 {
+		config.Ramp = DefaultSlowStartConfig.Ramp
+	}
+	if config.MinFraction <= 0 || config.MinFraction > 1 {
+		config.MinFraction = DefaultSlowStartConfig.MinFraction
+	}
+	if config.RetryAfter <= 0 {
+		config.RetryAfter = DefaultSlowStartConfig.RetryAfter
+	}
+	if config.FormatLeeError == nil {
+		config.FormatLeeError = DefaultSlowStartConfig.FormatLeeError
+	}
+
+	return newSlowStart(&config, time.Now).middleware
+}
+
+func newSlowStart(config *SlowStartConfig, now func() time.Time) *slowStart {
+	return &slowStart{config: config, now: now}
+}
+
+func (s *slowStart) middleware(next leego.HandlerFunc) leego.HandlerFunc {
+	return func(c leego.Context) leego.LeeError {
+		if s.config.Skipper(c) || atomic.LoadInt32(&s.over) == 1 {
+			return next(c)
+		}
+
+		accepted, counted := s.accept()
+		if !accepted {
+			c.Response().Header().Set(leego.HeaderRetryAfter, seconds(s.config.RetryAfter))
+			return s.config.FormatLeeError(ErrWarmingUp, slowStartMiddlewareName)
+		}
+		if counted {
+			defer s.done()
+		}
+		return next(c)
+	}
+}
+
+// accept reports whether a request is accepted, and whether it's counted in
+// the concurrent requests.
+func (s *slowStart) accept() (accepted, counted bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if s.start.IsZero() {
+		s.start = now
+	}
+	elapsed := now.Sub(s.start)
+	if elapsed >= s.config.Ramp {
+		atomic.StoreInt32(&s.over, 1)
+		return true, false
+	}
+	min := s.config.MinFraction
+	fraction := min + (1-min)*float64(elapsed)/float64(s.config.Ramp)
+
+	if max := s.config.MaxConcurrency; max > 0 {
+		if s.inFlight >= int(math.Ceil(float64(max)*fraction)) {
+			return false, false
+		}
+		s.inFlight++
+		return true, true
+	}
+	s.credit += fraction
+	if s.credit < 1 {
+		return false, false
+	}
+	s.credit--
+	return true, false
+}
+
+func (s *slowStart) done() {
+	s.mu.Lock()
+	s.inFlight--
+	s.mu.Unlock()
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlowStart(t *testing.T) {
+	now := time.Now()
+	config := DefaultSlowStartConfig
+	config.MinFraction = 0.25
+	s := newSlowStart(&config, func() time.Time { return now })
+	lee := leego.New()
+	accepted := 0
+	var rec *httptest.ResponseRecorder
+	request := func() {
+		rec = httptest.NewRecorder()
+		c := lee.NewContext(standard.NewRequest(httptest.NewRequest(leego.GET, "/", nil)), standard.NewResponse(rec))
+		err := s.middleware(func(c leego.Context) leego.LeeError {
+			accepted++
+			return nil
+		})(c)
+		if err != nil {
+			assert.Equal(t, ErrWarmingUp, err)
+			assert.Equal(t, "1", rec.Header().Get(leego.HeaderRetryAfter))
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		request()
+	}
+	assert.Equal(t, 25, accepted)
+
+	// Half way
+	now = now.Add(30 * time.Second)
+	accepted = 0
+	for i := 0; i < 100; i++ {
+		request()
+	}
+	assert.Equal(t, 62, accepted)
+
+	// Over
+	now = now.Add(30 * time.Second)
+	accepted = 0
+	for i := 0; i < 100; i++ {
+		request()
+	}
+	assert.Equal(t, 100, accepted)
+}
+
+func TestSlowStartConcurrency(t *testing.T) {
+	now := time.Now()
+	config := DefaultSlowStartConfig
+	config.MaxConcurrency = 10
+	config.MinFraction = 0.2
+	s := newSlowStart(&config, func() time.Time { return now })
+	lee := leego.New()
+	codes := []int{}
+	var h leego.HandlerFunc
+	h = s.middleware(func(c leego.Context) leego.LeeError {
+		// Nested requests are concurrent.
+		if len(codes) < 3 {
+			rec := httptest.NewRecorder()
+			nc := lee.NewContext(standard.NewRequest(httptest.NewRequest(leego.GET, "/", nil)), standard.NewResponse(rec))
+			if err := h(nc); err != nil {
+				codes = append(codes, err.(*leego.HTTPError).Code)
+			} else {
+				codes = append(codes, http.StatusOK)
+			}
+		}
+		return nil
+	})
+	c := lee.NewContext(standard.NewRequest(httptest.NewRequest(leego.GET, "/", nil)), standard.NewResponse(httptest.NewRecorder()))
+	assert.Nil(t, h(c))
+	// 2 concurrent requests at first
+	assert.Equal(t, []int{http.StatusServiceUnavailable, http.StatusOK}, codes)
+	assert.Equal(t, 0, s.inFlight)
+}
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"bytes"
+	"html"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// StaticConfig defines the config for Static middleware.
+	StaticConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Root directory from where the static content is served.
+		// Required.
+		Root string `json:"root"`
+
+		// Index file for serving a directory.
+		// Optional. Default value "index.html".
+		Index string `json:"index"`
+
+		// Enable HTML5 mode by forwarding all not-found requests to root so that
+		// SPA (single-page application) can handle the routing.
+		// Optional. Default value false.
+		HTML5 bool `json:"html5"`
+
+		// Enable directory browsing.
+		// Optional. Default value false.
+		Browse bool `json:"browse"`
+	}
+)
+
+var (
+	// DefaultStaticConfig is the default Static middleware config.
+	DefaultStaticConfig = StaticConfig{
+		Skipper: defaultSkipper,
+		Index:   "index.html",
+	}
+)
+
+// Static returns a Static middleware to serve static content from the provided
+// root directory. Requests which don't match a file are passed to the next
+// handler.
+func Static(root string) leego.MiddlewareFunc {
+	c := DefaultStaticConfig
+	c.Root = root
+	return StaticWithConfig(c)
+}
+
+// StaticWithConfig returns a Static middleware from config.
+// See `Static()`.
+func StaticWithConfig(config StaticConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultStaticConfig.Skipper
+	}
+	if config.Index == "" {
+		config.Index = DefaultStaticConfig.Index
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			p := c.Request().URL().Path()
+			if strings.HasSuffix(c.Path(), "*") {
+				// When serving from a group, e.g. `/static*`.
+				p = c.P(len(c.ParamNames()) - 1)
+			}
+			// Clean as an absolute path, so the name can't escape root.
+			p = path.Clean("/" + p)
+			name := filepath.Join(config.Root, filepath.FromSlash(p))
+
+			fi, err := os.Stat(name)
+			if err != nil {
+				if !os.IsNotExist(err) {
+					return err
+				}
+				if err := next(c); err != nil {
+					if he, ok := err.(*leego.HTTPError); ok && config.HTML5 && he.Code == http.StatusNotFound {
+						return c.File(filepath.Join(config.Root, config.Index))
+					}
+					return err
+				}
+				return nil
+			}
+
+			if fi.IsDir() {
+				index := filepath.Join(name, config.Index)
+				if _, err := os.Stat(index); err != nil {
+					if config.Browse {
+						return listDir(p, name, c)
+					}
+					return next(c)
+				}
+				return c.File(index)
+			}
+			return c.File(name)
+		}
+	}
+}
+
+// listDir sends an HTML listing of the directory.
+func listDir(p, name string, c leego.Context) leego.LeeError {
+	d, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	files, err := d.Readdir(-1)
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name() < files[j].Name()
+	})
+
+	base := c.Request().URL().Path()
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteString("<!DOCTYPE html>\n<html>\n<head><title>")
+	buf.WriteString(html.EscapeString(p))
+	buf.WriteString("</title></head>\n<body>\n<h1>")
+	buf.WriteString(html.EscapeString(p))
+	buf.WriteString("</h1>\n<ul>\n")
+	for _, f := range files {
+		n := f.Name()
+		if f.IsDir() {
+			n += "/"
+		}
+		u := url.URL{Path: base + n}
+		buf.WriteString(`<li><a href="`)
+		buf.WriteString(html.EscapeString(u.EscapedPath()))
+		buf.WriteString(`">`)
+		buf.WriteString(html.EscapeString(n))
+		buf.WriteString("</a></li>\n")
+	}
+	buf.WriteString("</ul>\n</body>\n</html>\n")
+	return c.HTML(http.StatusOK, buf.String())
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatic(t *testing.T) {
+	root, err := ioutil.TempDir("", "static")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	os.MkdirAll(filepath.Join(root, "docs"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "index.html"), []byte("index"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "app.js"), []byte("app"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "docs", "a b.txt"), []byte("a"), 0644)
+
+	serve := func(config StaticConfig, path string) *httptest.ResponseRecorder {
+		lee := leego.New()
+		lee.Use(StaticWithConfig(config))
+		lee.GET("/api/users", func(c leego.Context) leego.LeeError {
+			return c.String(http.StatusOK, "users")
+		})
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, path, nil)), standard.NewResponse(rec))
+		return rec
+	}
+	config := StaticConfig{Root: root}
+
+	rec := serve(config, "/app.js")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "app", rec.Body.String())
+
+	rec = serve(config, "/")
+	assert.Equal(t, "index", rec.Body.String())
+
+	rec = serve(config, "/api/users")
+	assert.Equal(t, "users", rec.Body.String())
+
+	rec = serve(config, "/../static_test.go")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = serve(config, "/docs")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Browse
+	config.Browse = true
+	rec = serve(config, "/docs")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `<a href="/docs/a%20b.txt">a b.txt</a>`)
+
+	// HTML5
+	config.HTML5 = true
+	rec = serve(config, "/users/1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "index", rec.Body.String())
+}
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine"
+)
+
+type (
+	// TimeoutConfig defines the config for Timeout middleware.
+	TimeoutConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Timeout is the time allowed to the handler.
+		// Required.
+		Timeout time.Duration `json:"timeout"`
+
+		// FormatLeeError formats the errors returned by the middleware, see
+		// `Middleware#FormatLeeError()`.
+		// Optional. Default value returns the error as is.
+		FormatLeeError func(err error, middlewareName string) leego.LeeError
+	}
+
+	timeoutResponse struct {
+		engine.Response
+		ctx       context.Context
+		mu        sync.Mutex
+		committed bool // Header was written before the timeout
+	}
+)
+
+const timeoutMiddlewareName = "timeout"
+
+var (
+	// DefaultTimeoutConfig is the default Timeout middleware config.
+	DefaultTimeoutConfig = TimeoutConfig{
+		Skipper:        defaultSkipper,
+		FormatLeeError: defaultFormatLeeError,
+	}
+
+	// ErrTimeout is returned when the handler times out.
+	ErrTimeout = leego.NewHTTPError(http.StatusServiceUnavailable, "request timeout")
+)
+
+// Timeout returns a middleware which cancels the request context after d, see
+// `Context#Deadline()` and `Context#Done()`.
+//
+// The deadline is cooperative only: nothing is sent when it passes. The handler
+// runs on the request goroutine, as the context is reused after the request,
+// and a handler which blocks holds the response until it returns. Then, if it
+// didn't respond before the deadline, its writes are discarded and
+// `ErrTimeout` (503) is returned to the error handler. Handlers must return
+// once `Context#Done()` is closed, e.g. by passing `Context#Context()` to
+// database queries and outgoing requests.
+func Timeout(d time.Duration) leego.MiddlewareFunc {
+	c := DefaultTimeoutConfig
+	c.Timeout = d
+	return TimeoutWithConfig(c)
+}
+
+// TimeoutWithConfig returns a Timeout middleware from config.
+// See `Timeout()`.
+func TimeoutWithConfig(config TimeoutConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultTimeoutConfig.Skipper
+	}
+	if config.Timeout <= 0 {
+		panic("timeout middleware requires timeout")
+	}
+	if config.FormatLeeError == nil {
+		config.FormatLeeError = DefaultTimeoutConfig.FormatLeeError
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			parent := c.Context()
+			ctx, cancel := context.WithTimeout(parent, config.Timeout)
+			defer cancel()
+			res := c.Response()
+			tr := &timeoutResponse{Response: res, ctx: ctx}
+			c.SetContext(ctx)
+			c.SetResponse(tr)
+			err := next(c)
+			c.SetResponse(res)
+			c.SetContext(parent)
+
+			tr.mu.Lock()
+			committed := tr.committed
+			tr.mu.Unlock()
+			if ctx.Err() == context.DeadlineExceeded && !committed {
+				return config.FormatLeeError(ErrTimeout, timeoutMiddlewareName)
+			}
+			return err
+		}
+	}
+}
+
+// expired reports whether the timeout passed, and marks the response committed
+// otherwise.
+func (r *timeoutResponse) expired(commit bool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ctx.Err() != nil {
+		return true
+	}
+	if commit {
+		r.committed = true
+	}
+	return false
+}
+
+func (r *timeoutResponse) WriteHeader(code int) {
+	if r.expired(true) {
+		return
+	}
+	r.Response.WriteHeader(code)
+}
+
+func (r *timeoutResponse) Write(b []byte) (int, error) {
+	if r.expired(true) {
+		return 0, r.ctx.Err()
+	}
+	return r.Response.Write(b)
+}
+
+func (r *timeoutResponse) Flush() {
+	if r.expired(false) {
+		return
+	}
+	r.Response.Flush()
+}
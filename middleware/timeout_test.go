@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeout(t *testing.T) {
+	lee := leego.New()
+	lee.Use(Timeout(20 * time.Millisecond))
+	lee.GET("/slow", func(c leego.Context) leego.LeeError {
+		_, ok := c.Deadline()
+		assert.True(t, ok)
+		<-c.Done()
+		return c.String(http.StatusOK, "late")
+	})
+	lee.GET("/blocking", func(c leego.Context) leego.LeeError {
+		time.Sleep(50 * time.Millisecond)
+		return c.String(http.StatusOK, "late")
+	})
+	lee.GET("/fast", func(c leego.Context) leego.LeeError {
+		return c.String(http.StatusOK, "ok")
+	})
+
+	rec := httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, "/slow", nil)), standard.NewResponse(rec))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "request timeout", rec.Body.String())
+
+	// Sent once the handler returns, not at the deadline
+	rec = httptest.NewRecorder()
+	start := time.Now()
+	lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, "/blocking", nil)), standard.NewResponse(rec))
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "request timeout", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	lee.ServeHTTP(standard.NewRequest(httptest.NewRequest(leego.GET, "/fast", nil)), standard.NewResponse(rec))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "ok", rec.Body.String())
+
+	assert.Panics(t, func() {
+		TimeoutWithConfig(TimeoutConfig{})
+	})
+}
@@ -0,0 +1,208 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"golang.org/x/net/context"
+)
+
+type (
+	// TraceConfig defines the config for Trace middleware.
+	TraceConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// Export receives the sampled spans once ended, e.g. an adapter to an
+		// OpenTelemetry exporter.
+		// Required.
+		Export func(span *Span)
+	}
+
+	// SpanContext identifies a span across services, propagated in the W3C
+	// `traceparent` header.
+	SpanContext struct {
+		TraceID [16]byte
+		SpanID  [8]byte
+		Sampled bool
+
+		// TraceState is the vendor data of the `tracestate` header, passed on
+		// as is.
+		TraceState string
+	}
+
+	// Span is the server span of a request.
+	Span struct {
+		SpanContext
+
+		// Parent is the span of the caller, invalid for a new trace.
+		Parent SpanContext
+
+		// Name is the method and the route, e.g. "GET /users/:id".
+		Name  string
+		Start time.Time
+		End   time.Time
+
+		// Attributes follow the OpenTelemetry HTTP conventions, e.g.
+		// "http.route" and "http.status_code".
+		Attributes map[string]interface{}
+
+		// Error is the error returned by the handler, or the status text for
+		// 5xx responses.
+		Error string
+	}
+
+	spanContextKey struct{}
+)
+
+var (
+	// DefaultTraceConfig is the default Trace middleware config.
+	DefaultTraceConfig = TraceConfig{
+		Skipper: defaultSkipper,
+	}
+)
+
+// Trace returns a middleware which starts a server span per request, child of
+// the span of the caller sent in `traceparent`, and exports it with the route,
+// status and error once the request is handled. The span is in the context of
+// the request, see `SpanFromContext()`, for the clients of downstream services
+// to continue the trace with `InjectTraceparent()`.
+func Trace(export func(span *Span)) leego.MiddlewareFunc {
+	c := DefaultTraceConfig
+	c.Export = export
+	return TraceWithConfig(c)
+}
+
+// TraceWithConfig returns a Trace middleware from config.
+// See `Trace()`.
+func TraceWithConfig(config TraceConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultTraceConfig.Skipper
+	}
+	if config.Export == nil {
+		panic("trace middleware requires export")
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			req := c.Request()
+			span := &Span{Start: time.Now()}
+			if parent, ok := ParseTraceparent(req.Header().Get(leego.HeaderTraceparent)); ok {
+				parent.TraceState = req.Header().Get(leego.HeaderTracestate)
+				span.Parent = parent
+				span.TraceID = parent.TraceID
+				span.Sampled = parent.Sampled
+				span.TraceState = parent.TraceState
+			} else {
+				randomID(span.TraceID[:])
+				span.Sampled = true
+			}
+			randomID(span.SpanID[:])
+			c.SetContext(context.WithValue(c.Context(), spanContextKey{}, span))
+
+			err := next(c)
+			if !span.Sampled {
+				return err
+			}
+			span.End = time.Now()
+			status := responseStatus(c.Response(), err)
+			span.Name = req.Method()
+			span.Attributes = map[string]interface{}{
+				"http.method":      req.Method(),
+				"http.target":      req.URI(),
+				"http.scheme":      req.Scheme(),
+				"http.host":        req.Host(),
+				"http.user_agent":  req.UserAgent(),
+				"http.client_ip":   c.RealIP(),
+				"http.status_code": status,
+			}
+			if route := c.Path(); route != "" {
+				span.Name += " " + route
+				span.Attributes["http.route"] = route
+			}
+			if err != nil {
+				span.Error = err.Error()
+			} else if status >= http.StatusInternalServerError {
+				span.Error = http.StatusText(status)
+			}
+			config.Export(span)
+			return err
+		}
+	}
+}
+
+// SpanFromContext returns the span of the request in ctx, nil if there is
+// none. See `Trace()`.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanContextKey{}).(*Span)
+	return s
+}
+
+// InjectTraceparent sets the `traceparent` and `tracestate` headers of a
+// request to a downstream service, so it continues the trace of the span in
+// ctx. It does nothing without span.
+func InjectTraceparent(ctx context.Context, h http.Header) {
+	s := SpanFromContext(ctx)
+	if s == nil {
+		return
+	}
+	h.Set(leego.HeaderTraceparent, s.Traceparent())
+	if s.TraceState != "" {
+		h.Set(leego.HeaderTracestate, s.TraceState)
+	}
+}
+
+// ParseTraceparent parses a W3C `traceparent` header, e.g.
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func ParseTraceparent(s string) (sc SpanContext, ok bool) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	// Versions after 00 may add fields
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || parts[0] == "00" && len(parts) != 4 {
+		return sc, false
+	}
+	var version, flags [1]byte
+	if !decodeHex(version[:], parts[0]) || !decodeHex(sc.TraceID[:], parts[1]) ||
+		!decodeHex(sc.SpanID[:], parts[2]) || !decodeHex(flags[:], parts[3]) {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, sc.IsValid()
+}
+
+// IsValid returns true if the trace and span IDs aren't all zeros.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Traceparent returns the W3C `traceparent` header of the span.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-" + flags
+}
+
+// decodeHex decodes the lowercase hex s into b, of exactly its length.
+func decodeHex(b []byte, s string) bool {
+	if len(s) != 2*len(b) || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(b, []byte(s))
+	return err == nil
+}
+
+func randomID(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		panic("trace: " + err.Error())
+	}
+}
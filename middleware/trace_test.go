@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrace(t *testing.T) {
+	lee := leego.New()
+	var spans []*Span
+	mw := Trace(func(s *Span) {
+		spans = append(spans, s)
+	})
+	downstream := make(http.Header)
+	request := func(traceparent string, h leego.HandlerFunc) leego.LeeError {
+		req := httptest.NewRequest(leego.GET, "/users/1", nil)
+		if traceparent != "" {
+			req.Header.Set(leego.HeaderTraceparent, traceparent)
+			req.Header.Set(leego.HeaderTracestate, "vendor=abc")
+		}
+		c := lee.NewContext(standard.NewRequest(req), standard.NewResponse(httptest.NewRecorder()))
+		c.SetPath("/users/:id")
+		return mw(h)(c)
+	}
+
+	// Continued trace
+	parent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	request(parent, func(c leego.Context) leego.LeeError {
+		InjectTraceparent(c.Context(), downstream)
+		return c.String(http.StatusOK, "joe")
+	})
+	if assert.Len(t, spans, 1) {
+		s := spans[0]
+		assert.Equal(t, parent, s.Parent.Traceparent())
+		assert.Equal(t, s.Parent.TraceID, s.TraceID)
+		assert.NotEqual(t, s.Parent.SpanID, s.SpanID)
+		assert.Equal(t, "GET /users/:id", s.Name)
+		assert.Equal(t, "/users/:id", s.Attributes["http.route"])
+		assert.Equal(t, http.StatusOK, s.Attributes["http.status_code"])
+		assert.Empty(t, s.Error)
+		assert.False(t, s.End.Before(s.Start))
+		assert.Equal(t, s.Traceparent(), downstream.Get(leego.HeaderTraceparent))
+		assert.Equal(t, "vendor=abc", downstream.Get(leego.HeaderTracestate))
+		assert.True(t, strings.HasPrefix(s.Traceparent(), "00-4bf92f3577b34da6a3ce929d0e0e4736-"))
+	}
+
+	// New trace with error
+	spans = nil
+	request("00-00000000000000000000000000000000-00f067aa0ba902b7-01", func(c leego.Context) leego.LeeError {
+		return errors.New("db down")
+	})
+	if assert.Len(t, spans, 1) {
+		s := spans[0]
+		assert.False(t, s.Parent.IsValid())
+		assert.True(t, s.IsValid())
+		assert.True(t, s.Sampled)
+		assert.Equal(t, http.StatusInternalServerError, s.Attributes["http.status_code"])
+		assert.Equal(t, "db down", s.Error)
+	}
+
+	// Not sampled
+	spans = nil
+	request("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", func(c leego.Context) leego.LeeError {
+		return nil
+	})
+	assert.Empty(t, spans)
+}
+
+func TestParseTraceparent(t *testing.T) {
+	for _, tt := range []struct {
+		header string
+		ok     bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00-4bf92f3577b34da6-00f067aa0ba902b7-01", false},
+		{"", false},
+	} {
+		_, ok := ParseTraceparent(tt.header)
+		assert.Equal(t, tt.ok, ok, tt.header)
+	}
+}
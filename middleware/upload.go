@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"  // GIF dimensions
+	_ "image/jpeg" // JPEG dimensions
+	_ "image/png"  // PNG dimensions
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// UploadValidatorConfig defines the config for UploadValidator middleware.
+	UploadValidatorConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper Skipper
+
+		// AllowedTypes lists the allowed content types, detected from the magic
+		// bytes of the file.
+		// Optional. Default value `DefaultUploadValidatorConfig.AllowedTypes`.
+		AllowedTypes []string `json:"allowed_types"`
+
+		// MaxWidth is the maximum width of an image in pixels, 0 for no limit.
+		// Optional. Default value 0.
+		MaxWidth int `json:"max_width"`
+
+		// MaxHeight is the maximum height of an image in pixels, 0 for no limit.
+		// Optional. Default value 0.
+		MaxHeight int `json:"max_height"`
+
+		// FormatLeeError formats the errors returned by the middleware, see
+		// `Middleware#FormatLeeError()`. A rejected file is passed as
+		// `*UploadError`.
+		// Optional. Default value returns `*UploadError` as a 422 `HTTPError`.
+		FormatLeeError func(err error, middlewareName string) leego.LeeError
+	}
+
+	// UploadError describes a rejected upload.
+	UploadError struct {
+		// Field is the form field of the file.
+		Field string `json:"field"`
+
+		// Filename is the name of the file sent by the client.
+		Filename string `json:"filename"`
+
+		// DeclaredType is the content type sent by the client.
+		DeclaredType string `json:"declared_type"`
+
+		// DetectedType is the content type detected from the content.
+		DetectedType string `json:"detected_type"`
+
+		// Reason tells why the file is rejected.
+		Reason string `json:"reason"`
+	}
+)
+
+const uploadValidatorMiddlewareName = "upload-validator"
+
+var (
+	// DefaultUploadValidatorConfig is the default UploadValidator middleware
+	// config.
+	DefaultUploadValidatorConfig = UploadValidatorConfig{
+		Skipper:      defaultSkipper,
+		AllowedTypes: []string{"image/jpeg", "image/png", "image/gif", "application/pdf"},
+		FormatLeeError: func(err error, middlewareName string) leego.LeeError {
+			if _, ok := err.(*UploadError); ok {
+				return leego.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+			}
+			return err
+		},
+	}
+)
+
+// Error implements `error` function.
+func (e *UploadError) Error() string {
+	return fmt.Sprintf("file %q of field %q: %s", e.Filename, e.Field, e.Reason)
+}
+
+// UploadValidator returns a middleware which validates the files of a multipart
+// request before the handler runs, see `ValidateUpload()`. A rejected file
+// returns 422.
+func UploadValidator() leego.MiddlewareFunc {
+	return UploadValidatorWithConfig(DefaultUploadValidatorConfig)
+}
+
+// UploadValidatorWithConfig returns an UploadValidator middleware from config.
+// See `UploadValidator()`.
+func UploadValidatorWithConfig(config UploadValidatorConfig) leego.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultUploadValidatorConfig.Skipper
+	}
+	if len(config.AllowedTypes) == 0 {
+		config.AllowedTypes = DefaultUploadValidatorConfig.AllowedTypes
+	}
+	if config.FormatLeeError == nil {
+		config.FormatLeeError = DefaultUploadValidatorConfig.FormatLeeError
+	}
+
+	return func(next leego.HandlerFunc) leego.HandlerFunc {
+		return func(c leego.Context) leego.LeeError {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			req := c.Request()
+			if !strings.HasPrefix(req.Header().Get(leego.HeaderContentType), leego.MIMEMultipartForm) {
+				return next(c)
+			}
+			form, err := req.MultipartForm()
+			if err != nil {
+				return config.FormatLeeError(leego.NewHTTPError(http.StatusBadRequest, err.Error()), uploadValidatorMiddlewareName)
+			}
+			for field, files := range form.File {
+				for _, fh := range files {
+					if err := ValidateUpload(field, fh, config); err != nil {
+						return config.FormatLeeError(err, uploadValidatorMiddlewareName)
+					}
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+// ValidateUpload checks the uploaded file of field against config. Its content
+// type is detected from its magic bytes, so it must be allowed and match the
+// content type declared by the client, if any. The dimensions of an image are
+// checked against the maximum. A rejected file returns `*UploadError`.
+func ValidateUpload(field string, fh *multipart.FileHeader, config UploadValidatorConfig) error {
+	f, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	uerr := &UploadError{
+		Field:        field,
+		Filename:     fh.Filename,
+		DeclaredType: fh.Header.Get(leego.HeaderContentType),
+	}
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	uerr.DetectedType = mediaType(http.DetectContentType(head[:n]))
+
+	allowed := false
+	for _, t := range config.AllowedTypes {
+		if t == uerr.DetectedType {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		uerr.Reason = "content type " + uerr.DetectedType + " not allowed"
+		return uerr
+	}
+	if uerr.DeclaredType != "" && mediaType(uerr.DeclaredType) != uerr.DetectedType &&
+		mediaType(uerr.DeclaredType) != leego.MIMEOctetStream {
+		uerr.Reason = "declared content type " + uerr.DeclaredType + " doesn't match content"
+		return uerr
+	}
+
+	if strings.HasPrefix(uerr.DetectedType, "image/") && (config.MaxWidth > 0 || config.MaxHeight > 0) {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		ic, _, err := image.DecodeConfig(f)
+		if err != nil {
+			uerr.Reason = "invalid image: " + err.Error()
+			return uerr
+		}
+		if (config.MaxWidth > 0 && ic.Width > config.MaxWidth) || (config.MaxHeight > 0 && ic.Height > config.MaxHeight) {
+			uerr.Reason = fmt.Sprintf("image of %dx%d exceeds %dx%d", ic.Width, ic.Height, config.MaxWidth, config.MaxHeight)
+			return uerr
+		}
+	}
+	return nil
+}
+
+// mediaType returns the media type of a content type, without parameters.
+func mediaType(contentType string) string {
+	if t, _, err := mime.ParseMediaType(contentType); err == nil {
+		return t
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadValidator(t *testing.T) {
+	img := new(bytes.Buffer)
+	png.Encode(img, image.NewRGBA(image.Rect(0, 0, 20, 10)))
+
+	run := func(config UploadValidatorConfig, contentType string, content []byte) leego.LeeError {
+		body := new(bytes.Buffer)
+		w := multipart.NewWriter(body)
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Disposition", `form-data; name="avatar"; filename="a.png"`)
+		if contentType != "" {
+			h.Set(leego.HeaderContentType, contentType)
+		}
+		part, _ := w.CreatePart(h)
+		part.Write(content)
+		w.Close()
+		req := httptest.NewRequest(leego.POST, "/", body)
+		req.Header.Set(leego.HeaderContentType, w.FormDataContentType())
+		c := leego.New().NewContext(standard.NewRequest(req), standard.NewResponse(httptest.NewRecorder()))
+		return UploadValidatorWithConfig(config)(func(c leego.Context) leego.LeeError {
+			return nil
+		})(c)
+	}
+
+	assert.Nil(t, run(UploadValidatorConfig{}, "image/png", img.Bytes()))
+	assert.Nil(t, run(UploadValidatorConfig{}, "", img.Bytes()))
+	assert.Nil(t, run(UploadValidatorConfig{MaxWidth: 20, MaxHeight: 10}, "image/png", img.Bytes()))
+
+	for _, test := range []struct {
+		config      UploadValidatorConfig
+		contentType string
+		content     []byte
+		reason      string
+	}{
+		{UploadValidatorConfig{}, "image/png", []byte("<html>x</html>"), "content type text/html not allowed"},
+		{UploadValidatorConfig{}, "image/jpeg", img.Bytes(), "declared content type image/jpeg doesn't match content"},
+		{UploadValidatorConfig{MaxWidth: 10}, "image/png", img.Bytes(), "image of 20x10 exceeds 10x0"},
+	} {
+		var uerr *UploadError
+		test.config.FormatLeeError = func(err error, name string) leego.LeeError {
+			uerr, _ = err.(*UploadError)
+			return err
+		}
+		run(test.config, test.contentType, test.content)
+		if assert.NotNil(t, uerr) {
+			assert.Equal(t, "avatar", uerr.Field)
+			assert.Equal(t, test.reason, uerr.Reason)
+		}
+	}
+
+	err := run(UploadValidatorConfig{}, "", []byte("%PDF-1.4 plain"))
+	assert.Nil(t, err)
+	err = run(UploadValidatorConfig{AllowedTypes: []string{"image/png"}}, "", []byte("%PDF-1.4 plain"))
+	if he, ok := err.(*leego.HTTPError); assert.True(t, ok) {
+		assert.Equal(t, http.StatusUnprocessableEntity, he.Code)
+	}
+}
@@ -0,0 +1,137 @@
+package leego
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// defaultOffers are the types `Context#Negotiate()` offers by default.
+var defaultOffers = []string{MIMEApplicationJSON, MIMEApplicationXML, MIMETextPlain}
+
+func (c *leegoContext) Accepts(offers ...string) string {
+	return negotiate(c.request.Header().Get(HeaderAccept), offers, matchMediaType)
+}
+
+func (c *leegoContext) AcceptsLanguage(offers ...string) string {
+	header := c.request.Header().Get(HeaderAcceptLanguage)
+	if len(offers) > 0 {
+		return negotiate(header, offers, matchLanguage)
+	}
+	// The preferred language of the client
+	lang, max := "", 0.0
+	for rest := header; rest != ""; {
+		var r string
+		var q float64
+		r, q, rest = nextAccept(rest)
+		if q > max && r != "*" {
+			lang, max = r, q
+		}
+	}
+	return lang
+}
+
+func (c *leegoContext) Negotiate(code int, i interface{}, offers ...string) error {
+	if len(offers) == 0 {
+		offers = defaultOffers
+	}
+	switch c.Accepts(offers...) {
+	case MIMEApplicationJSON:
+		return c.JSON(code, i)
+	case MIMEApplicationXML:
+		return c.XML(code, i)
+	case MIMETextHTML:
+		return c.HTML(code, fmt.Sprint(i))
+	case MIMETextPlain:
+		return c.String(code, fmt.Sprint(i))
+	}
+	return ErrNotAcceptable
+}
+
+// negotiate returns the offer with the highest quality in the Accept-style
+// header, the first offer if the header is empty. match returns how specific a
+// range matching an offer is, 0 if it doesn't match.
+func negotiate(header string, offers []string, match func(r, offer string) int) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	if strings.TrimSpace(header) == "" {
+		return offers[0]
+	}
+	best, bestQ, bestPos, bestSpec := "", 0.0, 0, 0
+	for _, o := range offers {
+		q, pos, spec := 0.0, 0, 0
+		for i, rest := 0, header; rest != ""; i++ {
+			var r string
+			var rq float64
+			r, rq, rest = nextAccept(rest)
+			// The most specific range matching the offer sets its quality
+			if s := match(r, o); s > spec {
+				q, pos, spec = rq, i, s
+			}
+		}
+		if q == 0 {
+			continue
+		}
+		// Ties go to the range listed first, then to the more specific match,
+		// then to the first offer
+		if q > bestQ || q == bestQ && (pos < bestPos || pos == bestPos && spec > bestSpec) {
+			best, bestQ, bestPos, bestSpec = o, q, pos, spec
+		}
+	}
+	return best
+}
+
+// nextAccept parses the first range of an Accept-style header and its quality,
+// e.g. "text/html;q=0.8", and returns the rest of the header.
+func nextAccept(header string) (r string, q float64, rest string) {
+	if i := strings.IndexByte(header, ','); i >= 0 {
+		header, rest = header[:i], header[i+1:]
+	}
+	q = 1
+	if i := strings.IndexByte(header, ';'); i >= 0 {
+		for _, p := range strings.Split(header[i+1:], ";") {
+			p = strings.TrimSpace(p)
+			if len(p) > 2 && (p[0] == 'q' || p[0] == 'Q') && p[1] == '=' {
+				var err error
+				if q, err = strconv.ParseFloat(p[2:], 64); err != nil || q < 0 || q > 1 {
+					q = 0
+				}
+			}
+		}
+		header = header[:i]
+	}
+	return strings.TrimSpace(header), q, rest
+}
+
+// matchMediaType matches a media range, e.g. "text/*", with a media type.
+func matchMediaType(r, offer string) int {
+	switch {
+	case strings.EqualFold(r, offer):
+		return 3
+	case r == "*/*":
+		return 1
+	case strings.HasSuffix(r, "/*"):
+		if i := strings.IndexByte(offer, '/'); i >= 0 && strings.EqualFold(r[:len(r)-1], offer[:i+1]) {
+			return 2
+		}
+	}
+	return 0
+}
+
+// matchLanguage matches a language range, e.g. "en", with a language tag, e.g.
+// "en-US". A tag also matches the more specific ranges it's a prefix of, e.g.
+// "en" matches "en-GB", before "*".
+func matchLanguage(r, offer string) int {
+	switch {
+	case strings.EqualFold(r, offer):
+		return 4
+	case len(offer) > len(r) && offer[len(r)] == '-' && strings.EqualFold(offer[:len(r)], r):
+		return 3
+	case len(r) > len(offer) && r[len(offer)] == '-' && strings.EqualFold(r[:len(offer)], offer):
+		return 2
+	case r == "*":
+		return 1
+	}
+	return 0
+}
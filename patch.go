@@ -0,0 +1,388 @@
+package leego
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+type (
+	// JSONPatch is a JSON Patch document (RFC 6902), bound from a request with
+	// the `MIMEApplicationJSONPatch` content type.
+	JSONPatch []PatchOperation
+
+	// PatchOperation is an operation of a JSON Patch.
+	PatchOperation struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		From  string          `json:"from,omitempty"`
+		Value json.RawMessage `json:"value,omitempty"`
+	}
+
+	// PatchConfig restricts the patches applied with `ApplyJSONPatch()` and
+	// `ApplyMergePatch()`.
+	PatchConfig struct {
+		// AllowedPaths lists the JSON pointers which may be changed, with their
+		// children, e.g. "/name" allows "/name" and "/name/first". A "*" token
+		// matches any token, e.g. "/items/*/quantity".
+		// Optional. Default value allows all paths.
+		AllowedPaths []string `json:"allowed_paths"`
+
+		// MaxOperations is the maximum number of operations of a JSON Patch, or
+		// of values changed by a merge patch, 0 for no limit.
+		// Optional. Default value 0.
+		MaxOperations int `json:"max_operations"`
+	}
+)
+
+// MIME types of patches
+const (
+	MIMEApplicationJSONPatch  = "application/json-patch+json"
+	MIMEApplicationMergePatch = "application/merge-patch+json"
+)
+
+var (
+	errPatchPath = NewHTTPError(http.StatusConflict, "patch path not found")
+)
+
+// ApplyJSONPatch applies patch to doc, a pointer to a struct or a map, through
+// its JSON representation. The patch is applied completely or not at all. An
+// invalid patch returns 400, a forbidden path 403, and a failed test or a
+// missing path 409.
+func ApplyJSONPatch(doc interface{}, patch JSONPatch, config PatchConfig) error {
+	if config.MaxOperations > 0 && len(patch) > config.MaxOperations {
+		return NewHTTPError(http.StatusBadRequest, fmt.Sprintf("patch of %d operations exceeds %d", len(patch), config.MaxOperations))
+	}
+	tree, err := toTree(doc)
+	if err != nil {
+		return err
+	}
+	for _, op := range patch {
+		if tree, err = op.apply(tree, &config); err != nil {
+			return err
+		}
+	}
+	return fromTree(tree, doc)
+}
+
+// ApplyMergePatch applies the JSON Merge Patch (RFC 7386) patch to doc, a
+// pointer to a struct or a map, through its JSON representation. An invalid
+// patch returns 400 and a forbidden path 403.
+func ApplyMergePatch(doc interface{}, patch []byte, config PatchConfig) error {
+	var p interface{}
+	if err := decodeTree(patch, &p); err != nil {
+		return NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	var paths []string
+	mergePaths(p, "", &paths)
+	if config.MaxOperations > 0 && len(paths) > config.MaxOperations {
+		return NewHTTPError(http.StatusBadRequest, fmt.Sprintf("patch of %d values exceeds %d", len(paths), config.MaxOperations))
+	}
+	for _, path := range paths {
+		if err := config.allow(path); err != nil {
+			return err
+		}
+	}
+	tree, err := toTree(doc)
+	if err != nil {
+		return err
+	}
+	return fromTree(mergePatch(tree, p), doc)
+}
+
+func (op *PatchOperation) apply(tree interface{}, config *PatchConfig) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	if op.Op != "test" {
+		if err = config.allow(op.Path); err != nil {
+			return nil, err
+		}
+	}
+	var value interface{}
+	switch op.Op {
+	case "add", "replace", "test":
+		if len(op.Value) == 0 {
+			return nil, NewHTTPError(http.StatusBadRequest, "patch "+op.Op+" requires value")
+		}
+		if err = decodeTree(op.Value, &value); err != nil {
+			return nil, NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	switch op.Op {
+	case "add":
+		return patchAdd(tree, path, value)
+	case "remove":
+		tree, _, err = patchRemove(tree, path)
+		return tree, err
+	case "replace":
+		if tree, _, err = patchRemove(tree, path); err != nil {
+			return nil, err
+		}
+		return patchAdd(tree, path, value)
+	case "test":
+		v, err := patchGet(tree, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(v, value) {
+			return nil, NewHTTPError(http.StatusConflict, "patch test failed at "+op.Path)
+		}
+		return tree, nil
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			if err = config.allow(op.From); err != nil {
+				return nil, err
+			}
+			if strings.HasPrefix(op.Path, op.From+"/") {
+				return nil, NewHTTPError(http.StatusBadRequest, "patch can't move "+op.From+" into itself")
+			}
+			if tree, value, err = patchRemove(tree, from); err != nil {
+				return nil, err
+			}
+		} else {
+			if value, err = patchGet(tree, from); err != nil {
+				return nil, err
+			}
+			if value, err = copyTree(value); err != nil {
+				return nil, err
+			}
+		}
+		return patchAdd(tree, path, value)
+	}
+	return nil, NewHTTPError(http.StatusBadRequest, "invalid patch op "+strconv.Quote(op.Op))
+}
+
+// allow returns 403 if path isn't allowed.
+func (config *PatchConfig) allow(path string) error {
+	if len(config.AllowedPaths) == 0 {
+		return nil
+	}
+	tokens := strings.Split(path, "/")
+	for _, a := range config.AllowedPaths {
+		allowed := strings.Split(a, "/")
+		if len(allowed) > len(tokens) {
+			continue
+		}
+		match := true
+		for i, t := range allowed {
+			if t != "*" && t != tokens[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return nil
+		}
+	}
+	return NewHTTPError(http.StatusForbidden, "patch path "+path+" not allowed")
+}
+
+// parsePointer returns the unescaped tokens of a JSON pointer (RFC 6901).
+func parsePointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if p[0] != '/' {
+		return nil, NewHTTPError(http.StatusBadRequest, "invalid patch path "+strconv.Quote(p))
+	}
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(t, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+func patchGet(node interface{}, path []string) (interface{}, error) {
+	for _, t := range path {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			v, ok := n[t]
+			if !ok {
+				return nil, errPatchPath
+			}
+			node = v
+		case []interface{}:
+			i, err := patchIndex(t, len(n)-1)
+			if err != nil {
+				return nil, err
+			}
+			node = n[i]
+		default:
+			return nil, errPatchPath
+		}
+	}
+	return node, nil
+}
+
+func patchAdd(node interface{}, path []string, v interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return v, nil
+	}
+	t := path[0]
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			n[t] = v
+			return n, nil
+		}
+		child, ok := n[t]
+		if !ok {
+			return nil, errPatchPath
+		}
+		c, err := patchAdd(child, path[1:], v)
+		n[t] = c
+		return n, err
+	case []interface{}:
+		if len(path) == 1 {
+			if t == "-" {
+				return append(n, v), nil
+			}
+			i, err := patchIndex(t, len(n))
+			if err != nil {
+				return nil, err
+			}
+			n = append(n, nil)
+			copy(n[i+1:], n[i:])
+			n[i] = v
+			return n, nil
+		}
+		i, err := patchIndex(t, len(n)-1)
+		if err != nil {
+			return nil, err
+		}
+		c, err := patchAdd(n[i], path[1:], v)
+		n[i] = c
+		return n, err
+	}
+	return nil, errPatchPath
+}
+
+// patchRemove removes the value at path and returns it.
+func patchRemove(node interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, node, nil
+	}
+	t := path[0]
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, ok := n[t]
+		if !ok {
+			return nil, nil, errPatchPath
+		}
+		if len(path) == 1 {
+			delete(n, t)
+			return n, child, nil
+		}
+		c, removed, err := patchRemove(child, path[1:])
+		n[t] = c
+		return n, removed, err
+	case []interface{}:
+		i, err := patchIndex(t, len(n)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(path) == 1 {
+			removed := n[i]
+			return append(n[:i], n[i+1:]...), removed, nil
+		}
+		c, removed, err := patchRemove(n[i], path[1:])
+		n[i] = c
+		return n, removed, err
+	}
+	return nil, nil, errPatchPath
+}
+
+// patchIndex parses an array index token, which must be <= max.
+func patchIndex(t string, max int) (int, error) {
+	i, err := strconv.Atoi(t)
+	if err != nil || i < 0 || (len(t) > 1 && t[0] == '0') {
+		return 0, NewHTTPError(http.StatusBadRequest, "invalid patch index "+strconv.Quote(t))
+	}
+	if i > max {
+		return 0, errPatchPath
+	}
+	return i, nil
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergePatch(t[k], v)
+		}
+	}
+	return t
+}
+
+// mergePaths collects the JSON pointers changed by a merge patch.
+func mergePaths(patch interface{}, prefix string, paths *[]string) {
+	p, ok := patch.(map[string]interface{})
+	if !ok || (len(p) == 0 && prefix != "") {
+		*paths = append(*paths, prefix)
+		return
+	}
+	for k, v := range p {
+		k = strings.Replace(strings.Replace(k, "~", "~0", -1), "/", "~1", -1)
+		mergePaths(v, prefix+"/"+k, paths)
+	}
+}
+
+func decodeTree(b []byte, v *interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+func toTree(doc interface{}) (tree interface{}, err error) {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return
+	}
+	err = decodeTree(b, &tree)
+	return
+}
+
+// fromTree sets doc from the JSON representation tree.
+func fromTree(tree, doc interface{}) error {
+	b, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	v := reflect.ValueOf(doc).Elem()
+	n := reflect.New(v.Type())
+	if err = json.Unmarshal(b, n.Interface()); err != nil {
+		return NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	}
+	v.Set(n.Elem())
+	return nil
+}
+
+func copyTree(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var c interface{}
+	err = decodeTree(b, &c)
+	return c, err
+}
@@ -0,0 +1,97 @@
+package leego_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+type patchUser struct {
+	Name  string            `json:"name"`
+	Email string            `json:"email,omitempty"`
+	Tags  []string          `json:"tags"`
+	Meta  map[string]string `json:"meta,omitempty"`
+	Admin bool              `json:"admin"`
+}
+
+func patchCode(err error) int {
+	if he, ok := err.(*leego.HTTPError); ok {
+		return he.Code
+	}
+	return 0
+}
+
+func TestBindJSONPatch(t *testing.T) {
+	body := `[{"op":"replace","path":"/name","value":"Joe"},{"op":"add","path":"/tags/-","value":"b"}]`
+	req := httptest.NewRequest(leego.PATCH, "/", strings.NewReader(body))
+	req.Header.Set(leego.HeaderContentType, leego.MIMEApplicationJSONPatch)
+	c := leego.New().NewContext(standard.NewRequest(req), standard.NewResponse(httptest.NewRecorder()))
+	var patch leego.JSONPatch
+	assert.NoError(t, c.Bind(&patch))
+
+	u := &patchUser{Name: "Jon", Tags: []string{"a"}}
+	assert.NoError(t, leego.ApplyJSONPatch(u, patch, leego.PatchConfig{}))
+	assert.Equal(t, "Joe", u.Name)
+	assert.Equal(t, []string{"a", "b"}, u.Tags)
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	config := leego.PatchConfig{AllowedPaths: []string{"/name", "/tags", "/meta"}, MaxOperations: 4}
+	u := &patchUser{Name: "Jon", Tags: []string{"a", "b"}, Meta: map[string]string{"a/b": "1"}}
+	err := leego.ApplyJSONPatch(u, leego.JSONPatch{
+		{Op: "test", Path: "/name", Value: []byte(`"Jon"`)},
+		{Op: "remove", Path: "/tags/0"},
+		{Op: "copy", From: "/name", Path: "/meta/name"},
+		{Op: "move", From: "/meta/a~1b", Path: "/meta/c"},
+	}, config)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b"}, u.Tags)
+	assert.Equal(t, map[string]string{"name": "Jon", "c": "1"}, u.Meta)
+
+	// Forbidden path
+	err = leego.ApplyJSONPatch(u, leego.JSONPatch{{Op: "replace", Path: "/admin", Value: []byte("true")}}, config)
+	assert.Equal(t, http.StatusForbidden, patchCode(err))
+	assert.False(t, u.Admin)
+
+	// Failed test leaves doc unchanged
+	err = leego.ApplyJSONPatch(u, leego.JSONPatch{
+		{Op: "replace", Path: "/name", Value: []byte(`"Joe"`)},
+		{Op: "test", Path: "/name", Value: []byte(`"Jon"`)},
+	}, config)
+	assert.Equal(t, http.StatusConflict, patchCode(err))
+	assert.Equal(t, "Jon", u.Name)
+
+	// Missing path, invalid op and too many operations
+	err = leego.ApplyJSONPatch(u, leego.JSONPatch{{Op: "remove", Path: "/tags/5"}}, config)
+	assert.Equal(t, http.StatusConflict, patchCode(err))
+	err = leego.ApplyJSONPatch(u, leego.JSONPatch{{Op: "frobnicate", Path: "/name"}}, config)
+	assert.Equal(t, http.StatusBadRequest, patchCode(err))
+	err = leego.ApplyJSONPatch(u, make(leego.JSONPatch, 5), config)
+	assert.Equal(t, http.StatusBadRequest, patchCode(err))
+
+	// Type mismatch
+	err = leego.ApplyJSONPatch(u, leego.JSONPatch{{Op: "replace", Path: "/name", Value: []byte("1")}}, config)
+	assert.Equal(t, http.StatusUnprocessableEntity, patchCode(err))
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	u := &patchUser{Name: "Jon", Email: "jon@example.com", Meta: map[string]string{"a": "1", "b": "2"}}
+	config := leego.PatchConfig{AllowedPaths: []string{"/email", "/meta/*"}}
+	assert.NoError(t, leego.ApplyMergePatch(u, []byte(`{"email":null,"meta":{"a":null,"c":"3"}}`), config))
+	assert.Equal(t, "", u.Email)
+	assert.Equal(t, map[string]string{"b": "2", "c": "3"}, u.Meta)
+
+	err := leego.ApplyMergePatch(u, []byte(`{"name":"Joe"}`), config)
+	assert.Equal(t, http.StatusForbidden, patchCode(err))
+	err = leego.ApplyMergePatch(u, []byte(`{`), config)
+	assert.Equal(t, http.StatusBadRequest, patchCode(err))
+
+	m := map[string]interface{}{"a": 1}
+	assert.NoError(t, leego.ApplyMergePatch(&m, []byte(`{"b":{"c":true}}`), leego.PatchConfig{}))
+	assert.Equal(t, map[string]interface{}{"a": float64(1), "b": map[string]interface{}{"c": true}}, m)
+}
@@ -0,0 +1,91 @@
+package leego
+
+type (
+	// Predicate reports whether a route applies to the request, beyond its method
+	// and path. See `Leego#When()`.
+	Predicate func(Context) bool
+
+	// conditionalRoute dispatches a method and path to the first handler whose
+	// predicate holds, falling through to the unconditional handler.
+	conditionalRoute struct {
+		cases    []conditionalCase
+		fallback HandlerFunc
+	}
+
+	conditionalCase struct {
+		predicate Predicate
+		handler   HandlerFunc
+	}
+)
+
+// Header returns a predicate which holds when the request header `name` equals
+// value, e.g. `Header("X-API-Version", "2")`.
+func Header(name, value string) Predicate {
+	return func(c Context) bool {
+		return c.Request().Header().Get(name) == value
+	}
+}
+
+// Query returns a predicate which holds when the query param `name` equals
+// value.
+func Query(name, value string) Predicate {
+	return func(c Context) bool {
+		return c.QueryParam(name) == value
+	}
+}
+
+// All returns a predicate which holds when every predicate holds.
+func All(predicates ...Predicate) Predicate {
+	return func(c Context) bool {
+		for _, p := range predicates {
+			if !p(c) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// When registers a new route for method and path which only matches when the
+// predicate holds, with optional route-level middleware. Predicates are tried in
+// registration order; when none holds, the request falls through to the route
+// registered without a predicate, if any, otherwise it's not found.
+func (e *Leego) When(p Predicate, method, path string, handler HandlerFunc, middleware ...MiddlewareFunc) {
+	key := method + path
+	cr := e.conditionals[key]
+	if cr == nil {
+		cr = &conditionalRoute{fallback: e.router.handler(method, path)}
+		if e.conditionals == nil {
+			e.conditionals = make(map[string]*conditionalRoute)
+		}
+		e.conditionals[key] = cr
+		e.router.Add(method, path, cr.serve, e)
+	}
+	cr.cases = append(cr.cases, conditionalCase{p, chain(handler, middleware)})
+	e.router.routes[key] = &Route{
+		Method:  method,
+		Path:    path,
+		Handler: handlerName(handler),
+	}
+	e.router.setMiddleware(key, middleware)
+}
+
+// When implements `leego#When()` for sub-routes within the Group.
+func (g *Group) When(p Predicate, method, path string, handler HandlerFunc, middleware ...MiddlewareFunc) {
+	m := []MiddlewareFunc{}
+	m = append(m, g.middleware...)
+	m = append(m, middleware...)
+	g.leego.When(p, method, g.prefix+path, handler, m...)
+}
+
+func (r *conditionalRoute) serve(c Context) LeeError {
+	for _, cc := range r.cases {
+		if cc.predicate(c) {
+			return cc.handler(c)
+		}
+	}
+	if r.fallback != nil {
+		return r.fallback(c)
+	}
+	return ErrNotFound
+}
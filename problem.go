@@ -0,0 +1,116 @@
+package leego
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Problem is an RFC 7807 problem details object, sent as
+// `application/problem+json`. Handlers can return it as an error:
+//
+//	return &leego.Problem{
+//		Type:   "https://example.com/probs/out-of-credit",
+//		Title:  "You do not have enough credit.",
+//		Status: http.StatusForbidden,
+//		Extensions: map[string]interface{}{"balance": 30},
+//	}
+type Problem struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// Extensions are additional members of the problem.
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// Error makes it compatible with `error` interface.
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	return p.Title
+}
+
+// MarshalJSON encodes the problem with its extensions as members.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	set := func(k, v string) {
+		if v != "" {
+			m[k] = v
+		}
+	}
+	set("type", p.Type)
+	set("title", p.Title)
+	set("detail", p.Detail)
+	set("instance", p.Instance)
+	if p.Status != 0 {
+		m["status"] = p.Status
+	}
+	return json.Marshal(m)
+}
+
+// NewProblem returns the problem for err. An HTTPError gives a problem of type
+// "about:blank" titled after its status code, with its message as detail and
+// its details as extensions. The instance is the path of the request. Like
+// with `Leego#DefaultHTTPErrorHandler()`, internal errors and other errors are
+// only shown in debug mode.
+func NewProblem(c Context, err error) *Problem {
+	if p, ok := err.(*Problem); ok {
+		q := *p
+		if q.Status == 0 {
+			q.Status = http.StatusInternalServerError
+		}
+		if q.Instance == "" {
+			q.Instance = c.Request().URL().Path()
+		}
+		return &q
+	}
+	he := &HTTPError{Code: http.StatusInternalServerError, Message: http.StatusText(http.StatusInternalServerError)}
+	if h, ok := asHTTPError(err); ok {
+		he = h
+	}
+	p := &Problem{
+		Type:       "about:blank",
+		Title:      http.StatusText(he.Code),
+		Status:     he.Code,
+		Instance:   c.Request().URL().Path(),
+		Extensions: he.Details,
+	}
+	if he.Message != p.Title {
+		p.Detail = he.Message
+	}
+	if c.Leego().Debug() {
+		p.Detail = err.Error()
+	}
+	return p
+}
+
+// ProblemHTTPErrorHandler is an HTTP error handler sending errors as RFC 7807
+// problems, see `NewProblem()`:
+//
+//	lee.SetHTTPErrorHandler(lee.ProblemHTTPErrorHandler)
+//
+// Groups can use it too, see `Group#SetHTTPErrorHandler()`.
+func (e *Leego) ProblemHTTPErrorHandler(err LeeError, c Context) {
+	if c.Response().Committed() {
+		return
+	}
+	p := NewProblem(c, err)
+	if c.Request().Method() == HEAD {
+		c.NoContent(p.Status)
+		return
+	}
+	b, jerr := json.Marshal(p)
+	if jerr != nil {
+		c.String(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+	c.Response().Header().Set(HeaderContentType, MIMEApplicationProblemJSON)
+	c.Response().WriteHeader(p.Status)
+	c.Response().Write(b)
+}
@@ -1,12 +1,34 @@
 package leego
 
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
 type (
 	// Router is the registry of all registered routes for an `leego` instance for
 	// request matching and URL path parameter parsing.
 	Router struct {
 		tree   *node
-		routes map[string]Route
+		routes map[string]*Route
 		leego  *Leego
+
+		// middleware are the names of the route middleware by method+path.
+		middleware map[string][]string
+
+		// meta is the metadata of the routes by method+path, see
+		// `Leego#SetRouteMeta()`.
+		meta map[string]map[string]string
+
+		// caseInsensitive matches the static parts of the paths regardless of
+		// case, see `Leego#SetCaseInsensitive()`.
+		caseInsensitive bool
+
+		// autoOptions answers the OPTIONS requests to the paths without an
+		// OPTIONS route, see `Leego#SetAutoOptions()`.
+		autoOptions bool
 	}
 	node struct {
 		kind          kind
@@ -30,6 +52,9 @@ type (
 		post    HandlerFunc
 		put     HandlerFunc
 		trace   HandlerFunc
+
+		// other are the handlers of non-standard methods, e.g. PROPFIND.
+		other map[string]HandlerFunc
 	}
 )
 
@@ -45,9 +70,20 @@ func NewRouter(lee *Leego) *Router {
 		tree: &node{
 			methodHandler: new(methodHandler),
 		},
-		routes: make(map[string]Route),
+		routes: make(map[string]*Route),
 		leego:   lee,
+		middleware: make(map[string][]string),
+		meta:       make(map[string]map[string]string),
+	}
+}
+
+// setMiddleware records the names of the middleware of the route with key.
+func (r *Router) setMiddleware(key string, middleware []MiddlewareFunc) {
+	var names []string
+	for _, m := range middleware {
+		names = append(names, funcName(m))
 	}
+	r.middleware[key] = names
 }
 
 // Add registers a new route for method and path with matching handler.
@@ -61,6 +97,9 @@ func (r *Router) Add(method, path string, h HandlerFunc, lee *Leego) {
 	}
 	ppath := path        // Pristine path
 	pnames := []string{} // Param names
+	if r.caseInsensitive {
+		path = lowerStatic(path)
+	}
 
 	for i, l := 0, len(path); i < l; i++ {
 		if path[i] == ':' {
@@ -90,6 +129,18 @@ func (r *Router) Add(method, path string, h HandlerFunc, lee *Leego) {
 	r.insert(method, path, h, skind, ppath, pnames, lee)
 }
 
+// handler returns the handler registered for method and path, if any.
+func (r *Router) handler(method, path string) HandlerFunc {
+	if path == "" || path[0] != '/' {
+		path = "/" + path
+	}
+	cn, h, code := r.find(method, path, make([]string, *r.leego.maxParam), nil)
+	if code != http.StatusOK || cn.ppath != path {
+		return nil
+	}
+	return h
+}
+
 func (r *Router) insert(method, path string, h HandlerFunc, t kind, ppath string, pnames []string, lee *Leego) {
 	// Adjust max param
 	l := len(pnames)
@@ -168,6 +219,9 @@ func (r *Router) insert(method, path string, h HandlerFunc, t kind, ppath string
 		} else {
 			// Node already exists
 			if h != nil {
+				if cn.hasHandler() && cn.ppath != ppath {
+					panic(fmt.Sprintf("leego ⇛ route %s conflicts with %s", ppath, cn.ppath))
+				}
 				cn.addHandler(method, h)
 				cn.ppath = ppath
 				cn.pnames = pnames
@@ -177,6 +231,20 @@ func (r *Router) insert(method, path string, h HandlerFunc, t kind, ppath string
 	}
 }
 
+// lowerStatic returns path with its static parts in lower case.
+func lowerStatic(path string) string {
+	b := []byte(path)
+	for i := 0; i < len(b); i++ {
+		if b[i] == ':' {
+			for ; i < len(b) && b[i] != '/'; i++ {
+			}
+			continue
+		}
+		b[i] = lower(b[i])
+	}
+	return string(b)
+}
+
 func newNode(t kind, pre string, p *node, c children, mh *methodHandler, ppath string, pnames []string) *node {
 	return &node{
 		kind:          t,
@@ -194,15 +262,6 @@ func (n *node) addChild(c *node) {
 	n.children = append(n.children, c)
 }
 
-func (n *node) findChild(l byte, t kind) *node {
-	for _, c := range n.children {
-		if c.label == l && c.kind == t {
-			return c
-		}
-	}
-	return nil
-}
-
 func (n *node) findChildWithLabel(l byte) *node {
 	for _, c := range n.children {
 		if c.label == l {
@@ -222,6 +281,9 @@ func (n *node) findChildByKind(t kind) *node {
 }
 
 func (n *node) addHandler(method string, h HandlerFunc) {
+	if h == nil {
+		return
+	}
 	switch method {
 	case GET:
 		n.methodHandler.get = h
@@ -241,6 +303,11 @@ func (n *node) addHandler(method string, h HandlerFunc) {
 		n.methodHandler.connect = h
 	case TRACE:
 		n.methodHandler.trace = h
+	default:
+		if n.methodHandler.other == nil {
+			n.methodHandler.other = make(map[string]HandlerFunc)
+		}
+		n.methodHandler.other[method] = h
 	}
 }
 
@@ -265,17 +332,49 @@ func (n *node) findHandler(method string) HandlerFunc {
 	case TRACE:
 		return n.methodHandler.trace
 	default:
-		return nil
+		return n.methodHandler.other[method]
 	}
 }
 
-func (n *node) checkMethodNotAllowed() HandlerFunc {
+// hasHandler reports whether n has a handler for any method.
+func (n *node) hasHandler() bool {
 	for _, m := range methods {
-		if h := n.findHandler(m); h != nil {
-			return MethodNotAllowedHandler
+		if n.findHandler(m) != nil {
+			return true
 		}
 	}
-	return NotFoundHandler
+	return len(n.methodHandler.other) > 0
+}
+
+// allow returns the methods of n for the `Allow` header, e.g. "GET, POST".
+func (n *node) allow() string {
+	var allowed []string
+	for _, m := range methods {
+		if n.findHandler(m) != nil {
+			allowed = append(allowed, m)
+		}
+	}
+	other := make([]string, 0, len(n.methodHandler.other))
+	for m := range n.methodHandler.other {
+		other = append(other, m)
+	}
+	sort.Strings(other)
+	return strings.Join(append(allowed, other...), ", ")
+}
+
+// allow returns the methods of cn for the `Allow` header, with OPTIONS if it's
+// answered automatically.
+func (r *Router) allow(cn *node) string {
+	allow := cn.allow()
+	if r.autoOptions && cn.methodHandler.options == nil {
+		allow += ", " + OPTIONS
+	}
+	return allow
+}
+
+// optionsHandler answers the OPTIONS requests, see `Leego#SetAutoOptions()`.
+func optionsHandler(c Context) LeeError {
+	return c.NoContent(http.StatusNoContent)
 }
 
 // Find lookup a handler registed for method and path. It also parses URL for path
@@ -287,145 +386,219 @@ func (n *node) checkMethodNotAllowed() HandlerFunc {
 // - Reset it `Context#Reset()`
 // - Return it `leego#ReleaseContext()`.
 func (r *Router) Find(method, path string, context Context) {
-	cn := r.tree // Current node as root
+	// Params map is built lazily, see `Context#GetParamsMap()`.
+	context.SetParamsMap(nil)
 
-	var (
-		search = path
-		c       *node  // Child node
-		n int    // Param counter
-		nk kind   // Next kind
-		nn      *node  // Next node
-		ns string // Next search
-		pmap  =make(map[string]string)
-		pvalues = context.ParamValues()
-	)
-
-	// Search order static > param > any
-	for {
-		if search == "" {
-			goto End
+	r.lookup(method, path, context, nil)
+}
+
+// lookup implements `Find()`, recording the matching decisions in trace if it's
+// not nil.
+func (r *Router) lookup(method, path string, context Context, trace *[]string) {
+	cn, h, code := r.find(method, path, context.ParamValues(), trace)
+	if code == http.StatusMethodNotAllowed {
+		if method == OPTIONS && r.autoOptions {
+			h, code = optionsHandler, http.StatusOK
+		}
+		if context.Response() != nil {
+			context.Response().Header().Set(HeaderAllow, r.allow(cn))
+		}
+	}
+	if code != http.StatusOK {
+		if ch, ok := r.leego.errorHandler(path, code); ok {
+			h = ch
 		}
+	}
+	context.SetHandler(h)
+	if cn == nil {
+		return
+	}
+	context.SetPath(cn.ppath)
+	context.SetParamNames(cn.pnames...)
+}
 
-		pl := 0 // Prefix length
-		l := 0  // LCP length
+// find looks up the node registered for method and path and loads path
+// parameter values into pvalues. It returns the matched handler along with
+// `http.StatusOK`, or the not found / method not allowed handler along with
+// its status code. The node is nil if no node matches the path at all.
+//
+// Children are tried in the order static > param > any, backtracking to the
+// next kind whenever a subtree doesn't match, so e.g. "/users/news" matches
+// "/users/:id" rather than failing on "/users/new". The first node in that order
+// with a handler for method wins; if the path only matches nodes without one,
+// the first of them answers 405.
+//
+// If trace is not nil, each decision is appended to it, see
+// `Leego#SetRouteTrace()`. It's checked at every step so the arguments aren't
+// evaluated otherwise.
+func (r *Router) find(method, path string, pvalues []string, trace *[]string) (*node, HandlerFunc, int) {
+	if path == "" {
+		path = "/"
+	}
+	m := matcher{
+		method:  method,
+		pvalues: pvalues,
+		trace:   trace,
+		fold:    r.caseInsensitive,
+	}
+	if cn := m.match(r.tree, path, 0); cn != nil {
+		return cn, cn.findHandler(method), http.StatusOK
+	}
+	if cn := m.allowed; cn != nil {
+		if trace != nil {
+			tracef(trace, "%q has no %s handler, %d", cn.ppath, method, http.StatusMethodNotAllowed)
+		}
+		return cn, MethodNotAllowedHandler, http.StatusMethodNotAllowed
+	}
+	if trace != nil {
+		tracef(trace, "not found")
+	}
+	return nil, NotFoundHandler, http.StatusNotFound
+}
 
-		if cn.label != ':' {
-			sl := len(search)
-			pl = len(cn.prefix)
+// matcher holds the state of a `Router#find()` lookup.
+type matcher struct {
+	method  string
+	pvalues []string
+	trace   *[]string
+	fold    bool  // Case-insensitive, the static prefixes are lower case
+	allowed *node // First node matching the path without a handler for method
+}
 
-			// LCP
-			max := pl
-			if sl < max {
-				max = sl
+// match matches search against cn and its subtree, n being the number of
+// params matched so far. It returns the node with a handler for the method.
+func (m *matcher) match(cn *node, search string, n int) *node {
+	switch cn.kind {
+	case skind:
+		if !m.hasPrefix(search, cn.prefix) {
+			if m.trace != nil {
+				tracef(m.trace, "%s %q rejected, %q doesn't match", cn.kind, cn.prefix, search)
 			}
-			for ; l < max && search[l] == cn.prefix[l]; l++ {
+			return nil
+		}
+		search = search[len(cn.prefix):]
+	case pkind:
+		// Issue #378
+		if n == len(m.pvalues) {
+			if m.trace != nil {
+				tracef(m.trace, "param %q skipped, no param slot left", cn.ppath)
 			}
+			return nil
 		}
-
-		if l == pl {
-			// Continue search
-			search = search[l:]
-		} else {
-			cn = nn
-			search = ns
-			if nk == pkind {
-				goto Param
-			} else if nk == akind {
-				goto Any
+		i := strings.IndexByte(search, '/')
+		if i < 0 {
+			i = len(search)
+		}
+		if i == 0 {
+			if m.trace != nil {
+				tracef(m.trace, "param rejected, %q has no value", search)
 			}
-			// Not found
-			return
+			return nil
+		}
+		m.pvalues[n] = search[:i]
+		n++
+		search = search[i:]
+	case akind:
+		m.pvalues[len(cn.pnames)-1] = search
+		if m.trace != nil {
+			tracef(m.trace, "any %q matched %q", cn.ppath, search)
 		}
+		return m.end(cn, "")
+	}
+	if m.trace != nil {
+		tracef(m.trace, "%s %q matched, remaining %q", cn.kind, cn.prefix, search)
+	}
 
-		if search == "" {
-			goto End
+	if search == "" {
+		if h := m.end(cn, ""); h != nil {
+			return h
 		}
+		// Dig further for any, might have an empty value for *, e.g.
+		// serving a directory. Issue #207.
+		if c := cn.findChildByKind(akind); c != nil {
+			m.pvalues[len(c.pnames)-1] = ""
+			return m.end(c, ", empty any")
+		}
+		return nil
+	}
 
-		// Static node
-		if c = cn.findChild(search[0], skind); c != nil {
-			// Save next
-			if cn.label == '/' {
-				nk = pkind
-				nn = cn
-				ns = search
+	// Static nodes, several with case-insensitive matching
+	for _, c := range cn.children {
+		if c.kind == skind && m.equal(search[0], c.label) {
+			if h := m.match(c, search, n); h != nil {
+				return h
 			}
-			cn = c
-			continue
 		}
-
-		// Param node
-		Param:
-		if c = cn.findChildByKind(pkind); c != nil {
-			// Issue #378
-			if len(pvalues) == n {
-				continue
+	}
+	for _, t := range [...]kind{pkind, akind} {
+		if c := cn.findChildByKind(t); c != nil {
+			if m.trace != nil {
+				tracef(m.trace, "try %s under %q with %q", t, cn.prefix, search)
 			}
-
-			// Save next
-			if cn.label == '/' {
-				nk = akind
-				nn = cn
-				ns = search
+			if h := m.match(c, search, n); h != nil {
+				return h
 			}
-
-			cn = c
-			i, l := 0, len(search)
-			for ; i < l && search[i] != '/'; i++ {
-			}
-			pvalues[n] = search[:i]
-			n++
-			search = search[i:]
-			continue
 		}
+	}
+	return nil
+}
 
-		// Any node
-		Any:
-		if cn = cn.findChildByKind(akind); cn == nil {
-			if nn != nil {
-				cn = nn
-				nn = nil // Next
-				search = ns
-				if nk == pkind {
-					goto Param
-				} else if nk == akind {
-					goto Any
-				}
-			}
-			// Not found
-			return
+// end returns cn if it has a handler for the method, or records it for 405 if
+// it has handlers for other methods.
+func (m *matcher) end(cn *node, note string) *node {
+	if cn.findHandler(m.method) != nil {
+		if m.trace != nil {
+			tracef(m.trace, "route %s %s%s", m.method, cn.ppath, note)
 		}
-		pvalues[len(cn.pnames) - 1] = search
-		goto End
+		return cn
 	}
+	if m.allowed == nil && cn.hasHandler() {
+		m.allowed = cn
+	}
+	return nil
+}
 
-	End:
-	context.SetHandler(cn.findHandler(method))
-	context.SetPath(cn.ppath)
-	context.SetParamNames(cn.pnames...)
-
+func (m *matcher) hasPrefix(s, prefix string) bool {
+	if len(s) < len(prefix) {
+		return false
+	}
+	if !m.fold {
+		return s[:len(prefix)] == prefix
+	}
+	for i := 0; i < len(prefix); i++ {
+		if lower(s[i]) != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
 
-	// NOTE: Slow zone...
-	if context.Handler() == nil {
-		context.SetHandler(cn.checkMethodNotAllowed())
+func (m *matcher) equal(b, label byte) bool {
+	if m.fold {
+		return lower(b) == label
+	}
+	return b == label
+}
 
-		// Dig further for any, might have an empty value for *, e.g.
-		// serving a directory. Issue #207.
-		if cn = cn.findChildByKind(akind); cn == nil {
-			return
-		}
-		if h := cn.findHandler(method); h != nil {
-			context.SetHandler(h)
-		} else {
-			context.SetHandler(cn.checkMethodNotAllowed())
-		}
-		context.SetPath(cn.ppath)
-		context.SetParamNames(cn.pnames...)
-		pvalues[len(cn.pnames) - 1] = ""
+// lower returns the lower case of an ASCII letter, b otherwise.
+func lower(b byte) byte {
+	if 'A' <= b && b <= 'Z' {
+		return b + 'a' - 'A'
 	}
+	return b
+}
+
+func tracef(trace *[]string, format string, a ...interface{}) {
+	*trace = append(*trace, fmt.Sprintf(format, a...))
+}
 
-	for i, name := range cn.pnames {
-		pmap[name]=pvalues[i]
+// String implements `fmt.Stringer` interface.
+func (k kind) String() string {
+	switch k {
+	case pkind:
+		return "param"
+	case akind:
+		return "any"
 	}
-	context.SetParamsMap(pmap)
-	return
+	return "static"
 }
@@ -0,0 +1,184 @@
+package leego
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouterParamsMap(t *testing.T) {
+	lee := New()
+	r := lee.router
+	r.Add(GET, "/users/:id/files/:name", func(Context) LeeError { return nil }, lee)
+	c := lee.NewContext(nil, nil).(*leegoContext)
+
+	r.Find(GET, "/users/1/files/a.txt", c)
+	assert.Equal(t, map[string]string{"id": "1", "name": "a.txt"}, c.GetParamsMap())
+
+	// Overridden map
+	c.SetParamsMap(map[string]string{"id": "2"})
+	assert.Equal(t, "2", c.GetParamsMap()["id"])
+
+	// Rebuilt on the next lookup
+	r.Find(GET, "/users/3/files/b.txt", c)
+	assert.Equal(t, map[string]string{"id": "3", "name": "b.txt"}, c.GetParamsMap())
+}
+
+func TestRouterAnyFallback(t *testing.T) {
+	lee := New()
+	r := lee.router
+	h := func(Context) LeeError { return nil }
+	r.Add(GET, "/v1*", h, lee)
+	r.Add(GET, "/v1/users", h, lee)
+	r.Add(GET, "/v1/users/:id", h, lee)
+	r.Add(POST, "/v2/users", h, lee)
+
+	for path, any := range map[string]string{
+		"/v1/missing":   "/missing",
+		"/v1/":          "/",
+		"/v1/users/1/x": "/users/1/x",
+		"/v1/uploads":   "/uploads",
+	} {
+		c := lee.NewContext(nil, nil).(*leegoContext)
+		r.Find(GET, path, c)
+		assert.Equal(t, "/v1*", c.Path())
+		assert.Equal(t, any, c.P(0))
+	}
+
+	c := lee.NewContext(nil, nil).(*leegoContext)
+	r.Find(GET, "/v1/users/1", c)
+	assert.Equal(t, "/v1/users/:id", c.Path())
+	assert.Equal(t, "1", c.Param("id"))
+}
+
+func TestRouterTrace(t *testing.T) {
+	lee := New()
+	r := lee.router
+	h := func(Context) LeeError { return nil }
+	r.Add(GET, "/users/new", h, lee)
+	r.Add(GET, "/users/:id", h, lee)
+	r.Add(GET, "/users/:id/files/*", h, lee)
+
+	var trace []string
+	c := lee.NewContext(nil, nil).(*leegoContext)
+	r.lookup(GET, "/users/nobody", c, &trace)
+	assert.Equal(t, "/users/:id", c.Path())
+	assert.Equal(t, "route GET /users/:id", trace[len(trace)-1])
+	var rejected bool
+	for _, s := range trace {
+		if strings.Contains(s, `static "new" rejected`) {
+			rejected = true
+		}
+	}
+	assert.True(t, rejected)
+
+	trace = nil
+	r.lookup(POST, "/users/1", c, &trace)
+	assert.Equal(t, `"/users/:id" has no POST handler, 405`, trace[len(trace)-1])
+}
+
+func TestRouterCustomMethod(t *testing.T) {
+	lee := New()
+	r := lee.router
+	h := func(Context) LeeError { return nil }
+	r.Add("PROPFIND", "/dav/:file", h, lee)
+
+	c := lee.NewContext(nil, nil).(*leegoContext)
+	r.Find("PROPFIND", "/dav/a.txt", c)
+	assert.Equal(t, "/dav/:file", c.Path())
+	assert.Equal(t, "a.txt", c.Param("file"))
+
+	_, _, code := r.find(GET, "/dav/a.txt", make([]string, *lee.maxParam), nil)
+	assert.Equal(t, 405, code)
+	_, _, code = r.find("REPORT", "/dav/a.txt", make([]string, *lee.maxParam), nil)
+	assert.Equal(t, 405, code)
+}
+
+func TestRouterPriority(t *testing.T) {
+	lee := New()
+	r := lee.router
+	h := func(Context) LeeError { return nil }
+	r.Add(GET, "/users/new", h, lee)
+	r.Add(GET, "/users/new/x", h, lee)
+	r.Add(GET, "/users/:id", h, lee)
+	r.Add(GET, "/users/:id/edit", h, lee)
+	r.Add(GET, "/static/*", h, lee)
+	r.Add(GET, "/static/:file/meta", h, lee)
+	r.Add(POST, "/orders/new", h, lee)
+	r.Add(GET, "/orders/:id", h, lee)
+
+	for path, route := range map[string][2]string{
+		"/users/new":       {"/users/new", ""},
+		"/users/news":      {"/users/:id", "news"},
+		"/users/ne":        {"/users/:id", "ne"},
+		"/users/new/edit":  {"/users/:id/edit", "new"},
+		"/users/1/edit":    {"/users/:id/edit", "1"},
+		"/users/new/x":     {"/users/new/x", ""},
+		"/static/a/meta":   {"/static/:file/meta", "a"},
+		"/static/a/metax":  {"/static/*", "a/metax"},
+		"/static/a/b/meta": {"/static/*", "a/b/meta"},
+		"/orders/new":      {"/orders/:id", "new"},
+	} {
+		c := lee.NewContext(nil, nil).(*leegoContext)
+		r.Find(GET, path, c)
+		assert.Equal(t, route[0], c.Path(), path)
+		assert.Equal(t, route[1], c.P(0), path)
+	}
+
+	_, _, code := r.find(GET, "/users//edit", make([]string, *lee.maxParam), nil)
+	assert.Equal(t, 404, code)
+	_, _, code = r.find(PUT, "/orders/new", make([]string, *lee.maxParam), nil)
+	assert.Equal(t, 405, code)
+}
+
+func TestRouterConflict(t *testing.T) {
+	lee := New()
+	h := func(Context) LeeError { return nil }
+	lee.GET("/users/:id", h)
+	lee.POST("/users/:id", h)
+	assert.Panics(t, func() {
+		lee.GET("/users/:id", h)
+	})
+	assert.Panics(t, func() {
+		lee.PUT("/users/:name", h)
+	})
+	assert.Panics(t, func() {
+		lee.Any("/users/:id", h)
+	})
+
+	// Predicate routes keep their fallback
+	lee.When(func(Context) bool { return true }, GET, "/items", h)
+	lee.GET("/items", h)
+	assert.Panics(t, func() {
+		lee.GET("/items", h)
+	})
+
+	// Group middleware added later
+	g := lee.Group("/admin")
+	g.Use(func(next HandlerFunc) HandlerFunc { return next })
+	assert.NotPanics(t, func() {
+		g.Use(func(next HandlerFunc) HandlerFunc { return next })
+	})
+}
+
+func TestRouterCaseInsensitive(t *testing.T) {
+	lee := New()
+	lee.SetCaseInsensitive(true)
+	r := lee.router
+	h := func(Context) LeeError { return nil }
+	r.Add(GET, "/Users/:ID/Files", h, lee)
+	assert.Panics(t, func() {
+		r.Add(POST, "/users/:ID/files", h, lee)
+	})
+
+	c := lee.NewContext(nil, nil).(*leegoContext)
+	r.Find(GET, "/USERS/Joe/files", c)
+	assert.Equal(t, "/Users/:ID/Files", c.Path())
+	assert.Equal(t, "Joe", c.Param("ID"))
+
+	lee = New()
+	lee.router.Add(GET, "/Users", h, lee)
+	_, _, code := lee.router.find(GET, "/users", make([]string, *lee.maxParam), nil)
+	assert.Equal(t, 404, code)
+}
@@ -0,0 +1,445 @@
+// Package saml is a SAML 2.0 service provider for SP-initiated single sign-on,
+// configurable per tenant, e.g.
+//
+//	g := lee.Group("/saml/:tenant")
+//	saml.Mount(g, saml.Config{
+//		ServiceProvider: func(c leego.Context) (*saml.ServiceProvider, error) {
+//			return tenants.SAML(c.Param("tenant"))
+//		},
+//		OnLogin: func(c leego.Context, a *saml.Assertion, relayState string) error {
+//			if err := middleware.SetSessionUser(c, a.NameID); err != nil {
+//				return err
+//			}
+//			return c.Redirect(http.StatusFound, "/")
+//		},
+//	})
+//
+// The login endpoint sends AuthnRequests with the HTTP-Redirect binding,
+// signed if the service provider has a key, and the ACS endpoint receives
+// responses with the HTTP-POST binding. Responses or assertions must be signed
+// by the identity provider with RSA-SHA256 and exclusive canonicalization;
+// encrypted assertions and IdP-initiated login are not supported.
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// Config defines the config for the SAML endpoints.
+	Config struct {
+		// ServiceProvider returns the service provider of the tenant of the
+		// request.
+		// Required.
+		ServiceProvider func(c leego.Context) (*ServiceProvider, error)
+
+		// OnLogin is called with the verified assertion and the relay state,
+		// e.g. to log the user in and redirect to the relay state.
+		// Required.
+		OnLogin func(c leego.Context, a *Assertion, relayState string) error
+
+		// RequestStore tracks the pending AuthnRequests, so that responses
+		// answer one of them, once.
+		// Optional. Default value an in-memory store.
+		RequestStore RequestStore
+
+		// RequestTimeout is the time the user has to log in at the identity
+		// provider.
+		// Optional. Default value 10 minutes.
+		RequestTimeout time.Duration `json:"request_timeout"`
+
+		// ClockSkew is the tolerance of the time conditions of assertions.
+		// Optional. Default value 1 minute.
+		ClockSkew time.Duration `json:"clock_skew"`
+	}
+
+	// ServiceProvider is the configuration of the service provider of a
+	// tenant and of its identity provider.
+	ServiceProvider struct {
+		// EntityID identifies the service provider, usually its metadata URL.
+		EntityID string
+
+		// ACSURL is the URL of the ACS endpoint.
+		ACSURL string
+
+		// Key signs the AuthnRequests if not nil.
+		Key *rsa.PrivateKey
+
+		// Certificate is the certificate of Key published in the metadata.
+		Certificate *x509.Certificate
+
+		// IdPEntityID is the entity ID of the identity provider, checked
+		// against the issuer of responses if not empty.
+		IdPEntityID string
+
+		// IdPSSOURL is the HTTP-Redirect single sign-on URL of the identity
+		// provider.
+		IdPSSOURL string
+
+		// IdPCertificates are the signing certificates of the identity
+		// provider, several during key rollovers.
+		IdPCertificates []*x509.Certificate
+	}
+
+	// Assertion is a verified assertion of the identity provider.
+	Assertion struct {
+		ID           string
+		Issuer       string
+		NameID       string
+		NameIDFormat string
+		SessionIndex string
+		Attributes   map[string][]string
+	}
+
+	// RequestStore is the storage of the pending AuthnRequests.
+	RequestStore interface {
+		// Save records the request id, pending until expires.
+		Save(id string, expires time.Time) error
+
+		// Take removes the request id and returns true if it was pending.
+		Take(id string) (bool, error)
+	}
+
+	// MemoryRequestStore is an in-memory `RequestStore`.
+	MemoryRequestStore struct {
+		mu       sync.Mutex
+		requests map[string]time.Time
+		lastScan time.Time
+		now      func() time.Time
+	}
+
+	sp struct {
+		config Config
+	}
+)
+
+// Namespaces
+const (
+	nsAssertion = "urn:oasis:names:tc:SAML:2.0:assertion"
+	nsProtocol  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	nsMetadata  = "urn:oasis:names:tc:SAML:2.0:metadata"
+
+	bindingPOST   = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	statusSuccess = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	bearer        = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+)
+
+var (
+	// DefaultConfig is the default SAML config.
+	DefaultConfig = Config{
+		RequestTimeout: 10 * time.Minute,
+		ClockSkew:      time.Minute,
+	}
+
+	// ErrInvalidResponse is returned when a SAML response fails
+	// verification.
+	ErrInvalidResponse = leego.NewHTTPError(http.StatusForbidden, "invalid saml response")
+)
+
+// Mount mounts the service provider endpoints on g:
+//
+//	GET  /metadata  the SP metadata to register at the identity provider
+//	GET  /login     redirects to the identity provider, with the RelayState
+//	                query param passed through
+//	POST /acs       the assertion consumer service
+func Mount(g *leego.Group, config Config) {
+	// Defaults
+	if config.ServiceProvider == nil || config.OnLogin == nil {
+		panic("saml requires service provider and on login")
+	}
+	if config.RequestStore == nil {
+		config.RequestStore = NewMemoryRequestStore()
+	}
+	if config.RequestTimeout == 0 {
+		config.RequestTimeout = DefaultConfig.RequestTimeout
+	}
+	if config.ClockSkew == 0 {
+		config.ClockSkew = DefaultConfig.ClockSkew
+	}
+	s := &sp{config: config}
+	g.GET("/metadata", s.metadata)
+	g.GET("/login", s.login)
+	g.POST("/acs", s.acs)
+}
+
+func (s *sp) metadata(c leego.Context) leego.LeeError {
+	p, err := s.config.ServiceProvider(c)
+	if err != nil {
+		return err
+	}
+	type keyDescriptor struct {
+		Use         string `xml:"use,attr"`
+		Certificate string `xml:"ds:KeyInfo>ds:X509Data>ds:X509Certificate"`
+	}
+	md := struct {
+		XMLName  xml.Name `xml:"md:EntityDescriptor"`
+		MD       string   `xml:"xmlns:md,attr"`
+		DS       string   `xml:"xmlns:ds,attr"`
+		EntityID string   `xml:"entityID,attr"`
+		SP       struct {
+			AuthnRequestsSigned  bool            `xml:"AuthnRequestsSigned,attr"`
+			WantAssertionsSigned bool            `xml:"WantAssertionsSigned,attr"`
+			Protocols            string          `xml:"protocolSupportEnumeration,attr"`
+			Keys                 []keyDescriptor `xml:"md:KeyDescriptor"`
+			ACS                  struct {
+				Binding  string `xml:"Binding,attr"`
+				Location string `xml:"Location,attr"`
+				Index    int    `xml:"index,attr"`
+			} `xml:"md:AssertionConsumerService"`
+		} `xml:"md:SPSSODescriptor"`
+	}{MD: nsMetadata, DS: nsDSig, EntityID: p.EntityID}
+	md.SP.AuthnRequestsSigned = p.Key != nil
+	md.SP.WantAssertionsSigned = true
+	md.SP.Protocols = nsProtocol
+	if p.Certificate != nil {
+		md.SP.Keys = append(md.SP.Keys, keyDescriptor{Use: "signing", Certificate: base64.StdEncoding.EncodeToString(p.Certificate.Raw)})
+	}
+	md.SP.ACS.Binding = bindingPOST
+	md.SP.ACS.Location = p.ACSURL
+	b, err := xml.Marshal(md)
+	if err != nil {
+		return err
+	}
+	return c.XMLBlob(http.StatusOK, b)
+}
+
+func (s *sp) login(c leego.Context) leego.LeeError {
+	p, err := s.config.ServiceProvider(c)
+	if err != nil {
+		return err
+	}
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+	if err = s.config.RequestStore.Save(id, time.Now().Add(s.config.RequestTimeout)); err != nil {
+		return err
+	}
+	req := `<samlp:AuthnRequest xmlns:samlp="` + nsProtocol + `" xmlns:saml="` + nsAssertion + `"` +
+		` ID="` + id + `" Version="2.0" IssueInstant="` + time.Now().UTC().Format(time.RFC3339) + `"` +
+		` Destination="` + escapeAttr(p.IdPSSOURL) + `" AssertionConsumerServiceURL="` + escapeAttr(p.ACSURL) + `"` +
+		` ProtocolBinding="` + bindingPOST + `"><saml:Issuer>` + escapeText(p.EntityID) + `</saml:Issuer></samlp:AuthnRequest>`
+	buf := new(bytes.Buffer)
+	fw, _ := flate.NewWriter(buf, flate.DefaultCompression)
+	fw.Write([]byte(req))
+	fw.Close()
+
+	// The signature covers the query in this order (SAML bindings 3.4.4.1)
+	q := "SAMLRequest=" + url.QueryEscape(base64.StdEncoding.EncodeToString(buf.Bytes()))
+	if rs := c.QueryParam("RelayState"); rs != "" {
+		q += "&RelayState=" + url.QueryEscape(rs)
+	}
+	if p.Key != nil {
+		q += "&SigAlg=" + url.QueryEscape(algRSASHA256)
+		h := sha256.Sum256([]byte(q))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, p.Key, crypto.SHA256, h[:])
+		if err != nil {
+			return err
+		}
+		q += "&Signature=" + url.QueryEscape(base64.StdEncoding.EncodeToString(sig))
+	}
+	sep := "?"
+	if strings.Contains(p.IdPSSOURL, "?") {
+		sep = "&"
+	}
+	return c.Redirect(http.StatusFound, p.IdPSSOURL+sep+q)
+}
+
+func (s *sp) acs(c leego.Context) leego.LeeError {
+	p, err := s.config.ServiceProvider(c)
+	if err != nil {
+		return err
+	}
+	b, err := decodeBase64(c.FormValue("SAMLResponse"))
+	if err != nil {
+		return leego.NewHTTPError(http.StatusBadRequest, "invalid saml response encoding")
+	}
+	a, err := s.verify(p, b, time.Now())
+	if err != nil {
+		return err
+	}
+	return s.config.OnLogin(c, a, c.FormValue("RelayState"))
+}
+
+// verify verifies the response b of the identity provider of p and returns
+// its assertion.
+func (s *sp) verify(p *ServiceProvider, b []byte, now time.Time) (*Assertion, error) {
+	resp, err := parseXML(b)
+	if err != nil || !resp.is(nsProtocol, "Response") {
+		return nil, ErrInvalidResponse
+	}
+	if d := resp.attr("Destination"); d != "" && d != p.ACSURL {
+		return nil, ErrInvalidResponse
+	}
+	status := resp.child(nsProtocol, "Status")
+	if status == nil {
+		return nil, ErrInvalidResponse
+	}
+	if code := status.child(nsProtocol, "StatusCode"); code == nil || code.attr("Value") != statusSuccess {
+		return nil, leego.NewHTTPError(http.StatusForbidden, "saml login failed")
+	}
+	assertions := resp.children(nsAssertion, "Assertion")
+	if len(assertions) != 1 || resp.child(nsAssertion, "EncryptedAssertion") != nil {
+		return nil, ErrInvalidResponse
+	}
+	an := assertions[0]
+
+	// The response or the assertion is signed, any signature must be valid
+	signed := false
+	for _, n := range []*xmlNode{resp, an} {
+		switch err = verifySignature(n, p.IdPCertificates); err {
+		case nil:
+			signed = true
+		case errUnsigned:
+		default:
+			return nil, ErrInvalidResponse
+		}
+	}
+	if !signed {
+		return nil, ErrInvalidResponse
+	}
+
+	a := &Assertion{ID: an.attr("ID"), Attributes: make(map[string][]string)}
+	if issuer := an.child(nsAssertion, "Issuer"); issuer != nil {
+		a.Issuer = issuer.content()
+	}
+	if p.IdPEntityID != "" && a.Issuer != p.IdPEntityID {
+		return nil, ErrInvalidResponse
+	}
+
+	// Subject, confirmed as bearer of the response to our request
+	subject := an.child(nsAssertion, "Subject")
+	if subject == nil {
+		return nil, ErrInvalidResponse
+	}
+	if id := subject.child(nsAssertion, "NameID"); id != nil {
+		a.NameID, a.NameIDFormat = id.content(), id.attr("Format")
+	}
+	inResponseTo := ""
+	for _, sc := range subject.children(nsAssertion, "SubjectConfirmation") {
+		d := sc.child(nsAssertion, "SubjectConfirmationData")
+		if sc.attr("Method") != bearer || d == nil || d.attr("Recipient") != p.ACSURL ||
+			!s.valid(now, "", d.attr("NotOnOrAfter")) {
+			continue
+		}
+		inResponseTo = d.attr("InResponseTo")
+		break
+	}
+	if a.NameID == "" || inResponseTo == "" {
+		return nil, ErrInvalidResponse
+	}
+	if r := resp.attr("InResponseTo"); r != "" && r != inResponseTo {
+		return nil, ErrInvalidResponse
+	}
+
+	// Conditions
+	cond := an.child(nsAssertion, "Conditions")
+	if cond == nil || !s.valid(now, cond.attr("NotBefore"), cond.attr("NotOnOrAfter")) {
+		return nil, ErrInvalidResponse
+	}
+	for _, ar := range cond.children(nsAssertion, "AudienceRestriction") {
+		ok := false
+		for _, au := range ar.children(nsAssertion, "Audience") {
+			ok = ok || au.content() == p.EntityID
+		}
+		if !ok {
+			return nil, ErrInvalidResponse
+		}
+	}
+
+	if as := an.child(nsAssertion, "AuthnStatement"); as != nil {
+		a.SessionIndex = as.attr("SessionIndex")
+	}
+	for _, st := range an.children(nsAssertion, "AttributeStatement") {
+		for _, attr := range st.children(nsAssertion, "Attribute") {
+			name := attr.attr("Name")
+			for _, v := range attr.children(nsAssertion, "AttributeValue") {
+				a.Attributes[name] = append(a.Attributes[name], v.content())
+			}
+		}
+	}
+
+	// Last, so that invalid responses don't consume the request
+	ok, err := s.config.RequestStore.Take(inResponseTo)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidResponse
+	}
+	return a, nil
+}
+
+// valid reports whether now is within the optional notBefore and notOnOrAfter
+// times, give or take the clock skew.
+func (s *sp) valid(now time.Time, notBefore, notOnOrAfter string) bool {
+	if notBefore != "" {
+		t, err := time.Parse(time.RFC3339, notBefore)
+		if err != nil || now.Before(t.Add(-s.config.ClockSkew)) {
+			return false
+		}
+	}
+	if notOnOrAfter != "" {
+		t, err := time.Parse(time.RFC3339, notOnOrAfter)
+		if err != nil || !now.Before(t.Add(s.config.ClockSkew)) {
+			return false
+		}
+	}
+	return true
+}
+
+func newID() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	// IDs must not start with a digit
+	return "_" + hex.EncodeToString(b), nil
+}
+
+// NewMemoryRequestStore returns an in-memory request store.
+func NewMemoryRequestStore() *MemoryRequestStore {
+	return &MemoryRequestStore{requests: make(map[string]time.Time), now: time.Now}
+}
+
+// Save implements `RequestStore#Save()`. Expired requests are removed every
+// minute on the way.
+func (s *MemoryRequestStore) Save(id string, expires time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now := s.now(); now.Sub(s.lastScan) >= time.Minute {
+		for id, e := range s.requests {
+			if !now.Before(e) {
+				delete(s.requests, id)
+			}
+		}
+		s.lastScan = now
+	}
+	s.requests[id] = expires
+	return nil
+}
+
+// Take implements `RequestStore#Take()`.
+func (s *MemoryRequestStore) Take(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.requests[id]
+	delete(s.requests, id)
+	return ok && s.now().Before(e), nil
+}
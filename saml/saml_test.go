@@ -0,0 +1,187 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalize(t *testing.T) {
+	for in, out := range map[string]string{
+		// Exclusive XML Canonicalization 1.0, section 2.2
+		`<n0:local xmlns:n0="foo:bar" xmlns:n3="ftp://example.org"><n1:elem2 xmlns:n1="http://example.net" xml:lang="en"><n3:stuff xmlns:n3="ftp://example.org"/></n1:elem2></n0:local>`: `<n1:elem2 xmlns:n1="http://example.net" xml:lang="en"><n3:stuff xmlns:n3="ftp://example.org"></n3:stuff></n1:elem2>`,
+		`<r><a xmlns="urn:x" b="2" a="1" xmlns:p="urn:p" xmlns:q="urn:q" p:c="&amp;&#9;"><b xmlns="">x &gt; y</b></a></r>`:                                                               `<a xmlns="urn:x" xmlns:p="urn:p" a="1" b="2" p:c="&amp;&#x9;"><b xmlns="">x &gt; y</b></a>`,
+	} {
+		n, err := parseXML([]byte(in))
+		if assert.NoError(t, err) {
+			assert.Equal(t, out, string(canonicalize(n.nodes[0], nil, nil)))
+		}
+	}
+	for _, in := range []string{
+		`<!DOCTYPE r [<!ENTITY e "e">]><r>&e;</r>`,
+		`</a>`,
+		`<a></a></b>`,
+		`<a><b></a></b>`,
+		`<a><b></b>`,
+	} {
+		_, err := parseXML([]byte(in))
+		assert.Error(t, err, in)
+	}
+}
+
+// sign inserts an enveloped signature of the element id of doc after its
+// issuer.
+func sign(t *testing.T, key *rsa.PrivateKey, doc, id string) string {
+	n, err := parseXML([]byte(doc))
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	var find func(n *xmlNode) *xmlNode
+	find = func(n *xmlNode) *xmlNode {
+		if !n.isText && n.attr("ID") == id {
+			return n
+		}
+		for _, c := range n.nodes {
+			if f := find(c); f != nil {
+				return f
+			}
+		}
+		return nil
+	}
+	digest := sha256.Sum256(canonicalize(find(n), nil, nil))
+	signedInfo := `<ds:SignedInfo xmlns:ds="` + nsDSig + `">` +
+		`<ds:CanonicalizationMethod Algorithm="` + algExcC14N + `"/>` +
+		`<ds:SignatureMethod Algorithm="` + algRSASHA256 + `"/>` +
+		`<ds:Reference URI="#` + id + `"><ds:Transforms>` +
+		`<ds:Transform Algorithm="` + algEnvelope + `"/><ds:Transform Algorithm="` + algExcC14N + `"/>` +
+		`</ds:Transforms><ds:DigestMethod Algorithm="` + algSHA256 + `"/>` +
+		`<ds:DigestValue>` + base64.StdEncoding.EncodeToString(digest[:]) + `</ds:DigestValue>` +
+		`</ds:Reference></ds:SignedInfo>`
+	si, _ := parseXML([]byte(signedInfo))
+	h := sha256.Sum256(canonicalize(si, nil, nil))
+	value, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+	sig := `<ds:Signature xmlns:ds="` + nsDSig + `">` + strings.Replace(signedInfo, ` xmlns:ds="`+nsDSig+`"`, "", 1) +
+		"<ds:SignatureValue>\n" + base64.StdEncoding.EncodeToString(value) + "\n</ds:SignatureValue></ds:Signature>"
+	i := strings.Index(doc, `ID="`+id+`"`)
+	j := i + strings.Index(doc[i:], "</saml:Issuer>") + len("</saml:Issuer>")
+	return doc[:j] + sig + doc[j:]
+}
+
+func TestSAML(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 1024)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	cert, _ := x509.ParseCertificate(der)
+	p := &ServiceProvider{
+		EntityID:        "https://sp.example.com/saml/acme/metadata",
+		ACSURL:          "https://sp.example.com/saml/acme/acs",
+		Key:             key,
+		IdPEntityID:     "https://idp.example.com",
+		IdPSSOURL:       "https://idp.example.com/sso",
+		IdPCertificates: []*x509.Certificate{cert},
+	}
+
+	lee := leego.New()
+	Mount(lee.Group("/saml/:tenant"), Config{
+		ServiceProvider: func(c leego.Context) (*ServiceProvider, error) {
+			if c.Param("tenant") != "acme" {
+				return nil, leego.ErrNotFound
+			}
+			return p, nil
+		},
+		OnLogin: func(c leego.Context, a *Assertion, relayState string) error {
+			return c.String(http.StatusOK, a.NameID+" "+strings.Join(a.Attributes["role"], ",")+" "+relayState)
+		},
+	})
+	request := func(method, path string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Header.Set(leego.HeaderContentType, leego.MIMEApplicationForm)
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		return rec
+	}
+
+	rec := request(leego.GET, "/saml/acme/metadata", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `entityID="https://sp.example.com/saml/acme/metadata"`)
+	assert.Contains(t, rec.Body.String(), `Location="https://sp.example.com/saml/acme/acs"`)
+	assert.Equal(t, http.StatusNotFound, request(leego.GET, "/saml/other/metadata", nil).Code)
+
+	// AuthnRequest
+	rec = request(leego.GET, "/saml/acme/login?RelayState=%2Fhome", nil)
+	assert.Equal(t, http.StatusFound, rec.Code)
+	u, _ := url.Parse(rec.Header().Get(leego.HeaderLocation))
+	assert.Equal(t, "idp.example.com", u.Host)
+	assert.Equal(t, "/home", u.Query().Get("RelayState"))
+	signed := u.RawQuery[:strings.Index(u.RawQuery, "&Signature=")]
+	sig, _ := base64.StdEncoding.DecodeString(u.Query().Get("Signature"))
+	h := sha256.Sum256([]byte(signed))
+	assert.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, h[:], sig))
+	deflated, _ := base64.StdEncoding.DecodeString(u.Query().Get("SAMLRequest"))
+	authnRequest, _ := ioutil.ReadAll(flate.NewReader(bytes.NewReader(deflated)))
+	requestID := regexp.MustCompile(` ID="([^"]+)"`).FindStringSubmatch(string(authnRequest))[1]
+
+	// Response
+	now := time.Now().UTC()
+	response := func(nameID string) string {
+		return `<samlp:Response xmlns:samlp="` + nsProtocol + `" ID="_r1" Version="2.0" InResponseTo="` + requestID + `" Destination="` + p.ACSURL + `">` +
+			`<samlp:Status><samlp:StatusCode Value="` + statusSuccess + `"/></samlp:Status>` +
+			`<saml:Assertion xmlns:saml="` + nsAssertion + `" ID="_a1" Version="2.0">` +
+			`<saml:Issuer>https://idp.example.com</saml:Issuer>` +
+			`<saml:Subject><saml:NameID Format="urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress">` + nameID + `</saml:NameID>` +
+			`<saml:SubjectConfirmation Method="` + bearer + `"><saml:SubjectConfirmationData InResponseTo="` + requestID + `" Recipient="` + p.ACSURL + `" NotOnOrAfter="` + now.Add(5*time.Minute).Format(time.RFC3339) + `"/></saml:SubjectConfirmation>` +
+			`</saml:Subject>` +
+			`<saml:Conditions NotBefore="` + now.Add(-time.Minute).Format(time.RFC3339) + `" NotOnOrAfter="` + now.Add(5*time.Minute).Format(time.RFC3339) + `">` +
+			`<saml:AudienceRestriction><saml:Audience>` + p.EntityID + `</saml:Audience></saml:AudienceRestriction></saml:Conditions>` +
+			`<saml:AuthnStatement SessionIndex="s1"/>` +
+			`<saml:AttributeStatement><saml:Attribute Name="role"><saml:AttributeValue>admin</saml:AttributeValue><saml:AttributeValue>dev</saml:AttributeValue></saml:Attribute></saml:AttributeStatement>` +
+			`</saml:Assertion></samlp:Response>`
+	}
+	post := func(doc string) *httptest.ResponseRecorder {
+		return request(leego.POST, "/saml/acme/acs", url.Values{
+			"SAMLResponse": {base64.StdEncoding.EncodeToString([]byte(doc))},
+			"RelayState":   {"/home"},
+		})
+	}
+
+	// Unsigned and tampered
+	assert.Equal(t, http.StatusForbidden, post(response("joe@example.com")).Code)
+	doc := sign(t, key, response("joe@example.com"), "_a1")
+	assert.Equal(t, http.StatusForbidden, post(strings.Replace(doc, "joe@", "admin@", 1)).Code)
+
+	rec = post(doc)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "joe@example.com admin,dev /home", rec.Body.String())
+
+	// Replay
+	assert.Equal(t, http.StatusForbidden, post(doc).Code)
+
+	// Malformed
+	for _, doc := range []string{`</a>`, `<a></a></b>`, `<a>`} {
+		assert.Equal(t, http.StatusForbidden, post(doc).Code, doc)
+	}
+}
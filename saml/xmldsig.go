@@ -0,0 +1,368 @@
+package saml
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+)
+
+type (
+	// xmlNode is an element or a text node of a parsed document, keeping the
+	// namespace prefixes needed by canonicalization.
+	xmlNode struct {
+		space  string // Namespace URI
+		prefix string
+		local  string
+		attrs  []xmlAttr
+		scope  map[string]string // In-scope namespaces by prefix
+		nodes  []*xmlNode
+		text   string
+		isText bool
+	}
+
+	xmlAttr struct {
+		space  string
+		prefix string
+		local  string
+		value  string
+	}
+)
+
+// Namespaces and algorithms
+const (
+	nsXML        = "http://www.w3.org/XML/1998/namespace"
+	nsDSig       = "http://www.w3.org/2000/09/xmldsig#"
+	algExcC14N   = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	algEnvelope  = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	algRSASHA256 = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	algSHA256    = "http://www.w3.org/2001/04/xmlenc#sha256"
+)
+
+var (
+	errInvalidXML       = errors.New("saml: invalid xml")
+	errUnsigned         = errors.New("saml: unsigned")
+	errInvalidSignature = errors.New("saml: invalid signature")
+)
+
+// parseXML parses a document, rejecting DTDs.
+func parseXML(b []byte) (*xmlNode, error) {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	var root *xmlNode
+	stack := []*xmlNode{}
+	for {
+		t, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errInvalidXML
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			scope := map[string]string{"xml": nsXML}
+			if len(stack) > 0 {
+				scope = stack[len(stack)-1].scope
+			} else if root != nil {
+				return nil, errInvalidXML
+			}
+			n := &xmlNode{prefix: t.Name.Space, local: t.Name.Local, scope: scope}
+			for _, a := range t.Attr {
+				switch {
+				case a.Name.Space == "xmlns":
+					n.declare(a.Name.Local, a.Value)
+				case a.Name.Space == "" && a.Name.Local == "xmlns":
+					n.declare("", a.Value)
+				default:
+					n.attrs = append(n.attrs, xmlAttr{prefix: a.Name.Space, local: a.Name.Local, value: a.Value})
+				}
+			}
+			var ok bool
+			if n.space, ok = n.scope[n.prefix]; !ok && n.prefix != "" {
+				return nil, errInvalidXML
+			}
+			for i, a := range n.attrs {
+				if a.prefix == "" {
+					continue
+				}
+				if n.attrs[i].space, ok = n.scope[a.prefix]; !ok {
+					return nil, errInvalidXML
+				}
+			}
+			if len(stack) > 0 {
+				p := stack[len(stack)-1]
+				p.nodes = append(p.nodes, n)
+			} else {
+				root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			// RawToken doesn't check that the end tags match.
+			if len(stack) == 0 {
+				return nil, errInvalidXML
+			}
+			if n := stack[len(stack)-1]; n.prefix != t.Name.Space || n.local != t.Name.Local {
+				return nil, errInvalidXML
+			}
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				p := stack[len(stack)-1]
+				p.nodes = append(p.nodes, &xmlNode{text: string(t), isText: true})
+			}
+		case xml.Directive:
+			return nil, errInvalidXML
+		}
+	}
+	if root == nil || len(stack) > 0 {
+		return nil, errInvalidXML
+	}
+	return root, nil
+}
+
+// declare declares a namespace on n, copying the scope of its parent.
+func (n *xmlNode) declare(prefix, uri string) {
+	scope := make(map[string]string, len(n.scope)+1)
+	for p, u := range n.scope {
+		scope[p] = u
+	}
+	scope[prefix] = uri
+	n.scope = scope
+}
+
+func (n *xmlNode) is(space, local string) bool {
+	return !n.isText && n.space == space && n.local == local
+}
+
+// child returns the first child element space:local, nil if there is none.
+func (n *xmlNode) child(space, local string) *xmlNode {
+	for _, c := range n.nodes {
+		if c.is(space, local) {
+			return c
+		}
+	}
+	return nil
+}
+
+// children returns the child elements space:local.
+func (n *xmlNode) children(space, local string) []*xmlNode {
+	var nodes []*xmlNode
+	for _, c := range n.nodes {
+		if c.is(space, local) {
+			nodes = append(nodes, c)
+		}
+	}
+	return nodes
+}
+
+// attr returns the value of the attribute local without namespace.
+func (n *xmlNode) attr(local string) string {
+	for _, a := range n.attrs {
+		if a.space == "" && a.local == local {
+			return a.value
+		}
+	}
+	return ""
+}
+
+// content returns the concatenated text of n, trimmed.
+func (n *xmlNode) content() string {
+	var b strings.Builder
+	for _, c := range n.nodes {
+		if c.isText {
+			b.WriteString(c.text)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// canonicalize writes the exclusive canonical form of n without comments
+// (Exclusive XML Canonicalization 1.0), omitting the node skip. inclusive are
+// the prefixes of the InclusiveNamespaces PrefixList.
+func canonicalize(n *xmlNode, inclusive []string, skip *xmlNode) []byte {
+	buf := new(bytes.Buffer)
+	c14n(buf, n, map[string]string{}, inclusive, skip)
+	return buf.Bytes()
+}
+
+func c14n(w *bytes.Buffer, n *xmlNode, rendered map[string]string, inclusive []string, skip *xmlNode) {
+	if n.isText {
+		w.WriteString(escapeText(n.text))
+		return
+	}
+
+	// Namespaces visibly utilized and not already rendered by an ancestor
+	used := map[string]bool{n.prefix: true}
+	for _, a := range n.attrs {
+		if a.prefix != "" && a.prefix != "xml" {
+			used[a.prefix] = true
+		}
+	}
+	for _, p := range inclusive {
+		if p == "#default" {
+			p = ""
+		}
+		if _, ok := n.scope[p]; ok {
+			used[p] = true
+		}
+	}
+	prefixes := make([]string, 0, len(used))
+	for p := range used {
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(prefixes)
+	var decls []string
+	next, copied := rendered, false
+	for _, p := range prefixes {
+		uri := n.scope[p]
+		r, ok := rendered[p]
+		if p == "" && uri == "" && (!ok || r == "") || ok && r == uri {
+			continue
+		}
+		if !copied {
+			next, copied = make(map[string]string, len(rendered)+1), true
+			for k, v := range rendered {
+				next[k] = v
+			}
+		}
+		next[p] = uri
+		if p == "" {
+			decls = append(decls, ` xmlns="`+escapeAttr(uri)+`"`)
+		} else {
+			decls = append(decls, ` xmlns:`+p+`="`+escapeAttr(uri)+`"`)
+		}
+	}
+
+	attrs := append([]xmlAttr{}, n.attrs...)
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].space != attrs[j].space {
+			return attrs[i].space < attrs[j].space
+		}
+		return attrs[i].local < attrs[j].local
+	})
+
+	name := n.local
+	if n.prefix != "" {
+		name = n.prefix + ":" + n.local
+	}
+	w.WriteString("<" + name)
+	for _, d := range decls {
+		w.WriteString(d)
+	}
+	for _, a := range attrs {
+		w.WriteByte(' ')
+		if a.prefix != "" {
+			w.WriteString(a.prefix + ":")
+		}
+		w.WriteString(a.local + `="` + escapeAttr(a.value) + `"`)
+	}
+	w.WriteByte('>')
+	for _, c := range n.nodes {
+		if c != skip {
+			c14n(w, c, next, inclusive, skip)
+		}
+	}
+	w.WriteString("</" + name + ">")
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func escapeAttr(s string) string {
+	return attrEscaper.Replace(s)
+}
+
+// verifySignature verifies the enveloped signature of n with one of certs.
+// The signature must reference n by its ID, so that the verified element is
+// the one used, against signature wrapping.
+func verifySignature(n *xmlNode, certs []*x509.Certificate) error {
+	sig := n.child(nsDSig, "Signature")
+	if sig == nil {
+		return errUnsigned
+	}
+	si := sig.child(nsDSig, "SignedInfo")
+	if si == nil {
+		return errInvalidSignature
+	}
+	cm := si.child(nsDSig, "CanonicalizationMethod")
+	sm := si.child(nsDSig, "SignatureMethod")
+	refs := si.children(nsDSig, "Reference")
+	if cm == nil || cm.attr("Algorithm") != algExcC14N || sm == nil || sm.attr("Algorithm") != algRSASHA256 || len(refs) != 1 {
+		return errInvalidSignature
+	}
+	ref := refs[0]
+	if id := n.attr("ID"); id == "" || ref.attr("URI") != "#"+id {
+		return errInvalidSignature
+	}
+
+	// Digest of n
+	var inclusive []string
+	if ts := ref.child(nsDSig, "Transforms"); ts != nil {
+		for _, t := range ts.children(nsDSig, "Transform") {
+			switch t.attr("Algorithm") {
+			case algEnvelope:
+			case algExcC14N:
+				inclusive = prefixList(t)
+			default:
+				return errInvalidSignature
+			}
+		}
+	}
+	dm := ref.child(nsDSig, "DigestMethod")
+	dv := ref.child(nsDSig, "DigestValue")
+	if dm == nil || dm.attr("Algorithm") != algSHA256 || dv == nil {
+		return errInvalidSignature
+	}
+	digest, err := decodeBase64(dv.content())
+	if err != nil {
+		return errInvalidSignature
+	}
+	h := sha256.Sum256(canonicalize(n, inclusive, sig))
+	if !bytes.Equal(h[:], digest) {
+		return errInvalidSignature
+	}
+
+	// Signature of SignedInfo
+	sv := sig.child(nsDSig, "SignatureValue")
+	if sv == nil {
+		return errInvalidSignature
+	}
+	value, err := decodeBase64(sv.content())
+	if err != nil {
+		return errInvalidSignature
+	}
+	h = sha256.Sum256(canonicalize(si, prefixList(cm), nil))
+	for _, cert := range certs {
+		if k, ok := cert.PublicKey.(*rsa.PublicKey); ok && rsa.VerifyPKCS1v15(k, crypto.SHA256, h[:], value) == nil {
+			return nil
+		}
+	}
+	return errInvalidSignature
+}
+
+// prefixList returns the InclusiveNamespaces PrefixList of a canonicalization
+// method or transform.
+func prefixList(n *xmlNode) []string {
+	if in := n.child(algExcC14N, "InclusiveNamespaces"); in != nil {
+		return strings.Fields(in.attr("PrefixList"))
+	}
+	return nil
+}
+
+// decodeBase64 decodes standard base64, ignoring whitespace.
+func decodeBase64(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+}
@@ -0,0 +1,102 @@
+package leego
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Query parameters of signed URLs
+const (
+	signedURLExpires   = "expires"
+	signedURLSignature = "signature"
+)
+
+var (
+	// ErrInvalidSignature is returned by `Leego#VerifyURL()` for URLs not
+	// signed with the URL signing key.
+	ErrInvalidSignature = NewHTTPError(http.StatusForbidden, "invalid signature")
+
+	// ErrURLExpired is returned by `Leego#VerifyURL()` for expired URLs.
+	ErrURLExpired = NewHTTPError(http.StatusForbidden, "url expired")
+)
+
+// SetURLSigningKey sets the key signing URLs, see `Leego#SignURL()`.
+func (e *Leego) SetURLSigningKey(key []byte) {
+	e.urlSigningKey = key
+}
+
+// SignURL generates a URL from the route named name like `Leego#Reverse()`,
+// signed to be valid for ttl, e.g. to share a link or register a callback
+// without session:
+//
+//	lee.SignURL("invoice.download", []interface{}{42}, time.Hour)
+//	// "/invoices/42/download?expires=1500000000&signature=..."
+//
+// It returns "" if there is no such route. See `middleware.SignedURL()` to
+// verify the URLs.
+func (e *Leego) SignURL(name string, params []interface{}, ttl time.Duration) string {
+	uri := e.Reverse(name, params...)
+	if uri == "" {
+		return ""
+	}
+	return e.SignURI(uri, ttl)
+}
+
+// SignURI signs uri, a path with an optional query, to be valid for ttl. It
+// panics if no URL signing key is set.
+func (e *Leego) SignURI(uri string, ttl time.Duration) string {
+	if len(e.urlSigningKey) == 0 {
+		panic("leego: url signing key not set")
+	}
+	u, err := url.ParseRequestURI(uri)
+	if err != nil {
+		panic("leego: invalid uri to sign: " + err.Error())
+	}
+	q := u.Query()
+	q.Del(signedURLSignature)
+	q.Set(signedURLExpires, strconv.FormatInt(time.Now().Add(ttl).Unix(), 10))
+	q.Set(signedURLSignature, e.signURL(u.EscapedPath(), q))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// VerifyURL verifies that uri was signed by `Leego#SignURI()` and hasn't
+// expired.
+func (e *Leego) VerifyURL(uri string) error {
+	if len(e.urlSigningKey) == 0 {
+		return ErrInvalidSignature
+	}
+	u, err := url.ParseRequestURI(uri)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	q := u.Query()
+	sig := q[signedURLSignature]
+	if len(sig) != 1 {
+		return ErrInvalidSignature
+	}
+	q.Del(signedURLSignature)
+	if !hmac.Equal([]byte(sig[0]), []byte(e.signURL(u.EscapedPath(), q))) {
+		return ErrInvalidSignature
+	}
+	expires, err := strconv.ParseInt(q.Get(signedURLExpires), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if time.Now().Unix() >= expires {
+		return ErrURLExpired
+	}
+	return nil
+}
+
+// signURL returns the signature of path with the query q, without signature.
+func (e *Leego) signURL(path string, q url.Values) string {
+	mac := hmac.New(sha256.New, e.urlSigningKey)
+	mac.Write([]byte(path + "?" + q.Encode()))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,99 @@
+package twofactor
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errInvalidCBOR = errors.New("invalid cbor")
+
+// decodeCBOR decodes the first CBOR item of b, the subset used by WebAuthn,
+// and returns the rest of b. Unsigned and negative integers are decoded as
+// int64, byte strings as []byte, text strings as string, arrays as
+// []interface{}, maps as map[interface{}]interface{} and simple values as
+// bool or nil. Floats and tags are not supported.
+func decodeCBOR(b []byte) (interface{}, []byte, error) {
+	if len(b) == 0 {
+		return nil, nil, errInvalidCBOR
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+	b = b[1:]
+	var n uint64
+	switch {
+	case info < 24:
+		n = uint64(info)
+	case info == 24 && len(b) >= 1:
+		n, b = uint64(b[0]), b[1:]
+	case info == 25 && len(b) >= 2:
+		n, b = uint64(binary.BigEndian.Uint16(b)), b[2:]
+	case info == 26 && len(b) >= 4:
+		n, b = uint64(binary.BigEndian.Uint32(b)), b[4:]
+	case info == 27 && len(b) >= 8:
+		n, b = binary.BigEndian.Uint64(b), b[8:]
+	default:
+		return nil, nil, errInvalidCBOR
+	}
+
+	switch major {
+	case 0:
+		if n > 1<<63-1 {
+			return nil, nil, errInvalidCBOR
+		}
+		return int64(n), b, nil
+	case 1:
+		if n > 1<<63-1 {
+			return nil, nil, errInvalidCBOR
+		}
+		return -1 - int64(n), b, nil
+	case 2, 3:
+		if uint64(len(b)) < n {
+			return nil, nil, errInvalidCBOR
+		}
+		if major == 2 {
+			return b[:n], b[n:], nil
+		}
+		return string(b[:n]), b[n:], nil
+	case 4:
+		if uint64(len(b)) < n {
+			return nil, nil, errInvalidCBOR
+		}
+		a := make([]interface{}, n)
+		for i := range a {
+			var err error
+			if a[i], b, err = decodeCBOR(b); err != nil {
+				return nil, nil, err
+			}
+		}
+		return a, b, nil
+	case 5:
+		if uint64(len(b)) < 2*n {
+			return nil, nil, errInvalidCBOR
+		}
+		m := make(map[interface{}]interface{}, n)
+		for i := uint64(0); i < n; i++ {
+			k, rest, err := decodeCBOR(b)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errInvalidCBOR
+			}
+			if m[k], b, err = decodeCBOR(rest); err != nil {
+				return nil, nil, err
+			}
+		}
+		return m, b, nil
+	case 7:
+		switch info {
+		case 20:
+			return false, b, nil
+		case 21:
+			return true, b, nil
+		case 22:
+			return nil, b, nil
+		}
+	}
+	return nil, nil, errInvalidCBOR
+}
@@ -0,0 +1,97 @@
+package twofactor
+
+import (
+	"bytes"
+	"sync"
+)
+
+// MemoryStore is an in-memory `TOTPStore` and `WebAuthnStore`.
+type MemoryStore struct {
+	mu          sync.Mutex
+	totp        map[string]TOTPSecret
+	credentials map[string][]WebAuthnCredential
+	challenges  map[string]WebAuthnChallenge
+}
+
+// NewMemoryStore returns an in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		totp:        make(map[string]TOTPSecret),
+		credentials: make(map[string][]WebAuthnCredential),
+		challenges:  make(map[string]WebAuthnChallenge),
+	}
+}
+
+// TOTP implements `TOTPStore#TOTP()`.
+func (s *MemoryStore) TOTP(userID string) (*TOTPSecret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.totp[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &t, nil
+}
+
+// SaveTOTP implements `TOTPStore#SaveTOTP()`.
+func (s *MemoryStore) SaveTOTP(userID string, t *TOTPSecret) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.totp[userID] = *t
+	return nil
+}
+
+// DeleteTOTP implements `TOTPStore#DeleteTOTP()`.
+func (s *MemoryStore) DeleteTOTP(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.totp, userID)
+	return nil
+}
+
+// Credentials implements `WebAuthnStore#Credentials()`.
+func (s *MemoryStore) Credentials(userID string) ([]*WebAuthnCredential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	creds := make([]*WebAuthnCredential, len(s.credentials[userID]))
+	for i := range creds {
+		c := s.credentials[userID][i]
+		creds[i] = &c
+	}
+	return creds, nil
+}
+
+// SaveCredential implements `WebAuthnStore#SaveCredential()`.
+func (s *MemoryStore) SaveCredential(c *WebAuthnCredential) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	creds := s.credentials[c.UserID]
+	for i := range creds {
+		if bytes.Equal(creds[i].ID, c.ID) {
+			creds[i] = *c
+			return nil
+		}
+	}
+	s.credentials[c.UserID] = append(creds, *c)
+	return nil
+}
+
+// SaveChallenge implements `WebAuthnStore#SaveChallenge()`.
+func (s *MemoryStore) SaveChallenge(userID string, ch *WebAuthnChallenge) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.challenges[userID] = *ch
+	return nil
+}
+
+// TakeChallenge implements `WebAuthnStore#TakeChallenge()`.
+func (s *MemoryStore) TakeChallenge(userID string) (*WebAuthnChallenge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch, ok := s.challenges[userID]
+	if !ok {
+		return nil, nil
+	}
+	delete(s.challenges, userID)
+	return &ch, nil
+}
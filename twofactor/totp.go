@@ -0,0 +1,376 @@
+// Package twofactor provides second-factor authentication endpoints, TOTP
+// (authenticator apps) and WebAuthn (security keys and platform
+// authenticators), mountable on a group of routes whose middleware
+// authenticates the first factor, e.g.
+//
+//	g := lee.Group("/2fa", middleware.Session(sessions), requireLogin)
+//	twofactor.MountTOTP(g, twofactor.TOTPConfig{
+//		Issuer: "Example",
+//		Store:  store,
+//		UserID: func(c leego.Context) (string, error) {
+//			return middleware.SessionFrom(c).UserID, nil
+//		},
+//		OnVerified: func(c leego.Context, userID string) error {
+//			middleware.SessionFrom(c).Values["2fa"] = "ok"
+//			return nil
+//		},
+//	})
+package twofactor
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// TOTPConfig defines the config for the TOTP endpoints.
+	TOTPConfig struct {
+		// Issuer names the service in authenticator apps.
+		// Required.
+		Issuer string `json:"issuer"`
+
+		// Store keeps the TOTP secrets of the users.
+		// Required.
+		Store TOTPStore
+
+		// UserID returns the user authenticated with the first factor.
+		// Required.
+		UserID func(c leego.Context) (string, error)
+
+		// AccountName returns the account shown in authenticator apps, e.g. an
+		// email address.
+		// Optional. Default value returns the user ID.
+		AccountName func(c leego.Context, userID string) (string, error)
+
+		// OnVerified is called when a code is verified, e.g. to mark the
+		// session as fully authenticated.
+		// Optional.
+		OnVerified func(c leego.Context, userID string) error
+
+		// Period is the validity period of a code, at least one second.
+		// Optional. Default value 30 seconds.
+		Period time.Duration `json:"period"`
+
+		// Digits is the number of digits of a code.
+		// Optional. Default value 6.
+		Digits int `json:"digits"`
+
+		// Skew is the number of periods before and after the current one
+		// whose codes are accepted, for clock drift.
+		// Optional. Default value 1.
+		Skew int `json:"skew"`
+
+		// MaxFailures is the number of invalid codes after which the user is
+		// locked out for Lockout, against brute force.
+		// Optional. Default value 5.
+		MaxFailures int `json:"max_failures"`
+
+		// Lockout is the time a user who entered MaxFailures invalid codes
+		// can't enter codes.
+		// Optional. Default value 5 minutes.
+		Lockout time.Duration `json:"lockout"`
+	}
+
+	// TOTPSecret is the TOTP secret of a user.
+	TOTPSecret struct {
+		Secret []byte `json:"secret"`
+
+		// Confirmed is set once the user entered a first code, the secret
+		// being pending until then.
+		Confirmed bool `json:"confirmed"`
+
+		// LastCounter is the counter of the last code used, which can't be
+		// used again.
+		LastCounter int64 `json:"last_counter"`
+
+		// Failures is the number of invalid codes entered since the last
+		// valid one or lockout.
+		Failures int `json:"failures"`
+
+		// LockedUntil is the end of the lockout of the user, see
+		// `TOTPConfig#MaxFailures`.
+		LockedUntil time.Time `json:"locked_until"`
+	}
+
+	// TOTPStore is the storage of the TOTP secrets.
+	TOTPStore interface {
+		// TOTP returns the secret of the user userID, nil if there is none.
+		TOTP(userID string) (*TOTPSecret, error)
+
+		// SaveTOTP creates or updates the secret of the user userID.
+		SaveTOTP(userID string, s *TOTPSecret) error
+
+		// DeleteTOTP removes the secret of the user userID.
+		DeleteTOTP(userID string) error
+	}
+
+	// TOTPEnrollment is the response of the enrollment endpoint.
+	TOTPEnrollment struct {
+		// Secret is the base32 secret to type in authenticator apps.
+		Secret string `json:"secret"`
+
+		// URL is the otpauth URL to show as a QR code.
+		URL string `json:"url"`
+	}
+
+	totpRequest struct {
+		Code string `json:"code" form:"code"`
+	}
+)
+
+var (
+	// DefaultTOTPConfig is the default TOTP config.
+	DefaultTOTPConfig = TOTPConfig{
+		Period:      30 * time.Second,
+		Digits:      6,
+		Skew:        1,
+		MaxFailures: 5,
+		Lockout:     5 * time.Minute,
+	}
+
+	// ErrInvalidCode is returned when a second-factor code or assertion is
+	// invalid.
+	ErrInvalidCode = leego.NewHTTPError(http.StatusUnauthorized, "invalid code")
+
+	// ErrNotEnrolled is returned when the user has no second factor of the
+	// kind.
+	ErrNotEnrolled = leego.NewHTTPError(http.StatusNotFound, "second factor not enrolled")
+
+	// ErrTooManyAttempts is returned when the user is locked out after too
+	// many invalid codes.
+	ErrTooManyAttempts = leego.NewHTTPError(http.StatusTooManyRequests, "too many attempts")
+
+	b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// MountTOTP mounts the TOTP endpoints on g:
+//
+//	POST   /totp/enroll   creates a pending secret, returns `TOTPEnrollment`
+//	POST   /totp/confirm  confirms the pending secret with {"code": "123456"}
+//	POST   /totp/verify   verifies a code of the confirmed secret
+//	DELETE /totp          removes the secret, with a code
+//
+// Enrolling again replaces a pending secret but not a confirmed one, which
+// must be removed first. Codes can't be used twice. A user entering
+// `TOTPConfig#MaxFailures` invalid codes in a row is locked out for
+// `TOTPConfig#Lockout`; the count isn't atomic across concurrent requests, so
+// guessing from many clients at once should still be throttled in front, e.g.
+// with `middleware.LoginThrottle`.
+func MountTOTP(g *leego.Group, config TOTPConfig) {
+	// Defaults
+	if config.Issuer == "" || config.Store == nil || config.UserID == nil {
+		panic("totp requires issuer, store and user id")
+	}
+	if config.AccountName == nil {
+		config.AccountName = func(c leego.Context, userID string) (string, error) {
+			return userID, nil
+		}
+	}
+	if config.Period == 0 {
+		config.Period = DefaultTOTPConfig.Period
+	}
+	if config.Digits == 0 {
+		config.Digits = DefaultTOTPConfig.Digits
+	}
+	if config.Skew == 0 {
+		config.Skew = DefaultTOTPConfig.Skew
+	}
+	if config.MaxFailures == 0 {
+		config.MaxFailures = DefaultTOTPConfig.MaxFailures
+	}
+	if config.Lockout == 0 {
+		config.Lockout = DefaultTOTPConfig.Lockout
+	}
+	if config.Period < time.Second {
+		panic("totp period must be at least one second")
+	}
+	t := &totp{config: config}
+	g.POST("/totp/enroll", t.enroll)
+	g.POST("/totp/confirm", t.confirm)
+	g.POST("/totp/verify", t.verify)
+	g.DELETE("/totp", t.remove)
+}
+
+// GenerateTOTP returns the code of secret at t, with the given period, at
+// least one second, and number of digits (RFC 6238, HMAC-SHA1).
+func GenerateTOTP(secret []byte, t time.Time, period time.Duration, digits int) string {
+	return hotp(secret, t.Unix()/int64(period/time.Second), digits)
+}
+
+type totp struct {
+	config TOTPConfig
+}
+
+func (t *totp) enroll(c leego.Context) leego.LeeError {
+	userID, err := t.config.UserID(c)
+	if err != nil {
+		return err
+	}
+	s, err := t.config.Store.TOTP(userID)
+	if err != nil {
+		return err
+	}
+	if s != nil && s.Confirmed {
+		return leego.NewHTTPError(http.StatusConflict, "totp already enrolled")
+	}
+	account, err := t.config.AccountName(c, userID)
+	if err != nil {
+		return err
+	}
+	s = &TOTPSecret{Secret: make([]byte, 20)}
+	if _, err = rand.Read(s.Secret); err != nil {
+		return err
+	}
+	if err = t.config.Store.SaveTOTP(userID, s); err != nil {
+		return err
+	}
+	secret := b32.EncodeToString(s.Secret)
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", t.config.Issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", strconv.Itoa(t.config.Digits))
+	q.Set("period", strconv.Itoa(int(t.config.Period/time.Second)))
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + t.config.Issuer + ":" + account,
+		RawQuery: q.Encode(),
+	}
+	return c.JSON(http.StatusOK, TOTPEnrollment{Secret: secret, URL: u.String()})
+}
+
+func (t *totp) confirm(c leego.Context) leego.LeeError {
+	userID, s, err := t.check(c, false)
+	if err != nil {
+		return err
+	}
+	s.Confirmed = true
+	if err = t.config.Store.SaveTOTP(userID, s); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (t *totp) verify(c leego.Context) leego.LeeError {
+	userID, s, err := t.check(c, true)
+	if err != nil {
+		return err
+	}
+	if err = t.config.Store.SaveTOTP(userID, s); err != nil {
+		return err
+	}
+	if t.config.OnVerified != nil {
+		if err = t.config.OnVerified(c, userID); err != nil {
+			return err
+		}
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (t *totp) remove(c leego.Context) leego.LeeError {
+	userID, _, err := t.check(c, true)
+	if err != nil {
+		return err
+	}
+	if err = t.config.Store.DeleteTOTP(userID); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// check validates the code of the request against the secret of the user,
+// confirmed or pending, and records its counter. Invalid codes are counted
+// towards the lockout of the user.
+func (t *totp) check(c leego.Context, confirmed bool) (string, *TOTPSecret, error) {
+	userID, err := t.config.UserID(c)
+	if err != nil {
+		return "", nil, err
+	}
+	var req totpRequest
+	if err = c.Bind(&req); err != nil {
+		return "", nil, err
+	}
+	s, err := t.config.Store.TOTP(userID)
+	if err != nil {
+		return "", nil, err
+	}
+	if s == nil || s.Confirmed != confirmed {
+		return "", nil, ErrNotEnrolled
+	}
+	now := time.Now()
+	if now.Before(s.LockedUntil) {
+		return "", nil, ErrTooManyAttempts
+	}
+	counter, ok := t.validate(s, strings.TrimSpace(req.Code), now)
+	if !ok {
+		if s.Failures++; s.Failures >= t.config.MaxFailures {
+			s.Failures = 0
+			s.LockedUntil = now.Add(t.config.Lockout)
+		}
+		if err = t.config.Store.SaveTOTP(userID, s); err != nil {
+			return "", nil, err
+		}
+		return "", nil, ErrInvalidCode
+	}
+	s.LastCounter = counter
+	s.Failures = 0
+	return userID, s, nil
+}
+
+// validate returns the counter of code if it's valid at now and newer than
+// the last one used.
+func (t *totp) validate(s *TOTPSecret, code string, now time.Time) (int64, bool) {
+	if len(code) != t.config.Digits {
+		return 0, false
+	}
+	current := now.Unix() / int64(t.config.Period/time.Second)
+	for i := -t.config.Skew; i <= t.config.Skew; i++ {
+		counter := current + int64(i)
+		if counter <= s.LastCounter {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(hotp(s.Secret, counter, t.config.Digits)), []byte(code)) == 1 {
+			return counter, true
+		}
+	}
+	return 0, false
+}
+
+// hotp returns the HOTP code of secret at counter (RFC 4226).
+func hotp(secret []byte, counter int64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	h := hmac.New(sha1.New, secret)
+	h.Write(msg[:])
+	sum := h.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	v := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	code := strconv.FormatUint(uint64(v%mod), 10)
+	return strings.Repeat("0", digits-len(code)) + code
+}
+
+// DecodeTOTPSecret decodes a base32 secret, e.g. `TOTPEnrollment#Secret`.
+func DecodeTOTPSecret(secret string) ([]byte, error) {
+	b, err := b32.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return nil, errors.New("invalid totp secret")
+	}
+	return b, nil
+}
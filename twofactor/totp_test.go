@@ -0,0 +1,111 @@
+package twofactor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateTOTP(t *testing.T) {
+	// RFC 6238 test vectors
+	secret := []byte("12345678901234567890")
+	for sec, code := range map[int64]string{
+		59:         "94287082",
+		1111111109: "07081804",
+		2000000000: "69279037",
+	} {
+		assert.Equal(t, code, GenerateTOTP(secret, time.Unix(sec, 0), 30*time.Second, 8))
+	}
+}
+
+func TestTOTP(t *testing.T) {
+	store := NewMemoryStore()
+	verified := ""
+	lee := leego.New()
+	MountTOTP(lee.Group("/2fa"), TOTPConfig{
+		Issuer: "Example",
+		Store:  store,
+		UserID: func(c leego.Context) (string, error) {
+			return "joe", nil
+		},
+		OnVerified: func(c leego.Context, userID string) error {
+			verified = userID
+			return nil
+		},
+	})
+	request := func(method, path, code string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{"code":"`+code+`"}`))
+		req.Header.Set(leego.HeaderContentType, leego.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		return rec
+	}
+
+	rec := request(leego.POST, "/2fa/totp/enroll", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var e TOTPEnrollment
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &e))
+	u, err := url.Parse(e.URL)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "otpauth", u.Scheme)
+		assert.Equal(t, "/Example:joe", u.Path)
+		assert.Equal(t, e.Secret, u.Query().Get("secret"))
+	}
+	secret, err := DecodeTOTPSecret(e.Secret)
+	assert.NoError(t, err)
+	code := func(d time.Duration) string {
+		return GenerateTOTP(secret, time.Now().Add(d), 30*time.Second, 6)
+	}
+
+	// Pending until confirmed
+	assert.Equal(t, http.StatusNotFound, request(leego.POST, "/2fa/totp/verify", code(0)).Code)
+	assert.Equal(t, http.StatusUnauthorized, request(leego.POST, "/2fa/totp/confirm", "000000").Code)
+	assert.Equal(t, http.StatusNoContent, request(leego.POST, "/2fa/totp/confirm", code(0)).Code)
+	assert.Equal(t, http.StatusConflict, request(leego.POST, "/2fa/totp/enroll", "").Code)
+
+	// Codes can't be used twice
+	assert.Equal(t, http.StatusUnauthorized, request(leego.POST, "/2fa/totp/verify", code(0)).Code)
+	assert.Equal(t, "", verified)
+	assert.Equal(t, http.StatusNoContent, request(leego.POST, "/2fa/totp/verify", code(30*time.Second)).Code)
+	assert.Equal(t, "joe", verified)
+	assert.Equal(t, http.StatusUnauthorized, request(leego.POST, "/2fa/totp/verify", code(-30*time.Second)).Code)
+
+	s, _ := store.TOTP("joe")
+	s.LastCounter = 0
+	store.SaveTOTP("joe", s)
+	assert.Equal(t, http.StatusNoContent, request(leego.DELETE, "/2fa/totp", code(0)).Code)
+	s, _ = store.TOTP("joe")
+	assert.Nil(t, s)
+
+	// Lockout
+	request(leego.POST, "/2fa/totp/enroll", "")
+	s, _ = store.TOTP("joe")
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusUnauthorized, request(leego.POST, "/2fa/totp/confirm", "000000").Code)
+	}
+	code = func(d time.Duration) string {
+		return GenerateTOTP(s.Secret, time.Now().Add(d), 30*time.Second, 6)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, request(leego.POST, "/2fa/totp/confirm", code(0)).Code)
+	s, _ = store.TOTP("joe")
+	s.LockedUntil = time.Time{}
+	store.SaveTOTP("joe", s)
+	assert.Equal(t, http.StatusNoContent, request(leego.POST, "/2fa/totp/confirm", code(0)).Code)
+
+	assert.Panics(t, func() {
+		MountTOTP(lee.Group("/"), TOTPConfig{})
+	})
+	assert.Panics(t, func() {
+		MountTOTP(lee.Group("/"), TOTPConfig{Issuer: "Example", Store: store, UserID: func(c leego.Context) (string, error) {
+			return "joe", nil
+		}, Period: time.Millisecond})
+	})
+}
@@ -0,0 +1,504 @@
+package twofactor
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/go-wyvern/leego"
+)
+
+type (
+	// WebAuthnConfig defines the config for the WebAuthn endpoints.
+	WebAuthnConfig struct {
+		// RPID is the relying party ID, the domain of the site, e.g.
+		// "example.com".
+		// Required.
+		RPID string `json:"rp_id"`
+
+		// RPName is the name of the site shown by authenticators.
+		// Optional. Default value RPID.
+		RPName string `json:"rp_name"`
+
+		// Origin is the origin of the pages running the ceremonies, e.g.
+		// "https://example.com".
+		// Required.
+		Origin string `json:"origin"`
+
+		// Store keeps the credentials and the pending challenges.
+		// Required.
+		Store WebAuthnStore
+
+		// UserID returns the user authenticated with the first factor.
+		// Required.
+		UserID func(c leego.Context) (string, error)
+
+		// UserName returns the account shown by authenticators, e.g. an email
+		// address.
+		// Optional. Default value returns the user ID.
+		UserName func(c leego.Context, userID string) (string, error)
+
+		// OnVerified is called when an assertion is verified, e.g. to mark the
+		// session as fully authenticated.
+		// Optional.
+		OnVerified func(c leego.Context, userID string) error
+
+		// Timeout is the time the user has to complete a ceremony.
+		// Optional. Default value 5 minutes.
+		Timeout time.Duration `json:"timeout"`
+	}
+
+	// WebAuthnCredential is a registered authenticator of a user.
+	WebAuthnCredential struct {
+		ID     Bytes  `json:"id"`
+		UserID string `json:"user_id"`
+
+		// PublicKey is the COSE public key of the credential.
+		PublicKey Bytes `json:"public_key"`
+
+		// SignCount is the signature counter of the authenticator, used to
+		// detect cloned authenticators.
+		SignCount uint32    `json:"sign_count"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+
+	// WebAuthnChallenge is the challenge of a pending ceremony.
+	WebAuthnChallenge struct {
+		Challenge Bytes `json:"challenge"`
+
+		// Type is "webauthn.create" for registrations and "webauthn.get" for
+		// assertions.
+		Type      string    `json:"type"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
+	// WebAuthnStore is the storage of the WebAuthn credentials.
+	WebAuthnStore interface {
+		// Credentials returns the credentials of the user userID.
+		Credentials(userID string) ([]*WebAuthnCredential, error)
+
+		// SaveCredential creates or updates c.
+		SaveCredential(c *WebAuthnCredential) error
+
+		// SaveChallenge sets the pending challenge of the user userID,
+		// replacing any.
+		SaveChallenge(userID string, ch *WebAuthnChallenge) error
+
+		// TakeChallenge removes and returns the pending challenge of the user
+		// userID, nil if there is none.
+		TakeChallenge(userID string) (*WebAuthnChallenge, error)
+	}
+
+	// Bytes are binary data encoded in JSON as unpadded base64url, like in
+	// the WebAuthn JavaScript API wrappers.
+	Bytes []byte
+
+	webAuthnCredentialRequest struct {
+		ID       string `json:"id"`
+		RawID    Bytes  `json:"rawId"`
+		Type     string `json:"type"`
+		Response struct {
+			ClientDataJSON    Bytes `json:"clientDataJSON"`
+			AttestationObject Bytes `json:"attestationObject"`
+			AuthenticatorData Bytes `json:"authenticatorData"`
+			Signature         Bytes `json:"signature"`
+		} `json:"response"`
+	}
+
+	clientData struct {
+		Type        string `json:"type"`
+		Challenge   string `json:"challenge"`
+		Origin      string `json:"origin"`
+		CrossOrigin bool   `json:"crossOrigin"`
+	}
+
+	authenticatorData struct {
+		rpIDHash     []byte
+		flags        byte
+		signCount    uint32
+		credentialID []byte
+		publicKey    []byte
+	}
+
+	webAuthn struct {
+		config WebAuthnConfig
+	}
+)
+
+// Authenticator data flags
+const (
+	flagUserPresent  = 0x01
+	flagAttestedData = 0x40
+)
+
+// COSE algorithms
+const (
+	coseES256 = -7
+	coseRS256 = -257
+)
+
+var (
+	// DefaultWebAuthnConfig is the default WebAuthn config.
+	DefaultWebAuthnConfig = WebAuthnConfig{
+		Timeout: 5 * time.Minute,
+	}
+
+	// ErrInvalidCredential is returned when a WebAuthn response fails
+	// verification.
+	ErrInvalidCredential = leego.NewHTTPError(http.StatusBadRequest, "invalid webauthn credential")
+)
+
+// MountWebAuthn mounts the WebAuthn ceremonies on g:
+//
+//	POST /webauthn/register/begin   returns the options of
+//	                                `navigator.credentials.create()`
+//	POST /webauthn/register/finish  registers the created credential
+//	POST /webauthn/login/begin      returns the options of
+//	                                `navigator.credentials.get()`
+//	POST /webauthn/login/finish     verifies the assertion
+//
+// Binary fields are base64url encoded both ways. Registration requests no
+// attestation, so the authenticator model isn't verified. ES256 and RS256
+// keys are supported.
+func MountWebAuthn(g *leego.Group, config WebAuthnConfig) {
+	// Defaults
+	if config.RPID == "" || config.Origin == "" || config.Store == nil || config.UserID == nil {
+		panic("webauthn requires rp id, origin, store and user id")
+	}
+	if config.RPName == "" {
+		config.RPName = config.RPID
+	}
+	if config.UserName == nil {
+		config.UserName = func(c leego.Context, userID string) (string, error) {
+			return userID, nil
+		}
+	}
+	if config.Timeout == 0 {
+		config.Timeout = DefaultWebAuthnConfig.Timeout
+	}
+	w := &webAuthn{config: config}
+	g.POST("/webauthn/register/begin", w.beginRegistration)
+	g.POST("/webauthn/register/finish", w.finishRegistration)
+	g.POST("/webauthn/login/begin", w.beginLogin)
+	g.POST("/webauthn/login/finish", w.finishLogin)
+}
+
+func (w *webAuthn) beginRegistration(c leego.Context) leego.LeeError {
+	userID, err := w.config.UserID(c)
+	if err != nil {
+		return err
+	}
+	name, err := w.config.UserName(c, userID)
+	if err != nil {
+		return err
+	}
+	creds, err := w.config.Store.Credentials(userID)
+	if err != nil {
+		return err
+	}
+	ch, err := w.challenge(userID, "webauthn.create")
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"publicKey": map[string]interface{}{
+			"challenge": ch,
+			"rp":        map[string]string{"id": w.config.RPID, "name": w.config.RPName},
+			"user": map[string]interface{}{
+				"id":          Bytes(userID),
+				"name":        name,
+				"displayName": name,
+			},
+			"pubKeyCredParams": []map[string]interface{}{
+				{"type": "public-key", "alg": coseES256},
+				{"type": "public-key", "alg": coseRS256},
+			},
+			"timeout":            int64(w.config.Timeout / time.Millisecond),
+			"excludeCredentials": descriptors(creds),
+			"attestation":        "none",
+		},
+	})
+}
+
+func (w *webAuthn) finishRegistration(c leego.Context) leego.LeeError {
+	userID, req, err := w.credentialRequest(c, "webauthn.create")
+	if err != nil {
+		return err
+	}
+	v, _, err := decodeCBOR(req.Response.AttestationObject)
+	if err != nil {
+		return ErrInvalidCredential
+	}
+	obj, _ := v.(map[interface{}]interface{})
+	raw, _ := obj["authData"].([]byte)
+	ad, err := w.authenticatorData(raw)
+	if err != nil || ad.flags&flagAttestedData == 0 {
+		return ErrInvalidCredential
+	}
+	if _, err = parseCOSEKey(ad.publicKey); err != nil {
+		return ErrInvalidCredential
+	}
+	creds, err := w.config.Store.Credentials(userID)
+	if err != nil {
+		return err
+	}
+	if findCredential(creds, ad.credentialID) != nil {
+		return leego.NewHTTPError(http.StatusConflict, "credential already registered")
+	}
+	err = w.config.Store.SaveCredential(&WebAuthnCredential{
+		ID:        ad.credentialID,
+		UserID:    userID,
+		PublicKey: ad.publicKey,
+		SignCount: ad.signCount,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusCreated)
+}
+
+func (w *webAuthn) beginLogin(c leego.Context) leego.LeeError {
+	userID, err := w.config.UserID(c)
+	if err != nil {
+		return err
+	}
+	creds, err := w.config.Store.Credentials(userID)
+	if err != nil {
+		return err
+	}
+	if len(creds) == 0 {
+		return ErrNotEnrolled
+	}
+	ch, err := w.challenge(userID, "webauthn.get")
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"publicKey": map[string]interface{}{
+			"challenge":        ch,
+			"rpId":             w.config.RPID,
+			"timeout":          int64(w.config.Timeout / time.Millisecond),
+			"allowCredentials": descriptors(creds),
+			"userVerification": "preferred",
+		},
+	})
+}
+
+func (w *webAuthn) finishLogin(c leego.Context) leego.LeeError {
+	userID, req, err := w.credentialRequest(c, "webauthn.get")
+	if err != nil {
+		return err
+	}
+	creds, err := w.config.Store.Credentials(userID)
+	if err != nil {
+		return err
+	}
+	cred := findCredential(creds, req.RawID)
+	if cred == nil {
+		return ErrInvalidCode
+	}
+	ad, err := w.authenticatorData(req.Response.AuthenticatorData)
+	if err != nil {
+		return ErrInvalidCode
+	}
+	key, err := parseCOSEKey(cred.PublicKey)
+	if err != nil {
+		return err
+	}
+	h := sha256.Sum256(req.Response.ClientDataJSON)
+	signed := append(append([]byte{}, req.Response.AuthenticatorData...), h[:]...)
+	if !verifySignature(key, signed, req.Response.Signature) {
+		return ErrInvalidCode
+	}
+	// A counter not increasing reveals a cloned authenticator, authenticators
+	// without counter always send 0
+	if (ad.signCount != 0 || cred.SignCount != 0) && ad.signCount <= cred.SignCount {
+		return ErrInvalidCode
+	}
+	cred.SignCount = ad.signCount
+	if err = w.config.Store.SaveCredential(cred); err != nil {
+		return err
+	}
+	if w.config.OnVerified != nil {
+		if err = w.config.OnVerified(c, userID); err != nil {
+			return err
+		}
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// challenge creates and stores the challenge of a ceremony of the user.
+func (w *webAuthn) challenge(userID, typ string) (Bytes, error) {
+	ch := &WebAuthnChallenge{
+		Challenge: make(Bytes, 32),
+		Type:      typ,
+		ExpiresAt: time.Now().Add(w.config.Timeout),
+	}
+	if _, err := rand.Read(ch.Challenge); err != nil {
+		return nil, err
+	}
+	if err := w.config.Store.SaveChallenge(userID, ch); err != nil {
+		return nil, err
+	}
+	return ch.Challenge, nil
+}
+
+// credentialRequest binds the credential of the request and verifies its
+// client data against the pending challenge of the user.
+func (w *webAuthn) credentialRequest(c leego.Context, typ string) (string, *webAuthnCredentialRequest, error) {
+	userID, err := w.config.UserID(c)
+	if err != nil {
+		return "", nil, err
+	}
+	req := new(webAuthnCredentialRequest)
+	if err = c.Bind(req); err != nil {
+		return "", nil, err
+	}
+	ch, err := w.config.Store.TakeChallenge(userID)
+	if err != nil {
+		return "", nil, err
+	}
+	if ch == nil || ch.Type != typ || !time.Now().Before(ch.ExpiresAt) {
+		return "", nil, leego.NewHTTPError(http.StatusBadRequest, "no pending webauthn ceremony")
+	}
+	var cd clientData
+	if err = json.Unmarshal(req.Response.ClientDataJSON, &cd); err != nil ||
+		cd.Type != typ || cd.CrossOrigin || cd.Origin != w.config.Origin ||
+		cd.Challenge != base64.RawURLEncoding.EncodeToString(ch.Challenge) {
+		return "", nil, ErrInvalidCredential
+	}
+	return userID, req, nil
+}
+
+// authenticatorData parses b and checks the relying party and the user
+// presence.
+func (w *webAuthn) authenticatorData(b []byte) (*authenticatorData, error) {
+	if len(b) < 37 {
+		return nil, errInvalidAuthenticatorData
+	}
+	ad := &authenticatorData{
+		rpIDHash:  b[:32],
+		flags:     b[32],
+		signCount: binary.BigEndian.Uint32(b[33:37]),
+	}
+	rpIDHash := sha256.Sum256([]byte(w.config.RPID))
+	if !bytes.Equal(ad.rpIDHash, rpIDHash[:]) || ad.flags&flagUserPresent == 0 {
+		return nil, errInvalidAuthenticatorData
+	}
+	if ad.flags&flagAttestedData != 0 {
+		// AAGUID (16), credential ID length (2), credential ID, COSE key
+		b = b[37:]
+		if len(b) < 18 {
+			return nil, errInvalidAuthenticatorData
+		}
+		n := int(binary.BigEndian.Uint16(b[16:18]))
+		b = b[18:]
+		if len(b) < n {
+			return nil, errInvalidAuthenticatorData
+		}
+		ad.credentialID = b[:n]
+		_, rest, err := decodeCBOR(b[n:])
+		if err != nil {
+			return nil, errInvalidAuthenticatorData
+		}
+		ad.publicKey = b[n : len(b)-len(rest)]
+	}
+	return ad, nil
+}
+
+var errInvalidAuthenticatorData = errors.New("invalid authenticator data")
+
+// parseCOSEKey parses an ES256 or RS256 COSE public key.
+func parseCOSEKey(b []byte) (crypto.PublicKey, error) {
+	v, _, err := decodeCBOR(b)
+	if err != nil {
+		return nil, err
+	}
+	m, _ := v.(map[interface{}]interface{})
+	alg, _ := m[int64(3)].(int64)
+	switch alg {
+	case coseES256:
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+		if m[int64(1)] != int64(2) || m[int64(-1)] != int64(1) || len(x) != 32 || len(y) != 32 {
+			break
+		}
+		k := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !k.Curve.IsOnCurve(k.X, k.Y) {
+			break
+		}
+		return k, nil
+	case coseRS256:
+		n, _ := m[int64(-1)].([]byte)
+		e, _ := m[int64(-2)].([]byte)
+		if m[int64(1)] != int64(3) || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			break
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	}
+	return nil, errors.New("unsupported cose key")
+}
+
+func verifySignature(key crypto.PublicKey, signed, sig []byte) bool {
+	h := sha256.Sum256(signed)
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		var s struct{ R, S *big.Int }
+		if rest, err := asn1.Unmarshal(sig, &s); err != nil || len(rest) > 0 {
+			return false
+		}
+		return ecdsa.Verify(k, h[:], s.R, s.S)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, h[:], sig) == nil
+	}
+	return false
+}
+
+func descriptors(creds []*WebAuthnCredential) []map[string]interface{} {
+	d := make([]map[string]interface{}, len(creds))
+	for i, c := range creds {
+		d[i] = map[string]interface{}{"type": "public-key", "id": c.ID}
+	}
+	return d
+}
+
+func findCredential(creds []*WebAuthnCredential, id []byte) *WebAuthnCredential {
+	for _, c := range creds {
+		if bytes.Equal(c.ID, id) {
+			return c
+		}
+	}
+	return nil
+}
+
+// MarshalJSON implements `json.Marshaler`.
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+// UnmarshalJSON implements `json.Unmarshaler`, accepting padded values.
+func (b *Bytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	d, err := base64.RawURLEncoding.DecodeString(string(bytes.TrimRight([]byte(s), "=")))
+	if err != nil {
+		return err
+	}
+	*b = d
+	return nil
+}
@@ -0,0 +1,193 @@
+package twofactor
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/stretchr/testify/assert"
+)
+
+// authenticator is a software WebAuthn authenticator.
+type authenticator struct {
+	key    *ecdsa.PrivateKey
+	id     []byte
+	rpID   string
+	origin string
+}
+
+func (a *authenticator) authData(flags byte, count uint32, attested bool) []byte {
+	h := sha256.Sum256([]byte(a.rpID))
+	b := append(h[:], flags, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[33:], count)
+	if attested {
+		b = append(b, make([]byte, 16)...) // AAGUID
+		b = append(b, byte(len(a.id)>>8), byte(len(a.id)))
+		b = append(b, a.id...)
+		b = append(b, encodeCBOR(map[interface{}]interface{}{
+			1: 2, 3: -7, -1: 1,
+			-2: pad32(a.key.X.Bytes()),
+			-3: pad32(a.key.Y.Bytes()),
+		})...)
+	}
+	return b
+}
+
+func pad32(b []byte) []byte {
+	return append(make([]byte, 32-len(b)), b...)
+}
+
+func (a *authenticator) clientData(typ, challenge string) []byte {
+	b, _ := json.Marshal(map[string]string{"type": typ, "challenge": challenge, "origin": a.origin})
+	return b
+}
+
+func (a *authenticator) create(challenge string) interface{} {
+	return map[string]interface{}{
+		"id":    base64.RawURLEncoding.EncodeToString(a.id),
+		"rawId": Bytes(a.id),
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON": Bytes(a.clientData("webauthn.create", challenge)),
+			"attestationObject": Bytes(encodeCBOR(map[interface{}]interface{}{
+				"fmt":      "none",
+				"attStmt":  map[interface{}]interface{}{},
+				"authData": a.authData(flagUserPresent|flagAttestedData, 0, true),
+			})),
+		},
+	}
+}
+
+func (a *authenticator) get(challenge string, count uint32) interface{} {
+	authData := a.authData(flagUserPresent, count, false)
+	clientData := a.clientData("webauthn.get", challenge)
+	h := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), h[:]...))
+	r, s, _ := ecdsa.Sign(rand.Reader, a.key, digest[:])
+	sig, _ := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+	return map[string]interface{}{
+		"id":    base64.RawURLEncoding.EncodeToString(a.id),
+		"rawId": Bytes(a.id),
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON":    Bytes(clientData),
+			"authenticatorData": Bytes(authData),
+			"signature":         Bytes(sig),
+		},
+	}
+}
+
+func encodeCBOR(v interface{}) []byte {
+	head := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n < 1<<8:
+			return []byte{major<<5 | 24, byte(n)}
+		default:
+			return []byte{major<<5 | 25, byte(n >> 8), byte(n)}
+		}
+	}
+	switch v := v.(type) {
+	case int:
+		if v < 0 {
+			return head(1, uint64(-1-v))
+		}
+		return head(0, uint64(v))
+	case []byte:
+		return append(head(2, uint64(len(v))), v...)
+	case string:
+		return append(head(3, uint64(len(v))), v...)
+	case map[interface{}]interface{}:
+		b := head(5, uint64(len(v)))
+		for k, e := range v {
+			b = append(b, encodeCBOR(k)...)
+			b = append(b, encodeCBOR(e)...)
+		}
+		return b
+	}
+	panic("unsupported cbor value")
+}
+
+func TestWebAuthn(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	a := &authenticator{key: key, id: []byte("credential-1"), rpID: "example.com", origin: "https://example.com"}
+	store := NewMemoryStore()
+	verified := ""
+	lee := leego.New()
+	MountWebAuthn(lee.Group("/2fa"), WebAuthnConfig{
+		RPID:   "example.com",
+		Origin: "https://example.com",
+		Store:  store,
+		UserID: func(c leego.Context) (string, error) {
+			return "joe", nil
+		},
+		OnVerified: func(c leego.Context, userID string) error {
+			verified = userID
+			return nil
+		},
+	})
+	request := func(path string, body interface{}) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(body)
+		req := httptest.NewRequest(leego.POST, path, bytes.NewReader(b))
+		req.Header.Set(leego.HeaderContentType, leego.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		lee.ServeHTTP(standard.NewRequest(req), standard.NewResponse(rec))
+		return rec
+	}
+	begin := func(path string) string {
+		rec := request(path, nil)
+		var options struct {
+			PublicKey struct {
+				Challenge string `json:"challenge"`
+			} `json:"publicKey"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &options)
+		return options.PublicKey.Challenge
+	}
+
+	assert.Equal(t, http.StatusNotFound, request("/2fa/webauthn/login/begin", nil).Code)
+
+	// Registration
+	ch := begin("/2fa/webauthn/register/begin")
+	assert.NotEqual(t, "", ch)
+	assert.Equal(t, http.StatusCreated, request("/2fa/webauthn/register/finish", a.create(ch)).Code)
+	assert.Equal(t, http.StatusBadRequest, request("/2fa/webauthn/register/finish", a.create(ch)).Code)
+	creds, _ := store.Credentials("joe")
+	assert.Len(t, creds, 1)
+
+	// Assertion
+	ch = begin("/2fa/webauthn/login/begin")
+	assert.Equal(t, http.StatusNoContent, request("/2fa/webauthn/login/finish", a.get(ch, 1)).Code)
+	assert.Equal(t, "joe", verified)
+
+	// Cloned authenticator
+	verified = ""
+	ch = begin("/2fa/webauthn/login/begin")
+	assert.Equal(t, http.StatusUnauthorized, request("/2fa/webauthn/login/finish", a.get(ch, 1)).Code)
+
+	// Other key
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ch = begin("/2fa/webauthn/login/begin")
+	assert.Equal(t, http.StatusUnauthorized, request("/2fa/webauthn/login/finish", (&authenticator{
+		key: other, id: a.id, rpID: a.rpID, origin: a.origin,
+	}).get(ch, 2)).Code)
+
+	// Other origin
+	ch = begin("/2fa/webauthn/login/begin")
+	a.origin = "https://evil.example"
+	assert.Equal(t, http.StatusBadRequest, request("/2fa/webauthn/login/finish", a.get(ch, 2)).Code)
+	assert.Equal(t, "", verified)
+}
@@ -0,0 +1,393 @@
+// Package websocket implements the server side of the WebSocket protocol
+// (RFC 6455) on top of `engine.Request` and `engine.Response`, so it works with
+// any engine which supports hijacking the connection.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-wyvern/leego/engine"
+)
+
+type (
+	// Config defines the config for the WebSocket upgrade.
+	Config struct {
+		// Subprotocols lists the supported subprotocols in order of preference.
+		// The first one also requested by the client is selected.
+		Subprotocols []string
+
+		// CheckOrigin returns true if the request Origin header is acceptable.
+		// Optional. Default value accepts requests without Origin header or with
+		// an Origin matching the Host header.
+		CheckOrigin func(engine.Request) bool
+
+		// MaxMessageSize is the maximum size in bytes of a message read from the
+		// peer. Optional. Default value 32 MB.
+		MaxMessageSize int64
+	}
+
+	// Conn represents a WebSocket connection.
+	Conn struct {
+		conn        net.Conn
+		br          *bufio.Reader
+		bw          *bufio.Writer
+		wmu         sync.Mutex
+		subprotocol string
+		maxSize     int64
+		closeOnce   sync.Once
+	}
+
+	// HandshakeError describes an error with the handshake from the peer.
+	HandshakeError struct {
+		Code    int
+		Message string
+	}
+
+	// CloseError is returned by `Conn#ReadMessage()` when the peer closes the
+	// connection.
+	CloseError struct {
+		Code int
+		Text string
+	}
+)
+
+// Message types.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+// Close codes.
+const (
+	CloseNormalClosure    = 1000
+	CloseGoingAway        = 1001
+	CloseProtocolError    = 1002
+	CloseNoStatusReceived = 1005
+	CloseMessageTooBig    = 1009
+)
+
+const (
+	acceptGUID            = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	defaultMaxMessageSize = 32 << 20 // 32 MB
+	maxControlPayload     = 125
+	closeTimeout          = time.Second
+)
+
+var (
+	// DefaultConfig is the default WebSocket upgrade config.
+	DefaultConfig = Config{
+		CheckOrigin:    checkSameOrigin,
+		MaxMessageSize: defaultMaxMessageSize,
+	}
+
+	errProtocol        = errors.New("websocket: protocol error")
+	errMessageTooBig   = errors.New("websocket: message too big")
+	errInvalidDataType = errors.New("websocket: invalid message type")
+)
+
+// Error makes it compatible with `error` interface.
+func (e *HandshakeError) Error() string {
+	return e.Message
+}
+
+// Error makes it compatible with `error` interface.
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+// Upgrade upgrades the HTTP connection to the WebSocket protocol.
+func Upgrade(req engine.Request, res engine.Response) (*Conn, error) {
+	return UpgradeWithConfig(req, res, DefaultConfig)
+}
+
+// UpgradeWithConfig upgrades the HTTP connection to the WebSocket protocol
+// with config. See `Upgrade()`.
+func UpgradeWithConfig(req engine.Request, res engine.Response, config Config) (*Conn, error) {
+	// Defaults
+	if config.CheckOrigin == nil {
+		config.CheckOrigin = DefaultConfig.CheckOrigin
+	}
+	if config.MaxMessageSize <= 0 {
+		config.MaxMessageSize = DefaultConfig.MaxMessageSize
+	}
+
+	h := req.Header()
+	if req.Method() != http.MethodGet {
+		return nil, &HandshakeError{http.StatusMethodNotAllowed, "websocket: method not GET"}
+	}
+	if !hasToken(h.Get("Connection"), "upgrade") || !hasToken(h.Get("Upgrade"), "websocket") {
+		return nil, &HandshakeError{http.StatusBadRequest, "websocket: not a websocket handshake"}
+	}
+	if h.Get("Sec-Websocket-Version") != "13" {
+		res.Header().Set("Sec-Websocket-Version", "13")
+		return nil, &HandshakeError{http.StatusUpgradeRequired, "websocket: unsupported version"}
+	}
+	key := h.Get("Sec-Websocket-Key")
+	if key == "" {
+		return nil, &HandshakeError{http.StatusBadRequest, "websocket: missing Sec-WebSocket-Key"}
+	}
+	if !config.CheckOrigin(req) {
+		return nil, &HandshakeError{http.StatusForbidden, "websocket: origin not allowed"}
+	}
+	subprotocol := selectSubprotocol(h.Get("Sec-Websocket-Protocol"), config.Subprotocols)
+
+	conn, rw, err := res.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: ")
+	rw.WriteString(acceptKey(key))
+	if subprotocol != "" {
+		rw.WriteString("\r\nSec-WebSocket-Protocol: ")
+		rw.WriteString(subprotocol)
+	}
+	rw.WriteString("\r\n\r\n")
+	if err = rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Conn{
+		conn:        conn,
+		br:          rw.Reader,
+		bw:          rw.Writer,
+		subprotocol: subprotocol,
+		maxSize:     config.MaxMessageSize,
+	}, nil
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	io.WriteString(h, key+acceptGUID)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func hasToken(header, token string) bool {
+	for _, t := range strings.Split(header, ",") {
+		if strings.EqualFold(strings.TrimSpace(t), token) {
+			return true
+		}
+	}
+	return false
+}
+
+func selectSubprotocol(requested string, supported []string) string {
+	for _, s := range supported {
+		if hasToken(requested, s) {
+			return s
+		}
+	}
+	return ""
+}
+
+func checkSameOrigin(req engine.Request) bool {
+	origin := req.Header().Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if i := strings.Index(origin, "://"); i >= 0 {
+		origin = origin[i+3:]
+	}
+	return strings.EqualFold(origin, req.Host())
+}
+
+// Subprotocol returns the negotiated subprotocol.
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// RemoteAddr returns the remote network address.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// LocalAddr returns the local network address.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// SetReadDeadline sets the read deadline on the underlying connection.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline on the underlying connection.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// ReadMessage reads the next text or binary message. Ping frames are answered
+// and pong frames are skipped. When the peer closes the connection, the close is
+// acknowledged and a `*CloseError` is returned.
+func (c *Conn) ReadMessage() (messageType int, p []byte, err error) {
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch opcode {
+		case PingMessage:
+			if err = c.writeFrame(PongMessage, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			continue
+		case CloseMessage:
+			ce := &CloseError{Code: CloseNoStatusReceived}
+			if len(payload) >= 2 {
+				ce.Code = int(binary.BigEndian.Uint16(payload))
+				ce.Text = string(payload[2:])
+			}
+			c.writeClose(CloseNormalClosure)
+			return 0, nil, ce
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, errProtocol)
+			}
+			messageType = opcode
+		case 0: // Continuation
+			if messageType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, errProtocol)
+			}
+		default:
+			return 0, nil, c.fail(CloseProtocolError, errProtocol)
+		}
+		if int64(len(p)+len(payload)) > c.maxSize {
+			return 0, nil, c.fail(CloseMessageTooBig, errMessageTooBig)
+		}
+		p = append(p, payload...)
+		if fin {
+			return messageType, p, nil
+		}
+	}
+}
+
+func (c *Conn) readFrame() (fin bool, opcode int, payload []byte, err error) {
+	var h [2]byte
+	if _, err = io.ReadFull(c.br, h[:]); err != nil {
+		return
+	}
+	fin = h[0]&0x80 != 0
+	opcode = int(h[0] & 0x0f)
+	if h[0]&0x70 != 0 || h[1]&0x80 == 0 {
+		// Reserved bits must be clear and client frames must be masked.
+		err = c.fail(CloseProtocolError, errProtocol)
+		return
+	}
+	n := int64(h[1] & 0x7f)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err = io.ReadFull(c.br, b[:]); err != nil {
+			return
+		}
+		n = int64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err = io.ReadFull(c.br, b[:]); err != nil {
+			return
+		}
+		n = int64(binary.BigEndian.Uint64(b[:]))
+	}
+	if opcode >= CloseMessage && (n > maxControlPayload || !fin) {
+		err = c.fail(CloseProtocolError, errProtocol)
+		return
+	}
+	if n < 0 || n > c.maxSize {
+		err = c.fail(CloseMessageTooBig, errMessageTooBig)
+		return
+	}
+	var mask [4]byte
+	if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return
+}
+
+// WriteMessage writes a message of the type with data as payload. It is safe
+// to call concurrently with `ReadMessage()`.
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	switch messageType {
+	case TextMessage, BinaryMessage:
+	case PingMessage, PongMessage:
+		if len(data) > maxControlPayload {
+			return errProtocol
+		}
+	default:
+		return errInvalidDataType
+	}
+	return c.writeFrame(messageType, data)
+}
+
+func (c *Conn) writeFrame(opcode int, data []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	var h [10]byte
+	h[0] = 0x80 | byte(opcode)
+	n := 2
+	switch l := len(data); {
+	case l <= 125:
+		h[1] = byte(l)
+	case l <= 0xffff:
+		h[1] = 126
+		binary.BigEndian.PutUint16(h[2:], uint16(l))
+		n = 4
+	default:
+		h[1] = 127
+		binary.BigEndian.PutUint64(h[2:], uint64(l))
+		n = 10
+	}
+	if _, err := c.bw.Write(h[:n]); err != nil {
+		return err
+	}
+	if _, err := c.bw.Write(data); err != nil {
+		return err
+	}
+	return c.bw.Flush()
+}
+
+func (c *Conn) writeClose(code int) error {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], uint16(code))
+	c.conn.SetWriteDeadline(time.Now().Add(closeTimeout))
+	return c.writeFrame(CloseMessage, b[:])
+}
+
+// fail closes the connection with the code and returns err.
+func (c *Conn) fail(code int, err error) error {
+	c.closeOnce.Do(func() {
+		c.writeClose(code)
+		c.conn.Close()
+	})
+	return err
+}
+
+// Close sends a normal closure frame and closes the connection.
+func (c *Conn) Close() (err error) {
+	c.closeOnce.Do(func() {
+		c.writeClose(CloseNormalClosure)
+		err = c.conn.Close()
+	})
+	return
+}
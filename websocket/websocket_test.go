@@ -0,0 +1,109 @@
+package websocket_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-wyvern/leego"
+	"github.com/go-wyvern/leego/engine/standard"
+	"github.com/go-wyvern/leego/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func newServer() *httptest.Server {
+	lee := leego.New()
+	lee.GET("/ws", func(c leego.Context) leego.LeeError {
+		return c.WebSocket(func(ws *websocket.Conn) error {
+			for {
+				t, p, err := ws.ReadMessage()
+				if err != nil {
+					return nil
+				}
+				if err = ws.WriteMessage(t, p); err != nil {
+					return err
+				}
+			}
+		})
+	})
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lee.ServeHTTP(standard.NewRequest(r), standard.NewResponse(w))
+	}))
+}
+
+func writeFrame(w io.Writer, opcode byte, payload []byte) error {
+	mask := [4]byte{1, 2, 3, 4}
+	b := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	b = append(b, mask[:]...)
+	for i, c := range payload {
+		b = append(b, c^mask[i%4])
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+func readFrame(r io.Reader) (opcode byte, payload []byte, err error) {
+	var h [2]byte
+	if _, err = io.ReadFull(r, h[:]); err != nil {
+		return
+	}
+	payload = make([]byte, h[1]&0x7f)
+	_, err = io.ReadFull(r, payload)
+	return h[0] & 0x0f, payload, err
+}
+
+func TestWebSocket(t *testing.T) {
+	srv := newServer()
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if assert.NoError(t, err) {
+		defer conn.Close()
+		io.WriteString(conn, "GET /ws HTTP/1.1\r\nHost: "+srv.Listener.Addr().String()+
+			"\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+			"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+		br := bufio.NewReader(conn)
+		res, err := http.ReadResponse(br, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+			assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", res.Header.Get("Sec-Websocket-Accept"))
+		}
+
+		// Echo
+		assert.NoError(t, writeFrame(conn, websocket.TextMessage, []byte("hello")))
+		op, p, err := readFrame(br)
+		assert.NoError(t, err)
+		assert.Equal(t, byte(websocket.TextMessage), op)
+		assert.Equal(t, "hello", string(p))
+
+		// Ping
+		assert.NoError(t, writeFrame(conn, websocket.PingMessage, []byte("p")))
+		op, p, err = readFrame(br)
+		assert.NoError(t, err)
+		assert.Equal(t, byte(websocket.PongMessage), op)
+		assert.Equal(t, "p", string(p))
+
+		// Close
+		b := make([]byte, 2)
+		binary.BigEndian.PutUint16(b, websocket.CloseNormalClosure)
+		assert.NoError(t, writeFrame(conn, websocket.CloseMessage, b))
+		op, _, err = readFrame(br)
+		assert.NoError(t, err)
+		assert.Equal(t, byte(websocket.CloseMessage), op)
+	}
+}
+
+func TestWebSocketBadHandshake(t *testing.T) {
+	srv := newServer()
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/ws")
+	if assert.NoError(t, err) {
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	}
+}